@@ -0,0 +1,143 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/akaumov/cubes/instance"
+	"github.com/akaumov/cubes/utils"
+)
+
+// flushWriter sends streamed logs to client as soon as they're written
+type flushWriter struct {
+	writer  io.Writer
+	flusher http.Flusher
+}
+
+func (w flushWriter) Write(data []byte) (int, error) {
+	written, err := w.writer.Write(data)
+	w.flusher.Flush()
+	return written, err
+}
+
+func writeJson(writer http.ResponseWriter, value interface{}) {
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(value)
+}
+
+func startInstance(name string, request *http.Request) error {
+	var startRequest instance.StartRequest
+
+	err := json.NewDecoder(request.Body).Decode(&startRequest)
+	if err != nil {
+		return fmt.Errorf("can't parse instance config: %v", err)
+	}
+
+	config := startRequest.Config
+
+	if config.Name != name {
+		return fmt.Errorf("config doesn't belong to instance '%v'", name)
+	}
+
+	status, _ := instance.GetStatus(name)
+	if instance.IsActiveStatus(status) {
+		return fmt.Errorf("instance '%v' is already running", name)
+	}
+
+	err = instance.Put(config)
+	if err != nil {
+		return err
+	}
+
+	return instance.StartWithSecrets(name, startRequest.SecretParams)
+}
+
+func handleInstance(writer http.ResponseWriter, request *http.Request, name string, action string) error {
+	switch action {
+	case "start":
+		return startInstance(name, request)
+	case "stop":
+		return instance.Stop(name)
+	case "pause":
+		return instance.Pause(name)
+	case "resume":
+		return instance.Resume(name)
+	case "status":
+		isExist, err := instance.IsExist(name)
+		if err != nil {
+			return err
+		}
+
+		// instance config is sent to agent on first start
+		status := instance.StatusStopped
+		if isExist {
+			status, err = instance.GetStatus(name)
+			if err != nil {
+				return err
+			}
+		}
+
+		writeJson(writer, instance.AgentStatus{
+			Status: status,
+		})
+		return nil
+	case "metrics":
+		metrics, err := instance.GetMetrics(name)
+		if err != nil {
+			return err
+		}
+
+		writeJson(writer, metrics)
+		return nil
+	case "logs":
+		var output io.Writer = writer
+
+		flusher, ok := writer.(http.Flusher)
+		if ok {
+			output = flushWriter{writer: writer, flusher: flusher}
+		}
+
+		return instance.Logs(name, request.URL.Query().Get("follow") == "true", output)
+	}
+
+	return fmt.Errorf("unknown action '%v'", action)
+}
+
+func serveHTTP(writer http.ResponseWriter, request *http.Request) {
+	path := strings.TrimPrefix(request.URL.Path, "/v1/instances/")
+	parts := strings.Split(path, "/")
+
+	if path == request.URL.Path || len(parts) != 2 || parts[0] == "" {
+		http.NotFound(writer, request)
+		return
+	}
+
+	name, action := parts[0], parts[1]
+	utils.Infof("%v %v from %v\n", action, name, request.RemoteAddr)
+
+	err := handleInstance(writer, request, name, action)
+	if err != nil {
+		utils.Warningf("can't %v instance %v: %v\n", action, name, err)
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Serve accepts instance lifecycle commands from cubes CLI, clients must have certificate signed by CA
+func Serve(address string, caFile string, certFile string, keyFile string) error {
+	tlsConfig, err := utils.GetTLSConfig(caFile, certFile, keyFile)
+	if err != nil {
+		return err
+	}
+
+	server := http.Server{
+		Addr:      address,
+		Handler:   http.HandlerFunc(serveHTTP),
+		TLSConfig: tlsConfig,
+	}
+
+	utils.Infof("Agent is listening on %v\n", address)
+	return server.ListenAndServeTLS("", "")
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/akaumov/cubes/utils"
+	"github.com/urfave/cli"
+)
+
+var auditCommand = cli.Command{
+	Name:  "audit",
+	Usage: "show audit log of state-changing operations of cubes commands and cubesd API: who, when, command and result, it's kept in .cubes/audit.log",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "since",
+			Usage: "show operations of last period: 1h, 24h",
+		},
+		cli.IntFlag{
+			Name:  "limit",
+			Value: 50,
+			Usage: "show last number of operations, 0 shows all",
+		},
+		cli.BoolFlag{
+			Name:  "json",
+			Usage: "print operations as json",
+		},
+	},
+	ArgsUsage: "[--since] [--limit] [--json]",
+	Action:    audit,
+}
+
+// dryRunCommands print what they'd do with --dry-run, other commands, which change state, refuse it
+var dryRunCommands = map[string]bool{
+	"instance remove": true,
+	"instance stop":   true,
+	"down":            true,
+	"migration sync":  true,
+	"migration reset": true,
+	"bus stop":        true,
+}
+
+// auditRedactedArgs are numbers of args of commands, which are recorded to audit log, the rest are secret
+var auditRedactedArgs = map[string]int{
+	"secret set": 1,
+}
+
+// auditRedactedValues are flags with lists of values: --params 'param1:value1;param2:value2', only keys of their
+// values are recorded to audit log, values are separated from keys by separator of flag
+var auditRedactedValues = map[string]string{
+	"params": ":",
+	"env":    "=",
+}
+
+// redactValues replaces values of list with ***: param1:***;param2:***, items without separator are replaced whole
+func redactValues(rawList string, separator string) string {
+	items := strings.Split(rawList, ";")
+
+	for i, item := range items {
+		parts := strings.SplitN(item, separator, 2)
+		if len(parts) != 2 {
+			items[i] = "***"
+			continue
+		}
+
+		items[i] = parts[0] + separator + "***"
+	}
+
+	return strings.Join(items, ";")
+}
+
+// isSecretFlag returns true for flags, which values aren't recorded to audit log
+func isSecretFlag(name string) bool {
+	for _, word := range []string{"password", "token", "secret", "key"} {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getCommandPath returns command without name of executable: instance start
+func getCommandPath(c *cli.Context) string {
+	// help name is full command with name of executable: cubes instance start
+	command := c.Command.HelpName
+	if index := strings.Index(command, " "); index != -1 {
+		command = command[index+1:]
+	}
+
+	return command
+}
+
+// audited records run of state-changing command to audit log of project, values of secret flags aren't recorded
+func audited(action func(c *cli.Context) error) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		command := getCommandPath(c)
+
+		// dry run doesn't change anything, so it isn't recorded
+		if utils.IsDryRun() {
+			if !dryRunCommands[command] {
+				return utils.ValidationError(fmt.Errorf("%v doesn't support --dry-run", command))
+			}
+
+			return action(c)
+		}
+
+		entry := utils.AuditEntry{
+			Time:    time.Now(),
+			Source:  utils.AuditSourceCli,
+			Command: command,
+			Args:    c.Args(),
+			Flags:   map[string]string{},
+		}
+
+		if count, ok := auditRedactedArgs[command]; ok && len(entry.Args) > count {
+			entry.Args = append(append([]string{}, entry.Args[:count]...), "***")
+		}
+
+		for _, name := range c.FlagNames() {
+			if !c.IsSet(name) {
+				continue
+			}
+
+			entry.Flags[name] = c.String(name)
+			if isSecretFlag(name) {
+				entry.Flags[name] = "***"
+			} else if separator, ok := auditRedactedValues[name]; ok {
+				entry.Flags[name] = redactValues(entry.Flags[name], separator)
+			}
+		}
+
+		entry.Result = utils.AuditStarted
+
+		auditErr := utils.RecordAudit(entry)
+		if auditErr != nil {
+			utils.Warningf("Can't record operation to audit log: %v\n", auditErr)
+		}
+
+		err := action(c)
+
+		entry.Time = time.Now()
+		entry.Result = utils.AuditOk
+		if err != nil {
+			entry.Result = utils.AuditFailed
+			entry.Error = err.Error()
+		}
+
+		auditErr = utils.RecordAudit(entry)
+		if auditErr != nil {
+			utils.Warningf("Can't record operation to audit log: %v\n", auditErr)
+		}
+
+		return err
+	}
+}
+
+func audit(c *cli.Context) error {
+	since := time.Time{}
+
+	if c.String("since") != "" {
+		period, err := time.ParseDuration(c.String("since"))
+		if err != nil {
+			return fmt.Errorf("wrong period %v: %v", c.String("since"), err)
+		}
+
+		since = time.Now().Add(-period)
+	}
+
+	if c.Int("limit") < 0 {
+		return fmt.Errorf("limit can't be negative")
+	}
+
+	entries, err := utils.GetAuditEntries(since)
+	if err != nil {
+		return err
+	}
+
+	if limit := c.Int("limit"); limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+
+	if c.Bool("json") {
+		entriesText, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(entriesText))
+		return nil
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "TIME\tUSER\tSOURCE\tCOMMAND\tRESULT")
+
+	for _, entry := range entries {
+		command := strings.Join(append([]string{entry.Command}, entry.Args...), " ")
+
+		flagsNames := []string{}
+		for name := range entry.Flags {
+			flagsNames = append(flagsNames, name)
+		}
+
+		sort.Strings(flagsNames)
+
+		for _, name := range flagsNames {
+			command += fmt.Sprintf(" --%v=%v", name, entry.Flags[name])
+		}
+
+		user := entry.User + "@" + entry.Host
+		if entry.Client != "" {
+			user += " (" + entry.Client + ")"
+		}
+
+		result := entry.Result
+		if entry.Error != "" {
+			result += ": " + entry.Error
+		}
+
+		fmt.Fprintf(writer, "%v\t%v\t%v\t%v\t%v\n", entry.Time.Local().Format("2006-01-02 15:04:05"), user, entry.Source, command, result)
+	}
+
+	return writer.Flush()
+}
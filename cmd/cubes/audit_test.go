@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestRedactValues(t *testing.T) {
+	cases := []struct {
+		list      string
+		separator string
+		expected  string
+	}{
+		{"user:admin;password:secret", ":", "user:***;password:***"},
+		{"LOG_LEVEL=debug;API_KEY=abc=def", "=", "LOG_LEVEL=***;API_KEY=***"},
+		{"broken;host:db", ":", "***;host:***"},
+	}
+
+	for _, c := range cases {
+		if redacted := redactValues(c.list, c.separator); redacted != c.expected {
+			t.Errorf("%v: expected %v, got %v", c.list, c.expected, redacted)
+		}
+	}
+}
+
+func TestSecretFlags(t *testing.T) {
+	for _, name := range []string{"password", "db-password", "token", "tls-key", "secret"} {
+		if !isSecretFlag(name) {
+			t.Errorf("flag %v isn't secret", name)
+		}
+	}
+
+	for _, name := range []string{"group", "label", "timeout"} {
+		if isSecretFlag(name) {
+			t.Errorf("flag %v is secret", name)
+		}
+	}
+
+	for _, name := range []string{"params", "env"} {
+		if _, ok := auditRedactedValues[name]; !ok {
+			t.Errorf("values of flag %v are recorded to audit log", name)
+		}
+	}
+}
@@ -0,0 +1,871 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/akaumov/cubes/global"
+	"github.com/akaumov/cubes/instance"
+	"github.com/akaumov/cubes/utils"
+	"github.com/eclipse/paho.mqtt.golang"
+	"github.com/urfave/cli"
+)
+
+var busCommand = cli.Command{
+	Name:  "bus",
+	Usage: "cubes bus",
+	Subcommands: []cli.Command{
+		{
+			Name:  "start",
+			Usage: "start cubes bus",
+			Description: `TLS is enabled when .cubes/tls/bus has server.pem and server-key.pem,
+   ca.pem enables clients verification, instances get client.pem and client-key.pem.
+
+   Keys of project.json:
+     isBusAuthEnabled          bus accepts only clients with credentials,
+                               instances connect with their own ones
+     persistentChannels        channels are kept on bus until delivered or their
+                               maxAgeSeconds, maxMessages and maxBytes retention
+                               is reached, isReliable ones are redelivered until
+                               acknowledged
+     busMetricsAddress         starts bus metrics
+     isDelayedDeliveryEnabled  starts scheduler of delayed messages
+     busPort                   host port of bus
+     busMonitoringPort         host port of bus monitoring
+     isBusEncryptionEnabled    encrypts stored messages
+     busHost                   points cubes to remote bus, which isn't started
+                               here, it can be set in profile too
+
+   With --daemon bus is kept by "cubes bus run" in background, its pid and log
+   are in .cubes/bus, bus status shows it and bus stop stops it.`,
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "daemon",
+					Usage: "keep bus by cubes process in background, which stops bus when it's stopped",
+				},
+				cli.IntFlag{
+					Name:  "timeout",
+					Value: 30,
+					Usage: "seconds to wait for connections drain, when daemon stops bus",
+				},
+			},
+			ArgsUsage: "[--daemon] [--timeout]",
+			Action:    audited(startBus),
+		},
+		{
+			Name:  "run",
+			Usage: "start cubes bus and keep it until Ctrl+C, bus is drained and stopped then",
+			Flags: []cli.Flag{
+				cli.IntFlag{
+					Name:  "timeout",
+					Value: 30,
+					Usage: "seconds to wait for connections drain before bus is stopped",
+				},
+			},
+			ArgsUsage: "[--timeout]",
+			Action:    audited(runBus),
+		},
+		{
+			Name:  "stop",
+			Usage: "drain connections and stop cubes bus",
+			Flags: []cli.Flag{
+				cli.IntFlag{
+					Name:  "timeout",
+					Value: 30,
+					Usage: "seconds to wait for connections drain before bus is stopped",
+				},
+			},
+			ArgsUsage: "[--timeout]",
+			Action:    audited(stopBus),
+		},
+		{
+			Name:  "status",
+			Usage: "show cubes bus status and connected clients",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "json",
+					Usage: "print status in json",
+				},
+			},
+			ArgsUsage: "[--json]",
+			Action:    busStatus,
+		},
+		{
+			Name:  "publish",
+			Usage: "publish json message to bus channel, message is read from stdin when --data and --file are omitted",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "data",
+					Usage: "json message",
+				},
+				cli.StringFlag{
+					Name:  "file",
+					Usage: "file with json message",
+				},
+				cli.DurationFlag{
+					Name:  "delay",
+					Usage: "deliver message after delay: --delay 10m, it needs isDelayedDeliveryEnabled in project.json",
+				},
+				cli.StringFlag{
+					Name:  "at",
+					Usage: "deliver message at RFC3339 time, it needs isDelayedDeliveryEnabled in project.json",
+				},
+				headersFlag,
+			},
+			ArgsUsage: "channel [--data | --file] [--delay | --at] [--headers]",
+			Action:    audited(busPublish),
+		},
+		{
+			Name:  "subscribe",
+			Usage: "print messages of bus channels until Ctrl+C, channels can have wildcards: users.*, users.>",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "filter",
+					Usage: "print only json messages with values at paths: \"method=create;params.user.id=12;headers.trace-id=abc\"",
+				},
+			},
+			ArgsUsage: "channel... [--filter]",
+			Action:    busSubscribe,
+		},
+		{
+			Name:  "request",
+			Usage: "send json request to bus channel and print response, request is read from stdin when --data and --file are omitted",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "data",
+					Usage: "json request",
+				},
+				cli.StringFlag{
+					Name:  "file",
+					Usage: "file with json request",
+				},
+				cli.DurationFlag{
+					Name:  "timeout",
+					Value: 5 * time.Second,
+					Usage: "time to wait for response",
+				},
+				headersFlag,
+			},
+			ArgsUsage: "channel [--data | --file] [--timeout] [--headers]",
+			Action:    audited(busRequest),
+		},
+		{
+			Name:  "channels",
+			Usage: "list bus channels of instances mappings with instances using them, subscriptions are shown when bus is running",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "json",
+					Usage: "print channels in json",
+				},
+			},
+			ArgsUsage: "[--json]",
+			Action:    busChannels,
+		},
+		{
+			Name:  "metrics",
+			Usage: "serve prometheus metrics of bus and its channels",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "listen",
+					Value: ":9101",
+					Usage: "metrics endpoint address",
+				},
+			},
+			ArgsUsage: "[--listen]",
+			Action:    busMetrics,
+		},
+		{
+			Name:  "dashboard",
+			Usage: "serve web page with live throughput of channels, connected instances and recent messages",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "listen",
+					Value: "localhost:8090",
+					Usage: "dashboard address",
+				},
+			},
+			ArgsUsage: "[--listen]",
+			Action:    busDashboard,
+		},
+		{
+			Name:  "bridge",
+			Usage: "bridge bus with other brokers",
+			Subcommands: []cli.Command{
+				{
+					Name:  "mqtt",
+					Usage: "forward messages between mqtt broker and bus until Ctrl+C, topics and channels shouldn't overlap",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "broker",
+							Usage: "mqtt broker: tcp://host:1883, ssl://host:8883",
+						},
+						cli.StringFlag{
+							Name:  "topics",
+							Usage: "mqtt topics forwarded to bus channels, channel is converted from topic when it's omitted: --topics 'sensors/+/temperature;devices/#:devices.events'",
+						},
+						cli.StringFlag{
+							Name:  "channels",
+							Usage: "bus channels forwarded to mqtt topics, topic is converted from channel when it's omitted: --channels 'commands.>;alerts:devices/alerts'",
+						},
+						cli.StringFlag{
+							Name:  "client-id",
+							Value: "cubes-bridge",
+							Usage: "mqtt client id",
+						},
+						cli.StringFlag{
+							Name:   "username",
+							Usage:  "mqtt username",
+							EnvVar: "CUBES_MQTT_USERNAME",
+						},
+						cli.StringFlag{
+							Name:   "password",
+							Usage:  "mqtt password",
+							EnvVar: "CUBES_MQTT_PASSWORD",
+						},
+					},
+					ArgsUsage: "--broker [--topics] [--channels] [--client-id] [--username] [--password]",
+					Action:    audited(bridgeMQTT),
+				},
+				{
+					Name:  "bus",
+					Usage: "forward channels between bus of project and remote bus of other project until Ctrl+C, exported and imported channels shouldn't overlap",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "remote",
+							Usage: "remote bus: nats://staging.example.com:4444",
+						},
+						cli.StringFlag{
+							Name:  "export",
+							Usage: "allowed local channels forwarded to remote bus, they can be renamed: --export 'orders.>;invoices:billing.invoices'",
+						},
+						cli.StringFlag{
+							Name:  "import",
+							Usage: "allowed remote channels forwarded to local bus, they can be renamed: --import 'billing.events.>;payments:payments.remote'",
+						},
+						cli.StringFlag{
+							Name:   "user",
+							Usage:  "user of remote bus",
+							EnvVar: "CUBES_REMOTE_BUS_USER",
+						},
+						cli.StringFlag{
+							Name:   "password",
+							Usage:  "password of remote bus",
+							EnvVar: "CUBES_REMOTE_BUS_PASSWORD",
+						},
+						cli.StringFlag{
+							Name:  "ca",
+							Usage: "CA certificate of remote bus with TLS",
+						},
+						cli.StringFlag{
+							Name:  "cert",
+							Usage: "client certificate for remote bus, which verifies clients",
+						},
+						cli.StringFlag{
+							Name:  "key",
+							Usage: "client key for remote bus, which verifies clients",
+						},
+					},
+					ArgsUsage: "--remote [--export] [--import] [--user] [--password] [--ca] [--cert] [--key]",
+					Action:    audited(bridgeBus),
+				},
+			},
+		},
+		{
+			Name:  "bench",
+			Usage: "send requests to channel and measure throughput and latency of responses",
+			Flags: []cli.Flag{
+				cli.IntFlag{
+					Name:  "size",
+					Value: 256,
+					Usage: "size of request params in bytes",
+				},
+				cli.IntFlag{
+					Name:  "rate",
+					Value: 1000,
+					Usage: "requests per second, 0 sends requests as fast as possible",
+				},
+				cli.IntFlag{
+					Name:  "count",
+					Value: 10000,
+					Usage: "number of requests",
+				},
+				cli.DurationFlag{
+					Name:  "timeout",
+					Value: 10 * time.Second,
+					Usage: "time to wait for responses after the last request",
+				},
+				cli.BoolFlag{
+					Name:  "echo",
+					Usage: "answer requests by temporary built-in echo instance, it's removed after bench",
+				},
+				cli.BoolFlag{
+					Name:  "json",
+					Usage: "print result in json",
+				},
+			},
+			ArgsUsage: "channel [--size] [--rate] [--count] [--timeout] [--echo] [--json]",
+			Action:    audited(busBench),
+		},
+		{
+			Name:  "replay",
+			Usage: "republish stored messages of persistent channel, every consumer of channel gets them",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "from",
+					Usage: "first message: sequence, RFC3339 time or duration before now: --from 2h",
+				},
+				cli.StringFlag{
+					Name:  "until",
+					Usage: "last message: sequence, RFC3339 time or duration before now",
+				},
+				cli.StringFlag{
+					Name:  "to",
+					Usage: "instance which should get messages, replay is refused when other instances consume channel too",
+				},
+				cli.BoolFlag{
+					Name:  "force",
+					Usage: "replay to instance even if other instances consume channel",
+				},
+			},
+			ArgsUsage: "channel [--from] [--until] [--to] [--force]",
+			Action:    audited(busReplay),
+		},
+		{
+			Name:   "validate",
+			Usage:  "check messages of channels with channelSchemas or maxMessageSize of channelLimits in project.json until Ctrl+C, wrong messages are published to deadletter.<channel>",
+			Action: busValidate,
+		},
+		{
+			Name:   "scheduler",
+			Usage:  "deliver delayed messages until Ctrl+C, it's started with bus when isDelayedDeliveryEnabled is set in project.json",
+			Action: audited(busScheduler),
+		},
+		{
+			Name:   "protocol",
+			Usage:  "answer protocol exchanges of instances until Ctrl+C, it's started with bus",
+			Action: audited(busProtocol),
+		},
+		{
+			Name:  "consumers",
+			Usage: "print instances consuming reliable persistent channels with their pending, unacknowledged and redelivered messages",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "json",
+					Usage: "print consumers in json",
+				},
+			},
+			ArgsUsage: "[--json]",
+			Action:    busConsumers,
+		},
+		{
+			Name:  "groups",
+			Usage: "print queue groups with their member instances, channels and pending messages",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "json",
+					Usage: "print queue groups in json",
+				},
+			},
+			ArgsUsage: "[--json]",
+			Action:    busGroups,
+			Subcommands: []cli.Command{
+				{
+					Name:      "drain",
+					Usage:     "stop members of queue group after they finish messages they handle, reliable channels keep new messages until members are started",
+					ArgsUsage: "group",
+					Action:    audited(busGroupsDrain),
+				},
+				{
+					Name:      "reset",
+					Usage:     "drop messages of reliable channels, which members of queue group haven't got or acknowledged",
+					ArgsUsage: "group",
+					Action:    audited(busGroupsReset),
+				},
+			},
+		},
+		{
+			Name:      "backup",
+			Usage:     "write stopped bus state to gzipped tar: persistent channels with consumers positions, bus users, bus certificates and project.json",
+			ArgsUsage: "backupPath",
+			Action:    busBackup,
+		},
+		{
+			Name:  "restore",
+			Usage: "replace bus state with state from backup, bus must be stopped",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "force",
+					Usage: "replace existing bus state",
+				},
+				cli.BoolFlag{
+					Name:  "config",
+					Usage: "restore project.json from backup too",
+				},
+			},
+			ArgsUsage: "[--force] [--config] backupPath",
+			Action:    audited(busRestore),
+		},
+	},
+}
+
+var headersFlag = cli.StringFlag{
+	Name:  "headers",
+	Usage: "message headers: --headers 'content-type:application/json;schema-version:2;trace-id:4bf92f35;custom-key:value'",
+}
+
+func startBus(c *cli.Context) error {
+	if utils.IsBusRemote() {
+		return fmt.Errorf("bus is on %v, remote bus isn't started by cubes", utils.GetBusHost())
+	}
+
+	if c.Bool("daemon") {
+		return global.StartBusDaemon(time.Duration(c.Int("timeout")) * time.Second)
+	}
+
+	return global.StartBus()
+}
+
+func runBus(c *cli.Context) error {
+	if utils.IsBusRemote() {
+		return fmt.Errorf("bus is on %v, remote bus isn't started by cubes", utils.GetBusHost())
+	}
+
+	return global.RunBus(time.Duration(c.Int("timeout")) * time.Second)
+}
+
+func busStatus(c *cli.Context) error {
+	status, err := global.GetBusStatus()
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("json") {
+		statusText, err := json.MarshalIndent(status, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(statusText))
+		return nil
+	}
+
+	if status.DaemonPid != 0 {
+		fmt.Printf("bus daemon is running with pid %v, log %v\n", status.DaemonPid, status.DaemonLog)
+	}
+
+	if !status.IsRunning {
+		fmt.Println("bus is stopped")
+		return nil
+	}
+
+	fmt.Printf("bus is running on %v, uptime %v, protocol %v\n", status.Address, time.Duration(status.UptimeSeconds)*time.Second, status.Protocol)
+
+	if status.MonitoringError != "" {
+		fmt.Printf("can't read bus clients: %v\n", status.MonitoringError)
+		return nil
+	}
+
+	fmt.Printf("version %v, %v clients, %v subscriptions\n\n", status.Version, len(status.Clients), status.Subscriptions)
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "INSTANCE\tADDRESS\tSUBSCRIPTIONS\tIN MSGS\tOUT MSGS")
+
+	for _, client := range status.Clients {
+		name := client.Instance
+		if name == "" {
+			name = "-"
+		}
+
+		fmt.Fprintf(writer, "%v\t%v\t%v\t%v\t%v\n", name, client.Address, client.Subscriptions, client.InMessages, client.OutMessages)
+	}
+
+	return writer.Flush()
+}
+
+func busChannels(c *cli.Context) error {
+	channels, err := global.GetBusChannels()
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("json") {
+		channelsText, err := json.MarshalIndent(channels, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(channelsText))
+		return nil
+	}
+
+	if channels.MonitoringError != "" {
+		fmt.Printf("can't read bus subscriptions: %v\n\n", channels.MonitoringError)
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "CHANNEL\tINSTANCE\tCUBE CHANNEL\tDIRECTION\tSUBSCRIBED")
+
+	for _, channel := range channels.Channels {
+		for _, endpoint := range channel.Endpoints {
+			direction := endpoint.Direction
+			if direction == "" {
+				direction = "-"
+			}
+
+			isSubscribed := "-"
+			if channels.IsLive {
+				isSubscribed = strconv.FormatBool(endpoint.IsSubscribed)
+			}
+
+			fmt.Fprintf(writer, "%v\t%v\t%v\t%v\t%v\n", channel.Channel, endpoint.Instance, endpoint.CubeChannel, direction, isSubscribed)
+		}
+
+		for _, subscriber := range channel.Subscribers {
+			fmt.Fprintf(writer, "%v\t%v\t-\t%v\ttrue\n", channel.Channel, subscriber, instance.ChannelIn)
+		}
+	}
+
+	return writer.Flush()
+}
+
+func busMetrics(c *cli.Context) error {
+	return global.ServeBusMetrics(c.String("listen"))
+}
+
+func busDashboard(c *cli.Context) error {
+	return global.ServeBusDashboard(c.String("listen"))
+}
+
+func busValidate(c *cli.Context) error {
+	return global.ServeSchemaValidation()
+}
+
+func busScheduler(c *cli.Context) error {
+	return global.ServeDelayedDelivery()
+}
+
+func busProtocol(c *cli.Context) error {
+	return global.ServeBusProtocol()
+}
+
+func busConsumers(c *cli.Context) error {
+	consumers, err := global.GetReliableConsumers()
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("json") {
+		consumersText, err := json.MarshalIndent(consumers, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(consumersText))
+		return nil
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "CHANNEL\tINSTANCE\tDELIVERY CHANNEL\tPENDING\tUNACKED\tREDELIVERED")
+
+	for _, consumer := range consumers {
+		if consumer.Error != "" {
+			fmt.Fprintf(writer, "%v\t%v\t%v\t%v\n", consumer.Channel, consumer.Instance, consumer.DeliveryChannel, consumer.Error)
+			continue
+		}
+
+		fmt.Fprintf(writer, "%v\t%v\t%v\t%v\t%v\t%v\n", consumer.Channel, consumer.Instance, consumer.DeliveryChannel,
+			consumer.Pending, consumer.Unacknowledged, consumer.Redelivered)
+	}
+
+	return writer.Flush()
+}
+
+func busGroups(c *cli.Context) error {
+	groups, err := global.GetQueueGroups()
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("json") {
+		groupsText, err := json.MarshalIndent(groups, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(groupsText))
+		return nil
+	}
+
+	if groups.MonitoringError != "" {
+		fmt.Printf("Can't read bus clients, pending messages are unknown: %v\n", groups.MonitoringError)
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "GROUP\tCHANNELS\tPENDING MESSAGES\tINSTANCE\tSTATUS\tCONNECTED\tPENDING BYTES")
+
+	for _, group := range groups.Groups {
+		channels := strings.Join(group.Channels, ";")
+		pendingMessages := "-"
+
+		if groups.IsLive {
+			pendingMessages = strconv.FormatUint(group.PendingMessages, 10)
+		}
+
+		for _, member := range group.Members {
+			connected := "-"
+			pendingBytes := "-"
+
+			if groups.IsLive {
+				connected = strconv.FormatBool(member.IsConnected)
+				pendingBytes = strconv.Itoa(member.PendingBytes)
+			}
+
+			fmt.Fprintf(writer, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n", group.Name, channels, pendingMessages, member.Instance,
+				member.Status, connected, pendingBytes)
+
+			// group columns are printed only in first row of group
+			channels = ""
+			pendingMessages = ""
+		}
+	}
+
+	return writer.Flush()
+}
+
+func busGroupsDrain(c *cli.Context) error {
+	name := c.Args().Get(0)
+	if name == "" {
+		return fmt.Errorf("queue group is required")
+	}
+
+	err := global.DrainQueueGroup(name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Queue group %v is drained\n", name)
+	return nil
+}
+
+func busGroupsReset(c *cli.Context) error {
+	name := c.Args().Get(0)
+	if name == "" {
+		return fmt.Errorf("queue group is required")
+	}
+
+	err := global.ResetQueueGroup(name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Queue group %v is reset\n", name)
+	return nil
+}
+
+func busBackup(c *cli.Context) error {
+	backupPath := c.Args().Get(0)
+	if backupPath == "" {
+		return fmt.Errorf("backup path is required")
+	}
+
+	err := global.BackupBus(backupPath)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Bus state is saved to %v\n", backupPath)
+	return nil
+}
+
+func busRestore(c *cli.Context) error {
+	backupPath := c.Args().Get(0)
+	if backupPath == "" {
+		return fmt.Errorf("backup path is required")
+	}
+
+	err := global.RestoreBus(backupPath, c.Bool("force"), c.Bool("config"))
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Bus state is restored from %v\n", backupPath)
+	return nil
+}
+
+func bridgeMQTT(c *cli.Context) error {
+	broker := c.String("broker")
+	if broker == "" {
+		return fmt.Errorf("broker is required")
+	}
+
+	topics, err := global.ParseBridgeRoutes(c.String("topics"))
+	if err != nil {
+		return err
+	}
+
+	channels, err := global.ParseBridgeRoutes(c.String("channels"))
+	if err != nil {
+		return err
+	}
+
+	options := mqtt.NewClientOptions().
+		SetClientID(c.String("client-id")).
+		SetUsername(c.String("username")).
+		SetPassword(c.String("password"))
+
+	return global.BridgeMQTT(broker, options, *topics, *channels)
+}
+
+func bridgeBus(c *cli.Context) error {
+	remote := c.String("remote")
+	if remote == "" {
+		return fmt.Errorf("remote is required")
+	}
+
+	exports, err := global.ParseBridgeRoutes(c.String("export"))
+	if err != nil {
+		return err
+	}
+
+	imports, err := global.ParseBridgeRoutes(c.String("import"))
+	if err != nil {
+		return err
+	}
+
+	return global.BridgeBus(global.RemoteBus{
+		URL:      remote,
+		User:     c.String("user"),
+		Password: c.String("password"),
+		CAFile:   c.String("ca"),
+		CertFile: c.String("cert"),
+		KeyFile:  c.String("key"),
+	}, *exports, *imports)
+}
+
+func busBench(c *cli.Context) error {
+	result, err := global.Bench(c.Args().Get(0), global.BenchOptions{
+		Size:    c.Int("size"),
+		Rate:    c.Int("rate"),
+		Count:   c.Int("count"),
+		Timeout: c.Duration("timeout"),
+	}, c.Bool("echo"))
+
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("json") {
+		resultText, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(resultText))
+		return nil
+	}
+
+	fmt.Printf("received %v of %v responses in %.2fs, %.1f msg/s\n", result.Received, result.Sent, result.DurationSeconds, result.Throughput)
+	fmt.Printf("latency p50 %.2fms, p90 %.2fms, p99 %.2fms, max %.2fms\n",
+		result.LatencyP50Millis, result.LatencyP90Millis, result.LatencyP99Millis, result.LatencyMaxMillis)
+
+	return nil
+}
+
+func busReplay(c *cli.Context) error {
+	from, err := global.ParseReplayPosition(c.String("from"))
+	if err != nil {
+		return err
+	}
+
+	until, err := global.ParseReplayPosition(c.String("until"))
+	if err != nil {
+		return err
+	}
+
+	replayed, err := global.Replay(c.Args().Get(0), *from, *until, c.String("to"), c.Bool("force"))
+	fmt.Printf("replayed %v messages\n", replayed)
+	return err
+}
+
+// readMessageData reads message from --data or --file flags or from stdin
+func readMessageData(c *cli.Context) ([]byte, error) {
+	if c.IsSet("data") {
+		return []byte(c.String("data")), nil
+	}
+
+	if c.String("file") != "" {
+		return ioutil.ReadFile(c.String("file"))
+	}
+
+	return ioutil.ReadAll(os.Stdin)
+}
+
+func busPublish(c *cli.Context) error {
+	data, err := readMessageData(c)
+	if err != nil {
+		return fmt.Errorf("can't read message: %v", err)
+	}
+
+	headers, err := global.ParseMessageHeaders(c.String("headers"))
+	if err != nil {
+		return err
+	}
+
+	if c.IsSet("delay") && c.IsSet("at") {
+		return fmt.Errorf("only one of delay and at can be set")
+	}
+
+	if c.IsSet("delay") {
+		return global.PublishDelayed(c.Args().Get(0), data, headers, time.Now().Add(c.Duration("delay")))
+	}
+
+	if c.IsSet("at") {
+		deliverAt, err := time.Parse(time.RFC3339, c.String("at"))
+		if err != nil {
+			return fmt.Errorf("wrong time of delivery: %v", err)
+		}
+
+		return global.PublishDelayed(c.Args().Get(0), data, headers, deliverAt)
+	}
+
+	return global.Publish(c.Args().Get(0), data, headers)
+}
+
+func busSubscribe(c *cli.Context) error {
+	filters, err := global.ParseMessageFilters(c.String("filter"))
+	if err != nil {
+		return err
+	}
+
+	return global.Subscribe(c.Args(), *filters, os.Stdout)
+}
+
+func busRequest(c *cli.Context) error {
+	data, err := readMessageData(c)
+	if err != nil {
+		return fmt.Errorf("can't read request: %v", err)
+	}
+
+	headers, err := global.ParseMessageHeaders(c.String("headers"))
+	if err != nil {
+		return err
+	}
+
+	response, err := global.Request(c.Args().Get(0), data, headers, c.Duration("timeout"))
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(global.FormatMessage(response))
+	return nil
+}
+
+func stopBus(c *cli.Context) error {
+	return global.StopBus(time.Duration(c.Int("timeout")) * time.Second)
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/akaumov/cubes/global"
+	"github.com/akaumov/cubes/instance"
+	"github.com/akaumov/cubes/utils"
+	"github.com/urfave/cli"
+)
+
+var testCommand = cli.Command{
+	Name:  "test",
+	Usage: "test instance: start its copy with test params on ephemeral bus, publish fixture messages of test spec and check responses and published messages, bus of project must be stopped",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "spec",
+			Usage: "path of test spec, it's tests/<instance>.json by default",
+		},
+		cli.BoolFlag{
+			Name:  "json",
+			Usage: "print report as json",
+		},
+	},
+	ArgsUsage: "instance [--spec] [--json]",
+	Action:    audited(testInstance),
+}
+
+var contractsCommand = cli.Command{
+	Name:  "contracts",
+	Usage: "contracts of bus channels: schemas of channels in cube meta and channelSchemas of project config",
+	Subcommands: []cli.Command{
+		{
+			Name:  "check",
+			Usage: "check that messages of every producer of bus channel are accepted by schemas of its consumers, it fails when any contract is broken",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "json",
+					Usage: "print report as json",
+				},
+			},
+			ArgsUsage: "[--json]",
+			Action:    contractsCheck,
+		},
+	},
+}
+
+var buildCommand = cli.Command{
+	Name:  "build",
+	Usage: "build cube from local source",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "instance",
+			Usage: "build instance's path source and use the build as its executable",
+		},
+	},
+	ArgsUsage: "[--instance name] [sourcePath]",
+	Action:    audited(build),
+}
+
+var updateCommand = cli.Command{
+	Name:  "update",
+	Usage: "resolve git and docker sources of instances again and record new commits and digests in cubes.lock",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "json",
+			Usage: "print updated sources as json",
+		},
+	},
+	ArgsUsage: "[--json] [instance...]",
+	Action:    audited(update),
+}
+
+var generateCommand = cli.Command{
+	Name:  "generate",
+	Usage: "generate code from project config",
+	Subcommands: []cli.Command{
+		{
+			Name:  "cube",
+			Usage: "generate cube skeleton for channels of instance: typed messages of channel schemas, handler and stubs of handlers",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "lang",
+					Value: global.LangGo,
+					Usage: "language of cube, only go is supported",
+				},
+				cli.StringFlag{
+					Name:  "output",
+					Usage: "directory of cube, it's cubes/<instance> by default",
+				},
+				cli.StringFlag{
+					Name:  "package",
+					Usage: "go package name, it's name of output directory by default",
+				},
+				cli.BoolFlag{
+					Name:  "json",
+					Usage: "print generated files as json",
+				},
+			},
+			ArgsUsage: "[--lang] [--output] [--package] [--json] instance",
+			Action:    audited(generateCube),
+		},
+	},
+}
+
+func build(c *cli.Context) error {
+	var buildId string
+	var err error
+
+	name := c.String("instance")
+	if name != "" {
+		buildId, err = instance.BuildInstance(name)
+	} else {
+		sourcePath := c.Args().Get(0)
+		if sourcePath == "" {
+			return fmt.Errorf("source path is required")
+		}
+
+		buildId, err = instance.Build(sourcePath)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(buildId)
+	return nil
+}
+
+func update(c *cli.Context) error {
+	updates, err := instance.Update(c.Args())
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("json") {
+		updatesText, err := json.MarshalIndent(updates, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(updatesText))
+		return nil
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "SOURCE\tPREVIOUS\tCURRENT")
+
+	for _, sourceUpdate := range updates {
+		previous := sourceUpdate.Previous
+		if previous == "" {
+			previous = "-"
+		}
+
+		fmt.Fprintf(writer, "%v\t%v\t%v\n", sourceUpdate.Source, previous, sourceUpdate.Current)
+	}
+
+	return writer.Flush()
+}
+
+func generateCube(c *cli.Context) error {
+	name := c.Args().Get(0)
+	if name == "" {
+		return fmt.Errorf("instance name is required")
+	}
+
+	result, err := global.GenerateCube(global.GenerateOptions{
+		Instance: name,
+		Lang:     c.String("lang"),
+		Output:   c.String("output"),
+		Package:  c.String("package"),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("json") {
+		resultText, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(resultText))
+		return nil
+	}
+
+	for _, filePath := range result.Files {
+		fmt.Println(filePath)
+	}
+
+	utils.Infof("Implement handlers in handlers.go of %v, then add instance of cube with: cubes instance add %v go:%v\n", result.Directory, name, result.GoPackage)
+	return nil
+}
+
+func testInstance(c *cli.Context) error {
+	name := c.Args().Get(0)
+	if name == "" {
+		return fmt.Errorf("instance name is required")
+	}
+
+	report, err := global.RunTests(name, c.String("spec"))
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("json") {
+		reportText, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(reportText))
+	} else {
+		for _, result := range report.Cases {
+			if result.IsPassed {
+				fmt.Printf("PASS  %v (%.0fms)\n", result.Name, result.DurationMillis)
+				continue
+			}
+
+			fmt.Printf("FAIL  %v (%.0fms)\n", result.Name, result.DurationMillis)
+			for _, failure := range result.Failures {
+				fmt.Printf("      %v\n", failure)
+			}
+		}
+
+		fmt.Printf("%v passed, %v failed\n", report.Passed, report.Failed)
+	}
+
+	if report.Failed > 0 {
+		return utils.ValidationError(fmt.Errorf("%v of %v test cases failed", report.Failed, len(report.Cases)))
+	}
+
+	return nil
+}
+
+func contractsCheck(c *cli.Context) error {
+	report, err := global.CheckContracts()
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("json") {
+		reportText, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(reportText))
+	} else {
+		for _, problem := range report.Unchecked {
+			utils.Warningf("%v: %v -> %v isn't checked, %v\n", problem.Channel, problem.Producer, problem.Consumer, problem.Message)
+		}
+
+		if len(report.Problems) > 0 {
+			writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(writer, "CHANNEL\tPRODUCER\tCONSUMER\tPROBLEM")
+
+			for _, problem := range report.Problems {
+				fmt.Fprintf(writer, "%v\t%v\t%v\t%v\n", problem.Channel, problem.Producer, problem.Consumer, problem.Message)
+			}
+
+			err = writer.Flush()
+			if err != nil {
+				return err
+			}
+		}
+
+		fmt.Printf("%v contracts checked, %v problems\n", report.Checked, len(report.Problems))
+	}
+
+	if len(report.Problems) > 0 {
+		return utils.ValidationError(fmt.Errorf("contracts of bus channels are broken"))
+	}
+
+	return nil
+}
@@ -3,709 +3,279 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"os"
+	"runtime"
 	"strconv"
 	"strings"
+	"text/tabwriter"
 
-	"github.com/akaumov/cube_executor"
 	"github.com/akaumov/cubes/db"
-	"github.com/akaumov/cubes/global"
+	"github.com/akaumov/cubes/executor"
 	"github.com/akaumov/cubes/instance"
+	"github.com/akaumov/cubes/utils"
 	"github.com/urfave/cli"
 )
 
+// build metadata is set by release build:
+// go build -ldflags "-X main.version=1.2.0 -X main.commit=$(git rev-parse --short HEAD) -X main.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	version   = "0.0.1"
+	commit    = "unknown"
+	buildDate = "unknown"
+)
+
+type VersionInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"buildDate"`
+	GoVersion string `json:"goVersion"`
+
+	// InstanceSchemaVersion and MigrationSchemaVersion are versions of configs and migrations, which cubes writes
+	InstanceSchemaVersion  string `json:"instanceSchemaVersion"`
+	MigrationSchemaVersion string `json:"migrationSchemaVersion"`
+
+	BusProtocolVersion    int    `json:"busProtocolVersion"`
+	MinBusProtocolVersion int    `json:"minBusProtocolVersion"`
+	ExecutorVersion       string `json:"executorVersion"`
+}
+
+const logFileMaxSize = 10 * 1024 * 1024
+const logFileBackups = 3
+
+var parallelFlag = cli.IntFlag{
+	Name:  "parallel",
+	Value: instance.DefaultParallelism,
+	Usage: "number of instances, which are started at once, cubesd starts them one by one",
+}
+
 func main() {
 	app := cli.NewApp()
-	app.Version = "0.0.1"
-	app.Commands = []cli.Command{
-		{
-			Name:   "init",
-			Usage:  "init project",
-			ArgsUsage: "projectName [description]",
-			Action: initProject,
-		},		{
-			Name:   "start",
-			Usage:  "start project",
-			Action: startProject,
+	app.Version = version
+	app.Description = utils.ExitCodesDescription
+	cli.VersionPrinter = func(c *cli.Context) {
+		printVersion(false)
+	}
+
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:  "log-file",
+			Usage: "write log to file, it's rotated when it grows over 10 MB",
+		},
+		cli.BoolFlag{
+			Name:  "verbose",
+			Usage: "log details of commands, which help debugging",
 		},
-		{
-			Name:   "list",
-			Usage:  "list all instances",
-			Action: list,
+		cli.BoolFlag{
+			Name:  "quiet",
+			Usage: "log warnings and errors only",
 		},
-		{
-			Name:  "bus",
-			Usage: "cubes bus",
-			Subcommands: []cli.Command{
-				{
-					Name:   "start",
-					Usage:  "start cubes bus",
-					Action: startBus,
-				},
-			},
+		cli.StringFlag{
+			Name:   "log-format",
+			Value:  "text",
+			EnvVar: "CUBES_LOG_FORMAT",
+			Usage:  "format of log: text or json, json log has one object with time, level and message per line",
 		},
-		{
-			Name:  "instance",
-			Usage: "cube instance",
-			Subcommands: []cli.Command{
-				{
-					Name:  "add",
-					Usage: "adds cube instance",
-					Flags: []cli.Flag{
-						cli.StringFlag{
-							Name:  "channels",
-							Usage: "channels mapping: --channels 'cubeChannel1:busChannel1;cubeChannel2:busChannel2'",
-						},
-						cli.StringFlag{
-							Name:  "queueGroup",
-							Usage: "queue group name",
-						},
-						cli.StringFlag{
-							Name:  "class",
-							Usage: "class name",
-						},
-						cli.StringFlag{
-							Name:  "ports",
-							Usage: "ports mapping: --ports 'hostPort:handlerPort:protocol;80:8080:tcp'",
-						},
-						cli.StringFlag{
-							Name:  "params",
-							Usage: "params: --params 'param1:Value1;param2:Value2'",
-						},
-					},
-					ArgsUsage: "[--ports] [--channels] [--params] name source",
-					Action:    instanceAdd,
-				},
-				{
-					Name:      "config",
-					Usage:     "get cube instance config",
-					ArgsUsage: "instanceName",
-					Action:    instanceConfig,
-				},
-				{
-					Name:      "remove",
-					Usage:     "remove cube instance",
-					ArgsUsage: "name",
-					Action:    instanceRemove,
-				},
-				{
-					Name:   "start",
-					Usage:  "start cube instance",
-					Action: instanceStart,
-				},
-				{
-					Name:  "stop",
-					Usage: "stops cube instance",
-					Action: func(c *cli.Context) error {
-						log.Println("stop instance")
-						return nil
-					},
-				},
-			},
+		cli.BoolFlag{
+			Name:  "no-color",
+			Usage: "don't color log and progress, they're colored only on terminal, NO_COLOR turns colors off too",
 		},
-		{
-			Name:  "migration",
-			Usage: "manage migrations",
-			Subcommands: []cli.Command{
-				{
-					Name:   "add",
-					Usage:  "add migrationDescription",
-					Action: addMigration,
-				},
-				{
-					Name:   "list",
-					Usage:  "return migrations",
-					Action: listMigrations,
-				},
-				{
-					Name:   "snapshot",
-					Usage:  "return snapshot",
-					Action: migrationSnapshot,
-				},
-				{
-					Name:  "table",
-					Usage: "operations with tables",
-					Subcommands: []cli.Command{
-						{
-							Name:   "add",
-							Usage:  "add tableName",
-							Action: addTable,
-						},
-						{
-							Name:   "delete",
-							Usage:  "delete tableName",
-							Action: deleteTable,
-						},
-					},
-				},
-				{
-					Name:  "column",
-					Usage: "operations with columns of tables",
-					Subcommands: []cli.Command{
-						{
-							Name:  "add",
-							Usage: "add tableName columName columnType",
-							Flags: []cli.Flag{
-								cli.BoolTFlag{
-									Name:  "nullable",
-									Usage: "isNullable flag, default true",
-								},
-								cli.StringFlag{
-									Name:  "default",
-									Usage: "default value",
-								},
-							},
-							Action: addColumn,
-						},
-						{
-							Name:   "delete",
-							Usage:  "delete tableName columName",
-							Action: deleteColumn,
-						},
-					},
-				},
-
-				{
-					Name:  "primary",
-					Usage: "operations with primary keys",
-					Subcommands: []cli.Command{
-						{
-							Name:   "add",
-							Usage:  "add tableName columnName",
-							Action: addPrimaryKey,
-						},
-						{
-							Name:   "delete",
-							Usage:  "delete tableName columnName",
-							Action: deletePrimaryKey,
-						},
-					},
-				},
-				{
-					Name:   "sync",
-					Usage:  "sync migrations",
-					Action: syncMigrations,
-				},
-				{
-					Name:  "relation",
-					Usage: "define table relations",
-					Subcommands: []cli.Command{
-						{
-							Name:      "add",
-							ArgsUsage: "relation add relationName relationType tableName remoteTableName 'columnName1:remoteColumnName1;columnName2:remoteColumnName2'",
-							Action:    addRelation,
-						},
-						{
-							Name:      "delete",
-							ArgsUsage: "relation delete table relationName",
-							Action:    deleteRelation,
-						},
-					},
-				},
-				{
-					Name:  "unique",
-					Usage: "define unique constraints",
-					Subcommands: []cli.Command{
-						{
-							Name:      "add",
-							ArgsUsage: "unique add constraintName tableName 'columnName1;columnName2'",
-							Action:    addUniqueConstraint,
-						},
-						{
-							Name:      "delete",
-							ArgsUsage: "unique delete table constraintName",
-							Action:    deleteUniqueConstraint,
-						},
-					},
-				},
-			},
+		cli.StringFlag{
+			Name:   "env",
+			EnvVar: "CUBES_ENV",
+			Usage:  "profile of project config: --env staging, it replaces database, bus address, runtime and instances params",
+		},
+		cli.StringFlag{
+			Name:   "project",
+			EnvVar: utils.EnvProject,
+			Usage:  "project of command: name in workspace or path, command runs in project directory, outside of projects it's current project of workspace",
+		},
+		cli.BoolFlag{
+			Name:   "dry-run",
+			EnvVar: utils.EnvDryRun,
+			Usage:  "print files deleted, SQL run and processes signaled by instance remove, instance stop, down, migration sync, migration reset and bus stop without doing it",
 		},
 	}
+	app.Before = setGlobalOptions
+	app.Action = runPlugin
+	app.Commands = []cli.Command{
+		initCommand,
+		startCommand,
+		upCommand,
+		downCommand,
+		configCommand,
+		versionCommand,
+		secretCommand,
+		eventsCommand,
+		testCommand,
+		contractsCommand,
+		telemetryCommand,
+		notificationsCommand,
+		auditCommand,
+		pluginsCommand,
+		lintCommand,
+		doctorCommand,
+		statusCommand,
+		listCommand,
+		buildCommand,
+		updateCommand,
+		generateCommand,
+		publishCommand,
+		searchCommand,
+		installCommand,
+		pullCommand,
+		agentCommand,
+		daemonCommand,
+		serviceCommand,
+		workspaceCommand,
+		tuiCommand,
+		dashboardCommand,
+		busCommand,
+		instanceCommand,
+		migrationCommand,
+	}
+
+	measureCommands(app.Commands)
 
 	err := app.Run(os.Args)
 	if err != nil {
-		log.Fatal(err)
+		utils.ExitWithError(err, isJSONOutput(os.Args[1:]))
 	}
 }
 
-func parseChannelsMapping(channelsMappingRaw string) (*map[cube_executor.CubeChannel]cube_executor.BusChannel, error) {
-	channelsMapping := map[cube_executor.CubeChannel]cube_executor.BusChannel{}
-
-	if channelsMappingRaw != "" {
-
-		for _, rawMap := range strings.Split(channelsMappingRaw, ";") {
-			splittedMap := strings.Split(rawMap, ":")
-
-			if len(splittedMap) != 2 {
-				return nil, fmt.Errorf("Wrong channels mapping: %v\n", rawMap)
-			}
-
-			cubeChannel := cube_executor.CubeChannel(splittedMap[0])
-			busChannel := cube_executor.BusChannel(splittedMap[1])
-
-			channelsMapping[cubeChannel] = busChannel
+// isJSONOutput returns true when command is run with --json, its error is printed as json then
+func isJSONOutput(args []string) bool {
+	for _, arg := range args {
+		if arg == "--" {
+			return false
 		}
-	}
-
-	return &channelsMapping, nil
-}
-
-func parsePortsMapping(portsMappingRaw string) (*[]cube_executor.PortMap, error) {
-
-	portsMapping := []cube_executor.PortMap{}
 
-	if portsMappingRaw != "" {
-
-		for _, rawMap := range strings.Split(portsMappingRaw, ";") {
-			splittedMap := strings.Split(rawMap, ":")
-
-			if len(splittedMap) < 2 || len(splittedMap) > 3 {
-				return nil, fmt.Errorf("wrong ports mapping: %v\n", rawMap)
-			}
-
-			hostPort, err := strconv.ParseUint(splittedMap[0], 10, 32)
-			if err != nil {
-				return nil, fmt.Errorf("wrong host port format: %v/n", hostPort)
-			}
-
-			handlerPort, err := strconv.ParseUint(splittedMap[1], 10, 32)
-			if err != nil {
-				return nil, fmt.Errorf("wrong cube port format: %v/n", handlerPort)
-			}
-
-			if len(splittedMap) == 2 {
-				portsMapping = append(portsMapping, cube_executor.PortMap{
-					HostPort: cube_executor.HostPort(hostPort),
-					CubePort: cube_executor.CubePort(handlerPort),
-					Protocol: cube_executor.Protocol("udp"),
-				})
-
-				portsMapping = append(portsMapping, cube_executor.PortMap{
-					HostPort: cube_executor.HostPort(hostPort),
-					CubePort: cube_executor.CubePort(handlerPort),
-					Protocol: cube_executor.Protocol("tcp"),
-				})
-
-			} else {
-				protocol := splittedMap[2]
-
-				if protocol != "udp" && protocol != "tcp" {
-					return nil, fmt.Errorf("wrong port protocol: %v/n", protocol)
-				}
-			}
+		if !strings.HasPrefix(arg, "-") {
+			continue
 		}
-	}
-
-	return &portsMapping, nil
-}
-
-func parseInstanceParams(rawParams string) (*map[string]string, error) {
-
-	params := map[string]string{}
 
-	if rawParams != "" {
-
-		for _, rawMap := range strings.Split(rawParams, ";") {
-			splittedMap := strings.Split(rawMap, ":")
-
-			if len(splittedMap) != 2 {
-				return nil, fmt.Errorf("Wrong params format: %v\n", rawMap)
-			}
-
-			key := splittedMap[0]
-			value := splittedMap[1]
-
-			params[key] = value
+		flag := strings.TrimLeft(arg, "-")
+		if flag == "json" {
+			return true
 		}
-	}
-
-	return &params, nil
-}
-
-
-func initProject(c *cli.Context) error {
-	args := c.Args()
-
-	projectName := args.Get(0)
-	description := args.Get(1)
-
-	if projectName == "" {
-		return fmt.Errorf("project name is required")
-	}
-
-	return global.InitProject(projectName, description)
-}
-
-
-func startProject(c *cli.Context) error {
-	return global.StartProject()
-}
-
-func instanceAdd(c *cli.Context) error {
-	args := c.Args()
-
-	//TODO: add instance name format check
-	name := args.Get(0)
-	if name == "" {
-		return fmt.Errorf("instance name is required")
-	}
-
-	source := args.Get(1)
-	if source == "" {
-		return fmt.Errorf("instance source is required")
-	}
-
-	queueGroup := c.String("queueGroup")
-	class := c.String("class")
-
-	channelsMappingRaw := c.String("channels")
-	channelsMapping, err := parseChannelsMapping(channelsMappingRaw)
-	if err != nil {
-		return err
-	}
-
-	portsMappingRaw := c.String("ports")
-	portsMapping, err := parsePortsMapping(portsMappingRaw)
-	if err != nil {
-		return err
-	}
-
-	paramsRaw := c.String("params")
-	params, err := parseInstanceParams(paramsRaw)
-	if err != nil {
-		return err
-	}
-
-	err = instance.Add(
-		name,
-		source,
-		class,
-		queueGroup,
-		*params,
-		*portsMapping,
-		*channelsMapping,
-	)
-
-	return err
-}
-
-func instanceConfig(c *cli.Context) error {
-	args := c.Args()
-
-	//TODO: add instance name format check
-	name := args.Get(0)
-	if name == "" {
-		return fmt.Errorf("instance name is required")
-	}
-
-	config, err := instance.GetConfigText(name)
-	fmt.Println(config)
-	return err
-}
-
-func instanceRemove(c *cli.Context) error {
-	args := c.Args()
-	name := args.Get(0)
-
-	if name == "" {
-		return fmt.Errorf("instance name is required")
-	}
-
-	return instance.Remove(name)
-}
-
-func instanceStart(c *cli.Context) error {
-	args := c.Args()
-	name := args.Get(0)
-
-	if name == "" {
-		return fmt.Errorf("instance name is required")
-	}
-
-	return instance.Start(name)
-}
-
-func list(c *cli.Context) error {
-
-	info, err := global.GetListInstances()
-	if err != nil {
-		return err
-	}
-
-	infoText, err := json.MarshalIndent(info, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	fmt.Println(string(infoText))
-	return nil
-}
 
-func startBus(c *cli.Context) error {
-	return global.StartBus()
-}
-
-func addMigration(c *cli.Context) error {
-	args := c.Args()
-	description := args.Get(0)
-
-	migrationFileName, err := db.AddMigration(description)
-	if err == nil {
-		fmt.Println(migrationFileName)
-	}
-
-	return err
-}
-
-func addTable(c *cli.Context) error {
-	args := c.Args()
-	tableName := args.Get(0)
-
-	if tableName == "" {
-		return fmt.Errorf("table name is required")
-	}
-
-	updatedMigrationId, err := db.AddTable(tableName)
-	if err != nil {
-		return err
+		if strings.HasPrefix(flag, "json=") {
+			isJSON, _ := strconv.ParseBool(strings.TrimPrefix(flag, "json="))
+			return isJSON
+		}
 	}
 
-	fmt.Println(updatedMigrationId)
-	return nil
+	return false
 }
 
-func deleteTable(c *cli.Context) error {
-	args := c.Args()
-	tableName := args.Get(0)
-
-	if tableName == "" {
-		return fmt.Errorf("table name is required")
+func getVersionInfo() VersionInfo {
+	return VersionInfo{
+		Version:                version,
+		Commit:                 commit,
+		BuildDate:              buildDate,
+		GoVersion:              runtime.Version(),
+		InstanceSchemaVersion:  instance.Version,
+		MigrationSchemaVersion: db.MigrationSchemaVersion,
+		BusProtocolVersion:     utils.BusProtocolVersion,
+		MinBusProtocolVersion:  utils.MinBusProtocolVersion,
+		ExecutorVersion:        executor.Version,
 	}
-
-	updatedMigrationId, err := db.DeleteTable(tableName)
-	if err != nil {
-		return err
-	}
-
-	fmt.Println(updatedMigrationId)
-	return nil
 }
 
-func addColumn(c *cli.Context) error {
-	args := c.Args()
-
-	tableName := args.Get(0)
-	if tableName == "" {
-		return fmt.Errorf("table name is required")
-	}
-
-	columnName := args.Get(1)
-	if columnName == "" {
-		return fmt.Errorf("column name is required")
-	}
+func printVersion(isJson bool) error {
+	info := getVersionInfo()
 
-	columnType := args.Get(2)
-	if columnType == "" {
-		return fmt.Errorf("column type is required")
-	}
-
-	isNullable := c.BoolT("nullable")
-	defaultValue := c.String("default")
-
-	updatedMigrationId, err := db.AddColumn(tableName, columnName, columnType, isNullable, defaultValue)
-	if err != nil {
-		return err
-	}
-
-	fmt.Println(updatedMigrationId)
-	return nil
-}
-
-func deleteColumn(c *cli.Context) error {
-	args := c.Args()
-
-	tableName := args.Get(0)
-	if tableName == "" {
-		return fmt.Errorf("table name is required")
-	}
-
-	columnName := args.Get(1)
-	if columnName == "" {
-		return fmt.Errorf("column name is required")
-	}
+	if isJson {
+		rawInfo, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			return err
+		}
 
-	updatedMigrationId, err := db.DeleteColumn(tableName, columnName)
-	if err != nil {
-		return err
+		fmt.Println(string(rawInfo))
+		return nil
 	}
 
-	fmt.Println(updatedMigrationId)
-	return nil
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(writer, "Version:\t%v\n", info.Version)
+	fmt.Fprintf(writer, "Commit:\t%v\n", info.Commit)
+	fmt.Fprintf(writer, "Build date:\t%v\n", info.BuildDate)
+	fmt.Fprintf(writer, "Go version:\t%v\n", info.GoVersion)
+	fmt.Fprintf(writer, "Instance schema version:\t%v\n", info.InstanceSchemaVersion)
+	fmt.Fprintf(writer, "Migration schema version:\t%v\n", info.MigrationSchemaVersion)
+	fmt.Fprintf(writer, "Bus protocol version:\t%v (oldest supported %v)\n", info.BusProtocolVersion, info.MinBusProtocolVersion)
+	fmt.Fprintf(writer, "Executor version:\t%v\n", info.ExecutorVersion)
+	return writer.Flush()
 }
 
-func addPrimaryKey(c *cli.Context) error {
-	args := c.Args()
-
-	tableName := args.Get(0)
-	if tableName == "" {
-		return fmt.Errorf("table name is required")
-	}
-
-	columnName := args.Get(1)
-	if columnName == "" {
-		return fmt.Errorf("column name is required")
-	}
-
-	updatedMigrationId, err := db.AddPrimaryKey(tableName, columnName)
+func setGlobalOptions(c *cli.Context) error {
+	err := setLogFile(c)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println(updatedMigrationId)
-	return nil
-}
-
-func deletePrimaryKey(c *cli.Context) error {
-	args := c.Args()
-
-	tableName := args.Get(0)
-	if tableName == "" {
-		return fmt.Errorf("table name is required")
-	}
-
-	columnName := args.Get(1)
-	if columnName == "" {
-		return fmt.Errorf("column name is required")
-	}
-
-	updatedMigrationId, err := db.DeletePrimaryKey(tableName, columnName)
+	err = setLogLevel(c)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println(updatedMigrationId)
-	return nil
-}
-
-func listMigrations(c *cli.Context) error {
-	migrations, err := db.GetList()
-	if err != nil {
-		return err
+	if c.GlobalBool("no-color") {
+		utils.DisableColor()
 	}
 
-	packedMigrations, _ := json.MarshalIndent(migrations, "", "  ")
-
-	fmt.Println(string(packedMigrations))
-	return nil
-}
-
-func parseColumnsMapping(mappingRaw string) (*[]db.ColumnsMap, error) {
-	mapping := []db.ColumnsMap{}
-
-	if mappingRaw != "" {
-		for _, rawMap := range strings.Split(mappingRaw, ";") {
-			splittedMap := strings.Split(rawMap, ":")
-
-			if len(splittedMap) != 2 {
-				return nil, fmt.Errorf("wrong columns mapping: %v\n", rawMap)
-			}
-
-			column := splittedMap[0]
-			remoteColumn := splittedMap[1]
-
-			mapping = append(mapping, db.ColumnsMap{
-				Column:       column,
-				RemoteColumn: remoteColumn,
-			})
+	// packages read project from working directory, so it's changed to selected project before command
+	if c.GlobalString("project") != "" {
+		err = utils.ChangeToProject(c.GlobalString("project"))
+		if err != nil {
+			return utils.ConfigError(err)
+		}
+	} else if command := c.Args().First(); command != "init" && command != "workspace" {
+		_, err = utils.ChangeToCurrentProject()
+		if err != nil {
+			utils.Warningf("Can't change to current project of workspace: %v\n", err)
 		}
 	}
 
-	return &mapping, nil
-}
-
-func addRelation(c *cli.Context) error {
-	args := c.Args()
-
-	relationName := args.Get(0)
-	relationType := args.Get(1)
-	table := args.Get(2)
-	remoteTable := args.Get(3)
-	rawMapping := args.Get(4)
-
-	columnsMapping, err := parseColumnsMapping(rawMapping)
-	if err != nil {
-		return err
+	// profile is passed to packages through environment, the same way as other overrides of project config
+	if c.GlobalIsSet("env") {
+		err = os.Setenv(utils.EnvProfile, c.GlobalString("env"))
+		if err != nil {
+			return err
+		}
 	}
 
-	updatedMigrationId, err := db.AddRelation(relationName, db.RelationType(relationType), table, remoteTable, *columnsMapping)
-	if err != nil {
-		return err
+	if c.GlobalBool("dry-run") {
+		return os.Setenv(utils.EnvDryRun, "true")
 	}
 
-	fmt.Println(updatedMigrationId)
 	return nil
 }
 
-func deleteRelation(c *cli.Context) error {
-	args := c.Args()
-
-	table := args.Get(0)
-	relationName := args.Get(1)
-
-	updatedMigrationId, err := db.DeleteRelation(table, relationName)
-	if err != nil {
-		return err
+func setLogFile(c *cli.Context) error {
+	logPath := c.String("log-file")
+	if logPath == "" {
+		return nil
 	}
 
-	fmt.Println(updatedMigrationId)
-	return nil
-}
-
-func addUniqueConstraint(c *cli.Context) error {
-	args := c.Args()
-
-	constraintName := args.Get(0)
-	table := args.Get(1)
-	rawColumns := args.Get(2)
-
-	columns := strings.Split(rawColumns, ";")
-
-	updatedMigrationId, err := db.AddUniqueConstraint(constraintName, table, columns)
+	logFile, err := utils.NewRotatingFile(logPath, logFileMaxSize, logFileBackups)
 	if err != nil {
-		return err
+		return fmt.Errorf("can't open log file: %v", err)
 	}
 
-	fmt.Println(updatedMigrationId)
+	utils.SetLogOutput(logFile)
 	return nil
 }
 
-func deleteUniqueConstraint(c *cli.Context) error {
-	args := c.Args()
-
-	table := args.Get(0)
-	relationName := args.Get(1)
-
-	updatedMigrationId, err := db.DeleteUniqueConstraint(table, relationName)
-	if err != nil {
-		return err
+func setLogLevel(c *cli.Context) error {
+	if c.GlobalBool("verbose") && c.GlobalBool("quiet") {
+		return fmt.Errorf("--verbose and --quiet can't be used together")
 	}
 
-	fmt.Println(updatedMigrationId)
-	return nil
-}
-
-func migrationSnapshot(c *cli.Context) error {
-	snapshot, err := db.GetCurrentSnapshot()
-	if err != nil {
-		return err
+	if c.GlobalBool("verbose") {
+		utils.SetLogLevel(utils.LogDebug)
 	}
 
-	textSnapshot, _ := json.MarshalIndent(*snapshot, "", "  ")
-	log.Println(string(textSnapshot))
-	return nil
-}
+	if c.GlobalBool("quiet") {
+		utils.SetLogLevel(utils.LogWarning)
+	}
 
-func syncMigrations(c *cli.Context) error {
-	return db.Sync()
+	return utils.SetLogFormat(c.GlobalString("log-format"))
 }
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/akaumov/cubes/agent"
+	"github.com/akaumov/cubes/daemon"
+	"github.com/akaumov/cubes/tui"
+	"github.com/akaumov/cubes/utils"
+	"github.com/urfave/cli"
+)
+
+var agentCommand = cli.Command{
+	Name:  "agent",
+	Usage: "run agent which starts instances of this project by commands of remote cubes CLI",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "listen",
+			Value: ":7443",
+			Usage: "agent address",
+		},
+		cli.StringFlag{
+			Name:  "ca",
+			Usage: "CA certificate which signs agent and CLI certificates",
+		},
+		cli.StringFlag{
+			Name:  "cert",
+			Usage: "agent certificate",
+		},
+		cli.StringFlag{
+			Name:  "key",
+			Usage: "agent certificate key",
+		},
+	},
+	ArgsUsage: "[--listen] --ca --cert --key",
+	Action:    audited(runAgent),
+}
+
+var daemonCommand = cli.Command{
+	Name:  "daemon",
+	Usage: "cubesd, which supervises instances and bus of project, instance start and stop go through it when it's running",
+	Subcommands: []cli.Command{
+		{
+			Name:  "status",
+			Usage: "print states of instances, which cubesd keeps",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "json",
+					Usage: "print status as json",
+				},
+			},
+			Action: daemonStatus,
+		},
+		{
+			Name:  "token",
+			Usage: "tokens of cubesd tcp API with roles: read-only reads states and logs, operator starts and stops instances too, admin can do everything",
+			Subcommands: []cli.Command{
+				{
+					Name:  "add",
+					Usage: "generate token with role and print it, it can't be printed again",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "role",
+							Value: daemon.RoleReadOnly,
+							Usage: "role of token: read-only, operator or admin",
+						},
+					},
+					ArgsUsage: "[--role] name",
+					Action:    audited(daemonTokenAdd),
+				},
+				{
+					Name:  "list",
+					Usage: "list names and roles of tokens",
+					Flags: []cli.Flag{
+						cli.BoolFlag{
+							Name:  "json",
+							Usage: "print tokens as json",
+						},
+					},
+					ArgsUsage: "[--json]",
+					Action:    daemonTokenList,
+				},
+				{
+					Name:      "remove",
+					Usage:     "remove token, clients with it are rejected right away",
+					ArgsUsage: "name",
+					Action:    audited(daemonTokenRemove),
+				},
+			},
+		},
+	},
+}
+
+var serviceCommand = cli.Command{
+	Name:  "service",
+	Usage: "OS service, which runs cubesd of project after reboot: systemd unit on linux, launchd daemon on macOS, scheduled task on windows",
+	Subcommands: []cli.Command{
+		{
+			Name:  "install",
+			Usage: "register cubesd of project with service manager of OS and start it, system service needs root or administrator",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "bus",
+					Value: daemon.BusNone,
+					Usage: "bus, which cubesd keeps running: none or container",
+				},
+				cli.DurationFlag{
+					Name:  "interval",
+					Value: 5 * time.Second,
+					Usage: "interval of instances checks",
+				},
+				cli.StringFlag{
+					Name:  "listen",
+					Usage: "tcp address of cubesd API, it needs tokens of 'cubes daemon token add'",
+				},
+				cli.BoolFlag{
+					Name:  "user",
+					Usage: "install service of current user, it doesn't need root, but runs only when user is logged in",
+				},
+				cli.BoolFlag{
+					Name:  "print",
+					Usage: "print unit, plist or command of service without installing it",
+				},
+			},
+			ArgsUsage: "[--bus] [--interval] [--listen] [--user] [--print]",
+			Action:    audited(serviceInstall),
+		},
+		{
+			Name:  "uninstall",
+			Usage: "stop cubesd of project and remove its service, instances keep running",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "user",
+					Usage: "uninstall service of current user",
+				},
+			},
+			ArgsUsage: "[--user]",
+			Action:    audited(serviceUninstall),
+		},
+		{
+			Name:  "status",
+			Usage: "print state of service of project",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "user",
+					Usage: "status of service of current user",
+				},
+			},
+			ArgsUsage: "[--user]",
+			Action:    serviceStatus,
+		},
+	},
+}
+
+var tuiCommand = cli.Command{
+	Name:  "tui",
+	Usage: "full-screen terminal interface: instances with live status, logs of selected instance, tail of bus messages, keys start, stop and restart instances",
+	Flags: []cli.Flag{
+		cli.IntFlag{
+			Name:  "interval",
+			Value: 2,
+			Usage: "seconds between refreshes",
+		},
+	},
+	ArgsUsage: "[--interval]",
+	Action:    audited(runTui),
+}
+
+var dashboardCommand = cli.Command{
+	Name:  "dashboard",
+	Usage: "serve web page over cubesd API: instances with status, logs, start and stop, bus channels topology and migrations status, page doesn't ask for token, its requests are limited by role",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "listen",
+			Value: "localhost:8091",
+			Usage: "dashboard address",
+		},
+		cli.StringFlag{
+			Name:  "role",
+			Value: daemon.RoleReadOnly,
+			Usage: "role of page: read-only, operator (starts and stops instances) or admin",
+		},
+	},
+	ArgsUsage: "[--listen] [--role]",
+	Action:    dashboard,
+}
+
+func daemonStatus(c *cli.Context) error {
+	status, err := daemon.GetStatus()
+	if err != nil {
+		return fmt.Errorf("cubesd isn't running: start it with 'cubesd' in project directory")
+	}
+
+	if c.Bool("json") {
+		statusText, err := json.MarshalIndent(status, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(statusText))
+		return nil
+	}
+
+	fmt.Printf("Pid: %v\n", status.Pid)
+	fmt.Printf("Uptime: %v\n", time.Since(status.StartedAt).Round(time.Second))
+	fmt.Printf("Bus: %v, running: %v\n", status.Bus, status.IsBusRunning)
+	fmt.Println()
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "INSTANCE\tSTATUS\tSUPERVISED\tRESTARTS\tCHECKED\tERROR")
+
+	for _, state := range status.Instances {
+		fmt.Fprintf(writer, "%v\t%v\t%v\t%v\t%v\t%v\n", state.Name, state.Status, state.IsSupervised, state.Restarts,
+			state.CheckedAt.Format("15:04:05"), state.LastError)
+	}
+
+	return writer.Flush()
+}
+
+func dashboard(c *cli.Context) error {
+	if !daemon.IsRunning() {
+		return fmt.Errorf("cubesd isn't running: start it with 'cubesd' in project directory")
+	}
+
+	return daemon.ServeDashboard(c.String("listen"), c.String("role"))
+}
+
+func daemonTokenAdd(c *cli.Context) error {
+	name := c.Args().Get(0)
+	if name == "" {
+		return fmt.Errorf("token name is required")
+	}
+
+	token, err := daemon.AddToken(name, c.String("role"))
+	if err != nil {
+		return err
+	}
+
+	utils.Infof("Token %v with role %v is added, it isn't shown again\n", name, c.String("role"))
+	fmt.Println(token)
+	return nil
+}
+
+func daemonTokenList(c *cli.Context) error {
+	tokens, err := daemon.GetTokens()
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("json") {
+		tokensText, err := json.MarshalIndent(tokens, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(tokensText))
+		return nil
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "NAME\tROLE\tCREATED")
+
+	for _, token := range tokens {
+		fmt.Fprintf(writer, "%v\t%v\t%v\n", token.Name, token.Role, token.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+	}
+
+	return writer.Flush()
+}
+
+func daemonTokenRemove(c *cli.Context) error {
+	name := c.Args().Get(0)
+	if name == "" {
+		return fmt.Errorf("token name is required")
+	}
+
+	err := daemon.RemoveToken(name)
+	if err != nil {
+		return err
+	}
+
+	utils.Infof("Token %v is removed\n", name)
+	return nil
+}
+
+func runTui(c *cli.Context) error {
+	if c.Int("interval") <= 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+
+	return tui.Run(time.Duration(c.Int("interval")) * time.Second)
+}
+
+func runAgent(c *cli.Context) error {
+	if c.String("ca") == "" || c.String("cert") == "" || c.String("key") == "" {
+		return fmt.Errorf("ca, cert and key are required")
+	}
+
+	return agent.Serve(c.String("listen"), c.String("ca"), c.String("cert"), c.String("key"))
+}
+
+func serviceInstall(c *cli.Context) error {
+	options := daemon.ServiceOptions{
+		Options: daemon.Options{
+			Bus:        c.String("bus"),
+			Interval:   c.Duration("interval"),
+			ApiAddress: c.String("listen"),
+		},
+		IsUser: c.Bool("user"),
+	}
+
+	if c.Bool("print") {
+		definition, err := daemon.GetServiceDefinition(options)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(definition)
+		return nil
+	}
+
+	return daemon.InstallService(options)
+}
+
+func serviceUninstall(c *cli.Context) error {
+	return daemon.UninstallService(c.Bool("user"))
+}
+
+func serviceStatus(c *cli.Context) error {
+	status, err := daemon.GetServiceStatus(c.Bool("user"))
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(status)
+	return nil
+}
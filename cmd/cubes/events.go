@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/akaumov/cubes/global"
+	"github.com/akaumov/cubes/utils"
+	"github.com/urfave/cli"
+)
+
+var eventsCommand = cli.Command{
+	Name:  "events",
+	Usage: "show lifecycle events of project: instance.started, instance.stopped, instance.crashed, migration.applied, migration.failed, bus.up, bus.down, they're kept in .cubes/events.log",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "follow",
+			Usage: "print new events until Ctrl+C",
+		},
+		cli.StringFlag{
+			Name:  "since",
+			Usage: "show events of last period: 1h, 24h",
+		},
+		cli.StringFlag{
+			Name:  "type",
+			Usage: "show events of type, it can end with '*': instance.*",
+		},
+		cli.BoolFlag{
+			Name:  "json",
+			Usage: "print every event as json line",
+		},
+	},
+	ArgsUsage: "[--follow] [--since] [--type] [--json]",
+	Action:    events,
+}
+
+var notificationsCommand = cli.Command{
+	Name:  "notifications",
+	Usage: "webhook and slack notifications of project config, cubesd sends them selected lifecycle events",
+	Subcommands: []cli.Command{
+		{
+			Name:  "list",
+			Usage: "list notifications of project",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "json",
+					Usage: "print notifications as json",
+				},
+			},
+			ArgsUsage: "[--json]",
+			Action:    notificationsList,
+		},
+		{
+			Name:      "test",
+			Usage:     "send test event to notification, it's sent to all notifications when name is omitted",
+			ArgsUsage: "[name]",
+			Action:    audited(notificationsTest),
+		},
+	},
+}
+
+func printEvent(event utils.Event, isJson bool) {
+	if isJson {
+		rawEvent, err := json.Marshal(event)
+		if err == nil {
+			fmt.Println(string(rawEvent))
+		}
+
+		return
+	}
+
+	fmt.Printf("%v  %-18v %v  %v\n", event.Time.Local().Format("2006-01-02 15:04:05"), event.Type, event.Subject, event.Message)
+}
+
+func events(c *cli.Context) error {
+	filter := c.String("type")
+	isJson := c.Bool("json")
+
+	if c.Bool("follow") {
+		if c.IsSet("since") {
+			return fmt.Errorf("--since can't be used with --follow, only new events are followed")
+		}
+
+		subscription, err := utils.SubscribeEvents(func(event utils.Event) {
+			if utils.IsEventMatched(filter, event.Type) {
+				printEvent(event, isJson)
+			}
+		})
+
+		if err != nil {
+			return err
+		}
+
+		defer subscription.Close()
+
+		interrupt := make(chan os.Signal, 1)
+		signal.Notify(interrupt, os.Interrupt)
+		defer signal.Stop(interrupt)
+
+		<-interrupt
+		return nil
+	}
+
+	since := time.Time{}
+
+	if c.String("since") != "" {
+		period, err := time.ParseDuration(c.String("since"))
+		if err != nil {
+			return fmt.Errorf("wrong period %v: %v", c.String("since"), err)
+		}
+
+		since = time.Now().Add(-period)
+	}
+
+	projectEvents, err := utils.GetEvents(since)
+	if err != nil {
+		return err
+	}
+
+	for _, event := range projectEvents {
+		if utils.IsEventMatched(filter, event.Type) {
+			printEvent(event, isJson)
+		}
+	}
+
+	return nil
+}
+
+func notificationsList(c *cli.Context) error {
+	config, err := global.GetConfig()
+	if err != nil {
+		return err
+	}
+
+	notifications := config.Notifications
+	if notifications == nil {
+		notifications = []global.NotificationSink{}
+	}
+
+	if c.Bool("json") {
+		notificationsText, err := json.MarshalIndent(notifications, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(notificationsText))
+		return nil
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "NAME\tTYPE\tEVENTS\tPROFILES")
+
+	for _, notification := range notifications {
+		profiles := strings.Join(notification.Profiles, ",")
+		if profiles == "" {
+			profiles = "all"
+		}
+
+		fmt.Fprintf(writer, "%v\t%v\t%v\t%v\n", notification.Name, notification.Type, strings.Join(notification.Events, ","), profiles)
+	}
+
+	return writer.Flush()
+}
+
+func notificationsTest(c *cli.Context) error {
+	return global.SendTestNotification(c.Args().Get(0))
+}
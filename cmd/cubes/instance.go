@@ -0,0 +1,1514 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akaumov/cube_executor"
+	"github.com/akaumov/cubes/daemon"
+	"github.com/akaumov/cubes/global"
+	"github.com/akaumov/cubes/instance"
+	"github.com/akaumov/cubes/utils"
+	"github.com/urfave/cli"
+)
+
+var listCommand = cli.Command{
+	Name:  "list",
+	Usage: "list all instances",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "group",
+			Usage: "list only instances of group",
+		},
+		cli.StringSliceFlag{
+			Name:  "label",
+			Usage: "filter instances by label: --label team=core, can be repeated",
+		},
+	},
+	Action: list,
+}
+
+var instanceCommand = cli.Command{
+	Name:  "instance",
+	Usage: "cube instance",
+	Subcommands: []cli.Command{
+		{
+			Name:      "add",
+			Usage:     "adds cube instance",
+			Flags:     instanceConfigFlags,
+			ArgsUsage: "[--ports] [--channels] [--params] [--groups] [--labels] [--runtime] [--volumes] [--readiness] [--log-max-size] [--restart-retries] [--bus-reconnect-wait] [--host] name source (go:package, docker://image:tag, git:url[#ref], path:directory, archive:url[#sha256:digest])",
+			Action:    audited(instanceAdd),
+		},
+		{
+			Name:  "gateway",
+			Usage: "adds instance of built-in http gateway",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "routes",
+					Usage: "routes to bus channels: --routes 'GET /users:users.list;POST /users:users.create;* /files/*:files'",
+				},
+				cli.StringFlag{
+					Name:  "port",
+					Value: "auto",
+					Usage: "host port of gateway, free port is allocated on start by default",
+				},
+				cli.IntFlag{
+					Name:  "timeout",
+					Usage: "bus request timeout in milliseconds",
+				},
+			},
+			ArgsUsage: "--routes [--port] [--timeout] name",
+			Action:    audited(instanceGateway),
+		},
+		{
+			Name:  "scheduler",
+			Usage: "adds instance of built-in scheduler",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "schedules",
+					Usage: "cron expressions and bus channels: --schedules '*/5 * * * *:jobs.cleanup;0 3 * * 1-5:reports.daily'",
+				},
+			},
+			ArgsUsage: "--schedules name",
+			Action:    audited(instanceScheduler),
+		},
+		{
+			Name:      "run",
+			Usage:     "runs temporary cube instance in foreground and removes it on exit",
+			Flags:     instanceConfigFlags,
+			ArgsUsage: "[instance add flags] [name] source",
+			Action:    audited(instanceRun),
+		},
+		{
+			Name:  "config",
+			Usage: "get cube instance config",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "params",
+					Usage: "show params declared by cube with their current values",
+				},
+			},
+			ArgsUsage: "[--params] instanceName",
+			Action:    instanceConfig,
+		},
+		{
+			Name:  "clone",
+			Usage: "clones cube instance under new name",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "channels",
+					Usage: "override channels mapping: --channels 'cubeChannel1:busChannel1;cubeChannel2:busChannel2'",
+				},
+				cli.StringFlag{
+					Name:  "ports",
+					Usage: "replace ports mapping: --ports 'hostPort:handlerPort:protocol;80:8080:tcp'",
+				},
+				cli.StringFlag{
+					Name:  "params",
+					Usage: "override params: --params 'param1:Value1;param2:Value2'",
+				},
+			},
+			ArgsUsage: "[--ports] [--channels] [--params] name newName",
+			Action:    audited(instanceClone),
+		},
+		{
+			Name:  "export",
+			Usage: "export cube instances configs to bundle",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "all",
+					Usage: "export all instances",
+				},
+				cli.StringFlag{
+					Name:  "output",
+					Value: "instances.tar.gz",
+					Usage: "bundle file path",
+				},
+				cli.BoolFlag{
+					Name:  "strip-secrets",
+					Usage: "clear values of secret params (passwords, tokens, keys)",
+				},
+			},
+			ArgsUsage: "[--all] [--output] [--strip-secrets] [name...]",
+			Action:    instanceExport,
+			Subcommands: []cli.Command{
+				{
+					Name:  "systemd",
+					Usage: "export systemd unit file for cube instance",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "output",
+							Usage: "unit file path, prints to stdout if not set",
+						},
+						cli.StringFlag{
+							Name:  "restart",
+							Value: "always",
+							Usage: "systemd restart policy",
+						},
+						cli.IntFlag{
+							Name:  "restart-sec",
+							Value: 5,
+							Usage: "seconds to wait before restart",
+						},
+						cli.StringSliceFlag{
+							Name:  "env-file",
+							Usage: "environment file loaded by systemd, can be repeated",
+						},
+					},
+					ArgsUsage: "[--output] [--restart] [--restart-sec] [--env-file] name",
+					Action:    instanceExportSystemd,
+				},
+			},
+		},
+		{
+			Name:  "import",
+			Usage: "import cube instances configs from bundle",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "force",
+					Usage: "overwrite existing instances",
+				},
+			},
+			ArgsUsage: "[--force] bundlePath",
+			Action:    audited(instanceImport),
+		},
+		{
+			Name:      "remove",
+			Usage:     "remove cube instance",
+			ArgsUsage: "name",
+			Action:    audited(instanceRemove),
+		},
+		{
+			Name:  "upgrade",
+			Usage: "fetch git or image source of cube instance and record new commit or digest",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "ref",
+					Usage: "git branch, tag, commit or image tag, current ref if not set",
+				},
+			},
+			ArgsUsage: "[--ref] name",
+			Action:    audited(instanceUpgrade),
+		},
+		{
+			Name:      "rename",
+			Usage:     "rename cube instance",
+			ArgsUsage: "name newName",
+			Action:    audited(instanceRename),
+		},
+		{
+			Name:  "start",
+			Usage: "start cube instance, instances selected by --all, --group or --label are started after instances they depend on, independent ones concurrently",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "all",
+					Usage: "start all instances",
+				},
+				cli.StringFlag{
+					Name:  "group",
+					Usage: "start all instances of group",
+				},
+				cli.StringSliceFlag{
+					Name:  "label",
+					Usage: "filter instances by label: --label team=core, can be repeated",
+				},
+				cli.DurationFlag{
+					Name:  "wait",
+					Usage: "wait until instance is ready: --wait 1m, instances, which others depend on, are waited for a minute by default",
+				},
+				parallelFlag,
+			},
+			ArgsUsage: "[--all] [--group] [--label] [--wait] [--parallel] [name]",
+			Action:    audited(instanceStart),
+		},
+		{
+			Name:  "attach",
+			Usage: "attach terminal to running cube instance",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "detach-keys",
+					Value: "ctrl-p,ctrl-q",
+					Usage: "key sequence for detaching",
+				},
+			},
+			ArgsUsage: "[--detach-keys] name",
+			Action:    audited(instanceAttach),
+		},
+		{
+			Name:      "exec",
+			Usage:     "run command inside running cube instance",
+			ArgsUsage: "name -- command [args...]",
+			Action:    audited(instanceExec),
+		},
+		{
+			Name:      "diff",
+			Usage:     "show config changes which need instance restart",
+			ArgsUsage: "name",
+			Action:    instanceDiff,
+		},
+		{
+			Name:      "history",
+			Usage:     "show saved versions of instance config",
+			ArgsUsage: "name",
+			Action:    instanceHistory,
+		},
+		{
+			Name:  "rollback",
+			Usage: "restore previous version of instance config",
+			Flags: []cli.Flag{
+				cli.IntFlag{
+					Name:  "to",
+					Usage: "config version to restore, previous version by default",
+				},
+			},
+			ArgsUsage: "[--to version] name",
+			Action:    audited(instanceRollback),
+		},
+		{
+			Name:      "dev",
+			Usage:     "watch instance source, rebuild and restart instance on changes",
+			ArgsUsage: "name",
+			Action:    audited(instanceDev),
+		},
+		{
+			Name:  "metrics",
+			Usage: "serve prometheus metrics of cube instances",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "listen",
+					Value: ":9464",
+					Usage: "metrics endpoint address",
+				},
+			},
+			ArgsUsage: "[--listen]",
+			Action:    instanceMetrics,
+		},
+		{
+			Name:  "top",
+			Usage: "shows live resources usage of cube instances",
+			Flags: []cli.Flag{
+				cli.IntFlag{
+					Name:  "interval",
+					Value: 2,
+					Usage: "refresh interval in seconds",
+				},
+				cli.StringFlag{
+					Name:  "group",
+					Usage: "show instances of group",
+				},
+				cli.StringSliceFlag{
+					Name:  "label",
+					Usage: "filter instances by label: --label team=core, can be repeated",
+				},
+			},
+			ArgsUsage: "[--interval] [--group] [--label]",
+			Action:    instanceTop,
+		},
+		{
+			Name:  "status",
+			Usage: "get cube instance status",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "group",
+					Usage: "print statuses of group instances",
+				},
+				cli.StringSliceFlag{
+					Name:  "label",
+					Usage: "filter instances by label: --label team=core, can be repeated",
+				},
+			},
+			ArgsUsage: "[--group] [--label] [name]",
+			Action:    instanceStatus,
+		},
+		{
+			Name:  "logs",
+			Usage: "print cube instance logs",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "follow",
+					Usage: "follow log output",
+				},
+				cli.BoolFlag{
+					Name:  "bus",
+					Usage: "print connects and disconnects of instance to bus with reasons, which bus closed connections for",
+				},
+			},
+			ArgsUsage: "[--follow] [--bus] name",
+			Action:    instanceLogs,
+		},
+		{
+			Name:  "stop",
+			Usage: "stops cube instance",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "group",
+					Usage: "stop all instances of group",
+				},
+				cli.StringSliceFlag{
+					Name:  "label",
+					Usage: "filter instances by label: --label team=core, can be repeated",
+				},
+				cli.BoolFlag{
+					Name:  "all",
+					Usage: "stop all instances of project",
+				},
+			},
+			ArgsUsage: "[--group] [--label] [--all] [name]",
+			Action:    audited(instanceStop),
+		},
+		{
+			Name:  "channels",
+			Usage: "manage instance channels mapping",
+			Subcommands: []cli.Command{
+				{
+					Name:      "set",
+					Usage:     "replace instance channels mapping, running instance resubscribes its channels without restart",
+					ArgsUsage: "name 'cubeChannel:busChannel;...'",
+					Action:    audited(instanceChannelsSet),
+				},
+			},
+		},
+		{
+			Name:      "pause",
+			Usage:     "freezes cube instance keeping its in-memory state",
+			ArgsUsage: "name",
+			Action:    audited(instancePause),
+		},
+		{
+			Name:      "resume",
+			Usage:     "resumes paused cube instance",
+			ArgsUsage: "name",
+			Action:    audited(instanceResume),
+		},
+	},
+}
+
+var instanceConfigFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "channels",
+		Usage: "channels mapping: --channels 'cubeChannel1:busChannel1;cubeChannel2:busChannel2'",
+	},
+	cli.StringFlag{
+		Name:  "queueGroup",
+		Usage: "queue group name",
+	},
+	cli.StringFlag{
+		Name:  "class",
+		Usage: "class name",
+	},
+	cli.StringFlag{
+		Name:  "ports",
+		Usage: "ports mapping: --ports 'hostPort:handlerPort:protocol;80:8080:tcp;auto:8081/tcp'",
+	},
+	cli.StringFlag{
+		Name:  "params",
+		Usage: "params: --params 'param1:Value1;param2:Value2'",
+	},
+	cli.StringFlag{
+		Name:  "groups",
+		Usage: "groups: --groups 'core;workers'",
+	},
+	cli.StringFlag{
+		Name:  "labels",
+		Usage: "labels: --labels 'team=core;tier=backend'",
+	},
+	cli.StringFlag{
+		Name:  "runtime",
+		Usage: "instance runtime: --runtime docker, it's defaultRuntime of project.json or docker by default",
+	},
+	cli.StringFlag{
+		Name:  "volumes",
+		Usage: "volumes: --volumes 'hostPath:cubePath[:ro];./data:/data'",
+	},
+	cli.StringFlag{
+		Name:  "readiness",
+		Usage: "readiness probe command run inside instance, channels of cube are subscribed after it passes: --readiness 'test -f /tmp/ready'",
+	},
+	cli.IntFlag{
+		Name:  "readiness-interval",
+		Value: 5,
+		Usage: "seconds between readiness probes",
+	},
+	cli.IntFlag{
+		Name:  "readiness-timeout",
+		Value: 3,
+		Usage: "readiness probe timeout in seconds",
+	},
+	cli.IntFlag{
+		Name:  "readiness-retries",
+		Value: 3,
+		Usage: "failed probes before instance is unhealthy",
+	},
+	cli.IntFlag{
+		Name:  "log-max-size",
+		Usage: "rotate instance log when it reaches size in megabytes",
+	},
+	cli.IntFlag{
+		Name:  "log-max-files",
+		Value: 5,
+		Usage: "number of rotated log files to keep",
+	},
+	cli.BoolFlag{
+		Name:  "log-compress",
+		Usage: "compress rotated log files",
+	},
+	cli.IntFlag{
+		Name:  "restart-retries",
+		Usage: "restart failed instance with growing delay up to number of times",
+	},
+	cli.IntFlag{
+		Name:  "bus-reconnect-wait",
+		Usage: "wait in milliseconds before first reconnect to bus, it doubles after each failed attempt",
+	},
+	cli.IntFlag{
+		Name:  "bus-reconnect-max-wait",
+		Value: 30000,
+		Usage: "max wait in milliseconds between reconnects to bus",
+	},
+	cli.IntFlag{
+		Name:  "bus-reconnect-attempts",
+		Usage: "reconnects to bus before instance gives up, 0 reconnects forever",
+	},
+	cli.StringFlag{
+		Name:  "host",
+		Usage: "address of cubes agent which runs instance: --host node1.example.com:7443",
+	},
+	cli.StringFlag{
+		Name:  "depends-on",
+		Usage: "instances, which cubes up starts before this one: --depends-on 'db-writer;auth'",
+	},
+	cli.StringFlag{
+		Name:  "env",
+		Usage: "environment of instance: --env 'LOG_LEVEL=debug;REGION=eu'",
+	},
+	cli.IntFlag{
+		Name:  "memory",
+		Usage: "memory limit of instance in megabytes",
+	},
+	cli.Float64Flag{
+		Name:  "cpus",
+		Usage: "cpus limit of instance: --cpus 0.5",
+	},
+}
+
+func parseChannelsMapping(channelsMappingRaw string) (*map[cube_executor.CubeChannel]cube_executor.BusChannel, error) {
+	channelsMapping := map[cube_executor.CubeChannel]cube_executor.BusChannel{}
+
+	if channelsMappingRaw != "" {
+
+		for _, rawMap := range strings.Split(channelsMappingRaw, ";") {
+			splittedMap := strings.Split(rawMap, ":")
+
+			if len(splittedMap) != 2 {
+				return nil, fmt.Errorf("Wrong channels mapping: %v\n", rawMap)
+			}
+
+			cubeChannel := cube_executor.CubeChannel(splittedMap[0])
+			busChannel := cube_executor.BusChannel(splittedMap[1])
+
+			channelsMapping[cubeChannel] = busChannel
+		}
+	}
+
+	return &channelsMapping, nil
+}
+
+func parsePortsMapping(portsMappingRaw string) (*[]cube_executor.PortMap, error) {
+
+	portsMapping := []cube_executor.PortMap{}
+
+	if portsMappingRaw != "" {
+
+		for _, rawMap := range strings.Split(portsMappingRaw, ";") {
+			splittedMap := strings.Split(strings.Replace(rawMap, "/", ":", 1), ":")
+
+			if len(splittedMap) < 2 || len(splittedMap) > 3 {
+				return nil, fmt.Errorf("wrong ports mapping: %v\n", rawMap)
+			}
+
+			hostPort := uint64(instance.AutoHostPort)
+			if splittedMap[0] != "auto" {
+				var err error
+
+				hostPort, err = strconv.ParseUint(splittedMap[0], 10, 32)
+				if err != nil || hostPort == 0 {
+					return nil, fmt.Errorf("wrong host port format: %v/n", splittedMap[0])
+				}
+			}
+
+			handlerPort, err := strconv.ParseUint(splittedMap[1], 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("wrong cube port format: %v/n", splittedMap[1])
+			}
+
+			if len(splittedMap) == 2 {
+				portsMapping = append(portsMapping, cube_executor.PortMap{
+					HostPort: cube_executor.HostPort(hostPort),
+					CubePort: cube_executor.CubePort(handlerPort),
+					Protocol: cube_executor.Protocol("udp"),
+				})
+
+				portsMapping = append(portsMapping, cube_executor.PortMap{
+					HostPort: cube_executor.HostPort(hostPort),
+					CubePort: cube_executor.CubePort(handlerPort),
+					Protocol: cube_executor.Protocol("tcp"),
+				})
+
+			} else {
+				protocol := splittedMap[2]
+
+				if protocol != "udp" && protocol != "tcp" {
+					return nil, fmt.Errorf("wrong port protocol: %v/n", protocol)
+				}
+
+				portsMapping = append(portsMapping, cube_executor.PortMap{
+					HostPort: cube_executor.HostPort(hostPort),
+					CubePort: cube_executor.CubePort(handlerPort),
+					Protocol: cube_executor.Protocol(protocol),
+				})
+			}
+		}
+	}
+
+	return &portsMapping, nil
+}
+
+func parseInstanceParams(rawParams string) (*map[string]string, error) {
+
+	params := map[string]string{}
+
+	if rawParams != "" {
+
+		for _, rawMap := range strings.Split(rawParams, ";") {
+			splittedMap := strings.Split(rawMap, ":")
+
+			if len(splittedMap) != 2 {
+				return nil, fmt.Errorf("Wrong params format: %v\n", rawMap)
+			}
+
+			key := splittedMap[0]
+			value := splittedMap[1]
+
+			params[key] = value
+		}
+	}
+
+	return &params, nil
+}
+
+func parseInstanceGroups(rawGroups string) []string {
+
+	groups := []string{}
+
+	if rawGroups != "" {
+		for _, group := range strings.Split(rawGroups, ";") {
+			group = strings.TrimSpace(group)
+
+			if group != "" {
+				groups = append(groups, group)
+			}
+		}
+	}
+
+	return groups
+}
+
+func parseLabels(rawLabels string) (*map[string]string, error) {
+
+	labels := map[string]string{}
+
+	if rawLabels != "" {
+
+		for _, rawLabel := range strings.Split(rawLabels, ";") {
+			splittedLabel := strings.SplitN(rawLabel, "=", 2)
+
+			if len(splittedLabel) != 2 || strings.TrimSpace(splittedLabel[0]) == "" {
+				return nil, fmt.Errorf("wrong label format: %v\n", rawLabel)
+			}
+
+			labels[strings.TrimSpace(splittedLabel[0])] = strings.TrimSpace(splittedLabel[1])
+		}
+	}
+
+	return &labels, nil
+}
+
+func getInstanceFilter(c *cli.Context) (*instance.Filter, error) {
+	labels, err := parseLabels(strings.Join(c.StringSlice("label"), ";"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &instance.Filter{
+		Group:  c.String("group"),
+		Labels: *labels,
+	}, nil
+}
+
+func parseVolumes(rawVolumes string) (*[]instance.Volume, error) {
+
+	volumes := []instance.Volume{}
+
+	if rawVolumes != "" {
+
+		for _, rawVolume := range strings.Split(rawVolumes, ";") {
+			splittedVolume := strings.Split(rawVolume, ":")
+
+			if len(splittedVolume) < 2 || len(splittedVolume) > 3 {
+				return nil, fmt.Errorf("wrong volume format: %v\n", rawVolume)
+			}
+
+			isReadOnly := false
+			if len(splittedVolume) == 3 {
+				if splittedVolume[2] != "ro" && splittedVolume[2] != "rw" {
+					return nil, fmt.Errorf("wrong volume mode: %v\n", splittedVolume[2])
+				}
+
+				isReadOnly = splittedVolume[2] == "ro"
+			}
+
+			volumes = append(volumes, instance.Volume{
+				HostPath:   splittedVolume[0],
+				CubePath:   splittedVolume[1],
+				IsReadOnly: isReadOnly,
+			})
+		}
+	}
+
+	return &volumes, nil
+}
+
+func instanceAdd(c *cli.Context) error {
+	args := c.Args()
+
+	name := args.Get(0)
+	if name == "" {
+		return fmt.Errorf("instance name is required")
+	}
+
+	source := args.Get(1)
+	if source == "" {
+		return fmt.Errorf("instance source is required")
+	}
+
+	config, err := getInstanceConfig(c, name, source)
+	if err != nil {
+		return err
+	}
+
+	return instance.Add(*config)
+}
+
+func instanceGateway(c *cli.Context) error {
+	args := c.Args()
+
+	name := args.Get(0)
+	if name == "" {
+		return fmt.Errorf("instance name is required")
+	}
+
+	hostPort := instance.AutoHostPort
+	if c.String("port") != "auto" {
+		port, err := strconv.ParseUint(c.String("port"), 10, 16)
+		if err != nil {
+			return fmt.Errorf("wrong port: %v", c.String("port"))
+		}
+
+		hostPort = cube_executor.HostPort(port)
+	}
+
+	return instance.AddGateway(name, c.String("routes"), hostPort, c.Int("timeout"))
+}
+
+func instanceScheduler(c *cli.Context) error {
+	args := c.Args()
+
+	name := args.Get(0)
+	if name == "" {
+		return fmt.Errorf("instance name is required")
+	}
+
+	return instance.AddScheduler(name, c.String("schedules"))
+}
+
+func instanceRun(c *cli.Context) error {
+	args := c.Args()
+
+	name := args.Get(0)
+	source := args.Get(1)
+
+	if source == "" {
+		source = name
+		name = "run-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+
+	if source == "" {
+		return fmt.Errorf("instance source is required")
+	}
+
+	isExist, err := instance.IsExist(name)
+	if err != nil {
+		return err
+	}
+
+	if isExist {
+		return fmt.Errorf("instance '%v' already exists", name)
+	}
+
+	config, err := getInstanceConfig(c, name, source)
+	if err != nil {
+		return err
+	}
+
+	return instance.Run(*config, os.Stdout)
+}
+
+// getInstanceConfig builds instance config from instanceConfigFlags
+func getInstanceConfig(c *cli.Context, name string, source string) (*instance.Config, error) {
+	queueGroup := c.String("queueGroup")
+	class := c.String("class")
+
+	channelsMappingRaw := c.String("channels")
+	channelsMapping, err := parseChannelsMapping(channelsMappingRaw)
+	if err != nil {
+		return nil, err
+	}
+
+	portsMappingRaw := c.String("ports")
+	portsMapping, err := parsePortsMapping(portsMappingRaw)
+	if err != nil {
+		return nil, err
+	}
+
+	paramsRaw := c.String("params")
+	params, err := parseInstanceParams(paramsRaw)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := parseInstanceGroups(c.String("groups"))
+
+	labels, err := parseLabels(c.String("labels"))
+	if err != nil {
+		return nil, err
+	}
+
+	volumes, err := parseVolumes(c.String("volumes"))
+	if err != nil {
+		return nil, err
+	}
+
+	var readiness *instance.ReadinessProbe
+	if c.String("readiness") != "" {
+		readiness = &instance.ReadinessProbe{
+			Command:         c.String("readiness"),
+			IntervalSeconds: c.Int("readiness-interval"),
+			TimeoutSeconds:  c.Int("readiness-timeout"),
+			Retries:         c.Int("readiness-retries"),
+		}
+	}
+
+	var logRotation *instance.LogRotation
+	if c.Int("log-max-size") > 0 {
+		logRotation = &instance.LogRotation{
+			MaxSizeMegabytes: c.Int("log-max-size"),
+			MaxFiles:         c.Int("log-max-files"),
+			IsCompressed:     c.Bool("log-compress"),
+		}
+	}
+
+	var restart *instance.RestartPolicy
+	if c.Int("restart-retries") > 0 {
+		restart = &instance.RestartPolicy{
+			MaxRetries: c.Int("restart-retries"),
+		}
+	}
+
+	env, err := parseLabels(c.String("env"))
+	if err != nil {
+		return nil, err
+	}
+
+	var resources *instance.Resources
+	if c.Int("memory") != 0 || c.Float64("cpus") != 0 {
+		resources = &instance.Resources{
+			MemoryMegabytes: c.Int("memory"),
+			Cpus:            c.Float64("cpus"),
+		}
+	}
+
+	var busReconnect *instance.BusReconnect
+	if c.Int("bus-reconnect-wait") > 0 {
+		busReconnect = &instance.BusReconnect{
+			WaitMilliseconds:    c.Int("bus-reconnect-wait"),
+			MaxWaitMilliseconds: c.Int("bus-reconnect-max-wait"),
+			MaxAttempts:         c.Int("bus-reconnect-attempts"),
+		}
+	}
+
+	return &instance.Config{
+		CubeConfig: cube_executor.CubeConfig{
+			Name:            name,
+			Source:          source,
+			Class:           class,
+			QueueGroup:      queueGroup,
+			Params:          *params,
+			PortsMapping:    *portsMapping,
+			ChannelsMapping: *channelsMapping,
+		},
+		Runtime:   c.String("runtime"),
+		Groups:    groups,
+		Labels:    *labels,
+		Volumes:   *volumes,
+		Readiness: readiness,
+
+		LogRotation:  logRotation,
+		Restart:      restart,
+		BusReconnect: busReconnect,
+		Host:         c.String("host"),
+		DependsOn:    parseInstanceGroups(c.String("depends-on")),
+		Env:          *env,
+		Resources:    resources,
+	}, nil
+}
+
+func instanceConfig(c *cli.Context) error {
+	args := c.Args()
+
+	//TODO: add instance name format check
+	name := args.Get(0)
+	if name == "" {
+		return fmt.Errorf("instance name is required")
+	}
+
+	if c.Bool("params") {
+		return instanceConfigParams(name)
+	}
+
+	config, err := instance.GetConfigText(name)
+	fmt.Println(config)
+	return err
+}
+
+func instanceConfigParams(name string) error {
+	config, err := instance.GetConfig(name)
+	if err != nil {
+		return err
+	}
+
+	meta, err := instance.GetMeta(*config)
+	if err != nil {
+		return fmt.Errorf("can't read cube meta: %v", err)
+	}
+
+	if meta == nil || len(meta.Params) == 0 {
+		fmt.Println("cube doesn't declare params")
+		return nil
+	}
+
+	names := []string{}
+	for paramName := range meta.Params {
+		names = append(names, paramName)
+	}
+	sort.Strings(names)
+
+	for _, paramName := range names {
+		param := meta.Params[paramName]
+
+		paramType := param.Type
+		if paramType == "" {
+			paramType = instance.ParamString
+		}
+
+		flags := ""
+		if param.IsRequired {
+			flags += " required"
+		}
+
+		if param.Default != nil {
+			flags += fmt.Sprintf(" default=%v", param.Default)
+		}
+
+		fmt.Printf("%v (%v%v) = %v\n", paramName, paramType, flags, config.Params[paramName])
+
+		if param.Description != "" {
+			fmt.Printf("    %v\n", param.Description)
+		}
+	}
+
+	return nil
+}
+
+func instanceClone(c *cli.Context) error {
+	args := c.Args()
+
+	name := args.Get(0)
+	if name == "" {
+		return fmt.Errorf("instance name is required")
+	}
+
+	newName := args.Get(1)
+	if newName == "" {
+		return fmt.Errorf("new instance name is required")
+	}
+
+	channelsMapping, err := parseChannelsMapping(c.String("channels"))
+	if err != nil {
+		return err
+	}
+
+	var portsMapping *[]cube_executor.PortMap
+	if c.IsSet("ports") {
+		portsMapping, err = parsePortsMapping(c.String("ports"))
+		if err != nil {
+			return err
+		}
+	}
+
+	params, err := parseInstanceParams(c.String("params"))
+	if err != nil {
+		return err
+	}
+
+	return instance.Clone(name, newName, *params, portsMapping, *channelsMapping)
+}
+
+func instanceExport(c *cli.Context) error {
+	bundlePath := c.String("output")
+	isSecretsStripped := c.Bool("strip-secrets")
+
+	var err error
+	if c.Bool("all") {
+		err = instance.ExportAll(bundlePath, isSecretsStripped)
+	} else {
+		names := []string(c.Args())
+		if len(names) == 0 {
+			return fmt.Errorf("instance name is required")
+		}
+
+		err = instance.Export(names, bundlePath, isSecretsStripped)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(bundlePath)
+	return nil
+}
+
+func instanceExportSystemd(c *cli.Context) error {
+	args := c.Args()
+	name := args.Get(0)
+
+	if name == "" {
+		return fmt.Errorf("instance name is required")
+	}
+
+	unit, err := instance.GetSystemdUnit(name, instance.SystemdUnitOptions{
+		Restart:          c.String("restart"),
+		RestartSec:       c.Int("restart-sec"),
+		EnvironmentFiles: c.StringSlice("env-file"),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	outputPath := c.String("output")
+	if outputPath == "" {
+		fmt.Print(unit)
+		return nil
+	}
+
+	return ioutil.WriteFile(outputPath, []byte(unit), 0644)
+}
+
+func instanceImport(c *cli.Context) error {
+	args := c.Args()
+	bundlePath := args.Get(0)
+
+	if bundlePath == "" {
+		return fmt.Errorf("bundle path is required")
+	}
+
+	names, err := instance.Import(bundlePath, c.Bool("force"))
+	for _, name := range names {
+		fmt.Println(name)
+	}
+
+	return err
+}
+
+func instanceRemove(c *cli.Context) error {
+	args := c.Args()
+	name := args.Get(0)
+
+	if name == "" {
+		return fmt.Errorf("instance name is required")
+	}
+
+	return instance.Remove(name)
+}
+
+func instanceUpgrade(c *cli.Context) error {
+	args := c.Args()
+	name := args.Get(0)
+
+	if name == "" {
+		return fmt.Errorf("instance name is required")
+	}
+
+	commit, err := instance.Upgrade(name, c.String("ref"))
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(commit)
+	return nil
+}
+
+func instanceRename(c *cli.Context) error {
+	args := c.Args()
+
+	name := args.Get(0)
+	if name == "" {
+		return fmt.Errorf("instance name is required")
+	}
+
+	newName := args.Get(1)
+	if newName == "" {
+		return fmt.Errorf("new instance name is required")
+	}
+
+	return instance.Rename(name, newName)
+}
+
+func instanceStart(c *cli.Context) error {
+	filter, err := getInstanceFilter(c)
+	if err != nil {
+		return err
+	}
+
+	isDaemonRunning := daemon.IsRunning()
+	isSelected := c.Bool("all") || !filter.IsEmpty()
+
+	if isSelected && !isDaemonRunning {
+		readyTimeout := time.Minute
+		if c.IsSet("wait") {
+			readyTimeout = c.Duration("wait")
+		}
+
+		return instance.StartFiltered(*filter, c.Int("parallel"), readyTimeout)
+	}
+
+	if isSelected {
+		return forFilteredInstances(*filter, "Starting", daemon.StartInstance)
+	}
+
+	args := c.Args()
+	name := args.Get(0)
+
+	if name == "" {
+		return fmt.Errorf("instance name is required")
+	}
+
+	// running daemon starts instance, so it supervises it
+	if isDaemonRunning {
+		err = daemon.StartInstance(name)
+	} else {
+		err = instance.Start(name)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if c.IsSet("wait") {
+		return instance.WaitForReady(name, c.Duration("wait"))
+	}
+
+	return nil
+}
+
+func instanceStop(c *cli.Context) error {
+	filter, err := getInstanceFilter(c)
+	if err != nil {
+		return err
+	}
+
+	// daemon can't print plan of stop, so dry run is done here
+	isDaemonRunning := daemon.IsRunning() && !utils.IsDryRun()
+
+	if c.Bool("all") {
+		if !filter.IsEmpty() || c.Args().Get(0) != "" {
+			return fmt.Errorf("--all can't be used with instance name, --group or --label")
+		}
+
+		if isDaemonRunning {
+			return forFilteredInstances(instance.Filter{}, "Stopping", daemon.StopInstance)
+		}
+
+		return instance.StopFiltered(instance.Filter{})
+	}
+
+	if !filter.IsEmpty() && !isDaemonRunning {
+		return instance.StopFiltered(*filter)
+	}
+
+	if !filter.IsEmpty() {
+		return forFilteredInstances(*filter, "Stopping", daemon.StopInstance)
+	}
+
+	args := c.Args()
+	name := args.Get(0)
+
+	if name == "" {
+		return fmt.Errorf("instance name is required")
+	}
+
+	// daemon doesn't start instance again, when it's stopped through daemon
+	if isDaemonRunning {
+		return daemon.StopInstance(name)
+	}
+
+	return instance.Stop(name)
+}
+
+// forFilteredInstances runs action of daemon for every instance, which matches filter
+func forFilteredInstances(filter instance.Filter, progress string, action func(name string) error) error {
+	configs, err := instance.GetFilteredList(filter)
+	if err != nil {
+		return err
+	}
+
+	if len(*configs) == 0 {
+		return fmt.Errorf("no instances match filter")
+	}
+
+	bar := utils.StartProgress(progress+" instances", len(*configs))
+	defer bar.Done()
+
+	for index, config := range *configs {
+		bar.Step(config.Name)
+		utils.Infof("%v instance %v...\n", progress, config.Name)
+
+		err = action(config.Name)
+		if err != nil {
+			return utils.PartialError(index, len(*configs), err)
+		}
+	}
+
+	return nil
+}
+
+func instanceChannelsSet(c *cli.Context) error {
+	args := c.Args()
+	name := args.Get(0)
+
+	if name == "" {
+		return fmt.Errorf("instance name is required")
+	}
+
+	channelsMapping, err := parseChannelsMapping(args.Get(1))
+	if err != nil {
+		return err
+	}
+
+	return instance.SetChannelsMapping(name, *channelsMapping)
+}
+
+func instancePause(c *cli.Context) error {
+	args := c.Args()
+	name := args.Get(0)
+
+	if name == "" {
+		return fmt.Errorf("instance name is required")
+	}
+
+	return instance.Pause(name)
+}
+
+func instanceResume(c *cli.Context) error {
+	args := c.Args()
+	name := args.Get(0)
+
+	if name == "" {
+		return fmt.Errorf("instance name is required")
+	}
+
+	return instance.Resume(name)
+}
+
+func instanceTop(c *cli.Context) error {
+	filter, err := getInstanceFilter(c)
+	if err != nil {
+		return err
+	}
+
+	if c.Int("interval") <= 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+
+	return instance.Top(*filter, time.Duration(c.Int("interval"))*time.Second, os.Stdout)
+}
+
+func instanceAttach(c *cli.Context) error {
+	args := c.Args()
+	name := args.Get(0)
+
+	if name == "" {
+		return fmt.Errorf("instance name is required")
+	}
+
+	detachKeys := c.String("detach-keys")
+	utils.Infof("Attached to %v, press %v to detach\n", name, detachKeys)
+
+	return instance.Attach(name, os.Stdin, os.Stdout, detachKeys)
+}
+
+func instanceExec(c *cli.Context) error {
+	args := c.Args()
+	name := args.Get(0)
+
+	if name == "" {
+		return fmt.Errorf("instance name is required")
+	}
+
+	command := []string(args.Tail())
+	if len(command) > 0 && command[0] == "--" {
+		command = command[1:]
+	}
+
+	exitCode, err := instance.Exec(name, command, os.Stdin, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	if exitCode != 0 {
+		return cli.NewExitError("", exitCode)
+	}
+
+	return nil
+}
+
+func instanceDiff(c *cli.Context) error {
+	args := c.Args()
+	name := args.Get(0)
+
+	if name == "" {
+		return fmt.Errorf("instance name is required")
+	}
+
+	changes, err := instance.Diff(name)
+	if err != nil {
+		return err
+	}
+
+	if len(changes) == 0 {
+		fmt.Println("no pending changes")
+		return nil
+	}
+
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+
+	return nil
+}
+
+func instanceHistory(c *cli.Context) error {
+	args := c.Args()
+	name := args.Get(0)
+
+	if name == "" {
+		return fmt.Errorf("instance name is required")
+	}
+
+	history, err := instance.GetHistory(name)
+	if err != nil {
+		return err
+	}
+
+	for _, version := range *history {
+		fmt.Printf("%v\t%v\n", version.Version, version.CreatedAt.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+func instanceRollback(c *cli.Context) error {
+	args := c.Args()
+	name := args.Get(0)
+
+	if name == "" {
+		return fmt.Errorf("instance name is required")
+	}
+
+	version, err := instance.Rollback(name, c.Int("to"))
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("instance '%v' config is restored to version %v\n", name, version)
+	return nil
+}
+
+func instanceDev(c *cli.Context) error {
+	args := c.Args()
+	name := args.Get(0)
+
+	if name == "" {
+		return fmt.Errorf("instance name is required")
+	}
+
+	return instance.Dev(name, os.Stdout)
+}
+
+func instanceMetrics(c *cli.Context) error {
+	return instance.ServeMetrics(c.String("listen"))
+}
+
+func instanceStatus(c *cli.Context) error {
+	filter, err := getInstanceFilter(c)
+	if err != nil {
+		return err
+	}
+
+	if !filter.IsEmpty() {
+		info, err := global.GetListInstances(*filter)
+		if err != nil {
+			return err
+		}
+
+		for _, instanceInfo := range *info {
+			fmt.Println(instanceInfo.Config.Name, instanceInfo.Status)
+		}
+
+		return nil
+	}
+
+	args := c.Args()
+	name := args.Get(0)
+
+	if name == "" {
+		return fmt.Errorf("instance name is required")
+	}
+
+	status, err := instance.GetStatus(name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(status)
+
+	if !instance.IsActiveStatus(status) {
+		return nil
+	}
+
+	state, err := instance.GetState(name)
+	if err != nil {
+		return err
+	}
+
+	if state != nil {
+		for _, portMap := range state.Ports {
+			fmt.Printf("%v/%v -> %v\n", portMap.CubePort, portMap.Protocol, portMap.HostPort)
+		}
+	}
+
+	connectivity, err := global.GetInstanceBusConnectivity(name)
+	if err != nil {
+		return err
+	}
+
+	printBusConnectivity(*connectivity)
+	return nil
+}
+
+func printBusConnectivity(connectivity global.BusConnectivity) {
+	if connectivity.MonitoringError != "" {
+		fmt.Printf("bus: unknown, can't read bus connections: %v\n", connectivity.MonitoringError)
+		return
+	}
+
+	disconnects := 0
+	var lastEvent *global.BusConnectivityEvent
+
+	for i, event := range connectivity.Events {
+		if event.Event == global.BusDisconnected {
+			disconnects++
+		}
+
+		lastEvent = &connectivity.Events[i]
+	}
+
+	switch {
+	case lastEvent == nil:
+		fmt.Println("bus: not connected")
+	case connectivity.IsConnected:
+		fmt.Printf("bus: connected since %v, %v recent disconnects\n", lastEvent.Time.Format(time.RFC3339), disconnects)
+	default:
+		fmt.Printf("bus: disconnected since %v: %v\n", lastEvent.Time.Format(time.RFC3339), lastEvent.Reason)
+	}
+}
+
+func instanceLogs(c *cli.Context) error {
+	args := c.Args()
+	name := args.Get(0)
+
+	if name == "" {
+		return fmt.Errorf("instance name is required")
+	}
+
+	if c.Bool("bus") {
+		connectivity, err := global.GetInstanceBusConnectivity(name)
+		if err != nil {
+			return err
+		}
+
+		if connectivity.MonitoringError != "" {
+			return fmt.Errorf("can't read bus connections: %v", connectivity.MonitoringError)
+		}
+
+		for _, event := range connectivity.Events {
+			if event.Event == global.BusDisconnected {
+				fmt.Printf("%v bus %v %v: %v\n", event.Time.Format(time.RFC3339), event.Event, event.Address, event.Reason)
+				continue
+			}
+
+			fmt.Printf("%v bus %v %v\n", event.Time.Format(time.RFC3339), event.Event, event.Address)
+		}
+
+		return nil
+	}
+
+	return instance.Logs(name, c.Bool("follow"), os.Stdout)
+}
+
+func list(c *cli.Context) error {
+
+	filter, err := getInstanceFilter(c)
+	if err != nil {
+		return err
+	}
+
+	info, err := global.GetListInstances(*filter)
+	if err != nil {
+		return err
+	}
+
+	infoText, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(infoText))
+	return nil
+}
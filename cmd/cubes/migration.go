@@ -0,0 +1,432 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/akaumov/cubes/db"
+	"github.com/akaumov/cubes/global"
+	"github.com/akaumov/cubes/utils"
+	"github.com/urfave/cli"
+)
+
+var migrationCommand = cli.Command{
+	Name:  "migration",
+	Usage: "manage migrations",
+	Subcommands: []cli.Command{
+		{
+			Name:   "add",
+			Usage:  "add migrationDescription",
+			Action: audited(addMigration),
+		},
+		{
+			Name:   "list",
+			Usage:  "return migrations",
+			Action: listMigrations,
+		},
+		{
+			Name:   "snapshot",
+			Usage:  "return snapshot",
+			Action: migrationSnapshot,
+		},
+		{
+			Name:  "table",
+			Usage: "operations with tables",
+			Subcommands: []cli.Command{
+				{
+					Name:   "add",
+					Usage:  "add tableName",
+					Action: audited(addTable),
+				},
+				{
+					Name:   "delete",
+					Usage:  "delete tableName",
+					Action: audited(deleteTable),
+				},
+			},
+		},
+		{
+			Name:  "column",
+			Usage: "operations with columns of tables",
+			Subcommands: []cli.Command{
+				{
+					Name:  "add",
+					Usage: "add tableName columName columnType",
+					Flags: []cli.Flag{
+						cli.BoolTFlag{
+							Name:  "nullable",
+							Usage: "isNullable flag, default true",
+						},
+						cli.StringFlag{
+							Name:  "default",
+							Usage: "default value",
+						},
+					},
+					Action: audited(addColumn),
+				},
+				{
+					Name:   "delete",
+					Usage:  "delete tableName columName",
+					Action: audited(deleteColumn),
+				},
+			},
+		},
+
+		{
+			Name:  "primary",
+			Usage: "operations with primary keys",
+			Subcommands: []cli.Command{
+				{
+					Name:   "add",
+					Usage:  "add tableName columnName",
+					Action: audited(addPrimaryKey),
+				},
+				{
+					Name:   "delete",
+					Usage:  "delete tableName columnName",
+					Action: audited(deletePrimaryKey),
+				},
+			},
+		},
+		{
+			Name:   "sync",
+			Usage:  "sync migrations",
+			Action: audited(syncMigrations),
+		},
+		{
+			Name:  "reset",
+			Usage: "delete tables of synced migrations with their data and sync all migrations again, other tables are kept",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "force",
+					Usage: "delete data of tables, check what's deleted with --dry-run first",
+				},
+			},
+			ArgsUsage: "[--force]",
+			Action:    audited(resetMigrations),
+		},
+		{
+			Name:  "relation",
+			Usage: "define table relations",
+			Subcommands: []cli.Command{
+				{
+					Name:      "add",
+					ArgsUsage: "relation add relationName relationType tableName remoteTableName 'columnName1:remoteColumnName1;columnName2:remoteColumnName2'",
+					Action:    audited(addRelation),
+				},
+				{
+					Name:      "delete",
+					ArgsUsage: "relation delete table relationName",
+					Action:    audited(deleteRelation),
+				},
+			},
+		},
+		{
+			Name:  "unique",
+			Usage: "define unique constraints",
+			Subcommands: []cli.Command{
+				{
+					Name:      "add",
+					ArgsUsage: "unique add constraintName tableName 'columnName1;columnName2'",
+					Action:    audited(addUniqueConstraint),
+				},
+				{
+					Name:      "delete",
+					ArgsUsage: "unique delete table constraintName",
+					Action:    audited(deleteUniqueConstraint),
+				},
+			},
+		},
+	},
+}
+
+func addMigration(c *cli.Context) error {
+	args := c.Args()
+	description := args.Get(0)
+
+	migrationFileName, err := db.AddMigration(description)
+	if err == nil {
+		fmt.Println(migrationFileName)
+	}
+
+	return err
+}
+
+func addTable(c *cli.Context) error {
+	args := c.Args()
+	tableName := args.Get(0)
+
+	if tableName == "" {
+		return fmt.Errorf("table name is required")
+	}
+
+	updatedMigrationId, err := db.AddTable(tableName)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(updatedMigrationId)
+	return nil
+}
+
+func deleteTable(c *cli.Context) error {
+	args := c.Args()
+	tableName := args.Get(0)
+
+	if tableName == "" {
+		return fmt.Errorf("table name is required")
+	}
+
+	updatedMigrationId, err := db.DeleteTable(tableName)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(updatedMigrationId)
+	return nil
+}
+
+func addColumn(c *cli.Context) error {
+	args := c.Args()
+
+	tableName := args.Get(0)
+	if tableName == "" {
+		return fmt.Errorf("table name is required")
+	}
+
+	columnName := args.Get(1)
+	if columnName == "" {
+		return fmt.Errorf("column name is required")
+	}
+
+	columnType := args.Get(2)
+	if columnType == "" {
+		return fmt.Errorf("column type is required")
+	}
+
+	isNullable := c.BoolT("nullable")
+	defaultValue := c.String("default")
+
+	updatedMigrationId, err := db.AddColumn(tableName, columnName, columnType, isNullable, defaultValue)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(updatedMigrationId)
+	return nil
+}
+
+func deleteColumn(c *cli.Context) error {
+	args := c.Args()
+
+	tableName := args.Get(0)
+	if tableName == "" {
+		return fmt.Errorf("table name is required")
+	}
+
+	columnName := args.Get(1)
+	if columnName == "" {
+		return fmt.Errorf("column name is required")
+	}
+
+	updatedMigrationId, err := db.DeleteColumn(tableName, columnName)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(updatedMigrationId)
+	return nil
+}
+
+func addPrimaryKey(c *cli.Context) error {
+	args := c.Args()
+
+	tableName := args.Get(0)
+	if tableName == "" {
+		return fmt.Errorf("table name is required")
+	}
+
+	columnName := args.Get(1)
+	if columnName == "" {
+		return fmt.Errorf("column name is required")
+	}
+
+	updatedMigrationId, err := db.AddPrimaryKey(tableName, columnName)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(updatedMigrationId)
+	return nil
+}
+
+func deletePrimaryKey(c *cli.Context) error {
+	args := c.Args()
+
+	tableName := args.Get(0)
+	if tableName == "" {
+		return fmt.Errorf("table name is required")
+	}
+
+	columnName := args.Get(1)
+	if columnName == "" {
+		return fmt.Errorf("column name is required")
+	}
+
+	updatedMigrationId, err := db.DeletePrimaryKey(tableName, columnName)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(updatedMigrationId)
+	return nil
+}
+
+func listMigrations(c *cli.Context) error {
+	migrations, err := db.GetList()
+	if err != nil {
+		return err
+	}
+
+	packedMigrations, _ := json.MarshalIndent(migrations, "", "  ")
+
+	fmt.Println(string(packedMigrations))
+	return nil
+}
+
+func parseColumnsMapping(mappingRaw string) (*[]db.ColumnsMap, error) {
+	mapping := []db.ColumnsMap{}
+
+	if mappingRaw != "" {
+		for _, rawMap := range strings.Split(mappingRaw, ";") {
+			splittedMap := strings.Split(rawMap, ":")
+
+			if len(splittedMap) != 2 {
+				return nil, fmt.Errorf("wrong columns mapping: %v\n", rawMap)
+			}
+
+			column := splittedMap[0]
+			remoteColumn := splittedMap[1]
+
+			mapping = append(mapping, db.ColumnsMap{
+				Column:       column,
+				RemoteColumn: remoteColumn,
+			})
+		}
+	}
+
+	return &mapping, nil
+}
+
+func addRelation(c *cli.Context) error {
+	args := c.Args()
+
+	relationName := args.Get(0)
+	relationType := args.Get(1)
+	table := args.Get(2)
+	remoteTable := args.Get(3)
+	rawMapping := args.Get(4)
+
+	columnsMapping, err := parseColumnsMapping(rawMapping)
+	if err != nil {
+		return err
+	}
+
+	updatedMigrationId, err := db.AddRelation(relationName, db.RelationType(relationType), table, remoteTable, *columnsMapping)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(updatedMigrationId)
+	return nil
+}
+
+func deleteRelation(c *cli.Context) error {
+	args := c.Args()
+
+	table := args.Get(0)
+	relationName := args.Get(1)
+
+	updatedMigrationId, err := db.DeleteRelation(table, relationName)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(updatedMigrationId)
+	return nil
+}
+
+func addUniqueConstraint(c *cli.Context) error {
+	args := c.Args()
+
+	constraintName := args.Get(0)
+	table := args.Get(1)
+	rawColumns := args.Get(2)
+
+	columns := strings.Split(rawColumns, ";")
+
+	updatedMigrationId, err := db.AddUniqueConstraint(constraintName, table, columns)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(updatedMigrationId)
+	return nil
+}
+
+func deleteUniqueConstraint(c *cli.Context) error {
+	args := c.Args()
+
+	table := args.Get(0)
+	relationName := args.Get(1)
+
+	updatedMigrationId, err := db.DeleteUniqueConstraint(table, relationName)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(updatedMigrationId)
+	return nil
+}
+
+func migrationSnapshot(c *cli.Context) error {
+	snapshot, err := db.GetCurrentSnapshot()
+	if err != nil {
+		return err
+	}
+
+	textSnapshot, _ := json.MarshalIndent(*snapshot, "", "  ")
+	fmt.Println(string(textSnapshot))
+	return nil
+}
+
+func syncMigrations(c *cli.Context) error {
+	config, err := global.GetConfig()
+	if err != nil {
+		return err
+	}
+
+	if config.Database == nil {
+		return fmt.Errorf("database isn't configured: add database to project.json or set CUBES_DB_HOST, CUBES_DB_NAME, CUBES_DB_USER and CUBES_DB_PASSWORD")
+	}
+
+	return db.Sync(*config.Database)
+}
+
+func resetMigrations(c *cli.Context) error {
+	if !c.Bool("force") && !utils.IsDryRun() {
+		return fmt.Errorf("reset deletes data of tables: check it with --dry-run and pass --force")
+	}
+
+	config, err := global.GetConfig()
+	if err != nil {
+		return err
+	}
+
+	if config.Database == nil {
+		return fmt.Errorf("database isn't configured: add database to project.json or set CUBES_DB_HOST, CUBES_DB_NAME, CUBES_DB_USER and CUBES_DB_PASSWORD")
+	}
+
+	return db.Reset(*config.Database)
+}
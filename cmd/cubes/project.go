@@ -0,0 +1,416 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/akaumov/cubes/daemon"
+	"github.com/akaumov/cubes/db"
+	"github.com/akaumov/cubes/global"
+	"github.com/akaumov/cubes/utils"
+	"github.com/urfave/cli"
+)
+
+var initCommand = cli.Command{
+	Name:  "init",
+	Usage: "init project in current directory: project.json, migrations and instances directories, .gitignore entries and sample cube",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "db-host",
+			Value: "localhost",
+			Usage: "host of project database",
+		},
+		cli.IntFlag{
+			Name:  "db-port",
+			Value: 5432,
+			Usage: "port of project database",
+		},
+		cli.StringFlag{
+			Name:  "db-name",
+			Usage: "name of project database, it's project name by default",
+		},
+		cli.StringFlag{
+			Name:  "db-user",
+			Value: "admin",
+			Usage: "user of project database",
+		},
+		cli.StringFlag{
+			Name:  "db-password",
+			Usage: "password of project database",
+		},
+		cli.BoolFlag{
+			Name:  "no-sample",
+			Usage: "don't create sample cube",
+		},
+	},
+	ArgsUsage: "[--db-host] [--db-port] [--db-name] [--db-user] [--db-password] [--no-sample] projectName [description]",
+	Action:    audited(initProject),
+}
+
+var startCommand = cli.Command{
+	Name:   "start",
+	Usage:  "start project",
+	Action: audited(startProject),
+}
+
+var upCommand = cli.Command{
+	Name:  "up",
+	Usage: "start bus, sync migrations to database and start instances after instances they depend on, independent ones concurrently, running ones are kept",
+	Flags: []cli.Flag{
+		cli.DurationFlag{
+			Name:  "wait",
+			Value: time.Minute,
+			Usage: "wait until instance, which others depend on, is ready: --wait 2m",
+		},
+		parallelFlag,
+	},
+	ArgsUsage: "[--wait] [--parallel]",
+	Action:    audited(projectUp),
+}
+
+var downCommand = cli.Command{
+	Name:  "down",
+	Usage: "stop instances in reverse order of up and stop bus",
+	Flags: []cli.Flag{
+		cli.IntFlag{
+			Name:  "timeout",
+			Value: 30,
+			Usage: "seconds to wait for connections drain before bus is stopped",
+		},
+	},
+	ArgsUsage: "[--timeout]",
+	Action:    audited(projectDown),
+}
+
+var configCommand = cli.Command{
+	Name:   "config",
+	Usage:  "print project config with environment overrides: CUBES_DB_HOST, CUBES_DB_PORT, CUBES_DB_NAME, CUBES_DB_USER, CUBES_DB_PASSWORD, CUBES_BUS_PORT, CUBES_BUS_MONITORING_PORT, CUBES_DEFAULT_RUNTIME, CUBES_MIGRATIONS_PATH, CUBES_INSTANCES_PATH",
+	Action: projectConfig,
+}
+
+var versionCommand = cli.Command{
+	Name:  "version",
+	Usage: "print version of cubes with its commit, build date and supported schema and protocol versions",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "json",
+			Usage: "print version as json",
+		},
+	},
+	Action: printVersionCommand,
+}
+
+var pluginsCommand = cli.Command{
+	Name:  "plugins",
+	Usage: "list plugins: executables named cubes-<command> on PATH, which are run as 'cubes <command>'",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "json",
+			Usage: "print plugins as json",
+		},
+	},
+	Action: listPlugins,
+}
+
+var lintCommand = cli.Command{
+	Name:  "lint",
+	Usage: "run static checks of project without bus, database and docker: migrations, channel schemas, instances configs, channels mappings and unused bus channels",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "json",
+			Usage: "print findings as json",
+		},
+		cli.BoolFlag{
+			Name:  "strict",
+			Usage: "fail on warnings too",
+		},
+	},
+	ArgsUsage: "[--json] [--strict]",
+	Action:    lint,
+}
+
+var doctorCommand = cli.Command{
+	Name:  "doctor",
+	Usage: "check environment of project: project config, migrations, database, bus port, instances configs and docker, and print fixes of problems",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "json",
+			Usage: "print checks as json",
+		},
+	},
+	Action: doctor,
+}
+
+var statusCommand = cli.Command{
+	Name:  "status",
+	Usage: "summarize project: bus state, migrations, which aren't synced to database, instances health and recent failures, exit with error when anything is unhealthy",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "json",
+			Usage: "print status as json",
+		},
+	},
+	Action: projectStatus,
+}
+
+func initProject(c *cli.Context) error {
+	args := c.Args()
+
+	projectName := args.Get(0)
+	description := args.Get(1)
+
+	if projectName == "" {
+		return fmt.Errorf("project name is required")
+	}
+
+	database := db.Config{
+		Host:     c.String("db-host"),
+		Port:     c.Int("db-port"),
+		Name:     c.String("db-name"),
+		User:     c.String("db-user"),
+		Password: c.String("db-password"),
+	}
+
+	if database.Name == "" {
+		database.Name = projectName
+	}
+
+	return global.InitProject(projectName, description, &database, !c.Bool("no-sample"))
+}
+
+func projectConfig(c *cli.Context) error {
+	config, err := global.GetConfig()
+	if err != nil {
+		return err
+	}
+
+	// database password isn't printed, config output ends up in terminals and CI logs
+	if config.Database != nil && config.Database.Password != "" {
+		database := *config.Database
+		database.Password = "***"
+		config.Database = &database
+	}
+
+	configText, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if profile := utils.GetProfile(); profile != "" {
+		utils.Infof("Config with profile %v\n", profile)
+	}
+
+	fmt.Println(string(configText))
+	return nil
+}
+
+func printVersionCommand(c *cli.Context) error {
+	return printVersion(c.Bool("json"))
+}
+
+func projectUp(c *cli.Context) error {
+	return global.Up(c.Duration("wait"), c.Int("parallel"))
+}
+
+func projectDown(c *cli.Context) error {
+	return global.Down(time.Duration(c.Int("timeout")) * time.Second)
+}
+
+// runPlugin runs command, which cubes doesn't have, with plugin executable cubes-<command> from PATH
+func runPlugin(c *cli.Context) error {
+	args := c.Args()
+	if !args.Present() {
+		return cli.ShowAppHelp(c)
+	}
+
+	name := args.First()
+
+	pluginPath := utils.FindPlugin(name)
+	if pluginPath == "" {
+		return fmt.Errorf("unknown command %v: it isn't cubes command and %v%v isn't on PATH", name, utils.PluginPrefix, name)
+	}
+
+	exitCode, err := utils.RunPlugin(pluginPath, args.Tail())
+	if err != nil {
+		return fmt.Errorf("can't run plugin %v: %v", pluginPath, err)
+	}
+
+	if exitCode != 0 {
+		return cli.NewExitError("", exitCode)
+	}
+
+	return nil
+}
+
+func listPlugins(c *cli.Context) error {
+	plugins := utils.GetPlugins()
+
+	if c.Bool("json") {
+		pluginsText, err := json.MarshalIndent(plugins, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(pluginsText))
+		return nil
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "COMMAND\tPATH")
+
+	for _, plugin := range plugins {
+		fmt.Fprintf(writer, "%v\t%v\n", plugin.Name, plugin.Path)
+	}
+
+	return writer.Flush()
+}
+
+func lint(c *cli.Context) error {
+	report, err := global.RunLint()
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("json") {
+		reportText, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(reportText))
+	} else {
+		writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(writer, "SEVERITY\tCHECK\tTARGET\tMESSAGE")
+
+		for _, finding := range report.Findings {
+			fmt.Fprintf(writer, "%v\t%v\t%v\t%v\n", finding.Severity, finding.Check, finding.Target, finding.Message)
+		}
+
+		writer.Flush()
+		utils.Infof("%v errors, %v warnings\n", report.Errors, report.Warnings)
+	}
+
+	if report.Errors > 0 || (c.Bool("strict") && report.Warnings > 0) {
+		return utils.ValidationError(fmt.Errorf("lint found %v errors and %v warnings", report.Errors, report.Warnings))
+	}
+
+	return nil
+}
+
+func doctor(c *cli.Context) error {
+	report := global.RunDoctor()
+
+	if c.Bool("json") {
+		reportText, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(reportText))
+	} else {
+		writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(writer, "STATUS\tCHECK\tMESSAGE")
+
+		for _, check := range report.Checks {
+			fmt.Fprintf(writer, "%v\t%v\t%v\n", check.Status, check.Name, check.Message)
+
+			if check.Fix != "" {
+				fmt.Fprintf(writer, "\t\tfix: %v\n", check.Fix)
+			}
+		}
+
+		writer.Flush()
+	}
+
+	if report.Failed > 0 {
+		return utils.ValidationError(fmt.Errorf("%v checks failed", report.Failed))
+	}
+
+	return nil
+}
+
+func projectStatus(c *cli.Context) error {
+	status, err := global.GetProjectStatus()
+	if err != nil {
+		return err
+	}
+
+	// daemon remembers failures of supervised instances, which were started again since
+	supervisorStatus, err := daemon.GetStatus()
+	if err == nil {
+		for _, state := range supervisorStatus.Instances {
+			if state.LastError != "" {
+				status.AddFailure(state.Name, fmt.Sprintf("cubesd: %v, restarts: %v", state.LastError, state.Restarts))
+			}
+		}
+	}
+
+	if c.Bool("json") {
+		statusText, err := json.MarshalIndent(status, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(statusText))
+	} else {
+		project := status.Project
+		if status.Profile != "" {
+			project += ", profile " + status.Profile
+		}
+
+		fmt.Printf("Project: %v\n", project)
+
+		if status.Bus.IsRunning {
+			fmt.Printf("Bus: running on %v\n", status.Bus.Address)
+		} else {
+			fmt.Println("Bus: stopped")
+		}
+
+		switch {
+		case status.Migrations.IsSkipped:
+			fmt.Println("Migrations: database isn't configured")
+		case status.Migrations.Error != "":
+			fmt.Printf("Migrations: %v\n", status.Migrations.Error)
+		default:
+			fmt.Printf("Migrations: synced to '%v', %v pending\n", status.Migrations.SyncedId, len(status.Migrations.Pending))
+		}
+
+		fmt.Println()
+
+		writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(writer, "INSTANCE\tSTATUS\tHEALTHY")
+
+		for _, summary := range status.Instances {
+			fmt.Fprintf(writer, "%v\t%v\t%v\n", summary.Name, summary.Status, summary.IsHealthy)
+		}
+
+		writer.Flush()
+
+		if len(status.Failures) > 0 {
+			fmt.Println()
+			fmt.Println("Failures:")
+
+			for _, failure := range status.Failures {
+				if failure.Instance == "" {
+					fmt.Printf("  %v\n", failure.Message)
+					continue
+				}
+
+				fmt.Printf("  %v: %v\n", failure.Instance, failure.Message)
+			}
+		}
+	}
+
+	if !status.IsHealthy {
+		return fmt.Errorf("project is unhealthy: %v failures", len(status.Failures))
+	}
+
+	return nil
+}
+
+func startProject(c *cli.Context) error {
+	return global.StartProject()
+}
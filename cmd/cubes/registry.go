@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/akaumov/cubes/instance"
+	"github.com/akaumov/cubes/registry"
+	"github.com/akaumov/cubes/utils"
+	"github.com/urfave/cli"
+)
+
+var publishCommand = cli.Command{
+	Name:  "publish",
+	Usage: "build cube from local source and publish it with its meta to registry as archive or docker image",
+	Flags: []cli.Flag{
+		registryFlag,
+		cli.StringFlag{
+			Name:  "image",
+			Usage: "repository of docker image: registry.example.com/team/cube, cube is published as image tagged with version",
+		},
+		cli.StringFlag{
+			Name:  "name",
+			Usage: "name of template in registry, it's name of source directory by default",
+		},
+		cli.StringFlag{
+			Name:  "version",
+			Usage: "version of published cube, it's version of meta.json by default",
+		},
+		cli.StringFlag{
+			Name:  "description",
+			Usage: "description of template, it's description of meta.json by default",
+		},
+		cli.StringFlag{
+			Name:  "tags",
+			Usage: "tags of template: --tags tag1,tag2",
+		},
+	},
+	ArgsUsage: "[--registry] [--image] [--name] [--version] [--description] [--tags] sourcePath",
+	Action:    audited(publish),
+}
+
+var searchCommand = cli.Command{
+	Name:  "search",
+	Usage: "search cube templates in registry",
+	Flags: []cli.Flag{
+		registryFlag,
+	},
+	ArgsUsage: "[--registry] [query]",
+	Action:    search,
+}
+
+var installCommand = cli.Command{
+	Name:  "install",
+	Usage: "add instance from registry template",
+	Flags: []cli.Flag{
+		registryFlag,
+		cli.StringFlag{
+			Name:  "params",
+			Usage: "params overriding template params: --params 'param1:value1;param2:value2'",
+		},
+	},
+	ArgsUsage: "[--registry] [--params] template[@version] [name]",
+	Action:    audited(install),
+}
+
+var pullCommand = cli.Command{
+	Name:  "pull",
+	Usage: "download published cube into project cache and add its instance with channels and params declared by cube",
+	Flags: []cli.Flag{
+		registryFlag,
+		cli.StringFlag{
+			Name:  "params",
+			Usage: "params of instance: --params 'param1:value1;param2:value2'",
+		},
+		cli.BoolFlag{
+			Name:  "print",
+			Usage: "print config of instance without adding it",
+		},
+	},
+	ArgsUsage: "[--registry] [--params] [--print] name[@version] [instanceName]",
+	Action:    audited(pull),
+}
+
+var registryFlag = cli.StringFlag{
+	Name:   "registry",
+	EnvVar: "CUBES_REGISTRY",
+	Usage:  "url or path of registry index",
+}
+
+func publish(c *cli.Context) error {
+	sourcePath := c.Args().Get(0)
+	if sourcePath == "" {
+		return fmt.Errorf("source path is required")
+	}
+
+	cubePackage, err := instance.Pack(sourcePath, c.String("name"), c.String("version"))
+	if err != nil {
+		return err
+	}
+
+	tags := []string{}
+	for _, tag := range strings.Split(c.String("tags"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	source, err := registry.Publish(*cubePackage, registry.PublishOptions{
+		Registry:    c.String("registry"),
+		Image:       c.String("image"),
+		Description: c.String("description"),
+		Tags:        tags,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	utils.Infof("Cube %v %v is published\n", cubePackage.Name, cubePackage.Version)
+	fmt.Println(source)
+	return nil
+}
+
+func search(c *cli.Context) error {
+	templates, err := registry.Search(c.String("registry"), strings.Join(c.Args(), " "))
+	if err != nil {
+		return err
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "NAME\tVERSION\tSOURCE\tDESCRIPTION")
+
+	for _, template := range *templates {
+		version := template.Version
+		if version == "" {
+			version = "-"
+		}
+
+		fmt.Fprintf(writer, "%v\t%v\t%v\t%v\n", template.Name, version, template.Source, template.Description)
+	}
+
+	return writer.Flush()
+}
+
+func pull(c *cli.Context) error {
+	args := c.Args()
+
+	reference := args.Get(0)
+	if reference == "" {
+		return fmt.Errorf("cube name is required")
+	}
+
+	params, err := parseInstanceParams(c.String("params"))
+	if err != nil {
+		return err
+	}
+
+	config, err := registry.Pull(c.String("registry"), reference, args.Get(1), *params)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("print") {
+		configText, err := json.MarshalIndent(config, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(configText))
+		return nil
+	}
+
+	isExist, err := instance.IsExist(config.Name)
+	if err != nil {
+		return err
+	}
+
+	if isExist {
+		return fmt.Errorf("instance '%v' already exists", config.Name)
+	}
+
+	err = instance.Add(*config)
+	if err != nil {
+		return err
+	}
+
+	utils.Infof("Instance %v of %v is added\n", config.Name, reference)
+	return nil
+}
+
+func install(c *cli.Context) error {
+	args := c.Args()
+
+	templateName := args.Get(0)
+	if templateName == "" {
+		return fmt.Errorf("template name is required")
+	}
+
+	name := args.Get(1)
+	if name == "" {
+		name = strings.Split(templateName, "@")[0]
+	}
+
+	isExist, err := instance.IsExist(name)
+	if err != nil {
+		return err
+	}
+
+	if isExist {
+		return fmt.Errorf("instance '%v' already exists", name)
+	}
+
+	params, err := parseInstanceParams(c.String("params"))
+	if err != nil {
+		return err
+	}
+
+	return registry.Install(c.String("registry"), templateName, name, *params)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/akaumov/cubes/utils"
+	"github.com/urfave/cli"
+)
+
+var secretCommand = cli.Command{
+	Name:  "secret",
+	Usage: "encrypted secrets of project, params of instances and database config refer to them as ${secret:name}, they're resolved when instance is started or database is connected, key is taken from " + utils.EnvSecretsKey + ", .cubes/secrets/secrets.key or OS keychain",
+	Subcommands: []cli.Command{
+		{
+			Name:      "set",
+			Usage:     "set secret, value is read from stdin when it's omitted, so it isn't kept in shell history",
+			ArgsUsage: "name [value]",
+			Action:    audited(secretSet),
+		},
+		{
+			Name:      "get",
+			Usage:     "print value of secret",
+			ArgsUsage: "name",
+			Action:    secretGet,
+		},
+		{
+			Name:  "list",
+			Usage: "list names of secrets",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "json",
+					Usage: "print names as json",
+				},
+			},
+			ArgsUsage: "[--json]",
+			Action:    secretList,
+		},
+		{
+			Name:      "remove",
+			Usage:     "remove secret",
+			ArgsUsage: "name",
+			Action:    audited(secretRemove),
+		},
+	},
+}
+
+func secretSet(c *cli.Context) error {
+	args := c.Args()
+	name := args.Get(0)
+
+	if name == "" {
+		return fmt.Errorf("secret name is required")
+	}
+
+	value := args.Get(1)
+	if len(args) < 2 {
+		rawValue, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("can't read secret: %v", err)
+		}
+
+		value = strings.TrimRight(string(rawValue), "\r\n")
+	}
+
+	err := utils.SetSecret(name, value)
+	if err != nil {
+		return err
+	}
+
+	utils.Infof("Secret %v is set\n", name)
+	return nil
+}
+
+func secretGet(c *cli.Context) error {
+	name := c.Args().Get(0)
+	if name == "" {
+		return fmt.Errorf("secret name is required")
+	}
+
+	value, err := utils.GetSecret(name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(value)
+	return nil
+}
+
+func secretList(c *cli.Context) error {
+	names, err := utils.GetSecretsNames()
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("json") {
+		namesText, err := json.MarshalIndent(names, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(namesText))
+		return nil
+	}
+
+	for _, name := range names {
+		fmt.Println(name)
+	}
+
+	return nil
+}
+
+func secretRemove(c *cli.Context) error {
+	name := c.Args().Get(0)
+	if name == "" {
+		return fmt.Errorf("secret name is required")
+	}
+
+	err := utils.RemoveSecret(name)
+	if err != nil {
+		return err
+	}
+
+	utils.Infof("Secret %v is removed\n", name)
+	return nil
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/akaumov/cubes/utils"
+	"github.com/urfave/cli"
+)
+
+var telemetryCommand = cli.Command{
+	Name:  "telemetry",
+	Usage: "opt-in usage telemetry: names, durations and results of cubes commands are recorded to .cubes/telemetry/usage.log, args and flags aren't recorded and nothing is sent anywhere, it's disabled by default and " + utils.EnvTelemetry + "=0 turns it off",
+	Subcommands: []cli.Command{
+		{
+			Name:   "enable",
+			Usage:  "start recording usage of commands in project",
+			Action: audited(telemetryEnable),
+		},
+		{
+			Name:   "disable",
+			Usage:  "stop recording usage of commands, recorded usage is kept",
+			Action: audited(telemetryDisable),
+		},
+		{
+			Name:  "status",
+			Usage: "show whether telemetry is enabled and usage of the most used commands",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "json",
+					Usage: "print status as json",
+				},
+			},
+			ArgsUsage: "[--json]",
+			Action:    telemetryStatus,
+		},
+		{
+			Name:  "export",
+			Usage: "export recorded usage as json, so it can be shared with maintainers or platform team",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "since",
+					Usage: "export usage of last period: 24h, 720h",
+				},
+				cli.BoolFlag{
+					Name:  "summary",
+					Usage: "export usage summed by command instead of every run",
+				},
+				cli.StringFlag{
+					Name:  "output",
+					Usage: "write export to file instead of stdout",
+				},
+			},
+			ArgsUsage: "[--since] [--summary] [--output]",
+			Action:    telemetryExport,
+		},
+	},
+}
+
+// measured records duration and result of command to local telemetry, when project enabled it
+func measured(action func(c *cli.Context) error) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		startedAt := time.Now()
+		err := action(c)
+
+		utils.RecordUsage(getCommandPath(c), time.Since(startedAt), err != nil, version)
+		return err
+	}
+}
+
+// measureCommands wraps actions of commands and their subcommands with measured
+func measureCommands(commands []cli.Command) {
+	for i := range commands {
+		if action, ok := commands[i].Action.(func(c *cli.Context) error); ok {
+			commands[i].Action = measured(action)
+		}
+
+		measureCommands(commands[i].Subcommands)
+	}
+}
+
+func telemetryEnable(c *cli.Context) error {
+	err := utils.SetTelemetryEnabled(true)
+	if err != nil {
+		return err
+	}
+
+	utils.Infof("Telemetry is enabled, usage is recorded locally to .cubes/telemetry/usage.log\n")
+	return nil
+}
+
+func telemetryDisable(c *cli.Context) error {
+	err := utils.SetTelemetryEnabled(false)
+	if err != nil {
+		return err
+	}
+
+	utils.Infof("Telemetry is disabled\n")
+	return nil
+}
+
+type TelemetryStatus struct {
+	IsEnabled bool                 `json:"isEnabled"`
+	Id        string               `json:"id,omitempty"`
+	Records   int                  `json:"records"`
+	Commands  []utils.CommandUsage `json:"commands"`
+}
+
+func telemetryStatus(c *cli.Context) error {
+	settings, err := utils.GetTelemetrySettings()
+	if err != nil {
+		return err
+	}
+
+	records, err := utils.GetUsageRecords(time.Time{})
+	if err != nil {
+		return err
+	}
+
+	status := TelemetryStatus{
+		IsEnabled: utils.IsTelemetryEnabled(),
+		Id:        settings.Id,
+		Records:   len(records),
+		Commands:  utils.GetCommandsUsage(records),
+	}
+
+	if c.Bool("json") {
+		statusText, err := json.MarshalIndent(status, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(statusText))
+		return nil
+	}
+
+	if status.IsEnabled {
+		fmt.Printf("telemetry is enabled, %v runs are recorded\n", status.Records)
+	} else {
+		fmt.Printf("telemetry is disabled, %v runs are recorded\n", status.Records)
+	}
+
+	if len(status.Commands) == 0 {
+		return nil
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "COMMAND\tRUNS\tFAILURES\tAVG\tMAX")
+
+	for i, usage := range status.Commands {
+		if i == 10 {
+			break
+		}
+
+		fmt.Fprintf(writer, "%v\t%v\t%v\t%.0fms\t%.0fms\n", usage.Command, usage.Runs, usage.Failures, usage.AvgDurationMillis, usage.MaxDurationMillis)
+	}
+
+	return writer.Flush()
+}
+
+func telemetryExport(c *cli.Context) error {
+	since := time.Time{}
+
+	if c.String("since") != "" {
+		period, err := time.ParseDuration(c.String("since"))
+		if err != nil {
+			return fmt.Errorf("wrong period %v: %v", c.String("since"), err)
+		}
+
+		since = time.Now().Add(-period)
+	}
+
+	records, err := utils.GetUsageRecords(since)
+	if err != nil {
+		return err
+	}
+
+	var export interface{} = records
+	if c.Bool("summary") {
+		export = utils.GetCommandsUsage(records)
+	}
+
+	exportText, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if c.String("output") == "" {
+		fmt.Println(string(exportText))
+		return nil
+	}
+
+	err = ioutil.WriteFile(c.String("output"), append(exportText, '\n'), 0644)
+	if err != nil {
+		return err
+	}
+
+	utils.Infof("Exported %v runs to %v\n", len(records), c.String("output"))
+	return nil
+}
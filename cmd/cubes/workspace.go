@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/akaumov/cubes/global"
+	"github.com/akaumov/cubes/utils"
+	"github.com/urfave/cli"
+)
+
+var workspaceCommand = cli.Command{
+	Name:  "workspace",
+	Usage: "projects of user in ~/.cubes/workspace.json, --project selects them by name and commands outside of projects run in current one",
+	Subcommands: []cli.Command{
+		{
+			Name:  "add",
+			Usage: "register project directory in workspace, it's current directory by default",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "name",
+					Usage: "name of project in workspace, it's name of project config by default",
+				},
+			},
+			ArgsUsage: "[--name] [path]",
+			Action:    audited(workspaceAdd),
+		},
+		{
+			Name:      "remove",
+			Usage:     "remove project from workspace, its directory isn't touched",
+			ArgsUsage: "name",
+			Action:    audited(workspaceRemove),
+		},
+		{
+			Name:  "list",
+			Usage: "list projects of workspace",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "json",
+					Usage: "print projects as json",
+				},
+			},
+			ArgsUsage: "[--json]",
+			Action:    workspaceList,
+		},
+		{
+			Name:  "use",
+			Usage: "make project current, commands outside of projects run in it",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "clear",
+					Usage: "clear current project",
+				},
+			},
+			ArgsUsage: "name | --clear",
+			Action:    audited(workspaceUse),
+		},
+		{
+			Name:  "status",
+			Usage: "show health of every project of workspace: bus, migrations and instances",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "json",
+					Usage: "print statuses as json",
+				},
+			},
+			ArgsUsage: "[--json]",
+			Action:    workspaceStatus,
+		},
+	},
+}
+
+func workspaceAdd(c *cli.Context) error {
+	path := c.Args().Get(0)
+	if path == "" {
+		path = "."
+	}
+
+	project, err := utils.AddWorkspaceProject(c.String("name"), path)
+	if err != nil {
+		return err
+	}
+
+	utils.Infof("Project %v is added to workspace: %v\n", project.Name, project.Path)
+	return nil
+}
+
+func workspaceRemove(c *cli.Context) error {
+	name := c.Args().Get(0)
+	if name == "" {
+		return fmt.Errorf("project name is required")
+	}
+
+	err := utils.RemoveWorkspaceProject(name)
+	if err != nil {
+		return err
+	}
+
+	utils.Infof("Project %v is removed from workspace\n", name)
+	return nil
+}
+
+func workspaceList(c *cli.Context) error {
+	workspace, err := utils.GetWorkspace()
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("json") {
+		workspaceText, err := json.MarshalIndent(workspace, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(workspaceText))
+		return nil
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "\tNAME\tPATH")
+
+	for _, project := range workspace.Projects {
+		current := ""
+		if project.Name == workspace.Current {
+			current = "*"
+		}
+
+		fmt.Fprintf(writer, "%v\t%v\t%v\n", current, project.Name, project.Path)
+	}
+
+	return writer.Flush()
+}
+
+func workspaceUse(c *cli.Context) error {
+	name := c.Args().Get(0)
+	if name == "" && !c.Bool("clear") {
+		return fmt.Errorf("project name is required")
+	}
+
+	err := utils.UseWorkspaceProject(name)
+	if err != nil {
+		return err
+	}
+
+	if name == "" {
+		utils.Infof("Current project is cleared\n")
+		return nil
+	}
+
+	utils.Infof("Current project is %v\n", name)
+	return nil
+}
+
+func workspaceStatus(c *cli.Context) error {
+	statuses, err := global.GetWorkspaceStatus()
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("json") {
+		statusesText, err := json.MarshalIndent(statuses, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(statusesText))
+	} else {
+		writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(writer, "PROJECT\tHEALTH\tBUS\tINSTANCES\tFAILURES")
+
+		for _, projectStatus := range statuses {
+			if projectStatus.Status == nil {
+				fmt.Fprintf(writer, "%v\terror\t-\t-\t%v\n", projectStatus.Name, projectStatus.Error)
+				continue
+			}
+
+			status := projectStatus.Status
+
+			health := "healthy"
+			if !status.IsHealthy {
+				health = "unhealthy"
+			}
+
+			bus := "stopped"
+			if status.Bus.IsRunning {
+				bus = "running"
+			}
+
+			healthyInstances := 0
+			for _, instanceSummary := range status.Instances {
+				if instanceSummary.IsHealthy {
+					healthyInstances++
+				}
+			}
+
+			fmt.Fprintf(writer, "%v\t%v\t%v\t%v/%v\t%v\n", projectStatus.Name, health, bus, healthyInstances, len(status.Instances), len(status.Failures))
+		}
+
+		err = writer.Flush()
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, projectStatus := range statuses {
+		if projectStatus.Status == nil || !projectStatus.Status.IsHealthy {
+			return fmt.Errorf("workspace has unhealthy projects")
+		}
+	}
+
+	return nil
+}
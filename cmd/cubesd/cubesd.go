@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"time"
+
+	"github.com/akaumov/cubes/daemon"
+	"github.com/akaumov/cubes/utils"
+	"github.com/urfave/cli"
+)
+
+func main() {
+	app := cli.NewApp()
+	app.Name = "cubesd"
+	app.Usage = "daemon of cubes project: run it in project directory, cubes CLI talks to it over .cubes/daemon/cubesd.sock"
+	app.Version = "0.0.1"
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:  "bus",
+			Value: daemon.BusNone,
+			Usage: "bus, which daemon keeps running: none or container",
+		},
+		cli.DurationFlag{
+			Name:  "interval",
+			Value: 5 * time.Second,
+			Usage: "interval of instances checks, supervised instances are started again when they stop",
+		},
+		cli.StringFlag{
+			Name:  "listen",
+			Usage: "tcp address of HTTP API for CI and tools: --listen 127.0.0.1:7070, API is on local socket only by default",
+		},
+		cli.StringFlag{
+			Name:  "grpc-listen",
+			Usage: "tcp address of gRPC API: --grpc-listen 127.0.0.1:7071, its .proto and Go client are in daemon/cubesdpb, address other than loopback needs TLS",
+		},
+		cli.StringFlag{
+			Name:  "grpc-tls-cert",
+			Usage: "certificate of gRPC API, it's served with TLS then",
+		},
+		cli.StringFlag{
+			Name:  "grpc-tls-key",
+			Usage: "key of certificate of gRPC API",
+		},
+		cli.StringFlag{
+			Name:   "token",
+			EnvVar: "CUBESD_TOKEN",
+			Usage:  "admin bearer token, which clients of tcp API send in Authorization header, tokens with read-only and operator roles are added by 'cubes daemon token add'",
+		},
+		cli.StringFlag{
+			Name:  "log-file",
+			Usage: "write log to file, it's rotated when it grows over 10 MB",
+		},
+		cli.StringFlag{
+			Name:   "log-format",
+			Value:  "text",
+			EnvVar: "CUBES_LOG_FORMAT",
+			Usage:  "format of log: text or json",
+		},
+	}
+	app.Action = run
+
+	err := app.Run(os.Args)
+	if err != nil {
+		utils.Fatalf("%v", err)
+	}
+}
+
+func run(c *cli.Context) error {
+	if logPath := c.String("log-file"); logPath != "" {
+		logFile, err := utils.NewRotatingFile(logPath, 10*1024*1024, 3)
+		if err != nil {
+			return err
+		}
+
+		utils.SetLogOutput(logFile)
+	}
+
+	err := utils.SetLogFormat(c.String("log-format"))
+	if err != nil {
+		return err
+	}
+
+	return daemon.Serve(daemon.Options{
+		Bus:          c.String("bus"),
+		Interval:     c.Duration("interval"),
+		ApiAddress:   c.String("listen"),
+		ApiToken:     c.String("token"),
+		GrpcAddress:  c.String("grpc-listen"),
+		GrpcCertFile: c.String("grpc-tls-cert"),
+		GrpcKeyFile:  c.String("grpc-tls-key"),
+	})
+}
@@ -0,0 +1,293 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/akaumov/cubes/db"
+	"github.com/akaumov/cubes/global"
+	"github.com/akaumov/cubes/instance"
+	"github.com/akaumov/cubes/utils"
+)
+
+// ApiPrefix is prefix of daemon API version, incompatible changes of API get new prefix
+const ApiPrefix = "/v1/"
+
+func writeJson(writer http.ResponseWriter, value interface{}) {
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(value)
+}
+
+// sseWriter sends every line of output as server-sent event as soon as it's written
+type sseWriter struct {
+	writer  io.Writer
+	flusher http.Flusher
+	line    []byte
+}
+
+func (w *sseWriter) Write(data []byte) (int, error) {
+	w.line = append(w.line, data...)
+
+	for {
+		index := bytes.IndexByte(w.line, '\n')
+		if index == -1 {
+			break
+		}
+
+		_, err := fmt.Fprintf(w.writer, "data: %s\n\n", strings.TrimSuffix(string(w.line[:index]), "\r"))
+		if err != nil {
+			return 0, err
+		}
+
+		w.line = w.line[index+1:]
+	}
+
+	w.flusher.Flush()
+	return len(data), nil
+}
+
+func streamLogs(writer http.ResponseWriter, request *http.Request, name string) error {
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		return fmt.Errorf("logs can't be streamed")
+	}
+
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.Header().Set("Cache-Control", "no-cache")
+
+	output := sseWriter{
+		writer:  writer,
+		flusher: flusher,
+	}
+
+	return instance.Logs(name, request.URL.Query().Get("follow") == "true", &output)
+}
+
+// streamEvents sends lifecycle events of project as server-sent events until client disconnects,
+// type query filters them: ?type=instance.*
+func streamEvents(writer http.ResponseWriter, request *http.Request) error {
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		return fmt.Errorf("events can't be streamed")
+	}
+
+	filter := request.URL.Query().Get("type")
+	events := make(chan utils.Event, 64)
+
+	subscription, err := utils.SubscribeEvents(func(event utils.Event) {
+		if !utils.IsEventMatched(filter, event.Type) {
+			return
+		}
+
+		// events of slow client are dropped, so subscription isn't blocked
+		select {
+		case events <- event:
+		default:
+		}
+	})
+
+	if err != nil {
+		return err
+	}
+
+	defer subscription.Close()
+
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.Header().Set("Cache-Control", "no-cache")
+	flusher.Flush()
+
+	for {
+		select {
+		case <-request.Context().Done():
+			return nil
+		case event := <-events:
+			rawEvent, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+
+			_, err = fmt.Fprintf(writer, "data: %s\n\n", rawEvent)
+			if err != nil {
+				return nil
+			}
+
+			flusher.Flush()
+		}
+	}
+}
+
+func getEvents(writer http.ResponseWriter, request *http.Request) error {
+	since := time.Time{}
+
+	if period := request.URL.Query().Get("since"); period != "" {
+		duration, err := time.ParseDuration(period)
+		if err != nil {
+			return fmt.Errorf("wrong period %v: %v", period, err)
+		}
+
+		since = time.Now().Add(-duration)
+	}
+
+	events, err := utils.GetEvents(since)
+	if err != nil {
+		return err
+	}
+
+	filter := request.URL.Query().Get("type")
+	result := []utils.Event{}
+
+	for _, event := range events {
+		if utils.IsEventMatched(filter, event.Type) {
+			result = append(result, event)
+		}
+	}
+
+	writeJson(writer, result)
+	return nil
+}
+
+func syncMigrations() error {
+	config, err := global.GetConfig()
+	if err != nil {
+		return err
+	}
+
+	if config.Database == nil {
+		return fmt.Errorf("database isn't configured in project config")
+	}
+
+	return db.Sync(*config.Database)
+}
+
+// recordAudit records state-changing API request to audit log of project, user is user of daemon
+func recordAudit(request *http.Request, err error) {
+	client := request.RemoteAddr
+	if client == "" || client == "@" {
+		client = "local socket"
+	}
+
+	if name := getTokenName(request); name != "" {
+		client += ", token " + name
+	}
+
+	recordAuditEntry(client, request.Method+" "+request.URL.Path, err)
+}
+
+// recordAuditEntry records state-changing operation of API client to audit log of project
+func recordAuditEntry(client string, command string, err error) {
+	entry := utils.AuditEntry{
+		Source:  utils.AuditSourceDaemon,
+		Client:  client,
+		Command: command,
+		Result:  utils.AuditOk,
+	}
+
+	if err != nil {
+		entry.Result = utils.AuditFailed
+		entry.Error = err.Error()
+	}
+
+	auditErr := utils.RecordAudit(entry)
+	if auditErr != nil {
+		utils.Warningf("Can't record operation to audit log: %v\n", auditErr)
+	}
+}
+
+func (d *daemon) route(writer http.ResponseWriter, request *http.Request, path string) error {
+	parts := strings.Split(path, "/")
+	isGet := request.Method == http.MethodGet
+	isPost := request.Method == http.MethodPost
+
+	switch {
+	case path == "status" && isGet:
+		writeJson(writer, d.getStatus())
+	case path == "instances" && isGet:
+		writeJson(writer, d.getStatus().Instances)
+	case len(parts) == 3 && parts[0] == "instances" && parts[2] == "logs" && isGet:
+		return streamLogs(writer, request, parts[1])
+	case len(parts) == 3 && parts[0] == "instances" && isPost:
+		utils.Infof("%v %v\n", parts[2], parts[1])
+		return d.handleInstance(parts[1], parts[2])
+	case path == "bus/status" && isGet:
+		status, err := global.GetBusStatus()
+		if err != nil {
+			return err
+		}
+
+		writeJson(writer, status)
+	case path == "migrations" && isGet:
+		migrations, err := db.GetList()
+		if err != nil {
+			return err
+		}
+
+		writeJson(writer, migrations)
+	case path == "bus/channels" && isGet:
+		channels, err := global.GetBusChannels()
+		if err != nil {
+			return err
+		}
+
+		writeJson(writer, channels)
+	case path == "migrations/status" && isGet:
+		config, err := global.GetConfig()
+		if err != nil {
+			return err
+		}
+
+		status, err := db.GetMigrationsStatus(config.Database)
+		if err != nil {
+			return err
+		}
+
+		writeJson(writer, status)
+	case path == "events" && isGet && request.URL.Query().Get("follow") == "true":
+		return streamEvents(writer, request)
+	case path == "events" && isGet:
+		return getEvents(writer, request)
+	case path == "migrations/sync" && isPost:
+		utils.Infof("sync migrations\n")
+		return syncMigrations()
+	default:
+		http.NotFound(writer, request)
+	}
+
+	return nil
+}
+
+// serveHTTP serves daemon API:
+//
+//	GET  /v1/status                         daemon status with states of instances
+//	GET  /v1/instances                      states of instances
+//	POST /v1/instances/<name>/start|stop    start or stop instance
+//	GET  /v1/instances/<name>/logs          logs as server-sent events, ?follow=true streams new lines
+//	GET  /v1/bus/status                     bus status
+//	GET  /v1/bus/channels                   channels topology: instances, which publish and consume channels
+//	GET  /v1/migrations                     migrations of project
+//	GET  /v1/migrations/status              migrations with their sync status in project database
+//	POST /v1/migrations/sync                sync migrations to project database
+//	GET  /v1/events                         lifecycle events of project, ?since=1h and ?type=instance.* filter them,
+//	                                        ?follow=true streams new events as server-sent events
+func (d *daemon) serveHTTP(writer http.ResponseWriter, request *http.Request) {
+	path := strings.TrimPrefix(request.URL.Path, ApiPrefix)
+	if path == request.URL.Path {
+		http.NotFound(writer, request)
+		return
+	}
+
+	err := d.route(writer, request, strings.Trim(path, "/"))
+
+	if request.Method == http.MethodPost {
+		recordAudit(request, err)
+	}
+
+	if err != nil {
+		utils.Warningf("%v %v: %v\n", request.Method, request.URL.Path, err)
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+	}
+}
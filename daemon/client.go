@@ -0,0 +1,284 @@
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/akaumov/cubes/db"
+	"github.com/akaumov/cubes/global"
+	"github.com/akaumov/cubes/utils"
+)
+
+const socketFileName = "cubesd.sock"
+
+// socketMode allows only user of daemon to connect to socket
+const socketMode = 0600
+
+// GetSocketPath returns .cubes/daemon/cubesd.sock, which daemon of project listens on
+func GetSocketPath() (string, error) {
+	daemonDirectory, err := getDaemonDirectory()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(daemonDirectory, socketFileName), nil
+}
+
+// Client is typed client of daemon API, Go services use it to control cubes without shelling out
+type Client struct {
+	baseUrl string
+	token   string
+	client  *http.Client
+}
+
+// NewClient returns client of daemon API on tcp address, which is started with --listen and --token
+func NewClient(address string, token string) *Client {
+	return &Client{
+		baseUrl: "http://" + address,
+		token:   token,
+		client:  &http.Client{},
+	}
+}
+
+// NewSocketClient returns client of daemon of project in current directory, it's reached on local socket
+func NewSocketClient() (*Client, error) {
+	socketPath, err := GetSocketPath()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		baseUrl: "http://cubesd",
+		client: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, network string, address string) (net.Conn, error) {
+					var dialer net.Dialer
+					return dialer.DialContext(ctx, "unix", socketPath)
+				},
+			},
+		},
+	}, nil
+}
+
+func (c *Client) request(method string, path string) (*http.Response, error) {
+	request, err := http.NewRequest(method, c.baseUrl+ApiPrefix+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.token != "" {
+		request.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("can't connect to cubesd: %v", err)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		defer response.Body.Close()
+
+		message, _ := ioutil.ReadAll(response.Body)
+		return nil, fmt.Errorf("cubesd: %v", strings.TrimSpace(string(message)))
+	}
+
+	return response, nil
+}
+
+func (c *Client) call(method string, path string, result interface{}) error {
+	response, err := c.request(method, path)
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if result == nil {
+		return nil
+	}
+
+	return json.NewDecoder(response.Body).Decode(result)
+}
+
+func (c *Client) GetStatus() (*Status, error) {
+	var status Status
+
+	err := c.call(http.MethodGet, "status", &status)
+	if err != nil {
+		return nil, err
+	}
+
+	return &status, nil
+}
+
+func (c *Client) GetInstances() ([]InstanceState, error) {
+	instances := []InstanceState{}
+	return instances, c.call(http.MethodGet, "instances", &instances)
+}
+
+// StartInstance starts instance through daemon, daemon starts it again when it stops
+func (c *Client) StartInstance(name string) error {
+	return c.call(http.MethodPost, "instances/"+url.PathEscape(name)+"/start", nil)
+}
+
+// StopInstance stops instance through daemon, daemon doesn't supervise it anymore
+func (c *Client) StopInstance(name string) error {
+	return c.call(http.MethodPost, "instances/"+url.PathEscape(name)+"/stop", nil)
+}
+
+// Logs writes lines of instance logs to output, followed logs are written until connection is closed
+func (c *Client) Logs(name string, isFollow bool, output io.Writer) error {
+	response, err := c.request(http.MethodGet, "instances/"+url.PathEscape(name)+"/logs?follow="+fmt.Sprint(isFollow))
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	scanner := bufio.NewScanner(response.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		_, err = fmt.Fprintln(output, strings.TrimPrefix(line, "data: "))
+		if err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+func (c *Client) GetBusStatus() (*global.BusStatus, error) {
+	var status global.BusStatus
+
+	err := c.call(http.MethodGet, "bus/status", &status)
+	if err != nil {
+		return nil, err
+	}
+
+	return &status, nil
+}
+
+func (c *Client) GetBusChannels() (*global.BusChannels, error) {
+	var channels global.BusChannels
+
+	err := c.call(http.MethodGet, "bus/channels", &channels)
+	if err != nil {
+		return nil, err
+	}
+
+	return &channels, nil
+}
+
+func (c *Client) GetMigrationsStatus() (*db.MigrationsStatus, error) {
+	var status db.MigrationsStatus
+
+	err := c.call(http.MethodGet, "migrations/status", &status)
+	if err != nil {
+		return nil, err
+	}
+
+	return &status, nil
+}
+
+// GetEvents returns lifecycle events of project since period ago, filter matches their types: instance.*
+func (c *Client) GetEvents(since time.Duration, filter string) ([]utils.Event, error) {
+	events := []utils.Event{}
+
+	query := url.Values{}
+	query.Set("type", filter)
+	if since > 0 {
+		query.Set("since", since.String())
+	}
+
+	return events, c.call(http.MethodGet, "events?"+query.Encode(), &events)
+}
+
+// FollowEvents calls handler for new lifecycle events of project until connection is closed
+func (c *Client) FollowEvents(filter string, handler func(event utils.Event)) error {
+	response, err := c.request(http.MethodGet, "events?follow=true&type="+url.QueryEscape(filter))
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	scanner := bufio.NewScanner(response.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		var event utils.Event
+
+		err = json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event)
+		if err != nil {
+			return fmt.Errorf("can't parse event: %v", err)
+		}
+
+		handler(event)
+	}
+
+	return scanner.Err()
+}
+
+func (c *Client) GetMigrations() ([]db.Migration, error) {
+	migrations := []db.Migration{}
+	return migrations, c.call(http.MethodGet, "migrations", &migrations)
+}
+
+// SyncMigrations syncs migrations to project database
+func (c *Client) SyncMigrations() error {
+	return c.call(http.MethodPost, "migrations/sync", nil)
+}
+
+// IsRunning returns true when daemon of project answers on its socket
+func IsRunning() bool {
+	_, err := GetStatus()
+	return err == nil
+}
+
+// GetStatus returns status of daemon of project in current directory
+func GetStatus() (*Status, error) {
+	client, err := NewSocketClient()
+	if err != nil {
+		return nil, err
+	}
+
+	client.client.Timeout = time.Second
+	return client.GetStatus()
+}
+
+// StartInstance starts instance through daemon of project in current directory
+func StartInstance(name string) error {
+	client, err := NewSocketClient()
+	if err != nil {
+		return err
+	}
+
+	return client.StartInstance(name)
+}
+
+// StopInstance stops instance through daemon of project in current directory
+func StopInstance(name string) error {
+	client, err := NewSocketClient()
+	if err != nil {
+		return err
+	}
+
+	return client.StopInstance(name)
+}
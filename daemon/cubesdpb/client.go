@@ -0,0 +1,48 @@
+package cubesdpb
+
+import (
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// tokenCredentials sends bearer token of cubesd API with every call
+type tokenCredentials struct {
+	token    string
+	isSecure bool
+}
+
+func (t tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + t.token}, nil
+}
+
+// RequireTransportSecurity is false only for connection without TLS, cubesd serves it only on loopback address
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return t.isSecure
+}
+
+// Dial connects to gRPC API of cubesd on address of --grpc-listen, connection should be closed by caller.
+// API with TLS is verified by caFile, API on loopback address without TLS is dialed with empty caFile.
+func Dial(address string, token string, caFile string) (*grpc.ClientConn, CubesdClient, error) {
+	transportOption := grpc.WithInsecure()
+
+	if caFile != "" {
+		transportCredentials, err := credentials.NewClientTLSFromFile(caFile, "")
+		if err != nil {
+			return nil, nil, err
+		}
+
+		transportOption = grpc.WithTransportCredentials(transportCredentials)
+	}
+
+	connection, err := grpc.Dial(address, transportOption, grpc.WithPerRPCCredentials(tokenCredentials{
+		token:    token,
+		isSecure: caFile != "",
+	}))
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return connection, NewCubesdClient(connection), nil
+}
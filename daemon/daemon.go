@@ -0,0 +1,557 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/akaumov/cubes/global"
+	"github.com/akaumov/cubes/instance"
+	"github.com/akaumov/cubes/utils"
+)
+
+// bus modes of daemon: it doesn't touch bus or keeps bus container running
+const (
+	BusNone      = "none"
+	BusContainer = "container"
+)
+
+// InstanceState is state of instance, which daemon keeps between commands
+type InstanceState struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+
+	// IsSupervised instances are started again by daemon when they stop, they're instances started through daemon
+	IsSupervised bool      `json:"isSupervised"`
+	Restarts     int       `json:"restarts"`
+	LastError    string    `json:"lastError,omitempty"`
+	CheckedAt    time.Time `json:"checkedAt"`
+}
+
+type Status struct {
+	Pid       int       `json:"pid"`
+	StartedAt time.Time `json:"startedAt"`
+	Bus       string    `json:"bus"`
+
+	IsBusRunning bool            `json:"isBusRunning"`
+	Instances    []InstanceState `json:"instances"`
+}
+
+type daemon struct {
+	mutex   sync.Mutex
+	busMode string
+	status  Status
+	states  map[string]*InstanceState
+	// starting has instances, which are being started by supervisor or api, so they aren't started twice
+	starting map[string]bool
+}
+
+// supervisedFileName keeps names of supervised instances, so they're supervised again after daemon restart
+const supervisedFileName = "supervised.json"
+
+func getDaemonDirectory() (string, error) {
+	return utils.GetStateDirectoryPath("daemon")
+}
+
+func getSupervisedPath() (string, error) {
+	daemonDirectory, err := getDaemonDirectory()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(daemonDirectory, supervisedFileName), nil
+}
+
+func (d *daemon) loadSupervised() error {
+	supervisedPath, err := getSupervisedPath()
+	if err != nil {
+		return err
+	}
+
+	rawSupervised, err := ioutil.ReadFile(supervisedPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	names := []string{}
+	err = json.Unmarshal(rawSupervised, &names)
+	if err != nil {
+		return fmt.Errorf("can't parse %v: %v", supervisedPath, err)
+	}
+
+	for _, name := range names {
+		d.getState(name).IsSupervised = true
+	}
+
+	return nil
+}
+
+// saveSupervised is called with locked mutex
+func (d *daemon) saveSupervised() error {
+	supervisedPath, err := getSupervisedPath()
+	if err != nil {
+		return err
+	}
+
+	names := []string{}
+	for name, state := range d.states {
+		if state.IsSupervised {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	rawSupervised, err := json.MarshalIndent(names, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(supervisedPath, rawSupervised, 0644)
+}
+
+// getState is called with locked mutex
+func (d *daemon) getState(name string) *InstanceState {
+	state, ok := d.states[name]
+	if !ok {
+		state = &InstanceState{Name: name}
+		d.states[name] = state
+	}
+
+	return state
+}
+
+func (d *daemon) ensureBus() {
+	if d.busMode != BusContainer {
+		return
+	}
+
+	status, err := global.GetBusStatus()
+	if err == nil && status.IsRunning {
+		return
+	}
+
+	err = global.StartBus()
+	if err != nil {
+		utils.Warningf("Can't start bus: %v\n", err)
+	}
+}
+
+// beginStart marks instance as being started, false is returned when it's being started already. Restart is refused
+// for instance, which isn't supervised anymore, stop command can be handled while supervisor starts other instances.
+func (d *daemon) beginStart(name string, isRestart bool) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.starting[name] {
+		return false
+	}
+
+	if isRestart {
+		state, ok := d.states[name]
+		if !ok || !state.IsSupervised {
+			return false
+		}
+	}
+
+	d.starting[name] = true
+	return true
+}
+
+func (d *daemon) endStart(name string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	delete(d.starting, name)
+}
+
+// supervise refreshes states of instances and starts supervised instances, which are stopped. Statuses are read and
+// instances are started without locked mutex, so api isn't blocked by docker.
+func (d *daemon) supervise() {
+	d.ensureBus()
+
+	names, err := instance.GetNames()
+	if err != nil {
+		utils.Warningf("Can't list instances: %v\n", err)
+		return
+	}
+
+	busStatus, err := global.GetBusStatus()
+	isBusRunning := err == nil && busStatus.IsRunning
+
+	statuses := map[string]string{}
+	statusErrors := map[string]error{}
+
+	for _, name := range names {
+		statuses[name], statusErrors[name] = instance.GetStatus(name)
+	}
+
+	checkedAt := time.Now()
+	toRestart := []string{}
+
+	d.mutex.Lock()
+
+	for _, name := range names {
+		state := d.getState(name)
+		state.CheckedAt = checkedAt
+
+		status, err := statuses[name], statusErrors[name]
+		if err != nil {
+			state.Status = "unknown"
+			state.LastError = err.Error()
+			continue
+		}
+
+		previousStatus := state.Status
+		state.Status = status
+
+		// supervised instance, which stopped without stop command, is crashed too
+		isCrashed := status == instance.StatusFailed || status == instance.StatusCrashLooping ||
+			(state.IsSupervised && !instance.IsActiveStatus(status))
+
+		if isCrashed && status != previousStatus {
+			utils.EmitEvent(utils.EventInstanceCrashed, name, "instance is "+status)
+		}
+
+		if state.IsSupervised && !instance.IsActiveStatus(status) {
+			toRestart = append(toRestart, name)
+		}
+	}
+
+	for name := range d.states {
+		if _, ok := statuses[name]; !ok {
+			delete(d.states, name)
+		}
+	}
+
+	d.status.IsBusRunning = isBusRunning
+	d.mutex.Unlock()
+
+	for _, name := range toRestart {
+		if !d.beginStart(name, true) {
+			continue
+		}
+
+		utils.Infof("Instance %v is %v, starting it again\n", name, statuses[name])
+		err := instance.Start(name)
+
+		d.mutex.Lock()
+		delete(d.starting, name)
+		state := d.getState(name)
+
+		if err != nil {
+			state.LastError = err.Error()
+			utils.Warningf("Can't start instance %v: %v\n", name, err)
+		} else {
+			state.Restarts++
+			state.LastError = ""
+		}
+
+		d.mutex.Unlock()
+	}
+}
+
+func (d *daemon) getStatus() Status {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	status := d.status
+	status.Instances = []InstanceState{}
+
+	for _, state := range d.states {
+		status.Instances = append(status.Instances, *state)
+	}
+
+	sort.Slice(status.Instances, func(i, j int) bool {
+		return status.Instances[i].Name < status.Instances[j].Name
+	})
+
+	return status
+}
+
+func (d *daemon) setSupervised(name string, isSupervised bool) error {
+	// status is read from docker before lock, so other commands aren't blocked by it
+	status, statusErr := instance.GetStatus(name)
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	state := d.getState(name)
+	state.IsSupervised = isSupervised
+	state.LastError = ""
+
+	if statusErr == nil {
+		state.Status = status
+	}
+
+	return d.saveSupervised()
+}
+
+func (d *daemon) handleInstance(name string, action string) error {
+	switch action {
+	case "start":
+		if !d.beginStart(name, false) {
+			return fmt.Errorf("instance %v is being started already", name)
+		}
+
+		err := instance.Start(name)
+		d.endStart(name)
+
+		if err != nil {
+			return err
+		}
+
+		return d.setSupervised(name, true)
+	case "stop":
+		// instance isn't supervised before it's stopped, so supervisor doesn't start it again
+		err := d.setSupervised(name, false)
+		if err != nil {
+			return err
+		}
+
+		return instance.Stop(name)
+	}
+
+	return fmt.Errorf("unknown action '%v'", action)
+}
+
+func listenSocket() (net.Listener, error) {
+	socketPath, err := GetSocketPath()
+	if err != nil {
+		return nil, err
+	}
+
+	if IsRunning() {
+		return nil, fmt.Errorf("cubesd is running already on %v", socketPath)
+	}
+
+	// socket of crashed daemon is left behind
+	err = os.Remove(socketPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// socket controls instances of project, so only user of daemon connects to it
+	err = os.Chmod(socketPath, socketMode)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return &peerCheckingListener{Listener: listener}, nil
+}
+
+// peerCheckingListener accepts only clients of user of daemon on local socket
+type peerCheckingListener struct {
+	net.Listener
+}
+
+func (l *peerCheckingListener) Accept() (net.Conn, error) {
+	for {
+		connection, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		err = checkSocketPeer(connection)
+		if err == nil {
+			return connection, nil
+		}
+
+		utils.Warningf("Client on local socket is refused: %v\n", err)
+		connection.Close()
+	}
+}
+
+type Options struct {
+	// Bus is bus mode: none or container
+	Bus string
+
+	// Interval is interval of instances checks
+	Interval time.Duration
+
+	// ApiAddress is tcp address of HTTP API for CI and tools, API isn't served on tcp when it's empty
+	ApiAddress string
+
+	// ApiToken is admin bearer token of tcp API, tokens with roles are added by 'cubes daemon token add',
+	// clients on local socket don't need token
+	ApiToken string
+
+	// GrpcAddress is tcp address of gRPC API, it isn't served when it's empty, it needs token like HTTP API
+	GrpcAddress string
+
+	// GrpcCertFile and GrpcKeyFile are certificate and key of gRPC API, gRPC API without them is served only
+	// on loopback address, so token isn't sent over network in plain text
+	GrpcCertFile string
+	GrpcKeyFile  string
+}
+
+// isLoopbackAddress returns true when tcp address is reachable only from this host: 127.0.0.1:7071, localhost:7071
+func isLoopbackAddress(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func checkOptions(options Options) error {
+	if options.Bus != BusNone && options.Bus != BusContainer {
+		return fmt.Errorf("wrong bus mode %v, it can be %v or %v", options.Bus, BusNone, BusContainer)
+	}
+
+	if options.Interval <= 0 {
+		return fmt.Errorf("supervision interval must be positive")
+	}
+
+	if (options.GrpcCertFile == "") != (options.GrpcKeyFile == "") {
+		return fmt.Errorf("gRPC API needs both certificate and key for TLS")
+	}
+
+	if options.GrpcAddress != "" && options.GrpcCertFile == "" && !isLoopbackAddress(options.GrpcAddress) {
+		return fmt.Errorf("gRPC API on %v isn't loopback address, it needs TLS: pass --grpc-tls-cert and --grpc-tls-key", options.GrpcAddress)
+	}
+
+	for _, address := range []string{options.ApiAddress, options.GrpcAddress} {
+		if address == "" || options.ApiToken != "" {
+			continue
+		}
+
+		tokens, err := GetTokens()
+		if err != nil {
+			return err
+		}
+
+		if len(tokens) == 0 {
+			return fmt.Errorf("API on %v needs token, it controls instances of project: pass --token or add tokens with 'cubes daemon token add'", address)
+		}
+	}
+
+	return nil
+}
+
+// Serve runs daemon of project in current directory: it serves cubes CLI on local socket, keeps states of instances,
+// starts supervised instances again when they stop, keeps bus running and sends events to notifications. It runs until SIGINT or SIGTERM,
+// instances keep running after daemon is stopped.
+func Serve(options Options) error {
+	err := checkOptions(options)
+	if err != nil {
+		return err
+	}
+
+	listener, err := listenSocket()
+	if err != nil {
+		return err
+	}
+
+	defer listener.Close()
+
+	d := daemon{
+		busMode:  options.Bus,
+		states:   map[string]*InstanceState{},
+		starting: map[string]bool{},
+		status: Status{
+			Pid:       os.Getpid(),
+			StartedAt: time.Now(),
+			Bus:       options.Bus,
+		},
+	}
+
+	err = d.loadSupervised()
+	if err != nil {
+		return err
+	}
+
+	notifier, err := global.StartNotifier()
+	if err != nil {
+		return fmt.Errorf("can't start notifications: %v", err)
+	}
+
+	if notifier != nil {
+		defer notifier.Close()
+	}
+
+	d.supervise()
+
+	server := http.Server{
+		Handler: http.HandlerFunc(d.serveHTTP),
+	}
+
+	serveErrors := make(chan error, 3)
+	go func() {
+		serveErrors <- server.Serve(listener)
+	}()
+
+	apiServer := http.Server{
+		Addr:    options.ApiAddress,
+		Handler: requireToken(options.ApiToken, http.HandlerFunc(d.serveHTTP)),
+	}
+
+	if options.ApiAddress != "" {
+		go func() {
+			serveErrors <- apiServer.ListenAndServe()
+		}()
+
+		defer apiServer.Close()
+		utils.Infof("cubesd API is listening on %v\n", options.ApiAddress)
+	}
+
+	if options.GrpcAddress != "" {
+		grpcServer, err := newGrpcServer(&d, options.ApiToken, options.GrpcCertFile, options.GrpcKeyFile)
+		if err != nil {
+			return err
+		}
+
+		go func() {
+			serveErrors <- serveGrpc(grpcServer, options.GrpcAddress)
+		}()
+
+		defer grpcServer.Stop()
+		utils.Infof("cubesd gRPC API is listening on %v\n", options.GrpcAddress)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	ticker := time.NewTicker(options.Interval)
+	defer ticker.Stop()
+
+	utils.Infof("cubesd is listening on %v\n", listener.Addr())
+
+	for {
+		select {
+		case <-ticker.C:
+			d.supervise()
+		case <-signals:
+			utils.Infof("Stopping cubesd")
+			return server.Close()
+		case err = <-serveErrors:
+			return err
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package daemon
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/akaumov/cubes/utils"
+)
+
+// dashboardApiPrefix is prefix of daemon API requests of dashboard page, they're proxied to daemon socket
+const dashboardApiPrefix = "/api"
+
+// csrfHeader carries token of page, other sites can't read it, so they can't make state-changing requests
+const csrfHeader = "X-Cubes-Csrf-Token"
+
+// getAllowedHosts returns hosts, which page is opened by. Other hosts are refused, so site, which resolves its name
+// to local address, can't read API through browser. Address without host is opened by local names.
+func getAllowedHosts(address string) (map[string]bool, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("wrong dashboard address %v: %v", address, err)
+	}
+
+	if host != "" && host != "0.0.0.0" && host != "::" {
+		return map[string]bool{net.JoinHostPort(host, port): true}, nil
+	}
+
+	hosts := map[string]bool{}
+	for _, localHost := range []string{"localhost", "127.0.0.1", "::1"} {
+		hosts[net.JoinHostPort(localHost, port)] = true
+	}
+
+	hostname, err := os.Hostname()
+	if err == nil {
+		hosts[net.JoinHostPort(hostname, port)] = true
+	}
+
+	return hosts, nil
+}
+
+// checkDashboardRequest refuses requests of other sites: Host and Origin must be dashboard address and
+// state-changing requests must have token of page
+func checkDashboardRequest(request *http.Request, allowedHosts map[string]bool, csrfToken string) (int, string) {
+	if !allowedHosts[request.Host] {
+		return http.StatusForbidden, "dashboard isn't served on " + request.Host
+	}
+
+	origin := request.Header.Get("Origin")
+	if origin != "" {
+		originUrl, err := url.Parse(origin)
+		if err != nil || !allowedHosts[originUrl.Host] {
+			return http.StatusForbidden, "requests of " + origin + " aren't allowed"
+		}
+	}
+
+	if request.Method == http.MethodGet || request.Method == http.MethodHead {
+		return 0, ""
+	}
+
+	if subtle.ConstantTimeCompare([]byte(request.Header.Get(csrfHeader)), []byte(csrfToken)) != 1 {
+		return http.StatusForbidden, "wrong CSRF token"
+	}
+
+	return 0, ""
+}
+
+// ServeDashboard serves web page of project over daemon API: instances with their logs, bus channels topology
+// and migrations status. Page doesn't ask for token, requests of page are limited by role instead,
+// so read-only dashboard can be shared widely. Requests of other sites are refused.
+func ServeDashboard(address string, role string) error {
+	err := checkRole(role)
+	if err != nil {
+		return err
+	}
+
+	allowedHosts, err := getAllowedHosts(address)
+	if err != nil {
+		return err
+	}
+
+	rawCsrfToken := make([]byte, 24)
+
+	_, err = rand.Read(rawCsrfToken)
+	if err != nil {
+		return err
+	}
+
+	csrfToken := hex.EncodeToString(rawCsrfToken)
+	page := strings.Replace(dashboardPage, "{{csrfToken}}", csrfToken, 1)
+
+	client, err := NewSocketClient()
+	if err != nil {
+		return err
+	}
+
+	_, err = client.GetStatus()
+	if err != nil {
+		return err
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Director: func(request *http.Request) {
+			request.URL.Scheme = "http"
+			request.URL.Host = "cubesd"
+			request.URL.Path = strings.TrimPrefix(request.URL.Path, dashboardApiPrefix)
+		},
+		Transport: client.client.Transport,
+
+		// logs are server-sent events, they're flushed to page as soon as they come
+		FlushInterval: 100 * time.Millisecond,
+	}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("X-Frame-Options", "DENY")
+		w.Write([]byte(page))
+	})
+
+	mux.HandleFunc(dashboardApiPrefix+ApiPrefix, func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, dashboardApiPrefix)
+		if !IsRoleAllowed(role, r.Method, path) {
+			http.Error(w, "dashboard role "+role+" isn't allowed to "+r.Method+" "+path, http.StatusForbidden)
+			return
+		}
+
+		proxy.ServeHTTP(w, r)
+	})
+
+	mux.HandleFunc(dashboardApiPrefix+"/role", func(w http.ResponseWriter, r *http.Request) {
+		writeJson(w, map[string]string{"role": role})
+	})
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code, message := checkDashboardRequest(r, allowedHosts, csrfToken)
+		if code != 0 {
+			http.Error(w, message, code)
+			return
+		}
+
+		mux.ServeHTTP(w, r)
+	})
+
+	utils.Infof("Serving dashboard with role %v on http://%v\n", role, address)
+	return http.ListenAndServe(address, handler)
+}
+
+// dashboardPage polls daemon API, logs of opened instance are streamed while its card is opened
+const dashboardPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>cubes</title>
+<style>
+  body { font-family: sans-serif; margin: 20px; color: #222; }
+  h2 { margin-top: 28px; }
+  table { border-collapse: collapse; min-width: 600px; }
+  th, td { text-align: left; padding: 4px 12px; border-bottom: 1px solid #ddd; vertical-align: top; }
+  th { background: #f4f4f4; }
+  pre { margin: 0; white-space: pre-wrap; word-break: break-all; }
+  .muted { color: #888; }
+  .error { color: #b00; }
+  .cards { display: flex; flex-wrap: wrap; gap: 12px; }
+  .card { border: 1px solid #ddd; border-radius: 4px; padding: 10px 14px; min-width: 220px; }
+  .card h3 { margin: 0 0 6px 0; }
+  .running { color: #080; }
+  #logs { display: none; margin-top: 20px; }
+  #logs pre { background: #f8f8f8; border: 1px solid #ddd; padding: 8px; height: 320px; overflow-y: scroll; }
+</style>
+</head>
+<body>
+<h1>cubes</h1>
+<div id="status" class="muted">loading...</div>
+
+<h2>Instances</h2>
+<div id="instances" class="cards"></div>
+
+<div id="logs">
+  <h3>Logs of <span id="logsName"></span> <button onclick="closeLogs()">close</button></h3>
+  <pre id="logsLines"></pre>
+</div>
+
+<h2>Bus channels</h2>
+<div id="channelsStatus" class="muted"></div>
+<table>
+  <thead><tr><th>Channel</th><th>Publishers</th><th>Consumers</th><th>Other subscribers</th></tr></thead>
+  <tbody id="channels"></tbody>
+</table>
+
+<h2>Migrations</h2>
+<div id="migrationsStatus" class="muted"></div>
+<table>
+  <thead><tr><th>Id</th><th>Description</th><th>Synced</th></tr></thead>
+  <tbody id="migrations"></tbody>
+</table>
+
+<script>
+var interval = 2000;
+var csrfToken = "{{csrfToken}}";
+var logs = null;
+var canControl = false;
+
+function text(value) {
+  var element = document.createElement("div");
+  element.textContent = value;
+  return element.innerHTML;
+}
+
+function row(cells) {
+  return "<tr>" + cells.map(function (cell) { return "<td>" + cell + "</td>"; }).join("") + "</tr>";
+}
+
+function load(path, render) {
+  fetch("/api/v1/" + path).then(function (response) { return response.json(); }).then(render).catch(function () {});
+}
+
+function endpoints(channel, isPublisher) {
+  return channel.endpoints.filter(function (endpoint) {
+    return isPublisher ? endpoint.direction !== "in" : endpoint.direction !== "out";
+  }).map(function (endpoint) {
+    var name = text(endpoint.instance + " (" + endpoint.cubeChannel + ")");
+    return endpoint.isSubscribed ? "<b>" + name + "</b>" : name;
+  }).join("<br>");
+}
+
+function instanceAction(name, action) {
+  fetch("/api/v1/instances/" + encodeURIComponent(name) + "/" + action, { method: "POST", headers: { "X-Cubes-Csrf-Token": csrfToken } }).then(function (response) {
+    if (!response.ok) {
+      response.text().then(function (message) { alert(message); });
+    }
+
+    refresh();
+  });
+}
+
+function openLogs(name) {
+  closeLogs();
+
+  document.getElementById("logs").style.display = "block";
+  document.getElementById("logsName").textContent = name;
+
+  var lines = document.getElementById("logsLines");
+  lines.textContent = "";
+
+  logs = new EventSource("/api/v1/instances/" + encodeURIComponent(name) + "/logs?follow=true");
+  logs.onmessage = function (event) {
+    lines.textContent += event.data + "\n";
+    lines.scrollTop = lines.scrollHeight;
+  };
+}
+
+function closeLogs() {
+  if (logs) {
+    logs.close();
+    logs = null;
+  }
+
+  document.getElementById("logs").style.display = "none";
+}
+
+function card(state) {
+  var name = JSON.stringify(state.name).replace(/"/g, "&quot;");
+  var status = "<span class=\"" + (state.status === "running" ? "running" : "muted") + "\">" + text(state.status || "unknown") + "</span>";
+
+  return "<div class=\"card\"><h3>" + text(state.name) + "</h3>" +
+    "<div>" + status + (state.isSupervised ? ", supervised" : "") + ", restarts: " + state.restarts + "</div>" +
+    (state.lastError ? "<div class=\"error\">" + text(state.lastError) + "</div>" : "") +
+    "<p>" + (canControl ? "<button onclick=\"instanceAction(" + name + ", 'start')\">start</button> " +
+    "<button onclick=\"instanceAction(" + name + ", 'stop')\">stop</button> " : "") +
+    "<button onclick=\"openLogs(" + name + ")\">logs</button></p></div>";
+}
+
+function refresh() {
+  load("status", function (status) {
+    document.getElementById("status").textContent = "cubesd " + status.pid + ", bus " + status.bus +
+      (status.isBusRunning ? " is running" : " isn't running");
+
+    document.getElementById("instances").innerHTML = status.instances.length
+      ? status.instances.map(card).join("")
+      : "<span class=\"muted\">project has no instances</span>";
+  });
+
+  load("bus/channels", function (channels) {
+    document.getElementById("channelsStatus").textContent = channels.monitoringError
+      ? channels.monitoringError
+      : (channels.isLive ? "subscribed instances are bold" : "bus isn't running, channels are read from mappings");
+
+    document.getElementById("channels").innerHTML = channels.channels.map(function (channel) {
+      return row([text(channel.channel), endpoints(channel, true), endpoints(channel, false), text((channel.subscribers || []).join(", "))]);
+    }).join("");
+  });
+
+  load("migrations/status", function (status) {
+    document.getElementById("migrationsStatus").textContent = status.databaseError
+      ? "can't read database: " + status.databaseError
+      : (status.syncedId ? "synced to " + status.syncedId : "no migration is synced");
+
+    document.getElementById("migrations").innerHTML = status.migrations.map(function (migration) {
+      return row([text(migration.id), text(migration.description), migration.isSynced ? "yes" : "no"]);
+    }).join("");
+  });
+}
+
+fetch("/api/role").then(function (response) { return response.json(); }).then(function (result) {
+  canControl = result.role !== "read-only";
+  refresh();
+});
+
+setInterval(refresh, interval);
+</script>
+</body>
+</html>
+`
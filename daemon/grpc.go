@@ -0,0 +1,272 @@
+package daemon
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/akaumov/cubes/daemon/cubesdpb"
+	"github.com/akaumov/cubes/db"
+	"github.com/akaumov/cubes/global"
+	"github.com/akaumov/cubes/instance"
+	"github.com/akaumov/cubes/utils"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// grpcRoles are the lowest roles, which are allowed to call methods of gRPC API, other methods need admin
+var grpcRoles = map[string]string{
+	"/cubesd.Cubesd/GetStatus":       RoleReadOnly,
+	"/cubesd.Cubesd/GetInstanceLogs": RoleReadOnly,
+	"/cubesd.Cubesd/GetBusStatus":    RoleReadOnly,
+	"/cubesd.Cubesd/ListMigrations":  RoleReadOnly,
+	"/cubesd.Cubesd/StartInstance":   RoleOperator,
+	"/cubesd.Cubesd/StopInstance":    RoleOperator,
+}
+
+// grpcServer serves gRPC API of cubesd, it does the same as HTTP API
+type grpcServer struct {
+	d *daemon
+}
+
+// authorizeGrpc checks token of call in authorization metadata, name of token is returned
+func authorizeGrpc(ctx context.Context, adminToken string, method string) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	requestToken := ""
+	if values := md["authorization"]; len(values) > 0 {
+		requestToken = strings.TrimPrefix(values[0], "Bearer ")
+	}
+
+	name, role, ok := findToken(adminToken, requestToken)
+	if !ok || requestToken == "" {
+		return "", status.Error(codes.Unauthenticated, "wrong token")
+	}
+
+	requiredRole, ok := grpcRoles[method]
+	if !ok {
+		requiredRole = RoleAdmin
+	}
+
+	if rolesLevels[role] < rolesLevels[requiredRole] {
+		return "", status.Error(codes.PermissionDenied, "role "+role+" isn't allowed to call "+method)
+	}
+
+	return name, nil
+}
+
+// newGrpcServer returns gRPC server of daemon, it's served with TLS when certificate and key are passed
+func newGrpcServer(d *daemon, adminToken string, certFile string, keyFile string) (*grpc.Server, error) {
+	options := []grpc.ServerOption{
+		grpc.UnaryInterceptor(func(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			name, err := authorizeGrpc(ctx, adminToken, info.FullMethod)
+			if err != nil {
+				return nil, err
+			}
+
+			return handler(context.WithValue(ctx, tokenNameKey{}, name), request)
+		}),
+		grpc.StreamInterceptor(func(server interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			_, err := authorizeGrpc(stream.Context(), adminToken, info.FullMethod)
+			if err != nil {
+				return err
+			}
+
+			return handler(server, stream)
+		}),
+	}
+
+	if certFile != "" {
+		transportCredentials, err := credentials.NewServerTLSFromFile(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("can't read TLS certificate of gRPC API: %v", err)
+		}
+
+		options = append(options, grpc.Creds(transportCredentials))
+	}
+
+	server := grpc.NewServer(options...)
+	cubesdpb.RegisterCubesdServer(server, &grpcServer{d: d})
+	return server, nil
+}
+
+// recordGrpcAudit records state-changing call to audit log of project like recordAudit
+func recordGrpcAudit(ctx context.Context, command string, err error) {
+	client := "grpc"
+	if callPeer, ok := peer.FromContext(ctx); ok && callPeer.Addr != nil {
+		client = callPeer.Addr.String()
+	}
+
+	if name, _ := ctx.Value(tokenNameKey{}).(string); name != "" {
+		client += ", token " + name
+	}
+
+	recordAuditEntry(client, command, err)
+}
+
+func getGrpcInstanceState(state InstanceState) *cubesdpb.InstanceState {
+	result := cubesdpb.InstanceState{
+		Name:         state.Name,
+		Status:       state.Status,
+		IsSupervised: state.IsSupervised,
+		Restarts:     int32(state.Restarts),
+		LastError:    state.LastError,
+	}
+
+	// instance, which isn't checked yet, has zero time
+	if !state.CheckedAt.IsZero() {
+		result.CheckedAt = state.CheckedAt.Unix()
+	}
+
+	return &result
+}
+
+func (s *grpcServer) GetStatus(ctx context.Context, request *cubesdpb.GetStatusRequest) (*cubesdpb.Status, error) {
+	daemonStatus := s.d.getStatus()
+
+	result := cubesdpb.Status{
+		Pid:          int32(daemonStatus.Pid),
+		StartedAt:    daemonStatus.StartedAt.Unix(),
+		Bus:          daemonStatus.Bus,
+		IsBusRunning: daemonStatus.IsBusRunning,
+	}
+
+	for _, state := range daemonStatus.Instances {
+		result.Instances = append(result.Instances, getGrpcInstanceState(state))
+	}
+
+	return &result, nil
+}
+
+// handleInstance starts or stops instance and returns its state after it
+func (s *grpcServer) handleInstance(ctx context.Context, name string, action string) (*cubesdpb.InstanceState, error) {
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "instance name is required")
+	}
+
+	utils.Infof("%v %v\n", action, name)
+
+	err := s.d.handleInstance(name, action)
+	recordGrpcAudit(ctx, "grpc "+action+" "+name, err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, state := range s.d.getStatus().Instances {
+		if state.Name == name {
+			return getGrpcInstanceState(state), nil
+		}
+	}
+
+	return &cubesdpb.InstanceState{Name: name}, nil
+}
+
+func (s *grpcServer) StartInstance(ctx context.Context, request *cubesdpb.InstanceRequest) (*cubesdpb.InstanceState, error) {
+	return s.handleInstance(ctx, request.Name, "start")
+}
+
+func (s *grpcServer) StopInstance(ctx context.Context, request *cubesdpb.InstanceRequest) (*cubesdpb.InstanceState, error) {
+	return s.handleInstance(ctx, request.Name, "stop")
+}
+
+// logLinesWriter sends every line of output as message of stream as soon as it's written
+type logLinesWriter struct {
+	stream cubesdpb.Cubesd_GetInstanceLogsServer
+	line   []byte
+}
+
+func (w *logLinesWriter) Write(data []byte) (int, error) {
+	w.line = append(w.line, data...)
+
+	for {
+		index := bytes.IndexByte(w.line, '\n')
+		if index == -1 {
+			break
+		}
+
+		err := w.stream.Send(&cubesdpb.LogLine{
+			Text: strings.TrimSuffix(string(w.line[:index]), "\r"),
+		})
+
+		if err != nil {
+			return 0, err
+		}
+
+		w.line = w.line[index+1:]
+	}
+
+	return len(data), nil
+}
+
+func (s *grpcServer) GetInstanceLogs(request *cubesdpb.GetInstanceLogsRequest, stream cubesdpb.Cubesd_GetInstanceLogsServer) error {
+	if request.Name == "" {
+		return status.Error(codes.InvalidArgument, "instance name is required")
+	}
+
+	return instance.Logs(request.Name, request.Follow, &logLinesWriter{stream: stream})
+}
+
+func (s *grpcServer) GetBusStatus(ctx context.Context, request *cubesdpb.GetBusStatusRequest) (*cubesdpb.BusStatus, error) {
+	busStatus, err := global.GetBusStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	return &cubesdpb.BusStatus{
+		IsRunning:       busStatus.IsRunning,
+		Address:         busStatus.Address,
+		Version:         busStatus.Version,
+		UptimeSeconds:   busStatus.UptimeSeconds,
+		Subscriptions:   busStatus.Subscriptions,
+		Protocol:        int32(busStatus.Protocol),
+		MonitoringError: busStatus.MonitoringError,
+	}, nil
+}
+
+func (s *grpcServer) ListMigrations(ctx context.Context, request *cubesdpb.ListMigrationsRequest) (*cubesdpb.ListMigrationsResponse, error) {
+	migrations, err := db.GetList()
+	if err != nil {
+		return nil, err
+	}
+
+	result := cubesdpb.ListMigrationsResponse{}
+
+	for _, migration := range *migrations {
+		result.Migrations = append(result.Migrations, &cubesdpb.Migration{
+			Id:          migration.Id,
+			Description: migration.Description,
+		})
+	}
+
+	return &result, nil
+}
+
+func (s *grpcServer) SyncMigrations(ctx context.Context, request *cubesdpb.SyncMigrationsRequest) (*cubesdpb.SyncMigrationsResponse, error) {
+	utils.Infof("sync migrations\n")
+
+	err := syncMigrations()
+	recordGrpcAudit(ctx, "grpc sync migrations", err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &cubesdpb.SyncMigrationsResponse{}, nil
+}
+
+// serveGrpc serves gRPC API on tcp address until server is stopped
+func serveGrpc(server *grpc.Server, address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("can't listen gRPC API on %v: %v", address, err)
+	}
+
+	return server.Serve(listener)
+}
@@ -0,0 +1,43 @@
+package daemon
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// checkSocketPeer checks that client on local socket is run by user of daemon or root, socket mode protects it too,
+// but it's changed after socket is created
+func checkSocketPeer(connection net.Conn) error {
+	unixConnection, ok := connection.(*net.UnixConn)
+	if !ok {
+		return fmt.Errorf("connection isn't unix socket connection")
+	}
+
+	rawConnection, err := unixConnection.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var credentials *syscall.Ucred
+	var credentialsErr error
+
+	err = rawConnection.Control(func(fd uintptr) {
+		credentials, credentialsErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if credentialsErr != nil {
+		return fmt.Errorf("can't read credentials of client: %v", credentialsErr)
+	}
+
+	if credentials.Uid != 0 && int(credentials.Uid) != os.Getuid() {
+		return fmt.Errorf("client with pid %v is run by user %v, but daemon is run by user %v", credentials.Pid, credentials.Uid, os.Getuid())
+	}
+
+	return nil
+}
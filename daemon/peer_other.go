@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package daemon
+
+import "net"
+
+// checkSocketPeer doesn't check client on systems without SO_PEERCRED, clients of other users are refused
+// by socket mode
+func checkSocketPeer(connection net.Conn) error {
+	return nil
+}
@@ -0,0 +1,229 @@
+package daemon
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// roles of API tokens: read-only reads states and logs, operator starts and stops instances too,
+// admin can do everything, including migrations sync
+const (
+	RoleReadOnly = "read-only"
+	RoleOperator = "operator"
+	RoleAdmin    = "admin"
+)
+
+var rolesLevels = map[string]int{
+	RoleReadOnly: 1,
+	RoleOperator: 2,
+	RoleAdmin:    3,
+}
+
+// ApiToken is named token of API, only hash of token is kept
+type ApiToken struct {
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+const tokensFileName = "tokens.json"
+
+type tokenNameKey struct{}
+
+func checkRole(role string) error {
+	if _, ok := rolesLevels[role]; !ok {
+		return fmt.Errorf("wrong role %v, it can be %v, %v or %v", role, RoleReadOnly, RoleOperator, RoleAdmin)
+	}
+
+	return nil
+}
+
+// getRequiredRole returns the lowest role, which is allowed to make API request
+func getRequiredRole(method string, path string) string {
+	path = strings.Trim(strings.TrimPrefix(path, ApiPrefix), "/")
+	parts := strings.Split(path, "/")
+
+	switch {
+	case method == http.MethodGet:
+		return RoleReadOnly
+	case len(parts) == 3 && parts[0] == "instances" && (parts[2] == "start" || parts[2] == "stop"):
+		return RoleOperator
+	}
+
+	return RoleAdmin
+}
+
+// IsRoleAllowed returns true when role can make API request
+func IsRoleAllowed(role string, method string, path string) bool {
+	return rolesLevels[role] >= rolesLevels[getRequiredRole(method, path)]
+}
+
+func getTokensPath() (string, error) {
+	daemonDirectory, err := getDaemonDirectory()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(daemonDirectory, tokensFileName), nil
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// GetTokens returns named tokens of API, they're read on every request, so daemon doesn't need restart
+func GetTokens() ([]ApiToken, error) {
+	tokensPath, err := getTokensPath()
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := []ApiToken{}
+
+	rawTokens, err := ioutil.ReadFile(tokensPath)
+	if os.IsNotExist(err) {
+		return tokens, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(rawTokens, &tokens)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse %v: %v", tokensPath, err)
+	}
+
+	return tokens, nil
+}
+
+func saveTokens(tokens []ApiToken) error {
+	tokensPath, err := getTokensPath()
+	if err != nil {
+		return err
+	}
+
+	rawTokens, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(tokensPath, rawTokens, 0600)
+}
+
+// AddToken generates token with role and returns it, token can't be read again later
+func AddToken(name string, role string) (string, error) {
+	err := checkRole(role)
+	if err != nil {
+		return "", err
+	}
+
+	if name == "" {
+		return "", fmt.Errorf("token name is required")
+	}
+
+	tokens, err := GetTokens()
+	if err != nil {
+		return "", err
+	}
+
+	for _, token := range tokens {
+		if token.Name == name {
+			return "", fmt.Errorf("token %v exists already", name)
+		}
+	}
+
+	data := make([]byte, 24)
+
+	_, err = rand.Read(data)
+	if err != nil {
+		return "", err
+	}
+
+	token := hex.EncodeToString(data)
+
+	tokens = append(tokens, ApiToken{
+		Name:      name,
+		Role:      role,
+		Hash:      hashToken(token),
+		CreatedAt: time.Now(),
+	})
+
+	return token, saveTokens(tokens)
+}
+
+func RemoveToken(name string) error {
+	tokens, err := GetTokens()
+	if err != nil {
+		return err
+	}
+
+	for i, token := range tokens {
+		if token.Name == name {
+			return saveTokens(append(tokens[:i], tokens[i+1:]...))
+		}
+	}
+
+	return fmt.Errorf("token %v doesn't exist", name)
+}
+
+// findToken returns name and role of token, token of --token flag is admin token without name
+func findToken(adminToken string, token string) (string, string, bool) {
+	if adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1 {
+		return "", RoleAdmin, true
+	}
+
+	tokens, err := GetTokens()
+	if err != nil {
+		return "", "", false
+	}
+
+	hash := hashToken(token)
+
+	for _, apiToken := range tokens {
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(apiToken.Hash)) == 1 {
+			return apiToken.Name, apiToken.Role, true
+		}
+	}
+
+	return "", "", false
+}
+
+// requireToken passes requests with bearer token, which role allows request, name of token is kept in request context
+func requireToken(adminToken string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		requestToken := strings.TrimPrefix(request.Header.Get("Authorization"), "Bearer ")
+
+		name, role, ok := findToken(adminToken, requestToken)
+		if !ok || requestToken == "" {
+			http.Error(writer, "wrong token", http.StatusUnauthorized)
+			return
+		}
+
+		if !IsRoleAllowed(role, request.Method, request.URL.Path) {
+			http.Error(writer, "role "+role+" isn't allowed to "+request.Method+" "+request.URL.Path, http.StatusForbidden)
+			return
+		}
+
+		handler.ServeHTTP(writer, request.WithContext(context.WithValue(request.Context(), tokenNameKey{}, name)))
+	})
+}
+
+// getTokenName returns name of token of request, it's empty for local socket and --token
+func getTokenName(request *http.Request) string {
+	name, _ := request.Context().Value(tokenNameKey{}).(string)
+	return name
+}
@@ -0,0 +1,109 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestRolesOfApiRequests(t *testing.T) {
+	cases := []struct {
+		role      string
+		method    string
+		path      string
+		isAllowed bool
+	}{
+		{RoleReadOnly, http.MethodGet, ApiPrefix + "instances", true},
+		{RoleReadOnly, http.MethodPost, ApiPrefix + "instances/api/start", false},
+		{RoleOperator, http.MethodPost, ApiPrefix + "instances/api/start", true},
+		{RoleOperator, http.MethodPost, ApiPrefix + "instances/api/stop", true},
+		{RoleOperator, http.MethodPost, ApiPrefix + "migrations/sync", false},
+		{RoleOperator, http.MethodPost, ApiPrefix + "instances/api/remove", false},
+		{RoleAdmin, http.MethodPost, ApiPrefix + "migrations/sync", true},
+		{"unknown", http.MethodGet, ApiPrefix + "instances", false},
+	}
+
+	for _, c := range cases {
+		isAllowed := IsRoleAllowed(c.role, c.method, c.path)
+		if isAllowed != c.isAllowed {
+			t.Errorf("%v %v %v: expected allowed %v, got %v", c.role, c.method, c.path, c.isAllowed, isAllowed)
+		}
+	}
+}
+
+func TestCheckRoleRefusesUnknownRole(t *testing.T) {
+	for _, role := range []string{RoleReadOnly, RoleOperator, RoleAdmin} {
+		if err := checkRole(role); err != nil {
+			t.Errorf("role %v is refused: %v", role, err)
+		}
+	}
+
+	if err := checkRole("root"); err == nil {
+		t.Errorf("unknown role is accepted")
+	}
+}
+
+// useProjectDirectory runs test in temporary project directory, so tokens are kept there, returned func restores
+// working directory
+func useProjectDirectory(t *testing.T) func() {
+	directory, err := ioutil.TempDir("", "cubes_project_")
+	if err != nil {
+		t.Fatalf("can't create project directory: %v", err)
+	}
+
+	previousDirectory, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("can't read working directory: %v", err)
+	}
+
+	err = os.Chdir(directory)
+	if err != nil {
+		t.Fatalf("can't change working directory: %v", err)
+	}
+
+	return func() {
+		os.Chdir(previousDirectory)
+		os.RemoveAll(directory)
+	}
+}
+
+func TestAuthorizeGrpcChecksTokenAndRole(t *testing.T) {
+	defer useProjectDirectory(t)()
+
+	operatorToken, err := AddToken("ci", RoleOperator)
+	if err != nil {
+		t.Fatalf("can't add token: %v", err)
+	}
+
+	withToken := func(token string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+	}
+
+	name, err := authorizeGrpc(withToken(operatorToken), "", "/cubesd.Cubesd/StopInstance")
+	if err != nil || name != "ci" {
+		t.Fatalf("operator token isn't allowed to stop instance: %v, %v", name, err)
+	}
+
+	_, err = authorizeGrpc(withToken(operatorToken), "", "/cubesd.Cubesd/SyncMigrations")
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected permission denied for operator token, got %v", err)
+	}
+
+	_, err = authorizeGrpc(withToken("admin-token"), "admin-token", "/cubesd.Cubesd/SyncMigrations")
+	if err != nil {
+		t.Fatalf("admin token is refused: %v", err)
+	}
+
+	for _, token := range []string{"", "wrong"} {
+		_, err = authorizeGrpc(withToken(token), "", "/cubesd.Cubesd/GetStatus")
+		if status.Code(err) != codes.Unauthenticated {
+			t.Fatalf("expected unauthenticated call with token %q, got %v", token, err)
+		}
+	}
+}
@@ -0,0 +1,531 @@
+package daemon
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/akaumov/cubes/global"
+	"github.com/akaumov/cubes/utils"
+)
+
+// ServiceOptions are options of cubesd, which OS service runs in project directory after reboot.
+// Token of API isn't kept in service, API on tcp address needs tokens of 'cubes daemon token add'.
+type ServiceOptions struct {
+	Options
+
+	// IsUser installs service of current user: systemd user unit, launchd agent or task run on logon,
+	// it doesn't need root, but runs only when user is logged in
+	IsUser bool
+}
+
+type serviceData struct {
+	Name        string
+	Project     string
+	CubesdPath  string
+	ProjectPath string
+	Args        []string
+	Profile     string
+	IsUser      bool
+}
+
+// serviceManager registers cubesd with service manager of OS
+type serviceManager interface {
+	// getDefinition returns unit, plist or command, which is registered
+	getDefinition(service serviceData) (string, error)
+	install(service serviceData) error
+	uninstall(service serviceData) error
+	status(service serviceData) (string, error)
+}
+
+var serviceManagers = map[string]serviceManager{
+	"linux":   &systemdManager{},
+	"darwin":  &launchdManager{},
+	"windows": &windowsManager{},
+}
+
+const cubesdExecutable = "cubesd"
+
+var serviceNameRegexp = regexp.MustCompile(`[^a-z0-9-]+`)
+
+func getServiceManager() (serviceManager, error) {
+	manager, ok := serviceManagers[runtime.GOOS]
+	if !ok {
+		return nil, fmt.Errorf("services aren't supported on %v", runtime.GOOS)
+	}
+
+	return manager, nil
+}
+
+// getCubesdPath returns cubesd next to cubes executable or from PATH
+func getCubesdPath() (string, error) {
+	executable := cubesdExecutable
+	if runtime.GOOS == "windows" {
+		executable += ".exe"
+	}
+
+	cubesPath, err := os.Executable()
+	if err == nil {
+		cubesdPath := filepath.Join(filepath.Dir(cubesPath), executable)
+		if _, err = os.Stat(cubesdPath); err == nil {
+			return cubesdPath, nil
+		}
+	}
+
+	cubesdPath, err := exec.LookPath(executable)
+	if err != nil {
+		return "", fmt.Errorf("can't find %v next to cubes or in PATH", executable)
+	}
+
+	return filepath.Abs(cubesdPath)
+}
+
+func getServiceData(options ServiceOptions) (*serviceData, error) {
+	err := checkOptions(options.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	if options.ApiToken != "" {
+		return nil, fmt.Errorf("token isn't kept in service, add tokens with 'cubes daemon token add'")
+	}
+
+	config, err := global.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("can't read project config: %v", err)
+	}
+
+	projectPath, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	cubesdPath, err := getCubesdPath()
+	if err != nil {
+		return nil, err
+	}
+
+	daemonDirectory, err := getDaemonDirectory()
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"--bus", options.Bus, "--interval", options.Interval.String(), "--log-file", filepath.Join(daemonDirectory, "cubesd.log")}
+	if options.ApiAddress != "" {
+		args = append(args, "--listen", options.ApiAddress)
+	}
+
+	return &serviceData{
+		Name:        GetServiceName(config.Name),
+		Project:     config.Name,
+		CubesdPath:  cubesdPath,
+		ProjectPath: projectPath,
+		Args:        args,
+		Profile:     utils.GetProfile(),
+		IsUser:      options.IsUser,
+	}, nil
+}
+
+// GetServiceName returns name of service of project: cubes-<project>
+func GetServiceName(project string) string {
+	return "cubes-" + strings.Trim(serviceNameRegexp.ReplaceAllString(strings.ToLower(project), "-"), "-")
+}
+
+// GetServiceDefinition returns unit, plist or command, which is registered by InstallService, without installing it
+func GetServiceDefinition(options ServiceOptions) (string, error) {
+	manager, err := getServiceManager()
+	if err != nil {
+		return "", err
+	}
+
+	service, err := getServiceData(options)
+	if err != nil {
+		return "", err
+	}
+
+	return manager.getDefinition(*service)
+}
+
+// InstallService registers cubesd of project with service manager of OS and starts it, so it survives reboots
+func InstallService(options ServiceOptions) error {
+	manager, err := getServiceManager()
+	if err != nil {
+		return err
+	}
+
+	service, err := getServiceData(options)
+	if err != nil {
+		return err
+	}
+
+	err = manager.install(*service)
+	if err != nil {
+		return fmt.Errorf("can't install service %v: %v", service.Name, err)
+	}
+
+	utils.Infof("Service %v is installed and started\n", service.Name)
+	return nil
+}
+
+// UninstallService stops cubesd of project and removes its service, instances keep running
+func UninstallService(isUser bool) error {
+	manager, err := getServiceManager()
+	if err != nil {
+		return err
+	}
+
+	config, err := global.GetConfig()
+	if err != nil {
+		return fmt.Errorf("can't read project config: %v", err)
+	}
+
+	service := serviceData{
+		Name:   GetServiceName(config.Name),
+		IsUser: isUser,
+	}
+
+	err = manager.uninstall(service)
+	if err != nil {
+		return fmt.Errorf("can't uninstall service %v: %v", service.Name, err)
+	}
+
+	utils.Infof("Service %v is uninstalled\n", service.Name)
+	return nil
+}
+
+// GetServiceStatus returns state of service of project, which service manager reports
+func GetServiceStatus(isUser bool) (string, error) {
+	manager, err := getServiceManager()
+	if err != nil {
+		return "", err
+	}
+
+	config, err := global.GetConfig()
+	if err != nil {
+		return "", fmt.Errorf("can't read project config: %v", err)
+	}
+
+	return manager.status(serviceData{
+		Name:   GetServiceName(config.Name),
+		IsUser: isUser,
+	})
+}
+
+func renderService(serviceTemplate *template.Template, service serviceData) (string, error) {
+	var definition bytes.Buffer
+
+	err := serviceTemplate.Execute(&definition, service)
+	if err != nil {
+		return "", err
+	}
+
+	return definition.String(), nil
+}
+
+func runServiceCommand(name string, args ...string) (string, error) {
+	output, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%v %v: %v: %v", name, strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+func writeServiceFile(path string, definition string) error {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, []byte(definition), 0644)
+}
+
+func getHomeDirectory() (string, error) {
+	home := os.Getenv("HOME")
+	if home == "" {
+		return "", fmt.Errorf("HOME isn't set")
+	}
+
+	return home, nil
+}
+
+// systemdManager installs unit to /etc/systemd/system or ~/.config/systemd/user for user service
+type systemdManager struct{}
+
+// quoteSystemdArg quotes arguments of ExecStart with spaces, systemd splits command line by them
+func quoteSystemdArg(arg string) string {
+	if strings.ContainsAny(arg, " \t\"'\\") {
+		return strconv.Quote(arg)
+	}
+
+	return arg
+}
+
+var systemdServiceTemplate = template.Must(template.New("unit").Funcs(template.FuncMap{"quote": quoteSystemdArg}).Parse(`[Unit]
+Description=cubesd of cubes project {{.Project}}
+After=network-online.target docker.service
+
+[Service]
+Type=simple
+WorkingDirectory={{.ProjectPath}}
+{{if .Profile}}Environment=CUBES_ENV={{.Profile}}
+{{end}}ExecStart={{quote .CubesdPath}}{{range .Args}} {{quote .}}{{end}}
+Restart=on-failure
+RestartSec=5
+
+[Install]
+WantedBy={{if .IsUser}}default.target{{else}}multi-user.target{{end}}
+`))
+
+func (m *systemdManager) getUnitPath(service serviceData) (string, error) {
+	if !service.IsUser {
+		return filepath.Join("/etc/systemd/system", service.Name+".service"), nil
+	}
+
+	home, err := getHomeDirectory()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".config/systemd/user", service.Name+".service"), nil
+}
+
+func (m *systemdManager) systemctl(service serviceData, args ...string) (string, error) {
+	if service.IsUser {
+		args = append([]string{"--user"}, args...)
+	}
+
+	return runServiceCommand("systemctl", args...)
+}
+
+func (m *systemdManager) getDefinition(service serviceData) (string, error) {
+	return renderService(systemdServiceTemplate, service)
+}
+
+func (m *systemdManager) install(service serviceData) error {
+	unitPath, err := m.getUnitPath(service)
+	if err != nil {
+		return err
+	}
+
+	unit, err := m.getDefinition(service)
+	if err != nil {
+		return err
+	}
+
+	err = writeServiceFile(unitPath, unit)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.systemctl(service, "daemon-reload")
+	if err != nil {
+		return err
+	}
+
+	_, err = m.systemctl(service, "enable", "--now", service.Name)
+	return err
+}
+
+func (m *systemdManager) uninstall(service serviceData) error {
+	unitPath, err := m.getUnitPath(service)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.systemctl(service, "disable", "--now", service.Name)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(unitPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	_, err = m.systemctl(service, "daemon-reload")
+	return err
+}
+
+func (m *systemdManager) status(service serviceData) (string, error) {
+	args := []string{"is-active", service.Name}
+	if service.IsUser {
+		args = append([]string{"--user"}, args...)
+	}
+
+	// is-active exits with error for inactive units, its output is state anyway
+	output, err := exec.Command("systemctl", args...).Output()
+	if len(output) == 0 && err != nil {
+		return "", fmt.Errorf("can't get status of %v: %v", service.Name, err)
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+// launchdManager installs agent to ~/Library/LaunchAgents or daemon to /Library/LaunchDaemons
+type launchdManager struct{}
+
+var launchdServiceTemplate = template.Must(template.New("plist").Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>{{html .Name}}</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>{{html .CubesdPath}}</string>
+{{range .Args}}		<string>{{html .}}</string>
+{{end}}	</array>
+	<key>WorkingDirectory</key>
+	<string>{{html .ProjectPath}}</string>
+{{if .Profile}}	<key>EnvironmentVariables</key>
+	<dict>
+		<key>CUBES_ENV</key>
+		<string>{{html .Profile}}</string>
+	</dict>
+{{end}}	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<dict>
+		<key>SuccessfulExit</key>
+		<false/>
+	</dict>
+</dict>
+</plist>
+`))
+
+func (m *launchdManager) getPlistPath(service serviceData) (string, error) {
+	if !service.IsUser {
+		return filepath.Join("/Library/LaunchDaemons", service.Name+".plist"), nil
+	}
+
+	home, err := getHomeDirectory()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, "Library/LaunchAgents", service.Name+".plist"), nil
+}
+
+func (m *launchdManager) getDefinition(service serviceData) (string, error) {
+	return renderService(launchdServiceTemplate, service)
+}
+
+func (m *launchdManager) install(service serviceData) error {
+	plistPath, err := m.getPlistPath(service)
+	if err != nil {
+		return err
+	}
+
+	plist, err := m.getDefinition(service)
+	if err != nil {
+		return err
+	}
+
+	err = writeServiceFile(plistPath, plist)
+	if err != nil {
+		return err
+	}
+
+	_, err = runServiceCommand("launchctl", "load", "-w", plistPath)
+	return err
+}
+
+func (m *launchdManager) uninstall(service serviceData) error {
+	plistPath, err := m.getPlistPath(service)
+	if err != nil {
+		return err
+	}
+
+	_, err = runServiceCommand("launchctl", "unload", "-w", plistPath)
+	if err != nil {
+		return err
+	}
+
+	return os.Remove(plistPath)
+}
+
+func (m *launchdManager) status(service serviceData) (string, error) {
+	output, err := exec.Command("launchctl", "list", service.Name).Output()
+	if err != nil {
+		return "not installed", nil
+	}
+
+	// list prints "PID" = 123; for running service
+	if strings.Contains(string(output), `"PID"`) {
+		return "active", nil
+	}
+
+	return "inactive", nil
+}
+
+// windowsManager registers scheduled task, which runs cubesd on boot or on logon of user.
+// cubesd isn't service of service control manager, it doesn't handle its control requests.
+type windowsManager struct{}
+
+func (m *windowsManager) getDefinition(service serviceData) (string, error) {
+	command := fmt.Sprintf(`cmd /c cd /d "%v" && `, service.ProjectPath)
+	if service.Profile != "" {
+		command += fmt.Sprintf("set CUBES_ENV=%v&& ", service.Profile)
+	}
+
+	command += `"` + service.CubesdPath + `"`
+	for _, arg := range service.Args {
+		command += ` "` + arg + `"`
+	}
+
+	return command, nil
+}
+
+func (m *windowsManager) install(service serviceData) error {
+	command, err := m.getDefinition(service)
+	if err != nil {
+		return err
+	}
+
+	args := []string{"/Create", "/F", "/TN", service.Name, "/TR", command}
+	if service.IsUser {
+		args = append(args, "/SC", "ONLOGON")
+	} else {
+		args = append(args, "/SC", "ONSTART", "/RU", "SYSTEM")
+	}
+
+	_, err = runServiceCommand("schtasks", args...)
+	if err != nil {
+		return err
+	}
+
+	_, err = runServiceCommand("schtasks", "/Run", "/TN", service.Name)
+	return err
+}
+
+func (m *windowsManager) uninstall(service serviceData) error {
+	// task isn't running when cubesd was stopped already
+	runServiceCommand("schtasks", "/End", "/TN", service.Name)
+
+	_, err := runServiceCommand("schtasks", "/Delete", "/F", "/TN", service.Name)
+	return err
+}
+
+func (m *windowsManager) status(service serviceData) (string, error) {
+	output, err := runServiceCommand("schtasks", "/Query", "/TN", service.Name, "/FO", "LIST")
+	if err != nil {
+		return "not installed", nil
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "Status:") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "Status:")), nil
+		}
+	}
+
+	return "unknown", nil
+}
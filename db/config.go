@@ -0,0 +1,95 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/akaumov/cubes/utils"
+)
+
+// Config is connection of project database, it's kept in project config
+type Config struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Name     string `json:"name"`
+	User     string `json:"user"`
+	Password string `json:"password,omitempty"`
+
+	// settings of connection pool, database/sql defaults are used when they're 0. Managed databases limit
+	// number of connections, so they're set lower there.
+	MaxOpenConns           int `json:"maxOpenConns,omitempty"`
+	MaxIdleConns           int `json:"maxIdleConns,omitempty"`
+	ConnMaxLifetimeSeconds int `json:"connMaxLifetimeSeconds,omitempty"`
+
+	// StatementTimeoutSeconds is statement_timeout of connections, statements aren't limited when it's 0
+	StatementTimeoutSeconds int `json:"statementTimeoutSeconds,omitempty"`
+}
+
+// resolveSecrets replaces ${secret:name} references in connection fields, config keeps references
+func resolveSecrets(config Config) (Config, error) {
+	for _, field := range []*string{&config.Host, &config.Name, &config.User, &config.Password} {
+		value, err := utils.ResolveSecrets(*field)
+		if err != nil {
+			return config, fmt.Errorf("can't resolve secrets of database config: %v", err)
+		}
+
+		*field = value
+	}
+
+	return config, nil
+}
+
+func checkConfig(config Config) error {
+	if config.Host == "" || config.Name == "" || config.User == "" {
+		return fmt.Errorf("database host, name and user are required")
+	}
+
+	if config.Port <= 0 {
+		return fmt.Errorf("database port must be positive")
+	}
+
+	if config.MaxOpenConns < 0 || config.MaxIdleConns < 0 || config.ConnMaxLifetimeSeconds < 0 ||
+		config.StatementTimeoutSeconds < 0 {
+		return fmt.Errorf("database pool settings and statement timeout can't be negative")
+	}
+
+	return nil
+}
+
+// quoteConnectionValue quotes value of connection string, so passwords with spaces and quotes are passed as is
+func quoteConnectionValue(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+func getConnectionString(config Config) string {
+	connectionString := fmt.Sprintf("user=%v password=%v dbname=%v host=%v port=%v sslmode=disable",
+		quoteConnectionValue(config.User),
+		quoteConnectionValue(config.Password),
+		quoteConnectionValue(config.Name),
+		quoteConnectionValue(config.Host),
+		config.Port)
+
+	// driver passes statement_timeout to server as run-time parameter, so it's set for every connection of pool
+	if config.StatementTimeoutSeconds > 0 {
+		connectionString += fmt.Sprintf(" statement_timeout=%v", config.StatementTimeoutSeconds*1000)
+	}
+
+	return connectionString
+}
+
+// setPoolSettings limits connections of pool with config
+func setPoolSettings(db *sql.DB, config Config) {
+	if config.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(config.MaxOpenConns)
+	}
+
+	if config.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(config.MaxIdleConns)
+	}
+
+	if config.ConnMaxLifetimeSeconds > 0 {
+		db.SetConnMaxLifetime(time.Duration(config.ConnMaxLifetimeSeconds) * time.Second)
+	}
+}
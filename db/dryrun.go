@@ -0,0 +1,83 @@
+package db
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+
+	"github.com/akaumov/cubes/utils"
+)
+
+// sqlPrinter prints statements of migrations instead of running them
+type sqlPrinter struct{}
+
+func (p sqlPrinter) Exec(query string, args ...interface{}) (sql.Result, error) {
+	lines := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(query), "\n") {
+		lines = append(lines, strings.TrimSpace(line))
+	}
+
+	if len(args) == 0 {
+		utils.DryRunf("run SQL: %v\n", strings.Join(lines, "\n    "))
+	} else {
+		utils.DryRunf("run SQL: %v with %q\n", strings.Join(lines, "\n    "), args)
+	}
+
+	return driver.RowsAffected(0), nil
+}
+
+// printSync prints SQL, which Sync runs for migrations, which aren't synced yet. Database is only read to find them.
+func printSync(config Config) error {
+	migrations, err := IterateMigrations()
+	if err != nil {
+		return err
+	}
+
+	currentMigrationId, err := GetSyncedMigrationId(config)
+	if err != nil {
+		return err
+	}
+
+	printer := sqlPrinter{}
+
+	err = addMigrationsTableIfNotExist(printer)
+	if err != nil {
+		return err
+	}
+
+	applied, _, err := applyPendingMigrations(printer, migrations, currentMigrationId)
+	if err != nil {
+		return err
+	}
+
+	utils.Infof("%v migrations would be synced\n", len(applied))
+	return nil
+}
+
+// printReset prints SQL, which Reset runs: tables of synced migrations are dropped and all migrations are synced
+func printReset(tables []string) error {
+	migrations, err := IterateMigrations()
+	if err != nil {
+		return err
+	}
+
+	printer := sqlPrinter{}
+
+	err = dropTables(printer, tables)
+	if err != nil {
+		return err
+	}
+
+	err = addMigrationsTableIfNotExist(printer)
+	if err != nil {
+		return err
+	}
+
+	applied, _, err := applyPendingMigrations(printer, migrations, "")
+	if err != nil {
+		return err
+	}
+
+	utils.Infof("%v tables would be deleted, %v migrations would be synced\n", len(tables), len(applied))
+	return nil
+}
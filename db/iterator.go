@@ -0,0 +1,75 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// MigrationIterator goes over migration files in order of their ids. File is read and parsed only when its migration
+// is requested, so migrations, which are skipped, aren't parsed and all migrations aren't kept in memory.
+type MigrationIterator struct {
+	paths []string
+	index int
+}
+
+// IterateMigrations returns iterator, which is placed before the first migration
+func IterateMigrations() (*MigrationIterator, error) {
+
+	migrationsDirectoryPath, err := GetMigrationsDirectoryPath()
+	if err != nil {
+		return nil, err
+	}
+
+	paths, err := filepath.Glob(filepath.Join(migrationsDirectoryPath, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(paths)
+
+	return &MigrationIterator{
+		paths: paths,
+		index: -1,
+	}, nil
+}
+
+// Next moves iterator to next migration, false is returned when there're no more migrations
+func (i *MigrationIterator) Next() bool {
+	if i.index < len(i.paths) {
+		i.index++
+	}
+
+	return i.index < len(i.paths)
+}
+
+// Id returns id of current migration from name of its file, file isn't read
+func (i *MigrationIterator) Id() string {
+	_, fileName := filepath.Split(i.paths[i.index])
+	return strings.SplitN(strings.TrimSuffix(fileName, ".json"), "_", 2)[0]
+}
+
+// Remaining returns number of migrations after current one
+func (i *MigrationIterator) Remaining() int {
+	return len(i.paths) - i.index - 1
+}
+
+// Migration reads and parses current migration
+func (i *MigrationIterator) Migration() (*Migration, error) {
+
+	rawMigration, err := ioutil.ReadFile(i.paths[i.index])
+	if err != nil {
+		return nil, fmt.Errorf("can't read migration %v: %v", i.Id(), err)
+	}
+
+	var migration Migration
+	err = json.Unmarshal(rawMigration, &migration)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse migration %v: %v", i.Id(), err)
+	}
+
+	return &migration, nil
+}
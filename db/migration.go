@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/akaumov/cubes/utils"
 	_ "github.com/lib/pq"
 )
 
@@ -89,6 +90,9 @@ type Action struct {
 	Params json.RawMessage `json:"params"`
 }
 
+// MigrationSchemaVersion is schema version of migrations, which are added by this cubes
+const MigrationSchemaVersion = "1"
+
 type Migration struct {
 	SchemaVersion string   `json:"schemaVersion"`
 	Id            string   `json:"id"`
@@ -97,13 +101,7 @@ type Migration struct {
 }
 
 func GetMigrationsDirectoryPath() (string, error) {
-	pwd, err := os.Getwd()
-	if err != nil {
-		return "", err
-	}
-
-	directory := filepath.Join(pwd, migrationsDirectoryName)
-	return directory, nil
+	return utils.GetMigrationsPath(migrationsDirectoryName)
 }
 
 func AddMigration(description string) (string, error) {
@@ -127,7 +125,7 @@ func AddMigration(description string) (string, error) {
 	}
 
 	migration := Migration{
-		SchemaVersion: "1",
+		SchemaVersion: MigrationSchemaVersion,
 		Id:            dateId,
 		Description:   description,
 		Actions:       []Action{},
@@ -155,7 +153,38 @@ func AddMigration(description string) (string, error) {
 		return "", err
 	}
 
-	return fileName, ioutil.WriteFile(filepath.Join(migrationsDir, fileName), packedMigration, 0777)
+	return fileName, writeMigrationFile(filepath.Join(migrationsDir, fileName), packedMigration)
+}
+
+// writeMigrationFile replaces migration file by rename, so modification time of migrations directory is changed
+// and snapshots cached by other processes, like cubesd, are dropped
+func writeMigrationFile(path string, data []byte) error {
+	defer resetSnapshotCache()
+
+	file, err := ioutil.TempFile(filepath.Dir(path), ".migration_")
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Chmod(file.Name(), 0644)
+	}
+
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+
+	if err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+
+	return nil
 }
 
 func getMigrationPath(id string) (string, error) {
@@ -209,27 +238,15 @@ func Get(id string) (*Migration, error) {
 
 func GetList() (*[]Migration, error) {
 
-	migrationsDirectoryPath, err := GetMigrationsDirectoryPath()
+	migrations, err := IterateMigrations()
 	if err != nil {
 		return nil, err
 	}
 
-	configsPathPattern := filepath.Join(migrationsDirectoryPath, "*.json")
-	files, err := filepath.Glob(configsPathPattern)
-
-	if err != nil {
-		return nil, err
-	}
-
-	sort.Strings(files)
-
 	result := []Migration{}
 
-	for _, migrationPath := range files {
-		_, fileName := filepath.Split(migrationPath)
-		migrationId := strings.TrimSuffix(fileName, ".json")
-
-		migration, err := Get(migrationId)
+	for migrations.Next() {
+		migration, err := migrations.Migration()
 		if err != nil {
 			return nil, fmt.Errorf("can't read migration %v/n", err)
 		}
@@ -237,7 +254,7 @@ func GetList() (*[]Migration, error) {
 		result = append(result, *migration)
 	}
 
-	return &result, err
+	return &result, nil
 }
 
 func addActionToMigrationFile(method string, params interface{}) (string, error) {
@@ -269,7 +286,7 @@ func addActionToMigrationFile(method string, params interface{}) (string, error)
 
 	packedMigration, _ := json.MarshalIndent(lastMigration, "", "  ")
 	migrationPath, _ := getMigrationPath(lastMigration.Id)
-	err = ioutil.WriteFile(migrationPath, packedMigration, 0777)
+	err = writeMigrationFile(migrationPath, packedMigration)
 	if err != nil {
 		return "", fmt.Errorf("can't write migration/n")
 	}
@@ -461,3 +478,63 @@ func DeleteUniqueConstraint(table string, constrtaintName string) (string, error
 
 	return addActionToMigrationFile("deleteUniqueConstraint", params)
 }
+
+// getProblemMessage strips "/n", which errors of migrations end with
+func getProblemMessage(err error) string {
+	return strings.Replace(err.Error(), "/n", "", -1)
+}
+
+// MigrationProblem is reason, why migration can't be synced, Action is index of failed action or -1
+type MigrationProblem struct {
+	Migration string `json:"migration"`
+	Action    int    `json:"action"`
+	Message   string `json:"message"`
+}
+
+// ValidateMigrations parses every migration and applies its actions to snapshot in order without database,
+// so broken migrations are found before sync. Failed actions are skipped and next ones are still checked.
+func ValidateMigrations() ([]MigrationProblem, error) {
+	migrationsDirectoryPath, err := GetMigrationsDirectoryPath()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := filepath.Glob(filepath.Join(migrationsDirectoryPath, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(files)
+
+	problems := []MigrationProblem{}
+	snapshot := Snapshot{
+		Tables: []Table{},
+	}
+
+	for _, migrationPath := range files {
+		migrationId := strings.TrimSuffix(filepath.Base(migrationPath), ".json")
+
+		migration, err := Get(migrationId)
+		if err != nil {
+			problems = append(problems, MigrationProblem{Migration: migrationId, Action: -1, Message: getProblemMessage(err)})
+			continue
+		}
+
+		if migration.SchemaVersion != "" && migration.SchemaVersion != MigrationSchemaVersion {
+			problems = append(problems, MigrationProblem{
+				Migration: migrationId,
+				Action:    -1,
+				Message:   fmt.Sprintf("schema version %v isn't supported, it's %v", migration.SchemaVersion, MigrationSchemaVersion),
+			})
+		}
+
+		for index, action := range migration.Actions {
+			err = applyActionsToSnapshot(&snapshot, []Action{action})
+			if err != nil {
+				problems = append(problems, MigrationProblem{Migration: migrationId, Action: index, Message: getProblemMessage(err)})
+			}
+		}
+	}
+
+	return problems, nil
+}
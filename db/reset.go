@@ -0,0 +1,122 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/akaumov/cubes/utils"
+)
+
+// Reset drops tables of synced migrations and syncs all migrations again in one transaction, so database has
+// only tables of migrations without data. Tables, which migrations don't create, are kept.
+func Reset(config Config) error {
+	// migrations can be changed by other process since previous sync
+	err := checkSnapshotCache()
+	if err != nil {
+		return err
+	}
+
+	syncedMigrationId, err := GetSyncedMigrationId(config)
+	if err != nil {
+		return err
+	}
+
+	tables, err := getSyncedTables(syncedMigrationId)
+	if err != nil {
+		return err
+	}
+
+	if utils.IsDryRun() {
+		return printReset(tables)
+	}
+
+	applied, failedId, err := resetMigrations(config, tables)
+	if err != nil {
+		utils.EmitEvent(utils.EventMigrationFailed, failedId, strings.TrimSpace(err.Error()))
+		return err
+	}
+
+	for _, migrationId := range applied {
+		utils.EmitEvent(utils.EventMigrationApplied, migrationId, "")
+	}
+
+	return nil
+}
+
+// getSyncedTables returns tables, which migrations up to synced one create, database without synced migrations
+// has none of them
+func getSyncedTables(syncedMigrationId string) ([]string, error) {
+	tables := []string{}
+
+	if syncedMigrationId == "" {
+		return tables, nil
+	}
+
+	snapshot, err := GetSnapshotForVersion(syncedMigrationId, -1)
+	if err != nil {
+		return nil, fmt.Errorf("can't read tables of synced migrations: %v", err)
+	}
+
+	for _, table := range snapshot.Tables {
+		tables = append(tables, table.Name)
+	}
+
+	return tables, nil
+}
+
+// dropTables drops tables with relations to them and migrations table
+func dropTables(transaction executor, tables []string) error {
+	for _, table := range tables {
+		_, err := transaction.Exec(fmt.Sprintf("DROP TABLE IF EXISTS \"%v\" CASCADE", table))
+		if err != nil {
+			return fmt.Errorf("can't delete table %v: %v", table, err)
+		}
+	}
+
+	_, err := transaction.Exec("DROP TABLE IF EXISTS _migrations")
+	if err != nil {
+		return fmt.Errorf("can't delete migrations table: %v", err)
+	}
+
+	return nil
+}
+
+// resetMigrations returns ids of applied migrations, id of failed migration is returned with error
+func resetMigrations(config Config, tables []string) ([]string, string, error) {
+	migrations, err := IterateMigrations()
+	if err != nil {
+		return nil, "", fmt.Errorf("can't read migrations: %v", err)
+	}
+
+	db, err := openDatabase(config)
+	if err != nil {
+		return nil, "", err
+	}
+
+	defer db.Close()
+
+	transaction, err := db.Begin()
+	if err != nil {
+		return nil, "", fmt.Errorf("can't start transaction: %v", err)
+	}
+
+	err = dropTables(transaction, tables)
+	if err != nil {
+		transaction.Rollback()
+		return nil, "", err
+	}
+
+	err = addMigrationsTableIfNotExist(transaction)
+	if err != nil {
+		transaction.Rollback()
+		return nil, "", fmt.Errorf("can't add migration table: %v", err)
+	}
+
+	applied, failedId, err := applyPendingMigrations(transaction, migrations, "")
+	if err != nil {
+		transaction.Rollback()
+		return nil, failedId, err
+	}
+
+	return applied, "", transaction.Commit()
+}
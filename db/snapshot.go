@@ -83,20 +83,22 @@ func GetSnapshot(actions []Action) (*Snapshot, error) {
 }
 
 func GetSnapshotWithAction(method string, params interface{}) (*Snapshot, error) {
-	pActions, err := getActions("", -1)
+	snapshot, err := GetCurrentSnapshot()
 	if err != nil {
 		return nil, err
 	}
 
-	actions := *pActions
 	packedParams, _ := json.MarshalIndent(params, "", "  ")
 
-	actions = append(actions, Action{
+	err = applyActionsToSnapshot(snapshot, []Action{{
 		Method: method,
 		Params: packedParams,
-	})
+	}})
+	if err != nil {
+		return nil, err
+	}
 
-	return GetSnapshot(actions)
+	return snapshot, nil
 }
 
 func GetCurrentSnapshot() (*Snapshot, error) {
@@ -104,13 +106,7 @@ func GetCurrentSnapshot() (*Snapshot, error) {
 }
 
 func GetSnapshotForVersion(migrationId string, actionIndex int) (*Snapshot, error) {
-
-	actions, err := getActions(migrationId, actionIndex)
-	if err != nil {
-		return nil, err
-	}
-
-	return GetSnapshot(*actions)
+	return getCachedSnapshot(migrationId, actionIndex)
 }
 
 func GetStepBackSnapshot(migrationId string, actionIndex int) (*Snapshot, error) {
@@ -0,0 +1,236 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// snapshotKey is position in migrations: snapshot contains actions of previous migrations and actions of migration
+// up to actionIndex. Empty migrationId means all actions.
+type snapshotKey struct {
+	migrationId string
+	actionIndex int
+}
+
+// snapshotCache keeps snapshots computed by process, so repeated snapshots of Sync and added actions aren't computed
+// again from the first migration. Migration files are checked once, when cache is filled after start or after
+// migrations are written by process, and by every Sync, because cubesd runs Sync after migrations are added or edited
+// by cubes. Snapshots are dropped, when any migration file is added, removed or changed.
+var snapshotCache = struct {
+	sync.Mutex
+	state     string
+	snapshots map[snapshotKey]*Snapshot
+}{}
+
+// getMigrationsState returns name, size and modification time of each migration file, so cached snapshots are
+// checked by stats of files and in-place edits, which don't change the directory, drop them too.
+func getMigrationsState() (string, error) {
+	migrationsDirectoryPath, err := GetMigrationsDirectoryPath()
+	if err != nil {
+		return "", err
+	}
+
+	paths, err := filepath.Glob(filepath.Join(migrationsDirectoryPath, "*.json"))
+	if err != nil {
+		return "", fmt.Errorf("can't read migrations directory: %v", err)
+	}
+
+	sort.Strings(paths)
+
+	var state bytes.Buffer
+	state.WriteString(migrationsDirectoryPath)
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return "", fmt.Errorf("can't read migration: %v", err)
+		}
+
+		fmt.Fprintf(&state, "\n%v:%v:%v", filepath.Base(path), info.Size(), info.ModTime().UnixNano())
+	}
+
+	return state.String(), nil
+}
+
+// resetSnapshotCache drops snapshots after migrations are written by process
+func resetSnapshotCache() {
+	snapshotCache.Lock()
+	defer snapshotCache.Unlock()
+
+	snapshotCache.state = ""
+	snapshotCache.snapshots = nil
+}
+
+// checkSnapshotCache drops cached snapshots, when migration files are changed since cache is filled
+func checkSnapshotCache() error {
+	snapshotCache.Lock()
+	defer snapshotCache.Unlock()
+
+	return checkSnapshotCacheLocked()
+}
+
+// checkSnapshotCacheLocked is checkSnapshotCache for caller, which locks cache
+func checkSnapshotCacheLocked() error {
+	state, err := getMigrationsState()
+	if err != nil {
+		return err
+	}
+
+	if snapshotCache.snapshots == nil || snapshotCache.state != state {
+		snapshotCache.state = state
+		snapshotCache.snapshots = map[snapshotKey]*Snapshot{}
+	}
+
+	return nil
+}
+
+func copyStrings(values []string) []string {
+	if values == nil {
+		return nil
+	}
+
+	return append(make([]string, 0, len(values)), values...)
+}
+
+func copyTable(table Table) Table {
+	result := table
+
+	if table.Columns != nil {
+		result.Columns = append(make([]Column, 0, len(table.Columns)), table.Columns...)
+	}
+
+	if table.PrimaryKeys != nil {
+		result.PrimaryKeys = append(make([]ColumnName, 0, len(table.PrimaryKeys)), table.PrimaryKeys...)
+	}
+
+	if table.Relations != nil {
+		result.Relations = make([]Relation, len(table.Relations))
+
+		for index, relation := range table.Relations {
+			result.Relations[index] = relation
+
+			if relation.ColumnsMapping != nil {
+				result.Relations[index].ColumnsMapping = append(make([]ColumnsMap, 0, len(relation.ColumnsMapping)), relation.ColumnsMapping...)
+			}
+		}
+	}
+
+	if table.UniqueConstraints != nil {
+		result.UniqueConstraints = make([]UniqueConstraint, len(table.UniqueConstraints))
+
+		for index, constraint := range table.UniqueConstraints {
+			result.UniqueConstraints[index] = constraint
+			result.UniqueConstraints[index].Columns = copyStrings(constraint.Columns)
+		}
+	}
+
+	return result
+}
+
+// copySnapshot returns snapshot, which doesn't share tables with cached one, so callers can change it
+func copySnapshot(snapshot *Snapshot) *Snapshot {
+	result := Snapshot{}
+
+	if snapshot.Tables != nil {
+		result.Tables = make([]Table, len(snapshot.Tables))
+
+		for index, table := range snapshot.Tables {
+			result.Tables[index] = copyTable(table)
+		}
+	}
+
+	return &result
+}
+
+// getCachedSnapshot returns snapshot for position in migrations, it's computed only once, while migrations aren't changed.
+// Missing snapshot is computed from the nearest cached snapshot before position.
+func getCachedSnapshot(migrationId string, actionIndex int) (*Snapshot, error) {
+	snapshotCache.Lock()
+	defer snapshotCache.Unlock()
+
+	if snapshotCache.snapshots == nil {
+		err := checkSnapshotCacheLocked()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	key := snapshotKey{
+		migrationId: migrationId,
+		actionIndex: actionIndex,
+	}
+
+	snapshot, ok := snapshotCache.snapshots[key]
+	if !ok {
+		var err error
+
+		snapshot, err = computeSnapshot(migrationId, actionIndex)
+		if err != nil {
+			return nil, err
+		}
+
+		snapshotCache.snapshots[key] = snapshot
+	}
+
+	return copySnapshot(snapshot), nil
+}
+
+// computeSnapshot applies actions up to position as getActions selects them, cache is locked by caller
+func computeSnapshot(migrationId string, actionIndex int) (*Snapshot, error) {
+
+	migrations, err := IterateMigrations()
+	if err != nil {
+		return nil, fmt.Errorf("can't read migrations: %v", err)
+	}
+
+	base := &Snapshot{
+		Tables: []Table{},
+	}
+
+	actions := []Action{}
+
+	for migrations.Next() {
+		migration, err := migrations.Migration()
+		if err != nil {
+			return nil, fmt.Errorf("can't read migrations: %v", err)
+		}
+
+		for index, action := range migration.Actions {
+			actions = append(actions, action)
+
+			cachedSnapshot, ok := snapshotCache.snapshots[snapshotKey{migrationId: migration.Id, actionIndex: index}]
+			if ok {
+				base = cachedSnapshot
+				actions = []Action{}
+			}
+
+			if migrationId != "" &&
+				migration.Id == migrationId &&
+				actionIndex >= 0 &&
+				index >= actionIndex {
+				break
+			}
+		}
+
+		if migrationId != "" && migration.Id == migrationId {
+			break
+		}
+	}
+
+	snapshot := copySnapshot(base)
+
+	err = applyActionsToSnapshot(snapshot, actions)
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
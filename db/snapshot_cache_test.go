@@ -0,0 +1,271 @@
+package db
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/akaumov/cubes/utils"
+)
+
+// useMigrationsDirectory points migrations to temporary directory and drops cached snapshots of other tests,
+// returned func restores them
+func useMigrationsDirectory(t *testing.T) (string, func()) {
+	directory, err := ioutil.TempDir("", "cubes_migrations_")
+	if err != nil {
+		t.Fatalf("can't create migrations directory: %v", err)
+	}
+
+	previousPath, isSet := os.LookupEnv(utils.EnvMigrationsPath)
+	os.Setenv(utils.EnvMigrationsPath, directory)
+	resetSnapshotCache()
+
+	return directory, func() {
+		if isSet {
+			os.Setenv(utils.EnvMigrationsPath, previousPath)
+		} else {
+			os.Unsetenv(utils.EnvMigrationsPath)
+		}
+
+		resetSnapshotCache()
+		os.RemoveAll(directory)
+	}
+}
+
+func writeTestMigration(t *testing.T, directory string, id string, tables ...string) {
+	migration := Migration{
+		SchemaVersion: MigrationSchemaVersion,
+		Id:            id,
+		Actions:       []Action{},
+	}
+
+	for _, table := range tables {
+		params, _ := json.Marshal(AddTableParams{Name: table})
+		migration.Actions = append(migration.Actions, Action{
+			Method: "addTable",
+			Params: params,
+		})
+	}
+
+	packedMigration, _ := json.Marshal(migration)
+
+	err := ioutil.WriteFile(filepath.Join(directory, id+".json"), packedMigration, 0644)
+	if err != nil {
+		t.Fatalf("can't write migration: %v", err)
+	}
+}
+
+func getTableNames(snapshot *Snapshot) []string {
+	names := []string{}
+	for _, table := range snapshot.Tables {
+		names = append(names, table.Name)
+	}
+
+	return names
+}
+
+func checkTables(t *testing.T, snapshot *Snapshot, expected ...string) {
+	t.Helper()
+
+	names := getTableNames(snapshot)
+	if len(names) != len(expected) {
+		t.Fatalf("expected tables %v, got %v", expected, names)
+	}
+
+	for index := range expected {
+		if names[index] != expected[index] {
+			t.Fatalf("expected tables %v, got %v", expected, names)
+		}
+	}
+}
+
+func TestSnapshotCacheHit(t *testing.T) {
+	directory, restore := useMigrationsDirectory(t)
+	defer restore()
+
+	writeTestMigration(t, directory, "20200101000000", "users")
+
+	snapshot, err := GetCurrentSnapshot()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	checkTables(t, snapshot, "users")
+
+	// cached snapshot is returned without reading migrations again
+	snapshotCache.snapshots[snapshotKey{migrationId: "", actionIndex: -1}] = &Snapshot{
+		Tables: []Table{{Name: "cached"}},
+	}
+
+	snapshot, err = GetCurrentSnapshot()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	checkTables(t, snapshot, "cached")
+}
+
+func TestSnapshotCacheReturnsCopies(t *testing.T) {
+	directory, restore := useMigrationsDirectory(t)
+	defer restore()
+
+	writeTestMigration(t, directory, "20200101000000", "users")
+
+	snapshot, err := GetCurrentSnapshot()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	snapshot.Tables[0].Name = "changed"
+	snapshot.Tables = append(snapshot.Tables, Table{Name: "added"})
+
+	snapshot, err = GetCurrentSnapshot()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	checkTables(t, snapshot, "users")
+}
+
+func TestSnapshotCacheMissStartsFromNearestCached(t *testing.T) {
+	directory, restore := useMigrationsDirectory(t)
+	defer restore()
+
+	writeTestMigration(t, directory, "20200101000000", "users", "posts", "comments")
+
+	_, err := GetSnapshotForVersion("20200101000000", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// missing snapshot applies the rest of actions to the nearest cached one
+	snapshotCache.snapshots[snapshotKey{migrationId: "20200101000000", actionIndex: 0}] = &Snapshot{
+		Tables: []Table{{Name: "cached"}},
+	}
+
+	snapshot, err := GetSnapshotForVersion("20200101000000", 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	checkTables(t, snapshot, "cached", "posts", "comments")
+
+	snapshot, err = GetSnapshotForVersion("20200101000000", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	checkTables(t, snapshot, "cached", "posts")
+}
+
+func TestSnapshotCacheIsDroppedAfterNewMigration(t *testing.T) {
+	directory, restore := useMigrationsDirectory(t)
+	defer restore()
+
+	writeTestMigration(t, directory, "20200101000000", "users")
+
+	snapshot, err := GetCurrentSnapshot()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	checkTables(t, snapshot, "users")
+
+	// file of other process changes migrations directory
+	writeTestMigration(t, directory, "20200102000000", "posts")
+
+	// lookups are served from memory until cache is checked by sync
+	snapshot, err = GetCurrentSnapshot()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	checkTables(t, snapshot, "users")
+
+	err = checkSnapshotCache()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	snapshot, err = GetCurrentSnapshot()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	checkTables(t, snapshot, "users", "posts")
+}
+
+func TestSnapshotCacheIsDroppedAfterActionIsAdded(t *testing.T) {
+	directory, restore := useMigrationsDirectory(t)
+	defer restore()
+
+	writeTestMigration(t, directory, "20200101000000", "users")
+
+	_, err := GetCurrentSnapshot()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = AddTable("posts")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	snapshot, err := GetCurrentSnapshot()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	checkTables(t, snapshot, "users", "posts")
+
+	// migration file is rewritten, so state is changed for other processes too
+	state, err := getMigrationsState()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = AddTable("comments")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	newState, err := getMigrationsState()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if newState == state {
+		t.Fatalf("migrations state isn't changed by added action")
+	}
+}
+
+func TestSnapshotCacheIsDroppedAfterMigrationIsEditedInPlace(t *testing.T) {
+	directory, restore := useMigrationsDirectory(t)
+	defer restore()
+
+	writeTestMigration(t, directory, "20200101000000", "users")
+
+	snapshot, err := GetCurrentSnapshot()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	checkTables(t, snapshot, "users")
+
+	// editor writes file in place, directory isn't changed
+	writeTestMigration(t, directory, "20200101000000", "accounts")
+
+	err = checkSnapshotCache()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	snapshot, err = GetCurrentSnapshot()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	checkTables(t, snapshot, "accounts")
+}
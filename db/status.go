@@ -0,0 +1,77 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// undefinedTableCode is postgres error of missing table, database without synced migrations has no _migrations
+const undefinedTableCode = "42P01"
+
+type MigrationStatus struct {
+	Id          string `json:"id"`
+	Description string `json:"description"`
+	IsSynced    bool   `json:"isSynced"`
+}
+
+type MigrationsStatus struct {
+	SyncedId   string            `json:"syncedId"`
+	Migrations []MigrationStatus `json:"migrations"`
+
+	// DatabaseError is set when database can't be read, synced migrations are unknown then
+	DatabaseError string `json:"databaseError,omitempty"`
+}
+
+// GetSyncedMigrationId returns id of the latest migration synced to database, it's empty when nothing is synced
+func GetSyncedMigrationId(config Config) (string, error) {
+	db, err := openDatabase(config)
+	if err != nil {
+		return "", err
+	}
+
+	defer db.Close()
+
+	var migrationId string
+
+	err = db.QueryRow("SELECT id FROM _migrations ORDER BY id DESC LIMIT 1").Scan(&migrationId)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+
+	if pqError, ok := err.(*pq.Error); ok && pqError.Code == undefinedTableCode {
+		return "", nil
+	}
+
+	return migrationId, err
+}
+
+// GetMigrationsStatus returns migrations of project and whether they're synced to database,
+// project without database gets migrations only
+func GetMigrationsStatus(config *Config) (*MigrationsStatus, error) {
+	migrations, err := GetList()
+	if err != nil {
+		return nil, err
+	}
+
+	status := MigrationsStatus{
+		Migrations: []MigrationStatus{},
+	}
+
+	if config != nil {
+		status.SyncedId, err = GetSyncedMigrationId(*config)
+		if err != nil {
+			status.DatabaseError = err.Error()
+		}
+	}
+
+	for _, migration := range *migrations {
+		status.Migrations = append(status.Migrations, MigrationStatus{
+			Id:          migration.Id,
+			Description: migration.Description,
+			IsSynced:    status.SyncedId != "" && migration.Id <= status.SyncedId,
+		})
+	}
+
+	return &status, nil
+}
@@ -4,12 +4,19 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"log"
+	"os"
 	"strconv"
 	"strings"
+
+	"github.com/akaumov/cubes/utils"
 )
 
-func applyAddTable(transaction *sql.Tx, params AddTableParams) error {
+// executor runs statements of migrations, it's transaction of sync or sqlPrinter in dry run
+type executor interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func applyAddTable(transaction executor, params AddTableParams) error {
 
 	if strings.TrimSpace(params.Name) == "" {
 		return fmt.Errorf("table is required")
@@ -24,7 +31,7 @@ func applyAddTable(transaction *sql.Tx, params AddTableParams) error {
 	return nil
 }
 
-func applyDeleteTable(transaction *sql.Tx, params DeleteTableParams) error {
+func applyDeleteTable(transaction executor, params DeleteTableParams) error {
 
 	if strings.TrimSpace(params.Name) == "" {
 		return fmt.Errorf("table is required")
@@ -40,7 +47,7 @@ func applyDeleteTable(transaction *sql.Tx, params DeleteTableParams) error {
 	return nil
 }
 
-func applyAddColumn(transaction *sql.Tx, params AddColumnParams) error {
+func applyAddColumn(transaction executor, params AddColumnParams) error {
 
 	if strings.TrimSpace(params.Table) == "" {
 		return fmt.Errorf("table is required")
@@ -74,7 +81,7 @@ func applyAddColumn(transaction *sql.Tx, params AddColumnParams) error {
 	return nil
 }
 
-func applyDeleteColumn(transaction *sql.Tx, params DeleteColumnParams) error {
+func applyDeleteColumn(transaction executor, params DeleteColumnParams) error {
 
 	query := fmt.Sprintf(`
 		ALTER TABLE "%v"
@@ -89,7 +96,7 @@ func applyDeleteColumn(transaction *sql.Tx, params DeleteColumnParams) error {
 	return nil
 }
 
-func applyAddPrimaryKey(transaction *sql.Tx, migrationId string, actionIndex int, params AddPrimaryKeyParams) error {
+func applyAddPrimaryKey(transaction executor, migrationId string, actionIndex int, params AddPrimaryKeyParams) error {
 
 	snapshot, err := GetSnapshotForVersion(migrationId, actionIndex)
 	if err != nil {
@@ -143,7 +150,7 @@ func applyAddPrimaryKey(transaction *sql.Tx, migrationId string, actionIndex int
 	return nil
 }
 
-func applyDeletePrimaryKey(transaction *sql.Tx, migrationId string, actionIndex int, params DeletePrimaryKeyParams) error {
+func applyDeletePrimaryKey(transaction executor, migrationId string, actionIndex int, params DeletePrimaryKeyParams) error {
 
 	constraintName := params.Table + "_pkey"
 
@@ -194,7 +201,7 @@ func applyDeletePrimaryKey(transaction *sql.Tx, migrationId string, actionIndex
 	return nil
 }
 
-func applyAddRelation(transaction *sql.Tx, params AddRelationParams) error {
+func applyAddRelation(transaction executor, params AddRelationParams) error {
 
 	columns := ""
 	remoteColumns := ""
@@ -225,7 +232,7 @@ func applyAddRelation(transaction *sql.Tx, params AddRelationParams) error {
 	return nil
 }
 
-func applyAddUniqueConstraint(transaction *sql.Tx, params AddUniqueConstraintParams) error {
+func applyAddUniqueConstraint(transaction executor, params AddUniqueConstraintParams) error {
 
 	columns := ""
 
@@ -250,7 +257,7 @@ func applyAddUniqueConstraint(transaction *sql.Tx, params AddUniqueConstraintPar
 	return nil
 }
 
-func applyDeleteRelation(transaction *sql.Tx, params DeleteRelationParams) error {
+func applyDeleteRelation(transaction executor, params DeleteRelationParams) error {
 
 	query := fmt.Sprintf(`
 		ALTER TABLE "%v"
@@ -265,7 +272,7 @@ func applyDeleteRelation(transaction *sql.Tx, params DeleteRelationParams) error
 	return nil
 }
 
-func applyDeleteUniqueConstraint(transaction *sql.Tx, params DeleteUniqueConstraintParams) error {
+func applyDeleteUniqueConstraint(transaction executor, params DeleteUniqueConstraintParams) error {
 
 	query := fmt.Sprintf(`
 		ALTER TABLE "%v"
@@ -280,82 +287,148 @@ func applyDeleteUniqueConstraint(transaction *sql.Tx, params DeleteUniqueConstra
 	return nil
 }
 
-func Sync() error {
-
-	migrations, err := GetList()
+// openDatabase connects to database of config with resolved secrets and pool settings, connection is checked by ping
+func openDatabase(config Config) (*sql.DB, error) {
+	err := checkConfig(config)
 	if err != nil {
-		return fmt.Errorf("can't read migrations: %v\n", err)
+		return nil, err
 	}
 
-	dbConnectionString := fmt.Sprintf("user=%v password=%v dbname=%v host=%v port=%v sslmode=disable",
-		"admin",
-		"123456",
-		"timeio",
-		"localhost",
-		5432)
+	config, err = resolveSecrets(config)
+	if err != nil {
+		return nil, err
+	}
 
-	db, err := sql.Open("postgres", dbConnectionString)
+	db, err := sql.Open("postgres", getConnectionString(config))
 	if err != nil {
-		return fmt.Errorf("can't connect to db: %v", err)
+		return nil, fmt.Errorf("can't connect to db: %v", err)
 	}
-	defer func() { db.Close() }()
+
+	setPoolSettings(db, config)
 
 	err = db.Ping()
 	if err != nil {
-		return fmt.Errorf("can't connect to db: %v", err)
+		db.Close()
+		return nil, fmt.Errorf("can't connect to db: %v", err)
+	}
+
+	return db, nil
+}
+
+// Ping checks that database is reachable with config
+func Ping(config Config) error {
+	db, err := openDatabase(config)
+	if err != nil {
+		return err
+	}
+
+	return db.Close()
+}
+
+// Sync applies migrations, which aren't synced to database yet, in one transaction and emits events of them
+func Sync(config Config) error {
+	// migrations can be changed by other process since previous sync
+	err := checkSnapshotCache()
+	if err != nil {
+		return err
+	}
+
+	if utils.IsDryRun() {
+		return printSync(config)
+	}
+
+	applied, failedId, err := syncMigrations(config)
+	if err != nil {
+		utils.EmitEvent(utils.EventMigrationFailed, failedId, strings.TrimSpace(err.Error()))
+		return err
+	}
+
+	for _, migrationId := range applied {
+		utils.EmitEvent(utils.EventMigrationApplied, migrationId, "")
+	}
+
+	return nil
+}
+
+// syncMigrations returns ids of applied migrations, id of failed migration is returned with error
+func syncMigrations(config Config) ([]string, string, error) {
+
+	migrations, err := IterateMigrations()
+	if err != nil {
+		return nil, "", fmt.Errorf("can't read migrations: %v\n", err)
+	}
+
+	db, err := openDatabase(config)
+	if err != nil {
+		return nil, "", err
 	}
+	defer func() { db.Close() }()
 
-	log.Println("Connected to db")
+	utils.Debugf("Connected to db")
 	transaction, err := db.Begin()
 	if err != nil {
 		transaction.Rollback()
-		return fmt.Errorf("can't start transaction: %v", err)
+		return nil, "", fmt.Errorf("can't start transaction: %v", err)
 	}
 
 	err = addMigrationsTableIfNotExist(transaction)
 	if err != nil {
 		transaction.Rollback()
-		return fmt.Errorf("can't add migration table: %v", err)
+		return nil, "", fmt.Errorf("can't add migration table: %v", err)
 	}
 
 	currentMigrationId, err := getCurrentSyncedMigrationId(transaction)
 	if err != nil {
 		transaction.Rollback()
-		return fmt.Errorf("can't read current migration state: %v", err)
+		return nil, "", fmt.Errorf("can't read current migration state: %v", err)
 	}
 
-	_, err = GetCurrentSnapshot()
+	applied, failedId, err := applyPendingMigrations(transaction, migrations, currentMigrationId)
 	if err != nil {
-		return err
+		transaction.Rollback()
+		return nil, failedId, err
 	}
 
+	return applied, "", transaction.Commit()
+}
+
+// applyPendingMigrations applies migrations after current synced one and records them to migrations table.
+// Synced migrations are skipped by ids of their files and aren't parsed.
+func applyPendingMigrations(transaction executor, migrations *MigrationIterator, currentMigrationId string) ([]string, string, error) {
+
 	isCurrentMigrationPassed := currentMigrationId == ""
 
-	for _, migration := range *migrations {
+	for !isCurrentMigrationPassed && migrations.Next() {
+		isCurrentMigrationPassed = migrations.Id() == currentMigrationId
+	}
 
-		if migration.Id == currentMigrationId {
-			isCurrentMigrationPassed = true
-			continue
-		}
+	progress := utils.StartProgress("Syncing migrations", migrations.Remaining())
+	defer progress.Done()
 
-		if !isCurrentMigrationPassed {
-			continue
+	applied := []string{}
+
+	for migrations.Next() {
+		progress.Step(migrations.Id())
+
+		migration, err := migrations.Migration()
+		if err != nil {
+			return nil, migrations.Id(), err
 		}
 
-		err = applyMigrationActions(transaction, migration)
+		err = applyMigrationActions(transaction, *migration)
 		if err != nil {
-			transaction.Rollback()
-			return fmt.Errorf("can't apply migration %v: %v\n", migration.Id, err)
+			return nil, migration.Id, fmt.Errorf("can't apply migration %v: %v\n", migration.Id, err)
 		}
 
-		addMigrationToMigrationsTable(transaction, migration)
+		applied = append(applied, migration.Id)
+
+		err = addMigrationToMigrationsTable(transaction, *migration)
 		if err != nil {
-			transaction.Rollback()
-			return fmt.Errorf("can't add migration to migrations table %v: %v\n", migration.Id, err)
+			return nil, migration.Id, fmt.Errorf("can't add migration to migrations table %v: %v\n", migration.Id, err)
 		}
 	}
 
-	return transaction.Commit()
+	return applied, "", nil
 }
 
 func getCurrentSyncedMigrationId(transaction *sql.Tx) (string, error) {
@@ -371,9 +444,10 @@ func getCurrentSyncedMigrationId(transaction *sql.Tx) (string, error) {
 	return migrationId, err
 }
 
-func applyMigrationActions(transaction *sql.Tx, migration Migration) error {
+func applyMigrationActions(transaction executor, migration Migration) error {
+	output := utils.AboveProgress(os.Stdout)
 
-	fmt.Println(migration.Id)
+	fmt.Fprintln(output, migration.Id)
 
 	for index, action := range migration.Actions {
 
@@ -418,14 +492,16 @@ func applyMigrationActions(transaction *sql.Tx, migration Migration) error {
 		}
 
 		if err != nil {
-			fmt.Println("#"+strconv.Itoa(index), method, "error")
+			fmt.Fprintln(output, "#"+strconv.Itoa(index), method, "error")
 			return fmt.Errorf("can't apply action #%v=\"%v\": %v\n", index, method, err)
+		} else if _, isDryRun := transaction.(sqlPrinter); isDryRun {
+			fmt.Fprintln(output, "#"+strconv.Itoa(index), method, "planned", "")
 		} else {
-			fmt.Println("#"+strconv.Itoa(index), method, "success", "")
+			fmt.Fprintln(output, "#"+strconv.Itoa(index), method, "success", "")
 		}
 	}
 
-	fmt.Println()
+	fmt.Fprintln(output)
 
 	return nil
 }
@@ -528,7 +604,7 @@ func decodeAction(method string, params json.RawMessage) (string, interface{}, e
 	return "", nil, nil
 }
 
-func addMigrationsTableIfNotExist(transaction *sql.Tx) error {
+func addMigrationsTableIfNotExist(transaction executor) error {
 	_, err := transaction.Exec(`
 		CREATE TABLE IF NOT EXISTS _migrations (
         	id varchar(255) NOT NULL,
@@ -539,7 +615,7 @@ func addMigrationsTableIfNotExist(transaction *sql.Tx) error {
 	return err
 }
 
-func addMigrationToMigrationsTable(transaction *sql.Tx, migration Migration) error {
+func addMigrationToMigrationsTable(transaction executor, migration Migration) error {
 	packedMigration, _ := json.Marshal(migration)
 	_, err := transaction.Exec("INSERT INTO _migrations (id, data) VALUES ($1, $2)", migration.Id, packedMigration)
 	return err
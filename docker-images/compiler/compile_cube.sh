@@ -1,43 +1,50 @@
 #!/usr/bin/env bash
 set -e
-cd src
 
-echo "Current dir: $PWD"
-ls -l
+CUBES_PATH=$GOPATH/src/github.com/akaumov/cubes
 
-echo "Cloning cube executor from git..."
-git clone https://github.com/akaumov/cube_executor.git cube_executor
+echo "Cloning cubes from git..."
+git clone --depth 1 https://github.com/akaumov/cubes.git $CUBES_PATH
 
-echo "Current dir: $PWD"
-ls -l
+# built-in cubes are in cubes already
+if [[ $CUBE_PACKAGE != github.com/akaumov/cubes/* ]]; then
+    echo "Cloning cube handler from git..."
+    go get -d $CUBE_PACKAGE
 
-cd cube_executor
-sed -i "s|github.com/akaumov/cube-stub|$CUBE_PACKAGE|g" cube.go
+    # handler is built with vendored packages of cubes, so it's passed to executor as the same cube interface
+    mkdir -p $CUBES_PATH/vendor/$CUBE_PACKAGE
+    cp -r $GOPATH/src/$CUBE_PACKAGE/. $CUBES_PATH/vendor/$CUBE_PACKAGE
+    rm -rf $CUBES_PATH/vendor/$CUBE_PACKAGE/vendor/github.com/akaumov/cube
+fi
 
-echo "Current dir: $PWD"
-ls -l
-echo "Cloning cube handler from git..."
-dep ensure
+echo "Generating main..."
+mkdir -p $CUBES_PATH/build/cube
+cat > $CUBES_PATH/build/cube/main.go <<MAIN
+package main
+
+import (
+	"log"
+
+	"github.com/akaumov/cubes/executor"
+	handler "$CUBE_PACKAGE"
+)
+
+func main() {
+	err := executor.Run(executor.ConfigPath, &handler.Handler{})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+MAIN
 
-echo "Current dir: $PWD"
-ls -l
 echo "Compiling code..."
-go build -x -v  ./cmd/cube
+cd $CUBES_PATH/build/cube
+CGO_ENABLED=0 go build -v -o cube .
 
-echo "Current dir: $PWD"
 chmod u=rx,g=rx,o=rx cube
-ls -l
-echo "Making tar..."
-tar -cvf cube.tar cube
-
-echo "Current dir: $PWD"
-ls -l
-echo "Moving..."
-mv cube.tar /build/cube.tar
 
+echo "Making tar..."
+tar -cvf /build/cube.tar cube
 chmod u=rw,g=rw,o=rw /build/cube.tar
 
-echo "Moved"
-cd /build
-echo "Current dir: $PWD"
-ls -l
\ No newline at end of file
+echo "Done"
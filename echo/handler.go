@@ -0,0 +1,33 @@
+package echo
+
+import (
+	"github.com/akaumov/cube"
+)
+
+const Version = "1"
+
+// RequestsChannel is channel of requests, which are answered with their params
+const RequestsChannel = "requests"
+
+// Handler is cube which answers requests with their params, it's used to measure bus latency
+type Handler struct{}
+
+func (h *Handler) OnInitInstance() []cube.InputChannel {
+	return []cube.InputChannel{RequestsChannel}
+}
+
+func (h *Handler) OnStart(instance cube.Cube) {
+}
+
+func (h *Handler) OnStop(instance cube.Cube) {
+}
+
+func (h *Handler) OnReceiveMessage(instance cube.Cube, channel cube.Channel, message cube.Message) {
+}
+
+func (h *Handler) OnReceiveRequest(instance cube.Cube, channel cube.Channel, request cube.Request) (*cube.Response, error) {
+	return &cube.Response{
+		Version: Version,
+		Result:  request.Params,
+	}, nil
+}
@@ -0,0 +1,237 @@
+package executor
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// defaultBusURL is address of bus in project network, instances started by older cubes don't get CUBE_BUS_URL
+const defaultBusURL = "nats://cubes-bus:4444"
+
+// reconnectBackoff is wait between connects to bus: it doubles from wait after each failed attempt up to maxWait,
+// executor gives up after maxAttempts, it retries forever when maxAttempts is 0
+type reconnectBackoff struct {
+	wait        time.Duration
+	maxWait     time.Duration
+	maxAttempts int
+}
+
+// defaultReconnectBackoff is used when instance has no bus reconnect in its config
+var defaultReconnectBackoff = reconnectBackoff{
+	wait:    2 * time.Second,
+	maxWait: 30 * time.Second,
+}
+
+func getEnvInt(key string) (int, error) {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("wrong %v %v, it must be number, which isn't negative", key, os.Getenv(key))
+	}
+
+	return value, nil
+}
+
+// getReconnectBackoff returns backoff, which cubes passes to instance in CUBE_BUS_RECONNECT_* variables
+func getReconnectBackoff() (*reconnectBackoff, error) {
+	if os.Getenv("CUBE_BUS_RECONNECT_WAIT_MS") == "" {
+		backoff := defaultReconnectBackoff
+		return &backoff, nil
+	}
+
+	wait, err := getEnvInt("CUBE_BUS_RECONNECT_WAIT_MS")
+	if err != nil {
+		return nil, err
+	}
+
+	maxWait, err := getEnvInt("CUBE_BUS_RECONNECT_MAX_WAIT_MS")
+	if err != nil {
+		return nil, err
+	}
+
+	maxAttempts, err := getEnvInt("CUBE_BUS_RECONNECT_MAX_ATTEMPTS")
+	if err != nil {
+		return nil, err
+	}
+
+	return &reconnectBackoff{
+		wait:        time.Duration(wait) * time.Millisecond,
+		maxWait:     time.Duration(maxWait) * time.Millisecond,
+		maxAttempts: maxAttempts,
+	}, nil
+}
+
+// getDelay returns wait before attempt, attempts are counted from 1
+func (b reconnectBackoff) getDelay(attempt int) time.Duration {
+	delay := b.wait
+	for i := 1; i < attempt && delay < b.maxWait; i++ {
+		delay *= 2
+	}
+
+	if delay > b.maxWait {
+		return b.maxWait
+	}
+
+	return delay
+}
+
+// isExhausted returns true when executor doesn't try to connect after attempts
+func (b reconnectBackoff) isExhausted(attempts int) bool {
+	return b.maxAttempts > 0 && attempts >= b.maxAttempts
+}
+
+// getBusURL returns address of bus, which cubes passes to instance
+func getBusURL() string {
+	url := os.Getenv("CUBE_BUS_URL")
+	if url == "" {
+		return defaultBusURL
+	}
+
+	return url
+}
+
+// getBusTLSConfig returns TLS config from certificates, which cubes mounts to instance when bus TLS is enabled,
+// it's nil when bus TLS isn't enabled
+func getBusTLSConfig() (*tls.Config, error) {
+	if os.Getenv("CUBE_BUS_TLS") != "true" {
+		return nil, nil
+	}
+
+	caPath := os.Getenv("CUBE_BUS_TLS_CA")
+	if caPath == "" {
+		// without CA there is nothing to verify bus certificate with, bus is in project network anyway
+		return &tls.Config{InsecureSkipVerify: true}, nil
+	}
+
+	rawCA, err := ioutil.ReadFile(caPath)
+	if err != nil {
+		return nil, fmt.Errorf("can't read bus CA: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(rawCA) {
+		return nil, fmt.Errorf("can't parse bus CA")
+	}
+
+	certificate, err := tls.LoadX509KeyPair(os.Getenv("CUBE_BUS_TLS_CERT"), os.Getenv("CUBE_BUS_TLS_KEY"))
+	if err != nil {
+		return nil, fmt.Errorf("can't read bus client certificate: %v", err)
+	}
+
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		RootCAs:      pool,
+		Certificates: []tls.Certificate{certificate},
+	}, nil
+}
+
+// getBusOptions returns options of bus connection from environment, which cubes passes to instance
+func (c *Cube) getBusOptions(backoff reconnectBackoff) ([]nats.Option, error) {
+	maxReconnects := backoff.maxAttempts
+	if maxReconnects == 0 {
+		maxReconnects = -1
+	}
+
+	options := []nats.Option{
+		nats.Name(c.config.Name),
+		nats.MaxReconnects(maxReconnects),
+		nats.CustomReconnectDelay(backoff.getDelay),
+		nats.DisconnectErrHandler(c.onBusDisconnect),
+		nats.ReconnectHandler(c.onBusReconnect),
+		nats.ClosedHandler(c.onBusClose),
+	}
+
+	tlsConfig, err := getBusTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if tlsConfig != nil {
+		options = append(options, nats.Secure(tlsConfig))
+	}
+
+	// cubes passes credentials when instance has them, bus without auth accepts them too
+	user := os.Getenv("CUBE_BUS_USER")
+	if user != "" {
+		options = append(options, nats.UserInfo(user, os.Getenv("CUBE_BUS_PASSWORD")))
+	}
+
+	return options, nil
+}
+
+// connectBus connects instance to bus, connection is named by instance, so bus reports it by its name. Instance
+// could be started before bus, so first connect is retried with the same backoff as reconnects.
+func (c *Cube) connectBus() error {
+	url := getBusURL()
+
+	backoff, err := getReconnectBackoff()
+	if err != nil {
+		return err
+	}
+
+	options, err := c.getBusOptions(*backoff)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 1; ; attempt++ {
+		connection, err := nats.Connect(url, options...)
+		if err == nil {
+			c.connection = connection
+			return nil
+		}
+
+		if backoff.isExhausted(attempt) {
+			return fmt.Errorf("can't connect to bus %v after %v attempts: %v", url, attempt, err)
+		}
+
+		delay := backoff.getDelay(attempt)
+		log.Printf("Can't connect to bus %v, next attempt in %v: %v", url, delay, err)
+
+		select {
+		case <-time.After(delay):
+		case <-c.stopped:
+			return fmt.Errorf("instance is stopped before it's connected to bus")
+		}
+	}
+}
+
+// onBusDisconnect logs lost connection, messages aren't received until executor reconnects
+func (c *Cube) onBusDisconnect(connection *nats.Conn, err error) {
+	if err == nil {
+		return
+	}
+
+	log.Printf("Disconnected from bus, reconnecting: %v", err)
+}
+
+// onBusReconnect logs reconnect to instance output and to log channel, so it's seen by bus subscribers of logs too.
+// Bus could be restarted by other cubes meanwhile, so protocol is exchanged again.
+func (c *Cube) onBusReconnect(connection *nats.Conn) {
+	c.LogWarning("reconnected to bus " + connection.ConnectedUrl() + ", messages published while it was disconnected were missed")
+
+	// callbacks of connection are called one by one, so other events aren't held while protocol reply is waited
+	go func() {
+		err := c.exchangeProtocol()
+		if err != nil {
+			c.fail(err)
+		}
+	}()
+}
+
+// onBusClose stops instance, when executor gave up reconnecting, instance exits then and its restart policy applies
+func (c *Cube) onBusClose(connection *nats.Conn) {
+	err := connection.LastError()
+	if err == nil {
+		err = fmt.Errorf("connection is closed")
+	}
+
+	c.fail(fmt.Errorf("bus connection is lost: %v", err))
+}
@@ -0,0 +1,106 @@
+package executor
+
+import (
+	"fmt"
+
+	"github.com/akaumov/cube"
+	"github.com/nats-io/nats.go"
+)
+
+// getBusInputChannels returns bus channels of cube's input channels by current channels mapping
+func (c *Cube) getBusInputChannels() map[string]bool {
+	busChannels := map[string]bool{}
+	for _, inputChannel := range c.inputChannels {
+		busChannels[c.mapToBusChannel(cube.Channel(inputChannel))] = true
+	}
+
+	return busChannels
+}
+
+func (c *Cube) subscribeBusChannel(busChannel string) (*nats.Subscription, error) {
+	if c.config.QueueGroup != "" {
+		return c.connection.QueueSubscribe(busChannel, c.config.QueueGroup, c.handleBusMessage)
+	}
+
+	return c.connection.Subscribe(busChannel, c.handleBusMessage)
+}
+
+// updateSubscriptions subscribes bus channels of current channels mapping, which aren't subscribed yet, and drops
+// subscriptions of channels, which aren't mapped anymore. Channels, which are kept in mapping, keep their
+// subscriptions, so their messages aren't missed or received twice.
+func (c *Cube) updateSubscriptions() error {
+	busChannels := c.getBusInputChannels()
+
+	for busChannel := range busChannels {
+		if _, ok := c.subscriptions[busChannel]; ok {
+			continue
+		}
+
+		subscription, err := c.subscribeBusChannel(busChannel)
+		if err != nil {
+			return fmt.Errorf("can't subscribe to %v: %v", busChannel, err)
+		}
+
+		c.subscriptions[busChannel] = subscription
+	}
+
+	for busChannel, subscription := range c.subscriptions {
+		if busChannels[busChannel] {
+			continue
+		}
+
+		subscription.Unsubscribe()
+		delete(c.subscriptions, busChannel)
+	}
+
+	return nil
+}
+
+// subscribe subscribes input channels of cube by current channels mapping
+func (c *Cube) subscribe() error {
+	c.subscriptionsMutex.Lock()
+	defer c.subscriptionsMutex.Unlock()
+
+	c.subscriptions = map[string]*nats.Subscription{}
+	return c.updateSubscriptions()
+}
+
+// unsubscribe drops subscriptions of input channels, messages, which are handled already, are finished
+func (c *Cube) unsubscribe() {
+	c.subscriptionsMutex.Lock()
+	defer c.subscriptionsMutex.Unlock()
+
+	for _, subscription := range c.subscriptions {
+		subscription.Unsubscribe()
+	}
+
+	c.subscriptions = nil
+}
+
+// reload rereads config of instance on SIGHUP, cubes sends it when channels mapping of running instance is changed,
+// input channels are resubscribed by new mapping without restart
+func (c *Cube) reload() {
+	config, err := readConfig(c.configPath)
+	if err != nil {
+		c.LogError(fmt.Sprintf("can't reload config: %v", err))
+		return
+	}
+
+	c.setConfig(*config)
+
+	c.subscriptionsMutex.Lock()
+	defer c.subscriptionsMutex.Unlock()
+
+	// cube, which isn't ready yet, is subscribed by new mapping, when it's ready
+	if c.subscriptions == nil {
+		return
+	}
+
+	err = c.updateSubscriptions()
+	if err != nil {
+		c.LogError(fmt.Sprintf("can't resubscribe channels by new mapping: %v", err))
+		return
+	}
+
+	c.LogInfo("channels are resubscribed by new mapping")
+}
@@ -0,0 +1,404 @@
+// Package executor runs cube handler as instance: it reads config of instance, connects to bus and passes
+// messages and requests of cube's channels to handler. Compiled cubes have main, which calls Run with handler.
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/akaumov/cube"
+	"github.com/nats-io/nats.go"
+	"github.com/satori/go.uuid"
+)
+
+const Version = "1"
+
+// ConfigPath is path of instance config in instance container, cubes copies config there before start
+const ConfigPath = "/config.json"
+
+// Config is config of instance, which cubes copies to instance container, it has only fields, which executor uses
+type Config struct {
+	Name            string            `json:"name"`
+	Class           string            `json:"class"`
+	Params          map[string]string `json:"params"`
+	QueueGroup      string            `json:"queueGroup"`
+	ChannelsMapping map[string]string `json:"channelsMapping"`
+	Readiness       *ReadinessProbe   `json:"readiness,omitempty"`
+}
+
+// LogMessageParams are params of log message, which executor publishes to log channel of instance
+type LogMessageParams struct {
+	Time       int64  `json:"time"`
+	Id         string `json:"id"`
+	Class      string `json:"class"`
+	InstanceId string `json:"instanceId"`
+	Level      string `json:"level"`
+	Text       string `json:"text"`
+}
+
+// Cube is running instance of cube, it's passed to handler
+type Cube struct {
+	config     Config
+	configPath string
+	handler    cube.HandlerInterface
+	connection *nats.Conn
+
+	mappingMutex        sync.RWMutex
+	cubeChannelsMapping map[string]string
+	busChannelsMapping  map[string]string
+
+	inputChannels []cube.InputChannel
+
+	// subscriptions are subscriptions of bus channels, they're nil until cube is ready
+	subscriptionsMutex sync.Mutex
+	subscriptions      map[string]*nats.Subscription
+
+	stopOnce sync.Once
+	stopped  chan struct{}
+
+	// failure is why instance is stopped by executor, it's nil when instance is stopped by signal or handler
+	failure error
+}
+
+func readConfig(configPath string) (*Config, error) {
+	rawConfig, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("can't read config file: %v", err)
+	}
+
+	var config Config
+	err = json.Unmarshal(rawConfig, &config)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse config file: %v", err)
+	}
+
+	return &config, nil
+}
+
+// NewCube reads config of instance, handler gets messages of instance after Start
+func NewCube(configPath string, handler cube.HandlerInterface) (*Cube, error) {
+	config, err := readConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Cube{
+		configPath: configPath,
+		handler:    handler,
+		stopped:    make(chan struct{}),
+	}
+
+	c.setConfig(*config)
+	return c, nil
+}
+
+// Run runs handler as instance until instance is stopped by SIGTERM or Ctrl+C
+func Run(configPath string, handler cube.HandlerInterface) error {
+	c, err := NewCube(configPath, handler)
+	if err != nil {
+		return err
+	}
+
+	return c.Start()
+}
+
+// setConfig replaces config of instance and its channels mapping
+func (c *Cube) setConfig(config Config) {
+	busChannelsMapping := map[string]string{}
+	for cubeChannel, busChannel := range config.ChannelsMapping {
+		busChannelsMapping[busChannel] = cubeChannel
+	}
+
+	c.mappingMutex.Lock()
+	defer c.mappingMutex.Unlock()
+
+	c.config = config
+	c.cubeChannelsMapping = config.ChannelsMapping
+	c.busChannelsMapping = busChannelsMapping
+}
+
+func (c *Cube) GetParam(param string) string {
+	c.mappingMutex.RLock()
+	defer c.mappingMutex.RUnlock()
+
+	return c.config.Params[param]
+}
+
+func (c *Cube) GetClass() string {
+	return c.config.Class
+}
+
+func (c *Cube) GetInstanceId() string {
+	return c.config.Name
+}
+
+func (c *Cube) mapToBusChannel(channel cube.Channel) string {
+	c.mappingMutex.RLock()
+	defer c.mappingMutex.RUnlock()
+
+	busChannel, ok := c.cubeChannelsMapping[string(channel)]
+	if !ok || busChannel == "" {
+		return string(channel)
+	}
+
+	return busChannel
+}
+
+func (c *Cube) mapToCubeChannel(channel string) cube.Channel {
+	c.mappingMutex.RLock()
+	defer c.mappingMutex.RUnlock()
+
+	cubeChannel, ok := c.busChannelsMapping[channel]
+	if !ok || cubeChannel == "" {
+		return cube.Channel(channel)
+	}
+
+	return cube.Channel(cubeChannel)
+}
+
+func (c *Cube) PublishMessage(channel cube.Channel, message cube.Message) error {
+	encodedMessage, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	return c.connection.Publish(c.mapToBusChannel(channel), encodedMessage)
+}
+
+func (c *Cube) CallMethod(channel cube.Channel, request cube.Request, timeout time.Duration) (*cube.Response, error) {
+	encodedRequest, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := c.connection.Request(c.mapToBusChannel(channel), encodedRequest, timeout)
+	if err == nats.ErrTimeout {
+		return nil, cube.ErrorTimeout
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var response cube.Response
+	err = json.Unmarshal(reply.Data, &response)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse response: %v", err)
+	}
+
+	return &response, nil
+}
+
+// sendLogMessage publishes log message of instance to log.<level>.<class>.<instance> channel and prints it to
+// instance output, so it's in instance logs when bus isn't reachable
+func (c *Cube) sendLogMessage(level string, text string) error {
+	log.Printf("%v: %v", level, text)
+
+	id := uuid.NewV4().String()
+
+	packedLogMessage, err := json.Marshal(LogMessageParams{
+		Id:         id,
+		Class:      c.config.Class,
+		InstanceId: c.config.Name,
+		Time:       time.Now().UnixNano(),
+		Level:      level,
+		Text:       text,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	encodedMessage, err := json.Marshal(cube.Message{
+		Id:      &id,
+		Version: Version,
+		Method:  level,
+		Params:  (*json.RawMessage)(&packedLogMessage),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return c.connection.Publish(getLogChannel(level, c.config.Class, c.config.Name), encodedMessage)
+}
+
+// getLogChannel returns channel of log messages of instance
+func getLogChannel(level string, class string, name string) string {
+	return "log." + level + "." + class + "." + name
+}
+
+func (c *Cube) LogDebug(text string) error {
+	return c.sendLogMessage("debug", text)
+}
+
+func (c *Cube) LogError(text string) error {
+	return c.sendLogMessage("error", text)
+}
+
+func (c *Cube) LogFatal(text string) error {
+	return c.sendLogMessage("fatal", text)
+}
+
+func (c *Cube) LogInfo(text string) error {
+	return c.sendLogMessage("info", text)
+}
+
+func (c *Cube) LogWarning(text string) error {
+	return c.sendLogMessage("warning", text)
+}
+
+func (c *Cube) LogTrace(text string) error {
+	return c.sendLogMessage("trace", text)
+}
+
+// handleBusMessage passes message to handler, message with reply channel is request and handler's response is sent
+// back to it. Handler, which reads headers, gets them with message or request.
+func (c *Cube) handleBusMessage(busMessage *nats.Msg) {
+	channel := c.mapToCubeChannel(busMessage.Subject)
+
+	if busMessage.Reply == "" {
+		var message cube.Message
+		err := json.Unmarshal(busMessage.Data, &message)
+		if err != nil {
+			c.LogWarning(fmt.Sprintf("can't parse message of %v: %v", channel, err))
+			return
+		}
+
+		if headersHandler, ok := c.handler.(MessageHeadersHandler); ok {
+			headersHandler.OnReceiveMessageWithHeaders(c, channel, message, getHeaders(busMessage))
+			return
+		}
+
+		c.handler.OnReceiveMessage(c, channel, message)
+		return
+	}
+
+	var request cube.Request
+	err := json.Unmarshal(busMessage.Data, &request)
+	if err != nil {
+		c.respond(busMessage, nil, fmt.Errorf("can't parse request: %v", err))
+		return
+	}
+
+	if headersHandler, ok := c.handler.(RequestHeadersHandler); ok {
+		response, err := headersHandler.OnReceiveRequestWithHeaders(c, channel, request, getHeaders(busMessage))
+		c.respond(busMessage, response, err)
+		return
+	}
+
+	response, err := c.handler.OnReceiveRequest(c, channel, request)
+	c.respond(busMessage, response, err)
+}
+
+// respond sends response of handler to reply channel of request, handler's error is sent as response error
+func (c *Cube) respond(busMessage *nats.Msg, response *cube.Response, err error) {
+	if err != nil {
+		response = &cube.Response{
+			Version: Version,
+			Errors: &[]cube.Error{
+				{Code: "error", Name: "error", Description: err.Error()},
+			},
+		}
+	}
+
+	if response == nil {
+		response = &cube.Response{Version: Version}
+	}
+
+	encodedResponse, err := json.Marshal(response)
+	if err != nil {
+		c.LogError(fmt.Sprintf("can't encode response: %v", err))
+		return
+	}
+
+	err = busMessage.Respond(encodedResponse)
+	if err != nil {
+		c.LogWarning(fmt.Sprintf("can't send response: %v", err))
+	}
+}
+
+// Start connects to bus, subscribes input channels of cube, when it's ready, and blocks until instance is stopped,
+// it returns error when instance is stopped by executor
+func (c *Cube) Start() error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	go func() {
+		for {
+			select {
+			case receivedSignal := <-signals:
+				if receivedSignal == syscall.SIGHUP {
+					c.reload()
+					continue
+				}
+
+				c.Stop()
+				return
+			case <-c.stopped:
+				return
+			}
+		}
+	}()
+
+	err := c.connectBus()
+	if err != nil {
+		return err
+	}
+
+	connection := c.connection
+	defer connection.Close()
+
+	err = c.exchangeProtocol()
+	if err != nil {
+		return err
+	}
+
+	c.inputChannels = c.handler.OnInitInstance()
+
+	c.handler.OnStart(c)
+
+	// half-initialized cube doesn't get messages, other instances of its queue group get them meanwhile
+	if c.waitForReadiness() {
+		err = c.subscribe()
+		if err != nil {
+			c.handler.OnStop(c)
+			return err
+		}
+
+		markReady()
+		log.Printf("Instance %v started", c.config.Name)
+	}
+
+	<-c.stopped
+
+	c.unsubscribe()
+	c.handler.OnStop(c)
+
+	// messages published by handler while it stopped are sent before connection is closed
+	connection.Flush()
+	return c.failure
+}
+
+// Stop stops instance, Start returns after handler's OnStop
+func (c *Cube) Stop() {
+	c.stopOnce.Do(func() { close(c.stopped) })
+}
+
+// fail stops instance with error, Start returns it
+func (c *Cube) fail(err error) {
+	c.stopOnce.Do(func() {
+		c.failure = err
+		close(c.stopped)
+	})
+}
+
+var _ cube.Cube = (*Cube)(nil)
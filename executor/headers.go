@@ -0,0 +1,62 @@
+package executor
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/akaumov/cube"
+	"github.com/nats-io/nats.go"
+)
+
+// Headers are metadata of message: content type, schema version, trace id and custom keys, keys are lowercased
+type Headers map[string]string
+
+// MessageHeadersHandler is implemented by handler, which reads headers of messages, executor passes messages to it
+// instead of OnReceiveMessage
+type MessageHeadersHandler interface {
+	OnReceiveMessageWithHeaders(instance cube.Cube, channel cube.Channel, message cube.Message, headers Headers)
+}
+
+// RequestHeadersHandler is implemented by handler, which reads headers of requests, executor passes requests to it
+// instead of OnReceiveRequest
+type RequestHeadersHandler interface {
+	OnReceiveRequestWithHeaders(instance cube.Cube, channel cube.Channel, request cube.Request, headers Headers) (*cube.Response, error)
+}
+
+// HeadersPublisher is implemented by instance, which handler gets, handler publishes messages with headers by it:
+// instance.(executor.HeadersPublisher).PublishMessageWithHeaders(channel, message, headers)
+type HeadersPublisher interface {
+	PublishMessageWithHeaders(channel cube.Channel, message cube.Message, headers Headers) error
+}
+
+// getHeaders returns headers of message received from bus, keys are lowercased like keys of cubes publish --headers
+func getHeaders(busMessage *nats.Msg) Headers {
+	headers := Headers{}
+
+	for key, values := range busMessage.Header {
+		if len(values) > 0 {
+			headers[strings.ToLower(key)] = values[0]
+		}
+	}
+
+	return headers
+}
+
+// PublishMessageWithHeaders sends message with headers, message without headers is sent like by PublishMessage
+func (c *Cube) PublishMessageWithHeaders(channel cube.Channel, message cube.Message, headers Headers) error {
+	encodedMessage, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	busMessage := nats.NewMsg(c.mapToBusChannel(channel))
+	busMessage.Data = encodedMessage
+
+	for key, value := range headers {
+		busMessage.Header.Set(key, value)
+	}
+
+	return c.connection.PublishMsg(busMessage)
+}
+
+var _ HeadersPublisher = (*Cube)(nil)
@@ -0,0 +1,98 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// BusProtocolVersion is version of protocol between executor and bus: message encoding and service channels,
+// it's increased when they change incompatibly
+const BusProtocolVersion = 1
+
+// ProtocolChannel is channel, which executor sends its protocol to after it connects to bus, cubes started with bus
+// answers with protocol of bus
+const ProtocolChannel = "_cubes.protocol"
+
+// protocolTimeout is how long executor waits for protocol of bus
+const protocolTimeout = 5 * time.Second
+
+// ProtocolRequest is sent by executor to ProtocolChannel
+type ProtocolRequest struct {
+	Instance string `json:"instance"`
+	Class    string `json:"class"`
+	Protocol int    `json:"protocol"`
+}
+
+// ProtocolResponse is protocol of bus, Error is set when bus doesn't serve protocol of instance
+type ProtocolResponse struct {
+	Protocol int    `json:"protocol"`
+	Error    string `json:"error,omitempty"`
+}
+
+// checkProtocol returns error when bus and executor can't understand each other
+func checkProtocol(busProtocol int) error {
+	if busProtocol < BusProtocolVersion {
+		return fmt.Errorf("instance speaks bus protocol %v, but bus speaks older protocol %v: restart bus with newer cubes",
+			BusProtocolVersion, busProtocol)
+	}
+
+	return nil
+}
+
+// getEnvBusProtocol returns protocol of bus, which cubes passed to instance on start, it's 0 when it isn't passed
+func getEnvBusProtocol() int {
+	protocol, err := strconv.Atoi(os.Getenv("CUBE_BUS_PROTOCOL"))
+	if err != nil {
+		return 0
+	}
+
+	return protocol
+}
+
+// exchangeProtocol sends protocol of executor to bus and checks protocol, which bus answers with. Bus started
+// by older cubes doesn't answer, protocol passed to instance on start is checked then.
+func (c *Cube) exchangeProtocol() error {
+	request, err := json.Marshal(ProtocolRequest{
+		Instance: c.config.Name,
+		Class:    c.config.Class,
+		Protocol: BusProtocolVersion,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	reply, err := c.connection.Request(ProtocolChannel, request, protocolTimeout)
+	if err == nats.ErrNoResponders || err == nats.ErrTimeout {
+		busProtocol := getEnvBusProtocol()
+		if busProtocol == 0 {
+			log.Printf("Bus doesn't answer with its protocol, it isn't checked")
+			return nil
+		}
+
+		log.Printf("Bus doesn't answer with its protocol, protocol %v of bus at instance start is checked", busProtocol)
+		return checkProtocol(busProtocol)
+	}
+
+	if err != nil {
+		return fmt.Errorf("can't exchange protocol with bus: %v", err)
+	}
+
+	var response ProtocolResponse
+	err = json.Unmarshal(reply.Data, &response)
+	if err != nil {
+		return fmt.Errorf("can't parse protocol of bus: %v", err)
+	}
+
+	if response.Error != "" {
+		return fmt.Errorf("bus refused protocol %v of instance: %v", BusProtocolVersion, response.Error)
+	}
+
+	return checkProtocol(response.Protocol)
+}
@@ -0,0 +1,60 @@
+package executor
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"os/exec"
+	"time"
+)
+
+// ReadyMarkerPath is file, which executor writes when channels of cube are subscribed, healthcheck of instance
+// container checks it
+const ReadyMarkerPath = "/tmp/cube-ready"
+
+// ReadinessProbe is command, which passes when cube is ready to get messages
+type ReadinessProbe struct {
+	Command         string `json:"command"`
+	IntervalSeconds int    `json:"intervalSeconds"`
+	TimeoutSeconds  int    `json:"timeoutSeconds"`
+}
+
+// runReadinessProbe runs probe command in shell of instance, it returns error when command fails or times out
+func runReadinessProbe(probe ReadinessProbe) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(probe.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	return exec.CommandContext(ctx, "sh", "-c", probe.Command).Run()
+}
+
+// waitForReadiness runs readiness probe of instance until it passes, it returns false when instance is stopped
+// before it's ready
+func (c *Cube) waitForReadiness() bool {
+	probe := c.config.Readiness
+	if probe == nil {
+		return true
+	}
+
+	for {
+		err := runReadinessProbe(*probe)
+		if err == nil {
+			return true
+		}
+
+		log.Printf("Instance isn't ready, its channels aren't subscribed: %v", err)
+
+		select {
+		case <-time.After(time.Duration(probe.IntervalSeconds) * time.Second):
+		case <-c.stopped:
+			return false
+		}
+	}
+}
+
+// markReady writes ready marker, so healthcheck of instance passes and instances, which depend on it, are started
+func markReady() {
+	err := ioutil.WriteFile(ReadyMarkerPath, []byte{}, 0644)
+	if err != nil {
+		log.Printf("Can't write ready marker %v: %v", ReadyMarkerPath, err)
+	}
+}
@@ -0,0 +1,172 @@
+package gateway
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/akaumov/cube"
+	"github.com/akaumov/cube-http-gateway/js"
+)
+
+const Version = "1"
+
+const defaultTimeoutMs = 30000
+
+// Handler is cube which passes http requests to bus channels found in routes table
+// and writes responses back, request and response formats are the same as in cube-http-gateway
+type Handler struct {
+	httpServer   *http.Server
+	cubeInstance cube.Cube
+	routes       []Route
+	timeout      time.Duration
+}
+
+func (h *Handler) OnInitInstance() []cube.InputChannel {
+	return []cube.InputChannel{}
+}
+
+func (h *Handler) OnStart(cubeInstance cube.Cube) {
+	h.cubeInstance = cubeInstance
+
+	routes, err := ParseRoutes(cubeInstance.GetParam("routes"))
+	if err != nil {
+		cubeInstance.LogFatal("can't parse routes: " + err.Error())
+		return
+	}
+
+	h.routes = routes
+	h.timeout = defaultTimeoutMs * time.Millisecond
+
+	timeoutString := cubeInstance.GetParam("timeout")
+	if timeoutString != "" {
+		timeoutMs, err := strconv.ParseUint(timeoutString, 10, 64)
+		if err != nil {
+			cubeInstance.LogError("wrong timeout: " + timeoutString)
+		} else {
+			h.timeout = time.Duration(timeoutMs) * time.Millisecond
+		}
+	}
+
+	go h.startHttpServer()
+}
+
+func (h *Handler) OnStop(c cube.Cube) {
+	if h.httpServer != nil {
+		h.httpServer.Close()
+	}
+}
+
+func (h *Handler) OnReceiveMessage(instance cube.Cube, channel cube.Channel, message cube.Message) {
+	instance.LogError("OnReceiveMessage: is not implemented")
+}
+
+func (h *Handler) OnReceiveRequest(instance cube.Cube, channel cube.Channel, request cube.Request) (*cube.Response, error) {
+	instance.LogError("OnReceiveRequest: is not implemented")
+	return &cube.Response{
+		Version: Version,
+		Errors: &[]cube.Error{
+			{
+				Code:        "400",
+				Name:        "NotImplemented",
+				Description: "OnReceiveRequest: is not implemented",
+			},
+		},
+	}, nil
+}
+
+func (h *Handler) startHttpServer() {
+	h.httpServer = &http.Server{
+		Addr:    ":80",
+		Handler: h,
+	}
+
+	h.cubeInstance.LogInfo("Start http listening")
+
+	err := h.httpServer.ListenAndServe()
+	if err != nil && err != http.ErrServerClosed {
+		h.cubeInstance.LogFatal(err.Error())
+	}
+}
+
+func (h *Handler) packRequest(request *http.Request) (*cube.Request, error) {
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(body) == 0 {
+		body = nil
+	}
+
+	params := js.RequestParams{
+		Method:     request.Method,
+		InputTime:  time.Now().UnixNano(),
+		Host:       request.Host,
+		RequestURI: request.RequestURI,
+		Body:       body,
+		RemoteAddr: request.RemoteAddr,
+		Headers:    request.Header,
+	}
+
+	packedParams, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cube.Request{
+		Version: Version,
+		Method:  request.Method,
+		Params:  (*json.RawMessage)(&packedParams),
+	}, nil
+}
+
+func writeError(writer http.ResponseWriter, status int) {
+	http.Error(writer, http.StatusText(status), status)
+}
+
+func (h *Handler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	route := findRoute(h.routes, request.Method, request.URL.Path)
+	if route == nil {
+		writeError(writer, http.StatusNotFound)
+		return
+	}
+
+	requestData, err := h.packRequest(request)
+	if err != nil {
+		writeError(writer, http.StatusBadRequest)
+		return
+	}
+
+	response, err := h.cubeInstance.CallMethod(cube.Channel(route.Channel), *requestData, h.timeout)
+	if err != nil {
+		if err == cube.ErrorTimeout {
+			writeError(writer, http.StatusGatewayTimeout)
+			return
+		}
+
+		writeError(writer, http.StatusBadGateway)
+		return
+	}
+
+	if response.Errors != nil && len(*response.Errors) > 0 || response.Result == nil {
+		writeError(writer, http.StatusBadGateway)
+		return
+	}
+
+	var result js.Response
+	err = json.Unmarshal(*response.Result, &result)
+	if err != nil {
+		writeError(writer, http.StatusBadGateway)
+		return
+	}
+
+	writer.WriteHeader(int(result.Status))
+	if len(result.Body) > 0 {
+		writer.Write(result.Body)
+	}
+}
+
+var _ cube.HandlerInterface = (*Handler)(nil)
@@ -0,0 +1,72 @@
+package gateway
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Route maps http method and path to bus channel, path ending with "/*" matches all nested paths
+type Route struct {
+	Method  string
+	Path    string
+	Channel string
+}
+
+func (r Route) match(method string, path string) bool {
+	if r.Method != "*" && r.Method != method {
+		return false
+	}
+
+	if strings.HasSuffix(r.Path, "/*") {
+		prefix := strings.TrimSuffix(r.Path, "*")
+		return strings.HasPrefix(path, prefix) || path == strings.TrimSuffix(prefix, "/")
+	}
+
+	return r.Path == path
+}
+
+// ParseRoutes parses routes table: "GET /users:users.list;POST /users:users.create;* /files/*:files"
+func ParseRoutes(rawRoutes string) ([]Route, error) {
+	routes := []Route{}
+
+	if strings.TrimSpace(rawRoutes) == "" {
+		return routes, nil
+	}
+
+	for _, rawRoute := range strings.Split(rawRoutes, ";") {
+		rawRoute = strings.TrimSpace(rawRoute)
+		if rawRoute == "" {
+			continue
+		}
+
+		channelIndex := strings.LastIndex(rawRoute, ":")
+		if channelIndex == -1 {
+			return nil, fmt.Errorf("wrong route format: %v", rawRoute)
+		}
+
+		channel := strings.TrimSpace(rawRoute[channelIndex+1:])
+		parts := strings.Fields(rawRoute[:channelIndex])
+
+		if len(parts) != 2 || channel == "" || !strings.HasPrefix(parts[1], "/") {
+			return nil, fmt.Errorf("wrong route format: %v", rawRoute)
+		}
+
+		routes = append(routes, Route{
+			Method:  strings.ToUpper(parts[0]),
+			Path:    parts[1],
+			Channel: channel,
+		})
+	}
+
+	return routes, nil
+}
+
+func findRoute(routes []Route, method string, path string) *Route {
+	for index := range routes {
+		if routes[index].match(method, path) {
+			return &routes[index]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,363 @@
+package global
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/akaumov/cubes/utils"
+)
+
+// backupStateDirectory keeps bus state in backup, paths inside it are relative to project state directory
+const backupStateDirectory = "state"
+const backupConfigFile = "project.json"
+
+// busStateDirectories keep bus store with consumers positions, bus users and bus certificates
+var busStateDirectories = []string{"bus", "credentials", path.Join("tls", "bus")}
+
+// isBusStateFile returns false for files of running processes and bus protocol, they make no sense after restore, and for encryption key,
+// which must be kept apart from encrypted messages
+func isBusStateFile(name string) bool {
+	return name != "protocol" && name != busEncryptionKeyFile && filepath.Ext(name) != ".pid" && filepath.Ext(name) != ".log"
+}
+
+func checkBusStopped() error {
+	isRunning, err := isBusRunning()
+	if err != nil {
+		return fmt.Errorf("can't inspect bus container: %v", err)
+	}
+
+	if isRunning {
+		return fmt.Errorf("bus is running, stop it with 'cubes bus stop' first, its store is changed while it runs")
+	}
+
+	return nil
+}
+
+func writeBackupFile(tarWriter *tar.Writer, filePath string, name string, info os.FileInfo) error {
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+
+	header.Name = name
+
+	err = tarWriter.WriteHeader(header)
+	if err != nil {
+		return err
+	}
+
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	_, err = io.Copy(tarWriter, file)
+	return err
+}
+
+func writeBackupDirectory(tarWriter *tar.Writer, stateDirectory string, directory string) error {
+	root := filepath.Join(stateDirectory, filepath.FromSlash(directory))
+
+	return filepath.Walk(root, func(filePath string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() && (!info.Mode().IsRegular() || !isBusStateFile(info.Name())) {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(stateDirectory, filePath)
+		if err != nil {
+			return err
+		}
+
+		name := path.Join(backupStateDirectory, filepath.ToSlash(relativePath))
+		if info.IsDir() {
+			name += "/"
+		}
+
+		return writeBackupFile(tarWriter, filePath, name, info)
+	})
+}
+
+// BackupBus writes persistent bus state to gzipped tar: streams with messages and consumers positions, bus users,
+// bus certificates and project config. Bus must be stopped, so its store isn't changed while it's copied.
+func BackupBus(backupPath string) error {
+	err := checkBusStopped()
+	if err != nil {
+		return err
+	}
+
+	stateDirectory, err := utils.GetStateDirectoryPath()
+	if err != nil {
+		return err
+	}
+
+	configPath, err := getProjectConfigPath()
+	if err != nil {
+		return err
+	}
+
+	backupFile, err := os.Create(backupPath)
+	if err != nil {
+		return fmt.Errorf("can't create backup file: %v", err)
+	}
+
+	defer backupFile.Close()
+
+	gzipWriter := gzip.NewWriter(backupFile)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	configInfo, err := os.Stat(configPath)
+	if err != nil {
+		return fmt.Errorf("can't read project config: %v", err)
+	}
+
+	err = writeBackupFile(tarWriter, configPath, backupConfigFile, configInfo)
+	if err != nil {
+		return fmt.Errorf("can't write backup: %v", err)
+	}
+
+	for _, directory := range busStateDirectories {
+		err = writeBackupDirectory(tarWriter, stateDirectory, directory)
+		if err != nil {
+			return fmt.Errorf("can't write backup of %v: %v", directory, err)
+		}
+	}
+
+	err = tarWriter.Close()
+	if err != nil {
+		return fmt.Errorf("can't write backup: %v", err)
+	}
+
+	keyPath, err := getBusEncryptionKeyPath()
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(keyPath); err == nil {
+		utils.Warningf("Bus encryption key isn't in backup, keep %v apart, restored messages can't be read without it\n", keyPath)
+	}
+
+	return gzipWriter.Close()
+}
+
+// hasBusState returns true when project has bus state, which would be replaced by restore
+func hasBusState(stateDirectory string) (bool, error) {
+	hasState := false
+
+	for _, directory := range busStateDirectories {
+		root := filepath.Join(stateDirectory, filepath.FromSlash(directory))
+
+		err := filepath.Walk(root, func(filePath string, info os.FileInfo, err error) error {
+			if os.IsNotExist(err) {
+				return nil
+			}
+
+			if err != nil {
+				return err
+			}
+
+			if !info.IsDir() && isBusStateFile(info.Name()) {
+				hasState = true
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return hasState, nil
+}
+
+// getRestorePath returns path of backup entry in project, entries outside of bus state are refused
+func getRestorePath(stateDirectory string, name string) (string, error) {
+	cleanName := path.Clean(name)
+	relativePath := strings.TrimPrefix(cleanName, backupStateDirectory+"/")
+
+	if relativePath == cleanName {
+		return "", fmt.Errorf("unknown backup entry %v", name)
+	}
+
+	for _, directory := range busStateDirectories {
+		if relativePath == directory || strings.HasPrefix(relativePath, directory+"/") {
+			return filepath.Join(stateDirectory, filepath.FromSlash(relativePath)), nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown backup entry %v", name)
+}
+
+func restoreFile(reader io.Reader, filePath string, mode os.FileMode) error {
+	err := os.MkdirAll(filepath.Dir(filePath), 0777)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	_, err = io.Copy(file, reader)
+	return err
+}
+
+// readBackup calls onEntry for every entry of backup
+func readBackup(backupPath string, onEntry func(header *tar.Header, reader io.Reader) error) error {
+	backupFile, err := os.Open(backupPath)
+	if err != nil {
+		return fmt.Errorf("can't open backup file: %v", err)
+	}
+
+	defer backupFile.Close()
+
+	gzipReader, err := gzip.NewReader(backupFile)
+	if err != nil {
+		return fmt.Errorf("can't read backup: %v", err)
+	}
+
+	defer gzipReader.Close()
+
+	tarReader := tar.NewReader(gzipReader)
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("can't read backup: %v", err)
+		}
+
+		err = onEntry(header, tarReader)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// RestoreBus replaces bus state of project with state from backup, project config is restored only when it's asked,
+// bus must be stopped. Existing bus state is replaced only when it's forced.
+func RestoreBus(backupPath string, isForced bool, isConfigRestored bool) error {
+	err := checkBusStopped()
+	if err != nil {
+		return err
+	}
+
+	stateDirectory, err := utils.GetStateDirectoryPath()
+	if err != nil {
+		return err
+	}
+
+	hasState, err := hasBusState(stateDirectory)
+	if err != nil {
+		return fmt.Errorf("can't read bus state: %v", err)
+	}
+
+	if hasState && !isForced {
+		return fmt.Errorf("project already has bus state, use --force to replace it")
+	}
+
+	configPath, err := getProjectConfigPath()
+	if err != nil {
+		return err
+	}
+
+	// whole backup is checked before current state is removed
+	err = readBackup(backupPath, func(header *tar.Header, reader io.Reader) error {
+		if header.Name == backupConfigFile {
+			return nil
+		}
+
+		_, err := getRestorePath(stateDirectory, header.Name)
+		return err
+	})
+
+	if err != nil {
+		return err
+	}
+
+	// encryption key isn't in backup, restored store is readable only with current key
+	keyPath, err := getBusEncryptionKeyPath()
+	if err != nil {
+		return err
+	}
+
+	key, err := ioutil.ReadFile(keyPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("can't read bus encryption key: %v", err)
+	}
+
+	for _, directory := range busStateDirectories {
+		err = os.RemoveAll(filepath.Join(stateDirectory, filepath.FromSlash(directory)))
+		if err != nil {
+			return fmt.Errorf("can't clear bus state: %v", err)
+		}
+	}
+
+	if key != nil {
+		err = restoreFile(bytes.NewReader(key), keyPath, 0600)
+		if err != nil {
+			return fmt.Errorf("can't keep bus encryption key: %v", err)
+		}
+	}
+
+	return readBackup(backupPath, func(header *tar.Header, reader io.Reader) error {
+		if header.Name == backupConfigFile {
+			if !isConfigRestored {
+				return nil
+			}
+
+			err := restoreFile(reader, configPath, 0644)
+			if err != nil {
+				return fmt.Errorf("can't restore project config: %v", err)
+			}
+
+			return nil
+		}
+
+		restorePath, err := getRestorePath(stateDirectory, header.Name)
+		if err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(restorePath, 0777)
+		case tar.TypeReg:
+			err = restoreFile(reader, restorePath, os.FileMode(header.Mode).Perm())
+		}
+
+		if err != nil {
+			return fmt.Errorf("can't restore %v: %v", header.Name, err)
+		}
+
+		return nil
+	})
+}
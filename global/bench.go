@@ -0,0 +1,218 @@
+package global
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akaumov/cube"
+	"github.com/akaumov/cubes/instance"
+	"github.com/akaumov/cubes/utils"
+	"github.com/nats-io/nats.go"
+)
+
+const benchEchoName = "cubes-bench-echo"
+
+// benchEchoTimeout is time to wait for echo instance, its source is built on the first start
+const benchEchoTimeout = 5 * time.Minute
+
+type BenchOptions struct {
+	// Size is size of request params in bytes
+	Size int
+
+	// Rate is number of requests per second, requests are sent as fast as possible when it's 0
+	Rate int
+
+	Count int
+
+	// Timeout is time to wait for responses after the last request is sent
+	Timeout time.Duration
+}
+
+type BenchResult struct {
+	Sent             int     `json:"sent"`
+	Received         int     `json:"received"`
+	DurationSeconds  float64 `json:"durationSeconds"`
+	Throughput       float64 `json:"throughput"`
+	LatencyP50Millis float64 `json:"latencyP50Millis"`
+	LatencyP90Millis float64 `json:"latencyP90Millis"`
+	LatencyP99Millis float64 `json:"latencyP99Millis"`
+	LatencyMaxMillis float64 `json:"latencyMaxMillis"`
+}
+
+type benchParams struct {
+	Data string `json:"data"`
+}
+
+func getBenchRequest(size int) ([]byte, error) {
+	params, err := json.Marshal(benchParams{Data: strings.Repeat("x", size)})
+	if err != nil {
+		return nil, err
+	}
+
+	rawParams := json.RawMessage(params)
+
+	return json.Marshal(cube.Request{
+		Version: "1",
+		Method:  "echo",
+		Params:  &rawParams,
+	})
+}
+
+func getPercentile(latencies []time.Duration, percentile float64) float64 {
+	if len(latencies) == 0 {
+		return 0
+	}
+
+	index := int(float64(len(latencies)-1) * percentile)
+	return float64(latencies[index]) / float64(time.Millisecond)
+}
+
+// waitResponder sends requests until channel has responder, echo instance subscribes after it's built and started
+func waitResponder(connection *nats.Conn, channel string, request []byte, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		_, err := connection.Request(channel, request, time.Second)
+		if err == nil {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("channel %v doesn't have responder: %v", channel, err)
+		}
+	}
+}
+
+// Bench sends requests to channel at rate and measures throughput and latency of responses,
+// with echo temporary echo instance answers requests of channel
+func Bench(channel string, options BenchOptions, isEcho bool) (*BenchResult, error) {
+	if channel == "" {
+		return nil, fmt.Errorf("channel is required")
+	}
+
+	if options.Count <= 0 || options.Size < 0 || options.Rate < 0 {
+		return nil, fmt.Errorf("count must be positive, size and rate can't be negative")
+	}
+
+	request, err := getBenchRequest(options.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	connection, err := connectRunningBus()
+	if err != nil {
+		return nil, err
+	}
+
+	defer connection.Close()
+
+	responderTimeout := options.Timeout
+
+	if isEcho {
+		err = instance.AddEcho(benchEchoName, channel)
+		if err != nil {
+			return nil, fmt.Errorf("can't add echo instance: %v", err)
+		}
+
+		defer instance.RemoveTemporary(benchEchoName)
+
+		err = instance.Start(benchEchoName)
+		if err != nil {
+			return nil, fmt.Errorf("can't start echo instance: %v", err)
+		}
+
+		responderTimeout = benchEchoTimeout
+	}
+
+	utils.Infof("Waiting for responder...")
+
+	err = waitResponder(connection, channel, request, responderTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	inbox := nats.NewInbox()
+	sentAt := make([]time.Time, options.Count)
+	latencies := []time.Duration{}
+	lastReceivedAt := time.Time{}
+	mutex := sync.Mutex{}
+	isDone := make(chan struct{})
+
+	_, err = connection.Subscribe(inbox+".*", func(message *nats.Msg) {
+		receivedAt := time.Now()
+
+		index, err := strconv.Atoi(message.Subject[len(inbox)+1:])
+		if err != nil || index < 0 || index >= options.Count {
+			return
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		latencies = append(latencies, receivedAt.Sub(sentAt[index]))
+		lastReceivedAt = receivedAt
+
+		if len(latencies) == options.Count {
+			close(isDone)
+		}
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	utils.Infof("Sending %v requests of %v bytes to %v\n", options.Count, len(request), channel)
+
+	startedAt := time.Now()
+
+	for i := 0; i < options.Count; i++ {
+		if options.Rate > 0 {
+			time.Sleep(time.Until(startedAt.Add(time.Duration(i) * time.Second / time.Duration(options.Rate))))
+		}
+
+		mutex.Lock()
+		sentAt[i] = time.Now()
+		mutex.Unlock()
+
+		err = connection.PublishRequest(channel, inbox+"."+strconv.Itoa(i), request)
+		if err != nil {
+			return nil, fmt.Errorf("can't send request: %v", err)
+		}
+	}
+
+	connection.Flush()
+
+	select {
+	case <-isDone:
+	case <-time.After(options.Timeout):
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	// throughput is counted until the last response, waiting for lost responses doesn't lower it
+	duration := lastReceivedAt.Sub(startedAt)
+	if len(latencies) == 0 {
+		duration = time.Since(startedAt)
+	}
+
+	sort.Slice(latencies, func(i, j int) bool {
+		return latencies[i] < latencies[j]
+	})
+
+	return &BenchResult{
+		Sent:             options.Count,
+		Received:         len(latencies),
+		DurationSeconds:  duration.Seconds(),
+		Throughput:       float64(len(latencies)) / duration.Seconds(),
+		LatencyP50Millis: getPercentile(latencies, 0.5),
+		LatencyP90Millis: getPercentile(latencies, 0.9),
+		LatencyP99Millis: getPercentile(latencies, 0.99),
+		LatencyMaxMillis: getPercentile(latencies, 1),
+	}, nil
+}
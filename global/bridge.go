@@ -0,0 +1,346 @@
+package global
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/akaumov/cubes/utils"
+	"github.com/eclipse/paho.mqtt.golang"
+	"github.com/nats-io/nats.go"
+)
+
+// BridgeRoute forwards messages from source topic or channel to target, empty target is converted from name of message source
+type BridgeRoute struct {
+	Source string
+	Target string
+}
+
+// ParseBridgeRoutes parses routes in format "source[:target];source2[:target2]"
+func ParseBridgeRoutes(rawRoutes string) (*[]BridgeRoute, error) {
+	routes := []BridgeRoute{}
+
+	for _, rawRoute := range strings.Split(rawRoutes, ";") {
+		rawRoute = strings.TrimSpace(rawRoute)
+		if rawRoute == "" {
+			continue
+		}
+
+		parts := strings.SplitN(rawRoute, ":", 2)
+		route := BridgeRoute{Source: parts[0]}
+
+		if len(parts) == 2 {
+			route.Target = parts[1]
+		}
+
+		if route.Source == "" {
+			return nil, fmt.Errorf("wrong route %v, format is source[:target]", rawRoute)
+		}
+
+		routes = append(routes, route)
+	}
+
+	return &routes, nil
+}
+
+// topicToChannel converts mqtt topic to bus channel: sensors/+/temperature becomes sensors.*.temperature
+func topicToChannel(topic string) string {
+	tokens := strings.Split(topic, "/")
+	for i, token := range tokens {
+		switch token {
+		case "+":
+			tokens[i] = "*"
+		case "#":
+			tokens[i] = ">"
+		}
+	}
+
+	return strings.Join(tokens, ".")
+}
+
+// channelToTopic converts bus channel to mqtt topic: sensors.*.temperature becomes sensors/+/temperature
+func channelToTopic(channel string) string {
+	tokens := strings.Split(channel, ".")
+	for i, token := range tokens {
+		switch token {
+		case "*":
+			tokens[i] = "+"
+		case ">":
+			tokens[i] = "#"
+		}
+	}
+
+	return strings.Join(tokens, "/")
+}
+
+func getBridgeTarget(route BridgeRoute, source string, convert func(string) string) string {
+	if route.Target != "" {
+		return route.Target
+	}
+
+	return convert(source)
+}
+
+func checkBridgeTargets(routes []BridgeRoute, wildcards string) error {
+	for _, route := range routes {
+		if strings.ContainsAny(route.Target, wildcards) {
+			return fmt.Errorf("target of %v can't have wildcards", route.Source)
+		}
+	}
+
+	return nil
+}
+
+// mqttDisconnectQuiesce is time in milliseconds, which mqtt client waits for messages being published on disconnect
+const mqttDisconnectQuiesce = 250
+
+// BridgeMQTT forwards messages between mqtt broker and bus until Ctrl+C is pressed, messages are passed as is.
+// Topics are forwarded to bus channels and channels are forwarded to mqtt topics, they shouldn't overlap or messages loop.
+func BridgeMQTT(broker string, options *mqtt.ClientOptions, topics []BridgeRoute, channels []BridgeRoute) error {
+	if len(topics) == 0 && len(channels) == 0 {
+		return fmt.Errorf("at least one topic or channel is required")
+	}
+
+	err := checkBridgeTargets(topics, "*>")
+	if err != nil {
+		return err
+	}
+
+	err = checkBridgeTargets(channels, "+#")
+	if err != nil {
+		return err
+	}
+
+	busConnection, err := connectRunningBus()
+	if err != nil {
+		return err
+	}
+
+	defer busConnection.Close()
+
+	if options.ClientID == "" {
+		options.SetClientID("cubes-bridge")
+	}
+
+	// client reconnects to broker, when connection is lost, and subscribes topics again, broker doesn't keep them
+	// for clean session. Bridge is stopped, when topics can't be subscribed.
+	subscribeErrors := make(chan error, 1)
+	options.AddBroker(broker)
+	options.SetAutoReconnect(true)
+	options.SetConnectionLostHandler(func(client mqtt.Client, err error) {
+		utils.Warningf("Connection to broker is lost, reconnecting: %v\n", err)
+	})
+
+	options.SetOnConnectHandler(func(client mqtt.Client) {
+		err := subscribeBridgeTopics(client, busConnection, topics)
+		if err != nil {
+			select {
+			case subscribeErrors <- err:
+			default:
+			}
+		}
+	})
+
+	mqttClient := mqtt.NewClient(options)
+
+	token := mqttClient.Connect()
+	if token.Wait() && token.Error() != nil {
+		return fmt.Errorf("can't connect to broker: %v", token.Error())
+	}
+
+	defer mqttClient.Disconnect(mqttDisconnectQuiesce)
+
+	for _, route := range channels {
+		route := route
+
+		_, err = busConnection.Subscribe(route.Source, func(message *nats.Msg) {
+			token := mqttClient.Publish(getBridgeTarget(route, message.Subject, channelToTopic), 0, false, message.Data)
+			token.Wait()
+
+			err := token.Error()
+			if err != nil {
+				utils.Warningf("Can't forward message of %v to mqtt: %v\n", message.Subject, err)
+			}
+		})
+
+		if err != nil {
+			return fmt.Errorf("can't subscribe to %v: %v", route.Source, err)
+		}
+	}
+
+	utils.Infof("Bridging %v with bus: %v topics, %v channels\n", broker, len(topics), len(channels))
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	select {
+	case <-interrupt:
+		return nil
+	case err = <-subscribeErrors:
+		return err
+	}
+}
+
+// subscribeBridgeTopics subscribes mqtt topics of routes and forwards their messages to bus channels,
+// it's called on every connect to broker
+func subscribeBridgeTopics(mqttClient mqtt.Client, busConnection *nats.Conn, topics []BridgeRoute) error {
+	if len(topics) == 0 {
+		return nil
+	}
+
+	sources := map[string]byte{}
+	for _, route := range topics {
+		sources[route.Source] = 0
+	}
+
+	token := mqttClient.SubscribeMultiple(sources, func(client mqtt.Client, message mqtt.Message) {
+		topic := message.Topic()
+
+		for _, route := range topics {
+			if !isChannelMatched(topicToChannel(route.Source), topicToChannel(topic)) {
+				continue
+			}
+
+			err := busConnection.Publish(getBridgeTarget(route, topic, topicToChannel), message.Payload())
+			if err != nil {
+				utils.Warningf("Can't forward message of %v to bus: %v\n", topic, err)
+			}
+
+			return
+		}
+	})
+
+	if token.Wait() && token.Error() != nil {
+		return fmt.Errorf("can't subscribe to mqtt topics: %v", token.Error())
+	}
+
+	return nil
+}
+
+// RemoteBus is bus of other project, which is bridged with local bus
+type RemoteBus struct {
+	URL      string
+	User     string
+	Password string
+
+	// CAFile, CertFile and KeyFile are used when remote bus has TLS
+	CAFile   string
+	CertFile string
+	KeyFile  string
+}
+
+func connectRemoteBus(remote RemoteBus) (*nats.Conn, error) {
+	options := []nats.Option{
+		nats.Timeout(busRequestTimeout),
+		nats.Name("cubes-bridge"),
+		nats.MaxReconnects(-1),
+	}
+
+	if remote.User != "" {
+		options = append(options, nats.UserInfo(remote.User, remote.Password))
+	}
+
+	if remote.CAFile != "" {
+		options = append(options, nats.RootCAs(remote.CAFile))
+	}
+
+	if remote.CertFile != "" {
+		options = append(options, nats.ClientCert(remote.CertFile, remote.KeyFile))
+	}
+
+	connection, err := nats.Connect(remote.URL, options...)
+	if err != nil {
+		return nil, fmt.Errorf("can't connect to remote bus: %v", err)
+	}
+
+	return connection, nil
+}
+
+// checkBridgeLoops refuses routes, which forward messages back to bus they came from
+func checkBridgeLoops(exports []BridgeRoute, imports []BridgeRoute) error {
+	for _, exportRoute := range exports {
+		for _, importRoute := range imports {
+			exportTarget := getBridgeTarget(exportRoute, exportRoute.Source, strings.TrimSpace)
+			importTarget := getBridgeTarget(importRoute, importRoute.Source, strings.TrimSpace)
+
+			if isChannelMatched(importRoute.Source, exportTarget) || isChannelMatched(exportTarget, importRoute.Source) ||
+				isChannelMatched(exportRoute.Source, importTarget) || isChannelMatched(importTarget, exportRoute.Source) {
+				return fmt.Errorf("routes %v and %v make messages loop between buses", exportRoute.Source, importRoute.Source)
+			}
+		}
+	}
+
+	return nil
+}
+
+func forwardChannels(from *nats.Conn, to *nats.Conn, routes []BridgeRoute, direction string) error {
+	for _, route := range routes {
+		route := route
+
+		_, err := from.Subscribe(route.Source, func(message *nats.Msg) {
+			err := to.Publish(getBridgeTarget(route, message.Subject, strings.TrimSpace), message.Data)
+			if err != nil {
+				utils.Warningf("Can't %v message of %v: %v\n", direction, message.Subject, err)
+			}
+		})
+
+		if err != nil {
+			return fmt.Errorf("can't subscribe to %v: %v", route.Source, err)
+		}
+	}
+
+	return nil
+}
+
+// BridgeBus forwards allowed channels between local and remote bus until Ctrl+C is pressed, exported channels go from local
+// bus to remote one and imported channels go back, channels are renamed when routes have targets
+func BridgeBus(remote RemoteBus, exports []BridgeRoute, imports []BridgeRoute) error {
+	if len(exports) == 0 && len(imports) == 0 {
+		return fmt.Errorf("at least one exported or imported channel is required")
+	}
+
+	err := checkBridgeTargets(append(exports, imports...), "*>")
+	if err != nil {
+		return err
+	}
+
+	err = checkBridgeLoops(exports, imports)
+	if err != nil {
+		return err
+	}
+
+	localConnection, err := connectRunningBus()
+	if err != nil {
+		return err
+	}
+
+	defer localConnection.Close()
+
+	remoteConnection, err := connectRemoteBus(remote)
+	if err != nil {
+		return err
+	}
+
+	defer remoteConnection.Close()
+
+	err = forwardChannels(localConnection, remoteConnection, exports, "export")
+	if err != nil {
+		return err
+	}
+
+	err = forwardChannels(remoteConnection, localConnection, imports, "import")
+	if err != nil {
+		return err
+	}
+
+	utils.Infof("Bridging bus with %v: %v exported, %v imported channels\n", remote.URL, len(exports), len(imports))
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	<-interrupt
+	return nil
+}
@@ -0,0 +1,59 @@
+package global
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseBridgeRoutes(t *testing.T) {
+	routes, err := ParseBridgeRoutes(" sensors/+/temperature:temperature ; alerts/#;;")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []BridgeRoute{
+		{Source: "sensors/+/temperature", Target: "temperature"},
+		{Source: "alerts/#"},
+	}
+
+	if !reflect.DeepEqual(*routes, expected) {
+		t.Fatalf("expected routes %v, got %v", expected, *routes)
+	}
+}
+
+func TestParseBridgeRoutesRefusesRouteWithoutSource(t *testing.T) {
+	_, err := ParseBridgeRoutes("sensors/#;:temperature")
+	if err == nil {
+		t.Fatalf("route without source is accepted")
+	}
+}
+
+func TestTopicToChannel(t *testing.T) {
+	cases := map[string]string{
+		"sensors/+/temperature": "sensors.*.temperature",
+		"alerts/#":              "alerts.>",
+		"status":                "status",
+	}
+
+	for topic, channel := range cases {
+		if converted := topicToChannel(topic); converted != channel {
+			t.Errorf("topic %v: expected channel %v, got %v", topic, channel, converted)
+		}
+
+		if converted := channelToTopic(channel); converted != topic {
+			t.Errorf("channel %v: expected topic %v, got %v", channel, topic, converted)
+		}
+	}
+}
+
+func TestBridgeTargetsCantHaveWildcards(t *testing.T) {
+	err := checkBridgeTargets([]BridgeRoute{{Source: "sensors/#", Target: "sensors.>"}}, "*>")
+	if err == nil {
+		t.Fatalf("target with wildcard is accepted")
+	}
+
+	err = checkBridgeTargets([]BridgeRoute{{Source: "sensors/#"}}, "*>")
+	if err != nil {
+		t.Fatalf("route without target is refused: %v", err)
+	}
+}
@@ -0,0 +1,330 @@
+package global
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/akaumov/cubes/instance"
+	"github.com/akaumov/cubes/utils"
+	docker_client "github.com/docker/docker/client"
+	"golang.org/x/net/context"
+)
+
+// busPort is port of bus in its container, it's published on host on port from project config
+const busPort = utils.BusPort
+
+// busDrainSignal puts bus into lame duck mode: it stops accepting connections and lets clients move their subscriptions
+const busDrainSignal = "SIGUSR2"
+
+func isBusRunning() (bool, error) {
+	containerInfo, err := utils.InspectContainer(utils.GetBusContainerName())
+	if err != nil {
+		return false, err
+	}
+
+	return containerInfo != nil && containerInfo.State != nil && containerInfo.State.Running, nil
+}
+
+// StopBus drains bus connections and stops bus, it's stopped forcibly after drain timeout. Bus started with --daemon
+// is stopped by its daemon.
+func StopBus(drainTimeout time.Duration) error {
+	daemonPid, err := getBusProcessPid(busDaemonProcess)
+	if err != nil {
+		return fmt.Errorf("can't read pid of bus daemon: %v", err)
+	}
+
+	if daemonPid != 0 {
+		return stopBusDaemon(daemonPid, drainTimeout)
+	}
+
+	return stopBus(drainTimeout)
+}
+
+func stopBus(drainTimeout time.Duration) error {
+	isRunning, err := isBusRunning()
+	if err != nil && !docker_client.IsErrConnectionFailed(err) {
+		return fmt.Errorf("can't inspect bus container: %v", err)
+	}
+
+	// bus container can't be stopped without docker, processes of bus on host are stopped anyway
+	isDockerUnreachable := err != nil
+	if isDockerUnreachable {
+		utils.Warningf("Docker isn't reachable, bus container isn't stopped: %v\n", err)
+	}
+
+	err = stopBusMetrics()
+	if err != nil {
+		utils.Warningf("Can't stop bus metrics: %v\n", err)
+	}
+
+	err = stopBusProcess(schedulerProcess)
+	if err != nil {
+		utils.Warningf("Can't stop scheduler of delayed messages: %v\n", err)
+	}
+
+	err = stopBusProcess(protocolProcess)
+	if err != nil {
+		utils.Warningf("Can't stop protocol exchange: %v\n", err)
+	}
+
+	if isDockerUnreachable {
+		return nil
+	}
+
+	if !isRunning {
+		utils.Infof("Bus isn't running")
+		return nil
+	}
+
+	if utils.IsDryRun() {
+		printBusStop(drainTimeout)
+		return nil
+	}
+
+	err = stopBusContainer(drainTimeout)
+	if err != nil {
+		return err
+	}
+
+	utils.EmitEvent(utils.EventBusDown, "bus", "container is stopped")
+	return nil
+}
+
+// stopBusContainer puts bus into lame duck mode and waits until clients leave it
+func stopBusContainer(drainTimeout time.Duration) error {
+	ctx := context.Background()
+	client, err := docker_client.NewEnvClient()
+
+	if err != nil {
+		return fmt.Errorf("can't connect to docker service: %v", err)
+	}
+
+	defer client.Close()
+
+	utils.Infof("Draining bus connections...")
+
+	err = client.ContainerKill(ctx, utils.GetBusContainerName(), busDrainSignal)
+	if err != nil {
+		return fmt.Errorf("can't drain bus: %v", err)
+	}
+
+	deadline := time.Now().Add(drainTimeout)
+	for time.Now().Before(deadline) {
+		isRunning, err := isBusRunning()
+		if err != nil || !isRunning {
+			return err
+		}
+
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	utils.Infof("Stopping bus")
+
+	err = client.ContainerStop(ctx, utils.GetBusContainerName(), nil)
+	if err != nil && !docker_client.IsErrContainerNotFound(err) {
+		return fmt.Errorf("can't stop bus: %v", err)
+	}
+
+	return nil
+}
+
+// printBusStop prints what is done to stop bus container
+func printBusStop(drainTimeout time.Duration) {
+	container := utils.GetBusContainerName()
+
+	utils.DryRunf("send %v to bus container %v and wait up to %v for connections drain\n", busDrainSignal, container, drainTimeout)
+	utils.DryRunf("stop bus container %v\n", container)
+}
+
+type BusClient struct {
+	Instance      string `json:"instance"`
+	Address       string `json:"address"`
+	Subscriptions uint32 `json:"subscriptions"`
+	InMessages    uint64 `json:"inMessages"`
+	OutMessages   uint64 `json:"outMessages"`
+}
+
+type BusStatus struct {
+	IsRunning     bool        `json:"isRunning"`
+	Address       string      `json:"address"`
+	Version       string      `json:"version"`
+	UptimeSeconds float64     `json:"uptimeSeconds"`
+	Subscriptions uint32      `json:"subscriptions"`
+	Clients       []BusClient `json:"clients"`
+
+	// Protocol is version of protocol between cube executors and bus
+	Protocol int `json:"protocol"`
+
+	// MonitoringError is set when bus is running, but its clients can't be read
+	MonitoringError string `json:"monitoringError,omitempty"`
+
+	// DaemonPid and DaemonLog are set when bus is started with --daemon
+	DaemonPid int    `json:"daemonPid,omitempty"`
+	DaemonLog string `json:"daemonLog,omitempty"`
+}
+
+// getInstancesAddresses returns names of instances by addresses of their containers
+func getInstancesAddresses() map[string]string {
+	result := map[string]string{}
+
+	configs, err := instance.GetList()
+	if err != nil {
+		return result
+	}
+
+	for _, config := range *configs {
+		containerInfo, err := utils.InspectContainer(config.Name)
+		if err != nil || containerInfo == nil || containerInfo.NetworkSettings == nil {
+			continue
+		}
+
+		for _, network := range containerInfo.NetworkSettings.Networks {
+			if network != nil && network.IPAddress != "" {
+				result[network.IPAddress] = config.Name
+			}
+		}
+	}
+
+	return result
+}
+
+func GetBusStatus() (*BusStatus, error) {
+	containerInfo, err := utils.InspectContainer(utils.GetBusContainerName())
+	if err != nil {
+		return nil, fmt.Errorf("can't inspect bus container: %v", err)
+	}
+
+	status := BusStatus{
+		Clients: []BusClient{},
+	}
+
+	// daemon is running while bus image is pulled too
+	status.DaemonPid, status.DaemonLog, err = getBusDaemonStatus()
+	if err != nil {
+		return nil, fmt.Errorf("can't read pid of bus daemon: %v", err)
+	}
+
+	if containerInfo == nil || containerInfo.State == nil || !containerInfo.State.Running {
+		return &status, nil
+	}
+
+	status.IsRunning = true
+	status.Address = "localhost:" + utils.GetBusHostPort()
+	status.Protocol = utils.GetBusProtocol()
+
+	startedAt, err := time.Parse(time.RFC3339Nano, containerInfo.State.StartedAt)
+	if err == nil {
+		status.UptimeSeconds = time.Since(startedAt).Seconds()
+	}
+
+	serverInfo, err := utils.GetBusServerInfo()
+	if err != nil {
+		status.MonitoringError = err.Error()
+		return &status, nil
+	}
+
+	status.Version = serverInfo.Version
+	status.Subscriptions = serverInfo.Subscriptions
+
+	connections, err := utils.GetBusConnections()
+	if err != nil {
+		status.MonitoringError = err.Error()
+		return &status, nil
+	}
+
+	instancesAddresses := getInstancesAddresses()
+
+	for _, connection := range *connections {
+		status.Clients = append(status.Clients, BusClient{
+			Instance:      instancesAddresses[connection.IP],
+			Address:       connection.IP + ":" + strconv.Itoa(connection.Port),
+			Subscriptions: connection.Subscriptions,
+			InMessages:    connection.InMessages,
+			OutMessages:   connection.OutMessages,
+		})
+	}
+
+	return &status, nil
+}
+
+// getBusTLSOptions returns bus arguments and binds of certificates directory when bus TLS is enabled
+func getBusTLSOptions() ([]string, []string, error) {
+	isEnabled, err := utils.IsBusTLSEnabled()
+	if err != nil || !isEnabled {
+		return []string{}, []string{}, err
+	}
+
+	directory, err := utils.GetBusTLSDirectoryPath()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	args := []string{
+		"--tls",
+		"--tlscert", path.Join(utils.BusCertsPath, utils.BusTLSServerCertFile),
+		"--tlskey", path.Join(utils.BusCertsPath, utils.BusTLSServerKeyFile),
+	}
+
+	isClientVerified, err := utils.IsBusClientVerified()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if isClientVerified {
+		args = append(args, "--tlsverify", "--tlscacert", path.Join(utils.BusCertsPath, utils.BusTLSCAFile))
+	}
+
+	utils.Infof("Bus TLS is enabled, clients verification: %v\n", isClientVerified)
+	return args, []string{directory + ":" + utils.BusCertsPath + ":ro"}, nil
+}
+
+const busAuthConfigPath = "/etc/cubes-bus/auth.conf"
+
+// getBusAuthOptions writes users of all instances and returns bus arguments and bind of users config when bus auth is enabled
+func getBusAuthOptions(config ProjectConfig) ([]string, []string, error) {
+	if !config.IsBusAuthEnabled {
+		return []string{}, []string{}, nil
+	}
+
+	err := instance.WriteBusAuthConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	authConfigPath, err := instance.GetBusAuthConfigPath()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	utils.Infof("Bus auth is enabled")
+	return []string{"-c", busAuthConfigPath}, []string{authConfigPath + ":" + busAuthConfigPath + ":ro"}, nil
+}
+
+// startBusProtocol saves protocol of started bus, warns about running instances, which are too old or too new for it,
+// and starts answering protocol exchanges of instances
+func startBusProtocol() error {
+	err := utils.WriteBusProtocol()
+	if err != nil {
+		return fmt.Errorf("can't save bus protocol: %v", err)
+	}
+
+	instance.CheckRunningBusProtocol()
+	return startProtocolExchange()
+}
+
+func checkBusPorts(config ProjectConfig) error {
+	for _, port := range []int{config.BusPort, config.BusMonitoringPort} {
+		if port < 0 || port > 65535 {
+			return fmt.Errorf("wrong bus port %v", port)
+		}
+	}
+
+	busHostPort := utils.GetBusHostPort()
+	if busHostPort == utils.GetBusMonitoringHostPort() {
+		return fmt.Errorf("bus and bus monitoring can't use the same port %v", busHostPort)
+	}
+
+	return nil
+}
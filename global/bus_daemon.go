@@ -0,0 +1,138 @@
+package global
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/akaumov/cubes/utils"
+)
+
+const busDaemonProcess = "daemon"
+
+// busCheckInterval is how often bus daemon checks that bus container is running
+const busCheckInterval = 5 * time.Second
+
+// busDaemonStopGrace is how long bus stop waits for bus daemon after drain timeout
+const busDaemonStopGrace = 30 * time.Second
+
+// RunBus starts bus and keeps it until Ctrl+C or SIGTERM, bus is drained and stopped then. It returns error when
+// bus container is stopped by anything else.
+func RunBus(drainTimeout time.Duration) error {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+
+	err := StartBus()
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(busCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-interrupt:
+			utils.Infof("Stopping bus")
+			return stopBus(drainTimeout)
+		case <-ticker.C:
+			isRunning, err := isBusRunning()
+			if err != nil {
+				utils.Warningf("Can't inspect bus container: %v\n", err)
+				continue
+			}
+
+			if !isRunning {
+				// processes started with bus are stopped with it
+				stopBus(drainTimeout)
+				return fmt.Errorf("bus container is stopped")
+			}
+		}
+	}
+}
+
+// StartBusDaemon runs "cubes bus run" in background, so bus start doesn't keep terminal, its pid and log are
+// in .cubes/bus
+func StartBusDaemon(drainTimeout time.Duration) error {
+	pid, err := getBusProcessPid(busDaemonProcess)
+	if err != nil {
+		return err
+	}
+
+	if pid != 0 {
+		utils.Infof("Bus daemon is already running with pid %v\n", pid)
+		return nil
+	}
+
+	if utils.IsDryRun() {
+		utils.DryRunf("run \"cubes bus run\" in background\n")
+		return nil
+	}
+
+	err = startBusProcess(busDaemonProcess, "bus", "run", "--timeout", strconv.Itoa(int(drainTimeout.Seconds())))
+	if err != nil {
+		return fmt.Errorf("can't start bus daemon: %v", err)
+	}
+
+	pid, err = getBusProcessPid(busDaemonProcess)
+	if err != nil {
+		return err
+	}
+
+	logPath, err := getBusProcessLogPath(busDaemonProcess)
+	if err != nil {
+		return err
+	}
+
+	utils.Infof("Bus is started in background with pid %v, its log is %v\n", pid, logPath)
+	return nil
+}
+
+// stopBusDaemon stops bus daemon, which drains and stops bus, and waits until it exits
+func stopBusDaemon(pid int, drainTimeout time.Duration) error {
+	err := stopBusProcess(busDaemonProcess)
+	if err != nil {
+		return fmt.Errorf("can't stop bus daemon: %v", err)
+	}
+
+	if utils.IsDryRun() {
+		return nil
+	}
+
+	deadline := time.Now().Add(drainTimeout + busDaemonStopGrace)
+
+	for time.Now().Before(deadline) {
+		isRunning, err := isBusProcess(busDaemonProcess, pid)
+		if err != nil {
+			return err
+		}
+
+		if !isRunning {
+			utils.Infof("Bus daemon with pid %v is stopped\n", pid)
+			return nil
+		}
+
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	return fmt.Errorf("bus daemon with pid %v is still stopping bus, see its log", pid)
+}
+
+// getBusDaemonStatus returns pid and log of bus daemon, pid is 0 when bus isn't started with --daemon
+func getBusDaemonStatus() (int, string, error) {
+	pid, err := getBusProcessPid(busDaemonProcess)
+	if err != nil || pid == 0 {
+		return 0, "", err
+	}
+
+	logPath, err := getBusProcessLogPath(busDaemonProcess)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return pid, logPath, nil
+}
@@ -0,0 +1,169 @@
+package global
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/akaumov/cubes/utils"
+	"github.com/nats-io/nats.go"
+)
+
+type channelCounters struct {
+	Messages uint64
+	Bytes    uint64
+}
+
+// busMetrics counts messages of every bus channel, bus monitoring has only totals
+type busMetrics struct {
+	mutex    sync.Mutex
+	channels map[string]*channelCounters
+}
+
+// isServiceChannel returns true for replies and bus api channels, which would flood metrics with unique names
+func isServiceChannel(channel string) bool {
+	return strings.HasPrefix(channel, "_INBOX.") || strings.HasPrefix(channel, "$") || strings.HasPrefix(channel, cubesChannelPrefix)
+}
+
+func (metrics *busMetrics) onMessage(message *nats.Msg) {
+	if isServiceChannel(message.Subject) {
+		return
+	}
+
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	counters, ok := metrics.channels[message.Subject]
+	if !ok {
+		counters = &channelCounters{}
+		metrics.channels[message.Subject] = counters
+	}
+
+	counters.Messages++
+	counters.Bytes += uint64(len(message.Data))
+}
+
+func (metrics *busMetrics) getChannels() (map[string]channelCounters, []string) {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	channels := map[string]channelCounters{}
+	names := []string{}
+
+	for name, counters := range metrics.channels {
+		channels[name] = *counters
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return channels, names
+}
+
+func (metrics *busMetrics) write(output io.Writer) {
+	serverInfo, err := utils.GetBusServerInfo()
+
+	fmt.Fprintln(output, "# HELP cubes_bus_up Whether the bus monitoring is reachable.")
+	fmt.Fprintln(output, "# TYPE cubes_bus_up gauge")
+	fmt.Fprintf(output, "cubes_bus_up %v\n", boolToMetric(err == nil))
+
+	if err == nil {
+		fmt.Fprintln(output, "# HELP cubes_bus_connections Number of clients connected to the bus.")
+		fmt.Fprintln(output, "# TYPE cubes_bus_connections gauge")
+		fmt.Fprintf(output, "cubes_bus_connections %v\n", serverInfo.Connections)
+
+		fmt.Fprintln(output, "# HELP cubes_bus_connections_total Number of connections accepted by the bus.")
+		fmt.Fprintln(output, "# TYPE cubes_bus_connections_total counter")
+		fmt.Fprintf(output, "cubes_bus_connections_total %v\n", serverInfo.TotalConnections)
+
+		fmt.Fprintln(output, "# HELP cubes_bus_subscriptions Number of subscriptions on the bus.")
+		fmt.Fprintln(output, "# TYPE cubes_bus_subscriptions gauge")
+		fmt.Fprintf(output, "cubes_bus_subscriptions %v\n", serverInfo.Subscriptions)
+
+		fmt.Fprintln(output, "# HELP cubes_bus_slow_consumers_total Number of clients disconnected for not reading messages fast enough.")
+		fmt.Fprintln(output, "# TYPE cubes_bus_slow_consumers_total counter")
+		fmt.Fprintf(output, "cubes_bus_slow_consumers_total %v\n", serverInfo.SlowConsumers)
+
+		fmt.Fprintln(output, "# HELP cubes_bus_messages_total Number of messages received and sent by the bus.")
+		fmt.Fprintln(output, "# TYPE cubes_bus_messages_total counter")
+		fmt.Fprintf(output, "cubes_bus_messages_total{direction=\"in\"} %v\n", serverInfo.InMessages)
+		fmt.Fprintf(output, "cubes_bus_messages_total{direction=\"out\"} %v\n", serverInfo.OutMessages)
+
+		fmt.Fprintln(output, "# HELP cubes_bus_bytes_total Number of bytes received and sent by the bus.")
+		fmt.Fprintln(output, "# TYPE cubes_bus_bytes_total counter")
+		fmt.Fprintf(output, "cubes_bus_bytes_total{direction=\"in\"} %v\n", serverInfo.InBytes)
+		fmt.Fprintf(output, "cubes_bus_bytes_total{direction=\"out\"} %v\n", serverInfo.OutBytes)
+	}
+
+	channels, names := metrics.getChannels()
+
+	fmt.Fprintln(output, "# HELP cubes_bus_channel_messages_total Number of messages published to the channel.")
+	fmt.Fprintln(output, "# TYPE cubes_bus_channel_messages_total counter")
+	for _, name := range names {
+		fmt.Fprintf(output, "cubes_bus_channel_messages_total{channel=%q} %v\n", name, channels[name].Messages)
+	}
+
+	fmt.Fprintln(output, "# HELP cubes_bus_channel_bytes_total Number of bytes published to the channel.")
+	fmt.Fprintln(output, "# TYPE cubes_bus_channel_bytes_total counter")
+	for _, name := range names {
+		fmt.Fprintf(output, "cubes_bus_channel_bytes_total{channel=%q} %v\n", name, channels[name].Bytes)
+	}
+}
+
+func boolToMetric(value bool) int {
+	if value {
+		return 1
+	}
+
+	return 0
+}
+
+// ServeBusMetrics serves prometheus metrics of bus, messages of channels are counted from start of serving
+func ServeBusMetrics(address string) error {
+	connection, err := connectRunningBus()
+	if err != nil {
+		return err
+	}
+
+	defer connection.Close()
+
+	metrics := busMetrics{
+		channels: map[string]*channelCounters{},
+	}
+
+	_, err = connection.Subscribe(">", metrics.onMessage)
+	if err != nil {
+		return fmt.Errorf("can't subscribe to bus channels: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		metrics.write(w)
+	})
+
+	utils.Infof("Serving bus metrics on %v/metrics\n", address)
+	return http.ListenAndServe(address, mux)
+}
+
+// startBusMetrics runs "cubes bus metrics" in background when project config has bus metrics address
+func startBusMetrics(config ProjectConfig) error {
+	if config.BusMetricsAddress == "" {
+		return nil
+	}
+
+	err := startBusProcess("metrics", "bus", "metrics", "--listen", config.BusMetricsAddress)
+	if err != nil {
+		return fmt.Errorf("can't start bus metrics: %v", err)
+	}
+
+	utils.Infof("Bus metrics are served on %v/metrics\n", config.BusMetricsAddress)
+	return nil
+}
+
+// stopBusMetrics stops bus metrics started with bus
+func stopBusMetrics() error {
+	return stopBusProcess("metrics")
+}
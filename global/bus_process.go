@@ -0,0 +1,182 @@
+package global
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/akaumov/cubes/utils"
+)
+
+// getBusProcessPidPath returns path of pid file of cubes process, which runs in background with bus
+func getBusProcessPidPath(name string) (string, error) {
+	busDirectory, err := utils.GetStateDirectoryPath("bus")
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(busDirectory, name+".pid"), nil
+}
+
+// getBusProcessLogPath returns path of log of cubes process, which runs in background with bus
+func getBusProcessLogPath(name string) (string, error) {
+	busDirectory, err := utils.GetStateDirectoryPath("bus")
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(busDirectory, name+".log"), nil
+}
+
+// startBusProcess runs cubes with args in background, its output is written to .cubes/bus/<name>.log
+func startBusProcess(name string, args ...string) error {
+	err := stopBusProcess(name)
+	if err != nil {
+		return err
+	}
+
+	executablePath, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	logPath, err := getBusProcessLogPath(name)
+	if err != nil {
+		return err
+	}
+
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	defer logFile.Close()
+
+	// log is rotated by process, output is kept for panics
+	command := exec.Command(executablePath, append([]string{"--log-file", logPath}, args...)...)
+	command.Stdout = logFile
+	command.Stderr = logFile
+
+	err = command.Start()
+	if err != nil {
+		return err
+	}
+
+	pidPath, err := getBusProcessPidPath(name)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(pidPath, []byte(strconv.Itoa(command.Process.Pid)), 0644)
+}
+
+// getBusProcessPid returns pid of cubes process started with bus, it's 0 when process isn't started
+func getBusProcessPid(name string) (int, error) {
+	pidPath, err := getBusProcessPidPath(name)
+	if err != nil {
+		return 0, err
+	}
+
+	rawPid, err := ioutil.ReadFile(pidPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+
+		return 0, err
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(rawPid)))
+	if err != nil {
+		return 0, fmt.Errorf("can't parse pid of bus %v: %v", name, err)
+	}
+
+	// pid is reused by other process after reboot or crash, it isn't signalled then
+	isBusProcess, err := isBusProcess(name, pid)
+	if err != nil || !isBusProcess {
+		return 0, err
+	}
+
+	return pid, nil
+}
+
+// getProcessCommandLine returns arguments of process joined by spaces, it's empty when process doesn't exist
+func getProcessCommandLine(pid int) (string, error) {
+	rawCommandLine, err := ioutil.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "cmdline"))
+	if err == nil {
+		return strings.Replace(string(rawCommandLine), "\x00", " ", -1), nil
+	}
+
+	if _, statErr := os.Stat("/proc/self"); statErr == nil {
+		return "", nil
+	}
+
+	// systems without procfs, ps fails when process doesn't exist
+	output, err := exec.Command("ps", "-o", "command=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return "", nil
+	}
+
+	return string(output), nil
+}
+
+// isBusProcess checks that process with pid is cubes process started with bus, it's told by its log file,
+// which belongs to process of project
+func isBusProcess(name string, pid int) (bool, error) {
+	logPath, err := getBusProcessLogPath(name)
+	if err != nil {
+		return false, err
+	}
+
+	commandLine, err := getProcessCommandLine(pid)
+	if err != nil {
+		return false, err
+	}
+
+	return strings.Contains(commandLine, "--log-file "+logPath+" "), nil
+}
+
+// stopBusProcess stops cubes process started with bus, pid file of process, which isn't running, is removed
+func stopBusProcess(name string) error {
+	pidPath, err := getBusProcessPidPath(name)
+	if err != nil {
+		return err
+	}
+
+	pid, err := getBusProcessPid(name)
+	if err != nil {
+		return err
+	}
+
+	if pid == 0 {
+		if !utils.IsDryRun() {
+			os.Remove(pidPath)
+		}
+
+		return nil
+	}
+
+	if utils.IsDryRun() {
+		utils.DryRunf("send %v to %v process with pid %v\n", os.Interrupt, name, pid)
+		utils.DryRunf("delete file %v\n", pidPath)
+		return nil
+	}
+
+	defer os.Remove(pidPath)
+
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return nil
+	}
+
+	err = process.Signal(os.Interrupt)
+	if err != nil && err != os.ErrProcessDone {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,188 @@
+package global
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/akaumov/cubes/instance"
+	"github.com/akaumov/cubes/utils"
+	docker_client "github.com/docker/docker/client"
+)
+
+// ChannelEndpoint is cube channel of instance which is mapped to bus channel
+type ChannelEndpoint struct {
+	Instance    string `json:"instance"`
+	CubeChannel string `json:"cubeChannel"`
+
+	// Direction is declared in cube meta, it's empty when meta doesn't have channel
+	Direction string `json:"direction"`
+
+	// IsSubscribed is set when bus is running and instance is subscribed to channel
+	IsSubscribed bool `json:"isSubscribed"`
+}
+
+type BusChannel struct {
+	Channel   string            `json:"channel"`
+	Endpoints []ChannelEndpoint `json:"endpoints"`
+
+	// Subscribers are connected instances and clients which are subscribed to channel, but it isn't in their mappings
+	Subscribers []string `json:"subscribers"`
+}
+
+type BusChannels struct {
+	IsLive   bool         `json:"isLive"`
+	Channels []BusChannel `json:"channels"`
+
+	// MonitoringError is set when bus is running, but its subscriptions can't be read
+	MonitoringError string `json:"monitoringError,omitempty"`
+}
+
+// isChannelMatched matches channel with subscription, which can have wildcards: * matches one token, > matches the rest
+func isChannelMatched(subscription string, channel string) bool {
+	subscriptionTokens := strings.Split(subscription, ".")
+	channelTokens := strings.Split(channel, ".")
+
+	for i, token := range subscriptionTokens {
+		if token == ">" {
+			return len(channelTokens) > i
+		}
+
+		if i >= len(channelTokens) || (token != "*" && token != channelTokens[i]) {
+			return false
+		}
+	}
+
+	return len(subscriptionTokens) == len(channelTokens)
+}
+
+// getInstanceChannels returns bus channels of instance by its cube channels, unmapped cube channels use bus channels with the same name
+func getInstanceChannels(config instance.Config, meta *instance.Meta) map[string]string {
+	result := map[string]string{}
+
+	if meta != nil {
+		for cubeChannel := range meta.Channels {
+			result[cubeChannel] = cubeChannel
+		}
+	}
+
+	for cubeChannel, busChannel := range config.ChannelsMapping {
+		result[string(cubeChannel)] = string(busChannel)
+	}
+
+	return result
+}
+
+func getChannel(channels map[string]*BusChannel, name string) *BusChannel {
+	channel, ok := channels[name]
+	if !ok {
+		channel = &BusChannel{
+			Channel:     name,
+			Endpoints:   []ChannelEndpoint{},
+			Subscribers: []string{},
+		}
+
+		channels[name] = channel
+	}
+
+	return channel
+}
+
+// GetBusChannels returns bus channels of instances mappings with instances using them, subscriptions are added when bus is running
+func GetBusChannels() (*BusChannels, error) {
+	configs, err := instance.GetList()
+	if err != nil {
+		return nil, err
+	}
+
+	channels := map[string]*BusChannel{}
+
+	for _, config := range *configs {
+		meta, err := instance.GetMeta(config)
+		if err != nil {
+			utils.Warningf("Can't read meta of %v: %v\n", config.Name, err)
+		}
+
+		for cubeChannel, busChannel := range getInstanceChannels(config, meta) {
+			direction := ""
+			if meta != nil {
+				direction = meta.Channels[cubeChannel].Direction
+			}
+
+			channel := getChannel(channels, busChannel)
+			channel.Endpoints = append(channel.Endpoints, ChannelEndpoint{
+				Instance:    config.Name,
+				CubeChannel: cubeChannel,
+				Direction:   direction,
+			})
+		}
+	}
+
+	result := BusChannels{
+		Channels: []BusChannel{},
+	}
+
+	isRunning, err := isBusRunning()
+	if err != nil && !docker_client.IsErrConnectionFailed(err) {
+		return nil, err
+	}
+
+	if isRunning {
+		result.MonitoringError = addSubscriptions(channels)
+		result.IsLive = result.MonitoringError == ""
+	}
+
+	for _, channel := range channels {
+		sort.Slice(channel.Endpoints, func(i, j int) bool {
+			return channel.Endpoints[i].Instance < channel.Endpoints[j].Instance
+		})
+
+		result.Channels = append(result.Channels, *channel)
+	}
+
+	sort.Slice(result.Channels, func(i, j int) bool {
+		return result.Channels[i].Channel < result.Channels[j].Channel
+	})
+
+	return &result, nil
+}
+
+// addSubscriptions marks subscribed endpoints and adds subscriptions, which aren't in mappings, it returns monitoring error
+func addSubscriptions(channels map[string]*BusChannel) string {
+	connections, err := utils.GetBusConnections()
+	if err != nil {
+		return err.Error()
+	}
+
+	instancesAddresses := getInstancesAddresses()
+
+	for _, connection := range *connections {
+		client := instancesAddresses[connection.IP]
+		if client == "" {
+			client = connection.IP
+		}
+
+		for _, subscription := range connection.SubscriptionsList {
+			isMapped := false
+
+			for _, channel := range channels {
+				if !isChannelMatched(subscription, channel.Channel) {
+					continue
+				}
+
+				for i, endpoint := range channel.Endpoints {
+					if endpoint.Instance == client {
+						channel.Endpoints[i].IsSubscribed = true
+						isMapped = isMapped || subscription == channel.Channel
+					}
+				}
+			}
+
+			if !isMapped {
+				channel := getChannel(channels, subscription)
+				channel.Subscribers = append(channel.Subscribers, client)
+			}
+		}
+	}
+
+	return ""
+}
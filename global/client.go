@@ -0,0 +1,140 @@
+package global
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/akaumov/cubes/utils"
+	"github.com/nats-io/nats.go"
+)
+
+// getRunningBusConfig returns project config, when bus is running, CLI fails at once otherwise
+func getRunningBusConfig() (*ProjectConfig, error) {
+	// remote bus isn't run here, it's checked by connecting to it
+	isRunning := utils.IsBusRemote()
+	if !isRunning {
+		isContainerRunning, err := isBusRunning()
+		if err != nil {
+			return nil, fmt.Errorf("can't inspect bus container: %v", err)
+		}
+
+		isRunning = isContainerRunning
+	}
+
+	if !isRunning {
+		return nil, fmt.Errorf("bus isn't running")
+	}
+
+	config, err := GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("can't read project config: %v", err)
+	}
+
+	return config, nil
+}
+
+// connectRunningBus connects to bus from CLI, it fails at once when bus isn't running
+func connectRunningBus() (*nats.Conn, error) {
+	config, err := getRunningBusConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	connection, err := connectBus(*config)
+	if err != nil {
+		return nil, fmt.Errorf("can't connect to bus: %v", err)
+	}
+
+	return connection, nil
+}
+
+// prepareMessage checks json message with schema of channel and encodes it with limits of channel, headers are sent
+// apart from message, so they aren't checked by schema
+func prepareMessage(channel string, data []byte, headers MessageHeaders) ([]byte, error) {
+	if channel == "" {
+		return nil, fmt.Errorf("channel is required")
+	}
+
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("message must be json")
+	}
+
+	err := checkMessageHeaders(headers)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ValidateMessage(channel, data)
+	if err != nil {
+		return nil, fmt.Errorf("message doesn't match schema of %v: %v", channel, err)
+	}
+
+	return encodeMessage(channel, data)
+}
+
+// Publish sends json message with headers to bus channel
+func Publish(channel string, data []byte, headers MessageHeaders) error {
+	data, err := prepareMessage(channel, data, headers)
+	if err != nil {
+		return err
+	}
+
+	connection, err := connectRunningBus()
+	if err != nil {
+		return err
+	}
+
+	defer connection.Close()
+
+	err = connection.PublishMsg(newBusMessage(channel, headers, data))
+	if err != nil {
+		return fmt.Errorf("can't publish message: %v", err)
+	}
+
+	return connection.Flush()
+}
+
+// Request sends json request with headers to bus channel and waits for response
+func Request(channel string, data []byte, headers MessageHeaders, timeout time.Duration) ([]byte, error) {
+	if channel == "" {
+		return nil, fmt.Errorf("channel is required")
+	}
+
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("request must be json")
+	}
+
+	err := checkMessageHeaders(headers)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ValidateMessage(channel, data)
+	if err != nil {
+		return nil, fmt.Errorf("request doesn't match schema of %v: %v", channel, err)
+	}
+
+	data, err = encodeMessage(channel, data)
+	if err != nil {
+		return nil, err
+	}
+
+	connection, err := connectRunningBus()
+	if err != nil {
+		return nil, err
+	}
+
+	defer connection.Close()
+
+	response, err := connection.RequestMsg(newBusMessage(channel, headers, data), timeout)
+	if err == nats.ErrTimeout {
+		return nil, fmt.Errorf("no response in %v", timeout)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("can't send request: %v", err)
+	}
+
+	return decompressMessage(response.Data)
+}
@@ -0,0 +1,133 @@
+package global
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+)
+
+const CompressionGzip = "gzip"
+
+// defaultCompressionThreshold is size of message, from which message is compressed, small messages only grow after gzip
+const defaultCompressionThreshold = 1024
+
+// gzipMagic starts every gzip message, json message can't start with it, so compressed messages are recognized without headers
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// ChannelLimit limits size of channel messages on bus and compresses large messages
+type ChannelLimit struct {
+	// MaxMessageSize is limit of message size in bytes on bus, after compression
+	MaxMessageSize int `json:"maxMessageSize,omitempty"`
+
+	// Compression is algorithm of compression: gzip, messages aren't compressed when it's empty
+	Compression string `json:"compression,omitempty"`
+
+	// CompressionThreshold is size of message in bytes, from which message is compressed
+	CompressionThreshold int `json:"compressionThreshold,omitempty"`
+}
+
+func checkChannelLimits(limits map[string]ChannelLimit) error {
+	for channel, limit := range limits {
+		if limit.MaxMessageSize < 0 || limit.CompressionThreshold < 0 {
+			return fmt.Errorf("limits of channel %v can't be negative", channel)
+		}
+
+		if limit.Compression != "" && limit.Compression != CompressionGzip {
+			return fmt.Errorf("unknown compression of channel %v: %v, only %v is supported", channel, limit.Compression, CompressionGzip)
+		}
+	}
+
+	return nil
+}
+
+func isMessageCompressed(data []byte) bool {
+	return bytes.HasPrefix(data, gzipMagic)
+}
+
+func compressMessage(data []byte) ([]byte, error) {
+	var compressed bytes.Buffer
+
+	writer := gzip.NewWriter(&compressed)
+
+	_, err := writer.Write(data)
+	if err != nil {
+		return nil, err
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return compressed.Bytes(), nil
+}
+
+// decompressMessage returns compressed message unpacked, other messages are returned as is
+func decompressMessage(data []byte) ([]byte, error) {
+	if !isMessageCompressed(data) {
+		return data, nil
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("can't decompress message: %v", err)
+	}
+
+	defer reader.Close()
+
+	decompressed, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("can't decompress message: %v", err)
+	}
+
+	return decompressed, nil
+}
+
+// decompressForDisplay unpacks compressed message, message is kept as is when it can't be unpacked
+func decompressForDisplay(data []byte) []byte {
+	decompressed, err := decompressMessage(data)
+	if err != nil {
+		return data
+	}
+
+	return decompressed
+}
+
+// applyChannelLimit compresses message when channel has compression and checks its size
+func applyChannelLimit(limit ChannelLimit, channel string, data []byte) ([]byte, error) {
+	threshold := limit.CompressionThreshold
+	if threshold == 0 {
+		threshold = defaultCompressionThreshold
+	}
+
+	if limit.Compression != "" && len(data) >= threshold {
+		compressed, err := compressMessage(data)
+		if err != nil {
+			return nil, fmt.Errorf("can't compress message: %v", err)
+		}
+
+		data = compressed
+	}
+
+	if limit.MaxMessageSize > 0 && len(data) > limit.MaxMessageSize {
+		return nil, fmt.Errorf("message of %v bytes exceeds limit of channel %v: %v bytes", len(data), channel, limit.MaxMessageSize)
+	}
+
+	return data, nil
+}
+
+// encodeMessage prepares message for bus channel: it's compressed and checked with limits of channel from project config
+func encodeMessage(channel string, data []byte) ([]byte, error) {
+	config, err := GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("can't read project config: %v", err)
+	}
+
+	err = checkChannelLimits(config.ChannelLimits)
+	if err != nil {
+		return nil, err
+	}
+
+	return applyChannelLimit(config.ChannelLimits[channel], channel, data)
+}
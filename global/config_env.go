@@ -0,0 +1,66 @@
+package global
+
+import (
+	"os"
+
+	"github.com/akaumov/cubes/db"
+	"github.com/akaumov/cubes/utils"
+)
+
+// environment variables of database connection, they override database of project config
+const (
+	envDatabaseHost     = "CUBES_DB_HOST"
+	envDatabasePort     = "CUBES_DB_PORT"
+	envDatabaseName     = "CUBES_DB_NAME"
+	envDatabaseUser     = "CUBES_DB_USER"
+	envDatabasePassword = "CUBES_DB_PASSWORD"
+)
+
+const defaultDatabasePort = 5432
+
+func isAnyEnvSet(names ...string) bool {
+	for _, name := range names {
+		if _, ok := os.LookupEnv(name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// applyConfigEnv replaces settings of project config with environment variables, so the same project.json
+// works on developer machine and in deployment
+func applyConfigEnv(config *ProjectConfig) error {
+	err := utils.LookupEnvInt(utils.EnvBusPort, &config.BusPort)
+	if err != nil {
+		return err
+	}
+
+	err = utils.LookupEnvInt(utils.EnvBusMonitoringPort, &config.BusMonitoringPort)
+	if err != nil {
+		return err
+	}
+
+	utils.LookupEnvString(utils.EnvBusHost, &config.BusHost)
+	utils.LookupEnvString(utils.EnvDefaultRuntime, &config.DefaultRuntime)
+	utils.LookupEnvString(utils.EnvMigrationsPath, &config.MigrationsPath)
+	utils.LookupEnvString(utils.EnvInstancesPath, &config.InstancesPath)
+
+	if config.Database == nil && isAnyEnvSet(envDatabaseHost, envDatabasePort, envDatabaseName, envDatabaseUser, envDatabasePassword) {
+		config.Database = &db.Config{
+			Host: "localhost",
+			Port: defaultDatabasePort,
+		}
+	}
+
+	if config.Database == nil {
+		return nil
+	}
+
+	utils.LookupEnvString(envDatabaseHost, &config.Database.Host)
+	utils.LookupEnvString(envDatabaseName, &config.Database.Name)
+	utils.LookupEnvString(envDatabaseUser, &config.Database.User)
+	utils.LookupEnvString(envDatabasePassword, &config.Database.Password)
+
+	return utils.LookupEnvInt(envDatabasePort, &config.Database.Port)
+}
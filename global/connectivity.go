@@ -0,0 +1,111 @@
+package global
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/akaumov/cubes/utils"
+)
+
+const (
+	BusConnected    = "connected"
+	BusDisconnected = "disconnected"
+)
+
+// maxBusConnectivityEvents is number of the latest events, which are returned for instance
+const maxBusConnectivityEvents = 20
+
+// BusConnectivityEvent is connect or disconnect of instance, they're read from bus, so they're known for instances,
+// which aren't run by cube executor, too
+type BusConnectivityEvent struct {
+	Time    time.Time `json:"time"`
+	Event   string    `json:"event"`
+	Address string    `json:"address"`
+
+	// Reason is why bus closed connection: Client Closed, Slow Consumer, Authorization Violation, etc
+	Reason string `json:"reason,omitempty"`
+}
+
+type BusConnectivity struct {
+	Instance    string `json:"instance"`
+	IsConnected bool   `json:"isConnected"`
+
+	// Events are the latest connects and disconnects, the oldest one is the first
+	Events []BusConnectivityEvent `json:"events"`
+
+	// MonitoringError is set when bus connections can't be read
+	MonitoringError string `json:"monitoringError,omitempty"`
+}
+
+// isInstanceConnection returns true when connection is opened by instance: it's from instance container
+// or executor named connection by instance
+func isInstanceConnection(name string, connection utils.BusConnection, instancesAddresses map[string]string) bool {
+	return connection.Name == name || instancesAddresses[connection.IP] == name
+}
+
+func getConnectionAddress(connection utils.BusConnection) string {
+	return connection.IP + ":" + strconv.Itoa(connection.Port)
+}
+
+// GetInstanceBusConnectivity returns whether instance is connected to bus and its latest connects and disconnects
+func GetInstanceBusConnectivity(name string) (*BusConnectivity, error) {
+	connectivity := BusConnectivity{
+		Instance: name,
+		Events:   []BusConnectivityEvent{},
+	}
+
+	connections, err := utils.GetBusConnections()
+	if err != nil {
+		connectivity.MonitoringError = err.Error()
+		return &connectivity, nil
+	}
+
+	closedConnections, err := utils.GetBusClosedConnections()
+	if err != nil {
+		connectivity.MonitoringError = err.Error()
+		return &connectivity, nil
+	}
+
+	instancesAddresses := getInstancesAddresses()
+
+	for _, connection := range *closedConnections {
+		if !isInstanceConnection(name, connection, instancesAddresses) || connection.Stop == nil {
+			continue
+		}
+
+		connectivity.Events = append(connectivity.Events, BusConnectivityEvent{
+			Time:    connection.Start,
+			Event:   BusConnected,
+			Address: getConnectionAddress(connection),
+		}, BusConnectivityEvent{
+			Time:    *connection.Stop,
+			Event:   BusDisconnected,
+			Address: getConnectionAddress(connection),
+			Reason:  connection.Reason,
+		})
+	}
+
+	for _, connection := range *connections {
+		if !isInstanceConnection(name, connection, instancesAddresses) {
+			continue
+		}
+
+		connectivity.IsConnected = true
+		connectivity.Events = append(connectivity.Events, BusConnectivityEvent{
+			Time:    connection.Start,
+			Event:   BusConnected,
+			Address: getConnectionAddress(connection),
+		})
+	}
+
+	sort.SliceStable(connectivity.Events, func(i, j int) bool {
+		return connectivity.Events[i].Time.Before(connectivity.Events[j].Time)
+	})
+
+	if len(connectivity.Events) > maxBusConnectivityEvents {
+		connectivity.Events = connectivity.Events[len(connectivity.Events)-maxBusConnectivityEvents:]
+	}
+
+	return &connectivity, nil
+}
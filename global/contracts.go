@@ -0,0 +1,351 @@
+package global
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/akaumov/cubes/instance"
+	"github.com/akaumov/cubes/utils"
+)
+
+// projectSchemaConsumer is name of consumer for schemas of channelSchemas, validator of project rejects messages, which don't match them
+const projectSchemaConsumer = "channelSchemas"
+
+// ContractProblem is reason, why message of producer can be rejected by consumer of bus channel
+type ContractProblem struct {
+	Channel  string `json:"channel"`
+	Producer string `json:"producer"`
+	Consumer string `json:"consumer"`
+	Message  string `json:"message"`
+}
+
+// ContractsReport is result of contracts check, Unchecked are pairs, which producer doesn't declare schema for
+type ContractsReport struct {
+	Checked   int               `json:"checked"`
+	Problems  []ContractProblem `json:"problems"`
+	Unchecked []ContractProblem `json:"unchecked"`
+}
+
+// contractEndpoint is cube channel of instance with schema of its messages, schema is nil when it isn't declared
+type contractEndpoint struct {
+	instance    string
+	cubeChannel string
+	busChannel  string
+	schema      *JSONSchema
+}
+
+func (endpoint contractEndpoint) getName() string {
+	if endpoint.cubeChannel == "" {
+		return endpoint.instance
+	}
+
+	return endpoint.instance + "." + endpoint.cubeChannel
+}
+
+func canSendType(types []string, jsonType string) bool {
+	if len(types) == 0 {
+		return true
+	}
+
+	for _, schemaType := range types {
+		if schemaType == jsonType || (schemaType == "integer" && jsonType == "number") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isTypeAccepted(types []string, jsonType string) bool {
+	for _, schemaType := range types {
+		if schemaType == jsonType || (schemaType == "number" && jsonType == "integer") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getValues returns values of const or enum, they're all values, which schema allows
+func (schema *JSONSchema) getValues() []interface{} {
+	if schema.Const != nil {
+		return []interface{}{schema.Const}
+	}
+
+	return schema.Enum
+}
+
+func getLimitProblem(path string, producer *int, consumer *int, isMinimum bool, what string) string {
+	if consumer == nil {
+		return ""
+	}
+
+	if isMinimum && (producer == nil || *producer < *consumer) {
+		return fmt.Sprintf("%v: producer can send less than %v %v", path, *consumer, what)
+	}
+
+	if !isMinimum && (producer == nil || *producer > *consumer) {
+		return fmt.Sprintf("%v: producer can send more than %v %v", path, *consumer, what)
+	}
+
+	return ""
+}
+
+// getSchemaProblems returns reasons, why message valid for producer schema can be rejected by consumer schema.
+// Constraint of consumer, which producer doesn't guarantee, is problem. Properties, which producer doesn't declare,
+// aren't compared, they're usually not sent.
+func getSchemaProblems(path string, producer *JSONSchema, consumer *JSONSchema) []string {
+	if consumer == nil {
+		return nil
+	}
+
+	if producer == nil {
+		producer = &JSONSchema{}
+	}
+
+	problems := []string{}
+
+	// producer of fixed values is compatible, when consumer accepts every value
+	if values := producer.getValues(); len(values) > 0 {
+		for _, value := range values {
+			err := consumer.validate(path, value)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("producer can send %v, but %v", value, err))
+			}
+		}
+
+		return problems
+	}
+
+	if len(consumer.getValues()) > 0 {
+		return append(problems, fmt.Sprintf("%v: producer can send any value, consumer expects one of %v", path, consumer.getValues()))
+	}
+
+	producerTypes := producer.getTypes()
+	consumerTypes := consumer.getTypes()
+
+	if len(consumerTypes) > 0 {
+		if len(producerTypes) == 0 {
+			problems = append(problems, fmt.Sprintf("%v: producer can send any type, consumer expects %v", path, consumer.Type))
+		}
+
+		for _, producerType := range producerTypes {
+			if !isTypeAccepted(consumerTypes, producerType) {
+				problems = append(problems, fmt.Sprintf("%v: producer can send %v, consumer expects %v", path, producerType, consumer.Type))
+			}
+		}
+	}
+
+	if canSendType(producerTypes, "number") {
+		if consumer.Minimum != nil && (producer.Minimum == nil || *producer.Minimum < *consumer.Minimum) {
+			problems = append(problems, fmt.Sprintf("%v: producer can send numbers below %v", path, *consumer.Minimum))
+		}
+
+		if consumer.Maximum != nil && (producer.Maximum == nil || *producer.Maximum > *consumer.Maximum) {
+			problems = append(problems, fmt.Sprintf("%v: producer can send numbers above %v", path, *consumer.Maximum))
+		}
+	}
+
+	if canSendType(producerTypes, "string") {
+		problems = append(problems, getLimitProblem(path, producer.MinLength, consumer.MinLength, true, "characters"))
+		problems = append(problems, getLimitProblem(path, producer.MaxLength, consumer.MaxLength, false, "characters"))
+
+		if consumer.Pattern != "" && producer.Pattern != consumer.Pattern {
+			problems = append(problems, fmt.Sprintf("%v: producer doesn't guarantee pattern %v", path, consumer.Pattern))
+		}
+	}
+
+	if canSendType(producerTypes, "array") {
+		problems = append(problems, getLimitProblem(path, producer.MinItems, consumer.MinItems, true, "items"))
+		problems = append(problems, getLimitProblem(path, producer.MaxItems, consumer.MaxItems, false, "items"))
+
+		if consumer.Items != nil {
+			problems = append(problems, getSchemaProblems(path+"[]", producer.Items, consumer.Items)...)
+		}
+	}
+
+	if canSendType(producerTypes, "object") {
+		problems = append(problems, getObjectProblems(path, producer, consumer)...)
+	}
+
+	result := []string{}
+	for _, problem := range problems {
+		if problem != "" {
+			result = append(result, problem)
+		}
+	}
+
+	return result
+}
+
+func getObjectProblems(path string, producer *JSONSchema, consumer *JSONSchema) []string {
+	problems := []string{}
+
+	for _, property := range consumer.Required {
+		isRequired := false
+		for _, producerProperty := range producer.Required {
+			if producerProperty == property {
+				isRequired = true
+			}
+		}
+
+		if !isRequired {
+			problems = append(problems, fmt.Sprintf("%v.%v: consumer requires it, producer can omit it", path, property))
+		}
+	}
+
+	isAdditionalAllowed := func(schema *JSONSchema) bool {
+		return schema.AdditionalProperties == nil || *schema.AdditionalProperties
+	}
+
+	keys := []string{}
+	for key := range producer.Properties {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	if !isAdditionalAllowed(consumer) {
+		if isAdditionalAllowed(producer) {
+			problems = append(problems, fmt.Sprintf("%v: producer can send other properties, consumer doesn't allow them", path))
+		}
+
+		for _, key := range keys {
+			if _, ok := consumer.Properties[key]; !ok {
+				problems = append(problems, fmt.Sprintf("%v.%v: producer can send it, consumer doesn't allow it", path, key))
+			}
+		}
+	}
+
+	for _, key := range keys {
+		consumerProperty, ok := consumer.Properties[key]
+		if !ok {
+			continue
+		}
+
+		problems = append(problems, getSchemaProblems(path+"."+key, producer.Properties[key], consumerProperty)...)
+	}
+
+	return problems
+}
+
+// getContractEndpoints returns producers and consumers of bus channels by cube meta of instances
+func getContractEndpoints() ([]contractEndpoint, []contractEndpoint, error) {
+	configs, err := instance.GetList()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	producers := []contractEndpoint{}
+	consumers := []contractEndpoint{}
+
+	for _, config := range *configs {
+		meta, err := instance.GetMeta(config)
+		if err != nil {
+			utils.Warningf("Can't read meta of %v, its contracts aren't checked: %v\n", config.Name, err)
+			continue
+		}
+
+		if meta == nil {
+			continue
+		}
+
+		channels := getInstanceChannels(config, meta)
+
+		for cubeChannel, channelMeta := range meta.Channels {
+			endpoint := contractEndpoint{
+				instance:    config.Name,
+				cubeChannel: cubeChannel,
+				busChannel:  channels[cubeChannel],
+			}
+
+			if len(channelMeta.Schema) > 0 {
+				endpoint.schema, err = ParseJSONSchema(channelMeta.Schema)
+				if err != nil {
+					return nil, nil, fmt.Errorf("wrong schema of channel %v of %v: %v", cubeChannel, config.Name, err)
+				}
+			}
+
+			switch channelMeta.Direction {
+			case instance.ChannelOut:
+				producers = append(producers, endpoint)
+			case instance.ChannelIn:
+				consumers = append(consumers, endpoint)
+			}
+		}
+	}
+
+	return producers, consumers, nil
+}
+
+// CheckContracts checks, that messages of every producer of bus channel are accepted by its consumers:
+// instances with in channels and schemas of channelSchemas of project config
+func CheckContracts() (*ContractsReport, error) {
+	config, err := GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("can't read project config: %v", err)
+	}
+
+	producers, consumers, err := getContractEndpoints()
+	if err != nil {
+		return nil, err
+	}
+
+	schemas, err := getChannelSchemas(*config)
+	if err != nil {
+		return nil, err
+	}
+
+	for channel, schema := range schemas {
+		consumers = append(consumers, contractEndpoint{
+			instance:   projectSchemaConsumer,
+			busChannel: channel,
+			schema:     schema,
+		})
+	}
+
+	sort.Slice(producers, func(i, j int) bool {
+		return producers[i].getName() < producers[j].getName()
+	})
+
+	sort.Slice(consumers, func(i, j int) bool {
+		return consumers[i].getName() < consumers[j].getName()
+	})
+
+	report := ContractsReport{
+		Problems:  []ContractProblem{},
+		Unchecked: []ContractProblem{},
+	}
+
+	for _, producer := range producers {
+		for _, consumer := range consumers {
+			if consumer.schema == nil || !isChannelMatched(consumer.busChannel, producer.busChannel) {
+				continue
+			}
+
+			if producer.schema == nil {
+				report.Unchecked = append(report.Unchecked, ContractProblem{
+					Channel:  producer.busChannel,
+					Producer: producer.getName(),
+					Consumer: consumer.getName(),
+					Message:  "producer doesn't declare schema",
+				})
+
+				continue
+			}
+
+			report.Checked++
+
+			for _, problem := range getSchemaProblems("$", producer.schema, consumer.schema) {
+				report.Problems = append(report.Problems, ContractProblem{
+					Channel:  producer.busChannel,
+					Producer: producer.getName(),
+					Consumer: consumer.getName(),
+					Message:  problem,
+				})
+			}
+		}
+	}
+
+	return &report, nil
+}
@@ -0,0 +1,256 @@
+package global
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/akaumov/cubes/utils"
+)
+
+const dashboardMessagesLimit = 50
+const dashboardMessageSize = 1024
+
+// dashboardSamplePeriod is minimal time between sampled messages of the same channel
+const dashboardSamplePeriod = time.Second
+
+type DashboardMessage struct {
+	Channel     string    `json:"channel"`
+	ReceivedAt  time.Time `json:"receivedAt"`
+	Data        string    `json:"data"`
+	IsTruncated bool      `json:"isTruncated"`
+}
+
+type DashboardChannel struct {
+	Channel  string `json:"channel"`
+	Messages uint64 `json:"messages"`
+	Bytes    uint64 `json:"bytes"`
+}
+
+// dashboard keeps counters of channels and recent messages, sampled not more often than once in sample period for every channel
+type dashboard struct {
+	metrics busMetrics
+
+	mutex        sync.Mutex
+	messages     []DashboardMessage
+	lastSampleAt map[string]time.Time
+}
+
+func (board *dashboard) onMessage(message *nats.Msg) {
+	board.metrics.onMessage(message)
+
+	if isServiceChannel(message.Subject) {
+		return
+	}
+
+	board.mutex.Lock()
+	defer board.mutex.Unlock()
+
+	now := time.Now()
+	if now.Sub(board.lastSampleAt[message.Subject]) < dashboardSamplePeriod {
+		return
+	}
+
+	board.lastSampleAt[message.Subject] = now
+
+	data := decompressForDisplay(message.Data)
+	isTruncated := len(data) > dashboardMessageSize
+	if isTruncated {
+		data = data[:dashboardMessageSize]
+	}
+
+	board.messages = append(board.messages, DashboardMessage{
+		Channel:     message.Subject,
+		ReceivedAt:  now,
+		Data:        string(data),
+		IsTruncated: isTruncated,
+	})
+
+	if len(board.messages) > dashboardMessagesLimit {
+		board.messages = board.messages[len(board.messages)-dashboardMessagesLimit:]
+	}
+}
+
+func (board *dashboard) getMessages() []DashboardMessage {
+	board.mutex.Lock()
+	defer board.mutex.Unlock()
+
+	return append([]DashboardMessage{}, board.messages...)
+}
+
+func (board *dashboard) getChannels() []DashboardChannel {
+	counters, names := board.metrics.getChannels()
+	result := []DashboardChannel{}
+
+	for _, name := range names {
+		result = append(result, DashboardChannel{
+			Channel:  name,
+			Messages: counters[name].Messages,
+			Bytes:    counters[name].Bytes,
+		})
+	}
+
+	return result
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}, err error) {
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
+
+// ServeBusDashboard serves web page with live throughput of channels, connected instances and recent messages
+func ServeBusDashboard(address string) error {
+	connection, err := connectRunningBus()
+	if err != nil {
+		return err
+	}
+
+	defer connection.Close()
+
+	board := dashboard{
+		metrics: busMetrics{
+			channels: map[string]*channelCounters{},
+		},
+		messages:     []DashboardMessage{},
+		lastSampleAt: map[string]time.Time{},
+	}
+
+	_, err = connection.Subscribe(">", board.onMessage)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(dashboardPage))
+	})
+
+	mux.HandleFunc("/api/status", func(w http.ResponseWriter, r *http.Request) {
+		status, err := GetBusStatus()
+		writeJSON(w, status, err)
+	})
+
+	mux.HandleFunc("/api/channels", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, board.getChannels(), nil)
+	})
+
+	mux.HandleFunc("/api/messages", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, board.getMessages(), nil)
+	})
+
+	utils.Infof("Serving bus dashboard on http://%v\n", address)
+	return http.ListenAndServe(address, mux)
+}
+
+// dashboardPage polls api and computes throughput of channels from difference of counters
+const dashboardPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>cubes bus</title>
+<style>
+  body { font-family: sans-serif; margin: 20px; color: #222; }
+  h2 { margin-top: 28px; }
+  table { border-collapse: collapse; min-width: 600px; }
+  th, td { text-align: left; padding: 4px 12px; border-bottom: 1px solid #ddd; }
+  th { background: #f4f4f4; }
+  pre { margin: 0; max-width: 800px; white-space: pre-wrap; word-break: break-all; }
+  .muted { color: #888; }
+</style>
+</head>
+<body>
+<h1>cubes bus</h1>
+<div id="status" class="muted">loading...</div>
+
+<h2>Channels</h2>
+<table>
+  <thead><tr><th>Channel</th><th>Messages/s</th><th>KB/s</th><th>Messages</th></tr></thead>
+  <tbody id="channels"></tbody>
+</table>
+
+<h2>Instances</h2>
+<table>
+  <thead><tr><th>Instance</th><th>Address</th><th>Subscriptions</th><th>In</th><th>Out</th></tr></thead>
+  <tbody id="clients"></tbody>
+</table>
+
+<h2>Recent messages</h2>
+<table>
+  <thead><tr><th>Time</th><th>Channel</th><th>Message</th></tr></thead>
+  <tbody id="messages"></tbody>
+</table>
+
+<script>
+var interval = 2000;
+var previous = {};
+
+function text(value) {
+  var element = document.createElement("div");
+  element.textContent = value;
+  return element.innerHTML;
+}
+
+function row(cells) {
+  return "<tr>" + cells.map(function (cell) { return "<td>" + cell + "</td>"; }).join("") + "</tr>";
+}
+
+function load(path, render) {
+  fetch(path).then(function (response) { return response.json(); }).then(render).catch(function () {});
+}
+
+function refresh() {
+  load("/api/status", function (status) {
+    document.getElementById("status").textContent = status.isRunning
+      ? "running on " + status.address + ", version " + status.version + ", " + status.clients.length + " clients, " + status.subscriptions + " subscriptions"
+      : "bus is stopped";
+
+    document.getElementById("clients").innerHTML = status.clients.map(function (client) {
+      return row([text(client.instance || "-"), text(client.address), client.subscriptions, client.inMessages, client.outMessages]);
+    }).join("");
+  });
+
+  load("/api/channels", function (channels) {
+    var current = {};
+
+    document.getElementById("channels").innerHTML = channels.map(function (channel) {
+      var last = previous[channel.channel] || channel;
+      current[channel.channel] = channel;
+
+      var messagesRate = (channel.messages - last.messages) * 1000 / interval;
+      var bytesRate = (channel.bytes - last.bytes) * 1000 / interval / 1024;
+
+      return row([text(channel.channel), messagesRate.toFixed(1), bytesRate.toFixed(1), channel.messages]);
+    }).join("");
+
+    previous = current;
+  });
+
+  load("/api/messages", function (messages) {
+    document.getElementById("messages").innerHTML = messages.reverse().map(function (message) {
+      var data = message.data + (message.isTruncated ? "..." : "");
+      return row([new Date(message.receivedAt).toLocaleTimeString(), text(message.channel), "<pre>" + text(data) + "</pre>"]);
+    }).join("");
+  });
+}
+
+refresh();
+setInterval(refresh, interval);
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,225 @@
+package global
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/akaumov/cubes/utils"
+	"github.com/nats-io/nats.go"
+)
+
+// cubesChannelPrefix starts channels, which are used by cubes itself
+const cubesChannelPrefix = "_cubes."
+
+const delayedStream = "CUBES_DELAYED"
+const delayedChannelPrefix = cubesChannelPrefix + "delayed."
+
+const schedulerProcess = "scheduler"
+const schedulerConsumer = "cubes-scheduler"
+const schedulerChannel = cubesChannelPrefix + "scheduler"
+
+// schedulerMaxPending is number of delayed messages, which wait for delivery at once
+const schedulerMaxPending = 1000000
+
+// delayedMessage is kept in stream of delayed messages until it's time to deliver it to channel
+type delayedMessage struct {
+	Channel   string         `json:"channel"`
+	DeliverAt time.Time      `json:"deliverAt"`
+	Headers   MessageHeaders `json:"headers,omitempty"`
+	Data      []byte         `json:"data"`
+}
+
+func checkDelayedDelivery(config ProjectConfig) error {
+	if !config.IsDelayedDeliveryEnabled {
+		return fmt.Errorf("delayed delivery is disabled, set isDelayedDeliveryEnabled in project.json and restart bus")
+	}
+
+	return nil
+}
+
+// PublishDelayed keeps json message on bus and delivers it to channel at deliverAt, message is delivered even if bus
+// is restarted in between
+func PublishDelayed(channel string, data []byte, headers MessageHeaders, deliverAt time.Time) error {
+	config, err := GetConfig()
+	if err != nil {
+		return fmt.Errorf("can't read project config: %v", err)
+	}
+
+	err = checkDelayedDelivery(*config)
+	if err != nil {
+		return err
+	}
+
+	data, err = prepareMessage(channel, data, headers)
+	if err != nil {
+		return err
+	}
+
+	packedMessage, err := json.Marshal(delayedMessage{
+		Channel:   channel,
+		DeliverAt: deliverAt.UTC(),
+		Headers:   headers,
+		Data:      data,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	connection, err := connectRunningBus()
+	if err != nil {
+		return err
+	}
+
+	defer connection.Close()
+
+	// stream acknowledges stored messages, so message isn't lost silently
+	rawResponse, err := connection.Request(delayedChannelPrefix+channel, packedMessage, busRequestTimeout)
+	if err != nil {
+		return fmt.Errorf("can't publish delayed message: %v", err)
+	}
+
+	var response streamResponse
+	err = json.Unmarshal(rawResponse.Data, &response)
+	if err != nil {
+		return fmt.Errorf("can't parse bus response: %v", err)
+	}
+
+	if response.Error != nil {
+		return fmt.Errorf("can't publish delayed message: %v", response.Error.Description)
+	}
+
+	return nil
+}
+
+// startDelayedDelivery runs "cubes bus scheduler" in background when delayed delivery is enabled
+func startDelayedDelivery(config ProjectConfig) error {
+	if !config.IsDelayedDeliveryEnabled {
+		return nil
+	}
+
+	err := startBusProcess(schedulerProcess, "bus", "scheduler")
+	if err != nil {
+		return fmt.Errorf("can't start scheduler of delayed messages: %v", err)
+	}
+
+	utils.Infof("Delayed delivery is enabled")
+	return nil
+}
+
+// createDurableConsumer creates consumer of stream, which keeps its position on bus between restarts
+func createDurableConsumer(connection *nats.Conn, stream string, config consumerConfig) error {
+	rawRequest, err := json.Marshal(createConsumerRequest{StreamName: stream, Config: config})
+	if err != nil {
+		return err
+	}
+
+	rawResponse, err := connection.Request("$JS.API.CONSUMER.DURABLE.CREATE."+stream+"."+config.DurableName, rawRequest, busRequestTimeout)
+	if err != nil {
+		return err
+	}
+
+	var response consumerResponse
+	err = json.Unmarshal(rawResponse.Data, &response)
+	if err != nil {
+		return fmt.Errorf("can't parse bus response: %v", err)
+	}
+
+	if response.Error != nil {
+		return errors.New(response.Error.Description)
+	}
+
+	return nil
+}
+
+// acknowledge answers bus about stored message: +ACK, -NAK or +TERM
+func acknowledge(connection *nats.Conn, message *nats.Msg, answer string) error {
+	return connection.Publish(message.Reply, []byte(answer))
+}
+
+// deliverDelayed publishes message to its channel when it's time, otherwise bus redelivers message to scheduler later
+func deliverDelayed(connection *nats.Conn, message *nats.Msg) error {
+	var delayed delayedMessage
+
+	err := json.Unmarshal(message.Data, &delayed)
+	if err != nil {
+		// broken message would be redelivered forever
+		acknowledge(connection, message, "+TERM")
+		return fmt.Errorf("can't parse delayed message: %v", err)
+	}
+
+	delay := time.Until(delayed.DeliverAt)
+	if delay > 0 {
+		return acknowledge(connection, message, fmt.Sprintf("-NAK {\"delay\": %v}", int64(delay)))
+	}
+
+	err = connection.PublishMsg(newBusMessage(delayed.Channel, delayed.Headers, delayed.Data))
+	if err == nil {
+		err = connection.Flush()
+	}
+
+	if err != nil {
+		acknowledge(connection, message, "-NAK")
+		return fmt.Errorf("can't deliver delayed message to %v: %v", delayed.Channel, err)
+	}
+
+	return acknowledge(connection, message, "+ACK")
+}
+
+// ServeDelayedDelivery delivers delayed messages to their channels until Ctrl+C is pressed, it's started with bus
+func ServeDelayedDelivery() error {
+	config, err := GetConfig()
+	if err != nil {
+		return fmt.Errorf("can't read project config: %v", err)
+	}
+
+	err = checkDelayedDelivery(*config)
+	if err != nil {
+		return err
+	}
+
+	connection, err := connectBus(*config)
+	if err != nil {
+		return fmt.Errorf("can't connect to bus: %v", err)
+	}
+
+	defer connection.Close()
+
+	err = createDurableConsumer(connection, delayedStream, consumerConfig{
+		DeliverSubject: schedulerChannel,
+		DeliverPolicy:  "all",
+		AckPolicy:      "explicit",
+		ReplayPolicy:   "instant",
+		FilterSubject:  delayedChannelPrefix + ">",
+		DurableName:    schedulerConsumer,
+		MaxAckPending:  schedulerMaxPending,
+	})
+
+	if err != nil {
+		return fmt.Errorf("can't read delayed messages: %v", err)
+	}
+
+	_, err = connection.Subscribe(schedulerChannel, func(message *nats.Msg) {
+		err := deliverDelayed(connection, message)
+		if err != nil {
+			utils.Warningf("%v", err)
+		}
+	})
+
+	if err != nil {
+		return fmt.Errorf("can't subscribe to delayed messages: %v", err)
+	}
+
+	utils.Infof("Delivering delayed messages")
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	<-interrupt
+	return nil
+}
@@ -0,0 +1,225 @@
+package global
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	docker_client "github.com/docker/docker/client"
+
+	"github.com/akaumov/cubes/db"
+	"github.com/akaumov/cubes/instance"
+	"github.com/akaumov/cubes/utils"
+)
+
+// statuses of doctor checks, doctor exits with error when any check is failed
+const (
+	CheckOk      = "ok"
+	CheckWarning = "warning"
+	CheckFailed  = "failed"
+	CheckSkipped = "skipped"
+)
+
+// DoctorCheck is result of one check of environment, Fix tells how to solve problem
+type DoctorCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Fix     string `json:"fix,omitempty"`
+}
+
+type DoctorReport struct {
+	Checks []DoctorCheck `json:"checks"`
+	Failed int           `json:"failed"`
+}
+
+func (report *DoctorReport) add(name string, status string, message string, fix string) {
+	report.Checks = append(report.Checks, DoctorCheck{
+		Name:    name,
+		Status:  status,
+		Message: message,
+		Fix:     fix,
+	})
+
+	if status == CheckFailed {
+		report.Failed++
+	}
+}
+
+func checkDoctorProjectConfig(report *DoctorReport) *ProjectConfig {
+	configPath, err := getProjectConfigPath()
+	if err != nil {
+		report.add("project config", CheckFailed, err.Error(), "")
+		return nil
+	}
+
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		report.add("project config", CheckFailed, configPath+" doesn't exist", "run 'cubes init' in project directory")
+		return nil
+	}
+
+	config, err := GetConfig()
+	if err != nil {
+		report.add("project config", CheckFailed, err.Error(), "fix "+configPath+" or environment overrides of it")
+		return nil
+	}
+
+	message := "project " + config.Name + " is read"
+	if profile := utils.GetProfile(); profile != "" {
+		message += " with profile " + profile
+	}
+
+	report.add("project config", CheckOk, message, "")
+	return config
+}
+
+func checkDoctorMigrations(report *DoctorReport) {
+	migrations, err := db.GetList()
+	if err != nil {
+		report.add("migrations", CheckFailed, err.Error(), "fix or remove broken migration file")
+		return
+	}
+
+	report.add("migrations", CheckOk, fmt.Sprintf("%v migrations are parsed", len(*migrations)), "")
+}
+
+func checkDoctorDatabase(report *DoctorReport, config *ProjectConfig) {
+	if config == nil {
+		report.add("database", CheckSkipped, "project config isn't read", "")
+		return
+	}
+
+	if config.Database == nil {
+		report.add("database", CheckSkipped, "database isn't set", "add database to project.json or set CUBES_DB_HOST and CUBES_DB_NAME")
+		return
+	}
+
+	address := fmt.Sprintf("%v:%v", config.Database.Host, config.Database.Port)
+
+	err := db.Ping(*config.Database)
+	if err != nil {
+		report.add("database", CheckFailed, err.Error(), "start database on "+address+" or fix database in project.json")
+		return
+	}
+
+	report.add("database", CheckOk, config.Database.Name+" on "+address+" is reachable", "")
+}
+
+// isPortFree returns true when nothing listens on port of host
+func isPortFree(port string) bool {
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return false
+	}
+
+	listener.Close()
+	return true
+}
+
+func checkDoctorBus(report *DoctorReport) {
+	if utils.IsBusRemote() {
+		connection, err := connectRunningBus()
+		if err != nil {
+			report.add("bus", CheckFailed, err.Error(), "check that bus on "+utils.GetBusHost()+" is running and reachable")
+			return
+		}
+
+		connection.Close()
+		report.add("bus", CheckOk, "remote bus on "+utils.GetBusHost()+":"+utils.GetBusHostPort()+" is reachable", "")
+		return
+	}
+
+	isRunning, err := isBusRunning()
+	if err != nil && !docker_client.IsErrConnectionFailed(err) {
+		report.add("bus", CheckWarning, "can't inspect bus container: "+err.Error(), "")
+	}
+
+	if isRunning {
+		report.add("bus", CheckOk, "bus is running on :"+utils.GetBusHostPort(), "")
+		return
+	}
+
+	for _, port := range []string{utils.GetBusHostPort(), utils.GetBusMonitoringHostPort()} {
+		if !isPortFree(port) {
+			report.add("bus", CheckFailed, "bus isn't running, but port "+port+" is used by other process",
+				"stop process on port "+port+" or set busPort and busMonitoringPort in project.json")
+			return
+		}
+	}
+
+	report.add("bus", CheckOk, "bus isn't running, port "+utils.GetBusHostPort()+" is free", "")
+}
+
+func checkDoctorInstances(report *DoctorReport) []instance.Config {
+	names, err := instance.GetNames()
+	if err != nil {
+		report.add("instances", CheckFailed, err.Error(), "")
+		return nil
+	}
+
+	configs := []instance.Config{}
+
+	for _, name := range names {
+		checkName := "instance " + name
+
+		err := instance.CheckConfig(name)
+		if err != nil {
+			report.add(checkName, CheckFailed, err.Error(), "fix its config in instances directory or remove it with 'cubes instance remove "+name+"'")
+			continue
+		}
+
+		config, err := instance.GetConfig(name)
+		if err != nil {
+			report.add(checkName, CheckFailed, err.Error(), "")
+			continue
+		}
+
+		configs = append(configs, *config)
+		report.add(checkName, CheckOk, "config is valid", "")
+	}
+
+	if len(names) == 0 {
+		report.add("instances", CheckOk, "project has no instances", "")
+	}
+
+	return configs
+}
+
+func checkDoctorDocker(report *DoctorReport, configs []instance.Config) {
+	dockerInstances := []string{}
+	for _, config := range configs {
+		if instance.IsDockerRequired(config) {
+			dockerInstances = append(dockerInstances, config.Name)
+		}
+	}
+
+	if len(dockerInstances) == 0 {
+		report.add("docker", CheckSkipped, "no instance is run by docker", "")
+		return
+	}
+
+	err := utils.PingDocker()
+	if err != nil {
+		report.add("docker", CheckFailed, fmt.Sprintf("docker is required by %v instances, but isn't available: %v", len(dockerInstances), err),
+			"start docker service or set DOCKER_HOST")
+		return
+	}
+
+	report.add("docker", CheckOk, "docker is available", "")
+}
+
+// RunDoctor checks environment of project: project config, migrations, database, bus, instances configs and docker
+func RunDoctor() *DoctorReport {
+	report := DoctorReport{
+		Checks: []DoctorCheck{},
+	}
+
+	config := checkDoctorProjectConfig(&report)
+	checkDoctorMigrations(&report)
+	checkDoctorDatabase(&report, config)
+	checkDoctorBus(&report)
+	configs := checkDoctorInstances(&report)
+	checkDoctorDocker(&report, configs)
+
+	return &report
+}
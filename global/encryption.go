@@ -0,0 +1,116 @@
+package global
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/akaumov/cubes/utils"
+)
+
+// busEncryptionKeyFile keeps project key of bus store, it isn't put to bus backups
+const busEncryptionKeyFile = "encryption.key"
+
+// busEncryptionKeyEnv overrides key file, so key can be kept in secrets storage
+const busEncryptionKeyEnv = "CUBES_BUS_ENCRYPTION_KEY"
+
+const busServerConfigPath = "/etc/cubes-bus/server.conf"
+
+// busKeyEnv passes key to bus container, server config refers to it, so key isn't written to config
+const busKeyEnv = "CUBES_BUS_KEY"
+
+func getBusEncryptionKeyPath() (string, error) {
+	busDirectory, err := utils.GetStateDirectoryPath("bus")
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(busDirectory, busEncryptionKeyFile), nil
+}
+
+// getBusEncryptionKey returns project key from environment or key file, key is generated when project doesn't have it
+func getBusEncryptionKey() (string, error) {
+	key := os.Getenv(busEncryptionKeyEnv)
+	if key != "" {
+		return key, nil
+	}
+
+	keyPath, err := getBusEncryptionKeyPath()
+	if err != nil {
+		return "", err
+	}
+
+	rawKey, err := ioutil.ReadFile(keyPath)
+	if err == nil {
+		return strings.TrimSpace(string(rawKey)), nil
+	}
+
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	data := make([]byte, 32)
+
+	_, err = rand.Read(data)
+	if err != nil {
+		return "", err
+	}
+
+	key = hex.EncodeToString(data)
+
+	err = ioutil.WriteFile(keyPath, []byte(key), 0600)
+	if err != nil {
+		return "", err
+	}
+
+	utils.Infof("Bus encryption key is generated in %v, messages can't be read without it\n", keyPath)
+	return key, nil
+}
+
+// getBusEncryptionOptions writes server config, which encrypts bus store, and returns bus arguments, binds and environment.
+// Bus takes only one config, so server config includes users config when bus auth is enabled.
+func getBusEncryptionOptions(config ProjectConfig) ([]string, []string, []string, error) {
+	if !config.IsBusEncryptionEnabled {
+		return []string{}, []string{}, []string{}, nil
+	}
+
+	if len(config.PersistentChannels) == 0 && !config.IsDelayedDeliveryEnabled {
+		utils.Warningf("Bus encryption is enabled, but bus doesn't store messages")
+		return []string{}, []string{}, []string{}, nil
+	}
+
+	key, err := getBusEncryptionKey()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("can't read bus encryption key: %v", err)
+	}
+
+	busDirectory, err := utils.GetStateDirectoryPath("bus")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	serverConfig := ""
+	if config.IsBusAuthEnabled {
+		serverConfig += fmt.Sprintf("include %q\n", filepath.Base(busAuthConfigPath))
+	}
+
+	serverConfig += "jetstream {\n    key: $" + busKeyEnv + "\n}\n"
+
+	serverConfigPath := filepath.Join(busDirectory, "server.conf")
+
+	err = ioutil.WriteFile(serverConfigPath, []byte(serverConfig), 0644)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	utils.Infof("Bus store is encrypted")
+
+	return []string{"-c", busServerConfigPath},
+		[]string{serverConfigPath + ":" + busServerConfigPath + ":ro"},
+		[]string{busKeyEnv + "=" + key},
+		nil
+}
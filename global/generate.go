@@ -0,0 +1,633 @@
+package global
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/akaumov/cubes/instance"
+)
+
+// LangGo is the only language, which cube skeletons are generated in, cube executor is written in go
+const LangGo = "go"
+
+const (
+	generatedHandlerFileName  = "handler.go"
+	generatedMessagesFileName = "messages.go"
+	generatedStubsFileName    = "handlers.go"
+)
+
+// GenerateOptions are options of cube skeleton generation, Output is cubes/<instance> of project by default
+// and Package is name of output directory by default
+type GenerateOptions struct {
+	Instance string
+	Lang     string
+	Output   string
+	Package  string
+}
+
+// GenerateResult is generated skeleton, Files are written files, existing stubs and meta aren't rewritten
+type GenerateResult struct {
+	Directory string   `json:"directory"`
+	Package   string   `json:"package"`
+	GoPackage string   `json:"goPackage"`
+	Files     []string `json:"files"`
+}
+
+// generatedChannel is cube channel of instance with Go names of its constant, message type and handlers
+type generatedChannel struct {
+	Name        string
+	BusChannel  string
+	Direction   string
+	Schema      *JSONSchema
+	RawSchema   json.RawMessage
+	Constant    string
+	MessageType string
+	GoName      string
+}
+
+// goTypes collects declarations of message types, nested objects get types named by their path
+type goTypes struct {
+	declarations []string
+	names        map[string]bool
+}
+
+// getGoName converts channel or property name to exported go name: "user_id" is "UserId"
+func getGoName(name string) string {
+	var result strings.Builder
+
+	isUpper := true
+	for _, char := range name {
+		if !unicode.IsLetter(char) && !unicode.IsDigit(char) {
+			isUpper = true
+			continue
+		}
+
+		if isUpper {
+			char = unicode.ToUpper(char)
+			isUpper = false
+		}
+
+		result.WriteRune(char)
+	}
+
+	goName := result.String()
+	if goName == "" || unicode.IsDigit([]rune(goName)[0]) {
+		goName = "X" + goName
+	}
+
+	return goName
+}
+
+// getPackageName converts directory name to go package name
+func getPackageName(name string) string {
+	var result strings.Builder
+
+	for _, char := range strings.ToLower(name) {
+		if (char >= 'a' && char <= 'z') || (char >= '0' && char <= '9' && result.Len() > 0) {
+			result.WriteRune(char)
+		}
+	}
+
+	if result.Len() == 0 {
+		return "cube"
+	}
+
+	return result.String()
+}
+
+// getType returns go type of values of schema, types of objects are declared with name
+func (types *goTypes) getType(name string, schema *JSONSchema) string {
+	if schema == nil {
+		return "json.RawMessage"
+	}
+
+	schemaTypes := []string{}
+	for _, schemaType := range schema.getTypes() {
+		if schemaType != "null" {
+			schemaTypes = append(schemaTypes, schemaType)
+		}
+	}
+
+	if len(schemaTypes) != 1 {
+		return "json.RawMessage"
+	}
+
+	switch schemaTypes[0] {
+	case "string":
+		return "string"
+	case "integer":
+		return "int64"
+	case "number":
+		return "float64"
+	case "boolean":
+		return "bool"
+	case "array":
+		return "[]" + types.getType(name+"Item", schema.Items)
+	case "object":
+		if len(schema.Properties) == 0 {
+			return "map[string]interface{}"
+		}
+
+		return types.declareStruct(name, schema)
+	}
+
+	return "json.RawMessage"
+}
+
+func (types *goTypes) declareStruct(name string, schema *JSONSchema) string {
+	for types.names[name] {
+		name += "X"
+	}
+
+	types.names[name] = true
+
+	properties := []string{}
+	for property := range schema.Properties {
+		properties = append(properties, property)
+	}
+
+	sort.Strings(properties)
+
+	isRequired := map[string]bool{}
+	for _, property := range schema.Required {
+		isRequired[property] = true
+	}
+
+	var declaration strings.Builder
+	fmt.Fprintf(&declaration, "type %v struct {\n", name)
+
+	for _, property := range properties {
+		fieldName := getGoName(property)
+		fieldType := types.getType(name+fieldName, schema.Properties[property])
+		tag := property
+
+		// optional values are pointers, so zero value isn't confused with missing one
+		if !isRequired[property] {
+			tag += ",omitempty"
+
+			if !strings.HasPrefix(fieldType, "[]") && !strings.HasPrefix(fieldType, "map[") && fieldType != "json.RawMessage" {
+				fieldType = "*" + fieldType
+			}
+		}
+
+		fmt.Fprintf(&declaration, "\t%v %v `json:\"%v\"`\n", fieldName, fieldType, tag)
+	}
+
+	declaration.WriteString("}\n")
+
+	types.declarations = append(types.declarations, declaration.String())
+	return name
+}
+
+// getGeneratedChannels returns cube channels of instance with their directions and schemas of messages. Schema is taken
+// from cube meta, then from channelSchemas of project by bus channel. Channels, which meta doesn't declare, are in channels.
+func getGeneratedChannels(config instance.Config, meta *instance.Meta, projectSchemas map[string]json.RawMessage) ([]generatedChannel, error) {
+	channels := []generatedChannel{}
+
+	for cubeChannel, busChannel := range getInstanceChannels(config, meta) {
+		channel := generatedChannel{
+			Name:       cubeChannel,
+			BusChannel: busChannel,
+			Direction:  instance.ChannelIn,
+			GoName:     getGoName(cubeChannel),
+		}
+
+		if meta != nil {
+			if channelMeta, ok := meta.Channels[cubeChannel]; ok {
+				channel.Direction = channelMeta.Direction
+				channel.RawSchema = channelMeta.Schema
+			}
+		}
+
+		if len(channel.RawSchema) == 0 {
+			channel.RawSchema = projectSchemas[busChannel]
+		}
+
+		if len(channel.RawSchema) == 0 {
+			for schemaChannel, rawSchema := range projectSchemas {
+				if isChannelMatched(schemaChannel, busChannel) {
+					channel.RawSchema = rawSchema
+				}
+			}
+		}
+
+		if len(channel.RawSchema) > 0 {
+			schema, err := ParseJSONSchema(channel.RawSchema)
+			if err != nil {
+				return nil, fmt.Errorf("wrong schema of channel %v: %v", cubeChannel, err)
+			}
+
+			channel.Schema = schema
+		}
+
+		channel.Constant = channel.GoName + "Channel"
+		channel.MessageType = channel.GoName + "Message"
+		channels = append(channels, channel)
+	}
+
+	sort.Slice(channels, func(i, j int) bool {
+		return channels[i].Name < channels[j].Name
+	})
+
+	return channels, nil
+}
+
+func getProjectSchemas() (map[string]json.RawMessage, error) {
+	config, err := GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("can't read project config: %v", err)
+	}
+
+	schemas := map[string]json.RawMessage{}
+
+	for channel, schemaPath := range config.ChannelSchemas {
+		rawSchema, err := ioutil.ReadFile(schemaPath)
+		if err != nil {
+			return nil, fmt.Errorf("can't read schema of channel %v: %v", channel, err)
+		}
+
+		schemas[channel] = rawSchema
+	}
+
+	return schemas, nil
+}
+
+var generatedMessagesTemplate = template.Must(template.New("messages").Parse(`// Code generated by cubes generate cube; DO NOT EDIT.
+
+package {{.Package}}
+
+{{if .IsJsonUsed}}import (
+	"encoding/json"
+)
+
+{{end}}{{range .Declarations}}{{.}}
+{{end}}`))
+
+var generatedHandlerTemplate = template.Must(template.New("handler").Parse(`// Code generated by cubes generate cube; DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/akaumov/cube"
+)
+
+const Version = {{printf "%q" .Version}}
+
+// channels of cube, instance maps them to bus channels
+const (
+{{range .Channels}}	{{.Constant}} cube.Channel = {{printf "%q" .Name}}
+{{end}})
+
+// Handler is cube handler, which cube executor is compiled with, messages of in channels are passed to handlers of handlers.go
+type Handler struct{}
+
+func (h *Handler) OnInitInstance() []cube.InputChannel {
+	return []cube.InputChannel{
+{{range .Channels}}{{if eq .Direction "in"}}		cube.InputChannel({{.Constant}}),
+{{end}}{{end}}	}
+}
+
+func (h *Handler) OnStart(instance cube.Cube) {
+	h.onStart(instance)
+}
+
+func (h *Handler) OnStop(instance cube.Cube) {
+	h.onStop(instance)
+}
+
+func (h *Handler) OnReceiveMessage(instance cube.Cube, channel cube.Channel, message cube.Message) {
+	switch channel {
+{{range .Channels}}{{if eq .Direction "in"}}	case {{.Constant}}:
+		var params {{.MessageType}}
+
+		err := unmarshalParams(message.Params, &params)
+		if err != nil {
+			instance.LogError(fmt.Sprintf("wrong message of %v: %v", channel, err))
+			return
+		}
+
+		h.on{{.GoName}}Message(instance, message.Method, params)
+{{end}}{{end}}	default:
+		instance.LogWarning(fmt.Sprintf("message of %v isn't handled", channel))
+	}
+}
+
+func (h *Handler) OnReceiveRequest(instance cube.Cube, channel cube.Channel, request cube.Request) (*cube.Response, error) {
+	var result interface{}
+	var err error
+
+	switch channel {
+{{range .Channels}}{{if eq .Direction "in"}}	case {{.Constant}}:
+		var params {{.MessageType}}
+
+		err = unmarshalParams(request.Params, &params)
+		if err != nil {
+			return nil, fmt.Errorf("wrong request of %v: %v", channel, err)
+		}
+
+		result, err = h.on{{.GoName}}Request(instance, request.Method, params)
+{{end}}{{end}}	default:
+		return nil, fmt.Errorf("request of %v isn't handled", channel)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	rawResult, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+
+	resultMessage := json.RawMessage(rawResult)
+
+	return &cube.Response{
+		Version: Version,
+		Result:  &resultMessage,
+	}, nil
+}
+{{range .Channels}}{{if eq .Direction "out"}}
+// Publish{{.MessageType}} publishes message to {{.Name}} channel
+func Publish{{.MessageType}}(instance cube.Cube, method string, params {{.MessageType}}) error {
+	rawParams, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	paramsMessage := json.RawMessage(rawParams)
+
+	return instance.PublishMessage({{.Constant}}, cube.Message{
+		Version: Version,
+		Method:  method,
+		Params:  &paramsMessage,
+	})
+}
+{{end}}{{end}}
+func unmarshalParams(params *json.RawMessage, value interface{}) error {
+	if params == nil {
+		return nil
+	}
+
+	return json.Unmarshal(*params, value)
+}
+
+// errNotImplemented is returned by generated stubs of request handlers
+func errNotImplemented(method string) error {
+	return fmt.Errorf("method %v isn't implemented", method)
+}
+`))
+
+var generatedStubsTemplate = template.Must(template.New("stubs").Parse(`package {{.Package}}
+
+import (
+	"github.com/akaumov/cube"
+)
+
+func (h *Handler) onStart(instance cube.Cube) {
+}
+
+func (h *Handler) onStop(instance cube.Cube) {
+}
+`))
+
+var generatedStubTemplate = template.Must(template.New("stub").Parse(`
+// on{{.GoName}}Message handles message of {{.Name}} channel
+func (h *Handler) on{{.GoName}}Message(instance cube.Cube, method string, params {{.MessageType}}) {
+}
+
+// on{{.GoName}}Request answers request of {{.Name}} channel, result is sent in response
+func (h *Handler) on{{.GoName}}Request(instance cube.Cube, method string, params {{.MessageType}}) (interface{}, error) {
+	return nil, errNotImplemented(method)
+}
+`))
+
+func executeGoTemplate(goTemplate *template.Template, data interface{}) ([]byte, error) {
+	var source bytes.Buffer
+
+	err := goTemplate.Execute(&source, data)
+	if err != nil {
+		return nil, err
+	}
+
+	formatted, err := format.Source(source.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated code is wrong: %v", err)
+	}
+
+	return formatted, nil
+}
+
+// getStubs returns stubs of handlers, which stubs file doesn't have yet, so new channels get them on regeneration
+func getStubs(stubsSource string, channels []generatedChannel) (string, error) {
+	var stubs bytes.Buffer
+
+	for _, channel := range channels {
+		if channel.Direction != instance.ChannelIn || strings.Contains(stubsSource, "on"+channel.GoName+"Message(") {
+			continue
+		}
+
+		err := generatedStubTemplate.Execute(&stubs, channel)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return stubs.String(), nil
+}
+
+// getGeneratedMeta returns meta of generated cube, channels keep their directions and schemas
+func getGeneratedMeta(meta *instance.Meta, channels []generatedChannel) ([]byte, error) {
+	generatedMeta := instance.Meta{
+		Version:  "1",
+		Channels: map[string]instance.ChannelMeta{},
+		Params:   map[string]instance.ParamMeta{},
+	}
+
+	if meta != nil {
+		generatedMeta = *meta
+		generatedMeta.Channels = map[string]instance.ChannelMeta{}
+	}
+
+	for _, channel := range channels {
+		generatedMeta.Channels[channel.Name] = instance.ChannelMeta{
+			Direction: channel.Direction,
+			Schema:    channel.RawSchema,
+		}
+	}
+
+	rawMeta, err := json.MarshalIndent(generatedMeta, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(rawMeta, '\n'), nil
+}
+
+// GenerateCube writes skeleton of cube for channels of instance: typed messages of channel schemas, handler, which cube
+// executor is compiled with, and stubs of handlers. Generated files are rewritten, stubs file only gets stubs of new
+// channels and existing meta.json is kept.
+func GenerateCube(options GenerateOptions) (*GenerateResult, error) {
+	if options.Lang == "" {
+		options.Lang = LangGo
+	}
+
+	if options.Lang != LangGo {
+		return nil, fmt.Errorf("language %v isn't supported, cubes are generated only in %v", options.Lang, LangGo)
+	}
+
+	config, err := instance.GetConfig(options.Instance)
+	if err != nil {
+		return nil, err
+	}
+
+	meta, err := instance.GetMeta(*config)
+	if err != nil {
+		return nil, fmt.Errorf("can't read cube meta: %v", err)
+	}
+
+	projectSchemas, err := getProjectSchemas()
+	if err != nil {
+		return nil, err
+	}
+
+	channels, err := getGeneratedChannels(*config, meta, projectSchemas)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(channels) == 0 {
+		return nil, fmt.Errorf("instance '%v' doesn't have channels, map them with --channels or declare them in meta", options.Instance)
+	}
+
+	output := options.Output
+	if output == "" {
+		output = filepath.Join("cubes", options.Instance)
+	}
+
+	directory, err := filepath.Abs(output)
+	if err != nil {
+		return nil, err
+	}
+
+	packageName := options.Package
+	if packageName == "" {
+		packageName = getPackageName(filepath.Base(directory))
+	}
+
+	types := goTypes{
+		names: map[string]bool{},
+	}
+
+	for _, channel := range channels {
+		messageType := types.getType(channel.MessageType, channel.Schema)
+		if messageType != channel.MessageType {
+			types.declarations = append(types.declarations, fmt.Sprintf("type %v = %v\n", channel.MessageType, messageType))
+		}
+	}
+
+	version := "1"
+	if meta != nil && meta.Version != "" {
+		version = meta.Version
+	}
+
+	messagesSource, err := executeGoTemplate(generatedMessagesTemplate, map[string]interface{}{
+		"Package":      packageName,
+		"Declarations": types.declarations,
+		"IsJsonUsed":   strings.Contains(strings.Join(types.declarations, ""), "json.RawMessage"),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	handlerSource, err := executeGoTemplate(generatedHandlerTemplate, map[string]interface{}{
+		"Package":  packageName,
+		"Version":  version,
+		"Channels": channels,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.MkdirAll(directory, 0777)
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string][]byte{
+		generatedMessagesFileName: messagesSource,
+		generatedHandlerFileName:  handlerSource,
+	}
+
+	stubsPath := filepath.Join(directory, generatedStubsFileName)
+
+	stubsSource, err := ioutil.ReadFile(stubsPath)
+	isStubsCreated := os.IsNotExist(err)
+
+	if isStubsCreated {
+		var stubsHeader bytes.Buffer
+
+		err = generatedStubsTemplate.Execute(&stubsHeader, map[string]interface{}{
+			"Package": packageName,
+		})
+
+		stubsSource = stubsHeader.Bytes()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	stubs, err := getStubs(string(stubsSource), channels)
+	if err != nil {
+		return nil, err
+	}
+
+	if isStubsCreated || stubs != "" {
+		files[generatedStubsFileName], err = format.Source(append(stubsSource, []byte(stubs)...))
+		if err != nil {
+			return nil, fmt.Errorf("can't add stubs to %v: %v", generatedStubsFileName, err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(directory, "meta.json")); os.IsNotExist(err) {
+		files["meta.json"], err = getGeneratedMeta(meta, channels)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := GenerateResult{
+		Directory: directory,
+		Package:   packageName,
+		GoPackage: getCubePackage(directory),
+		Files:     []string{},
+	}
+
+	for fileName, data := range files {
+		filePath := filepath.Join(directory, fileName)
+
+		err = ioutil.WriteFile(filePath, data, 0644)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Files = append(result.Files, filePath)
+	}
+
+	sort.Strings(result.Files)
+	return &result, nil
+}
@@ -0,0 +1,266 @@
+package global
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/akaumov/cubes/instance"
+	"github.com/akaumov/cubes/utils"
+)
+
+// QueueGroupMember is instance of queue group, bus delivers every message of group channels to one of members
+type QueueGroupMember struct {
+	Instance string `json:"instance"`
+	Status   string `json:"status"`
+
+	// IsConnected is set when bus is running and instance is connected to it
+	IsConnected bool `json:"isConnected"`
+
+	// PendingBytes are bytes, which bus has for instance, but instance hasn't read yet
+	PendingBytes int `json:"pendingBytes"`
+}
+
+type QueueGroup struct {
+	Name     string             `json:"name"`
+	Channels []string           `json:"channels"`
+	Members  []QueueGroupMember `json:"members"`
+
+	// PendingMessages are messages of reliable channels, which members haven't got or haven't acknowledged yet
+	PendingMessages uint64 `json:"pendingMessages"`
+}
+
+type QueueGroups struct {
+	IsLive bool         `json:"isLive"`
+	Groups []QueueGroup `json:"groups"`
+
+	// MonitoringError is set when bus is running, but its clients can't be read
+	MonitoringError string `json:"monitoringError,omitempty"`
+}
+
+// getQueueGroupsMembers returns names of instances by their queue groups
+func getQueueGroupsMembers() (map[string][]string, error) {
+	configs, err := instance.GetList()
+	if err != nil {
+		return nil, err
+	}
+
+	members := map[string][]string{}
+	for _, config := range *configs {
+		if config.QueueGroup != "" {
+			members[config.QueueGroup] = append(members[config.QueueGroup], config.Name)
+		}
+	}
+
+	return members, nil
+}
+
+func getQueueGroupMembers(name string) ([]string, error) {
+	members, err := getQueueGroupsMembers()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(members[name]) == 0 {
+		return nil, fmt.Errorf("queue group %v doesn't exist", name)
+	}
+
+	return members[name], nil
+}
+
+// addQueueGroupsPending adds pending bytes of connected members and pending messages of their reliable consumers
+func addQueueGroupsPending(groups []QueueGroup) error {
+	connections, err := utils.GetBusConnections()
+	if err != nil {
+		return err
+	}
+
+	instancesAddresses := getInstancesAddresses()
+
+	connectedInstances := map[string]utils.BusConnection{}
+	for _, connection := range *connections {
+		if name := instancesAddresses[connection.IP]; name != "" {
+			connectedInstances[name] = connection
+		}
+	}
+
+	consumers, err := GetReliableConsumers()
+	if err != nil {
+		return err
+	}
+
+	for i := range groups {
+		group := &groups[i]
+
+		for j := range group.Members {
+			member := &group.Members[j]
+
+			connection, ok := connectedInstances[member.Instance]
+			member.IsConnected = ok
+			member.PendingBytes = connection.PendingBytes
+
+			for _, consumer := range consumers {
+				if consumer.Instance == member.Instance {
+					group.PendingMessages += consumer.Pending + consumer.Unacknowledged
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// GetQueueGroups returns queue groups of instances with channels they consume, pending messages are added when bus is running
+func GetQueueGroups() (*QueueGroups, error) {
+	groupsMembers, err := getQueueGroupsMembers()
+	if err != nil {
+		return nil, err
+	}
+
+	busChannels, err := GetBusChannels()
+	if err != nil {
+		return nil, err
+	}
+
+	result := QueueGroups{
+		Groups: []QueueGroup{},
+	}
+
+	for name, members := range groupsMembers {
+		group := QueueGroup{
+			Name:     name,
+			Channels: []string{},
+			Members:  []QueueGroupMember{},
+		}
+
+		isMember := map[string]bool{}
+
+		for _, member := range members {
+			isMember[member] = true
+
+			status, err := instance.GetStatus(member)
+			if err != nil {
+				status = "unknown"
+			}
+
+			group.Members = append(group.Members, QueueGroupMember{
+				Instance: member,
+				Status:   status,
+			})
+		}
+
+		for _, channel := range busChannels.Channels {
+			for _, endpoint := range channel.Endpoints {
+				if isMember[endpoint.Instance] && endpoint.Direction != instance.ChannelOut {
+					group.Channels = append(group.Channels, channel.Channel)
+					break
+				}
+			}
+		}
+
+		sort.Slice(group.Members, func(i, j int) bool {
+			return group.Members[i].Instance < group.Members[j].Instance
+		})
+
+		result.Groups = append(result.Groups, group)
+	}
+
+	sort.Slice(result.Groups, func(i, j int) bool {
+		return result.Groups[i].Name < result.Groups[j].Name
+	})
+
+	if busChannels.IsLive {
+		err = addQueueGroupsPending(result.Groups)
+		if err != nil {
+			result.MonitoringError = err.Error()
+		}
+
+		result.IsLive = result.MonitoringError == ""
+	} else {
+		result.MonitoringError = busChannels.MonitoringError
+	}
+
+	return &result, nil
+}
+
+// DrainQueueGroup stops members of queue group one by one, every member finishes messages it handles before it's stopped.
+// Messages of reliable channels are kept on bus until members are started again.
+func DrainQueueGroup(name string) error {
+	members, err := getQueueGroupMembers(name)
+	if err != nil {
+		return err
+	}
+
+	for _, member := range members {
+		status, err := instance.GetStatus(member)
+		if err != nil || !instance.IsActiveStatus(status) {
+			continue
+		}
+
+		utils.Infof("Stopping instance %v...\n", member)
+
+		err = instance.Stop(member)
+		if err != nil {
+			return fmt.Errorf("can't stop instance %v: %v", member, err)
+		}
+	}
+
+	return nil
+}
+
+// ResetQueueGroup drops messages of reliable channels, which members of queue group haven't got or haven't acknowledged,
+// members get only new messages after reset
+func ResetQueueGroup(name string) error {
+	members, err := getQueueGroupMembers(name)
+	if err != nil {
+		return err
+	}
+
+	config, err := GetConfig()
+	if err != nil {
+		return fmt.Errorf("can't read project config: %v", err)
+	}
+
+	consumers, err := getReliableConsumers(*config)
+	if err != nil {
+		return err
+	}
+
+	isMember := map[string]bool{}
+	for _, member := range members {
+		isMember[member] = true
+	}
+
+	groupConsumers := []ReliableConsumer{}
+	for _, consumer := range consumers {
+		if isMember[consumer.Instance] {
+			groupConsumers = append(groupConsumers, consumer)
+		}
+	}
+
+	if len(groupConsumers) == 0 {
+		return fmt.Errorf("queue group %v doesn't consume reliable channels, bus doesn't keep its messages", name)
+	}
+
+	connection, err := connectRunningBus()
+	if err != nil {
+		return err
+	}
+
+	defer connection.Close()
+
+	for _, consumer := range groupConsumers {
+		err = deleteConsumer(connection, consumer)
+		if err != nil {
+			return fmt.Errorf("can't reset consumer of %v for %v: %v", consumer.Channel, consumer.Instance, err)
+		}
+
+		err = createReliableConsumer(connection, *config, consumer)
+		if err != nil {
+			return fmt.Errorf("can't create consumer of %v for %v: %v", consumer.Channel, consumer.Instance, err)
+		}
+
+		utils.Infof("Consumer of %v for %v is reset\n", consumer.Channel, consumer.Instance)
+	}
+
+	return nil
+}
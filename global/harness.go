@@ -0,0 +1,518 @@
+package global
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/akaumov/cube"
+	"github.com/akaumov/cube_executor"
+	"github.com/akaumov/cubes/instance"
+	"github.com/akaumov/cubes/utils"
+	"github.com/nats-io/nats.go"
+)
+
+// testsDirectoryName is directory of test specs of instances: tests/<instance>.json
+const testsDirectoryName = "tests"
+
+// types of test cases: request waits for response of cube, message is only published
+const (
+	TestCaseRequest = "request"
+	TestCaseMessage = "message"
+)
+
+const (
+	defaultTestStartTimeout = 5 * time.Minute
+	defaultTestCaseTimeout  = 5 * time.Second
+)
+
+// TestSpec is test of instance: it's started with test params on ephemeral bus and gets fixture
+// messages of cases one by one
+type TestSpec struct {
+	// Params replace params of instance in test
+	Params map[string]string `json:"params,omitempty"`
+
+	// StartTimeoutSeconds is time to wait for instance, its source can be built on start
+	StartTimeoutSeconds int `json:"startTimeoutSeconds,omitempty"`
+
+	Cases []TestCase `json:"cases"`
+}
+
+// TestCase publishes fixture to channel and checks response and messages published by cube.
+// Channels are cube channels of instance, they're mapped to bus channels like in instance,
+// not mapped channels are bus channels.
+type TestCase struct {
+	Name    string           `json:"name"`
+	Type    string           `json:"type,omitempty"`
+	Channel string           `json:"channel"`
+	Method  string           `json:"method"`
+	Params  *json.RawMessage `json:"params,omitempty"`
+
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+
+	// Expect is checked for response of request, response without errors is expected when it's empty
+	Expect *TestExpectation `json:"expect,omitempty"`
+
+	// Outputs are messages, which cube must publish while case runs
+	Outputs []TestOutput `json:"outputs,omitempty"`
+}
+
+// TestExpectation checks response, Result matches when every field of it is in result: objects of result
+// can have other fields
+type TestExpectation struct {
+	Result *json.RawMessage `json:"result,omitempty"`
+
+	// Error is code of error, which response must have
+	Error string `json:"error,omitempty"`
+}
+
+// TestOutput matches message published to channel, Params match like result of expectation
+type TestOutput struct {
+	Channel string           `json:"channel"`
+	Method  string           `json:"method,omitempty"`
+	Params  *json.RawMessage `json:"params,omitempty"`
+}
+
+type TestCaseResult struct {
+	Name           string   `json:"name"`
+	IsPassed       bool     `json:"isPassed"`
+	Failures       []string `json:"failures,omitempty"`
+	DurationMillis float64  `json:"durationMillis"`
+}
+
+type TestReport struct {
+	Instance string           `json:"instance"`
+	Spec     string           `json:"spec"`
+	Cases    []TestCaseResult `json:"cases"`
+	Passed   int              `json:"passed"`
+	Failed   int              `json:"failed"`
+}
+
+// GetTestSpecPath returns default path of test spec of instance
+func GetTestSpecPath(name string) string {
+	return filepath.Join(testsDirectoryName, name+".json")
+}
+
+func readTestSpec(specPath string) (*TestSpec, error) {
+	rawSpec, err := ioutil.ReadFile(specPath)
+	if err != nil {
+		return nil, fmt.Errorf("can't read test spec: %v", err)
+	}
+
+	var spec TestSpec
+
+	err = json.Unmarshal(rawSpec, &spec)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse test spec %v: %v", specPath, err)
+	}
+
+	if len(spec.Cases) == 0 {
+		return nil, fmt.Errorf("test spec %v doesn't have cases", specPath)
+	}
+
+	for i, testCase := range spec.Cases {
+		if testCase.Name == "" {
+			return nil, fmt.Errorf("name of case %v is required", i+1)
+		}
+
+		if testCase.Type != "" && testCase.Type != TestCaseRequest && testCase.Type != TestCaseMessage {
+			return nil, fmt.Errorf("wrong type %v of case %v, it can be %v or %v", testCase.Type, testCase.Name, TestCaseRequest, TestCaseMessage)
+		}
+
+		if testCase.Channel == "" {
+			return nil, fmt.Errorf("channel of case %v is required", testCase.Name)
+		}
+
+		if testCase.Type == TestCaseMessage && testCase.Expect != nil {
+			return nil, fmt.Errorf("case %v publishes message, it can't expect response", testCase.Name)
+		}
+
+		for _, output := range testCase.Outputs {
+			if output.Channel == "" {
+				return nil, fmt.Errorf("channel of output of case %v is required", testCase.Name)
+			}
+		}
+	}
+
+	return &spec, nil
+}
+
+// isJsonMatched returns true when actual has every field of expected, arrays and values must be equal
+func isJsonMatched(expected interface{}, actual interface{}) bool {
+	switch expectedValue := expected.(type) {
+	case map[string]interface{}:
+		actualValue, ok := actual.(map[string]interface{})
+		if !ok {
+			return false
+		}
+
+		for key, value := range expectedValue {
+			field, ok := actualValue[key]
+			if !ok || !isJsonMatched(value, field) {
+				return false
+			}
+		}
+
+		return true
+	case []interface{}:
+		actualValue, ok := actual.([]interface{})
+		if !ok || len(actualValue) != len(expectedValue) {
+			return false
+		}
+
+		for i := range expectedValue {
+			if !isJsonMatched(expectedValue[i], actualValue[i]) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return reflect.DeepEqual(expected, actual)
+}
+
+func isRawJsonMatched(expected *json.RawMessage, actual *json.RawMessage) bool {
+	if expected == nil {
+		return true
+	}
+
+	if actual == nil {
+		return false
+	}
+
+	var expectedValue, actualValue interface{}
+
+	if json.Unmarshal(*expected, &expectedValue) != nil || json.Unmarshal(*actual, &actualValue) != nil {
+		return false
+	}
+
+	return isJsonMatched(expectedValue, actualValue)
+}
+
+func rawJsonText(value *json.RawMessage) string {
+	if value == nil {
+		return "null"
+	}
+
+	return string(*value)
+}
+
+func checkResponse(data []byte, expect *TestExpectation) []string {
+	var response cube.Response
+
+	err := json.Unmarshal(data, &response)
+	if err != nil {
+		return []string{fmt.Sprintf("can't parse response: %v", err)}
+	}
+
+	codes := []string{}
+	if response.Errors != nil {
+		for _, responseError := range *response.Errors {
+			codes = append(codes, responseError.Code)
+		}
+	}
+
+	if expect == nil {
+		expect = &TestExpectation{}
+	}
+
+	failures := []string{}
+
+	if expect.Error == "" && len(codes) > 0 {
+		failures = append(failures, fmt.Sprintf("response has errors %v", codes))
+	}
+
+	if expect.Error != "" {
+		hasError := false
+		for _, code := range codes {
+			if code == expect.Error {
+				hasError = true
+			}
+		}
+
+		if !hasError {
+			failures = append(failures, fmt.Sprintf("response doesn't have error %v, it has %v", expect.Error, codes))
+		}
+	}
+
+	if !isRawJsonMatched(expect.Result, response.Result) {
+		failures = append(failures, fmt.Sprintf("result %v doesn't match %v", rawJsonText(response.Result), rawJsonText(expect.Result)))
+	}
+
+	return failures
+}
+
+func isOutputMatched(output TestOutput, data []byte) bool {
+	var message cube.Message
+
+	if json.Unmarshal(data, &message) != nil {
+		return false
+	}
+
+	return (output.Method == "" || output.Method == message.Method) && isRawJsonMatched(output.Params, message.Params)
+}
+
+type testRunner struct {
+	connection *nats.Conn
+	channels   map[cube_executor.CubeChannel]cube_executor.BusChannel
+}
+
+// getBusChannel maps cube channel of instance to bus channel
+func (r *testRunner) getBusChannel(channel string) string {
+	busChannel, ok := r.channels[cube_executor.CubeChannel(channel)]
+	if ok {
+		return string(busChannel)
+	}
+
+	return channel
+}
+
+// hasSubscriber returns true when bus has client subscribed to channel, so messages published to it aren't lost
+func hasSubscriber(channel string) bool {
+	connections, err := utils.GetBusConnections()
+	if err != nil {
+		return false
+	}
+
+	for _, connection := range *connections {
+		for _, subscription := range connection.SubscriptionsList {
+			if subscription == channel {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// waitSubscriber waits until cube subscribes to channel, instance can be running before it's subscribed
+func (r *testRunner) waitSubscriber(channel string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	for !hasSubscriber(channel) {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("channel %v doesn't have subscriber after %v", channel, timeout)
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	return nil
+}
+
+func (r *testRunner) runCase(testCase TestCase) []string {
+	timeout := defaultTestCaseTimeout
+	if testCase.TimeoutSeconds > 0 {
+		timeout = time.Duration(testCase.TimeoutSeconds) * time.Second
+	}
+
+	type outputMessage struct {
+		channel string
+		data    []byte
+	}
+
+	messages := make(chan outputMessage, 1000)
+
+	for _, output := range testCase.Outputs {
+		channel := r.getBusChannel(output.Channel)
+
+		subscription, err := r.connection.Subscribe(channel, func(message *nats.Msg) {
+			select {
+			case messages <- outputMessage{channel: channel, data: message.Data}:
+			default:
+			}
+		})
+
+		if err != nil {
+			return []string{fmt.Sprintf("can't subscribe to %v: %v", channel, err)}
+		}
+
+		defer subscription.Unsubscribe()
+	}
+
+	err := r.connection.Flush()
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	deadline := time.Now().Add(timeout)
+	channel := r.getBusChannel(testCase.Channel)
+	failures := []string{}
+
+	if testCase.Type == TestCaseMessage {
+		data, err := json.Marshal(cube.Message{
+			Version: "1",
+			Method:  testCase.Method,
+			Params:  testCase.Params,
+		})
+
+		if err == nil {
+			err = r.connection.Publish(channel, data)
+		}
+
+		if err != nil {
+			return []string{fmt.Sprintf("can't publish message: %v", err)}
+		}
+	} else {
+		data, err := json.Marshal(cube.Request{
+			Version: "1",
+			Method:  testCase.Method,
+			Params:  testCase.Params,
+		})
+
+		if err != nil {
+			return []string{err.Error()}
+		}
+
+		response, err := r.connection.Request(channel, data, timeout)
+		if err != nil {
+			return []string{fmt.Sprintf("can't get response: %v", err)}
+		}
+
+		failures = append(failures, checkResponse(response.Data, testCase.Expect)...)
+	}
+
+	isOutputReceived := make([]bool, len(testCase.Outputs))
+	received := 0
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+waitOutputs:
+	for received < len(testCase.Outputs) {
+		select {
+		case message := <-messages:
+			for i, output := range testCase.Outputs {
+				if !isOutputReceived[i] && r.getBusChannel(output.Channel) == message.channel && isOutputMatched(output, message.data) {
+					isOutputReceived[i] = true
+					received++
+					break
+				}
+			}
+		case <-timer.C:
+			break waitOutputs
+		}
+	}
+
+	for i, output := range testCase.Outputs {
+		if !isOutputReceived[i] {
+			failures = append(failures, fmt.Sprintf("message %v %v isn't published to %v", output.Method, rawJsonText(output.Params), r.getBusChannel(output.Channel)))
+		}
+	}
+
+	return failures
+}
+
+// RunTests starts ephemeral bus container and temporary copy of instance with test params, runs cases of test spec
+// one by one and removes them. Bus of project must be stopped, tests don't touch it.
+func RunTests(name string, specPath string) (*TestReport, error) {
+	if specPath == "" {
+		specPath = GetTestSpecPath(name)
+	}
+
+	spec, err := readTestSpec(specPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if utils.IsBusRemote() {
+		return nil, fmt.Errorf("bus of project is remote, tests need bus on this host")
+	}
+
+	isRunning, err := isBusRunning()
+	if err != nil {
+		return nil, fmt.Errorf("can't inspect bus container: %v", err)
+	}
+
+	if isRunning {
+		return nil, fmt.Errorf("bus is running, stop it first")
+	}
+
+	err = StartBus()
+	if err != nil {
+		return nil, err
+	}
+
+	defer StopBus(0)
+
+	testName := "cubes-test-" + name
+
+	err = instance.Clone(name, testName, spec.Params, &[]cube_executor.PortMap{}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("can't add test instance: %v", err)
+	}
+
+	defer instance.RemoveTemporary(testName)
+
+	config, err := instance.GetConfig(testName)
+	if err != nil {
+		return nil, err
+	}
+
+	startTimeout := defaultTestStartTimeout
+	if spec.StartTimeoutSeconds > 0 {
+		startTimeout = time.Duration(spec.StartTimeoutSeconds) * time.Second
+	}
+
+	utils.Infof("Starting test instance of %v...\n", name)
+
+	err = instance.Start(testName)
+	if err == nil {
+		err = instance.WaitForReady(testName, startTimeout)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("can't start test instance: %v", err)
+	}
+
+	connection, err := connectRunningBus()
+	if err != nil {
+		return nil, err
+	}
+
+	defer connection.Close()
+
+	runner := testRunner{
+		connection: connection,
+		channels:   config.ChannelsMapping,
+	}
+
+	for _, testCase := range spec.Cases {
+		err = runner.waitSubscriber(runner.getBusChannel(testCase.Channel), startTimeout)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	report := TestReport{
+		Instance: name,
+		Spec:     specPath,
+		Cases:    []TestCaseResult{},
+	}
+
+	for _, testCase := range spec.Cases {
+		startedAt := time.Now()
+		failures := runner.runCase(testCase)
+
+		result := TestCaseResult{
+			Name:           testCase.Name,
+			IsPassed:       len(failures) == 0,
+			Failures:       failures,
+			DurationMillis: float64(time.Since(startedAt)) / float64(time.Millisecond),
+		}
+
+		if result.IsPassed {
+			report.Passed++
+		} else {
+			report.Failed++
+		}
+
+		report.Cases = append(report.Cases, result)
+	}
+
+	return &report, nil
+}
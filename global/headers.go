@@ -0,0 +1,125 @@
+package global
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+// well-known headers, they're checked before message is published
+const (
+	HeaderContentType   = "content-type"
+	HeaderSchemaVersion = "schema-version"
+	HeaderTraceId       = "trace-id"
+)
+
+var headerKeyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+var contentTypePattern = regexp.MustCompile(`^[a-z0-9.+-]+/[a-z0-9.+-]+(;.*)?$`)
+
+// MessageHeaders are metadata of message: content type, schema version, trace id and custom keys
+type MessageHeaders map[string]string
+
+// ParseMessageHeaders parses headers in format "key:value;key2:value2", keys are case insensitive
+func ParseMessageHeaders(rawHeaders string) (MessageHeaders, error) {
+	headers := MessageHeaders{}
+
+	for _, rawHeader := range strings.Split(rawHeaders, ";") {
+		rawHeader = strings.TrimSpace(rawHeader)
+		if rawHeader == "" {
+			continue
+		}
+
+		parts := strings.SplitN(rawHeader, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("wrong header %v, format is key:value", rawHeader)
+		}
+
+		headers[strings.ToLower(strings.TrimSpace(parts[0]))] = strings.TrimSpace(parts[1])
+	}
+
+	return headers, checkMessageHeaders(headers)
+}
+
+func checkMessageHeaders(headers MessageHeaders) error {
+	for key, value := range headers {
+		if !headerKeyPattern.MatchString(key) {
+			return fmt.Errorf("wrong header key %v, it can have lowercase letters, digits, dots, dashes and underscores", key)
+		}
+
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("header %v can't have line breaks", key)
+		}
+
+		switch key {
+		case HeaderContentType:
+			if !contentTypePattern.MatchString(strings.ToLower(value)) {
+				return fmt.Errorf("wrong %v header %v, format is type/subtype", key, value)
+			}
+		case HeaderSchemaVersion:
+			version, err := strconv.Atoi(value)
+			if err != nil || version <= 0 {
+				return fmt.Errorf("wrong %v header %v, it must be positive number", key, value)
+			}
+		case HeaderTraceId:
+			if value == "" {
+				return fmt.Errorf("%v header can't be empty", key)
+			}
+		}
+	}
+
+	return nil
+}
+
+// newBusMessage returns message for bus, message without headers is sent like messages of clients, which don't
+// support headers
+func newBusMessage(channel string, headers MessageHeaders, data []byte) *nats.Msg {
+	message := &nats.Msg{
+		Subject: channel,
+		Data:    data,
+	}
+
+	if len(headers) == 0 {
+		return message
+	}
+
+	message.Header = nats.Header{}
+	for key, value := range headers {
+		message.Header.Set(key, value)
+	}
+
+	return message
+}
+
+// getMessageHeaders returns headers of message received from bus, keys are lowercased like keys of --headers
+func getMessageHeaders(message *nats.Msg) MessageHeaders {
+	headers := MessageHeaders{}
+
+	for key, values := range message.Header {
+		if len(values) > 0 {
+			headers[strings.ToLower(key)] = values[0]
+		}
+	}
+
+	return headers
+}
+
+// FormatMessageHeaders returns headers in format "key=value key2=value2" sorted by keys
+func FormatMessageHeaders(headers MessageHeaders) string {
+	keys := []string{}
+	for key := range headers {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	formatted := []string{}
+	for _, key := range keys {
+		formatted = append(formatted, key+"="+headers[key])
+	}
+
+	return strings.Join(formatted, " ")
+}
@@ -1,21 +1,20 @@
 package global
 
 import (
-	"github.com/akaumov/cubes/utils"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/akaumov/cubes/db"
 	"github.com/akaumov/cubes/instance"
-	"github.com/akaumov/cube_executor"
+	"github.com/akaumov/cubes/utils"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	docker_client "github.com/docker/docker/client"
 	"github.com/docker/go-connections/nat"
 	"golang.org/x/net/context"
-	"fmt"
-	"log"
-	"path/filepath"
-	"strings"
-	"os"
-	"encoding/json"
-	"io/ioutil"
 )
 
 const busImage = "nats"
@@ -23,11 +22,60 @@ const busImage = "nats"
 type ProjectConfig struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
+
+	// IsBusAuthEnabled makes bus accept only instances with their credentials
+	IsBusAuthEnabled bool `json:"isBusAuthEnabled"`
+
+	// PersistentChannels are kept on bus until they're delivered, even if consumer instance is down
+	PersistentChannels []PersistentChannel `json:"persistentChannels,omitempty"`
+
+	// BusMetricsAddress is address of prometheus endpoint with bus metrics, which is started with bus
+	BusMetricsAddress string `json:"busMetricsAddress,omitempty"`
+
+	// BusPort and BusMonitoringPort are ports of bus on host, they're 4444 and 8222 by default,
+	// projects with their own ports can run their buses side by side
+	BusPort           int `json:"busPort,omitempty"`
+	BusMonitoringPort int `json:"busMonitoringPort,omitempty"`
+
+	// IsBusEncryptionEnabled encrypts messages stored on bus with project key: .cubes/bus/encryption.key or CUBES_BUS_ENCRYPTION_KEY
+	IsBusEncryptionEnabled bool `json:"isBusEncryptionEnabled,omitempty"`
+
+	// ChannelSchemas are paths of JSON schemas of channels messages, relative to project directory
+	ChannelSchemas map[string]string `json:"channelSchemas,omitempty"`
+
+	// DeadLetterChannel is prefix of channels, which get messages not matching schemas, it's "deadletter" by default
+	DeadLetterChannel string `json:"deadLetterChannel,omitempty"`
+
+	// ChannelLimits limit size of channels messages and compress their large messages
+	ChannelLimits map[string]ChannelLimit `json:"channelLimits,omitempty"`
+
+	// IsDelayedDeliveryEnabled keeps delayed messages on bus and starts scheduler, which delivers them in time
+	IsDelayedDeliveryEnabled bool `json:"isDelayedDeliveryEnabled,omitempty"`
+
+	// Database is connection of project database, which migrations are synced to
+	Database *db.Config `json:"database,omitempty"`
+
+	// DefaultRuntime is runtime of instances, which are added without it, it's docker by default
+	DefaultRuntime string `json:"defaultRuntime,omitempty"`
+
+	// MigrationsPath and InstancesPath are directories of migrations and instances configs, relative to project root,
+	// they're migrations and instances by default
+	MigrationsPath string `json:"migrationsPath,omitempty"`
+	InstancesPath  string `json:"instancesPath,omitempty"`
+
+	// BusHost is host, which cubes reaches bus on, it's localhost by default, remote bus isn't started by cubes
+	BusHost string `json:"busHost,omitempty"`
+
+	// Profiles are environments of project: dev, staging, prod, etc, profile is selected with --env or CUBES_ENV
+	Profiles map[string]ProjectProfile `json:"profiles,omitempty"`
+
+	// Notifications are webhooks and slack channels, which cubesd sends selected lifecycle events to
+	Notifications []NotificationSink `json:"notifications,omitempty"`
 }
 
 type InstanceInfo struct {
-	Status string                   `json:"status"`
-	Config cube_executor.CubeConfig `json:"config"`
+	Status string          `json:"status"`
+	Config instance.Config `json:"config"`
 }
 
 func getProjectConfigPath() (string, error) {
@@ -36,14 +84,24 @@ func getProjectConfigPath() (string, error) {
 		return "", err
 	}
 
-	instanceConfigPath := filepath.Join(currentDirectory, "project.json")
+	instanceConfigPath := filepath.Join(currentDirectory, utils.ProjectConfigFileName)
 	return instanceConfigPath, nil
 }
 
 func StartBus() error {
-	log.Println("Running bus")
+	isRunning, err := isBusRunning()
+	if err != nil {
+		return fmt.Errorf("can't inspect bus container: %v", err)
+	}
+
+	if isRunning {
+		utils.Infof("Bus is already running")
+		return nil
+	}
 
-	err := utils.PullImage(busImage)
+	utils.Infof("Running bus")
+
+	err = utils.PullImage(busImage)
 	if err != nil {
 		return fmt.Errorf("can't run bus %v/n", err)
 	}
@@ -53,6 +111,7 @@ func StartBus() error {
 		return fmt.Errorf("Can't run bus %v/n", err)
 	}
 
+	utils.EmitEvent(utils.EventBusUp, "bus", "container on :"+utils.GetBusHostPort())
 	return nil
 }
 
@@ -62,12 +121,64 @@ func runBus() error {
 		return fmt.Errorf("can't read project config: %v", err)
 	}
 
+	isRunning, err := isBusRunning()
+	if err != nil {
+		return err
+	}
+
+	if isRunning {
+		return nil
+	}
+
+	err = checkBusPorts(*config)
+	if err != nil {
+		return err
+	}
+
+	err = checkChannelLimits(config.ChannelLimits)
+	if err != nil {
+		return err
+	}
+
+	tlsArgs, tlsBinds, err := getBusTLSOptions()
+	if err != nil {
+		return fmt.Errorf("can't read bus certificates: %v", err)
+	}
+
+	authArgs, authBinds, err := getBusAuthOptions(*config)
+	if err != nil {
+		return fmt.Errorf("can't write bus users: %v", err)
+	}
+
+	persistenceArgs, persistenceBinds, err := getBusPersistenceOptions(*config)
+	if err != nil {
+		return fmt.Errorf("can't prepare bus store: %v", err)
+	}
+
+	encryptionArgs, encryptionBinds, encryptionEnv, err := getBusEncryptionOptions(*config)
+	if err != nil {
+		return fmt.Errorf("can't prepare bus encryption: %v", err)
+	}
+
+	// server config of encryption includes users config
+	if len(encryptionArgs) > 0 {
+		authArgs = []string{}
+	}
+
+	busArgs := append([]string{"-p", busPort, "-m", utils.BusMonitoringPort}, tlsArgs...)
+	busArgs = append(busArgs, authArgs...)
+	busArgs = append(busArgs, persistenceArgs...)
+	busArgs = append(busArgs, encryptionArgs...)
+
+	busBinds := append(tlsBinds, authBinds...)
+	busBinds = append(busBinds, persistenceBinds...)
+	busBinds = append(busBinds, encryptionBinds...)
+
 	ctx := context.Background()
 	client, err := docker_client.NewEnvClient()
 
 	if err != nil {
-		log.Fatalf("can't connect to docker service:\n%v", err)
-		return err
+		return utils.ConnectionError(fmt.Errorf("can't connect to docker service: %v", err))
 	}
 
 	defer client.Close()
@@ -75,34 +186,56 @@ func runBus() error {
 	resp, err := client.ContainerCreate(ctx, &container.Config{
 		Image: busImage,
 		Tty:   true,
-		Cmd:   []string{"-p", "4444"},
+		Cmd:   busArgs,
+		Env:   encryptionEnv,
 		ExposedPorts: nat.PortSet{
-			"4444/tcp": struct{}{},
+			nat.Port(busPort + "/tcp"):                 struct{}{},
+			nat.Port(utils.BusMonitoringPort + "/tcp"): struct{}{},
 		},
 	}, &container.HostConfig{
-		AutoRemove: true,
+		AutoRemove:  true,
+		Binds:       busBinds,
 		NetworkMode: container.NetworkMode(config.Name + "_network"),
 		PortBindings: nat.PortMap{
-			"4444/tcp": []nat.PortBinding{
+			nat.Port(busPort + "/tcp"): []nat.PortBinding{
 				{
 					HostIP:   "",
-					HostPort: "4444",
+					HostPort: utils.GetBusHostPort(),
+				},
+			},
+			nat.Port(utils.BusMonitoringPort + "/tcp"): []nat.PortBinding{
+				{
+					HostIP:   "127.0.0.1",
+					HostPort: utils.GetBusMonitoringHostPort(),
 				},
 			},
 		},
-	}, nil, "cubes-bus")
+	}, nil, utils.GetBusContainerName())
 
 	if err != nil {
-		log.Fatalf("can't create docker container:\n%v", err)
-		return err
+		return fmt.Errorf("can't create bus container: %v", err)
 	}
 
 	if err := client.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
-		log.Fatalf("can't start  instance container:\n%v", err)
+		return fmt.Errorf("can't start bus container: %v", err)
+	}
+
+	err = startBusProtocol()
+	if err != nil {
 		return err
 	}
 
-	return nil
+	err = createStreams(*config)
+	if err != nil {
+		return err
+	}
+
+	err = startDelayedDelivery(*config)
+	if err != nil {
+		return err
+	}
+
+	return startBusMetrics(*config)
 }
 
 func GetConfigText() (string, error) {
@@ -128,34 +261,20 @@ func GetConfig() (*ProjectConfig, error) {
 		return nil, fmt.Errorf("can't parse project config: %v/n", err)
 	}
 
-	return &config, nil
-}
-
-func InitProject(name string, description string) error {
-	configPath, err := getProjectConfigPath()
+	err = applyConfigProfile(&config)
 	if err != nil {
-		return err
-	}
-
-	if _, err := os.Stat(configPath); err != nil {
-		if !os.IsNotExist(err) {
-			return fmt.Errorf("project is already inited: %v/n", err)
-		}
+		return nil, err
 	}
 
-	config, _ := json.MarshalIndent(ProjectConfig{
-		Name: name,
-		Description:description,
-	}, "", "  ")
-
-	err = ioutil.WriteFile(configPath, config, 0777)
+	err = applyConfigEnv(&config)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("can't read project config overrides: %v", err)
 	}
 
-	return nil
+	return &config, nil
 }
 
+// StartProject starts project bus
 func StartProject() error {
 	err := CreatePrivateNetwork()
 	if err != nil {
@@ -178,7 +297,7 @@ func ProjectVersionLog() error {
 	return nil
 }
 
-func CreatePrivateNetwork() error  {
+func CreatePrivateNetwork() error {
 	config, err := GetConfig()
 	if err != nil {
 		return fmt.Errorf("can't read project config: %v", err)
@@ -188,47 +307,37 @@ func CreatePrivateNetwork() error  {
 	client, err := docker_client.NewEnvClient()
 
 	if err != nil {
-		log.Fatalf("can't connect to docker service:\n%v", err)
-		return err
+		return utils.ConnectionError(fmt.Errorf("can't connect to docker service: %v", err))
 	}
 
 	defer client.Close()
 
-
-	_, err = client.NetworkCreate(ctx, config.Name + "_network", types.NetworkCreate{
+	_, err = client.NetworkCreate(ctx, config.Name+"_network", types.NetworkCreate{
 		Driver: "bridge",
 	})
 
 	return err
 }
 
-func GetListInstances() (*[]InstanceInfo, error) {
-	instancesDirectoryPath, err := instance.GetInstancesDirectoryPath()
-	if err != nil {
-		return nil, err
-	}
-
-	configsPathPattern := filepath.Join(instancesDirectoryPath, "*.json")
-	files, err := filepath.Glob(configsPathPattern)
+func GetListInstances(filter instance.Filter) (*[]InstanceInfo, error) {
+	configs, err := instance.GetFilteredList(filter)
 	if err != nil {
 		return nil, err
 	}
 
 	result := []InstanceInfo{}
 
-	for _, configPath := range files {
-		_, fileName := filepath.Split(configPath)
-		instanceName := strings.TrimSuffix(fileName, ".json")
-
-		config, err := instance.GetConfig(instanceName)
+	for _, config := range *configs {
+		status, err := instance.GetStatus(config.Name)
 		if err != nil {
-			return nil, fmt.Errorf("can't read instance config %v/n", err)
+			status = instance.StatusUnknown
 		}
 
 		result = append(result, InstanceInfo{
-			Config: *config,
+			Status: status,
+			Config: config,
 		})
 	}
 
-	return &result, err
+	return &result, nil
 }
@@ -0,0 +1,247 @@
+package global
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/akaumov/cubes/db"
+	"github.com/akaumov/cubes/instance"
+	"github.com/akaumov/cubes/utils"
+)
+
+// sampleCubeDirectory is source of sample cube, it's handler package, which is compiled with cube executor
+const sampleCubeDirectory = "cubes/hello"
+
+// ignoredPaths are added to .gitignore of project: state directory keeps credentials, keys, builds and bus store
+var ignoredPaths = []string{"/.cubes/"}
+
+const sampleCubeMeta = `{
+  "version": "1",
+  "description": "sample cube, which answers requests with greeting",
+  "channels": {
+    "requests": {
+      "direction": "in"
+    }
+  },
+  "params": {
+    "greeting": {
+      "type": "string",
+      "default": "Hello",
+      "description": "first word of answer"
+    }
+  }
+}
+`
+
+const sampleCubeHandler = `package hello
+
+import (
+	"encoding/json"
+
+	"github.com/akaumov/cube"
+)
+
+const Version = "1"
+
+// RequestsChannel is channel of requests, which are answered with greeting
+const RequestsChannel = "requests"
+
+type Handler struct{}
+
+func (h *Handler) OnInitInstance() []cube.InputChannel {
+	return []cube.InputChannel{RequestsChannel}
+}
+
+func (h *Handler) OnStart(instance cube.Cube) {
+}
+
+func (h *Handler) OnStop(instance cube.Cube) {
+}
+
+func (h *Handler) OnReceiveMessage(instance cube.Cube, channel cube.Channel, message cube.Message) {
+}
+
+func (h *Handler) OnReceiveRequest(instance cube.Cube, channel cube.Channel, request cube.Request) (*cube.Response, error) {
+	var params struct {
+		Name string ` + "`json:\"name\"`" + `
+	}
+
+	if request.Params != nil {
+		json.Unmarshal(*request.Params, &params)
+	}
+
+	greeting := instance.GetParam("greeting")
+	if greeting == "" {
+		greeting = "Hello"
+	}
+
+	result, err := json.Marshal(greeting + ", " + params.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	rawResult := json.RawMessage(result)
+
+	return &cube.Response{
+		Version: Version,
+		Result:  &rawResult,
+	}, nil
+}
+`
+
+// createProjectDirectory creates directory of project, empty directory is kept in git with .gitkeep
+func createProjectDirectory(path string) error {
+	err := os.MkdirAll(path, 0777)
+	if err != nil {
+		return err
+	}
+
+	files, err := ioutil.ReadDir(path)
+	if err != nil || len(files) > 0 {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(path, ".gitkeep"), []byte{}, 0644)
+}
+
+// writeGitignore adds ignored paths, which .gitignore of project doesn't have yet
+func writeGitignore(projectDirectory string) error {
+	gitignorePath := filepath.Join(projectDirectory, ".gitignore")
+
+	rawGitignore, err := ioutil.ReadFile(gitignorePath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	gitignore := string(rawGitignore)
+	existingPaths := map[string]bool{}
+
+	for _, line := range strings.Split(gitignore, "\n") {
+		existingPaths[strings.TrimSpace(line)] = true
+	}
+
+	for _, path := range ignoredPaths {
+		if existingPaths[path] {
+			continue
+		}
+
+		if gitignore != "" && !strings.HasSuffix(gitignore, "\n") {
+			gitignore += "\n"
+		}
+
+		gitignore += path + "\n"
+	}
+
+	if gitignore == string(rawGitignore) {
+		return nil
+	}
+
+	return ioutil.WriteFile(gitignorePath, []byte(gitignore), 0644)
+}
+
+// writeSampleCube writes source of sample cube, existing source isn't replaced
+func writeSampleCube(projectDirectory string) error {
+	cubeDirectory := filepath.Join(projectDirectory, filepath.FromSlash(sampleCubeDirectory))
+
+	if _, err := os.Stat(cubeDirectory); err == nil {
+		utils.Infof("Sample cube %v exists already, it's kept\n", sampleCubeDirectory)
+		return nil
+	}
+
+	err := os.MkdirAll(cubeDirectory, 0777)
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(filepath.Join(cubeDirectory, "meta.json"), []byte(sampleCubeMeta), 0644)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(cubeDirectory, "handler.go"), []byte(sampleCubeHandler), 0644)
+}
+
+// getCubePackage returns go package of cube directory, directory outside of GOPATH gets placeholder
+func getCubePackage(cubeDirectory string) string {
+	for _, goPath := range filepath.SplitList(build.Default.GOPATH) {
+		relativePath, err := filepath.Rel(filepath.Join(goPath, "src"), cubeDirectory)
+		if err == nil && !strings.HasPrefix(relativePath, "..") {
+			return filepath.ToSlash(relativePath)
+		}
+	}
+
+	return "<package of " + cubeDirectory + ">"
+}
+
+// InitProject creates project in current directory: project.json with database connection, migrations and instances
+// directories, .gitignore entries and sample cube. Project, which is inited already, isn't touched.
+func InitProject(name string, description string, database *db.Config, isSampleCubeCreated bool) error {
+	configPath, err := getProjectConfigPath()
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(configPath); err == nil {
+		return fmt.Errorf("project is already inited: %v exists", configPath)
+	}
+
+	projectDirectory := filepath.Dir(configPath)
+
+	config, err := json.MarshalIndent(ProjectConfig{
+		Name:        name,
+		Description: description,
+		Database:    database,
+	}, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	migrationsDirectory, err := db.GetMigrationsDirectoryPath()
+	if err != nil {
+		return err
+	}
+
+	instancesDirectory, err := instance.GetInstancesDirectoryPath()
+	if err != nil {
+		return err
+	}
+
+	for _, directory := range []string{migrationsDirectory, instancesDirectory} {
+		err = createProjectDirectory(directory)
+		if err != nil {
+			return fmt.Errorf("can't create %v: %v", directory, err)
+		}
+	}
+
+	err = writeGitignore(projectDirectory)
+	if err != nil {
+		return fmt.Errorf("can't write .gitignore: %v", err)
+	}
+
+	if isSampleCubeCreated {
+		err = writeSampleCube(projectDirectory)
+		if err != nil {
+			return fmt.Errorf("can't write sample cube: %v", err)
+		}
+	}
+
+	// config is written last, so failed init can be run again
+	err = ioutil.WriteFile(configPath, append(config, '\n'), 0644)
+	if err != nil {
+		return err
+	}
+
+	utils.Infof("Project %v is inited\n", name)
+
+	if isSampleCubeCreated {
+		utils.Infof("Add instance of sample cube with: cubes instance add hello go:%v\n", getCubePackage(filepath.Join(projectDirectory, filepath.FromSlash(sampleCubeDirectory))))
+	}
+
+	return nil
+}
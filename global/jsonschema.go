@@ -0,0 +1,255 @@
+package global
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"unicode/utf8"
+)
+
+// JSONSchema is subset of JSON Schema, which is enough to check contracts of messages:
+// type, properties, required, additionalProperties, items, enum, const, numbers and strings limits
+type JSONSchema struct {
+	Type                 interface{}            `json:"type"`
+	Properties           map[string]*JSONSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	AdditionalProperties *bool                  `json:"additionalProperties"`
+	Items                *JSONSchema            `json:"items"`
+	Enum                 []interface{}          `json:"enum"`
+	Const                interface{}            `json:"const"`
+	Minimum              *float64               `json:"minimum"`
+	Maximum              *float64               `json:"maximum"`
+	MinLength            *int                   `json:"minLength"`
+	MaxLength            *int                   `json:"maxLength"`
+	Pattern              string                 `json:"pattern"`
+	MinItems             *int                   `json:"minItems"`
+	MaxItems             *int                   `json:"maxItems"`
+
+	pattern *regexp.Regexp
+}
+
+// ParseJSONSchema parses schema and compiles its patterns
+func ParseJSONSchema(rawSchema []byte) (*JSONSchema, error) {
+	var schema JSONSchema
+
+	err := json.Unmarshal(rawSchema, &schema)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse schema: %v", err)
+	}
+
+	err = schema.compile()
+	if err != nil {
+		return nil, err
+	}
+
+	return &schema, nil
+}
+
+func (schema *JSONSchema) compile() error {
+	if schema.Pattern != "" {
+		pattern, err := regexp.Compile(schema.Pattern)
+		if err != nil {
+			return fmt.Errorf("wrong pattern %v: %v", schema.Pattern, err)
+		}
+
+		schema.pattern = pattern
+	}
+
+	for _, property := range schema.Properties {
+		if property == nil {
+			continue
+		}
+
+		err := property.compile()
+		if err != nil {
+			return err
+		}
+	}
+
+	if schema.Items != nil {
+		return schema.Items.compile()
+	}
+
+	return nil
+}
+
+func getJSONType(value interface{}) string {
+	switch typedValue := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if typedValue == math.Trunc(typedValue) {
+			return "integer"
+		}
+
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return "unknown"
+	}
+}
+
+func (schema *JSONSchema) getTypes() []string {
+	switch schemaType := schema.Type.(type) {
+	case string:
+		return []string{schemaType}
+	case []interface{}:
+		types := []string{}
+		for _, item := range schemaType {
+			if text, ok := item.(string); ok {
+				types = append(types, text)
+			}
+		}
+
+		return types
+	default:
+		return nil
+	}
+}
+
+func (schema *JSONSchema) isTypeMatched(value interface{}) bool {
+	types := schema.getTypes()
+	if len(types) == 0 {
+		return true
+	}
+
+	valueType := getJSONType(value)
+	for _, schemaType := range types {
+		if schemaType == valueType || (schemaType == "number" && valueType == "integer") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Validate checks json message, error has path of the first wrong value
+func (schema *JSONSchema) Validate(data []byte) error {
+	var value interface{}
+
+	err := json.Unmarshal(data, &value)
+	if err != nil {
+		return fmt.Errorf("message isn't json: %v", err)
+	}
+
+	return schema.validate("$", value)
+}
+
+func (schema *JSONSchema) validate(path string, value interface{}) error {
+	if !schema.isTypeMatched(value) {
+		return fmt.Errorf("%v: expected %v, got %v", path, schema.Type, getJSONType(value))
+	}
+
+	if schema.Const != nil && !reflect.DeepEqual(schema.Const, value) {
+		return fmt.Errorf("%v: must be %v", path, schema.Const)
+	}
+
+	if len(schema.Enum) > 0 {
+		isFound := false
+		for _, item := range schema.Enum {
+			if reflect.DeepEqual(item, value) {
+				isFound = true
+				break
+			}
+		}
+
+		if !isFound {
+			return fmt.Errorf("%v: must be one of %v", path, schema.Enum)
+		}
+	}
+
+	switch typedValue := value.(type) {
+	case float64:
+		if schema.Minimum != nil && typedValue < *schema.Minimum {
+			return fmt.Errorf("%v: must be >= %v", path, *schema.Minimum)
+		}
+
+		if schema.Maximum != nil && typedValue > *schema.Maximum {
+			return fmt.Errorf("%v: must be <= %v", path, *schema.Maximum)
+		}
+
+	case string:
+		length := utf8.RuneCountInString(typedValue)
+		if schema.MinLength != nil && length < *schema.MinLength {
+			return fmt.Errorf("%v: must have at least %v characters", path, *schema.MinLength)
+		}
+
+		if schema.MaxLength != nil && length > *schema.MaxLength {
+			return fmt.Errorf("%v: must have at most %v characters", path, *schema.MaxLength)
+		}
+
+		if schema.pattern != nil && !schema.pattern.MatchString(typedValue) {
+			return fmt.Errorf("%v: must match %v", path, schema.Pattern)
+		}
+
+	case []interface{}:
+		if schema.MinItems != nil && len(typedValue) < *schema.MinItems {
+			return fmt.Errorf("%v: must have at least %v items", path, *schema.MinItems)
+		}
+
+		if schema.MaxItems != nil && len(typedValue) > *schema.MaxItems {
+			return fmt.Errorf("%v: must have at most %v items", path, *schema.MaxItems)
+		}
+
+		if schema.Items != nil {
+			for i, item := range typedValue {
+				err := schema.Items.validate(fmt.Sprintf("%v[%v]", path, i), item)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+	case map[string]interface{}:
+		return schema.validateObject(path, typedValue)
+	}
+
+	return nil
+}
+
+func (schema *JSONSchema) validateObject(path string, object map[string]interface{}) error {
+	for _, property := range schema.Required {
+		if _, ok := object[property]; !ok {
+			return fmt.Errorf("%v.%v: is required", path, property)
+		}
+	}
+
+	keys := []string{}
+	for key := range object {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		propertySchema, ok := schema.Properties[key]
+		if !ok {
+			if schema.AdditionalProperties != nil && !*schema.AdditionalProperties {
+				return fmt.Errorf("%v.%v: isn't allowed", path, key)
+			}
+
+			continue
+		}
+
+		if propertySchema == nil {
+			continue
+		}
+
+		err := propertySchema.validate(path+"."+key, object[key])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
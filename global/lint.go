@@ -0,0 +1,357 @@
+package global
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/akaumov/cubes/db"
+	"github.com/akaumov/cubes/instance"
+)
+
+// severities of lint findings, lint fails on errors, and on warnings too in strict mode
+const (
+	LintError   = "error"
+	LintWarning = "warning"
+)
+
+// checks of lint
+const (
+	LintMigrations   = "migrations"
+	LintSchemas      = "schemas"
+	LintInstances    = "instances"
+	LintChannels     = "channels"
+	LintUnreferenced = "unreferenced"
+)
+
+// LintFinding is problem found by static check, Target is migration, schema, instance or bus channel
+type LintFinding struct {
+	Check    string `json:"check"`
+	Severity string `json:"severity"`
+	Target   string `json:"target"`
+	Message  string `json:"message"`
+}
+
+type LintReport struct {
+	Findings []LintFinding `json:"findings"`
+	Errors   int           `json:"errors"`
+	Warnings int           `json:"warnings"`
+}
+
+func (report *LintReport) add(check string, severity string, target string, message string) {
+	report.Findings = append(report.Findings, LintFinding{
+		Check:    check,
+		Severity: severity,
+		Target:   target,
+		Message:  message,
+	})
+
+	if severity == LintError {
+		report.Errors++
+	} else {
+		report.Warnings++
+	}
+}
+
+// lintedInstance is instance with valid config and its cube meta, meta is nil when cube doesn't declare it
+type lintedInstance struct {
+	config instance.Config
+	meta   *instance.Meta
+}
+
+func lintMigrations(report *LintReport) {
+	problems, err := db.ValidateMigrations()
+	if err != nil {
+		report.add(LintMigrations, LintError, "migrations", err.Error())
+		return
+	}
+
+	for _, problem := range problems {
+		target := problem.Migration
+		if problem.Action >= 0 {
+			target = fmt.Sprintf("%v#%v", problem.Migration, problem.Action)
+		}
+
+		report.add(LintMigrations, LintError, target, problem.Message)
+	}
+}
+
+func lintSchema(report *LintReport, target string, rawSchema []byte) {
+	schema, err := ParseJSONSchema(rawSchema)
+	if err != nil {
+		report.add(LintSchemas, LintError, target, err.Error())
+		return
+	}
+
+	if len(schema.getTypes()) == 0 && len(schema.getValues()) == 0 {
+		report.add(LintSchemas, LintWarning, target, "schema doesn't declare type, any message matches it")
+	}
+
+	for _, problem := range getSchemaTypeProblems("$", schema) {
+		report.add(LintSchemas, LintError, target, problem)
+	}
+}
+
+// getSchemaTypeProblems returns unknown types of schema and its properties and items, values of them never match
+func getSchemaTypeProblems(path string, schema *JSONSchema) []string {
+	if schema == nil {
+		return nil
+	}
+
+	problems := []string{}
+
+	for _, schemaType := range schema.getTypes() {
+		switch schemaType {
+		case "null", "boolean", "object", "array", "number", "integer", "string":
+			break
+		default:
+			problems = append(problems, fmt.Sprintf("%v: type '%v' is unknown", path, schemaType))
+		}
+	}
+
+	properties := []string{}
+	for property := range schema.Properties {
+		properties = append(properties, property)
+	}
+
+	sort.Strings(properties)
+
+	for _, property := range properties {
+		problems = append(problems, getSchemaTypeProblems(path+"."+property, schema.Properties[property])...)
+	}
+
+	return append(problems, getSchemaTypeProblems(path+"[]", schema.Items)...)
+}
+
+func lintSchemas(report *LintReport, config ProjectConfig, instances []lintedInstance) {
+	channels := []string{}
+	for channel := range config.ChannelSchemas {
+		channels = append(channels, channel)
+	}
+
+	sort.Strings(channels)
+
+	for _, channel := range channels {
+		rawSchema, err := ioutil.ReadFile(config.ChannelSchemas[channel])
+		if err != nil {
+			report.add(LintSchemas, LintError, "channelSchemas."+channel, err.Error())
+			continue
+		}
+
+		lintSchema(report, "channelSchemas."+channel, rawSchema)
+	}
+
+	for _, linted := range instances {
+		if linted.meta == nil {
+			continue
+		}
+
+		for _, cubeChannel := range getSortedMetaChannels(linted.meta.Channels) {
+			if rawSchema := linted.meta.Channels[cubeChannel].Schema; len(rawSchema) > 0 {
+				lintSchema(report, linted.config.Name+"."+cubeChannel, rawSchema)
+			}
+		}
+	}
+}
+
+func getSortedMetaChannels(channels map[string]instance.ChannelMeta) []string {
+	keys := []string{}
+	for key := range channels {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// lintInstances checks configs of instances and returns valid ones with their meta
+func lintInstances(report *LintReport) []lintedInstance {
+	names, err := instance.GetNames()
+	if err != nil {
+		report.add(LintInstances, LintError, "instances", err.Error())
+		return nil
+	}
+
+	instances := []lintedInstance{}
+
+	for _, name := range names {
+		err := instance.CheckConfig(name)
+		if err != nil {
+			report.add(LintInstances, LintError, name, err.Error())
+			continue
+		}
+
+		config, err := instance.GetConfig(name)
+		if err != nil {
+			report.add(LintInstances, LintError, name, err.Error())
+			continue
+		}
+
+		meta, err := instance.GetMeta(*config)
+		if err != nil {
+			report.add(LintInstances, LintWarning, name, "can't read cube meta, its channels and params aren't checked: "+err.Error())
+		}
+
+		instances = append(instances, lintedInstance{
+			config: *config,
+			meta:   meta,
+		})
+	}
+
+	return instances
+}
+
+func lintChannelMappings(report *LintReport, instances []lintedInstance) {
+	for _, linted := range instances {
+		name := linted.config.Name
+
+		if linted.meta != nil {
+			for _, cubeChannel := range getSortedMetaChannels(linted.meta.Channels) {
+				direction := linted.meta.Channels[cubeChannel].Direction
+				if direction != instance.ChannelIn && direction != instance.ChannelOut {
+					report.add(LintChannels, LintError, name+"."+cubeChannel, fmt.Sprintf("direction '%v' is wrong, it's in or out", direction))
+				}
+			}
+		}
+
+		mapping := map[string]string{}
+		for cubeChannel, busChannel := range linted.config.ChannelsMapping {
+			mapping[string(cubeChannel)] = string(busChannel)
+		}
+
+		for _, cubeChannel := range getSortedChannels(mapping) {
+			target := name + "." + cubeChannel
+			busChannel := mapping[cubeChannel]
+
+			if busChannel == "" {
+				report.add(LintChannels, LintError, target, "it's mapped to empty bus channel")
+				continue
+			}
+
+			if linted.meta == nil {
+				continue
+			}
+
+			channelMeta, ok := linted.meta.Channels[cubeChannel]
+			if !ok {
+				report.add(LintChannels, LintError, target, "cube doesn't declare this channel, mapping isn't used")
+				continue
+			}
+
+			if channelMeta.Direction == instance.ChannelOut && strings.ContainsAny(busChannel, "*>") {
+				report.add(LintChannels, LintError, target, "out channel is mapped to wildcard "+busChannel+", messages can't be published to it")
+			}
+		}
+	}
+}
+
+// lintUnreferencedChannels finds bus channels, which nobody consumes or produces, and channels of project config,
+// which instances don't use. Channel of instance without meta can be both in and out, so it isn't reported.
+func lintUnreferencedChannels(report *LintReport, config ProjectConfig, instances []lintedInstance) {
+	type endpoint struct {
+		name       string
+		busChannel string
+		direction  string
+	}
+
+	endpoints := []endpoint{}
+
+	for _, linted := range instances {
+		channels := getInstanceChannels(linted.config, linted.meta)
+
+		for _, cubeChannel := range getSortedChannels(channels) {
+			direction := ""
+			if linted.meta != nil {
+				direction = linted.meta.Channels[cubeChannel].Direction
+			}
+
+			endpoints = append(endpoints, endpoint{
+				name:       linted.config.Name + "." + cubeChannel,
+				busChannel: channels[cubeChannel],
+				direction:  direction,
+			})
+		}
+	}
+
+	isMatched := func(busChannel string, direction string) bool {
+		for _, other := range endpoints {
+			if other.direction != direction && other.direction != "" {
+				continue
+			}
+
+			if isChannelMatched(busChannel, other.busChannel) || isChannelMatched(other.busChannel, busChannel) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	for _, current := range endpoints {
+		if current.direction == instance.ChannelOut && !isMatched(current.busChannel, instance.ChannelIn) {
+			report.add(LintUnreferenced, LintWarning, current.busChannel, "messages of "+current.name+" aren't consumed by any instance")
+		}
+
+		if current.direction == instance.ChannelIn && !isMatched(current.busChannel, instance.ChannelOut) {
+			report.add(LintUnreferenced, LintWarning, current.busChannel, current.name+" consumes it, but no instance publishes to it")
+		}
+	}
+
+	configChannels := map[string]string{}
+	for channel := range config.ChannelSchemas {
+		configChannels[channel] = "channelSchemas"
+	}
+
+	for channel := range config.ChannelLimits {
+		configChannels[channel] = "channelLimits"
+	}
+
+	for _, persistentChannel := range config.PersistentChannels {
+		configChannels[persistentChannel.Channel] = "persistentChannels"
+	}
+
+	for _, channel := range getSortedChannels(configChannels) {
+		isUsed := false
+		for _, current := range endpoints {
+			isUsed = isUsed || isChannelMatched(channel, current.busChannel)
+		}
+
+		if !isUsed {
+			report.add(LintUnreferenced, LintWarning, channel, "channel of "+configChannels[channel]+" isn't used by any instance")
+		}
+	}
+}
+
+func getSortedChannels(channels map[string]string) []string {
+	keys := []string{}
+	for key := range channels {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// RunLint runs static checks of project without bus, database and docker: migrations, schemas of channels,
+// configs of instances, channels mappings and bus channels, which nobody uses
+func RunLint() (*LintReport, error) {
+	config, err := GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("can't read project config: %v", err)
+	}
+
+	report := LintReport{
+		Findings: []LintFinding{},
+	}
+
+	lintMigrations(&report)
+
+	instances := lintInstances(&report)
+
+	lintSchemas(&report, *config, instances)
+	lintChannelMappings(&report, instances)
+	lintUnreferencedChannels(&report, *config, instances)
+
+	return &report, nil
+}
@@ -0,0 +1,240 @@
+package global
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/akaumov/cubes/utils"
+)
+
+// types of notification sinks: webhook gets event as json, slack gets message of incoming webhook
+const (
+	NotificationWebhook = "webhook"
+	NotificationSlack   = "slack"
+)
+
+const notificationTimeout = 10 * time.Second
+
+// NotificationSink sends lifecycle events of selected types to webhook or slack
+type NotificationSink struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+
+	// Url is url of webhook, it can refer to secret: ${secret:slack-webhook}
+	Url string `json:"url"`
+
+	// Events are types of events, which are sent, they can end with '*': instance.*
+	Events []string `json:"events"`
+
+	// Profiles limit events to events of processes with profiles: prod, all events are sent when it's empty
+	Profiles []string `json:"profiles,omitempty"`
+}
+
+// NotificationPayload is body of generic webhook
+type NotificationPayload struct {
+	Project string      `json:"project"`
+	Event   utils.Event `json:"event"`
+}
+
+func checkNotificationSink(sink NotificationSink) error {
+	if sink.Name == "" {
+		return fmt.Errorf("notification name is required")
+	}
+
+	if sink.Type != NotificationWebhook && sink.Type != NotificationSlack {
+		return fmt.Errorf("wrong type %v of notification %v, it can be %v or %v", sink.Type, sink.Name, NotificationWebhook, NotificationSlack)
+	}
+
+	if sink.Url == "" {
+		return fmt.Errorf("url of notification %v is required", sink.Name)
+	}
+
+	if len(sink.Events) == 0 {
+		return fmt.Errorf("events of notification %v are required", sink.Name)
+	}
+
+	return nil
+}
+
+func isNotificationMatched(sink NotificationSink, event utils.Event) bool {
+	if len(sink.Profiles) > 0 {
+		isProfileMatched := false
+
+		for _, profile := range sink.Profiles {
+			if profile == event.Profile {
+				isProfileMatched = true
+			}
+		}
+
+		if !isProfileMatched {
+			return false
+		}
+	}
+
+	for _, filter := range sink.Events {
+		if utils.IsEventMatched(filter, event.Type) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func formatSlackMessage(project string, event utils.Event) string {
+	text := fmt.Sprintf("*%v*: %v", project, event.Type)
+
+	if event.Profile != "" {
+		text += " (" + event.Profile + ")"
+	}
+
+	if event.Subject != "" {
+		text += " `" + event.Subject + "`"
+	}
+
+	if event.Message != "" {
+		text += "\n" + event.Message
+	}
+
+	return text
+}
+
+// sendNotification posts event to sink, url is resolved right before request, so secrets aren't kept
+func sendNotification(project string, sink NotificationSink, event utils.Event) error {
+	url, err := utils.ResolveSecrets(sink.Url)
+	if err != nil {
+		return err
+	}
+
+	var payload interface{} = NotificationPayload{
+		Project: project,
+		Event:   event,
+	}
+
+	if sink.Type == NotificationSlack {
+		payload = map[string]string{
+			"text": formatSlackMessage(project, event),
+		}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	client := http.Client{
+		Timeout: notificationTimeout,
+	}
+
+	response, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		message, _ := ioutil.ReadAll(response.Body)
+		return fmt.Errorf("%v: %v", response.Status, strings.TrimSpace(string(message)))
+	}
+
+	return nil
+}
+
+// Notifier sends lifecycle events of project to notification sinks until it's closed
+type Notifier struct {
+	subscription *utils.EventsSubscription
+}
+
+// StartNotifier subscribes to lifecycle events and sends them to notification sinks of project config,
+// it returns nil when project has no notifications
+func StartNotifier() (*Notifier, error) {
+	config, err := GetConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(config.Notifications) == 0 {
+		return nil, nil
+	}
+
+	for _, sink := range config.Notifications {
+		err = checkNotificationSink(sink)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	subscription, err := utils.SubscribeEvents(func(event utils.Event) {
+		for _, sink := range config.Notifications {
+			if !isNotificationMatched(sink, event) {
+				continue
+			}
+
+			err := sendNotification(config.Name, sink, event)
+			if err != nil {
+				utils.Warningf("Can't send %v event to notification %v: %v\n", event.Type, sink.Name, err)
+			}
+		}
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	utils.Infof("Sending events to %v notifications\n", len(config.Notifications))
+
+	return &Notifier{
+		subscription: subscription,
+	}, nil
+}
+
+func (notifier *Notifier) Close() {
+	notifier.subscription.Close()
+}
+
+// SendTestNotification sends test event to notification sink of project, all sinks get it when name is empty
+func SendTestNotification(name string) error {
+	config, err := GetConfig()
+	if err != nil {
+		return err
+	}
+
+	event := utils.Event{
+		Time:    time.Now(),
+		Type:    "notification.test",
+		Subject: name,
+		Message: "test notification from cubes",
+		Profile: utils.GetProfile(),
+	}
+
+	isSent := false
+
+	for _, sink := range config.Notifications {
+		if name != "" && sink.Name != name {
+			continue
+		}
+
+		err = checkNotificationSink(sink)
+		if err == nil {
+			err = sendNotification(config.Name, sink, event)
+		}
+
+		if err != nil {
+			return fmt.Errorf("can't send test event to notification %v: %v", sink.Name, err)
+		}
+
+		utils.Infof("Test event is sent to notification %v\n", sink.Name)
+		isSent = true
+	}
+
+	if !isSent {
+		return fmt.Errorf("project has no notification %v", name)
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package global
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/akaumov/cubes/db"
+	"github.com/akaumov/cubes/utils"
+)
+
+// ProjectProfile is environment of project, its settings replace settings of project config, which are set.
+// Environment variables override profile settings.
+type ProjectProfile struct {
+	// Database replaces fields of project database, which are set
+	Database *db.Config `json:"database,omitempty"`
+
+	BusHost           string `json:"busHost,omitempty"`
+	BusPort           int    `json:"busPort,omitempty"`
+	BusMonitoringPort int    `json:"busMonitoringPort,omitempty"`
+	DefaultRuntime    string `json:"defaultRuntime,omitempty"`
+
+	// InstanceParams are params of instances by their names, they replace params of instances configs on start
+	InstanceParams map[string]map[string]string `json:"instanceParams,omitempty"`
+}
+
+func getProfilesNames(config ProjectConfig) []string {
+	names := []string{}
+	for name := range config.Profiles {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func applyProfileDatabase(config *ProjectConfig, database *db.Config) {
+	if database == nil {
+		return
+	}
+
+	if config.Database == nil {
+		config.Database = &db.Config{
+			Host: "localhost",
+			Port: defaultDatabasePort,
+		}
+	}
+
+	if database.Host != "" {
+		config.Database.Host = database.Host
+	}
+
+	if database.Port != 0 {
+		config.Database.Port = database.Port
+	}
+
+	if database.Name != "" {
+		config.Database.Name = database.Name
+	}
+
+	if database.User != "" {
+		config.Database.User = database.User
+	}
+
+	if database.Password != "" {
+		config.Database.Password = database.Password
+	}
+
+	if database.MaxOpenConns != 0 {
+		config.Database.MaxOpenConns = database.MaxOpenConns
+	}
+
+	if database.MaxIdleConns != 0 {
+		config.Database.MaxIdleConns = database.MaxIdleConns
+	}
+
+	if database.ConnMaxLifetimeSeconds != 0 {
+		config.Database.ConnMaxLifetimeSeconds = database.ConnMaxLifetimeSeconds
+	}
+
+	if database.StatementTimeoutSeconds != 0 {
+		config.Database.StatementTimeoutSeconds = database.StatementTimeoutSeconds
+	}
+}
+
+// applyConfigProfile replaces settings of project config with settings of profile selected with CUBES_ENV
+func applyConfigProfile(config *ProjectConfig) error {
+	name := utils.GetProfile()
+	if name == "" {
+		return nil
+	}
+
+	profile, ok := config.Profiles[name]
+	if !ok {
+		return fmt.Errorf("profile %v doesn't exist in project config, profiles are: %v",
+			name, strings.Join(getProfilesNames(*config), ", "))
+	}
+
+	applyProfileDatabase(config, profile.Database)
+
+	if profile.BusHost != "" {
+		config.BusHost = profile.BusHost
+	}
+
+	if profile.BusPort != 0 {
+		config.BusPort = profile.BusPort
+	}
+
+	if profile.BusMonitoringPort != 0 {
+		config.BusMonitoringPort = profile.BusMonitoringPort
+	}
+
+	if profile.DefaultRuntime != "" {
+		config.DefaultRuntime = profile.DefaultRuntime
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package global
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/akaumov/cubes/executor"
+	"github.com/akaumov/cubes/instance"
+	"github.com/akaumov/cubes/utils"
+	"github.com/nats-io/nats.go"
+)
+
+const protocolProcess = "protocol"
+
+// ServeBusProtocol answers protocol exchanges of instances with protocol of bus until Ctrl+C, instance, which bus
+// doesn't serve, gets error and exits
+func ServeBusProtocol() error {
+	connection, err := connectRunningBus()
+	if err != nil {
+		return err
+	}
+
+	defer connection.Close()
+
+	_, err = connection.Subscribe(executor.ProtocolChannel, func(message *nats.Msg) {
+		err := answerBusProtocol(message)
+		if err != nil {
+			utils.Warningf("Can't answer protocol exchange: %v\n", err)
+		}
+	})
+
+	if err != nil {
+		return fmt.Errorf("can't subscribe to protocol exchanges: %v", err)
+	}
+
+	utils.Infof("Answering protocol exchanges with protocol %v\n", utils.BusProtocolVersion)
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	<-interrupt
+	return nil
+}
+
+func answerBusProtocol(message *nats.Msg) error {
+	var request executor.ProtocolRequest
+	err := json.Unmarshal(message.Data, &request)
+	if err != nil {
+		return fmt.Errorf("can't parse protocol of instance: %v", err)
+	}
+
+	response := executor.ProtocolResponse{Protocol: utils.BusProtocolVersion}
+
+	err = instance.CheckBusProtocol(request.Instance, request.Protocol, utils.BusProtocolVersion)
+	if err != nil {
+		utils.Warningf("Instance %v is refused: %v\n", request.Instance, err)
+		response.Error = err.Error()
+	} else {
+		utils.Infof("Instance %v speaks bus protocol %v\n", request.Instance, request.Protocol)
+	}
+
+	packedResponse, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+
+	return message.Respond(packedResponse)
+}
+
+// startProtocolExchange runs "cubes bus protocol" in background, so instances exchange protocol with bus,
+// when they connect
+func startProtocolExchange() error {
+	err := startBusProcess(protocolProcess, "bus", "protocol")
+	if err != nil {
+		return fmt.Errorf("can't start protocol exchange: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,243 @@
+package global
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/akaumov/cubes/instance"
+	"github.com/akaumov/cubes/utils"
+	"github.com/nats-io/nats.go"
+)
+
+// Reliable channels are persistent channels, which every consuming instance reads with its own durable consumer.
+// Messages are delivered to _cubes.reliable.<consumer>, instance acknowledges message by publishing +ACK to its reply
+// subject, -NAK asks for redelivery at once. Message, which isn't acknowledged in ack timeout, is redelivered until
+// max deliveries, so messages aren't lost when instance crashes while handling them.
+const reliableChannelPrefix = cubesChannelPrefix + "reliable."
+
+const defaultAckTimeoutSeconds = 30
+
+// ReliableConsumer is delivery of reliable channel to instance
+type ReliableConsumer struct {
+	Instance string `json:"instance"`
+	Channel  string `json:"channel"`
+	Stream   string `json:"stream"`
+	Name     string `json:"name"`
+
+	// DeliveryChannel is channel, where instance gets messages of reliable channel
+	DeliveryChannel string `json:"deliveryChannel"`
+}
+
+// ReliableConsumerState is progress of reliable consumer on running bus
+type ReliableConsumerState struct {
+	ReliableConsumer
+
+	// Pending are messages, which aren't delivered yet
+	Pending uint64 `json:"pending"`
+
+	// Unacknowledged are delivered messages, which wait for acknowledgement
+	Unacknowledged uint64 `json:"unacknowledged"`
+	Redelivered    uint64 `json:"redelivered"`
+	Error          string `json:"error,omitempty"`
+}
+
+type consumerInfoResponse struct {
+	streamResponse
+	NumAckPending  uint64 `json:"num_ack_pending"`
+	NumRedelivered uint64 `json:"num_redelivered"`
+	NumPending     uint64 `json:"num_pending"`
+}
+
+// getConsumerName converts instance and channel to name of durable consumer, which can't contain dots and wildcards
+func getConsumerName(instanceName string, channel string) string {
+	return strings.NewReplacer(".", "_", "*", "_", ">", "_").Replace(instanceName + "_" + channel)
+}
+
+// getReliableConsumers returns instances consuming reliable channels of project
+func getReliableConsumers(config ProjectConfig) ([]ReliableConsumer, error) {
+	consumers := []ReliableConsumer{}
+
+	hasReliableChannels := false
+	for _, persistentChannel := range config.PersistentChannels {
+		hasReliableChannels = hasReliableChannels || persistentChannel.IsReliable
+	}
+
+	if !hasReliableChannels {
+		return consumers, nil
+	}
+
+	busChannels, err := GetBusChannels()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, persistentChannel := range config.PersistentChannels {
+		if !persistentChannel.IsReliable {
+			continue
+		}
+
+		for _, busChannel := range busChannels.Channels {
+			if !isChannelMatched(persistentChannel.Channel, busChannel.Channel) {
+				continue
+			}
+
+			for _, endpoint := range busChannel.Endpoints {
+				if endpoint.Direction == instance.ChannelOut {
+					continue
+				}
+
+				name := getConsumerName(endpoint.Instance, busChannel.Channel)
+
+				consumers = append(consumers, ReliableConsumer{
+					Instance:        endpoint.Instance,
+					Channel:         busChannel.Channel,
+					Stream:          getStreamName(persistentChannel.Channel),
+					Name:            name,
+					DeliveryChannel: reliableChannelPrefix + name,
+				})
+			}
+		}
+	}
+
+	sort.Slice(consumers, func(i, j int) bool {
+		return consumers[i].Name < consumers[j].Name
+	})
+
+	return consumers, nil
+}
+
+func getPersistentChannel(config ProjectConfig, stream string) PersistentChannel {
+	for _, persistentChannel := range config.PersistentChannels {
+		if getStreamName(persistentChannel.Channel) == stream {
+			return persistentChannel
+		}
+	}
+
+	return PersistentChannel{}
+}
+
+func createReliableConsumer(connection *nats.Conn, config ProjectConfig, consumer ReliableConsumer) error {
+	persistentChannel := getPersistentChannel(config, consumer.Stream)
+
+	ackTimeout := persistentChannel.AckTimeoutSeconds
+	if ackTimeout == 0 {
+		ackTimeout = defaultAckTimeoutSeconds
+	}
+
+	return createDurableConsumer(connection, consumer.Stream, consumerConfig{
+		DeliverSubject: consumer.DeliveryChannel,
+		DeliverPolicy:  "new",
+		AckPolicy:      "explicit",
+		ReplayPolicy:   "instant",
+		FilterSubject:  consumer.Channel,
+		DurableName:    consumer.Name,
+		AckWait:        int64(time.Duration(ackTimeout) * time.Second),
+		MaxDeliver:     persistentChannel.MaxDeliveries,
+	})
+}
+
+// deleteConsumer removes durable consumer with its position and unacknowledged messages
+func deleteConsumer(connection *nats.Conn, consumer ReliableConsumer) error {
+	rawResponse, err := connection.Request("$JS.API.CONSUMER.DELETE."+consumer.Stream+"."+consumer.Name, nil, busRequestTimeout)
+	if err != nil {
+		return err
+	}
+
+	var response streamResponse
+	err = json.Unmarshal(rawResponse.Data, &response)
+	if err != nil {
+		return fmt.Errorf("can't parse bus response: %v", err)
+	}
+
+	if response.Error != nil {
+		return errors.New(response.Error.Description)
+	}
+
+	return nil
+}
+
+// createReliableConsumers creates durable consumers of instances consuming reliable channels, consumer of new instance
+// starts with new messages, existing consumers keep their positions
+func createReliableConsumers(connection *nats.Conn, config ProjectConfig) error {
+	consumers, err := getReliableConsumers(config)
+	if err != nil {
+		return fmt.Errorf("can't read consumers of reliable channels: %v", err)
+	}
+
+	for _, consumer := range consumers {
+		err = createReliableConsumer(connection, config, consumer)
+		if err != nil {
+			return fmt.Errorf("can't create consumer of %v for %v: %v", consumer.Channel, consumer.Instance, err)
+		}
+
+		utils.Debugf("Instance %v gets reliable channel %v from %v\n", consumer.Instance, consumer.Channel, consumer.DeliveryChannel)
+	}
+
+	return nil
+}
+
+func getConsumerInfo(connection *nats.Conn, consumer ReliableConsumer) (*consumerInfoResponse, error) {
+	rawResponse, err := connection.Request("$JS.API.CONSUMER.INFO."+consumer.Stream+"."+consumer.Name, nil, busRequestTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	var response consumerInfoResponse
+	err = json.Unmarshal(rawResponse.Data, &response)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse bus response: %v", err)
+	}
+
+	if response.Error != nil {
+		return nil, errors.New(response.Error.Description)
+	}
+
+	return &response, nil
+}
+
+// GetReliableConsumers returns consumers of reliable channels with their pending and unacknowledged messages
+func GetReliableConsumers() ([]ReliableConsumerState, error) {
+	config, err := GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("can't read project config: %v", err)
+	}
+
+	consumers, err := getReliableConsumers(*config)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(consumers) == 0 {
+		return []ReliableConsumerState{}, nil
+	}
+
+	connection, err := connectRunningBus()
+	if err != nil {
+		return nil, err
+	}
+
+	defer connection.Close()
+
+	states := []ReliableConsumerState{}
+
+	for _, consumer := range consumers {
+		state := ReliableConsumerState{ReliableConsumer: consumer}
+
+		info, err := getConsumerInfo(connection, consumer)
+		if err != nil {
+			state.Error = err.Error()
+		} else {
+			state.Pending = info.NumPending
+			state.Unacknowledged = info.NumAckPending
+			state.Redelivered = info.NumRedelivered
+		}
+
+		states = append(states, state)
+	}
+
+	return states, nil
+}
@@ -0,0 +1,278 @@
+package global
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akaumov/cubes/instance"
+	"github.com/akaumov/cubes/utils"
+	"github.com/nats-io/nats.go"
+)
+
+const replayIdleTimeout = 5 * time.Second
+
+// ReplayPosition is position in stream: sequence number or time, zero position is start or end of stream
+type ReplayPosition struct {
+	Sequence uint64
+	Time     time.Time
+}
+
+// ParseReplayPosition parses sequence number, RFC3339 time or duration before now: 42, 2018-05-01T10:00:00Z, 2h
+func ParseReplayPosition(rawPosition string) (*ReplayPosition, error) {
+	if rawPosition == "" {
+		return &ReplayPosition{}, nil
+	}
+
+	sequence, err := strconv.ParseUint(rawPosition, 10, 64)
+	if err == nil {
+		return &ReplayPosition{Sequence: sequence}, nil
+	}
+
+	moment, err := time.Parse(time.RFC3339, rawPosition)
+	if err == nil {
+		return &ReplayPosition{Time: moment}, nil
+	}
+
+	duration, err := time.ParseDuration(rawPosition)
+	if err == nil {
+		return &ReplayPosition{Time: time.Now().Add(-duration)}, nil
+	}
+
+	return nil, fmt.Errorf("wrong position %v, it must be sequence, RFC3339 time or duration", rawPosition)
+}
+
+type consumerConfig struct {
+	DeliverSubject string `json:"deliver_subject"`
+	DeliverPolicy  string `json:"deliver_policy"`
+	OptStartSeq    uint64 `json:"opt_start_seq,omitempty"`
+	OptStartTime   string `json:"opt_start_time,omitempty"`
+	AckPolicy      string `json:"ack_policy"`
+	ReplayPolicy   string `json:"replay_policy"`
+	FilterSubject  string `json:"filter_subject"`
+	DurableName    string `json:"durable_name,omitempty"`
+	MaxAckPending  int    `json:"max_ack_pending,omitempty"`
+	AckWait        int64  `json:"ack_wait,omitempty"`
+	MaxDeliver     int    `json:"max_deliver,omitempty"`
+}
+
+type createConsumerRequest struct {
+	StreamName string         `json:"stream_name"`
+	Config     consumerConfig `json:"config"`
+}
+
+type consumerResponse struct {
+	streamResponse
+	Name string `json:"name"`
+}
+
+// storedMessage is position of message delivered by stream consumer, it's kept in reply subject:
+// $JS.ACK.<stream>.<consumer>.<delivered>.<stream seq>.<consumer seq>.<time>.<pending>, newer servers add domain and account
+type storedMessage struct {
+	Sequence uint64
+	Time     time.Time
+	Pending  uint64
+}
+
+func parseStoredMessage(reply string) (*storedMessage, error) {
+	tokens := strings.Split(reply, ".")
+	if len(tokens) < 9 || tokens[0] != "$JS" || tokens[1] != "ACK" {
+		return nil, fmt.Errorf("wrong reply subject of stored message: %v", reply)
+	}
+
+	offset := 0
+	if len(tokens) > 9 {
+		offset = 2
+	}
+
+	sequence, err := strconv.ParseUint(tokens[5+offset], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp, err := strconv.ParseInt(tokens[7+offset], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	pending, err := strconv.ParseUint(tokens[8+offset], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return &storedMessage{
+		Sequence: sequence,
+		Time:     time.Unix(0, timestamp),
+		Pending:  pending,
+	}, nil
+}
+
+// getChannelStream returns stream of persistent channel, which keeps messages of channel
+func getChannelStream(config ProjectConfig, channel string) (string, error) {
+	for _, persistentChannel := range config.PersistentChannels {
+		if isChannelMatched(persistentChannel.Channel, channel) {
+			return getStreamName(persistentChannel.Channel), nil
+		}
+	}
+
+	return "", fmt.Errorf("channel %v isn't persistent", channel)
+}
+
+// checkReplayTarget checks that instance consumes channel and returns other instances, which will get replayed messages too
+func checkReplayTarget(channel string, target string) ([]string, error) {
+	channels, err := GetBusChannels()
+	if err != nil {
+		return nil, err
+	}
+
+	isConsumer := false
+	others := []string{}
+
+	for _, busChannel := range channels.Channels {
+		if busChannel.Channel != channel {
+			continue
+		}
+
+		for _, endpoint := range busChannel.Endpoints {
+			if endpoint.Direction == instance.ChannelOut {
+				continue
+			}
+
+			if endpoint.Instance == target {
+				isConsumer = true
+			} else {
+				others = append(others, endpoint.Instance)
+			}
+		}
+	}
+
+	if !isConsumer {
+		return nil, fmt.Errorf("instance %v doesn't consume channel %v", target, channel)
+	}
+
+	return others, nil
+}
+
+// Replay republishes stored messages of persistent channel from position until position to channel, returns number of
+// replayed messages. Instances can't be addressed on bus, so every consumer of channel gets replayed messages, target
+// instance is checked to consume channel and replay is refused when others consume it too unless it's forced.
+func Replay(channel string, from ReplayPosition, until ReplayPosition, target string, isForced bool) (int, error) {
+	// messages come to inbox, so they're published to channel they're filtered by
+	if channel == "" || strings.ContainsAny(channel, "*>") {
+		return 0, fmt.Errorf("channel without wildcards is required")
+	}
+
+	config, err := GetConfig()
+	if err != nil {
+		return 0, fmt.Errorf("can't read project config: %v", err)
+	}
+
+	stream, err := getChannelStream(*config, channel)
+	if err != nil {
+		return 0, err
+	}
+
+	if target != "" {
+		others, err := checkReplayTarget(channel, target)
+		if err != nil {
+			return 0, err
+		}
+
+		if len(others) > 0 && !isForced {
+			return 0, fmt.Errorf("instances %v consume channel %v too, they would get replayed messages", strings.Join(others, ", "), channel)
+		}
+	}
+
+	connection, err := connectRunningBus()
+	if err != nil {
+		return 0, err
+	}
+
+	defer connection.Close()
+
+	inbox := nats.NewInbox()
+	messages := make(chan *nats.Msg, 256)
+
+	subscription, err := connection.ChanSubscribe(inbox, messages)
+	if err != nil {
+		return 0, err
+	}
+
+	defer subscription.Unsubscribe()
+
+	consumer := consumerConfig{
+		DeliverSubject: inbox,
+		DeliverPolicy:  "all",
+		AckPolicy:      "none",
+		ReplayPolicy:   "instant",
+		FilterSubject:  channel,
+	}
+
+	if from.Sequence > 0 {
+		consumer.DeliverPolicy = "by_start_sequence"
+		consumer.OptStartSeq = from.Sequence
+	} else if !from.Time.IsZero() {
+		consumer.DeliverPolicy = "by_start_time"
+		consumer.OptStartTime = from.Time.UTC().Format(time.RFC3339Nano)
+	}
+
+	rawRequest, err := json.Marshal(createConsumerRequest{StreamName: stream, Config: consumer})
+	if err != nil {
+		return 0, err
+	}
+
+	rawResponse, err := connection.Request("$JS.API.CONSUMER.CREATE."+stream, rawRequest, busRequestTimeout)
+	if err != nil {
+		return 0, fmt.Errorf("can't read stream: %v", err)
+	}
+
+	var response consumerResponse
+	err = json.Unmarshal(rawResponse.Data, &response)
+	if err != nil {
+		return 0, fmt.Errorf("can't parse bus response: %v", err)
+	}
+
+	if response.Error != nil {
+		return 0, fmt.Errorf("can't read stream: %v", response.Error.Description)
+	}
+
+	defer connection.Request("$JS.API.CONSUMER.DELETE."+stream+"."+response.Name, nil, busRequestTimeout)
+
+	replayed := 0
+
+	for {
+		var message *nats.Msg
+
+		select {
+		case message = <-messages:
+		case <-time.After(replayIdleTimeout):
+			return replayed, nil
+		}
+
+		position, err := parseStoredMessage(message.Reply)
+		if err != nil {
+			return replayed, err
+		}
+
+		if (until.Sequence > 0 && position.Sequence > until.Sequence) || (!until.Time.IsZero() && position.Time.After(until.Time)) {
+			return replayed, nil
+		}
+
+		err = connection.Publish(channel, message.Data)
+		if err != nil {
+			return replayed, fmt.Errorf("can't publish message %v: %v", position.Sequence, err)
+		}
+
+		replayed++
+
+		if position.Pending == 0 {
+			return replayed, connection.Flush()
+		}
+
+		if replayed%1000 == 0 {
+			utils.Infof("Replayed %v messages\n", replayed)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package global
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/akaumov/cubes/utils"
+)
+
+const defaultDeadLetterChannel = "deadletter"
+
+// validatorQueueGroup lets several validators share channels without dead-lettering the same message twice
+const validatorQueueGroup = "cubes-validator"
+
+// DeadLetter is published to dead letter channel when message doesn't match schema of its channel
+type DeadLetter struct {
+	Channel string          `json:"channel"`
+	Error   string          `json:"error"`
+	Message json.RawMessage `json:"message"`
+}
+
+// getChannelSchemas loads schemas of channels from files set in project config
+func getChannelSchemas(config ProjectConfig) (map[string]*JSONSchema, error) {
+	schemas := map[string]*JSONSchema{}
+
+	for channel, schemaPath := range config.ChannelSchemas {
+		rawSchema, err := ioutil.ReadFile(schemaPath)
+		if err != nil {
+			return nil, fmt.Errorf("can't read schema of channel %v: %v", channel, err)
+		}
+
+		schema, err := ParseJSONSchema(rawSchema)
+		if err != nil {
+			return nil, fmt.Errorf("wrong schema of channel %v: %v", channel, err)
+		}
+
+		schemas[channel] = schema
+	}
+
+	return schemas, nil
+}
+
+func getDeadLetterChannel(config ProjectConfig, channel string) string {
+	deadLetterChannel := config.DeadLetterChannel
+	if deadLetterChannel == "" {
+		deadLetterChannel = defaultDeadLetterChannel
+	}
+
+	return deadLetterChannel + "." + channel
+}
+
+// ValidateMessage checks message with schema of channel, channels without schema accept any message
+func ValidateMessage(channel string, data []byte) error {
+	config, err := GetConfig()
+	if err != nil {
+		return fmt.Errorf("can't read project config: %v", err)
+	}
+
+	schemaPath, ok := config.ChannelSchemas[channel]
+	if !ok {
+		return nil
+	}
+
+	schemas, err := getChannelSchemas(ProjectConfig{
+		ChannelSchemas: map[string]string{channel: schemaPath},
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return schemas[channel].Validate(data)
+}
+
+// getMessageChecks returns checks of channels with schemas or size limits
+func getMessageChecks(config ProjectConfig) (map[string]func(data []byte) error, error) {
+	schemas, err := getChannelSchemas(config)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkChannelLimits(config.ChannelLimits)
+	if err != nil {
+		return nil, err
+	}
+
+	checks := map[string]func(data []byte) error{}
+
+	for channel, limit := range config.ChannelLimits {
+		if limit.MaxMessageSize == 0 {
+			continue
+		}
+
+		channel := channel
+		limit := limit
+
+		checks[channel] = func(data []byte) error {
+			if len(data) > limit.MaxMessageSize {
+				return fmt.Errorf("message of %v bytes exceeds limit of channel %v: %v bytes", len(data), channel, limit.MaxMessageSize)
+			}
+
+			return nil
+		}
+	}
+
+	for channel, schema := range schemas {
+		schema := schema
+		checkSize := checks[channel]
+
+		checks[channel] = func(data []byte) error {
+			if checkSize != nil {
+				err := checkSize(data)
+				if err != nil {
+					return err
+				}
+			}
+
+			decompressed, err := decompressMessage(data)
+			if err != nil {
+				return err
+			}
+
+			return schema.Validate(decompressed)
+		}
+	}
+
+	return checks, nil
+}
+
+// ServeSchemaValidation checks messages of channels with schemas or size limits until Ctrl+C is pressed,
+// wrong messages are published to dead letter channel: deadletter.<channel>
+func ServeSchemaValidation() error {
+	config, err := GetConfig()
+	if err != nil {
+		return fmt.Errorf("can't read project config: %v", err)
+	}
+
+	checks, err := getMessageChecks(*config)
+	if err != nil {
+		return err
+	}
+
+	if len(checks) == 0 {
+		return fmt.Errorf("project config doesn't have channelSchemas or channelLimits with maxMessageSize")
+	}
+
+	connection, err := connectRunningBus()
+	if err != nil {
+		return err
+	}
+
+	defer connection.Close()
+
+	for channel, check := range checks {
+		channel := channel
+		check := check
+
+		_, err = connection.QueueSubscribe(channel, validatorQueueGroup, func(message *nats.Msg) {
+			validationError := check(message.Data)
+			if validationError == nil {
+				return
+			}
+
+			utils.Warningf("Wrong message in %v: %v\n", channel, validationError)
+
+			deadLetter, err := json.Marshal(DeadLetter{
+				Channel: channel,
+				Error:   validationError.Error(),
+				Message: getRawMessage(decompressForDisplay(message.Data)),
+			})
+
+			if err == nil {
+				err = connection.Publish(getDeadLetterChannel(*config, channel), deadLetter)
+			}
+
+			if err != nil {
+				utils.Warningf("Can't publish dead letter: %v\n", err)
+			}
+		})
+
+		if err != nil {
+			return fmt.Errorf("can't subscribe to %v: %v", channel, err)
+		}
+
+		utils.Infof("Validating messages of %v\n", channel)
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	<-interrupt
+	return nil
+}
+
+// getRawMessage keeps json message as is and wraps other messages in json string
+func getRawMessage(data []byte) json.RawMessage {
+	if json.Valid(data) {
+		return json.RawMessage(data)
+	}
+
+	packedData, _ := json.Marshal(string(data))
+	return json.RawMessage(packedData)
+}
@@ -0,0 +1,181 @@
+package global
+
+import (
+	"fmt"
+
+	"github.com/akaumov/cubes/db"
+	"github.com/akaumov/cubes/instance"
+	"github.com/akaumov/cubes/utils"
+)
+
+type BusSummary struct {
+	IsRunning bool   `json:"isRunning"`
+	Address   string `json:"address,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// MigrationsSummary tells how far database is behind migrations of project, it's skipped when database isn't set
+type MigrationsSummary struct {
+	IsSkipped bool     `json:"isSkipped"`
+	SyncedId  string   `json:"syncedId"`
+	Pending   []string `json:"pending"`
+	Error     string   `json:"error,omitempty"`
+}
+
+type InstanceSummary struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	IsHealthy bool   `json:"isHealthy"`
+	Error     string `json:"error,omitempty"`
+}
+
+type StatusFailure struct {
+	Instance string `json:"instance,omitempty"`
+	Message  string `json:"message"`
+}
+
+// ProjectStatus is summary of whole project, project is unhealthy when anything in it needs attention
+type ProjectStatus struct {
+	Project    string            `json:"project"`
+	Profile    string            `json:"profile,omitempty"`
+	Bus        BusSummary        `json:"bus"`
+	Migrations MigrationsSummary `json:"migrations"`
+	Instances  []InstanceSummary `json:"instances"`
+	Failures   []StatusFailure   `json:"failures"`
+	IsHealthy  bool              `json:"isHealthy"`
+}
+
+// AddFailure adds failure of instance or project, when instance is empty, and makes project unhealthy
+func (status *ProjectStatus) AddFailure(instanceName string, message string) {
+	status.Failures = append(status.Failures, StatusFailure{
+		Instance: instanceName,
+		Message:  message,
+	})
+
+	status.IsHealthy = false
+}
+
+// isHealthyStatus returns false for instances, which failed or can't be inspected, stopped instance is healthy,
+// it's stopped on purpose
+func isHealthyStatus(status string) bool {
+	return status == instance.StatusRunning || status == instance.StatusStarting || status == instance.StatusPaused ||
+		status == instance.StatusStopped
+}
+
+func getBusSummary() BusSummary {
+	if utils.IsBusRemote() {
+		address := utils.GetBusHost() + ":" + utils.GetBusHostPort()
+
+		connection, err := connectRunningBus()
+		if err != nil {
+			return BusSummary{Address: address, Error: err.Error()}
+		}
+
+		connection.Close()
+		return BusSummary{IsRunning: true, Address: address}
+	}
+
+	status, err := GetBusStatus()
+	if err != nil {
+		return BusSummary{Error: err.Error()}
+	}
+
+	return BusSummary{
+		IsRunning: status.IsRunning,
+		Address:   status.Address,
+	}
+}
+
+func getMigrationsSummary(config *ProjectConfig) MigrationsSummary {
+	summary := MigrationsSummary{
+		Pending: []string{},
+	}
+
+	if config == nil || config.Database == nil {
+		summary.IsSkipped = true
+		return summary
+	}
+
+	status, err := db.GetMigrationsStatus(config.Database)
+	if err != nil {
+		summary.Error = err.Error()
+		return summary
+	}
+
+	summary.SyncedId = status.SyncedId
+	summary.Error = status.DatabaseError
+
+	for _, migration := range status.Migrations {
+		if !migration.IsSynced {
+			summary.Pending = append(summary.Pending, migration.Id)
+		}
+	}
+
+	return summary
+}
+
+// GetProjectStatus returns state of bus, migrations drift of database and health of instances
+func GetProjectStatus() (*ProjectStatus, error) {
+	config, err := GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("can't read project config: %v", err)
+	}
+
+	status := ProjectStatus{
+		Project:   config.Name,
+		Profile:   utils.GetProfile(),
+		Instances: []InstanceSummary{},
+		Failures:  []StatusFailure{},
+		IsHealthy: true,
+	}
+
+	status.Bus = getBusSummary()
+	if !status.Bus.IsRunning {
+		message := "bus isn't running"
+		if status.Bus.Error != "" {
+			message = "bus isn't reachable: " + status.Bus.Error
+		}
+
+		status.AddFailure("", message)
+	}
+
+	status.Migrations = getMigrationsSummary(config)
+	if status.Migrations.Error != "" {
+		status.AddFailure("", "can't read migrations status: "+status.Migrations.Error)
+	} else if len(status.Migrations.Pending) > 0 {
+		status.AddFailure("", fmt.Sprintf("%v migrations aren't synced to database", len(status.Migrations.Pending)))
+	}
+
+	names, err := instance.GetNames()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		summary := InstanceSummary{
+			Name: name,
+		}
+
+		summary.Status, err = instance.GetStatus(name)
+		if err != nil {
+			summary.Status = instance.StatusUnknown
+			summary.Error = err.Error()
+		}
+
+		summary.IsHealthy = err == nil && isHealthyStatus(summary.Status)
+		status.Instances = append(status.Instances, summary)
+
+		if summary.IsHealthy {
+			continue
+		}
+
+		if summary.Error != "" {
+			status.AddFailure(name, "can't get status: "+summary.Error)
+			continue
+		}
+
+		status.AddFailure(name, "instance is "+summary.Status)
+	}
+
+	return &status, nil
+}
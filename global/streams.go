@@ -0,0 +1,296 @@
+package global
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/akaumov/cubes/instance"
+	"github.com/akaumov/cubes/utils"
+	"github.com/nats-io/nats.go"
+)
+
+// busStorePath is directory inside bus container where persistent messages are kept
+const busStorePath = "/data"
+
+// discard policies of persistent channels
+const (
+	discardOld = "old"
+	discardNew = "new"
+)
+
+const busConnectTimeout = 10 * time.Second
+const busRequestTimeout = 5 * time.Second
+
+// PersistentChannel keeps messages of channel on bus, so they're delivered to instance when it's back after restart
+type PersistentChannel struct {
+	Channel string `json:"channel"`
+
+	// MaxAgeSeconds, MaxMessages and MaxBytes are retention limits, bus removes the oldest messages when they're reached
+	MaxAgeSeconds int64 `json:"maxAgeSeconds,omitempty"`
+	MaxMessages   int64 `json:"maxMessages,omitempty"`
+	MaxBytes      int64 `json:"maxBytes,omitempty"`
+
+	// Discard is what is discarded when limits are reached: old messages (default) or new ones, which are refused then
+	Discard string `json:"discard,omitempty"`
+
+	// IsReliable makes every consuming instance acknowledge messages, they're redelivered until they're acknowledged
+	IsReliable        bool  `json:"isReliable,omitempty"`
+	AckTimeoutSeconds int64 `json:"ackTimeoutSeconds,omitempty"`
+	MaxDeliveries     int   `json:"maxDeliveries,omitempty"`
+}
+
+type streamConfig struct {
+	Name      string   `json:"name"`
+	Subjects  []string `json:"subjects"`
+	Retention string   `json:"retention"`
+	Storage   string   `json:"storage"`
+	MaxAge    int64    `json:"max_age,omitempty"`
+	MaxMsgs   int64    `json:"max_msgs,omitempty"`
+	MaxBytes  int64    `json:"max_bytes,omitempty"`
+	Discard   string   `json:"discard,omitempty"`
+}
+
+type streamResponse struct {
+	Error *struct {
+		Code        int    `json:"code"`
+		Description string `json:"description"`
+	} `json:"error"`
+}
+
+func checkPersistentChannels(channels []PersistentChannel) error {
+	names := map[string]string{}
+
+	for _, channel := range channels {
+		if channel.Channel == "" {
+			return fmt.Errorf("persistent channel must have name")
+		}
+
+		if channel.MaxAgeSeconds < 0 || channel.MaxMessages < 0 || channel.MaxBytes < 0 || channel.AckTimeoutSeconds < 0 || channel.MaxDeliveries < 0 {
+			return fmt.Errorf("limits of persistent channel %v can't be negative", channel.Channel)
+		}
+
+		if channel.Discard != "" && channel.Discard != discardOld && channel.Discard != discardNew {
+			return fmt.Errorf("discard of persistent channel %v must be %v or %v", channel.Channel, discardOld, discardNew)
+		}
+
+		streamName := getStreamName(channel.Channel)
+		if existing, ok := names[streamName]; ok {
+			return fmt.Errorf("persistent channels %v and %v clash", existing, channel.Channel)
+		}
+
+		names[streamName] = channel.Channel
+	}
+
+	return nil
+}
+
+// getStreamName converts channel to stream name, which can't contain dots and wildcards
+func getStreamName(channel string) string {
+	return strings.ToUpper(strings.NewReplacer(".", "_", "*", "ANY", ">", "ALL").Replace(channel))
+}
+
+// getBusPersistenceOptions returns bus arguments and bind of messages store when project has persistent channels
+func getBusPersistenceOptions(config ProjectConfig) ([]string, []string, error) {
+	if len(config.PersistentChannels) == 0 && !config.IsDelayedDeliveryEnabled {
+		return []string{}, []string{}, nil
+	}
+
+	err := checkPersistentChannels(config.PersistentChannels)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	storeDirectory, err := utils.GetStateDirectoryPath("bus", "store")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	utils.Infof("Bus persistence is enabled for %v channels\n", len(config.PersistentChannels))
+	return []string{"-js", "-sd", busStorePath}, []string{storeDirectory + ":" + busStorePath}, nil
+}
+
+// getBusTLSConfig returns TLS config of cubes clients of bus, it's nil when bus TLS isn't enabled
+func getBusTLSConfig() (*tls.Config, error) {
+	isTLSEnabled, err := utils.IsBusTLSEnabled()
+	if err != nil || !isTLSEnabled {
+		return nil, err
+	}
+
+	isClientVerified, err := utils.IsBusClientVerified()
+	if err != nil {
+		return nil, err
+	}
+
+	if !isClientVerified {
+		// without CA there is nothing to verify bus certificate with, bus is local anyway
+		return &tls.Config{InsecureSkipVerify: true}, nil
+	}
+
+	directory, err := utils.GetBusTLSDirectoryPath()
+	if err != nil {
+		return nil, err
+	}
+
+	rawCA, err := ioutil.ReadFile(filepath.Join(directory, utils.BusTLSCAFile))
+	if err != nil {
+		return nil, fmt.Errorf("can't read bus CA: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(rawCA) {
+		return nil, fmt.Errorf("can't parse bus CA")
+	}
+
+	certificate, err := tls.LoadX509KeyPair(
+		filepath.Join(directory, utils.BusTLSClientCertFile),
+		filepath.Join(directory, utils.BusTLSClientKeyFile),
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("can't read bus client certificate: %v", err)
+	}
+
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		RootCAs:      pool,
+		Certificates: []tls.Certificate{certificate},
+	}, nil
+}
+
+// connectBus connects to bus as cubes admin, it uses TLS and credentials when they're enabled
+func connectBus(config ProjectConfig) (*nats.Conn, error) {
+	options := []nats.Option{nats.Timeout(busRequestTimeout)}
+
+	tlsConfig, err := getBusTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if tlsConfig != nil {
+		options = append(options, nats.Secure(tlsConfig))
+	}
+
+	if config.IsBusAuthEnabled {
+		credentials, err := instance.GetBusAdminCredentials()
+		if err != nil {
+			return nil, err
+		}
+
+		options = append(options, nats.UserInfo(credentials.User, credentials.Password))
+	}
+
+	url := "nats://" + utils.GetBusHost() + ":" + utils.GetBusHostPort()
+	deadline := time.Now().Add(busConnectTimeout)
+
+	for {
+		connection, err := nats.Connect(url, options...)
+		if err == nil || time.Now().After(deadline) {
+			return connection, err
+		}
+
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+func requestStream(connection *nats.Conn, action string, config streamConfig) (*streamResponse, error) {
+	packedConfig, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := connection.Request("$JS.API.STREAM."+action+"."+config.Name, packedConfig, busRequestTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	var response streamResponse
+	err = json.Unmarshal(message.Data, &response)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse bus response: %v", err)
+	}
+
+	return &response, nil
+}
+
+func createStream(connection *nats.Conn, stream streamConfig) error {
+	response, err := requestStream(connection, "CREATE", stream)
+	if err == nil && response.Error != nil {
+		// stream is kept in bus store between restarts, its limits could be changed in project config
+		response, err = requestStream(connection, "UPDATE", stream)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if response.Error != nil {
+		return errors.New(response.Error.Description)
+	}
+
+	return nil
+}
+
+// createStreams creates or updates streams of persistent channels and stream of delayed messages on running bus
+func createStreams(config ProjectConfig) error {
+	if len(config.PersistentChannels) == 0 && !config.IsDelayedDeliveryEnabled {
+		return nil
+	}
+
+	connection, err := connectBus(config)
+	if err != nil {
+		return fmt.Errorf("can't connect to bus: %v", err)
+	}
+
+	defer connection.Close()
+
+	for _, channel := range config.PersistentChannels {
+		err = createStream(connection, streamConfig{
+			Name:      getStreamName(channel.Channel),
+			Subjects:  []string{channel.Channel},
+			Retention: "limits",
+			Storage:   "file",
+			MaxAge:    int64(time.Duration(channel.MaxAgeSeconds) * time.Second),
+			MaxMsgs:   channel.MaxMessages,
+			MaxBytes:  channel.MaxBytes,
+			Discard:   channel.Discard,
+		})
+
+		if err != nil {
+			return fmt.Errorf("can't create stream of channel %v: %v", channel.Channel, err)
+		}
+
+		if channel.MaxAgeSeconds == 0 && channel.MaxMessages == 0 && channel.MaxBytes == 0 {
+			utils.Warningf("Channel %v is persistent without retention limits, its messages are kept until disk is full\n", channel.Channel)
+			continue
+		}
+
+		utils.Debugf("Channel %v is persistent\n", channel.Channel)
+	}
+
+	err = createReliableConsumers(connection, config)
+	if err != nil {
+		return err
+	}
+
+	if config.IsDelayedDeliveryEnabled {
+		err = createStream(connection, streamConfig{
+			Name:      delayedStream,
+			Subjects:  []string{delayedChannelPrefix + ">"},
+			Retention: "workqueue",
+			Storage:   "file",
+		})
+
+		if err != nil {
+			return fmt.Errorf("can't create stream of delayed messages: %v", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,164 @@
+package global
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// MessageFilter passes only json messages which have value at path, path is dot separated: params.user.id,
+// headers.<key> paths match headers of message
+type MessageFilter struct {
+	Path  []string
+	Value string
+}
+
+// ParseMessageFilters parses filters in format "path=value;path2=value2"
+func ParseMessageFilters(rawFilters string) (*[]MessageFilter, error) {
+	filters := []MessageFilter{}
+
+	for _, rawFilter := range strings.Split(rawFilters, ";") {
+		rawFilter = strings.TrimSpace(rawFilter)
+		if rawFilter == "" {
+			continue
+		}
+
+		parts := strings.SplitN(rawFilter, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("wrong filter %v, format is path=value", rawFilter)
+		}
+
+		filters = append(filters, MessageFilter{
+			Path:  strings.Split(parts[0], "."),
+			Value: parts[1],
+		})
+	}
+
+	return &filters, nil
+}
+
+// messageHeadersFilterPath starts paths of filters, which match headers instead of json message
+const messageHeadersFilterPath = "headers"
+
+func (filter MessageFilter) match(message interface{}) bool {
+	value := message
+
+	for _, key := range filter.Path {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return false
+		}
+
+		value, ok = object[key]
+		if !ok {
+			return false
+		}
+	}
+
+	if text, ok := value.(string); ok {
+		return text == filter.Value
+	}
+
+	packedValue, err := json.Marshal(value)
+	return err == nil && string(packedValue) == filter.Value
+}
+
+func isMessageMatched(data []byte, headers MessageHeaders, filters []MessageFilter) bool {
+	if len(filters) == 0 {
+		return true
+	}
+
+	var message interface{}
+	err := json.Unmarshal(data, &message)
+	if err != nil {
+		return false
+	}
+
+	for _, filter := range filters {
+		if len(filter.Path) == 2 && filter.Path[0] == messageHeadersFilterPath {
+			if value, ok := headers[strings.ToLower(filter.Path[1])]; !ok || value != filter.Value {
+				return false
+			}
+
+			continue
+		}
+
+		if !filter.match(message) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// FormatMessage indents json message, other messages are returned as is
+func FormatMessage(data []byte) string {
+	var indented bytes.Buffer
+
+	err := json.Indent(&indented, data, "", "  ")
+	if err != nil {
+		return string(data)
+	}
+
+	return indented.String()
+}
+
+// Subscribe prints messages of bus channels until Ctrl+C is pressed, channels can have wildcards: users.*, users.>
+func Subscribe(channels []string, filters []MessageFilter, output io.Writer) error {
+	if len(channels) == 0 {
+		return fmt.Errorf("at least one channel is required")
+	}
+
+	connection, err := connectRunningBus()
+	if err != nil {
+		return err
+	}
+
+	closed := make(chan struct{})
+	connection.SetClosedHandler(func(_ *nats.Conn) {
+		close(closed)
+	})
+
+	defer connection.Close()
+
+	messages := make(chan *nats.Msg, 64)
+	for _, channel := range channels {
+		_, err = connection.ChanSubscribe(channel, messages)
+		if err != nil {
+			return fmt.Errorf("can't subscribe to %v: %v", channel, err)
+		}
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	for {
+		select {
+		case <-interrupt:
+			return nil
+		case <-closed:
+			return fmt.Errorf("bus connection is lost: %v", connection.LastError())
+		case message := <-messages:
+			data := decompressForDisplay(message.Data)
+			headers := getMessageHeaders(message)
+			if !isMessageMatched(data, headers, filters) {
+				continue
+			}
+
+			title := message.Subject
+			if len(headers) > 0 {
+				title += " " + FormatMessageHeaders(headers)
+			}
+
+			fmt.Fprintf(output, "[%v] %v\n%v\n\n", time.Now().Format("15:04:05.000"), title, FormatMessage(data))
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package global
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// BusTail keeps recent messages of all bus channels until it's closed, service channels are skipped
+type BusTail struct {
+	connection *nats.Conn
+	limit      int
+
+	mutex    sync.Mutex
+	messages []DashboardMessage
+}
+
+func (tail *BusTail) onMessage(message *nats.Msg) {
+	if isServiceChannel(message.Subject) {
+		return
+	}
+
+	data := decompressForDisplay(message.Data)
+	isTruncated := len(data) > dashboardMessageSize
+	if isTruncated {
+		data = data[:dashboardMessageSize]
+	}
+
+	tail.mutex.Lock()
+	defer tail.mutex.Unlock()
+
+	tail.messages = append(tail.messages, DashboardMessage{
+		Channel:     message.Subject,
+		ReceivedAt:  time.Now(),
+		Data:        string(data),
+		IsTruncated: isTruncated,
+	})
+
+	if len(tail.messages) > tail.limit {
+		tail.messages = tail.messages[len(tail.messages)-tail.limit:]
+	}
+}
+
+// StartBusTail subscribes to all channels of running bus and keeps up to limit of their last messages
+func StartBusTail(limit int) (*BusTail, error) {
+	connection, err := connectRunningBus()
+	if err != nil {
+		return nil, err
+	}
+
+	tail := BusTail{
+		connection: connection,
+		limit:      limit,
+		messages:   []DashboardMessage{},
+	}
+
+	_, err = connection.Subscribe(">", tail.onMessage)
+	if err != nil {
+		connection.Close()
+		return nil, err
+	}
+
+	return &tail, nil
+}
+
+// GetMessages returns kept messages, the oldest is first
+func (tail *BusTail) GetMessages() []DashboardMessage {
+	tail.mutex.Lock()
+	defer tail.mutex.Unlock()
+
+	return append([]DashboardMessage{}, tail.messages...)
+}
+
+// IsConnected returns false when connection to bus is lost and isn't restored
+func (tail *BusTail) IsConnected() bool {
+	return !tail.connection.IsClosed()
+}
+
+func (tail *BusTail) Close() {
+	tail.connection.Close()
+}
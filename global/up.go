@@ -0,0 +1,139 @@
+package global
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/akaumov/cubes/db"
+	"github.com/akaumov/cubes/instance"
+	"github.com/akaumov/cubes/utils"
+)
+
+// startUpBus starts bus in background, running or remote bus is only checked
+func startUpBus() error {
+	if utils.IsBusRemote() {
+		connection, err := connectRunningBus()
+		if err != nil {
+			return err
+		}
+
+		connection.Close()
+		utils.Infof("Bus on %v is reachable\n", utils.GetBusHost())
+		return nil
+	}
+
+	return StartBus()
+}
+
+func syncUpDatabase() error {
+	config, err := GetConfig()
+	if err != nil {
+		return fmt.Errorf("can't read project config: %v", err)
+	}
+
+	if config.Database == nil {
+		utils.Infof("Database isn't configured, migrations aren't synced\n")
+		return nil
+	}
+
+	utils.Infof("Syncing migrations...\n")
+	return db.Sync(*config.Database)
+}
+
+func getOrderedInstances() ([]instance.Config, error) {
+	configs, err := instance.GetList()
+	if err != nil {
+		return nil, err
+	}
+
+	return instance.SortByDependencies(*configs)
+}
+
+// Up starts bus, syncs migrations to database and starts instances after instances they depend on with at most
+// parallelism of them at once, instance, which others depend on, is waited until it's ready.
+// Running bus and instances are kept.
+func Up(readyTimeout time.Duration, parallelism int) error {
+	configs, err := getOrderedInstances()
+	if err != nil {
+		return err
+	}
+
+	err = startUpBus()
+	if err != nil {
+		return fmt.Errorf("can't start bus: %v", err)
+	}
+
+	err = syncUpDatabase()
+	if err != nil {
+		return fmt.Errorf("can't sync migrations: %v", err)
+	}
+
+	started, err := instance.StartConcurrently(configs, parallelism, readyTimeout, func(config instance.Config) error {
+		status, err := instance.GetStatus(config.Name)
+		if err != nil {
+			return fmt.Errorf("can't get status of instance %v: %v", config.Name, err)
+		}
+
+		if instance.IsActiveStatus(status) {
+			return nil
+		}
+
+		utils.Infof("Starting instance %v...\n", config.Name)
+
+		err = instance.Start(config.Name)
+		if err != nil {
+			return fmt.Errorf("can't start instance %v: %v", config.Name, err)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return utils.PartialError(started, len(configs), err)
+	}
+
+	utils.Infof("Project is up: %v instances\n", len(configs))
+	return nil
+}
+
+// Down stops instances in reverse order of Up, so instances are stopped before instances they depend on,
+// and stops bus then. Remote bus isn't stopped.
+func Down(drainTimeout time.Duration) error {
+	configs, err := getOrderedInstances()
+	if err != nil {
+		return err
+	}
+
+	for i := len(configs) - 1; i >= 0; i-- {
+		name := configs[i].Name
+		handled := len(configs) - 1 - i
+
+		status, err := instance.GetStatus(name)
+		if err != nil && !utils.IsConnectionError(err) {
+			return utils.PartialError(handled, len(configs), fmt.Errorf("can't get status of instance %v: %v", name, err))
+		}
+
+		if err != nil {
+			utils.Warningf("Can't reach instance %v, it isn't stopped: %v\n", name, err)
+			continue
+		}
+
+		if !instance.IsActiveStatus(status) {
+			continue
+		}
+
+		utils.Infof("Stopping instance %v...\n", name)
+
+		err = instance.Stop(name)
+		if err != nil {
+			return utils.PartialError(handled, len(configs), fmt.Errorf("can't stop instance %v: %v", name, err))
+		}
+	}
+
+	if utils.IsBusRemote() {
+		utils.Infof("Bus on %v is remote, it isn't stopped\n", utils.GetBusHost())
+		return nil
+	}
+
+	return StopBus(drainTimeout)
+}
@@ -0,0 +1,55 @@
+package global
+
+import (
+	"os"
+
+	"github.com/akaumov/cubes/utils"
+)
+
+// WorkspaceProjectStatus is status of project of workspace, Error is set when project can't be inspected
+type WorkspaceProjectStatus struct {
+	Name      string         `json:"name"`
+	Path      string         `json:"path"`
+	IsCurrent bool           `json:"isCurrent"`
+	Status    *ProjectStatus `json:"status,omitempty"`
+	Error     string         `json:"error,omitempty"`
+}
+
+// GetWorkspaceStatus returns status of every project of workspace, projects are inspected one by one
+// in their directories
+func GetWorkspaceStatus() ([]WorkspaceProjectStatus, error) {
+	workspace, err := utils.GetWorkspace()
+	if err != nil {
+		return nil, err
+	}
+
+	pwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	defer os.Chdir(pwd)
+
+	statuses := []WorkspaceProjectStatus{}
+
+	for _, project := range workspace.Projects {
+		projectStatus := WorkspaceProjectStatus{
+			Name:      project.Name,
+			Path:      project.Path,
+			IsCurrent: project.Name == workspace.Current,
+		}
+
+		err = os.Chdir(project.Path)
+		if err == nil {
+			projectStatus.Status, err = GetProjectStatus()
+		}
+
+		if err != nil {
+			projectStatus.Error = err.Error()
+		}
+
+		statuses = append(statuses, projectStatus)
+	}
+
+	return statuses, nil
+}
@@ -0,0 +1,93 @@
+package instance
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/akaumov/cube_executor"
+	"github.com/akaumov/cubes/executor"
+	"github.com/akaumov/cubes/utils"
+)
+
+// busRepliesChannels are channels of replies, they're open to every instance, so it can make and answer requests
+const busRepliesChannels = "_INBOX.>"
+
+// getBusLogChannels returns channels, which executor publishes log messages of instance to
+func getBusLogChannels(config Config) string {
+	return "log.*." + config.Class + "." + config.Name
+}
+
+// getBusPermissions returns channels instance may publish and subscribe to by its channels mapping and directions of
+// channels in cube meta, channels without direction are allowed both ways, it returns nil when cube declares no channels
+func getBusPermissions(config Config) (publish []string, subscribe []string) {
+	meta, err := GetMeta(config)
+	if err != nil {
+		utils.Warningf("Can't read meta of %v, its bus channels aren't restricted: %v\n", config.Name, err)
+		return nil, nil
+	}
+
+	if (meta == nil || len(meta.Channels) == 0) && len(config.ChannelsMapping) == 0 {
+		return nil, nil
+	}
+
+	channels := map[string]ChannelMeta{}
+	if meta != nil {
+		for cubeChannel, channelMeta := range meta.Channels {
+			channels[cubeChannel] = channelMeta
+		}
+	}
+
+	for cubeChannel := range config.ChannelsMapping {
+		if _, ok := channels[string(cubeChannel)]; !ok {
+			channels[string(cubeChannel)] = ChannelMeta{}
+		}
+	}
+
+	publish = []string{busRepliesChannels, getBusLogChannels(config), executor.ProtocolChannel}
+	subscribe = []string{busRepliesChannels}
+
+	for cubeChannel, channelMeta := range channels {
+		busChannel := cubeChannel
+		if mappedChannel, ok := config.ChannelsMapping[cube_executor.CubeChannel(cubeChannel)]; ok {
+			busChannel = string(mappedChannel)
+		}
+
+		if channelMeta.Direction != ChannelIn {
+			publish = append(publish, busChannel)
+		}
+
+		if channelMeta.Direction != ChannelOut {
+			subscribe = append(subscribe, busChannel)
+		}
+	}
+
+	sort.Strings(publish)
+	sort.Strings(subscribe)
+	return publish, subscribe
+}
+
+func formatChannelsList(channels []string) string {
+	quoted := []string{}
+	for _, channel := range channels {
+		quoted = append(quoted, fmt.Sprintf("%q", channel))
+	}
+
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// formatBusUser returns bus user entry with permissions of instance
+func formatBusUser(config Config, credentials BusCredentials) string {
+	publish, subscribe := getBusPermissions(config)
+	if publish == nil {
+		return fmt.Sprintf("    {user: %q, password: %q}", credentials.User, credentials.Password)
+	}
+
+	return fmt.Sprintf(
+		"    {user: %q, password: %q, permissions: {publish: %v, subscribe: %v}}",
+		credentials.User,
+		credentials.Password,
+		formatChannelsList(publish),
+		formatChannelsList(subscribe),
+	)
+}
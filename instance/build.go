@@ -0,0 +1,200 @@
+package instance
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/akaumov/cubes/utils"
+)
+
+const cubeBuildScript = "build.sh"
+const cubeBinaryName = "cube"
+const cubeArchiveName = "cube.tar"
+
+// buildMutex makes instances, which are started concurrently, build one by one: they share git checkouts of sources
+// and build ids are made from time
+var buildMutex sync.Mutex
+
+// buildCube compiles cube from source directory with cube's build script or with go build
+func buildCube(sourcePath string, outputDir string) (string, error) {
+	binaryPath := filepath.Join(outputDir, cubeBinaryName)
+
+	var command *exec.Cmd
+
+	if _, err := os.Stat(filepath.Join(sourcePath, cubeBuildScript)); err == nil {
+		utils.Infof("Building cube with %v...\n", cubeBuildScript)
+		command = exec.Command("sh", cubeBuildScript)
+	} else {
+		goModuleMode := "GO111MODULE=off"
+		if _, err := os.Stat(filepath.Join(sourcePath, "go.mod")); err == nil {
+			utils.Debugf("Go module detected")
+			goModuleMode = "GO111MODULE=on"
+		}
+
+		utils.Infof("Building cube with go build...")
+		command = exec.Command("go", "build", "-o", binaryPath, ".")
+		command.Env = append(command.Env, "GOOS=linux", "CGO_ENABLED=0", goModuleMode)
+	}
+
+	command.Dir = sourcePath
+	command.Env = append(os.Environ(), append(command.Env, "CUBE_OUTPUT="+binaryPath)...)
+	command.Stdout = utils.AboveProgress(os.Stdout)
+	command.Stderr = utils.AboveProgress(os.Stderr)
+
+	progress := utils.StartProgress("Building cube", 0)
+	err := command.Run()
+	progress.Done()
+
+	if err != nil {
+		return "", fmt.Errorf("build failed: %v", err)
+	}
+
+	if _, err := os.Stat(binaryPath); err != nil {
+		return "", fmt.Errorf("build didn't produce cube binary at %v", binaryPath)
+	}
+
+	return binaryPath, nil
+}
+
+// packCube makes tar archive with cube binary which is copied to instance container
+func packCube(binaryPath string, tarPath string) error {
+	binary, err := os.Open(binaryPath)
+	if err != nil {
+		return err
+	}
+
+	defer binary.Close()
+
+	binaryInfo, err := binary.Stat()
+	if err != nil {
+		return err
+	}
+
+	tarFile, err := os.Create(tarPath)
+	if err != nil {
+		return err
+	}
+
+	defer tarFile.Close()
+
+	tarWriter := tar.NewWriter(tarFile)
+
+	err = tarWriter.WriteHeader(&tar.Header{
+		Name:    cubeBinaryName,
+		Mode:    0755,
+		Size:    binaryInfo.Size(),
+		ModTime: binaryInfo.ModTime(),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(tarWriter, binary)
+	if err != nil {
+		return err
+	}
+
+	return tarWriter.Close()
+}
+
+func getBuildPath(buildId string) (string, error) {
+	return utils.GetStateDirectoryPath("cache", "builds", buildId)
+}
+
+func getBuildArchivePath(buildId string) (string, error) {
+	buildPath, err := getBuildPath(buildId)
+	if err != nil {
+		return "", err
+	}
+
+	archivePath := filepath.Join(buildPath, cubeArchiveName)
+	if _, err := os.Stat(archivePath); err != nil {
+		return "", fmt.Errorf("build %v doesn't exist: %v", buildId, err)
+	}
+
+	return archivePath, nil
+}
+
+// Build compiles cube from local source directory into versioned artifact in project cache and returns build id
+func Build(sourcePath string) (string, error) {
+	if info, err := os.Stat(sourcePath); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("source directory %v doesn't exist", sourcePath)
+	}
+
+	absoluteSourcePath, err := filepath.Abs(sourcePath)
+	if err != nil {
+		return "", err
+	}
+
+	buildMutex.Lock()
+	defer buildMutex.Unlock()
+
+	buildsPath, err := utils.GetStateDirectoryPath("cache", "builds")
+	if err != nil {
+		return "", err
+	}
+
+	// builds made in the same second get suffix, so they don't overwrite each other
+	timeId := time.Now().UTC().Format("20060102150405")
+	buildId := timeId
+
+	for index := 2; ; index++ {
+		if _, err := os.Stat(filepath.Join(buildsPath, buildId)); os.IsNotExist(err) {
+			break
+		}
+
+		buildId = timeId + "-" + strconv.Itoa(index)
+	}
+
+	buildPath, err := getBuildPath(buildId)
+	if err != nil {
+		return "", err
+	}
+
+	binaryPath, err := buildCube(absoluteSourcePath, buildPath)
+	if err != nil {
+		os.RemoveAll(buildPath)
+		return "", err
+	}
+
+	err = packCube(binaryPath, filepath.Join(buildPath, cubeArchiveName))
+	if err != nil {
+		os.RemoveAll(buildPath)
+		return "", fmt.Errorf("can't pack cube: %v", err)
+	}
+
+	return buildId, nil
+}
+
+// BuildInstance builds instance's local source and wires the build as instance's executable
+func BuildInstance(name string) (string, error) {
+	config, err := GetConfig(name)
+	if err != nil {
+		return "", err
+	}
+
+	sourceType, sourceData, err := splitSource(config.Source)
+	if err != nil {
+		return "", err
+	}
+
+	if sourceType != SourcePath {
+		return "", fmt.Errorf("instance '%v' doesn't have local path source", name)
+	}
+
+	buildId, err := Build(sourceData)
+	if err != nil {
+		return "", err
+	}
+
+	config.Build = buildId
+	return buildId, saveConfig(*config)
+}
@@ -0,0 +1,216 @@
+package instance
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+const bundleInstancesDirectory = "instances"
+
+var secretParamMarkers = []string{"password", "secret", "token", "key", "credential"}
+
+func isSecretParam(param string) bool {
+	lowerParam := strings.ToLower(param)
+
+	for _, marker := range secretParamMarkers {
+		if strings.Contains(lowerParam, marker) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func stripSecrets(config Config) Config {
+	params := map[string]string{}
+
+	for key, value := range config.Params {
+		if isSecretParam(key) {
+			value = ""
+		}
+
+		params[key] = value
+	}
+
+	config.Params = params
+	return config
+}
+
+func Export(names []string, bundlePath string, isSecretsStripped bool) error {
+
+	if len(names) == 0 {
+		return fmt.Errorf("no instances to export")
+	}
+
+	bundleFile, err := os.Create(bundlePath)
+	if err != nil {
+		return fmt.Errorf("can't create bundle file: %v", err)
+	}
+
+	defer bundleFile.Close()
+
+	gzipWriter := gzip.NewWriter(bundleFile)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	for _, name := range names {
+		config, err := GetConfig(name)
+		if err != nil {
+			return fmt.Errorf("can't read instance config %v: %v", name, err)
+		}
+
+		if isSecretsStripped {
+			*config = stripSecrets(*config)
+		}
+
+		packedConfig, err := json.MarshalIndent(config, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		err = tarWriter.WriteHeader(&tar.Header{
+			Name:    path.Join(bundleInstancesDirectory, config.Name+".json"),
+			Mode:    0666,
+			Size:    int64(len(packedConfig)),
+			ModTime: time.Now(),
+		})
+
+		if err != nil {
+			return fmt.Errorf("can't write bundle: %v", err)
+		}
+
+		_, err = tarWriter.Write(packedConfig)
+		if err != nil {
+			return fmt.Errorf("can't write bundle: %v", err)
+		}
+	}
+
+	err = tarWriter.Close()
+	if err != nil {
+		return fmt.Errorf("can't write bundle: %v", err)
+	}
+
+	return gzipWriter.Close()
+}
+
+func ExportAll(bundlePath string, isSecretsStripped bool) error {
+	configs, err := GetList()
+	if err != nil {
+		return err
+	}
+
+	names := []string{}
+	for _, config := range *configs {
+		names = append(names, config.Name)
+	}
+
+	return Export(names, bundlePath, isSecretsStripped)
+}
+
+func readBundle(bundlePath string) (*[]Config, error) {
+	bundleFile, err := os.Open(bundlePath)
+	if err != nil {
+		return nil, fmt.Errorf("can't open bundle file: %v", err)
+	}
+
+	defer bundleFile.Close()
+
+	gzipReader, err := gzip.NewReader(bundleFile)
+	if err != nil {
+		return nil, fmt.Errorf("can't read bundle: %v", err)
+	}
+
+	defer gzipReader.Close()
+
+	tarReader := tar.NewReader(gzipReader)
+	configs := []Config{}
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("can't read bundle: %v", err)
+		}
+
+		if header.Typeflag != tar.TypeReg || path.Ext(header.Name) != ".json" {
+			continue
+		}
+
+		rawConfig, err := ioutil.ReadAll(tarReader)
+		if err != nil {
+			return nil, fmt.Errorf("can't read bundle entry %v: %v", header.Name, err)
+		}
+
+		upgradedConfig, _, err := upgradeConfig(header.Name, rawConfig)
+		if err != nil {
+			return nil, fmt.Errorf("can't upgrade bundle entry %v: %v", header.Name, err)
+		}
+
+		var config Config
+		err = json.Unmarshal(upgradedConfig, &config)
+		if err != nil {
+			return nil, fmt.Errorf("can't parse bundle entry %v: %v", header.Name, err)
+		}
+
+		if config.Name == "" {
+			return nil, fmt.Errorf("bundle entry %v has no instance name", header.Name)
+		}
+
+		err = checkInstanceName(config.Name)
+		if err != nil {
+			return nil, fmt.Errorf("bundle entry %v: %v", header.Name, err)
+		}
+
+		configs = append(configs, config)
+	}
+
+	return &configs, nil
+}
+
+func Import(bundlePath string, isOverwriteAllowed bool) ([]string, error) {
+	configs, err := readBundle(bundlePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isOverwriteAllowed {
+		for _, config := range *configs {
+			isExist, err := IsExist(config.Name)
+			if err != nil {
+				return nil, err
+			}
+
+			if isExist {
+				return nil, fmt.Errorf("instance '%v' already exists", config.Name)
+			}
+		}
+	}
+
+	err = createInstancesDirectoryIfNotExist()
+	if err != nil {
+		return nil, err
+	}
+
+	importedNames := []string{}
+
+	for _, config := range *configs {
+		err = saveConfig(config)
+		if err != nil {
+			return importedNames, fmt.Errorf("can't save instance config %v: %v", config.Name, err)
+		}
+
+		importedNames = append(importedNames, config.Name)
+	}
+
+	return importedNames, nil
+}
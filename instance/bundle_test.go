@@ -0,0 +1,84 @@
+package instance
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestBundle writes bundle with config entries and returns its path, returned func removes it
+func writeTestBundle(t *testing.T, entries map[string]string) (string, func()) {
+	directory, err := ioutil.TempDir("", "cubes_bundle_")
+	if err != nil {
+		t.Fatalf("can't create bundle directory: %v", err)
+	}
+
+	bundlePath := filepath.Join(directory, "bundle.tar.gz")
+
+	bundleFile, err := os.Create(bundlePath)
+	if err != nil {
+		t.Fatalf("can't create bundle: %v", err)
+	}
+
+	gzipWriter := gzip.NewWriter(bundleFile)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	for name, content := range entries {
+		err = tarWriter.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		})
+
+		if err == nil {
+			_, err = tarWriter.Write([]byte(content))
+		}
+
+		if err != nil {
+			t.Fatalf("can't write bundle entry %v: %v", name, err)
+		}
+	}
+
+	tarWriter.Close()
+	gzipWriter.Close()
+	bundleFile.Close()
+
+	return bundlePath, func() { os.RemoveAll(directory) }
+}
+
+func TestReadBundle(t *testing.T) {
+	bundlePath, remove := writeTestBundle(t, map[string]string{
+		"api.json": `{"name": "api", "class": "gateway"}`,
+	})
+
+	defer remove()
+
+	configs, err := readBundle(bundlePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(*configs) != 1 || (*configs)[0].Name != "api" {
+		t.Fatalf("expected config of api, got %v", *configs)
+	}
+}
+
+func TestReadBundleRefusesWrongInstanceNames(t *testing.T) {
+	for _, name := range []string{"../../etc/cron", "api/worker", ".hidden", ""} {
+		bundlePath, remove := writeTestBundle(t, map[string]string{
+			"api.json": `{"name": "` + name + `", "class": "gateway"}`,
+		})
+
+		_, err := readBundle(bundlePath)
+		remove()
+
+		if err == nil || !strings.HasPrefix(err.Error(), "bundle entry api.json") {
+			t.Errorf("instance name %q is accepted: %v", name, err)
+		}
+	}
+}
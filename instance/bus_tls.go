@@ -0,0 +1,50 @@
+package instance
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/akaumov/cubes/utils"
+)
+
+var busTLSClientFiles = []struct {
+	file string
+	env  string
+}{
+	{utils.BusTLSCAFile, "CUBE_BUS_TLS_CA"},
+	{utils.BusTLSClientCertFile, "CUBE_BUS_TLS_CERT"},
+	{utils.BusTLSClientKeyFile, "CUBE_BUS_TLS_KEY"},
+}
+
+// getBusTLSOptions returns binds of bus client certificates and environment variables with their paths for executor,
+// server key isn't passed to instances
+func getBusTLSOptions() ([]string, []string, error) {
+	binds := []string{}
+	env := []string{}
+
+	isEnabled, err := utils.IsBusTLSEnabled()
+	if err != nil || !isEnabled {
+		return binds, env, err
+	}
+
+	directory, err := utils.GetBusTLSDirectoryPath()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	env = append(env, "CUBE_BUS_TLS=true")
+
+	for _, clientFile := range busTLSClientFiles {
+		hostPath := filepath.Join(directory, clientFile.file)
+		if _, err := os.Stat(hostPath); err != nil {
+			continue
+		}
+
+		cubePath := path.Join(utils.BusCertsPath, clientFile.file)
+		binds = append(binds, hostPath+":"+cubePath+":ro")
+		env = append(env, clientFile.env+"="+cubePath)
+	}
+
+	return binds, env, nil
+}
@@ -0,0 +1,68 @@
+package instance
+
+import (
+	"fmt"
+
+	"github.com/akaumov/cube_executor"
+)
+
+func checkChannelsMapping(meta *Meta, channelsMapping map[cube_executor.CubeChannel]cube_executor.BusChannel) error {
+	if meta == nil || meta.Channels == nil {
+		return nil
+	}
+
+	for cubeChannel, busChannel := range channelsMapping {
+		if _, ok := meta.Channels[string(cubeChannel)]; !ok {
+			return fmt.Errorf("cube doesn't declare channel '%v'", cubeChannel)
+		}
+
+		if busChannel == "" {
+			return fmt.Errorf("bus channel for '%v' is empty", cubeChannel)
+		}
+	}
+
+	return nil
+}
+
+// SetChannelsMapping replaces channels mapping of instance, running instance resubscribes channels of cube by new
+// mapping without restart. Bus users are rewritten with config, so permissions of instance follow new mapping.
+func SetChannelsMapping(name string, channelsMapping map[cube_executor.CubeChannel]cube_executor.BusChannel) error {
+	config, err := GetConfig(name)
+	if err != nil {
+		return err
+	}
+
+	meta, err := GetMeta(*config)
+	if err != nil {
+		return fmt.Errorf("can't read cube meta: %v", err)
+	}
+
+	err = checkChannelsMapping(meta, channelsMapping)
+	if err != nil {
+		return err
+	}
+
+	config.ChannelsMapping = channelsMapping
+
+	err = saveConfig(*config)
+	if err != nil {
+		return err
+	}
+
+	status, err := GetStatus(name)
+	if err != nil || !IsActiveStatus(status) {
+		return nil
+	}
+
+	runtime, err := getConfigRuntime(*config)
+	if err != nil {
+		return err
+	}
+
+	err = runtime.Reload(*config)
+	if err != nil {
+		return fmt.Errorf("channels mapping is saved, but running instance keeps old one until restart: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,253 @@
+package instance
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/akaumov/cubes/utils"
+	docker_client "github.com/docker/docker/client"
+	"golang.org/x/net/context"
+)
+
+// busReloadSignal makes bus reread its config with users
+const busReloadSignal = "SIGHUP"
+
+// credentialsMutex makes instances started concurrently create credentials and rewrite bus users one by one
+var credentialsMutex sync.Mutex
+
+// busAdminUser is used by cubes itself to manage bus, it can't clash with instance names which are file names of configs
+const busAdminUser = "_cubes"
+
+// BusCredentials are used by instance to connect to bus, they're kept in .cubes/credentials, outside of instance config
+type BusCredentials struct {
+	User     string `json:"user"`
+	Password string `json:"password"`
+}
+
+func getBusCredentialsPath(name string) (string, error) {
+	credentialsDirectory, err := utils.GetStateDirectoryPath("credentials")
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(credentialsDirectory, name+".json"), nil
+}
+
+func GetBusCredentials(name string) (*BusCredentials, error) {
+	credentialsPath, err := getBusCredentialsPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	rawCredentials, err := ioutil.ReadFile(credentialsPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	var credentials BusCredentials
+	err = json.Unmarshal(rawCredentials, &credentials)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse bus credentials: %v", err)
+	}
+
+	return &credentials, nil
+}
+
+func generatePassword() (string, error) {
+	data := make([]byte, 24)
+
+	_, err := rand.Read(data)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(data), nil
+}
+
+// ensureBusCredentials generates credentials of instance if it doesn't have them and returns true if they're created
+func ensureBusCredentials(name string) (bool, error) {
+	credentials, err := GetBusCredentials(name)
+	if err != nil || credentials != nil {
+		return false, err
+	}
+
+	password, err := generatePassword()
+	if err != nil {
+		return false, err
+	}
+
+	packedCredentials, err := json.MarshalIndent(BusCredentials{
+		User:     name,
+		Password: password,
+	}, "", "  ")
+
+	if err != nil {
+		return false, err
+	}
+
+	credentialsPath, err := getBusCredentialsPath(name)
+	if err != nil {
+		return false, err
+	}
+
+	return true, ioutil.WriteFile(credentialsPath, packedCredentials, 0600)
+}
+
+func removeBusCredentials(name string) error {
+	credentialsPath, err := getBusCredentialsPath(name)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(credentialsPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// renameBusCredentials moves credentials of instance to new name, password is kept and user is renamed with instance
+func renameBusCredentials(transaction *fileTransaction, name string, newName string) error {
+	credentials, err := GetBusCredentials(name)
+	if err != nil || credentials == nil {
+		return err
+	}
+
+	credentials.User = newName
+
+	packedCredentials, err := json.MarshalIndent(credentials, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	newCredentialsPath, err := getBusCredentialsPath(newName)
+	if err != nil {
+		return err
+	}
+
+	err = transaction.writeFile(newCredentialsPath, packedCredentials, 0600)
+	if err != nil {
+		return err
+	}
+
+	credentialsPath, err := getBusCredentialsPath(name)
+	if err != nil {
+		return err
+	}
+
+	return transaction.removeFile(credentialsPath)
+}
+
+// GetBusAdminCredentials returns credentials which cubes uses to manage bus
+func GetBusAdminCredentials() (*BusCredentials, error) {
+	_, err := ensureBusCredentials(busAdminUser)
+	if err != nil {
+		return nil, err
+	}
+
+	return GetBusCredentials(busAdminUser)
+}
+
+// GetBusAuthConfigPath returns path of bus config with users of all instances
+func GetBusAuthConfigPath() (string, error) {
+	busDirectory, err := utils.GetStateDirectoryPath("bus")
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(busDirectory, "auth.conf"), nil
+}
+
+// WriteBusAuthConfig writes bus config with users of all instances, instances may use only channels of their mappings
+func WriteBusAuthConfig() error {
+	configs, err := GetList()
+	if err != nil {
+		return err
+	}
+
+	adminCredentials, err := GetBusAdminCredentials()
+	if err != nil {
+		return err
+	}
+
+	users := []string{
+		fmt.Sprintf("    {user: %q, password: %q}", adminCredentials.User, adminCredentials.Password),
+	}
+
+	for _, config := range *configs {
+		credentials, err := GetBusCredentials(config.Name)
+		if err != nil {
+			return err
+		}
+
+		if credentials == nil {
+			continue
+		}
+
+		users = append(users, formatBusUser(config, *credentials))
+	}
+
+	authConfig := "authorization {\n  users = [\n" + strings.Join(users, "\n") + "\n  ]\n}\n"
+
+	authConfigPath, err := GetBusAuthConfigPath()
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(authConfigPath, []byte(authConfig), 0600)
+}
+
+// updateBusAuth rewrites bus users and makes running bus reload them
+func updateBusAuth() error {
+	err := WriteBusAuthConfig()
+	if err != nil {
+		return err
+	}
+
+	containerInfo, err := utils.InspectContainer(utils.GetBusContainerName())
+	if err != nil {
+		if docker_client.IsErrConnectionFailed(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	if containerInfo == nil || containerInfo.State == nil || !containerInfo.State.Running {
+		return nil
+	}
+
+	client, err := docker_client.NewEnvClient()
+	if err != nil {
+		return err
+	}
+
+	defer client.Close()
+
+	return client.ContainerKill(context.Background(), utils.GetBusContainerName(), busReloadSignal)
+}
+
+// getBusCredentialsEnv returns environment variables with instance credentials for executor
+func getBusCredentialsEnv(name string) ([]string, error) {
+	credentials, err := GetBusCredentials(name)
+	if err != nil || credentials == nil {
+		return []string{}, err
+	}
+
+	return []string{
+		"CUBE_BUS_USER=" + credentials.User,
+		"CUBE_BUS_PASSWORD=" + credentials.Password,
+	}, nil
+}
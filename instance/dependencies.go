@@ -0,0 +1,256 @@
+package instance
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/akaumov/cubes/utils"
+)
+
+// DefaultParallelism is number of instances, which up and instance start with filter start at once
+const DefaultParallelism = 4
+
+func checkDependsOn(config Config) error {
+	for _, dependency := range config.DependsOn {
+		if dependency == config.Name {
+			return fmt.Errorf("instance %v can't depend on itself", config.Name)
+		}
+	}
+
+	return nil
+}
+
+// SortByDependencies returns configs in order of start: every instance goes after instances it depends on,
+// independent instances are sorted by names
+func SortByDependencies(configs []Config) ([]Config, error) {
+	configsByNames := map[string]Config{}
+	names := []string{}
+
+	for _, config := range configs {
+		configsByNames[config.Name] = config
+		names = append(names, config.Name)
+	}
+
+	sort.Strings(names)
+
+	const (
+		notVisited = iota
+		visiting
+		visited
+	)
+
+	states := map[string]int{}
+	sorted := []Config{}
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch states[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("instances depend on each other: %v", strings.Join(append(path, name), " -> "))
+		}
+
+		config := configsByNames[name]
+		states[name] = visiting
+
+		dependencies := append([]string{}, config.DependsOn...)
+		sort.Strings(dependencies)
+
+		for _, dependency := range dependencies {
+			if _, ok := configsByNames[dependency]; !ok {
+				return fmt.Errorf("instance %v depends on %v, which doesn't exist", name, dependency)
+			}
+
+			err := visit(dependency, append(path, name))
+			if err != nil {
+				return err
+			}
+		}
+
+		states[name] = visited
+		sorted = append(sorted, config)
+		return nil
+	}
+
+	for _, name := range names {
+		err := visit(name, []string{})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return sorted, nil
+}
+
+// HasDependents returns true when other instances depend on instance
+func HasDependents(name string, configs []Config) bool {
+	for _, config := range configs {
+		for _, dependency := range config.DependsOn {
+			if dependency == name {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// getStartDependencies returns dependencies of instances, which are in configs too, and their dependents.
+// Dependencies out of configs aren't started by command, so they aren't waited for.
+func getStartDependencies(configs []Config) (map[string]int, map[string][]string, error) {
+	isSelected := map[string]bool{}
+	for _, config := range configs {
+		isSelected[config.Name] = true
+	}
+
+	dependenciesCounts := map[string]int{}
+	dependents := map[string][]string{}
+
+	for _, config := range configs {
+		dependenciesCounts[config.Name] = 0
+
+		for _, dependency := range config.DependsOn {
+			if isSelected[dependency] {
+				dependenciesCounts[config.Name]++
+				dependents[dependency] = append(dependents[dependency], config.Name)
+			}
+		}
+	}
+
+	// instances, which are left after instances without dependencies are removed one by one, depend on each other
+	counts := map[string]int{}
+	queue := []string{}
+
+	for name, count := range dependenciesCounts {
+		counts[name] = count
+		if count == 0 {
+			queue = append(queue, name)
+		}
+	}
+
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+
+		for _, dependent := range dependents[name] {
+			counts[dependent]--
+			if counts[dependent] == 0 {
+				queue = append(queue, dependent)
+			}
+		}
+
+		delete(counts, name)
+	}
+
+	if len(counts) > 0 {
+		names := []string{}
+		for name := range counts {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+		return nil, nil, fmt.Errorf("instances depend on each other: %v", strings.Join(names, ", "))
+	}
+
+	return dependenciesCounts, dependents, nil
+}
+
+// StartConcurrently starts instances by start with at most parallelism of them at once. Instance is started after
+// instances it depends on are started and ready, instance with dependents is waited until it's ready then.
+// After failure, starts, which are already running, are finished, but nothing new is started, even instances, which
+// don't depend on failed one. It returns number of started instances with error of the first failed one.
+func StartConcurrently(configs []Config, parallelism int, readyTimeout time.Duration, start func(config Config) error) (int, error) {
+	waitForReady := func(name string) error {
+		return WaitForReady(name, readyTimeout)
+	}
+
+	return startConcurrently(configs, parallelism, start, waitForReady)
+}
+
+// startConcurrently is StartConcurrently, which waits for readiness of instances with waitForReady
+func startConcurrently(configs []Config, parallelism int, start func(config Config) error, waitForReady func(name string) error) (int, error) {
+	dependenciesCounts, dependents, err := getStartDependencies(configs)
+	if err != nil {
+		return 0, err
+	}
+
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	configsByNames := map[string]Config{}
+	ready := []string{}
+
+	for _, config := range configs {
+		configsByNames[config.Name] = config
+
+		if dependenciesCounts[config.Name] == 0 {
+			ready = append(ready, config.Name)
+		}
+	}
+
+	type startResult struct {
+		name string
+		err  error
+	}
+
+	results := make(chan startResult)
+
+	progress := utils.StartProgress("Starting instances", len(configs))
+	defer progress.Done()
+
+	running := 0
+	started := 0
+	var firstErr error
+
+	for {
+		for firstErr == nil && running < parallelism && len(ready) > 0 {
+			config := configsByNames[ready[0]]
+			ready = ready[1:]
+			running++
+
+			progress.Step(config.Name)
+
+			go func(config Config) {
+				err := start(config)
+				if err == nil && len(dependents[config.Name]) > 0 {
+					err = waitForReady(config.Name)
+				}
+
+				results <- startResult{
+					name: config.Name,
+					err:  err,
+				}
+			}(config)
+		}
+
+		if running == 0 {
+			break
+		}
+
+		result := <-results
+		running--
+
+		if result.err != nil {
+			if firstErr == nil {
+				firstErr = result.err
+			}
+
+			continue
+		}
+
+		started++
+
+		for _, dependent := range dependents[result.name] {
+			dependenciesCounts[dependent]--
+			if dependenciesCounts[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	return started, firstErr
+}
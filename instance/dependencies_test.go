@@ -0,0 +1,135 @@
+package instance
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// startRecorder records order of start steps of instances, which are started concurrently
+type startRecorder struct {
+	mutex sync.Mutex
+	steps []string
+}
+
+func (r *startRecorder) add(step string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.steps = append(r.steps, step)
+}
+
+func (r *startRecorder) index(step string) int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for index, recorded := range r.steps {
+		if recorded == step {
+			return index
+		}
+	}
+
+	return -1
+}
+
+func TestStartConcurrentlyWaitsForDependency(t *testing.T) {
+	recorder := &startRecorder{}
+
+	configs := []Config{
+		{Name: "api", DependsOn: []string{"db"}},
+		{Name: "db"},
+		{Name: "worker"},
+	}
+
+	start := func(config Config) error {
+		recorder.add("start " + config.Name)
+		return nil
+	}
+
+	waitForReady := func(name string) error {
+		time.Sleep(20 * time.Millisecond)
+		recorder.add("ready " + name)
+		return nil
+	}
+
+	started, err := startConcurrently(configs, 3, start, waitForReady)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if started != 3 {
+		t.Fatalf("expected 3 started instances, got %v", started)
+	}
+
+	if recorder.index("ready db") == -1 || recorder.index("start api") < recorder.index("ready db") {
+		t.Fatalf("api is started before db is ready: %v", recorder.steps)
+	}
+
+	// instances without dependents aren't waited for
+	if recorder.index("ready worker") != -1 || recorder.index("ready api") != -1 {
+		t.Fatalf("instances without dependents are waited for: %v", recorder.steps)
+	}
+}
+
+func TestStartConcurrentlyFinishesRunningStartsAfterFailure(t *testing.T) {
+	recorder := &startRecorder{}
+
+	configs := []Config{
+		{Name: "broken"},
+		{Name: "slow"},
+		{Name: "queued"},
+		{Name: "dependent", DependsOn: []string{"broken"}},
+	}
+
+	start := func(config Config) error {
+		switch config.Name {
+		case "broken":
+			return fmt.Errorf("can't start container")
+		case "slow":
+			time.Sleep(50 * time.Millisecond)
+		}
+
+		recorder.add("started " + config.Name)
+		return nil
+	}
+
+	waitForReady := func(name string) error {
+		return nil
+	}
+
+	started, err := startConcurrently(configs, 2, start, waitForReady)
+	if err == nil || err.Error() != "can't start container" {
+		t.Fatalf("expected error of broken instance, got %v", err)
+	}
+
+	if started != 1 || recorder.index("started slow") == -1 {
+		t.Fatalf("running start of sibling isn't finished after failure: %v started, %v", started, recorder.steps)
+	}
+
+	// queued sibling doesn't depend on broken instance, but nothing new is started after failure
+	if recorder.index("started queued") != -1 {
+		t.Fatalf("queued instance is started after failure: %v", recorder.steps)
+	}
+
+	if recorder.index("started dependent") != -1 {
+		t.Fatalf("dependent of failed instance is started: %v", recorder.steps)
+	}
+}
+
+func TestStartConcurrentlyRefusesCycles(t *testing.T) {
+	configs := []Config{
+		{Name: "a", DependsOn: []string{"b"}},
+		{Name: "b", DependsOn: []string{"a"}},
+	}
+
+	start := func(config Config) error {
+		t.Fatalf("instance %v is started", config.Name)
+		return nil
+	}
+
+	_, err := startConcurrently(configs, 2, start, func(name string) error { return nil })
+	if err == nil || err.Error() != "instances depend on each other: a, b" {
+		t.Fatalf("expected error of cycle, got %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package instance
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/akaumov/cubes/utils"
+)
+
+const devPollInterval = time.Second
+
+var devIgnoredDirectories = []string{".git", ".cubes", "vendor", "node_modules"}
+
+// getSourceState returns modification times of source files, polling is used because watching isn't available on all platforms
+func getSourceState(sourcePath string) (map[string]time.Time, error) {
+	state := map[string]time.Time{}
+
+	err := filepath.Walk(sourcePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			for _, ignoredDirectory := range devIgnoredDirectories {
+				if info.Name() == ignoredDirectory {
+					return filepath.SkipDir
+				}
+			}
+
+			return nil
+		}
+
+		if strings.HasPrefix(info.Name(), ".") || info.Name() == cubeBinaryName {
+			return nil
+		}
+
+		state[path] = info.ModTime()
+		return nil
+	})
+
+	return state, err
+}
+
+func isSourceStateChanged(previous map[string]time.Time, current map[string]time.Time) bool {
+	if len(previous) != len(current) {
+		return true
+	}
+
+	for path, modTime := range current {
+		previousModTime, ok := previous[path]
+		if !ok || !previousModTime.Equal(modTime) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func restartDevInstance(name string, output io.Writer) error {
+	_, err := BuildInstance(name)
+	if err != nil {
+		return err
+	}
+
+	status, _ := GetStatus(name)
+	if IsActiveStatus(status) {
+		err = Stop(name)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = Start(name)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		err := Logs(name, true, output)
+		if err != nil {
+			utils.Warningf("Can't stream logs: %v\n", err)
+		}
+	}()
+
+	return nil
+}
+
+// Dev watches instance's local source, rebuilds and restarts instance on changes and streams its logs
+func Dev(name string, output io.Writer) error {
+	config, err := GetConfig(name)
+	if err != nil {
+		return err
+	}
+
+	sourceType, sourcePath, err := splitSource(config.Source)
+	if err != nil {
+		return err
+	}
+
+	if sourceType != SourcePath {
+		return fmt.Errorf("instance '%v' doesn't have local path source", name)
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	var sourceState map[string]time.Time
+	ticker := time.NewTicker(devPollInterval)
+	defer ticker.Stop()
+
+	utils.Infof("Watching %v, press Ctrl+C to stop\n", sourcePath)
+
+	for {
+		currentState, err := getSourceState(sourcePath)
+		if err != nil {
+			return fmt.Errorf("can't read source directory: %v", err)
+		}
+
+		if sourceState == nil || isSourceStateChanged(sourceState, currentState) {
+			sourceState = currentState
+			utils.Infof("Source changed, rebuilding...")
+
+			err = restartDevInstance(name, output)
+			if err != nil {
+				utils.Warningf("Can't restart instance: %v\n", err)
+			}
+		}
+
+		select {
+		case <-interrupt:
+			utils.Infof("Stopping instance...")
+			return Stop(name)
+		case <-ticker.C:
+		}
+	}
+}
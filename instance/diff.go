@@ -0,0 +1,136 @@
+package instance
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+const (
+	ChangeAdded    = "+"
+	ChangeRemoved  = "-"
+	ChangeModified = "~"
+)
+
+type ConfigChange struct {
+	Type     string `json:"type"`
+	Path     string `json:"path"`
+	OldValue string `json:"oldValue"`
+	NewValue string `json:"newValue"`
+}
+
+func (c ConfigChange) String() string {
+	switch c.Type {
+	case ChangeAdded:
+		return fmt.Sprintf("%v %v: %v", c.Type, c.Path, c.NewValue)
+	case ChangeRemoved:
+		return fmt.Sprintf("%v %v: %v", c.Type, c.Path, c.OldValue)
+	}
+
+	return fmt.Sprintf("%v %v: %v -> %v", c.Type, c.Path, c.OldValue, c.NewValue)
+}
+
+// flattenValue converts decoded json to map of paths like "params.host" or "groups[0]" to json values
+func flattenValue(prefix string, value interface{}, result map[string]string) {
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		for key, item := range typedValue {
+			path := key
+			if prefix != "" {
+				path = prefix + "." + key
+			}
+
+			flattenValue(path, item, result)
+		}
+		break
+	case []interface{}:
+		for index, item := range typedValue {
+			flattenValue(prefix+"["+strconv.Itoa(index)+"]", item, result)
+		}
+		break
+	default:
+		packedValue, _ := json.Marshal(typedValue)
+		result[prefix] = string(packedValue)
+	}
+}
+
+func flattenConfig(config Config) (map[string]string, error) {
+	packedConfig, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var decodedConfig interface{}
+	err = json.Unmarshal(packedConfig, &decodedConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]string{}
+	flattenValue("", decodedConfig, result)
+	return result, nil
+}
+
+func diffConfigs(oldConfig Config, newConfig Config) ([]ConfigChange, error) {
+	oldValues, err := flattenConfig(oldConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	newValues, err := flattenConfig(newConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []ConfigChange{}
+
+	for path, oldValue := range oldValues {
+		newValue, ok := newValues[path]
+		if !ok {
+			changes = append(changes, ConfigChange{Type: ChangeRemoved, Path: path, OldValue: oldValue})
+		} else if newValue != oldValue {
+			changes = append(changes, ConfigChange{Type: ChangeModified, Path: path, OldValue: oldValue, NewValue: newValue})
+		}
+	}
+
+	for path, newValue := range newValues {
+		if _, ok := oldValues[path]; !ok {
+			changes = append(changes, ConfigChange{Type: ChangeAdded, Path: path, NewValue: newValue})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+
+	return changes, nil
+}
+
+// Diff returns changes of instance config which aren't applied to running instance
+func Diff(name string) ([]ConfigChange, error) {
+	config, err := GetConfig(name)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := GetStatus(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !IsActiveStatus(status) {
+		return nil, fmt.Errorf("instance '%v' is not running", name)
+	}
+
+	state, err := GetState(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if state == nil || state.StartedConfig == nil {
+		return nil, fmt.Errorf("instance '%v' wasn't started by cubes, config it was started with is unknown", name)
+	}
+
+	return diffConfigs(*state.StartedConfig, *config)
+}
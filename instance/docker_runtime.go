@@ -0,0 +1,692 @@
+package instance
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akaumov/cubes/utils"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	docker_client "github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
+	"golang.org/x/net/context"
+)
+
+const cubeCompilerImage = "azatk/cube-compiler:latest"
+const cubeInstanceImage = "azatk/cube-instance:latest"
+
+// instanceReloadSignal makes executor reread config of instance and resubscribe channels of cube
+const instanceReloadSignal = "SIGHUP"
+
+type dockerRuntime struct{}
+
+func (r *dockerRuntime) Start(instanceConfig Config) error {
+	sourceType, sourceData, err := splitSource(instanceConfig.Source)
+	if err != nil {
+		return err
+	}
+
+	imageToRun := cubeInstanceImage
+	appPath := ""
+
+	if sourceType == SourceGo {
+		utils.Infof("Pulling cube compiler image...")
+		err = utils.PullImage(cubeCompilerImage)
+		if err != nil {
+			return fmt.Errorf("can't pull compiler image: %v/n", err)
+		}
+
+		utils.Infof("Compiling cube...")
+		tempDir, err := ioutil.TempDir("", "cubes_")
+		if err != nil {
+			return fmt.Errorf("can't create temp directory for build %v/n", err)
+		}
+
+		defer func() { os.RemoveAll(tempDir) }()
+
+		err = compileGoCube(sourceData, tempDir)
+		if err != nil {
+			return fmt.Errorf("can't compile cube %v/n", err)
+		}
+
+		appPath = filepath.Join(tempDir, "cube.tar")
+	} else if sourceType == SourceGit {
+		tempDir, err := ioutil.TempDir("", "cubes_")
+		if err != nil {
+			return fmt.Errorf("can't create temp directory for build %v/n", err)
+		}
+
+		defer func() { os.RemoveAll(tempDir) }()
+
+		appPath, err = buildGitSource(instanceConfig, tempDir)
+		if err != nil {
+			return fmt.Errorf("can't build cube %v/n", err)
+		}
+	} else if sourceType == SourceArchive {
+		tempDir, err := ioutil.TempDir("", "cubes_")
+		if err != nil {
+			return fmt.Errorf("can't create temp directory for archive %v/n", err)
+		}
+
+		defer func() { os.RemoveAll(tempDir) }()
+
+		appPath, err = fetchArchiveSource(sourceData, tempDir)
+		if err != nil {
+			return fmt.Errorf("can't fetch cube archive %v/n", err)
+		}
+	} else if sourceType == SourcePath {
+		buildId := instanceConfig.Build
+		if buildId == "" {
+			buildId, err = BuildInstance(instanceConfig.Name)
+			if err != nil {
+				return fmt.Errorf("can't build cube %v/n", err)
+			}
+		}
+
+		appPath, err = getBuildArchivePath(buildId)
+		if err != nil {
+			return err
+		}
+	} else if sourceType == SourceDocker {
+		imageToRun = getPinnedImage(instanceConfig, sourceData)
+	}
+
+	utils.Infof("Runing cube instance...")
+	err = utils.PullImage(imageToRun)
+	if err != nil {
+		return fmt.Errorf("can't pull cube instance image: %v/n", err)
+	}
+
+	err = runCubeInstance(imageToRun, appPath, instanceConfig)
+	if err != nil {
+		return fmt.Errorf("can't run cube instance %v/n", err)
+	}
+
+	return nil
+}
+
+func (r *dockerRuntime) Stop(instanceConfig Config) error {
+	ctx := context.Background()
+	client, err := docker_client.NewEnvClient()
+
+	if err != nil {
+		return fmt.Errorf("can't connect to docker service: %v", err)
+	}
+
+	defer client.Close()
+
+	err = client.ContainerStop(ctx, instanceConfig.Name, nil)
+	if err != nil {
+		return fmt.Errorf("can't stop instance container: %v", err)
+	}
+
+	if instanceConfig.Restart != nil {
+		err = client.ContainerRemove(ctx, instanceConfig.Name, types.ContainerRemoveOptions{})
+		if err != nil && !docker_client.IsErrContainerNotFound(err) {
+			return fmt.Errorf("can't remove instance container: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func (r *dockerRuntime) Pause(instanceConfig Config) error {
+	ctx := context.Background()
+	client, err := docker_client.NewEnvClient()
+
+	if err != nil {
+		return fmt.Errorf("can't connect to docker service: %v", err)
+	}
+
+	defer client.Close()
+
+	err = client.ContainerPause(ctx, instanceConfig.Name)
+	if err != nil {
+		return fmt.Errorf("can't pause instance container: %v", err)
+	}
+
+	return nil
+}
+
+func (r *dockerRuntime) Resume(instanceConfig Config) error {
+	ctx := context.Background()
+	client, err := docker_client.NewEnvClient()
+
+	if err != nil {
+		return fmt.Errorf("can't connect to docker service: %v", err)
+	}
+
+	defer client.Close()
+
+	err = client.ContainerUnpause(ctx, instanceConfig.Name)
+	if err != nil {
+		return fmt.Errorf("can't resume instance container: %v", err)
+	}
+
+	return nil
+}
+
+func (r *dockerRuntime) Status(instanceConfig Config) (string, error) {
+	containerInfo, err := utils.InspectContainer(instanceConfig.Name)
+	if err != nil {
+		// unreachable docker is told apart by type, down skips instances then
+		if docker_client.IsErrConnectionFailed(err) {
+			return StatusUnknown, utils.ConnectionError(fmt.Errorf("can't inspect instance container: %v", err))
+		}
+
+		return StatusUnknown, fmt.Errorf("can't inspect instance container: %v", err)
+	}
+
+	if containerInfo == nil || containerInfo.State == nil {
+		return StatusStopped, nil
+	}
+
+	if containerInfo.State.Paused {
+		return StatusPaused, nil
+	}
+
+	if isCrashLooping(containerInfo) {
+		return StatusCrashLooping, nil
+	}
+
+	if containerInfo.State.Running {
+		if containerInfo.State.Health != nil {
+			switch containerInfo.State.Health.Status {
+			case types.Starting:
+				return StatusStarting, nil
+			case types.Unhealthy:
+				return StatusUnhealthy, nil
+			}
+		}
+
+		return StatusRunning, nil
+	}
+
+	if containerInfo.State.ExitCode != 0 {
+		return StatusFailed, nil
+	}
+
+	return StatusStopped, nil
+}
+
+func (r *dockerRuntime) Logs(instanceConfig Config, isFollow bool, output io.Writer) error {
+	ctx := context.Background()
+	client, err := docker_client.NewEnvClient()
+
+	if err != nil {
+		return fmt.Errorf("can't connect to docker service: %v", err)
+	}
+
+	defer client.Close()
+
+	logs, err := client.ContainerLogs(ctx, instanceConfig.Name, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     isFollow,
+	})
+
+	if err != nil {
+		return fmt.Errorf("can't read instance logs: %v", err)
+	}
+
+	defer logs.Close()
+
+	_, err = io.Copy(output, logs)
+	return err
+}
+
+func (r *dockerRuntime) Metrics(instanceConfig Config) (*Metrics, error) {
+	status, err := r.Status(instanceConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := Metrics{
+		Name:   instanceConfig.Name,
+		Status: status,
+	}
+
+	if !IsActiveStatus(status) {
+		return &metrics, nil
+	}
+
+	containerInfo, err := utils.InspectContainer(instanceConfig.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if containerInfo != nil {
+		metrics.Restarts = containerInfo.RestartCount
+
+		startedAt, err := time.Parse(time.RFC3339Nano, containerInfo.State.StartedAt)
+		if err == nil {
+			metrics.UptimeSeconds = time.Since(startedAt).Seconds()
+		}
+	}
+
+	ctx := context.Background()
+	client, err := docker_client.NewEnvClient()
+
+	if err != nil {
+		return nil, fmt.Errorf("can't connect to docker service: %v", err)
+	}
+
+	defer client.Close()
+
+	stats, err := client.ContainerStats(ctx, instanceConfig.Name, false)
+	if err != nil {
+		return nil, fmt.Errorf("can't read instance container stats: %v", err)
+	}
+
+	defer stats.Body.Close()
+
+	var statsData types.StatsJSON
+	err = json.NewDecoder(stats.Body).Decode(&statsData)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse instance container stats: %v", err)
+	}
+
+	metrics.CpuSeconds = float64(statsData.CPUStats.CPUUsage.TotalUsage) / float64(time.Second)
+	metrics.MemoryBytes = statsData.MemoryStats.Usage
+
+	if status != StatusPaused {
+		metrics.OpenFiles = r.getOpenFiles(instanceConfig)
+	}
+
+	if containerInfo != nil && containerInfo.NetworkSettings != nil {
+		metrics.InMessages, metrics.OutMessages = getBusMessages(containerInfo)
+	}
+
+	return &metrics, nil
+}
+
+// getOpenFiles counts file descriptors of cube process, which is the first process of container
+func (r *dockerRuntime) getOpenFiles(instanceConfig Config) int {
+	output := bytes.Buffer{}
+
+	exitCode, err := r.Exec(instanceConfig, []string{"sh", "-c", "ls /proc/1/fd | wc -l"}, strings.NewReader(""), &output)
+	if err != nil || exitCode != 0 {
+		return 0
+	}
+
+	openFiles, _ := strconv.Atoi(strings.TrimSpace(output.String()))
+	return openFiles
+}
+
+// getBusMessages sums message counters of bus connections made from container addresses,
+// they are zero when bus monitoring isn't available
+func getBusMessages(containerInfo *types.ContainerJSON) (uint64, uint64) {
+	connections, err := utils.GetBusConnections()
+	if err != nil {
+		return 0, 0
+	}
+
+	addresses := map[string]bool{}
+	for _, network := range containerInfo.NetworkSettings.Networks {
+		if network != nil && network.IPAddress != "" {
+			addresses[network.IPAddress] = true
+		}
+	}
+
+	var inMessages, outMessages uint64
+
+	for _, connection := range *connections {
+		if addresses[connection.IP] {
+			inMessages += connection.InMessages
+			outMessages += connection.OutMessages
+		}
+	}
+
+	return inMessages, outMessages
+}
+
+func (r *dockerRuntime) Attach(instanceConfig Config, input io.Reader, output io.Writer, detachKeys string) error {
+	ctx := context.Background()
+	client, err := docker_client.NewEnvClient()
+
+	if err != nil {
+		return fmt.Errorf("can't connect to docker service: %v", err)
+	}
+
+	defer client.Close()
+
+	connection, err := client.ContainerAttach(ctx, instanceConfig.Name, types.ContainerAttachOptions{
+		Stream:     true,
+		Stdin:      true,
+		Stdout:     true,
+		Stderr:     true,
+		DetachKeys: detachKeys,
+	})
+
+	if err != nil {
+		return fmt.Errorf("can't attach to instance container: %v", err)
+	}
+
+	defer connection.Close()
+
+	go func() {
+		io.Copy(connection.Conn, input)
+		connection.CloseWrite()
+	}()
+
+	_, err = io.Copy(output, connection.Reader)
+	return err
+}
+
+func (r *dockerRuntime) Exec(instanceConfig Config, command []string, input io.Reader, output io.Writer) (int, error) {
+	ctx := context.Background()
+	client, err := docker_client.NewEnvClient()
+
+	if err != nil {
+		return 0, fmt.Errorf("can't connect to docker service: %v", err)
+	}
+
+	defer client.Close()
+
+	execConfig := types.ExecConfig{
+		Tty:          true,
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+		Cmd:          command,
+	}
+
+	execution, err := client.ContainerExecCreate(ctx, instanceConfig.Name, execConfig)
+	if err != nil {
+		return 0, fmt.Errorf("can't create exec in instance container: %v", err)
+	}
+
+	connection, err := client.ContainerExecAttach(ctx, execution.ID, execConfig)
+	if err != nil {
+		return 0, fmt.Errorf("can't attach to exec in instance container: %v", err)
+	}
+
+	defer connection.Close()
+
+	go func() {
+		io.Copy(connection.Conn, input)
+		connection.CloseWrite()
+	}()
+
+	_, err = io.Copy(output, connection.Reader)
+	if err != nil {
+		return 0, err
+	}
+
+	executionInfo, err := client.ContainerExecInspect(ctx, execution.ID)
+	if err != nil {
+		return 0, fmt.Errorf("can't inspect exec in instance container: %v", err)
+	}
+
+	return executionInfo.ExitCode, nil
+}
+
+// Reload copies config with new channels mapping to running instance container and makes executor reread it.
+// Config in container has resolved secrets, so only its channels mapping is replaced.
+func (r *dockerRuntime) Reload(instanceConfig Config) error {
+	ctx := context.Background()
+	client, err := docker_client.NewEnvClient()
+
+	if err != nil {
+		return utils.ConnectionError(fmt.Errorf("can't connect to docker service: %v", err))
+	}
+
+	defer client.Close()
+
+	runningConfig, err := readContainerConfig(ctx, client, instanceConfig.Name)
+	if err != nil {
+		return fmt.Errorf("can't read config of running instance: %v", err)
+	}
+
+	runningConfig.ChannelsMapping = instanceConfig.ChannelsMapping
+
+	configArchive, err := getConfigArchive(*runningConfig)
+	if err != nil {
+		return fmt.Errorf("can't pack instance config: %v", err)
+	}
+
+	err = client.CopyToContainer(ctx, instanceConfig.Name, "/", configArchive, types.CopyToContainerOptions{})
+	if err != nil {
+		return fmt.Errorf("can't copy config to instance container: %v", err)
+	}
+
+	err = client.ContainerKill(ctx, instanceConfig.Name, instanceReloadSignal)
+	if err != nil {
+		return fmt.Errorf("can't signal instance to reload config: %v", err)
+	}
+
+	return nil
+}
+
+// readContainerConfig reads config, which instance container is started with
+func readContainerConfig(ctx context.Context, client *docker_client.Client, containerId string) (*Config, error) {
+	archive, _, err := client.CopyFromContainer(ctx, containerId, "/config.json")
+	if err != nil {
+		return nil, err
+	}
+
+	defer archive.Close()
+
+	tarReader := tar.NewReader(archive)
+
+	_, err = tarReader.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	err = json.NewDecoder(tarReader).Decode(&config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+func compileGoCube(cubePackage string, outputDir string) error {
+	ctx := context.Background()
+	client, err := docker_client.NewEnvClient()
+
+	if err != nil {
+		return utils.ConnectionError(fmt.Errorf("can't connect to docker service: %v", err))
+	}
+
+	defer client.Close()
+
+	resp, err := client.ContainerCreate(ctx, &container.Config{
+		Image: cubeCompilerImage,
+		Tty:   true,
+		Env:   []string{"CUBE_PACKAGE=" + cubePackage},
+	}, &container.HostConfig{
+		AutoRemove: true,
+		Binds:      []string{outputDir + ":/build:rw"},
+	}, nil, "")
+
+	if err != nil {
+		return fmt.Errorf("can't create docker container: %v", err)
+	}
+
+	if err := client.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
+		return fmt.Errorf("can't start docker container: %v", err)
+	}
+
+	client.ContainerWait(ctx, resp.ID)
+	return nil
+}
+
+func runCubeInstance(image string, appPath string, config Config) error {
+	ctx := context.Background()
+	client, err := docker_client.NewEnvClient()
+
+	if err != nil {
+		return utils.ConnectionError(fmt.Errorf("can't connect to docker service: %v", err))
+	}
+
+	defer client.Close()
+
+	client.ContainerStop(ctx, config.Name, nil)
+	client.ContainerRemove(ctx, config.Name, types.ContainerRemoveOptions{})
+
+	exposedPorts := nat.PortSet{}
+	portMap := nat.PortMap{}
+
+	for _, portData := range config.PortsMapping {
+
+		port, err := nat.NewPort(string(portData.Protocol), strconv.FormatUint(uint64(portData.CubePort), 10))
+		if err != nil {
+			return err
+		}
+
+		exposedPorts[port] = struct{}{}
+		portMap[port] = []nat.PortBinding{
+			{
+				HostIP:   "",
+				HostPort: strconv.FormatUint(uint64(portData.HostPort), 10),
+			},
+		}
+	}
+
+	volumesBinds, err := getVolumesBinds(config.Volumes)
+	if err != nil {
+		return err
+	}
+
+	binds := volumesBinds
+
+	tlsBinds, tlsEnv, err := getBusTLSOptions()
+	if err != nil {
+		return fmt.Errorf("can't read bus certificates: %v", err)
+	}
+
+	binds = append(binds, tlsBinds...)
+
+	credentialsEnv, err := getBusCredentialsEnv(config.Name)
+	if err != nil {
+		return fmt.Errorf("can't read bus credentials: %v", err)
+	}
+
+	env := append(getConfigEnv(config.Env), getPortsEnv(config.PortsMapping)...)
+	env = append(env, tlsEnv...)
+	env = append(env, credentialsEnv...)
+	env = append(env, "CUBE_BUS_URL=nats://"+utils.BusContainerName+":"+utils.BusPort)
+	env = append(env, getBusProtocolEnv()...)
+	env = append(env, getBusReconnectEnv(config.BusReconnect)...)
+
+	resp, err := client.ContainerCreate(ctx, &container.Config{
+		Image:        image,
+		Tty:          true,
+		OpenStdin:    true,
+		Env:          env,
+		Healthcheck:  getReadinessHealthcheck(config.Readiness, appPath != ""),
+		ExposedPorts: exposedPorts,
+		Labels: map[string]string{
+			"_CUBE":             "true",
+			"_CUBE_CLASS":       config.Class,
+			"_CUBE_NAME":        config.Name,
+			"_CUBE_VERSION":     config.Version,
+			"_CUBE_QUEUE_GROUP": config.QueueGroup,
+		},
+	}, &container.HostConfig{
+		// docker can't restart removed container, so container with restart policy is removed on stop
+		AutoRemove:    config.Restart == nil,
+		RestartPolicy: getDockerRestartPolicy(config.Restart),
+		Links:         []string{utils.GetBusContainerName() + ":" + utils.BusContainerName},
+		Binds:         binds,
+		PortBindings:  portMap,
+		LogConfig:     getLogConfig(config.LogRotation),
+		Resources:     getDockerResources(config.Resources),
+	}, nil, config.Name)
+
+	if err != nil {
+		return fmt.Errorf("can't create docker container: %v", err)
+	}
+
+	err = startCubeContainer(ctx, client, resp.ID, appPath, config)
+	if err != nil {
+		// container isn't left half-created, next start creates it again
+		client.ContainerRemove(ctx, resp.ID, types.ContainerRemoveOptions{Force: true})
+		return err
+	}
+
+	return nil
+}
+
+// startCubeContainer copies compiled cube and config to created container and starts it. Config has resolved
+// secrets, so it's copied to container instead of file of project, and it's removed with container.
+func startCubeContainer(ctx context.Context, client *docker_client.Client, containerId string, appPath string, config Config) error {
+	if appPath != "" {
+		file, err := os.Open(appPath)
+		if err != nil {
+			return fmt.Errorf("can't read compiled cube: %v", err)
+		}
+
+		defer file.Close()
+
+		err = client.CopyToContainer(ctx, containerId, "/home/app", file, types.CopyToContainerOptions{
+			AllowOverwriteDirWithFile: true,
+		})
+
+		if err != nil {
+			return fmt.Errorf("can't copy compiled app to instance container: %v", err)
+		}
+	}
+
+	configArchive, err := getConfigArchive(config)
+	if err != nil {
+		return fmt.Errorf("can't pack instance config: %v", err)
+	}
+
+	err = client.CopyToContainer(ctx, containerId, "/", configArchive, types.CopyToContainerOptions{})
+	if err != nil {
+		return fmt.Errorf("can't copy config to instance container: %v", err)
+	}
+
+	err = client.ContainerStart(ctx, containerId, types.ContainerStartOptions{})
+	if err != nil {
+		return fmt.Errorf("can't start instance container: %v", err)
+	}
+
+	return nil
+}
+
+// getConfigArchive packs config as /config.json, which executor reads
+func getConfigArchive(config Config) (io.Reader, error) {
+	rawConfig, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	var archive bytes.Buffer
+	tarWriter := tar.NewWriter(&archive)
+
+	err = tarWriter.WriteHeader(&tar.Header{
+		Name: "config.json",
+		Mode: 0644,
+		Size: int64(len(rawConfig)),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tarWriter.Write(rawConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tarWriter.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return &archive, nil
+}
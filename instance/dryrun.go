@@ -0,0 +1,75 @@
+package instance
+
+import (
+	"os"
+
+	"github.com/akaumov/cubes/utils"
+)
+
+// printFileRemoval prints removal of file in dry run, missing files aren't printed, because nothing is removed then
+func printFileRemoval(path string, err error) error {
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	utils.DryRunf("delete file %v\n", path)
+	return nil
+}
+
+// printBusAuthUpdate prints what updateBusAuth does: bus users are rewritten and running bus is signaled to reload them
+func printBusAuthUpdate() error {
+	authConfigPath, err := GetBusAuthConfigPath()
+	if err != nil {
+		return err
+	}
+
+	utils.DryRunf("rewrite bus users in %v\n", authConfigPath)
+
+	containerInfo, err := utils.InspectContainer(utils.GetBusContainerName())
+	if err == nil && containerInfo != nil && containerInfo.State != nil && containerInfo.State.Running {
+		utils.DryRunf("send %v to bus container %v\n", busReloadSignal, utils.GetBusContainerName())
+	}
+
+	return nil
+}
+
+// printRemove prints what Remove does with instance
+func printRemove(name string) error {
+	err := printFileRemoval(getInstanceConfigPath(name))
+	if err != nil {
+		return err
+	}
+
+	err = printFileRemoval(getBusCredentialsPath(name))
+	if err != nil {
+		return err
+	}
+
+	return printBusAuthUpdate()
+}
+
+// printStop prints what Stop does with instance, runtime isn't called
+func printStop(config Config, runtime Runtime) error {
+	switch runtime.(type) {
+	case *remoteRuntime:
+		utils.DryRunf("ask agent %v to stop instance %v\n", config.Host, config.Name)
+	case *dockerRuntime:
+		utils.DryRunf("stop container %v\n", config.Name)
+
+		if config.Restart != nil {
+			utils.DryRunf("remove container %v\n", config.Name)
+		}
+	default:
+		utils.DryRunf("stop instance %v with runtime %v\n", config.Name, config.Runtime)
+	}
+
+	return printFileRemoval(getStatePath(config.Name))
+}
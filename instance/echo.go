@@ -0,0 +1,25 @@
+package instance
+
+import (
+	"github.com/akaumov/cube_executor"
+	"github.com/akaumov/cubes/echo"
+)
+
+const EchoSource = "go:github.com/akaumov/cubes/echo"
+
+// AddEcho adds instance of built-in echo, which answers requests of bus channel with their params
+func AddEcho(name string, channel string) error {
+	return Add(Config{
+		CubeConfig: cube_executor.CubeConfig{
+			Name:         name,
+			Source:       EchoSource,
+			Params:       map[string]string{},
+			PortsMapping: []cube_executor.PortMap{},
+			ChannelsMapping: map[cube_executor.CubeChannel]cube_executor.BusChannel{
+				echo.RequestsChannel: cube_executor.BusChannel(channel),
+			},
+		},
+		Groups: []string{},
+		Labels: map[string]string{},
+	})
+}
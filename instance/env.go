@@ -0,0 +1,37 @@
+package instance
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// reservedEnvPrefix is prefix of environment, which cubes passes to executor: bus address, credentials, ports
+const reservedEnvPrefix = "CUBE_"
+
+func checkEnv(env map[string]string) error {
+	for key := range env {
+		if key == "" || strings.ContainsAny(key, "= \t\n") {
+			return fmt.Errorf("wrong environment variable name '%v'", key)
+		}
+
+		if strings.HasPrefix(key, reservedEnvPrefix) {
+			return fmt.Errorf("environment variable %v is reserved, %v variables are set by cubes", key, reservedEnvPrefix)
+		}
+	}
+
+	return nil
+}
+
+// getConfigEnv returns environment of instance in KEY=value form, it's sorted, so container config doesn't change
+// between starts
+func getConfigEnv(env map[string]string) []string {
+	result := []string{}
+
+	for key, value := range env {
+		result = append(result, key+"="+value)
+	}
+
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,43 @@
+package instance
+
+// Filter selects instances by group and labels, empty filter matches all instances
+type Filter struct {
+	Group  string
+	Labels map[string]string
+}
+
+func (f Filter) IsEmpty() bool {
+	return f.Group == "" && len(f.Labels) == 0
+}
+
+func (f Filter) Match(config Config) bool {
+	if f.Group != "" && !config.HasGroup(f.Group) {
+		return false
+	}
+
+	for key, value := range f.Labels {
+		configValue, ok := config.Labels[key]
+		if !ok || configValue != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+func GetFilteredList(filter Filter) (*[]Config, error) {
+	configs, err := GetList()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []Config{}
+
+	for _, config := range *configs {
+		if filter.Match(config) {
+			result = append(result, config)
+		}
+	}
+
+	return &result, nil
+}
@@ -0,0 +1,52 @@
+package instance
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/akaumov/cube_executor"
+	"github.com/akaumov/cubes/gateway"
+)
+
+const (
+	GatewaySource   = "go:github.com/akaumov/cubes/gateway"
+	gatewayCubePort = 80
+)
+
+// AddGateway adds instance of built-in http gateway, which passes requests to bus channels found in routes table
+func AddGateway(name string, routes string, hostPort cube_executor.HostPort, timeoutMs int) error {
+	parsedRoutes, err := gateway.ParseRoutes(routes)
+	if err != nil {
+		return err
+	}
+
+	if len(parsedRoutes) == 0 {
+		return fmt.Errorf("routes are required")
+	}
+
+	params := map[string]string{
+		"routes": routes,
+	}
+
+	if timeoutMs > 0 {
+		params["timeout"] = strconv.Itoa(timeoutMs)
+	}
+
+	return Add(Config{
+		CubeConfig: cube_executor.CubeConfig{
+			Name:   name,
+			Source: GatewaySource,
+			Params: params,
+			PortsMapping: []cube_executor.PortMap{
+				{
+					CubePort: gatewayCubePort,
+					HostPort: hostPort,
+					Protocol: "tcp",
+				},
+			},
+			ChannelsMapping: map[cube_executor.CubeChannel]cube_executor.BusChannel{},
+		},
+		Groups: []string{},
+		Labels: map[string]string{},
+	})
+}
@@ -0,0 +1,205 @@
+package instance
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akaumov/cubes/utils"
+)
+
+type HistoryVersion struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func getHistoryDirectoryPath(name string) (string, error) {
+	return utils.GetStateDirectoryPath("history", name)
+}
+
+func getHistoryVersionPath(name string, version int) (string, error) {
+	historyDirectory, err := getHistoryDirectoryPath(name)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(historyDirectory, strconv.Itoa(version)+".json"), nil
+}
+
+// GetHistory returns saved versions of instance config sorted from oldest to newest
+func GetHistory(name string) (*[]HistoryVersion, error) {
+	historyDirectory, err := getHistoryDirectoryPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := ioutil.ReadDir(historyDirectory)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []HistoryVersion{}
+
+	for _, file := range files {
+		version, err := strconv.Atoi(strings.TrimSuffix(file.Name(), ".json"))
+		if err != nil || file.IsDir() {
+			continue
+		}
+
+		result = append(result, HistoryVersion{
+			Version:   version,
+			CreatedAt: file.ModTime(),
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Version < result[j].Version
+	})
+
+	return &result, nil
+}
+
+func getHistoryVersionText(name string, version int) ([]byte, error) {
+	versionPath, err := getHistoryVersionPath(name, version)
+	if err != nil {
+		return nil, err
+	}
+
+	rawConfig, err := ioutil.ReadFile(versionPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("version %v of instance '%v' doesn't exist", version, name)
+		}
+
+		return nil, err
+	}
+
+	return rawConfig, nil
+}
+
+// addHistoryVersion saves copy of packed config, if it differs from the last saved version
+func addHistoryVersion(name string, packedConfig []byte) error {
+	history, err := GetHistory(name)
+	if err != nil {
+		return err
+	}
+
+	version := 1
+
+	if len(*history) > 0 {
+		lastVersion := (*history)[len(*history)-1].Version
+
+		lastConfig, err := getHistoryVersionText(name, lastVersion)
+		if err != nil {
+			return err
+		}
+
+		if bytes.Equal(lastConfig, packedConfig) {
+			return nil
+		}
+
+		version = lastVersion + 1
+	}
+
+	versionPath, err := getHistoryVersionPath(name, version)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(versionPath, packedConfig, 0666)
+}
+
+// renameHistory moves config history to new name, history of instance, which had new name before, must be removed
+func renameHistory(transaction *fileTransaction, name string, newName string) error {
+	historyDirectory, err := getHistoryDirectoryPath(name)
+	if err != nil {
+		return err
+	}
+
+	newHistoryDirectory, err := getHistoryDirectoryPath(newName)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(newHistoryDirectory)
+	if err != nil {
+		return err
+	}
+
+	return transaction.renameDirectory(historyDirectory, newHistoryDirectory)
+}
+
+// Rollback restores instance config from history, version 0 means the last version which differs from current config
+func Rollback(name string, version int) (int, error) {
+	currentConfig, err := GetConfigText(name)
+	if err != nil {
+		return 0, err
+	}
+
+	if version == 0 {
+		history, err := GetHistory(name)
+		if err != nil {
+			return 0, err
+		}
+
+		for index := len(*history) - 1; index >= 0; index-- {
+			historyVersion := (*history)[index].Version
+
+			rawConfig, err := getHistoryVersionText(name, historyVersion)
+			if err != nil {
+				return 0, err
+			}
+
+			if string(rawConfig) != currentConfig {
+				version = historyVersion
+				break
+			}
+		}
+
+		if version == 0 {
+			return 0, fmt.Errorf("instance '%v' has no previous config versions", name)
+		}
+	}
+
+	rawConfig, err := getHistoryVersionText(name, version)
+	if err != nil {
+		return 0, err
+	}
+
+	rawConfig, _, err = upgradeConfig(name, rawConfig)
+	if err != nil {
+		return 0, err
+	}
+
+	var config Config
+	err = json.Unmarshal(rawConfig, &config)
+	if err != nil {
+		return 0, fmt.Errorf("can't parse config version %v: %v", version, err)
+	}
+
+	// versions saved before renaming keep old name
+	config.Name = name
+
+	err = saveConfig(config)
+	if err != nil {
+		return 0, fmt.Errorf("can't save config: %v", err)
+	}
+
+	return version, nil
+}
+
+func removeHistory(name string) error {
+	historyDirectory, err := getHistoryDirectoryPath(name)
+	if err != nil {
+		return err
+	}
+
+	return os.RemoveAll(historyDirectory)
+}
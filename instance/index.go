@@ -1,37 +1,67 @@
 package instance
 
 import (
-	"github.com/akaumov/cube_executor"
-	"github.com/akaumov/cubes/utils"
-	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/api/types/container"
-	docker_client "github.com/docker/docker/client"
-	"github.com/docker/go-connections/nat"
-	"golang.org/x/net/context"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
-	"path/filepath"
 	"os"
-	"log"
-	"fmt"
-	"strconv"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
+
+	"github.com/akaumov/cube_executor"
+	"github.com/akaumov/cubes/utils"
 )
 
-const Version = "1"
+const Version = "2"
 const instancesDirectoryName = "instances"
 
-const cubeCompilerImage = "azatk/cube-compiler:latest"
-const cubeInstanceImage = "azatk/cube-instance:latest"
+// instanceNameRegexp matches names of instances, name is part of paths of instance files, container and bus user
+var instanceNameRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
 
-func GetInstancesDirectoryPath() (string, error) {
-	pwd, err := os.Getwd()
-	if err != nil {
-		return "", err
+type Config struct {
+	cube_executor.CubeConfig
+	SourceCommit string            `json:"sourceCommit,omitempty"`
+	SourceDigest string            `json:"sourceDigest,omitempty"`
+	Build        string            `json:"build,omitempty"`
+	Runtime      string            `json:"runtime"`
+	Groups       []string          `json:"groups"`
+	Labels       map[string]string `json:"labels"`
+	Volumes      []Volume          `json:"volumes"`
+
+	// Readiness is probe of instance, executor subscribes cube's channels after it passes, instance is starting
+	// until then, so up and --wait wait for it
+	Readiness *ReadinessProbe `json:"readiness,omitempty"`
+
+	LogRotation *LogRotation   `json:"logRotation,omitempty"`
+	Restart     *RestartPolicy `json:"restart,omitempty"`
+
+	BusReconnect *BusReconnect `json:"busReconnect,omitempty"`
+
+	// Host is address of agent which runs instance, instance runs locally if it's empty
+	Host string `json:"host,omitempty"`
+
+	// DependsOn are instances, which are started and ready before this instance is started by cubes up
+	DependsOn []string `json:"dependsOn,omitempty"`
+
+	// Env is environment of instance process, CUBE_ variables are reserved for executor
+	Env       map[string]string `json:"env,omitempty"`
+	Resources *Resources        `json:"resources,omitempty"`
+}
+
+func (c *Config) HasGroup(group string) bool {
+	for _, instanceGroup := range c.Groups {
+		if instanceGroup == group {
+			return true
+		}
 	}
 
-	instancesDirectory := filepath.Join(pwd, instancesDirectoryName)
-	return instancesDirectory, nil
+	return false
+}
+
+func GetInstancesDirectoryPath() (string, error) {
+	return utils.GetInstancesPath(instancesDirectoryName)
 }
 
 func getInstanceConfigPath(name string) (string, error) {
@@ -44,13 +74,252 @@ func getInstanceConfigPath(name string) (string, error) {
 	return instanceConfigPath, nil
 }
 
-func Add(name string, source string, class string, queueGroup string, params map[string]string, portsMapping []cube_executor.PortMap, channelsMapping map[cube_executor.CubeChannel]cube_executor.BusChannel) error {
-	instancesDirectory, err := GetInstancesDirectoryPath()
+func checkInstanceName(name string) error {
+	if !instanceNameRegexp.MatchString(name) {
+		return fmt.Errorf("wrong instance name '%v', it can have letters, digits, '_', '.' and '-' and starts with letter or digit", name)
+	}
+
+	return nil
+}
+
+// checkConfig checks settings of instance, which don't depend on other instances
+func checkConfig(config Config) error {
+	err := checkVolumes(config.Volumes)
+	if err != nil {
+		return err
+	}
+
+	err = checkReadinessProbe(config.Readiness)
+	if err != nil {
+		return err
+	}
+
+	err = checkLogRotation(config.LogRotation)
+	if err != nil {
+		return err
+	}
+
+	err = checkRestartPolicy(config.Restart)
+	if err != nil {
+		return err
+	}
+
+	err = checkDependsOn(config)
+	if err != nil {
+		return err
+	}
+
+	err = checkEnv(config.Env)
+	if err != nil {
+		return err
+	}
+
+	err = checkResources(config.Resources)
+	if err != nil {
+		return err
+	}
+
+	return checkBusReconnect(config.BusReconnect)
+}
+
+// CheckConfig checks that config of instance is read, its runtime is known, its settings are valid
+// and its ports don't conflict with other instances
+func CheckConfig(name string) error {
+	config, err := GetConfig(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = getConfigRuntime(*config)
+	if err != nil {
+		return err
+	}
+
+	err = checkConfig(*config)
+	if err != nil {
+		return err
+	}
+
+	names, err := GetNames()
+	if err != nil {
+		return err
+	}
+
+	// broken configs of other instances are reported by their own checks
+	configs := []Config{}
+	for _, otherName := range names {
+		otherConfig, err := GetConfig(otherName)
+		if err == nil {
+			configs = append(configs, *otherConfig)
+		}
+	}
+
+	return findPortsConflict(*config, configs)
+}
+
+// GetNames returns names of instances by their configs files, configs aren't read,
+// so broken configs are listed too
+func GetNames() ([]string, error) {
+	instancesDirectoryPath, err := GetInstancesDirectoryPath()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := filepath.Glob(filepath.Join(instancesDirectoryPath, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, configPath := range files {
+		names = append(names, strings.TrimSuffix(filepath.Base(configPath), ".json"))
+	}
+
+	return names, nil
+}
+
+// IsDockerRequired returns true when local instance is run by docker runtime
+func IsDockerRequired(config Config) bool {
+	if config.Host != "" {
+		return false
+	}
+
+	runtime := config.Runtime
+	if runtime == "" {
+		runtime = utils.GetDefaultRuntime(defaultRuntime)
+	}
+
+	return runtime == "docker"
+}
+
+func Add(config Config) error {
+	err := checkInstanceName(config.Name)
+	if err != nil {
+		return err
+	}
+
+	if config.Runtime == "" {
+		config.Runtime = utils.GetDefaultRuntime(defaultRuntime)
+	}
+
+	_, err = getRuntime(config.Runtime)
+	if err != nil {
+		return err
+	}
+
+	err = checkConfig(config)
 	if err != nil {
 		return err
 	}
 
 	//TODO: add checking usage of instance name
+	err = createInstancesDirectoryIfNotExist()
+	if err != nil {
+		return err
+	}
+
+	instanceFile, err := getInstanceConfigPath(config.Name)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(instanceFile); err != nil {
+		if !os.IsNotExist(err) {
+			return fmt.Errorf("instance file is already exist: %v/n", err)
+		}
+	}
+
+	config.SchemaVersion = Version
+	config.Version = "1"
+	config.NumberOfListeners = 1
+
+	err = checkDefinedPorts(config)
+	if err != nil {
+		return err
+	}
+
+	err = applyLock(&config)
+	if err != nil {
+		return err
+	}
+
+	err = checkParams(&config)
+	if err != nil {
+		return err
+	}
+
+	return saveConfig(config)
+}
+
+// Put saves config of instance received by agent, existing instance config is replaced
+func Put(config Config) error {
+	// name of received config is path of its file
+	err := checkInstanceName(config.Name)
+	if err != nil {
+		return err
+	}
+
+	config.Host = ""
+
+	_, err = getRuntime(config.Runtime)
+	if err != nil {
+		return err
+	}
+
+	err = checkVolumes(config.Volumes)
+	if err != nil {
+		return err
+	}
+
+	err = checkReadinessProbe(config.Readiness)
+	if err != nil {
+		return err
+	}
+
+	err = checkLogRotation(config.LogRotation)
+	if err != nil {
+		return err
+	}
+
+	err = checkRestartPolicy(config.Restart)
+	if err != nil {
+		return err
+	}
+
+	err = checkBusReconnect(config.BusReconnect)
+	if err != nil {
+		return err
+	}
+
+	err = checkEnv(config.Env)
+	if err != nil {
+		return err
+	}
+
+	err = checkResources(config.Resources)
+	if err != nil {
+		return err
+	}
+
+	err = createInstancesDirectoryIfNotExist()
+	if err != nil {
+		return err
+	}
+
+	err = checkDefinedPorts(config)
+	if err != nil {
+		return err
+	}
+
+	return saveConfig(config)
+}
+
+func createInstancesDirectoryIfNotExist() error {
+	instancesDirectory, err := GetInstancesDirectoryPath()
+	if err != nil {
+		return err
+	}
+
 	if _, err := os.Stat(instancesDirectory); err != nil {
 		if !os.IsNotExist(err) {
 			return err
@@ -62,38 +331,302 @@ func Add(name string, source string, class string, queueGroup string, params map
 		}
 	}
 
-	instanceFile, err := getInstanceConfigPath(name)
+	return nil
+}
+
+func IsExist(name string) (bool, error) {
+	instanceConfigPath, err := getInstanceConfigPath(name)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = os.Stat(instanceConfigPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+func saveConfig(config Config) error {
+	instanceFile, err := getInstanceConfigPath(config.Name)
 	if err != nil {
 		return err
 	}
 
-	if _, err := os.Stat(instanceFile); err != nil {
-		if !os.IsNotExist(err) {
-			return fmt.Errorf("instance file is already exist: %v/n", err)
+	packedConfig, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(instanceFile, packedConfig, 0777)
+	if err != nil {
+		return err
+	}
+
+	err = addHistoryVersion(config.Name, packedConfig)
+	if err != nil {
+		utils.Warningf("can't save config history: %v\n", err)
+	}
+
+	// bus permissions of instance follow its channels mapping, so users are rewritten on every change
+	_, err = ensureBusCredentials(config.Name)
+	if err == nil {
+		err = updateBusAuth()
+	}
+
+	if err != nil {
+		utils.Warningf("can't update bus credentials: %v\n", err)
+	}
+
+	return nil
+}
+
+func Clone(name string, newName string, params map[string]string, portsMapping *[]cube_executor.PortMap, channelsMapping map[cube_executor.CubeChannel]cube_executor.BusChannel) error {
+	err := checkInstanceName(newName)
+	if err != nil {
+		return err
+	}
+
+	config, err := GetConfig(name)
+	if err != nil {
+		return err
+	}
+
+	isExist, err := IsExist(newName)
+	if err != nil {
+		return err
+	}
+
+	if isExist {
+		return fmt.Errorf("instance '%v' already exists", newName)
+	}
+
+	clonedParams := map[string]string{}
+	for key, value := range config.Params {
+		clonedParams[key] = value
+	}
+
+	for key, value := range params {
+		clonedParams[key] = value
+	}
+
+	clonedChannelsMapping := map[cube_executor.CubeChannel]cube_executor.BusChannel{}
+	for cubeChannel, busChannel := range config.ChannelsMapping {
+		clonedChannelsMapping[cubeChannel] = busChannel
+	}
+
+	for cubeChannel, busChannel := range channelsMapping {
+		clonedChannelsMapping[cubeChannel] = busChannel
+	}
+
+	clonedPortsMapping := append([]cube_executor.PortMap{}, config.PortsMapping...)
+	if portsMapping != nil {
+		clonedPortsMapping = *portsMapping
+	}
+
+	config.Name = newName
+	config.Params = clonedParams
+	config.ChannelsMapping = clonedChannelsMapping
+	config.PortsMapping = clonedPortsMapping
+	config.Groups = append([]string{}, config.Groups...)
+
+	err = checkDefinedPorts(*config)
+	if err != nil {
+		return err
+	}
+
+	if len(params) > 0 {
+		err = checkParams(config)
+		if err != nil {
+			return err
 		}
 	}
 
-	config, _ := json.MarshalIndent(cube_executor.CubeConfig{
-		SchemaVersion:     Version,
-		Version:           "1",
-		Name:              name,
-		Source:            source,
-		Class:             class,
-		QueueGroup:        queueGroup,
-		Params:            params,
-		PortsMapping:      portsMapping,
-		ChannelsMapping:   channelsMapping,
-		NumberOfListeners: 1,
-	}, "", "  ")
+	return saveConfig(*config)
+}
+
+func Rename(name string, newName string) error {
+	err := checkInstanceName(newName)
+	if err != nil {
+		return err
+	}
+
+	config, err := GetConfig(name)
+	if err != nil {
+		return err
+	}
+
+	isExist, err := IsExist(newName)
+	if err != nil {
+		return err
+	}
+
+	if isExist {
+		return fmt.Errorf("instance '%v' already exists", newName)
+	}
+
+	runtime, err := getConfigRuntime(*config)
+	if err != nil {
+		return err
+	}
+
+	// container of instance, which exited with error, keeps old name too
+	status, err := runtime.Status(*config)
+	if err != nil && !utils.IsConnectionError(err) {
+		return fmt.Errorf("can't check instance status: %v", err)
+	}
+
+	if err == nil && status != StatusStopped {
+		return fmt.Errorf("instance '%v' is %v, stop it before renaming", name, status)
+	}
+
+	oldConfigPath, err := getInstanceConfigPath(name)
+	if err != nil {
+		return err
+	}
+
+	newConfigPath, err := getInstanceConfigPath(newName)
+	if err != nil {
+		return err
+	}
+
+	config.Name = newName
+	if config.QueueGroup == name {
+		config.QueueGroup = newName
+	}
 
-	err = ioutil.WriteFile(instanceFile, config, 0777)
+	packedConfig, err := json.MarshalIndent(config, "", "  ")
 	if err != nil {
 		return err
 	}
 
+	transaction := fileTransaction{}
+
+	dependents, err := renameInstanceFiles(&transaction, name, newName, packedConfig, oldConfigPath, newConfigPath)
+	if err != nil {
+		rollbackErr := transaction.rollback()
+		if rollbackErr != nil {
+			utils.Warningf("can't undo renaming of '%v': %v\n", name, rollbackErr)
+		}
+
+		return err
+	}
+
+	// instance is renamed already, history and bus users are kept up to date like on every config change
+	err = addHistoryVersion(newName, packedConfig)
+	if err != nil {
+		utils.Warningf("can't save config history: %v\n", err)
+	}
+
+	for _, dependent := range dependents {
+		rawConfig, err := GetConfigText(dependent)
+		if err == nil {
+			err = addHistoryVersion(dependent, []byte(rawConfig))
+		}
+
+		if err != nil {
+			utils.Warningf("can't save config history of %v: %v\n", dependent, err)
+		}
+	}
+
+	err = updateBusAuth()
+	if err != nil {
+		utils.Warningf("can't update bus credentials: %v\n", err)
+	}
+
 	return nil
 }
 
+// renameInstanceFiles writes config of instance with new name, moves its dependents, state, credentials and history
+// to new name and removes old config, changes are recorded in transaction, so they're undone on failure.
+// Names of instances, which depend on renamed instance, are returned.
+func renameInstanceFiles(transaction *fileTransaction, name string, newName string, packedConfig []byte, oldConfigPath string, newConfigPath string) ([]string, error) {
+	err := transaction.writeFile(newConfigPath, packedConfig, 0777)
+	if err != nil {
+		return nil, fmt.Errorf("can't save renamed instance config: %v", err)
+	}
+
+	dependents, err := renameDependency(transaction, name, newName)
+	if err != nil {
+		return nil, fmt.Errorf("can't update instances, which depend on '%v': %v", name, err)
+	}
+
+	err = renameState(transaction, name, newName)
+	if err != nil {
+		return nil, fmt.Errorf("can't rename instance state: %v", err)
+	}
+
+	err = renameBusCredentials(transaction, name, newName)
+	if err != nil {
+		return nil, fmt.Errorf("can't rename bus credentials: %v", err)
+	}
+
+	err = renameHistory(transaction, name, newName)
+	if err != nil {
+		return nil, fmt.Errorf("can't rename config history: %v", err)
+	}
+
+	err = transaction.removeFile(oldConfigPath)
+	if err != nil {
+		return nil, fmt.Errorf("can't remove old instance config: %v", err)
+	}
+
+	return dependents, nil
+}
+
+// renameDependency replaces renamed instance in dependsOn of other instances and returns names of changed instances
+func renameDependency(transaction *fileTransaction, name string, newName string) ([]string, error) {
+	names, err := GetNames()
+	if err != nil {
+		return nil, err
+	}
+
+	dependents := []string{}
+
+	for _, otherName := range names {
+		config, err := GetConfig(otherName)
+		if err != nil {
+			return nil, err
+		}
+
+		isChanged := false
+		for index, dependency := range config.DependsOn {
+			if dependency == name {
+				config.DependsOn[index] = newName
+				isChanged = true
+			}
+		}
+
+		if !isChanged {
+			continue
+		}
+
+		packedConfig, err := json.MarshalIndent(config, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+
+		configPath, err := getInstanceConfigPath(otherName)
+		if err != nil {
+			return nil, err
+		}
+
+		err = transaction.writeFile(configPath, packedConfig, 0777)
+		if err != nil {
+			return nil, err
+		}
+
+		dependents = append(dependents, otherName)
+	}
+
+	return dependents, nil
+}
+
 func Remove(name string) error {
 	//TODO: check instance state
 	instanceConfigPath, err := getInstanceConfigPath(name)
@@ -109,7 +642,25 @@ func Remove(name string) error {
 		return err
 	}
 
-	return os.Remove(instanceConfigPath)
+	if utils.IsDryRun() {
+		return printRemove(name)
+	}
+
+	err = os.Remove(instanceConfigPath)
+	if err != nil {
+		return err
+	}
+
+	err = removeBusCredentials(name)
+	if err == nil {
+		err = updateBusAuth()
+	}
+
+	if err != nil {
+		utils.Warningf("can't update bus credentials: %v\n", err)
+	}
+
+	return nil
 }
 
 func GetConfigText(name string) (string, error) {
@@ -122,202 +673,247 @@ func GetConfigText(name string) (string, error) {
 	return string(instanceConfig), nil
 }
 
-func GetConfig(name string) (*cube_executor.CubeConfig, error) {
+func GetConfig(name string) (*Config, error) {
 	rawConfig, err := GetConfigText(name)
 	if err != nil {
 		return nil, err
 	}
 
-	var config cube_executor.CubeConfig
-	err = json.Unmarshal(([]byte)(rawConfig), &config)
+	upgradedConfig, isUpgraded, err := upgradeConfig(name, ([]byte)(rawConfig))
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	err = json.Unmarshal(upgradedConfig, &config)
 
 	if err != nil {
 		return nil, fmt.Errorf("can't parse instance config: %v/n", err)
 	}
 
+	if isUpgraded {
+		err = saveConfig(config)
+		if err != nil {
+			return nil, fmt.Errorf("can't save upgraded instance config: %v", err)
+		}
+	}
+
 	return &config, nil
 }
 
-func splitSource(source string) (string, string, error) {
-	if strings.HasPrefix(source, "go:") {
-		return "go", strings.TrimPrefix(source, "go"), nil
-	} else if strings.HasPrefix(source, "docker:") {
-		return "go", strings.TrimPrefix(source, "docker"), nil
+func GetList() (*[]Config, error) {
+	instancesDirectoryPath, err := GetInstancesDirectoryPath()
+	if err != nil {
+		return nil, err
+	}
+
+	configsPathPattern := filepath.Join(instancesDirectoryPath, "*.json")
+	files, err := filepath.Glob(configsPathPattern)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []Config{}
+
+	for _, configPath := range files {
+		_, fileName := filepath.Split(configPath)
+		instanceName := strings.TrimSuffix(fileName, ".json")
+
+		config, err := GetConfig(instanceName)
+		if err != nil {
+			return nil, fmt.Errorf("can't read instance config %v/n", err)
+		}
+
+		result = append(result, *config)
 	}
 
-	return "", "", fmt.Errorf("wrong source format: %v\n", source)
+	return &result, nil
+}
+
+func GetListByGroup(group string) (*[]Config, error) {
+	return GetFilteredList(Filter{
+		Group: group,
+	})
 }
 
 func Start(name string) error {
+	return start(name, nil)
+}
+
+// StartWithSecrets starts instance received by agent, secretParams are params with secrets resolved by host,
+// which sent instance, they're passed to runtime only and aren't saved
+func StartWithSecrets(name string, secretParams map[string]string) error {
+	return start(name, secretParams)
+}
+
+func start(name string, secretParams map[string]string) error {
 	instanceConfig, err := GetConfig(name)
 	if err != nil {
 		return err
 	}
 
-	log.Println("Pulling cube compiler image...")
-	err = utils.PullImage(cubeCompilerImage)
-	if err != nil {
-		return fmt.Errorf("can't pull compiler image: %v/n", err)
-	}
+	// config is kept as it's read, so diff doesn't show params of profile and locked source as changes
+	startedConfig := *instanceConfig
 
-	log.Println("Compiling cube...")
-	tempDir, err := ioutil.TempDir("", "cubes_")
+	runtime, err := getConfigRuntime(*instanceConfig)
 	if err != nil {
-		return fmt.Errorf("can't create temp directory for build %v/n", err)
+		return err
 	}
 
-	defer func() { os.RemoveAll(tempDir) }()
+	applyProfileParams(instanceConfig)
 
-	sourceType, sourceData, err := splitSource(instanceConfig.Source)
+	// instance runs locked source, so it's the same code in every environment
+	err = applyLock(instanceConfig)
 	if err != nil {
 		return err
 	}
 
-	imageToRun := cubeInstanceImage
-
-	if sourceType == "go" {
-		err = compileGoCube(sourceData, tempDir)
+	// agent checks and allocates ports of remote instance on its host, secrets are resolved by remote runtime
+	if instanceConfig.Host != "" {
+		err = runtime.Start(*instanceConfig)
 		if err != nil {
-			return fmt.Errorf("can't compile cube %v/n", err)
+			return err
 		}
-	} else if sourceType == "docker" {
-		imageToRun = sourceData
+
+		utils.EmitEvent(utils.EventInstanceStarted, name, "on host "+instanceConfig.Host)
+		return nil
 	}
 
-	log.Println("Runing cube instance...")
-	err = utils.PullImage(imageToRun)
+	err = checkRunningPorts(*instanceConfig)
 	if err != nil {
-		return fmt.Errorf("can't pull cube instance image: %v/n", err)
+		return err
 	}
 
-	appPath := filepath.Join(tempDir, "cube.tar")
-	configPath, err := getInstanceConfigPath(instanceConfig.Name)
+	err = checkInstanceBusProtocol(*instanceConfig)
+	if err != nil {
+		return err
+	}
 
-	err = runCubeInstance(appPath, *instanceConfig, configPath)
+	configPath, err := getInstanceConfigPath(instanceConfig.Name)
 	if err != nil {
-		return fmt.Errorf("can't run cube instance %v/n", err)
+		return err
 	}
 
-	return nil
-}
+	// config could be edited by hand, so keep the version instance is started with
+	rawConfig, err := ioutil.ReadFile(configPath)
+	if err == nil {
+		err = addHistoryVersion(name, rawConfig)
+	}
 
-func Stop(name string) error {
-	return nil
-}
+	if err != nil {
+		utils.Warningf("can't save config history: %v\n", err)
+	}
 
-func Ping(name string) error {
-	return nil
-}
+	// instances added before bus auth get credentials on start
+	credentialsMutex.Lock()
+	isCreated, err := ensureBusCredentials(name)
+	if err == nil && isCreated {
+		err = updateBusAuth()
+	}
+	credentialsMutex.Unlock()
 
-func compileGoCube(cubePackage string, outputDir string) error {
-	ctx := context.Background()
-	client, err := docker_client.NewEnvClient()
+	if err != nil {
+		return fmt.Errorf("can't create bus credentials: %v", err)
+	}
 
+	portsMapping, err := allocatePorts(instanceConfig.PortsMapping)
 	if err != nil {
-		log.Fatalf("can't connect to docker service:\n%v", err)
 		return err
 	}
 
-	defer client.Close()
-
-	resp, err := client.ContainerCreate(ctx, &container.Config{
-		Image: cubeCompilerImage,
-		Tty:   true,
-		Env:   []string{"CUBE_PACKAGE=" + cubePackage},
-	}, &container.HostConfig{
-		AutoRemove: true,
-		Binds:      []string{outputDir + ":/build:rw"},
-	}, nil, "")
-
+	// secrets are resolved only in config, which instance is started with, it isn't written to project
+	runtimeConfig, err := resolveSecretParams(*instanceConfig, secretParams)
 	if err != nil {
-		log.Fatalf("can't create docker container:\n%v", err)
 		return err
 	}
 
-	if err := client.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
-		log.Fatalf("can't start docker container:\n%v", err)
+	runtimeConfig.PortsMapping = portsMapping
+
+	err = runtime.Start(runtimeConfig)
+	if err != nil {
 		return err
 	}
 
-	client.ContainerWait(ctx, resp.ID)
-	return nil
-}
+	utils.EmitEvent(utils.EventInstanceStarted, name, "")
 
-func runCubeInstance(appPath string, config cube_executor.CubeConfig, configPath string) error {
-	ctx := context.Background()
-	client, err := docker_client.NewEnvClient()
+	return saveState(name, State{
+		Ports:         portsMapping,
+		StartedConfig: &startedConfig,
+	})
+}
 
+// StartFiltered starts instances, which match filter, with at most parallelism of them at once after instances
+// they depend on, empty filter matches all instances
+func StartFiltered(filter Filter, parallelism int, readyTimeout time.Duration) error {
+	configs, err := GetFilteredList(filter)
 	if err != nil {
-		log.Fatalf("can't connect to docker service:\n%v", err)
 		return err
 	}
 
-	defer client.Close()
-
-	client.ContainerStop(ctx, config.Name, nil)
-	client.ContainerRemove(ctx, config.Name, types.ContainerRemoveOptions{})
-
-	exposedPorts := nat.PortSet{}
-	portMap := nat.PortMap{}
+	if len(*configs) == 0 {
+		return fmt.Errorf("no instances match filter")
+	}
 
-	for _, portData := range config.PortsMapping {
+	started, err := StartConcurrently(*configs, parallelism, readyTimeout, func(config Config) error {
+		utils.Infof("Starting instance %v...\n", config.Name)
 
-		port, err := nat.NewPort(string(portData.Protocol), strconv.FormatUint(uint64(portData.CubePort), 10))
+		err := Start(config.Name)
 		if err != nil {
-			return err
+			return fmt.Errorf("can't start instance %v: %v", config.Name, err)
 		}
 
-		exposedPorts[port] = struct{}{}
-		portMap[port] = []nat.PortBinding{
-			{
-				HostIP:   "",
-				HostPort: strconv.FormatUint(uint64(portData.HostPort), 10),
-			},
-		}
-	}
+		return nil
+	})
 
-	resp, err := client.ContainerCreate(ctx, &container.Config{
-		Image:        cubeInstanceImage,
-		Tty:          true,
-		ExposedPorts: exposedPorts,
-		Labels: map[string]string{
-			"_CUBE":             "true",
-			"_CUBE_CLASS":       config.Class,
-			"_CUBE_NAME":        config.Name,
-			"_CUBE_VERSION":     config.Version,
-			"_CUBE_QUEUE_GROUP": config.QueueGroup,
-		},
-	}, &container.HostConfig{
-		AutoRemove:   true,
-		Links:        []string{"cubes-bus:cubes-bus"},
-		Binds:        []string{configPath + ":/config.json:rw"},
-		PortBindings: portMap,
-	}, nil, config.Name)
+	return utils.PartialError(started, len(*configs), err)
+}
 
+func Stop(name string) error {
+	instanceConfig, err := GetConfig(name)
 	if err != nil {
-		log.Fatalf("can't create docker container:\n%v", err)
 		return err
 	}
 
-	file, err := os.Open(appPath)
+	runtime, err := getConfigRuntime(*instanceConfig)
 	if err != nil {
-		log.Fatalf("can't read compiled cube:\n%v", err)
 		return err
 	}
 
-	err = client.CopyToContainer(ctx, resp.ID, "/home/app", file, types.CopyToContainerOptions{
-		AllowOverwriteDirWithFile: true,
-	})
+	if utils.IsDryRun() {
+		return printStop(*instanceConfig, runtime)
+	}
 
+	err = runtime.Stop(*instanceConfig)
 	if err != nil {
-		log.Fatalf("can't copy compiled app to instance container:\n%v", err)
 		return err
 	}
 
-	if err := client.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
-		log.Fatalf("can't start  instance container:\n%v", err)
+	utils.EmitEvent(utils.EventInstanceStopped, name, "")
+	return removeState(name)
+}
+
+func StopFiltered(filter Filter) error {
+	configs, err := GetFilteredList(filter)
+	if err != nil {
 		return err
 	}
 
+	if len(*configs) == 0 {
+		return fmt.Errorf("no instances match filter")
+	}
+
+	for index, config := range *configs {
+		utils.Infof("Stopping instance %v...\n", config.Name)
+
+		err = Stop(config.Name)
+		if err != nil {
+			return utils.PartialError(index, len(*configs), fmt.Errorf("can't stop instance %v: %v", config.Name, err))
+		}
+	}
+
+	return nil
+}
+
+func Ping(name string) error {
 	return nil
 }
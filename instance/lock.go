@@ -0,0 +1,263 @@
+package instance
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/akaumov/cubes/utils"
+)
+
+// LockFileName is lock of cube sources at project root, it's committed with project, so every environment runs the same code
+const LockFileName = "cubes.lock"
+
+const lockVersion = "1"
+
+// lockMutex keeps records of instances started concurrently, they're read and saved with lock
+var lockMutex sync.Mutex
+
+// LockedSource is resolved git commit or image digest of source
+type LockedSource struct {
+	Commit string `json:"commit,omitempty"`
+	Digest string `json:"digest,omitempty"`
+}
+
+// Lock pins git and docker sources of instances, it's keyed by source, so instances with the same source run the same code
+type Lock struct {
+	Version string                  `json:"version"`
+	Sources map[string]LockedSource `json:"sources"`
+}
+
+// LockUpdate is change of locked source made by update, Previous is empty for new source
+type LockUpdate struct {
+	Source   string `json:"source"`
+	Previous string `json:"previous"`
+	Current  string `json:"current"`
+}
+
+func getLockPath() (string, error) {
+	pwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(pwd, LockFileName), nil
+}
+
+func GetLock() (*Lock, error) {
+	lockPath, err := getLockPath()
+	if err != nil {
+		return nil, err
+	}
+
+	lock := Lock{
+		Version: lockVersion,
+		Sources: map[string]LockedSource{},
+	}
+
+	rawLock, err := ioutil.ReadFile(lockPath)
+	if os.IsNotExist(err) {
+		return &lock, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(rawLock, &lock)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse %v: %v", LockFileName, err)
+	}
+
+	if lock.Sources == nil {
+		lock.Sources = map[string]LockedSource{}
+	}
+
+	return &lock, nil
+}
+
+func saveLock(lock *Lock) error {
+	lockPath, err := getLockPath()
+	if err != nil {
+		return err
+	}
+
+	lock.Version = lockVersion
+
+	rawLock, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(lockPath, append(rawLock, '\n'), 0644)
+}
+
+func isLockedSource(source string) bool {
+	sourceType, _, err := splitSource(source)
+	return err == nil && (sourceType == SourceGit || sourceType == SourceDocker)
+}
+
+func (locked LockedSource) getVersion() string {
+	if locked.Commit != "" {
+		return locked.Commit
+	}
+
+	return locked.Digest
+}
+
+// applyLock pins source of instance to its lock. Source, which isn't locked yet, is locked at commit or digest
+// recorded in instance config or resolved now, locked sources are changed only by Update.
+func applyLock(config *Config) error {
+	if !isLockedSource(config.Source) {
+		return nil
+	}
+
+	lockMutex.Lock()
+	defer lockMutex.Unlock()
+
+	lock, err := GetLock()
+	if err != nil {
+		return err
+	}
+
+	if locked, ok := lock.Sources[config.Source]; ok {
+		config.SourceCommit = locked.Commit
+		config.SourceDigest = locked.Digest
+		return nil
+	}
+
+	if config.SourceCommit == "" && config.SourceDigest == "" {
+		err = pinSource(config)
+		if err != nil {
+			return err
+		}
+	}
+
+	locked := LockedSource{
+		Commit: config.SourceCommit,
+		Digest: config.SourceDigest,
+	}
+
+	lock.Sources[config.Source] = locked
+
+	err = saveLock(lock)
+	if err != nil {
+		return fmt.Errorf("can't save %v: %v", LockFileName, err)
+	}
+
+	utils.Infof("Source %v is locked at %v\n", config.Source, locked.getVersion())
+	return nil
+}
+
+// lockSource records commit or digest of instance source in lock, existing record is replaced
+func lockSource(config Config) error {
+	lock, err := GetLock()
+	if err != nil {
+		return err
+	}
+
+	lock.Sources[config.Source] = LockedSource{
+		Commit: config.SourceCommit,
+		Digest: config.SourceDigest,
+	}
+
+	return saveLock(lock)
+}
+
+// Update resolves git and docker sources of instances again and records them in lock and instance configs.
+// All instances are updated, when names are empty, then sources, which instances don't use anymore, are removed from lock.
+func Update(names []string) ([]LockUpdate, error) {
+	configs, err := GetList()
+	if err != nil {
+		return nil, err
+	}
+
+	isSelected := map[string]bool{}
+	for _, name := range names {
+		isSelected[name] = true
+	}
+
+	selectedConfigs := []Config{}
+	usedSources := map[string]bool{}
+
+	for _, config := range *configs {
+		if !isLockedSource(config.Source) {
+			continue
+		}
+
+		usedSources[config.Source] = true
+
+		if len(names) == 0 || isSelected[config.Name] {
+			selectedConfigs = append(selectedConfigs, config)
+			delete(isSelected, config.Name)
+		}
+	}
+
+	for name := range isSelected {
+		return nil, fmt.Errorf("instance '%v' isn't found or doesn't have git or docker source", name)
+	}
+
+	lock, err := GetLock()
+	if err != nil {
+		return nil, err
+	}
+
+	updates := []LockUpdate{}
+	resolved := map[string]LockedSource{}
+
+	for _, config := range selectedConfigs {
+		locked, ok := resolved[config.Source]
+		if !ok {
+			err = pinSource(&config)
+			if err != nil {
+				return nil, fmt.Errorf("can't update source of %v: %v", config.Name, err)
+			}
+
+			locked = LockedSource{
+				Commit: config.SourceCommit,
+				Digest: config.SourceDigest,
+			}
+
+			resolved[config.Source] = locked
+
+			updates = append(updates, LockUpdate{
+				Source:   config.Source,
+				Previous: lock.Sources[config.Source].getVersion(),
+				Current:  locked.getVersion(),
+			})
+
+			lock.Sources[config.Source] = locked
+		}
+
+		config.SourceCommit = locked.Commit
+		config.SourceDigest = locked.Digest
+
+		err = saveConfig(config)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(names) == 0 {
+		for source := range lock.Sources {
+			if !usedSources[source] {
+				delete(lock.Sources, source)
+			}
+		}
+	}
+
+	err = saveLock(lock)
+	if err != nil {
+		return nil, fmt.Errorf("can't save %v: %v", LockFileName, err)
+	}
+
+	sort.Slice(updates, func(i, j int) bool {
+		return updates[i].Source < updates[j].Source
+	})
+
+	return updates, nil
+}
@@ -0,0 +1,46 @@
+package instance
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+type LogRotation struct {
+	MaxSizeMegabytes int  `json:"maxSizeMegabytes"`
+	MaxFiles         int  `json:"maxFiles"`
+	IsCompressed     bool `json:"isCompressed"`
+}
+
+func checkLogRotation(rotation *LogRotation) error {
+	if rotation == nil {
+		return nil
+	}
+
+	if rotation.MaxSizeMegabytes <= 0 {
+		return fmt.Errorf("max log size must be positive")
+	}
+
+	if rotation.MaxFiles <= 0 {
+		return fmt.Errorf("number of kept log files must be positive")
+	}
+
+	return nil
+}
+
+// getLogConfig returns docker logging config, docker rotates log file when it reaches max size and keeps max files
+func getLogConfig(rotation *LogRotation) container.LogConfig {
+	if rotation == nil {
+		return container.LogConfig{}
+	}
+
+	return container.LogConfig{
+		Type: "json-file",
+		Config: map[string]string{
+			"max-size": strconv.Itoa(rotation.MaxSizeMegabytes) + "m",
+			"max-file": strconv.Itoa(rotation.MaxFiles),
+			"compress": strconv.FormatBool(rotation.IsCompressed),
+		},
+	}
+}
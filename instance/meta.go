@@ -0,0 +1,245 @@
+package instance
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/akaumov/cubes/utils"
+)
+
+const (
+	metaFileName   = "meta.json"
+	metaImageLabel = "cube.meta"
+)
+
+const (
+	ParamString  = "string"
+	ParamNumber  = "number"
+	ParamBoolean = "boolean"
+)
+
+const (
+	ChannelIn  = "in"
+	ChannelOut = "out"
+)
+
+type ChannelMeta struct {
+	Direction string `json:"direction"`
+
+	// Schema is JSON schema of messages, which cube publishes to out channel or expects from in channel,
+	// cubes contracts check compares schemas of producers and consumers of bus channels
+	Schema json.RawMessage `json:"schema,omitempty"`
+}
+
+type ParamMeta struct {
+	Type        string      `json:"type"`
+	Default     interface{} `json:"default"`
+	Description string      `json:"description"`
+	IsRequired  bool        `json:"required"`
+}
+
+// Meta is description of cube class, declared in meta.json at the root of cube source,
+// in "cube.meta" label of docker image or in meta.json next to published cube archive
+type Meta struct {
+	Version     string                 `json:"version"`
+	Description string                 `json:"description"`
+	Channels    map[string]ChannelMeta `json:"channels"`
+	Params      map[string]ParamMeta   `json:"params"`
+
+	// BusProtocol is version of bus protocol, which cube executor of class speaks, it's 1 when it isn't declared
+	BusProtocol int `json:"busProtocol,omitempty"`
+}
+
+func getSourceDirectory(config Config) (string, error) {
+	sourceType, sourceData, err := splitSource(config.Source)
+	if err != nil {
+		return "", err
+	}
+
+	switch sourceType {
+	case SourceGo:
+		pwd, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+
+		sourcePackage, err := build.Import(sourceData, pwd, build.FindOnly)
+		if err != nil {
+			return "", nil
+		}
+
+		return sourcePackage.Dir, nil
+	case SourceGit:
+		url, _ := splitGitSource(sourceData)
+		return getGitCachePath(url)
+	case SourcePath:
+		return sourceData, nil
+	}
+
+	return "", nil
+}
+
+func parseMeta(rawMeta []byte) (*Meta, error) {
+	var meta Meta
+	err := json.Unmarshal(rawMeta, &meta)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse cube meta: %v", err)
+	}
+
+	for name, param := range meta.Params {
+		switch param.Type {
+		case "", ParamString, ParamNumber, ParamBoolean:
+			break
+		default:
+			return nil, fmt.Errorf("param '%v' has unknown type '%v'", name, param.Type)
+		}
+	}
+
+	return &meta, nil
+}
+
+// GetMeta returns meta of instance's cube class, nil if cube doesn't declare it
+func GetMeta(config Config) (*Meta, error) {
+	sourceType, sourceData, err := splitSource(config.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	if sourceType == SourceDocker {
+		labels, err := utils.GetImageLabels(getPinnedImage(config, sourceData))
+		if err != nil {
+			return nil, fmt.Errorf("can't inspect image: %v", err)
+		}
+
+		rawMeta, ok := labels[metaImageLabel]
+		if !ok {
+			return nil, nil
+		}
+
+		return parseMeta([]byte(rawMeta))
+	}
+
+	if sourceType == SourceArchive {
+		url, digest := splitArchiveSource(sourceData)
+		location := getArchiveMetaLocation(url)
+
+		if digest != "" {
+			cachePath, err := getArchiveCachePath(digest)
+			if err != nil {
+				return nil, err
+			}
+
+			cachedMetaPath := filepath.Join(cachePath, metaFileName)
+			if _, err := os.Stat(cachedMetaPath); err == nil {
+				location = cachedMetaPath
+			}
+		}
+
+		// meta.json is optional, like in cube source
+		rawMeta, err := readLocation(location)
+		if err != nil {
+			return nil, nil
+		}
+
+		return parseMeta(rawMeta)
+	}
+
+	sourceDirectory, err := getSourceDirectory(config)
+	if err != nil || sourceDirectory == "" {
+		return nil, err
+	}
+
+	rawMeta, err := ioutil.ReadFile(filepath.Join(sourceDirectory, metaFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return parseMeta(rawMeta)
+}
+
+func checkParamValue(param ParamMeta, value string) error {
+	switch param.Type {
+	case ParamNumber:
+		_, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("number is expected")
+		}
+		break
+	case ParamBoolean:
+		_, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("boolean is expected")
+		}
+		break
+	}
+
+	return nil
+}
+
+// applyParamsSchema checks params against cube meta and fills missing params with defaults
+func applyParamsSchema(meta *Meta, params map[string]string) (map[string]string, error) {
+	if meta == nil || meta.Params == nil {
+		return params, nil
+	}
+
+	result := map[string]string{}
+
+	names := []string{}
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		param, ok := meta.Params[name]
+		if !ok {
+			return nil, fmt.Errorf("cube doesn't declare param '%v'", name)
+		}
+
+		err := checkParamValue(param, params[name])
+		if err != nil {
+			return nil, fmt.Errorf("wrong value of param '%v': %v", name, err)
+		}
+
+		result[name] = params[name]
+	}
+
+	for name, param := range meta.Params {
+		if _, ok := result[name]; ok {
+			continue
+		}
+
+		if param.Default != nil {
+			result[name] = fmt.Sprint(param.Default)
+		} else if param.IsRequired {
+			return nil, fmt.Errorf("param '%v' is required", name)
+		}
+	}
+
+	return result, nil
+}
+
+func checkParams(config *Config) error {
+	meta, err := GetMeta(*config)
+	if err != nil {
+		return fmt.Errorf("can't read cube meta: %v", err)
+	}
+
+	params, err := applyParamsSchema(meta, config.Params)
+	if err != nil {
+		return err
+	}
+
+	config.Params = params
+	return nil
+}
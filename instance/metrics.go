@@ -0,0 +1,134 @@
+package instance
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/akaumov/cubes/utils"
+)
+
+type Metrics struct {
+	Name          string  `json:"name"`
+	Status        string  `json:"status"`
+	UptimeSeconds float64 `json:"uptimeSeconds"`
+	Restarts      int     `json:"restarts"`
+	CpuSeconds    float64 `json:"cpuSeconds"`
+	MemoryBytes   uint64  `json:"memoryBytes"`
+	OpenFiles     int     `json:"openFiles"`
+	InMessages    uint64  `json:"inMessages"`
+	OutMessages   uint64  `json:"outMessages"`
+}
+
+func GetMetrics(name string) (*Metrics, error) {
+	instanceConfig, err := GetConfig(name)
+	if err != nil {
+		return nil, err
+	}
+
+	runtime, err := getConfigRuntime(*instanceConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return runtime.Metrics(*instanceConfig)
+}
+
+func GetListMetrics() (*[]Metrics, error) {
+	configs, err := GetList()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []Metrics{}
+
+	for _, config := range *configs {
+		metrics, err := GetMetrics(config.Name)
+		if err != nil {
+			metrics = &Metrics{
+				Name:   config.Name,
+				Status: StatusUnknown,
+			}
+		}
+
+		result = append(result, *metrics)
+	}
+
+	return &result, nil
+}
+
+func boolToMetric(value bool) int {
+	if value {
+		return 1
+	}
+
+	return 0
+}
+
+// WriteMetrics writes metrics of all instances in prometheus text format
+func WriteMetrics(output io.Writer) error {
+	listMetrics, err := GetListMetrics()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(output, "# HELP cubes_instance_up Whether the instance is running.")
+	fmt.Fprintln(output, "# TYPE cubes_instance_up gauge")
+	for _, metrics := range *listMetrics {
+		fmt.Fprintf(output, "cubes_instance_up{instance=%q} %v\n", metrics.Name, boolToMetric(metrics.Status == StatusRunning))
+	}
+
+	fmt.Fprintln(output, "# HELP cubes_instance_uptime_seconds Seconds since the instance was started.")
+	fmt.Fprintln(output, "# TYPE cubes_instance_uptime_seconds gauge")
+	for _, metrics := range *listMetrics {
+		fmt.Fprintf(output, "cubes_instance_uptime_seconds{instance=%q} %v\n", metrics.Name, metrics.UptimeSeconds)
+	}
+
+	fmt.Fprintln(output, "# HELP cubes_instance_restarts_total Number of instance restarts.")
+	fmt.Fprintln(output, "# TYPE cubes_instance_restarts_total counter")
+	for _, metrics := range *listMetrics {
+		fmt.Fprintf(output, "cubes_instance_restarts_total{instance=%q} %v\n", metrics.Name, metrics.Restarts)
+	}
+
+	fmt.Fprintln(output, "# HELP cubes_instance_cpu_seconds_total Total CPU time consumed by the instance.")
+	fmt.Fprintln(output, "# TYPE cubes_instance_cpu_seconds_total counter")
+	for _, metrics := range *listMetrics {
+		fmt.Fprintf(output, "cubes_instance_cpu_seconds_total{instance=%q} %v\n", metrics.Name, metrics.CpuSeconds)
+	}
+
+	fmt.Fprintln(output, "# HELP cubes_instance_memory_bytes Memory used by the instance.")
+	fmt.Fprintln(output, "# TYPE cubes_instance_memory_bytes gauge")
+	for _, metrics := range *listMetrics {
+		fmt.Fprintf(output, "cubes_instance_memory_bytes{instance=%q} %v\n", metrics.Name, metrics.MemoryBytes)
+	}
+
+	fmt.Fprintln(output, "# HELP cubes_instance_open_files Number of files opened by the instance process.")
+	fmt.Fprintln(output, "# TYPE cubes_instance_open_files gauge")
+	for _, metrics := range *listMetrics {
+		fmt.Fprintf(output, "cubes_instance_open_files{instance=%q} %v\n", metrics.Name, metrics.OpenFiles)
+	}
+
+	fmt.Fprintln(output, "# HELP cubes_instance_bus_messages_total Number of bus messages received and sent by the instance.")
+	fmt.Fprintln(output, "# TYPE cubes_instance_bus_messages_total counter")
+	for _, metrics := range *listMetrics {
+		fmt.Fprintf(output, "cubes_instance_bus_messages_total{instance=%q,direction=\"in\"} %v\n", metrics.Name, metrics.InMessages)
+		fmt.Fprintf(output, "cubes_instance_bus_messages_total{instance=%q,direction=\"out\"} %v\n", metrics.Name, metrics.OutMessages)
+	}
+
+	return nil
+}
+
+func ServeMetrics(address string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+		err := WriteMetrics(w)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+
+	utils.Infof("Serving instances metrics on %v/metrics\n", address)
+	return http.ListenAndServe(address, mux)
+}
@@ -0,0 +1,195 @@
+package instance
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/akaumov/cube_executor"
+)
+
+// AutoHostPort in ports mapping means that free host port is picked on instance start
+const AutoHostPort = cube_executor.HostPort(0)
+
+// allocatedPorts are picked for instances started by this process, port is free until container binds it,
+// so instances started concurrently don't get the same port
+var allocatedPorts = struct {
+	sync.Mutex
+	ports map[string]bool
+}{
+	ports: map[string]bool{},
+}
+
+// findPortsConflict checks that host ports of instance aren't used by other instances
+func findPortsConflict(config Config, configs []Config) error {
+	for _, otherConfig := range configs {
+		if otherConfig.Name == config.Name || otherConfig.Host != config.Host {
+			continue
+		}
+
+		for _, portMap := range config.PortsMapping {
+			if portMap.HostPort == AutoHostPort {
+				continue
+			}
+
+			for _, otherPortMap := range otherConfig.PortsMapping {
+				if portMap.HostPort == otherPortMap.HostPort && portMap.Protocol == otherPortMap.Protocol {
+					return fmt.Errorf("host port %v/%v is already used by instance '%v'", portMap.HostPort, portMap.Protocol, otherConfig.Name)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkPortsAvailable checks that host ports of instance aren't bound by other processes
+func checkPortsAvailable(config Config) error {
+	for _, portMap := range config.PortsMapping {
+		if portMap.HostPort == AutoHostPort {
+			continue
+		}
+
+		address := ":" + strconv.FormatUint(uint64(portMap.HostPort), 10)
+
+		switch portMap.Protocol {
+		case "udp":
+			connection, err := net.ListenPacket("udp", address)
+			if err != nil {
+				return fmt.Errorf("host port %v/udp is already bound: %v", portMap.HostPort, err)
+			}
+
+			connection.Close()
+			break
+		default:
+			listener, err := net.Listen("tcp", address)
+			if err != nil {
+				return fmt.Errorf("host port %v/tcp is already bound: %v", portMap.HostPort, err)
+			}
+
+			listener.Close()
+			break
+		}
+	}
+
+	return nil
+}
+
+func checkDefinedPorts(config Config) error {
+	configs, err := GetList()
+	if err != nil {
+		return err
+	}
+
+	return findPortsConflict(config, *configs)
+}
+
+func checkRunningPorts(config Config) error {
+	configs, err := GetList()
+	if err != nil {
+		return err
+	}
+
+	runningConfigs := []Config{}
+	for _, otherConfig := range *configs {
+		if otherConfig.Host != config.Host {
+			continue
+		}
+
+		status, _ := GetStatus(otherConfig.Name)
+		if !IsActiveStatus(status) {
+			continue
+		}
+
+		state, _ := GetState(otherConfig.Name)
+		if state != nil {
+			otherConfig.PortsMapping = state.Ports
+		}
+
+		runningConfigs = append(runningConfigs, otherConfig)
+	}
+
+	err = findPortsConflict(config, runningConfigs)
+	if err != nil {
+		return err
+	}
+
+	status, _ := GetStatus(config.Name)
+	if IsActiveStatus(status) {
+		return nil
+	}
+
+	return checkPortsAvailable(config)
+}
+
+func getFreePort(protocol cube_executor.Protocol) (cube_executor.HostPort, error) {
+	if protocol == "udp" {
+		connection, err := net.ListenPacket("udp", ":0")
+		if err != nil {
+			return 0, err
+		}
+
+		defer connection.Close()
+		return cube_executor.HostPort(connection.LocalAddr().(*net.UDPAddr).Port), nil
+	}
+
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return 0, err
+	}
+
+	defer listener.Close()
+	return cube_executor.HostPort(listener.Addr().(*net.TCPAddr).Port), nil
+}
+
+// allocateFreePort returns free port, which isn't allocated for other instance by this process yet
+func allocateFreePort(protocol cube_executor.Protocol) (cube_executor.HostPort, error) {
+	allocatedPorts.Lock()
+	defer allocatedPorts.Unlock()
+
+	for {
+		hostPort, err := getFreePort(protocol)
+		if err != nil {
+			return 0, err
+		}
+
+		key := fmt.Sprintf("%v/%v", hostPort, protocol)
+		if !allocatedPorts.ports[key] {
+			allocatedPorts.ports[key] = true
+			return hostPort, nil
+		}
+	}
+}
+
+// allocatePorts returns ports mapping where automatic host ports are replaced with free ports
+func allocatePorts(portsMapping []cube_executor.PortMap) ([]cube_executor.PortMap, error) {
+	result := []cube_executor.PortMap{}
+
+	for _, portMap := range portsMapping {
+		if portMap.HostPort == AutoHostPort {
+			hostPort, err := allocateFreePort(portMap.Protocol)
+			if err != nil {
+				return nil, fmt.Errorf("can't allocate host port for %v/%v: %v", portMap.CubePort, portMap.Protocol, err)
+			}
+
+			portMap.HostPort = hostPort
+		}
+
+		result = append(result, portMap)
+	}
+
+	return result, nil
+}
+
+// getPortsEnv returns environment variables which tell cube its host ports: CUBE_HOST_PORT_8080_TCP=49152
+func getPortsEnv(portsMapping []cube_executor.PortMap) []string {
+	env := []string{}
+
+	for _, portMap := range portsMapping {
+		env = append(env, fmt.Sprintf("CUBE_HOST_PORT_%v_%v=%v", portMap.CubePort, strings.ToUpper(string(portMap.Protocol)), portMap.HostPort))
+	}
+
+	return env
+}
@@ -0,0 +1,26 @@
+package instance
+
+import (
+	"github.com/akaumov/cubes/utils"
+)
+
+// applyProfileParams replaces params of instance with params of selected profile
+func applyProfileParams(config *Config) {
+	profileParams := utils.GetProfileInstanceParams(config.Name)
+	if len(profileParams) == 0 {
+		return
+	}
+
+	params := map[string]string{}
+	for key, value := range config.Params {
+		params[key] = value
+	}
+
+	for key, value := range profileParams {
+		params[key] = value
+	}
+
+	config.Params = params
+
+	utils.Infof("Instance %v is started with params of profile %v\n", config.Name, utils.GetProfile())
+}
@@ -0,0 +1,80 @@
+package instance
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/akaumov/cubes/utils"
+)
+
+// getCubeBusProtocol returns bus protocol of cube class, cubes without meta or declared protocol are legacy ones
+func getCubeBusProtocol(meta *Meta) int {
+	if meta == nil || meta.BusProtocol == 0 {
+		return 1
+	}
+
+	return meta.BusProtocol
+}
+
+// CheckBusProtocol returns error when cube and bus can't understand each other
+func CheckBusProtocol(name string, cubeProtocol int, busProtocol int) error {
+	if cubeProtocol < utils.MinBusProtocolVersion {
+		return fmt.Errorf("cube %v speaks bus protocol %v, which isn't supported anymore, oldest supported protocol is %v: rebuild cube with newer cubes",
+			name, cubeProtocol, utils.MinBusProtocolVersion)
+	}
+
+	if cubeProtocol > utils.BusProtocolVersion {
+		return fmt.Errorf("cube %v speaks bus protocol %v, which is newer than protocol %v of cubes: upgrade cubes",
+			name, cubeProtocol, utils.BusProtocolVersion)
+	}
+
+	if cubeProtocol > busProtocol {
+		return fmt.Errorf("cube %v speaks bus protocol %v, but running bus speaks protocol %v: restart bus with 'cubes bus stop' and 'cubes bus start'",
+			name, cubeProtocol, busProtocol)
+	}
+
+	return nil
+}
+
+// checkInstanceBusProtocol checks protocol declared in cube meta against protocol of running bus before instance
+// is started, cube executor exchanges protocol with bus again, when it connects
+func checkInstanceBusProtocol(config Config) error {
+	meta, err := GetMeta(config)
+	if err != nil {
+		utils.Warningf("Can't read meta of %v, its bus protocol isn't checked: %v\n", config.Name, err)
+		return nil
+	}
+
+	return CheckBusProtocol(config.Name, getCubeBusProtocol(meta), utils.GetBusProtocol())
+}
+
+// getBusProtocolEnv passes protocol of running bus to instance, cube executor checks it, when bus started by older
+// cubes doesn't answer its protocol exchange
+func getBusProtocolEnv() []string {
+	return []string{"CUBE_BUS_PROTOCOL=" + strconv.Itoa(utils.GetBusProtocol())}
+}
+
+// CheckRunningBusProtocol logs running instances, which can't work with bus started by this cubes
+func CheckRunningBusProtocol() {
+	configs, err := GetList()
+	if err != nil {
+		return
+	}
+
+	for _, config := range *configs {
+		status, err := GetStatus(config.Name)
+		if err != nil || !IsActiveStatus(status) {
+			continue
+		}
+
+		meta, err := GetMeta(config)
+		if err != nil {
+			continue
+		}
+
+		err = CheckBusProtocol(config.Name, getCubeBusProtocol(meta), utils.BusProtocolVersion)
+		if err != nil {
+			utils.Warningf("Instance %v won't work with bus: %v\n", config.Name, err)
+		}
+	}
+}
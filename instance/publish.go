@@ -0,0 +1,157 @@
+package instance
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/akaumov/cubes/utils"
+	"github.com/docker/docker/api/types"
+	docker_client "github.com/docker/docker/client"
+	"golang.org/x/net/context"
+)
+
+// Package is built cube with its meta, which is published to registry
+type Package struct {
+	Name        string
+	Version     string
+	Meta        Meta
+	ArchivePath string
+}
+
+// GetDigest returns sha256 of packed cube, archive sources are checked against it
+func (p Package) GetDigest() (string, error) {
+	archive, err := ioutil.ReadFile(p.ArchivePath)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(archive)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// Pack builds cube from source directory for publishing, name is name of directory and version is version of meta,
+// when they're empty
+func Pack(sourcePath string, name string, version string) (*Package, error) {
+	absoluteSourcePath, err := filepath.Abs(sourcePath)
+	if err != nil {
+		return nil, err
+	}
+
+	rawMeta, err := ioutil.ReadFile(filepath.Join(absoluteSourcePath, metaFileName))
+	if err != nil {
+		return nil, fmt.Errorf("can't read %v, channels and params of published cube are declared in it: %v", metaFileName, err)
+	}
+
+	meta, err := parseMeta(rawMeta)
+	if err != nil {
+		return nil, err
+	}
+
+	if name == "" {
+		name = filepath.Base(absoluteSourcePath)
+	}
+
+	if version == "" {
+		version = meta.Version
+	}
+
+	if version == "" {
+		return nil, fmt.Errorf("version is required, pass --version or declare it in %v", metaFileName)
+	}
+
+	meta.Version = version
+
+	buildId, err := Build(absoluteSourcePath)
+	if err != nil {
+		return nil, err
+	}
+
+	archivePath, err := getBuildArchivePath(buildId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Package{
+		Name:        name,
+		Version:     version,
+		Meta:        *meta,
+		ArchivePath: archivePath,
+	}, nil
+}
+
+// BuildImage builds image of cube instance with packed cube and its meta in "cube.meta" label,
+// so it's started and inspected as docker source
+func BuildImage(p Package, image string) error {
+	archive, err := ioutil.ReadFile(p.ArchivePath)
+	if err != nil {
+		return err
+	}
+
+	rawMeta, err := json.Marshal(p.Meta)
+	if err != nil {
+		return err
+	}
+
+	dockerfile := []byte("FROM " + cubeInstanceImage + "\nADD " + cubeArchiveName + " /home/app/\n")
+
+	var buildContext bytes.Buffer
+	tarWriter := tar.NewWriter(&buildContext)
+
+	for _, file := range []struct {
+		name string
+		data []byte
+	}{{"Dockerfile", dockerfile}, {cubeArchiveName, archive}} {
+		err = tarWriter.WriteHeader(&tar.Header{
+			Name: file.name,
+			Mode: 0644,
+			Size: int64(len(file.data)),
+		})
+
+		if err != nil {
+			return err
+		}
+
+		_, err = tarWriter.Write(file.data)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tarWriter.Close()
+	if err != nil {
+		return err
+	}
+
+	client, err := docker_client.NewEnvClient()
+	if err != nil {
+		return fmt.Errorf("can't connect to docker service: %v", err)
+	}
+
+	defer client.Close()
+
+	utils.Infof("Building image %v...\n", image)
+
+	response, err := client.ImageBuild(context.Background(), &buildContext, types.ImageBuildOptions{
+		Tags:        []string{image},
+		Remove:      true,
+		ForceRemove: true,
+		PullParent:  true,
+		Labels: map[string]string{
+			metaImageLabel: string(rawMeta),
+		},
+	})
+
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	return utils.ReadDockerStream(response.Body)
+}
@@ -0,0 +1,86 @@
+package instance
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/akaumov/cubes/executor"
+	"github.com/docker/docker/api/types/container"
+)
+
+// ReadinessProbe is command, which passes when cube is ready to get messages, instance is starting until it passes
+// and unhealthy after Retries failed probes
+type ReadinessProbe struct {
+	Command         string `json:"command"`
+	IntervalSeconds int    `json:"intervalSeconds"`
+	TimeoutSeconds  int    `json:"timeoutSeconds"`
+	Retries         int    `json:"retries"`
+}
+
+func checkReadinessProbe(probe *ReadinessProbe) error {
+	if probe == nil {
+		return nil
+	}
+
+	if strings.TrimSpace(probe.Command) == "" {
+		return fmt.Errorf("readiness command is required")
+	}
+
+	if probe.IntervalSeconds <= 0 || probe.TimeoutSeconds <= 0 || probe.Retries <= 0 {
+		return fmt.Errorf("readiness interval, timeout and retries must be positive")
+	}
+
+	return nil
+}
+
+// getReadinessHealthcheck returns healthcheck of instance container. Executor runs probe itself and subscribes
+// channels of cube after it passes, so container of cube run by executor checks ready marker of executor. Container
+// of docker image runs probe as healthcheck, its channels aren't gated.
+func getReadinessHealthcheck(probe *ReadinessProbe, isExecuted bool) *container.HealthConfig {
+	if probe == nil {
+		return nil
+	}
+
+	test := []string{"CMD-SHELL", probe.Command}
+	if isExecuted {
+		test = []string{"CMD", "test", "-f", executor.ReadyMarkerPath}
+	}
+
+	return &container.HealthConfig{
+		Test:     test,
+		Interval: time.Duration(probe.IntervalSeconds) * time.Second,
+		Timeout:  time.Duration(probe.TimeoutSeconds) * time.Second,
+		Retries:  probe.Retries,
+	}
+}
+
+// WaitForReady waits until instance is running, instance with readiness probe or image with healthcheck is waited
+// until the check passes
+func WaitForReady(name string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		status, err := GetStatus(name)
+		if err != nil {
+			return err
+		}
+
+		switch status {
+		case StatusRunning:
+			return nil
+		case StatusUnhealthy:
+			return fmt.Errorf("instance '%v' isn't ready, its readiness probe or healthcheck failed", name)
+		case StatusStarting:
+			break
+		default:
+			return fmt.Errorf("instance '%v' is %v", name, status)
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("instance '%v' isn't ready after %v", name, timeout)
+		}
+
+		time.Sleep(time.Second)
+	}
+}
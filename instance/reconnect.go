@@ -0,0 +1,49 @@
+package instance
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// BusReconnect is backoff of cube executor reconnecting to bus: wait between attempts doubles from Wait up to MaxWait
+type BusReconnect struct {
+	WaitMilliseconds    int `json:"waitMilliseconds"`
+	MaxWaitMilliseconds int `json:"maxWaitMilliseconds"`
+
+	// MaxAttempts is number of reconnects before executor gives up and instance exits, executor retries forever when it's 0
+	MaxAttempts int `json:"maxAttempts"`
+}
+
+func checkBusReconnect(reconnect *BusReconnect) error {
+	if reconnect == nil {
+		return nil
+	}
+
+	if reconnect.WaitMilliseconds <= 0 {
+		return fmt.Errorf("bus reconnect wait must be positive")
+	}
+
+	if reconnect.MaxWaitMilliseconds < reconnect.WaitMilliseconds {
+		return fmt.Errorf("max bus reconnect wait can't be less than reconnect wait")
+	}
+
+	if reconnect.MaxAttempts < 0 {
+		return fmt.Errorf("bus reconnect attempts can't be negative")
+	}
+
+	return nil
+}
+
+// getBusReconnectEnv passes reconnect backoff to cube executor, executor reconnects forever with wait doubling from 2
+// up to 30 seconds when it isn't set
+func getBusReconnectEnv(reconnect *BusReconnect) []string {
+	if reconnect == nil {
+		return []string{}
+	}
+
+	return []string{
+		"CUBE_BUS_RECONNECT_WAIT_MS=" + strconv.Itoa(reconnect.WaitMilliseconds),
+		"CUBE_BUS_RECONNECT_MAX_WAIT_MS=" + strconv.Itoa(reconnect.MaxWaitMilliseconds),
+		"CUBE_BUS_RECONNECT_MAX_ATTEMPTS=" + strconv.Itoa(reconnect.MaxAttempts),
+	}
+}
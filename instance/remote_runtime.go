@@ -0,0 +1,163 @@
+package instance
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/akaumov/cubes/utils"
+)
+
+// AgentStatus is response of agent status endpoint
+type AgentStatus struct {
+	Status string `json:"status"`
+}
+
+// remoteRuntime runs instances on other hosts through cubes agent
+type remoteRuntime struct{}
+
+func (r *remoteRuntime) request(config Config, method string, action string, body interface{}) (*http.Response, error) {
+	tlsConfig, err := utils.GetClientTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	client := http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: tlsConfig,
+		},
+	}
+
+	var bodyReader io.Reader
+	if body != nil {
+		packedBody, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+
+		bodyReader = bytes.NewReader(packedBody)
+	}
+
+	requestUrl := "https://" + config.Host + "/v1/instances/" + url.PathEscape(config.Name) + "/" + action
+
+	request, err := http.NewRequest(method, requestUrl, bodyReader)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("can't connect to agent %v: %v", config.Host, err)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		defer response.Body.Close()
+
+		message, _ := ioutil.ReadAll(response.Body)
+		return nil, fmt.Errorf("agent %v: %v", config.Host, strings.TrimSpace(string(message)))
+	}
+
+	return response, nil
+}
+
+func (r *remoteRuntime) call(config Config, action string, body interface{}, result interface{}) error {
+	response, err := r.request(config, http.MethodPost, action, body)
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if result == nil {
+		return nil
+	}
+
+	return json.NewDecoder(response.Body).Decode(result)
+}
+
+// StartRequest is body of agent start request. Config keeps references to secrets, so agent doesn't save
+// resolved secrets, they're sent separately and passed only to runtime of agent.
+type StartRequest struct {
+	Config       Config            `json:"config"`
+	SecretParams map[string]string `json:"secretParams,omitempty"`
+}
+
+func (r *remoteRuntime) Start(instanceConfig Config) error {
+	secretParams, err := getSecretParams(instanceConfig)
+	if err != nil {
+		return err
+	}
+
+	return r.call(instanceConfig, "start", StartRequest{
+		Config:       instanceConfig,
+		SecretParams: secretParams,
+	}, nil)
+}
+
+func (r *remoteRuntime) Stop(instanceConfig Config) error {
+	return r.call(instanceConfig, "stop", nil, nil)
+}
+
+func (r *remoteRuntime) Pause(instanceConfig Config) error {
+	return r.call(instanceConfig, "pause", nil, nil)
+}
+
+func (r *remoteRuntime) Resume(instanceConfig Config) error {
+	return r.call(instanceConfig, "resume", nil, nil)
+}
+
+func (r *remoteRuntime) Status(instanceConfig Config) (string, error) {
+	var status AgentStatus
+
+	err := r.call(instanceConfig, "status", nil, &status)
+	if err != nil {
+		return StatusUnknown, err
+	}
+
+	return status.Status, nil
+}
+
+func (r *remoteRuntime) Logs(instanceConfig Config, isFollow bool, output io.Writer) error {
+	action := "logs"
+	if isFollow {
+		action += "?follow=true"
+	}
+
+	response, err := r.request(instanceConfig, http.MethodGet, action, nil)
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	_, err = io.Copy(output, response.Body)
+	return err
+}
+
+func (r *remoteRuntime) Metrics(instanceConfig Config) (*Metrics, error) {
+	var metrics Metrics
+
+	err := r.call(instanceConfig, "metrics", nil, &metrics)
+	if err != nil {
+		return nil, err
+	}
+
+	return &metrics, nil
+}
+
+func (r *remoteRuntime) Attach(instanceConfig Config, input io.Reader, output io.Writer, detachKeys string) error {
+	return fmt.Errorf("attaching to instance on remote host isn't supported")
+}
+
+func (r *remoteRuntime) Exec(instanceConfig Config, command []string, input io.Reader, output io.Writer) (int, error) {
+	return 0, fmt.Errorf("running commands in instance on remote host isn't supported")
+}
+
+func (r *remoteRuntime) Reload(instanceConfig Config) error {
+	return fmt.Errorf("reloading instance on remote host isn't supported, restart it")
+}
@@ -0,0 +1,36 @@
+package instance
+
+import (
+	"fmt"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// Resources limit memory and cpus of instance, zero isn't limited
+type Resources struct {
+	MemoryMegabytes int     `json:"memoryMegabytes,omitempty"`
+	Cpus            float64 `json:"cpus,omitempty"`
+}
+
+func checkResources(resources *Resources) error {
+	if resources == nil {
+		return nil
+	}
+
+	if resources.MemoryMegabytes < 0 || resources.Cpus < 0 {
+		return fmt.Errorf("memory and cpus limits can't be negative")
+	}
+
+	return nil
+}
+
+func getDockerResources(resources *Resources) container.Resources {
+	if resources == nil {
+		return container.Resources{}
+	}
+
+	return container.Resources{
+		Memory:   int64(resources.MemoryMegabytes) * 1024 * 1024,
+		NanoCPUs: int64(resources.Cpus * 1e9),
+	}
+}
@@ -0,0 +1,61 @@
+package instance
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+)
+
+const (
+	crashLoopRestarts = 3
+	crashLoopWindow   = 30 * time.Second
+)
+
+// RestartPolicy restarts failed instance, delay between restarts grows exponentially
+type RestartPolicy struct {
+	MaxRetries int `json:"maxRetries"`
+}
+
+func checkRestartPolicy(policy *RestartPolicy) error {
+	if policy == nil {
+		return nil
+	}
+
+	if policy.MaxRetries <= 0 {
+		return fmt.Errorf("restart retries must be positive")
+	}
+
+	return nil
+}
+
+// getDockerRestartPolicy returns "on-failure" policy, docker doubles delay before each restart of crashed container
+func getDockerRestartPolicy(policy *RestartPolicy) container.RestartPolicy {
+	if policy == nil {
+		return container.RestartPolicy{}
+	}
+
+	return container.RestartPolicy{
+		Name:              "on-failure",
+		MaximumRetryCount: policy.MaxRetries,
+	}
+}
+
+// isCrashLooping returns true if container waits for restart or was restarted several times and didn't live long since
+func isCrashLooping(containerInfo *types.ContainerJSON) bool {
+	if containerInfo.State.Restarting {
+		return true
+	}
+
+	if containerInfo.RestartCount < crashLoopRestarts {
+		return false
+	}
+
+	startedAt, err := time.Parse(time.RFC3339Nano, containerInfo.State.StartedAt)
+	if err != nil {
+		return false
+	}
+
+	return time.Since(startedAt) < crashLoopWindow
+}
@@ -0,0 +1,62 @@
+package instance
+
+import (
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/akaumov/cubes/utils"
+)
+
+// Run adds temporary instance, streams its logs until it exits or Ctrl+C is pressed and removes it
+func Run(config Config, output io.Writer) error {
+	err := Add(config)
+	if err != nil {
+		return err
+	}
+
+	defer RemoveTemporary(config.Name)
+
+	err = Start(config.Name)
+	if err != nil {
+		return err
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	logsEnd := make(chan error, 1)
+	go func() {
+		logsEnd <- Logs(config.Name, true, output)
+	}()
+
+	select {
+	case <-interrupt:
+		utils.Infof("Stopping instance...")
+		return nil
+	case err = <-logsEnd:
+		return err
+	}
+}
+
+// RemoveTemporary stops and removes instance with its config history, it is used for instances which live during one command
+func RemoveTemporary(name string) {
+	status, _ := GetStatus(name)
+	if IsActiveStatus(status) {
+		err := Stop(name)
+		if err != nil {
+			utils.Warningf("Can't stop instance: %v\n", err)
+		}
+	}
+
+	err := Remove(name)
+	if err != nil {
+		utils.Warningf("Can't remove instance: %v\n", err)
+	}
+
+	err = removeHistory(name)
+	if err != nil {
+		utils.Warningf("Can't remove instance config history: %v\n", err)
+	}
+}
@@ -0,0 +1,204 @@
+package instance
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/akaumov/cubes/utils"
+)
+
+const (
+	StatusRunning   = "running"
+	StatusStarting  = "starting"
+	StatusUnhealthy = "unhealthy"
+	StatusPaused    = "paused"
+	StatusStopped   = "stopped"
+	StatusUnknown   = "unknown"
+
+	// StatusCrashLooping is status of instance which fails right after start and is restarted with growing delay
+	StatusCrashLooping = "crash-looping"
+
+	// StatusFailed is status of instance which exited with error and won't be restarted anymore
+	StatusFailed = "failed"
+)
+
+const defaultRuntime = "docker"
+
+// Runtime runs cube instances, each backend decides how instance process is created and supervised
+type Runtime interface {
+	// Start runs instance with config, which has params of profile and resolved secrets, runtime doesn't keep it in project
+	Start(config Config) error
+	Stop(config Config) error
+	Pause(config Config) error
+	Resume(config Config) error
+	Status(config Config) (string, error)
+	Logs(config Config, isFollow bool, output io.Writer) error
+	Metrics(config Config) (*Metrics, error)
+	Attach(config Config, input io.Reader, output io.Writer, detachKeys string) error
+	Exec(config Config, command []string, input io.Reader, output io.Writer) (int, error)
+
+	// Reload passes changed channels mapping to running instance, its executor resubscribes channels of cube
+	Reload(config Config) error
+}
+
+var runtimes = map[string]Runtime{
+	"docker": &dockerRuntime{},
+}
+
+// IsActiveStatus returns true when instance process exists, whether it's ready or not
+func IsActiveStatus(status string) bool {
+	return status == StatusRunning || status == StatusStarting || status == StatusUnhealthy || status == StatusPaused ||
+		status == StatusCrashLooping
+}
+
+func getRuntime(name string) (Runtime, error) {
+	if name == "" {
+		name = utils.GetDefaultRuntime(defaultRuntime)
+	}
+
+	runtime, ok := runtimes[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown runtime: %v", name)
+	}
+
+	return runtime, nil
+}
+
+// getConfigRuntime returns runtime of instance, instances with host are run by remote agent
+func getConfigRuntime(config Config) (Runtime, error) {
+	if config.Host != "" {
+		return &remoteRuntime{}, nil
+	}
+
+	return getRuntime(config.Runtime)
+}
+
+func GetStatus(name string) (string, error) {
+	instanceConfig, err := GetConfig(name)
+	if err != nil {
+		return "", err
+	}
+
+	runtime, err := getConfigRuntime(*instanceConfig)
+	if err != nil {
+		return "", err
+	}
+
+	return runtime.Status(*instanceConfig)
+}
+
+// Pause freezes instance process, it keeps in-memory state but doesn't handle bus messages until it's resumed
+func Pause(name string) error {
+	instanceConfig, err := GetConfig(name)
+	if err != nil {
+		return err
+	}
+
+	runtime, err := getConfigRuntime(*instanceConfig)
+	if err != nil {
+		return err
+	}
+
+	status, err := runtime.Status(*instanceConfig)
+	if err != nil {
+		return err
+	}
+
+	if status == StatusPaused {
+		return fmt.Errorf("instance '%v' is already paused", name)
+	}
+
+	if !IsActiveStatus(status) {
+		return fmt.Errorf("instance '%v' is not running", name)
+	}
+
+	return runtime.Pause(*instanceConfig)
+}
+
+func Resume(name string) error {
+	instanceConfig, err := GetConfig(name)
+	if err != nil {
+		return err
+	}
+
+	runtime, err := getConfigRuntime(*instanceConfig)
+	if err != nil {
+		return err
+	}
+
+	status, err := runtime.Status(*instanceConfig)
+	if err != nil {
+		return err
+	}
+
+	if status != StatusPaused {
+		return fmt.Errorf("instance '%v' is not paused", name)
+	}
+
+	return runtime.Resume(*instanceConfig)
+}
+
+func Logs(name string, isFollow bool, output io.Writer) error {
+	instanceConfig, err := GetConfig(name)
+	if err != nil {
+		return err
+	}
+
+	runtime, err := getConfigRuntime(*instanceConfig)
+	if err != nil {
+		return err
+	}
+
+	return runtime.Logs(*instanceConfig, isFollow, output)
+}
+
+func Attach(name string, input io.Reader, output io.Writer, detachKeys string) error {
+	instanceConfig, err := GetConfig(name)
+	if err != nil {
+		return err
+	}
+
+	runtime, err := getConfigRuntime(*instanceConfig)
+	if err != nil {
+		return err
+	}
+
+	status, err := runtime.Status(*instanceConfig)
+	if err != nil {
+		return err
+	}
+
+	if !IsActiveStatus(status) || status == StatusPaused {
+		return fmt.Errorf("instance '%v' is not running", name)
+	}
+
+	return runtime.Attach(*instanceConfig, input, output, detachKeys)
+}
+
+// Exec runs command inside running instance and returns command exit code
+func Exec(name string, command []string, input io.Reader, output io.Writer) (int, error) {
+	if len(command) == 0 {
+		return 0, fmt.Errorf("command is required")
+	}
+
+	instanceConfig, err := GetConfig(name)
+	if err != nil {
+		return 0, err
+	}
+
+	runtime, err := getConfigRuntime(*instanceConfig)
+	if err != nil {
+		return 0, err
+	}
+
+	status, err := runtime.Status(*instanceConfig)
+	if err != nil {
+		return 0, err
+	}
+
+	if !IsActiveStatus(status) || status == StatusPaused {
+		return 0, fmt.Errorf("instance '%v' is not running", name)
+	}
+
+	return runtime.Exec(*instanceConfig, command, input, output)
+}
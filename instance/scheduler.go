@@ -0,0 +1,36 @@
+package instance
+
+import (
+	"fmt"
+
+	"github.com/akaumov/cube_executor"
+	"github.com/akaumov/cubes/scheduler"
+)
+
+const SchedulerSource = "go:github.com/akaumov/cubes/scheduler"
+
+// AddScheduler adds instance of built-in scheduler, which publishes messages to bus channels on cron schedules
+func AddScheduler(name string, schedules string) error {
+	parsedSchedules, err := scheduler.ParseSchedules(schedules)
+	if err != nil {
+		return err
+	}
+
+	if len(parsedSchedules) == 0 {
+		return fmt.Errorf("schedules are required")
+	}
+
+	return Add(Config{
+		CubeConfig: cube_executor.CubeConfig{
+			Name:   name,
+			Source: SchedulerSource,
+			Params: map[string]string{
+				"schedules": schedules,
+			},
+			PortsMapping:    []cube_executor.PortMap{},
+			ChannelsMapping: map[cube_executor.CubeChannel]cube_executor.BusChannel{},
+		},
+		Groups: []string{},
+		Labels: map[string]string{},
+	})
+}
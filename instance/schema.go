@@ -0,0 +1,101 @@
+package instance
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/akaumov/cubes/utils"
+)
+
+type rawConfig map[string]json.RawMessage
+
+// configUpgrades maps schema version to function which upgrades config to the next version
+var configUpgrades = map[int]func(config rawConfig) error{
+	1: upgradeConfigFromV1,
+}
+
+func upgradeConfigFromV1(config rawConfig) error {
+	emptyValues := map[string]string{
+		"params":          "{}",
+		"channelsMapping": "{}",
+		"portsMapping":    "[]",
+		"groups":          "[]",
+	}
+
+	for key, emptyValue := range emptyValues {
+		value, ok := config[key]
+		if !ok || string(value) == "null" {
+			config[key] = json.RawMessage(emptyValue)
+		}
+	}
+
+	return nil
+}
+
+func parseSchemaVersion(config rawConfig) (int, error) {
+	var schemaVersion string
+
+	rawSchemaVersion, ok := config["schemaVersion"]
+	if !ok {
+		return 1, nil
+	}
+
+	err := json.Unmarshal(rawSchemaVersion, &schemaVersion)
+	if err != nil {
+		return 0, fmt.Errorf("wrong schema version: %v", err)
+	}
+
+	version, err := strconv.Atoi(schemaVersion)
+	if err != nil {
+		return 0, fmt.Errorf("wrong schema version: %v", schemaVersion)
+	}
+
+	return version, nil
+}
+
+func upgradeConfig(name string, rawConfigText []byte) ([]byte, bool, error) {
+	var config rawConfig
+
+	err := json.Unmarshal(rawConfigText, &config)
+	if err != nil {
+		return nil, false, fmt.Errorf("can't parse instance config: %v", err)
+	}
+
+	currentVersion, _ := strconv.Atoi(Version)
+
+	schemaVersion, err := parseSchemaVersion(config)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if schemaVersion > currentVersion {
+		return nil, false, fmt.Errorf("instance config schema version %v is newer than supported %v, please update cubes", schemaVersion, currentVersion)
+	}
+
+	if schemaVersion == currentVersion {
+		return rawConfigText, false, nil
+	}
+
+	for version := schemaVersion; version < currentVersion; version++ {
+		upgrade, ok := configUpgrades[version]
+		if !ok {
+			return nil, false, fmt.Errorf("no upgrade for instance config schema version %v", version)
+		}
+
+		err = upgrade(config)
+		if err != nil {
+			return nil, false, fmt.Errorf("can't upgrade instance config from schema version %v: %v", version, err)
+		}
+	}
+
+	config["schemaVersion"], _ = json.Marshal(Version)
+	utils.Infof("Upgraded instance %v config from schema version %v to %v\n", name, schemaVersion, currentVersion)
+
+	upgradedConfigText, err := json.Marshal(config)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return upgradedConfigText, true, nil
+}
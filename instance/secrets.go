@@ -0,0 +1,54 @@
+package instance
+
+import (
+	"fmt"
+
+	"github.com/akaumov/cubes/utils"
+)
+
+// resolveSecretParams returns config with ${secret:name} references in params replaced with secrets, params of
+// resolvedParams are already resolved by host, which sent instance to agent
+func resolveSecretParams(config Config, resolvedParams map[string]string) (Config, error) {
+	params := map[string]string{}
+
+	for key, value := range config.Params {
+		resolvedValue, isResolved := resolvedParams[key]
+
+		switch {
+		case isResolved:
+			value = resolvedValue
+		case utils.HasSecretReference(value):
+			resolved, err := utils.ResolveSecrets(value)
+			if err != nil {
+				return config, fmt.Errorf("can't resolve param %v of instance %v: %v", key, config.Name, err)
+			}
+
+			value = resolved
+		}
+
+		params[key] = value
+	}
+
+	config.Params = params
+	return config, nil
+}
+
+// getSecretParams returns only params, which have references to secrets, with resolved secrets
+func getSecretParams(config Config) (map[string]string, error) {
+	secretParams := map[string]string{}
+
+	for key, value := range config.Params {
+		if !utils.HasSecretReference(value) {
+			continue
+		}
+
+		resolved, err := utils.ResolveSecrets(value)
+		if err != nil {
+			return nil, fmt.Errorf("can't resolve param %v of instance %v: %v", key, config.Name, err)
+		}
+
+		secretParams[key] = resolved
+	}
+
+	return secretParams, nil
+}
@@ -0,0 +1,389 @@
+package instance
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/akaumov/cubes/utils"
+)
+
+const (
+	SourceGo      = "go"
+	SourceDocker  = "docker"
+	SourceGit     = "git"
+	SourcePath    = "path"
+	SourceArchive = "archive"
+)
+
+func splitSource(source string) (string, string, error) {
+	if strings.HasPrefix(source, "go:") {
+		return SourceGo, strings.TrimPrefix(source, "go:"), nil
+	} else if strings.HasPrefix(source, "docker://") {
+		return SourceDocker, strings.TrimPrefix(source, "docker://"), nil
+	} else if strings.HasPrefix(source, "docker:") {
+		return SourceDocker, strings.TrimPrefix(source, "docker:"), nil
+	} else if strings.HasPrefix(source, "git:") {
+		return SourceGit, strings.TrimPrefix(source, "git:"), nil
+	} else if strings.HasPrefix(source, "path:") {
+		return SourcePath, strings.TrimPrefix(source, "path:"), nil
+	} else if strings.HasPrefix(source, "archive:") {
+		return SourceArchive, strings.TrimPrefix(source, "archive:"), nil
+	}
+
+	return "", "", fmt.Errorf("wrong source format: %v\n", source)
+}
+
+// splitGitSource splits "url#ref" git source data, ref is optional
+func splitGitSource(sourceData string) (string, string) {
+	index := strings.LastIndex(sourceData, "#")
+	if index == -1 {
+		return sourceData, ""
+	}
+
+	return sourceData[:index], sourceData[index+1:]
+}
+
+// splitArchiveSource splits "url#sha256:hex" archive source data, digest is optional
+func splitArchiveSource(sourceData string) (string, string) {
+	index := strings.LastIndex(sourceData, "#")
+	if index == -1 {
+		return sourceData, ""
+	}
+
+	return sourceData[:index], strings.TrimPrefix(sourceData[index+1:], "sha256:")
+}
+
+// readLocation reads file by url or local path, it's how archives of published cubes are fetched
+func readLocation(location string) ([]byte, error) {
+	if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+		return ioutil.ReadFile(location)
+	}
+
+	client := http.Client{
+		Timeout: 5 * time.Minute,
+	}
+
+	response, err := client.Get(location)
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%v responded with status %v", location, response.StatusCode)
+	}
+
+	return ioutil.ReadAll(response.Body)
+}
+
+// getArchiveMetaLocation returns location of meta.json, which is published next to cube archive
+func getArchiveMetaLocation(url string) string {
+	return url[:strings.LastIndex(url, "/")+1] + metaFileName
+}
+
+// getArchiveCachePath returns directory of archive in project cache, only archives with digest are cached
+func getArchiveCachePath(digest string) (string, error) {
+	return utils.GetStateDirectoryPath("cache", "archives", digest)
+}
+
+// fetchArchiveSource downloads packed cube of archive source, checks its digest and returns path to it,
+// archive with digest is downloaded once into project cache with its meta
+func fetchArchiveSource(sourceData string, outputDir string) (string, error) {
+	url, digest := splitArchiveSource(sourceData)
+
+	if digest != "" {
+		cachePath, err := getArchiveCachePath(digest)
+		if err != nil {
+			return "", err
+		}
+
+		cachedAppPath := filepath.Join(cachePath, cubeArchiveName)
+		if _, err := os.Stat(cachedAppPath); err == nil {
+			return cachedAppPath, nil
+		}
+
+		// meta is optional, like in cube source
+		rawMeta, err := readLocation(getArchiveMetaLocation(url))
+		if err == nil {
+			err = ioutil.WriteFile(filepath.Join(cachePath, metaFileName), rawMeta, 0644)
+		}
+
+		if err != nil {
+			utils.Debugf("Can't cache meta of %v: %v\n", url, err)
+		}
+
+		outputDir = cachePath
+	}
+
+	utils.Infof("Downloading %v...\n", url)
+
+	archive, err := readLocation(url)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(archive)
+	if digest != "" && hex.EncodeToString(hash[:]) != digest {
+		return "", fmt.Errorf("digest of %v doesn't match sha256:%v", url, digest)
+	}
+
+	appPath := filepath.Join(outputDir, cubeArchiveName)
+	return appPath, ioutil.WriteFile(appPath, archive, 0644)
+}
+
+// PullSource downloads source of cube into project cache or docker, so instance is started without downloading it
+func PullSource(source string) error {
+	sourceType, sourceData, err := splitSource(source)
+	if err != nil {
+		return err
+	}
+
+	switch sourceType {
+	case SourceArchive:
+		tempDir, err := ioutil.TempDir("", "cubes_")
+		if err != nil {
+			return err
+		}
+
+		defer os.RemoveAll(tempDir)
+
+		_, err = fetchArchiveSource(sourceData, tempDir)
+		return err
+	case SourceDocker:
+		utils.Infof("Pulling %v...\n", sourceData)
+		return utils.PullImage(sourceData)
+	case SourceGit:
+		url, ref := splitGitSource(sourceData)
+		_, _, err = syncGitSource(url, ref)
+		return err
+	}
+
+	return nil
+}
+
+func runGit(directory string, args ...string) (string, error) {
+	command := exec.Command("git", args...)
+	command.Dir = directory
+	command.Stderr = os.Stderr
+
+	output, err := command.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %v: %v", strings.Join(args, " "), err)
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+func getGitCachePath(url string) (string, error) {
+	gitCacheDirectory, err := utils.GetStateDirectoryPath("cache", "git")
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha1.Sum([]byte(url))
+	return filepath.Join(gitCacheDirectory, hex.EncodeToString(hash[:])[:12]), nil
+}
+
+// syncGitSource clones or fetches repository into project cache, checks out ref and returns checkout path and commit
+func syncGitSource(url string, ref string) (string, string, error) {
+	// url and ref are passed to git as args, so they can't look like options
+	if url == "" || strings.HasPrefix(url, "-") {
+		return "", "", fmt.Errorf("wrong git url: '%v'", url)
+	}
+
+	if strings.HasPrefix(ref, "-") {
+		return "", "", fmt.Errorf("wrong git ref: '%v'", ref)
+	}
+
+	checkoutPath, err := getGitCachePath(url)
+	if err != nil {
+		return "", "", err
+	}
+
+	if _, err := os.Stat(checkoutPath); err != nil {
+		if !os.IsNotExist(err) {
+			return "", "", err
+		}
+
+		utils.Infof("Cloning %v...\n", url)
+		_, err = runGit("", "clone", "--quiet", "--", url, checkoutPath)
+		if err != nil {
+			return "", "", err
+		}
+	} else {
+		utils.Infof("Fetching %v...\n", url)
+		_, err = runGit(checkoutPath, "fetch", "--quiet", "--tags", "origin")
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	commit, err := runGit(checkoutPath, "rev-parse", "--verify", "--quiet", "origin/"+ref+"^{commit}")
+	if err != nil {
+		commit, err = runGit(checkoutPath, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+		if err != nil {
+			return "", "", fmt.Errorf("can't resolve ref '%v' of %v", ref, url)
+		}
+	}
+
+	_, err = runGit(checkoutPath, "checkout", "--quiet", "--force", commit)
+	if err != nil {
+		return "", "", err
+	}
+
+	return checkoutPath, commit, nil
+}
+
+// pinSource records git commit or image digest of instance source, so instance is started from the same code
+func pinSource(config *Config) error {
+	sourceType, sourceData, err := splitSource(config.Source)
+	if err != nil {
+		return err
+	}
+
+	switch sourceType {
+	case SourceGit:
+		url, ref := splitGitSource(sourceData)
+
+		buildMutex.Lock()
+		_, commit, err := syncGitSource(url, ref)
+		buildMutex.Unlock()
+
+		if err != nil {
+			return fmt.Errorf("can't fetch git source: %v", err)
+		}
+
+		config.SourceCommit = commit
+		break
+	case SourceDocker:
+		utils.Infof("Pulling %v...\n", sourceData)
+
+		err = utils.PullImage(sourceData)
+		if err != nil {
+			return fmt.Errorf("can't pull image: %v", err)
+		}
+
+		digest, err := utils.GetImageDigest(sourceData)
+		if err != nil {
+			return fmt.Errorf("can't resolve image digest: %v", err)
+		}
+
+		config.SourceDigest = digest
+		break
+	}
+
+	return nil
+}
+
+// getPinnedImage returns image reference with recorded digest
+func getPinnedImage(config Config, image string) string {
+	if config.SourceDigest == "" {
+		return image
+	}
+
+	return utils.GetImageRepository(image) + "@" + config.SourceDigest
+}
+
+// buildGitSource builds cube at recorded commit and returns path to packed cube
+func buildGitSource(config Config, outputDir string) (string, error) {
+	_, sourceData, err := splitSource(config.Source)
+	if err != nil {
+		return "", err
+	}
+
+	buildMutex.Lock()
+	defer buildMutex.Unlock()
+
+	url, ref := splitGitSource(sourceData)
+	if config.SourceCommit != "" {
+		ref = config.SourceCommit
+	}
+
+	checkoutPath, _, err := syncGitSource(url, ref)
+	if err != nil {
+		return "", fmt.Errorf("can't fetch git source: %v", err)
+	}
+
+	binaryPath, err := buildCube(checkoutPath, outputDir)
+	if err != nil {
+		return "", err
+	}
+
+	appPath := filepath.Join(outputDir, "cube.tar")
+	err = packCube(binaryPath, appPath)
+	if err != nil {
+		return "", fmt.Errorf("can't pack cube: %v", err)
+	}
+
+	return appPath, nil
+}
+
+// Upgrade moves instance source to new git ref or image tag and records resolved commit or digest in config and lock
+func Upgrade(name string, ref string) (string, error) {
+	config, err := GetConfig(name)
+	if err != nil {
+		return "", err
+	}
+
+	sourceType, sourceData, err := splitSource(config.Source)
+	if err != nil {
+		return "", err
+	}
+
+	switch sourceType {
+	case SourceGit:
+		url, currentRef := splitGitSource(sourceData)
+		if ref == "" {
+			ref = currentRef
+		}
+
+		if ref != "" {
+			config.Source = "git:" + url + "#" + ref
+		} else {
+			config.Source = "git:" + url
+		}
+		break
+	case SourceDocker:
+		if ref != "" {
+			config.Source = "docker://" + utils.GetImageRepository(sourceData) + ":" + ref
+		}
+		break
+	default:
+		return "", fmt.Errorf("instance '%v' doesn't have git or docker source", name)
+	}
+
+	err = pinSource(config)
+	if err != nil {
+		return "", err
+	}
+
+	err = saveConfig(*config)
+	if err != nil {
+		return "", err
+	}
+
+	err = lockSource(*config)
+	if err != nil {
+		return "", fmt.Errorf("can't save %v: %v", LockFileName, err)
+	}
+
+	if sourceType == SourceGit {
+		return config.SourceCommit, nil
+	}
+
+	return config.SourceDigest, nil
+}
@@ -0,0 +1,115 @@
+package instance
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/akaumov/cube_executor"
+	"github.com/akaumov/cubes/utils"
+)
+
+// State is runtime information of started instance which isn't part of its config
+type State struct {
+	Ports []cube_executor.PortMap `json:"ports"`
+
+	// StartedConfig is config of instance as it was read on start, before profile and lock are applied
+	StartedConfig *Config `json:"startedConfig"`
+}
+
+func getStatePath(name string) (string, error) {
+	stateDirectory, err := utils.GetStateDirectoryPath("state")
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(stateDirectory, name+".json"), nil
+}
+
+func GetState(name string) (*State, error) {
+	statePath, err := getStatePath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	rawState, err := ioutil.ReadFile(statePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	var state State
+	err = json.Unmarshal(rawState, &state)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse instance state: %v", err)
+	}
+
+	return &state, nil
+}
+
+func saveState(name string, state State) error {
+	statePath, err := getStatePath(name)
+	if err != nil {
+		return err
+	}
+
+	packedState, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(statePath, packedState, 0666)
+}
+
+func removeState(name string) error {
+	statePath, err := getStatePath(name)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(statePath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// renameState moves state of instance to new name, so config instance was started with is kept
+func renameState(transaction *fileTransaction, name string, newName string) error {
+	state, err := GetState(name)
+	if err != nil || state == nil {
+		return err
+	}
+
+	if state.StartedConfig != nil {
+		state.StartedConfig.Name = newName
+	}
+
+	packedState, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	newStatePath, err := getStatePath(newName)
+	if err != nil {
+		return err
+	}
+
+	err = transaction.writeFile(newStatePath, packedState, 0666)
+	if err != nil {
+		return err
+	}
+
+	statePath, err := getStatePath(name)
+	if err != nil {
+		return err
+	}
+
+	return transaction.removeFile(statePath)
+}
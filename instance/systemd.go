@@ -0,0 +1,93 @@
+package instance
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"text/template"
+)
+
+type SystemdUnitOptions struct {
+	Restart          string
+	RestartSec       int
+	EnvironmentFiles []string
+}
+
+type systemdUnitData struct {
+	Name             string
+	CubesPath        string
+	ProjectPath      string
+	Restart          string
+	RestartSec       int
+	EnvironmentFiles []string
+}
+
+// instance container is started detached, so unit follows its logs to be bound to container lifetime
+var systemdUnitTemplate = template.Must(template.New("unit").Parse(`[Unit]
+Description=cubes instance {{.Name}}
+Requires=docker.service
+After=docker.service network-online.target
+
+[Service]
+Type=simple
+WorkingDirectory={{.ProjectPath}}
+{{range .EnvironmentFiles}}EnvironmentFile={{.}}
+{{end}}ExecStartPre={{.CubesPath}} instance start {{.Name}}
+ExecStart={{.CubesPath}} instance logs --follow {{.Name}}
+ExecStop={{.CubesPath}} instance stop {{.Name}}
+Restart={{.Restart}}
+RestartSec={{.RestartSec}}
+
+[Install]
+WantedBy=multi-user.target
+`))
+
+var systemdRestartPolicies = []string{"no", "always", "on-success", "on-failure", "on-abnormal", "on-abort", "on-watchdog"}
+
+func GetSystemdUnit(name string, options SystemdUnitOptions) (string, error) {
+	isExist, err := IsExist(name)
+	if err != nil {
+		return "", err
+	}
+
+	if !isExist {
+		return "", fmt.Errorf("instance '%v' doesn't exist", name)
+	}
+
+	isRestartPolicyValid := false
+	for _, policy := range systemdRestartPolicies {
+		if policy == options.Restart {
+			isRestartPolicyValid = true
+		}
+	}
+
+	if !isRestartPolicyValid {
+		return "", fmt.Errorf("wrong restart policy: %v", options.Restart)
+	}
+
+	cubesPath, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("can't find cubes executable: %v", err)
+	}
+
+	projectPath, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	var unit bytes.Buffer
+	err = systemdUnitTemplate.Execute(&unit, systemdUnitData{
+		Name:             name,
+		CubesPath:        cubesPath,
+		ProjectPath:      projectPath,
+		Restart:          options.Restart,
+		RestartSec:       options.RestartSec,
+		EnvironmentFiles: options.EnvironmentFiles,
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return unit.String(), nil
+}
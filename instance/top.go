@@ -0,0 +1,116 @@
+package instance
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+	"time"
+)
+
+type topSample struct {
+	metrics Metrics
+	time    time.Time
+}
+
+func formatBytes(value uint64) string {
+	units := []string{"B", "KiB", "MiB", "GiB"}
+
+	size := float64(value)
+	unit := 0
+
+	for size >= 1024 && unit < len(units)-1 {
+		size /= 1024
+		unit++
+	}
+
+	return fmt.Sprintf("%.1f%v", size, units[unit])
+}
+
+func getRate(current uint64, previous uint64, seconds float64) float64 {
+	if seconds <= 0 || current < previous {
+		return 0
+	}
+
+	return float64(current-previous) / seconds
+}
+
+func writeTop(output io.Writer, filter Filter, samples map[string]topSample) error {
+	configs, err := GetFilteredList(filter)
+	if err != nil {
+		return err
+	}
+
+	writer := tabwriter.NewWriter(output, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "NAME\tSTATUS\tCPU %\tMEMORY\tFILES\tIN MSG/S\tOUT MSG/S")
+
+	for _, config := range *configs {
+		metrics, err := GetMetrics(config.Name)
+		if err != nil {
+			metrics = &Metrics{
+				Name:   config.Name,
+				Status: StatusUnknown,
+			}
+		}
+
+		now := time.Now()
+		cpuPercent, inRate, outRate := 0.0, 0.0, 0.0
+
+		previous, ok := samples[config.Name]
+		if ok {
+			seconds := now.Sub(previous.time).Seconds()
+
+			if seconds > 0 && metrics.CpuSeconds >= previous.metrics.CpuSeconds {
+				cpuPercent = (metrics.CpuSeconds - previous.metrics.CpuSeconds) / seconds * 100
+			}
+
+			inRate = getRate(metrics.InMessages, previous.metrics.InMessages, seconds)
+			outRate = getRate(metrics.OutMessages, previous.metrics.OutMessages, seconds)
+		}
+
+		samples[config.Name] = topSample{
+			metrics: *metrics,
+			time:    now,
+		}
+
+		if !IsActiveStatus(metrics.Status) {
+			fmt.Fprintf(writer, "%v\t%v\t-\t-\t-\t-\t-\n", config.Name, metrics.Status)
+			continue
+		}
+
+		fmt.Fprintf(writer, "%v\t%v\t%.1f\t%v\t%v\t%.1f\t%.1f\n",
+			config.Name, metrics.Status, cpuPercent, formatBytes(metrics.MemoryBytes), metrics.OpenFiles, inRate, outRate)
+	}
+
+	return writer.Flush()
+}
+
+// Top shows resources usage of instances refreshing it in place until Ctrl+C is pressed
+func Top(filter Filter, interval time.Duration, output io.Writer) error {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	samples := map[string]topSample{}
+
+	for {
+		// move cursor home and clear screen
+		fmt.Fprint(output, "\033[H\033[2J")
+		fmt.Fprintf(output, "%v, refresh every %v, press Ctrl+C to exit\n\n", time.Now().Format("15:04:05"), interval)
+
+		err := writeTop(output, filter, samples)
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-interrupt:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
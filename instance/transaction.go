@@ -0,0 +1,104 @@
+package instance
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+// fileTransaction records changes of instance files, so they can be undone when change of several files fails
+type fileTransaction struct {
+	undos []func() error
+}
+
+// readOriginal returns content of file before change, it's nil when file doesn't exist
+func readOriginal(path string) ([]byte, os.FileMode, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, 0, nil
+		}
+
+		return nil, 0, err
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return content, info.Mode(), nil
+}
+
+// restore writes original content of file back or removes file, which didn't exist
+func restore(path string, original []byte, mode os.FileMode) error {
+	if original == nil {
+		err := os.Remove(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		return nil
+	}
+
+	return ioutil.WriteFile(path, original, mode)
+}
+
+func (t *fileTransaction) writeFile(path string, content []byte, mode os.FileMode) error {
+	original, originalMode, err := readOriginal(path)
+	if err != nil {
+		return err
+	}
+
+	t.undos = append(t.undos, func() error {
+		return restore(path, original, originalMode)
+	})
+
+	return ioutil.WriteFile(path, content, mode)
+}
+
+func (t *fileTransaction) removeFile(path string) error {
+	original, originalMode, err := readOriginal(path)
+	if err != nil || original == nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if err != nil {
+		return err
+	}
+
+	t.undos = append(t.undos, func() error {
+		return restore(path, original, originalMode)
+	})
+
+	return nil
+}
+
+// renameDirectory moves directory to path, which must not exist
+func (t *fileTransaction) renameDirectory(path string, newPath string) error {
+	err := os.Rename(path, newPath)
+	if err != nil {
+		return err
+	}
+
+	t.undos = append(t.undos, func() error {
+		return os.Rename(newPath, path)
+	})
+
+	return nil
+}
+
+// rollback undoes changes from the latest one, changes, which can't be undone, are returned as the first error
+func (t *fileTransaction) rollback() error {
+	var firstErr error
+
+	for index := len(t.undos) - 1; index >= 0; index-- {
+		err := t.undos[index]()
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	t.undos = nil
+	return firstErr
+}
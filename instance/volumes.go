@@ -0,0 +1,84 @@
+package instance
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type Volume struct {
+	HostPath   string `json:"hostPath"`
+	CubePath   string `json:"cubePath"`
+	IsReadOnly bool   `json:"isReadOnly"`
+}
+
+func checkVolumes(volumes []Volume) error {
+	cubePaths := map[string]bool{}
+
+	for _, volume := range volumes {
+		if strings.TrimSpace(volume.HostPath) == "" || strings.TrimSpace(volume.CubePath) == "" {
+			return fmt.Errorf("volume host and cube paths are required")
+		}
+
+		if !filepath.IsAbs(volume.CubePath) {
+			return fmt.Errorf("volume cube path must be absolute: %v", volume.CubePath)
+		}
+
+		if cubePaths[volume.CubePath] {
+			return fmt.Errorf("cube path %v is mounted twice", volume.CubePath)
+		}
+
+		cubePaths[volume.CubePath] = true
+	}
+
+	return nil
+}
+
+// getVolumeHostPath resolves host path relative to project directory and creates it if missing
+func getVolumeHostPath(volume Volume) (string, error) {
+	hostPath := volume.HostPath
+
+	if !filepath.IsAbs(hostPath) {
+		pwd, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+
+		hostPath = filepath.Join(pwd, hostPath)
+	}
+
+	if _, err := os.Stat(hostPath); err != nil {
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+
+		err = os.MkdirAll(hostPath, 0777)
+		if err != nil {
+			return "", fmt.Errorf("can't create volume directory %v: %v", hostPath, err)
+		}
+	}
+
+	return hostPath, nil
+}
+
+// getVolumesBinds returns docker binds for instance volumes: hostPath:cubePath[:ro]
+func getVolumesBinds(volumes []Volume) ([]string, error) {
+	binds := []string{}
+
+	for _, volume := range volumes {
+		hostPath, err := getVolumeHostPath(volume)
+		if err != nil {
+			return nil, err
+		}
+
+		mode := "rw"
+		if volume.IsReadOnly {
+			mode = "ro"
+		}
+
+		binds = append(binds, hostPath+":"+volume.CubePath+":"+mode)
+	}
+
+	return binds, nil
+}
@@ -0,0 +1,228 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/akaumov/cube_executor"
+	"github.com/akaumov/cubes/instance"
+	"github.com/akaumov/cubes/utils"
+)
+
+// EnvRegistryToken is bearer token, which is sent with files published to http registry
+const EnvRegistryToken = "CUBES_REGISTRY_TOKEN"
+
+// packagesDirectoryName is directory next to registry index, which published cubes are put to
+const packagesDirectoryName = "cubes"
+
+// PublishOptions describe published cube, Image is repository of docker image, cube is published
+// as image, when it's set, otherwise its archive is put next to registry index
+type PublishOptions struct {
+	Registry    string
+	Image       string
+	Description string
+	Tags        []string
+}
+
+func isHttpLocation(location string) bool {
+	return strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+}
+
+// writeLocation writes file by local path or with http PUT
+func writeLocation(location string, data []byte) error {
+	if !isHttpLocation(location) {
+		err := os.MkdirAll(filepath.Dir(location), 0755)
+		if err != nil {
+			return err
+		}
+
+		return ioutil.WriteFile(location, data, 0644)
+	}
+
+	request, err := http.NewRequest(http.MethodPut, location, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	if token := os.Getenv(EnvRegistryToken); token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	client := http.Client{
+		Timeout: 5 * time.Minute,
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("registry responded with status %v to %v", response.StatusCode, location)
+	}
+
+	return nil
+}
+
+// getPackageLocation returns location of file of published cube, it's next to registry index
+func getPackageLocation(registry string, p instance.Package, fileName string) string {
+	if isHttpLocation(registry) {
+		return registry[:strings.LastIndex(registry, "/")+1] + path.Join(packagesDirectoryName, p.Name, p.Version, fileName)
+	}
+
+	return filepath.Join(filepath.Dir(registry), packagesDirectoryName, p.Name, p.Version, fileName)
+}
+
+// publishArchive puts packed cube and its meta next to registry index and returns archive source of cube
+func publishArchive(registry string, p instance.Package) (string, error) {
+	archive, err := ioutil.ReadFile(p.ArchivePath)
+	if err != nil {
+		return "", err
+	}
+
+	digest, err := p.GetDigest()
+	if err != nil {
+		return "", err
+	}
+
+	rawMeta, err := json.MarshalIndent(p.Meta, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	archiveLocation := getPackageLocation(registry, p, filepath.Base(p.ArchivePath))
+
+	err = writeLocation(archiveLocation, archive)
+	if err != nil {
+		return "", fmt.Errorf("can't upload cube archive: %v", err)
+	}
+
+	err = writeLocation(getPackageLocation(registry, p, "meta.json"), rawMeta)
+	if err != nil {
+		return "", fmt.Errorf("can't upload cube meta: %v", err)
+	}
+
+	if !isHttpLocation(registry) {
+		archiveLocation, err = filepath.Abs(archiveLocation)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return "archive:" + archiveLocation + "#sha256:" + digest, nil
+}
+
+// publishImage builds image of cube, pushes it and returns docker source of cube
+func publishImage(image string, p instance.Package) (string, error) {
+	image = image + ":" + p.Version
+
+	err := instance.BuildImage(p, image)
+	if err != nil {
+		return "", fmt.Errorf("can't build image: %v", err)
+	}
+
+	err = utils.PushImage(image)
+	if err != nil {
+		return "", fmt.Errorf("can't push image: %v", err)
+	}
+
+	return "docker://" + image, nil
+}
+
+// addTemplate adds template to registry index, template of the same name and version is replaced, missing local index is created
+func addTemplate(registry string, template Template) error {
+	index, err := GetIndex(registry)
+	if err != nil {
+		if isHttpLocation(registry) {
+			return err
+		}
+
+		if _, statErr := os.Stat(registry); !os.IsNotExist(statErr) {
+			return err
+		}
+
+		index = &Index{
+			Version:   "1",
+			Templates: []Template{},
+		}
+	}
+
+	templates := []Template{}
+	for _, indexTemplate := range index.Templates {
+		if indexTemplate.Name != template.Name || indexTemplate.Version != template.Version {
+			templates = append(templates, indexTemplate)
+		}
+	}
+
+	index.Templates = append(templates, template)
+
+	rawIndex, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeLocation(registry, rawIndex)
+}
+
+// Publish publishes packed cube as image or archive and adds its template to registry index, it returns source of cube.
+// Registry isn't required, when cube is published as image.
+func Publish(p instance.Package, options PublishOptions) (string, error) {
+	if options.Image == "" && options.Registry == "" {
+		return "", fmt.Errorf("registry or image is required")
+	}
+
+	var source string
+	var err error
+
+	if options.Image != "" {
+		source, err = publishImage(options.Image, p)
+	} else {
+		source, err = publishArchive(options.Registry, p)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	if options.Registry == "" {
+		return source, nil
+	}
+
+	description := options.Description
+	if description == "" {
+		description = p.Meta.Description
+	}
+
+	tags := options.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	err = addTemplate(options.Registry, Template{
+		Name:            p.Name,
+		Version:         p.Version,
+		Description:     description,
+		Tags:            tags,
+		Source:          source,
+		Class:           p.Name,
+		Params:          map[string]string{},
+		PortsMapping:    []cube_executor.PortMap{},
+		ChannelsMapping: map[cube_executor.CubeChannel]cube_executor.BusChannel{},
+	})
+
+	if err != nil {
+		return "", fmt.Errorf("can't add template to registry index: %v", err)
+	}
+
+	return source, nil
+}
@@ -0,0 +1,54 @@
+package registry
+
+import (
+	"fmt"
+
+	"github.com/akaumov/cube_executor"
+	"github.com/akaumov/cubes/instance"
+)
+
+// Pull downloads published cube by "name" or "name@version" into project cache and returns config of its instance.
+// Channels and params, which cube declares in meta, are pre-filled, so they're visible and can be edited in config.
+func Pull(location string, reference string, name string, params map[string]string) (*instance.Config, error) {
+	template, err := Get(location, reference)
+	if err != nil {
+		return nil, err
+	}
+
+	err = instance.PullSource(template.Source)
+	if err != nil {
+		return nil, fmt.Errorf("can't pull %v: %v", template.Source, err)
+	}
+
+	if name == "" {
+		name = template.Name
+	}
+
+	config := getInstanceConfig(*template, name, params)
+	if template.Version != "" {
+		config.Labels["version"] = template.Version
+	}
+
+	meta, err := instance.GetMeta(config)
+	if err != nil {
+		return nil, fmt.Errorf("can't read cube meta: %v", err)
+	}
+
+	if meta == nil {
+		return &config, nil
+	}
+
+	for cubeChannel := range meta.Channels {
+		if _, ok := config.ChannelsMapping[cube_executor.CubeChannel(cubeChannel)]; !ok {
+			config.ChannelsMapping[cube_executor.CubeChannel(cubeChannel)] = cube_executor.BusChannel(cubeChannel)
+		}
+	}
+
+	for param, paramMeta := range meta.Params {
+		if _, ok := config.Params[param]; !ok && paramMeta.Default != nil {
+			config.Params[param] = fmt.Sprint(paramMeta.Default)
+		}
+	}
+
+	return &config, nil
+}
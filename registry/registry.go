@@ -0,0 +1,180 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/akaumov/cube_executor"
+	"github.com/akaumov/cubes/instance"
+)
+
+// Template is reusable instance definition published in registry index
+type Template struct {
+	Name            string                                                 `json:"name"`
+	Version         string                                                 `json:"version,omitempty"`
+	Description     string                                                 `json:"description"`
+	Tags            []string                                               `json:"tags"`
+	Source          string                                                 `json:"source"`
+	Class           string                                                 `json:"class"`
+	Params          map[string]string                                      `json:"params"`
+	PortsMapping    []cube_executor.PortMap                                `json:"portsMapping"`
+	ChannelsMapping map[cube_executor.CubeChannel]cube_executor.BusChannel `json:"channelsMapping"`
+}
+
+type Index struct {
+	Version   string     `json:"version"`
+	Templates []Template `json:"templates"`
+}
+
+func readIndex(location string) ([]byte, error) {
+	if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+		return ioutil.ReadFile(location)
+	}
+
+	client := http.Client{
+		Timeout: 30 * time.Second,
+	}
+
+	response, err := client.Get(location)
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("registry responded with status %v", response.StatusCode)
+	}
+
+	return ioutil.ReadAll(response.Body)
+}
+
+// GetIndex reads registry index from url or local file
+func GetIndex(location string) (*Index, error) {
+	if location == "" {
+		return nil, fmt.Errorf("registry is required")
+	}
+
+	rawIndex, err := readIndex(location)
+	if err != nil {
+		return nil, fmt.Errorf("can't read registry index: %v", err)
+	}
+
+	var index Index
+	err = json.Unmarshal(rawIndex, &index)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse registry index: %v", err)
+	}
+
+	return &index, nil
+}
+
+func (t Template) match(query string) bool {
+	if strings.Contains(strings.ToLower(t.Name), query) || strings.Contains(strings.ToLower(t.Description), query) {
+		return true
+	}
+
+	for _, tag := range t.Tags {
+		if strings.ToLower(tag) == query {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Search returns templates which name, description or tags contain query, all templates for empty query
+func Search(location string, query string) (*[]Template, error) {
+	index, err := GetIndex(location)
+	if err != nil {
+		return nil, err
+	}
+
+	query = strings.ToLower(strings.TrimSpace(query))
+	result := []Template{}
+
+	for _, template := range index.Templates {
+		if query == "" || template.match(query) {
+			result = append(result, template)
+		}
+	}
+
+	return &result, nil
+}
+
+// Get returns template by "name" or "name@version", it's the last published version, when version isn't set
+func Get(location string, reference string) (*Template, error) {
+	index, err := GetIndex(location)
+	if err != nil {
+		return nil, err
+	}
+
+	name, version := reference, ""
+	if separatorIndex := strings.LastIndex(reference, "@"); separatorIndex != -1 {
+		name, version = reference[:separatorIndex], reference[separatorIndex+1:]
+	}
+
+	var result *Template
+
+	for i, template := range index.Templates {
+		if template.Name == name && (version == "" || template.Version == version) {
+			result = &index.Templates[i]
+		}
+	}
+
+	if result == nil {
+		return nil, fmt.Errorf("template '%v' isn't found in registry", reference)
+	}
+
+	return result, nil
+}
+
+// Install adds instance from registry template, params override template's params
+func Install(location string, templateName string, name string, params map[string]string) error {
+	template, err := Get(location, templateName)
+	if err != nil {
+		return err
+	}
+
+	return instance.Add(getInstanceConfig(*template, name, params))
+}
+
+func getInstanceConfig(template Template, name string, params map[string]string) instance.Config {
+	instanceParams := map[string]string{}
+	for key, value := range template.Params {
+		instanceParams[key] = value
+	}
+
+	for key, value := range params {
+		instanceParams[key] = value
+	}
+
+	portsMapping := template.PortsMapping
+	if portsMapping == nil {
+		portsMapping = []cube_executor.PortMap{}
+	}
+
+	channelsMapping := template.ChannelsMapping
+	if channelsMapping == nil {
+		channelsMapping = map[cube_executor.CubeChannel]cube_executor.BusChannel{}
+	}
+
+	return instance.Config{
+		CubeConfig: cube_executor.CubeConfig{
+			Name:            name,
+			Source:          template.Source,
+			Class:           template.Class,
+			Params:          instanceParams,
+			PortsMapping:    portsMapping,
+			ChannelsMapping: channelsMapping,
+		},
+		Groups: []string{},
+		Labels: map[string]string{
+			"template": template.Name,
+		},
+	}
+}
@@ -0,0 +1,159 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type cronField struct {
+	min int
+	max int
+}
+
+var cronFields = []cronField{
+	{0, 59}, // minute
+	{0, 23}, // hour
+	{1, 31}, // day of month
+	{1, 12}, // month
+	{0, 6},  // day of week
+}
+
+// Expression is parsed cron expression, each field is set of allowed values
+type Expression struct {
+	fields          [5]map[int]bool
+	isDayOfMonthAny bool
+	isDayOfWeekAny  bool
+}
+
+// Schedule publishes message to channel at times matched by cron expression
+type Schedule struct {
+	Rule       string
+	Expression Expression
+	Channel    string
+}
+
+func parseCronField(rawField string, field cronField) (map[int]bool, error) {
+	values := map[int]bool{}
+
+	for _, part := range strings.Split(rawField, ",") {
+		step := 1
+		if index := strings.Index(part, "/"); index != -1 {
+			var err error
+			step, err = strconv.Atoi(part[index+1:])
+			if err != nil || step <= 0 {
+				return nil, fmt.Errorf("wrong step: %v", part)
+			}
+
+			part = part[:index]
+		}
+
+		from, to := field.min, field.max
+
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+
+			var err error
+			from, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, fmt.Errorf("wrong value: %v", part)
+			}
+
+			to = from
+			if len(bounds) == 2 {
+				to, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return nil, fmt.Errorf("wrong value: %v", part)
+				}
+			}
+		}
+
+		if from < field.min || to > field.max || from > to {
+			return nil, fmt.Errorf("value %v is out of range %v-%v", part, field.min, field.max)
+		}
+
+		for value := from; value <= to; value += step {
+			values[value] = true
+		}
+	}
+
+	return values, nil
+}
+
+// ParseExpression parses standard 5 fields cron expression: "minute hour dayOfMonth month dayOfWeek"
+func ParseExpression(rawExpression string) (*Expression, error) {
+	rawFields := strings.Fields(rawExpression)
+	if len(rawFields) != len(cronFields) {
+		return nil, fmt.Errorf("cron expression must have %v fields: %v", len(cronFields), rawExpression)
+	}
+
+	var expression Expression
+
+	for index, rawField := range rawFields {
+		values, err := parseCronField(rawField, cronFields[index])
+		if err != nil {
+			return nil, fmt.Errorf("wrong cron expression '%v': %v", rawExpression, err)
+		}
+
+		expression.fields[index] = values
+	}
+
+	expression.isDayOfMonthAny = rawFields[2] == "*"
+	expression.isDayOfWeekAny = rawFields[4] == "*"
+
+	return &expression, nil
+}
+
+// Match returns true if time matches expression, day of month and day of week match if any of them matches like in cron
+func (e Expression) Match(moment time.Time) bool {
+	if !e.fields[0][moment.Minute()] || !e.fields[1][moment.Hour()] || !e.fields[3][int(moment.Month())] {
+		return false
+	}
+
+	isDayOfMonthMatched := e.fields[2][moment.Day()]
+	isDayOfWeekMatched := e.fields[4][int(moment.Weekday())]
+
+	if e.isDayOfMonthAny || e.isDayOfWeekAny {
+		return isDayOfMonthMatched && isDayOfWeekMatched
+	}
+
+	return isDayOfMonthMatched || isDayOfWeekMatched
+}
+
+// ParseSchedules parses schedules table: "*/5 * * * *:jobs.cleanup;0 3 * * *:reports.daily"
+func ParseSchedules(rawSchedules string) ([]Schedule, error) {
+	schedules := []Schedule{}
+
+	for _, rawSchedule := range strings.Split(rawSchedules, ";") {
+		rawSchedule = strings.TrimSpace(rawSchedule)
+		if rawSchedule == "" {
+			continue
+		}
+
+		channelIndex := strings.LastIndex(rawSchedule, ":")
+		if channelIndex == -1 {
+			return nil, fmt.Errorf("wrong schedule format: %v", rawSchedule)
+		}
+
+		channel := strings.TrimSpace(rawSchedule[channelIndex+1:])
+		if channel == "" {
+			return nil, fmt.Errorf("wrong schedule format: %v", rawSchedule)
+		}
+
+		rule := strings.TrimSpace(rawSchedule[:channelIndex])
+
+		expression, err := ParseExpression(rule)
+		if err != nil {
+			return nil, err
+		}
+
+		schedules = append(schedules, Schedule{
+			Rule:       rule,
+			Expression: *expression,
+			Channel:    channel,
+		})
+	}
+
+	return schedules, nil
+}
@@ -0,0 +1,106 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/akaumov/cube"
+)
+
+const Version = "1"
+
+// TickParams are params of message published on schedule
+type TickParams struct {
+	Time int64  `json:"time"`
+	Rule string `json:"rule"`
+}
+
+// Handler is cube which publishes "tick" messages to bus channels on cron schedules
+type Handler struct {
+	cubeInstance cube.Cube
+	schedules    []Schedule
+	stop         chan struct{}
+}
+
+func (h *Handler) OnInitInstance() []cube.InputChannel {
+	return []cube.InputChannel{}
+}
+
+func (h *Handler) OnStart(cubeInstance cube.Cube) {
+	h.cubeInstance = cubeInstance
+
+	schedules, err := ParseSchedules(cubeInstance.GetParam("schedules"))
+	if err != nil {
+		cubeInstance.LogFatal("can't parse schedules: " + err.Error())
+		return
+	}
+
+	h.schedules = schedules
+	h.stop = make(chan struct{})
+
+	go h.run()
+}
+
+func (h *Handler) OnStop(c cube.Cube) {
+	if h.stop != nil {
+		close(h.stop)
+	}
+}
+
+func (h *Handler) OnReceiveMessage(instance cube.Cube, channel cube.Channel, message cube.Message) {
+	instance.LogError("OnReceiveMessage: is not implemented")
+}
+
+func (h *Handler) OnReceiveRequest(instance cube.Cube, channel cube.Channel, request cube.Request) (*cube.Response, error) {
+	instance.LogError("OnReceiveRequest: is not implemented")
+	return &cube.Response{
+		Version: Version,
+		Errors: &[]cube.Error{
+			{
+				Code:        "400",
+				Name:        "NotImplemented",
+				Description: "OnReceiveRequest: is not implemented",
+			},
+		},
+	}, nil
+}
+
+// run checks schedules at the beginning of every minute
+func (h *Handler) run() {
+	for {
+		now := time.Now()
+		nextMinute := now.Truncate(time.Minute).Add(time.Minute)
+
+		select {
+		case <-h.stop:
+			return
+		case moment := <-time.After(nextMinute.Sub(now)):
+			h.publishTicks(moment.Truncate(time.Minute))
+		}
+	}
+}
+
+func (h *Handler) publishTicks(moment time.Time) {
+	for _, schedule := range h.schedules {
+		if !schedule.Expression.Match(moment) {
+			continue
+		}
+
+		params, _ := json.Marshal(TickParams{
+			Time: moment.UnixNano(),
+			Rule: schedule.Rule,
+		})
+
+		err := h.cubeInstance.PublishMessage(cube.Channel(schedule.Channel), cube.Message{
+			Version: Version,
+			Method:  "tick",
+			Params:  (*json.RawMessage)(&params),
+		})
+
+		if err != nil {
+			h.cubeInstance.LogError("can't publish tick to " + schedule.Channel + ": " + err.Error())
+		}
+	}
+}
+
+var _ cube.HandlerInterface = (*Handler)(nil)
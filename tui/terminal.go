@@ -0,0 +1,93 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// stty changes terminal of stdin, there is no terminal package in vendor
+func stty(args ...string) (string, error) {
+	command := exec.Command("stty", args...)
+	command.Stdin = os.Stdin
+
+	output, err := command.Output()
+	if err != nil {
+		return "", fmt.Errorf("can't run stty %v: %v", strings.Join(args, " "), err)
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+// makeRaw turns off echo and line buffering of terminal, keys are read as soon as they're pressed.
+// It returns state of terminal, which is restored by restoreTerminal.
+func makeRaw() (string, error) {
+	state, err := stty("-g")
+	if err != nil {
+		return "", fmt.Errorf("cubes tui needs terminal: %v", err)
+	}
+
+	_, err = stty("-icanon", "-echo", "min", "1")
+	if err != nil {
+		return "", err
+	}
+
+	return state, nil
+}
+
+func restoreTerminal(state string) {
+	stty(state)
+}
+
+// getSize returns width and height of terminal
+func getSize() (int, int, error) {
+	size, err := stty("size")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var width, height int
+
+	_, err = fmt.Sscanf(size, "%d %d", &height, &width)
+	if err != nil {
+		return 0, 0, fmt.Errorf("can't parse terminal size '%v': %v", size, err)
+	}
+
+	return width, height, nil
+}
+
+// keys, which aren't printable characters
+const (
+	keyUp   = "up"
+	keyDown = "down"
+	keyTab  = "tab"
+)
+
+// readKeys sends pressed keys until stdin is closed, arrows are sent as keyUp and keyDown
+func readKeys(keys chan<- string) {
+	buffer := make([]byte, 16)
+
+	for {
+		count, err := os.Stdin.Read(buffer)
+		if err != nil {
+			close(keys)
+			return
+		}
+
+		input := string(buffer[:count])
+
+		switch {
+		case input == "\033[A" || input == "\033OA":
+			keys <- keyUp
+		case input == "\033[B" || input == "\033OB":
+			keys <- keyDown
+		case input == "\t":
+			keys <- keyTab
+		case !strings.HasPrefix(input, "\033"):
+			for _, key := range input {
+				keys <- string(key)
+			}
+		}
+	}
+}
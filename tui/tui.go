@@ -0,0 +1,422 @@
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/akaumov/cubes/daemon"
+	"github.com/akaumov/cubes/global"
+	"github.com/akaumov/cubes/instance"
+)
+
+// panes of bottom part of screen: logs of selected instance or messages of bus
+const (
+	paneLogs = "logs"
+	paneBus  = "bus"
+)
+
+const busTailLimit = 200
+
+type instanceRow struct {
+	Name         string
+	Status       string
+	IsSupervised bool
+}
+
+type ui struct {
+	output io.Writer
+	width  int
+	height int
+
+	instances []instanceRow
+	selected  int
+	pane      string
+
+	logs        []string
+	bus         *global.BusTail
+	isDaemon    bool
+	isBusActive bool
+
+	// message is result of the last action, it's shown in footer
+	message string
+}
+
+func (u *ui) getSelected() string {
+	if u.selected < 0 || u.selected >= len(u.instances) {
+		return ""
+	}
+
+	return u.instances[u.selected].Name
+}
+
+// loadInstances reads states of instances from daemon, when it's running, or from their runtimes
+func (u *ui) loadInstances() {
+	rows := []instanceRow{}
+
+	status, err := daemon.GetStatus()
+	u.isDaemon = err == nil
+
+	if u.isDaemon {
+		u.isBusActive = status.IsBusRunning
+
+		for _, state := range status.Instances {
+			rows = append(rows, instanceRow{
+				Name:         state.Name,
+				Status:       state.Status,
+				IsSupervised: state.IsSupervised,
+			})
+		}
+	} else {
+		busStatus, err := global.GetBusStatus()
+		u.isBusActive = err == nil && busStatus.IsRunning
+
+		names, err := instance.GetNames()
+		if err != nil {
+			u.message = err.Error()
+		}
+
+		for _, name := range names {
+			status, err := instance.GetStatus(name)
+			if err != nil {
+				status = instance.StatusUnknown
+			}
+
+			rows = append(rows, instanceRow{
+				Name:   name,
+				Status: status,
+			})
+		}
+	}
+
+	// selection follows instance, when instances are added or removed
+	selectedName := u.getSelected()
+	u.instances = rows
+	u.selected = 0
+
+	for i, row := range rows {
+		if row.Name == selectedName {
+			u.selected = i
+		}
+	}
+}
+
+// loadLogs reads logs of selected instance again, only their tail is kept
+func (u *ui) loadLogs(limit int) {
+	name := u.getSelected()
+	if name == "" {
+		u.logs = []string{}
+		return
+	}
+
+	var output bytes.Buffer
+
+	err := instance.Logs(name, false, &output)
+	if err != nil {
+		u.logs = []string{"can't read logs: " + err.Error()}
+		return
+	}
+
+	lines := strings.Split(strings.TrimRight(output.String(), "\n"), "\n")
+	if len(lines) > limit {
+		lines = lines[len(lines)-limit:]
+	}
+
+	u.logs = lines
+}
+
+// connectBus subscribes to bus, it's tried again on every refresh until bus is running
+func (u *ui) connectBus() {
+	if u.bus != nil && u.bus.IsConnected() {
+		return
+	}
+
+	if u.bus != nil {
+		u.bus.Close()
+		u.bus = nil
+	}
+
+	if !u.isBusActive {
+		return
+	}
+
+	bus, err := global.StartBusTail(busTailLimit)
+	if err == nil {
+		u.bus = bus
+	}
+}
+
+func (u *ui) refresh() {
+	width, height, err := getSize()
+	if err == nil {
+		u.width, u.height = width, height
+	}
+
+	u.loadInstances()
+
+	if u.pane == paneLogs {
+		u.loadLogs(u.height)
+	} else {
+		u.connectBus()
+	}
+}
+
+// runAction starts, stops or restarts instance through daemon, when it's running, so daemon keeps supervising it
+func runAction(name string, action string, isDaemon bool) error {
+	start, stop := instance.Start, instance.Stop
+	if isDaemon {
+		start, stop = daemon.StartInstance, daemon.StopInstance
+	}
+
+	switch action {
+	case "start":
+		return start(name)
+	case "stop":
+		return stop(name)
+	}
+
+	err := stop(name)
+	if err != nil {
+		return err
+	}
+
+	return start(name)
+}
+
+func fitLine(line string, width int) string {
+	line = strings.Replace(line, "\t", "    ", -1)
+
+	runes := []rune(line)
+	if len(runes) > width {
+		runes = runes[:width]
+	}
+
+	return string(runes)
+}
+
+func (u *ui) getBusLines(limit int) []string {
+	if u.bus == nil {
+		return []string{"bus isn't running"}
+	}
+
+	lines := []string{}
+
+	for _, message := range u.bus.GetMessages() {
+		data := strings.Replace(message.Data, "\n", " ", -1)
+		if message.IsTruncated {
+			data += "..."
+		}
+
+		lines = append(lines, fmt.Sprintf("%v  %v  %v", message.ReceivedAt.Format("15:04:05"), message.Channel, data))
+	}
+
+	if len(lines) > limit {
+		lines = lines[len(lines)-limit:]
+	}
+
+	return lines
+}
+
+func (u *ui) render() {
+	lines := []string{}
+
+	mode := "instances are controlled directly"
+	if u.isDaemon {
+		mode = "instances are controlled through cubesd"
+	}
+
+	busState := "stopped"
+	if u.isBusActive {
+		busState = "running"
+	}
+
+	lines = append(lines, fmt.Sprintf("cubes  %v  bus: %v  %v", time.Now().Format("15:04:05"), busState, mode))
+	lines = append(lines, "")
+	lines = append(lines, fmt.Sprintf("  %-30v %-12v %v", "INSTANCE", "STATUS", "SUPERVISED"))
+
+	// instances take at most half of screen, the rest is pane
+	listHeight := u.height/2 - len(lines)
+	offset := 0
+	if u.selected >= listHeight {
+		offset = u.selected - listHeight + 1
+	}
+
+	for i := offset; i < len(u.instances) && i-offset < listHeight; i++ {
+		row := u.instances[i]
+
+		cursor := "  "
+		if i == u.selected {
+			cursor = "> "
+		}
+
+		lines = append(lines, fmt.Sprintf("%v%-30v %-12v %v", cursor, row.Name, row.Status, row.IsSupervised))
+	}
+
+	if len(u.instances) == 0 {
+		lines = append(lines, "  project has no instances")
+	}
+
+	lines = append(lines, "")
+
+	if u.pane == paneLogs {
+		lines = append(lines, "Logs of "+u.getSelected())
+	} else {
+		lines = append(lines, "Bus messages")
+	}
+
+	// footer takes two lines
+	paneHeight := u.height - len(lines) - 2
+	if paneHeight < 0 {
+		paneHeight = 0
+	}
+
+	paneLines := u.logs
+	if u.pane == paneBus {
+		paneLines = u.getBusLines(paneHeight)
+	}
+
+	if len(paneLines) > paneHeight {
+		paneLines = paneLines[len(paneLines)-paneHeight:]
+	}
+
+	lines = append(lines, paneLines...)
+
+	for len(lines) < u.height-2 {
+		lines = append(lines, "")
+	}
+
+	lines = append(lines, u.message)
+	lines = append(lines, "up/down, j/k select  s start  x stop  r restart  tab logs/bus  q quit")
+
+	var screen bytes.Buffer
+
+	// move cursor home, every line is cleared to its end
+	screen.WriteString("\033[H")
+
+	for i, line := range lines {
+		if i >= u.height {
+			break
+		}
+
+		screen.WriteString(fitLine(line, u.width) + "\033[K")
+		if i < u.height-1 {
+			screen.WriteString("\n")
+		}
+	}
+
+	u.output.Write(screen.Bytes())
+}
+
+type actionResult struct {
+	name   string
+	action string
+	err    error
+}
+
+// Run shows full-screen interface of project until q or Ctrl+C is pressed: instances with their statuses,
+// logs of selected instance or tail of bus messages, keys start, stop and restart selected instance
+func Run(interval time.Duration) error {
+	state, err := makeRaw()
+	if err != nil {
+		return err
+	}
+
+	defer restoreTerminal(state)
+
+	u := ui{
+		output: os.Stdout,
+		width:  80,
+		height: 24,
+		pane:   paneLogs,
+	}
+
+	defer func() {
+		if u.bus != nil {
+			u.bus.Close()
+		}
+	}()
+
+	// alternate screen keeps shell output, cursor is hidden while interface is shown
+	fmt.Fprint(u.output, "\033[?1049h\033[?25l")
+	defer fmt.Fprint(u.output, "\033[?25h\033[?1049l")
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	keys := make(chan string, 16)
+	go readKeys(keys)
+
+	results := make(chan actionResult, 4)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	u.refresh()
+	u.render()
+
+	for {
+		select {
+		case <-interrupt:
+			return nil
+		case <-ticker.C:
+			u.refresh()
+		case result := <-results:
+			u.message = result.action + " " + result.name + ": done"
+			if result.err != nil {
+				u.message = result.action + " " + result.name + ": " + result.err.Error()
+			}
+
+			u.refresh()
+		case key, ok := <-keys:
+			if !ok {
+				return nil
+			}
+
+			switch key {
+			case "q", "Q":
+				return nil
+			case keyUp, "k":
+				if u.selected > 0 {
+					u.selected--
+				}
+			case keyDown, "j":
+				if u.selected < len(u.instances)-1 {
+					u.selected++
+				}
+			case keyTab:
+				if u.pane == paneLogs {
+					u.pane = paneBus
+				} else {
+					u.pane = paneLogs
+				}
+			case "s", "x", "r":
+				name := u.getSelected()
+				if name == "" {
+					break
+				}
+
+				action := map[string]string{"s": "start", "x": "stop", "r": "restart"}[key]
+				u.message = action + " " + name + "..."
+
+				go func(isDaemon bool) {
+					results <- actionResult{
+						name:   name,
+						action: action,
+						err:    runAction(name, action, isDaemon),
+					}
+				}(u.isDaemon)
+			}
+
+			if key == keyUp || key == keyDown || key == "j" || key == "k" || key == keyTab {
+				u.refresh()
+			}
+		}
+
+		u.render()
+	}
+}
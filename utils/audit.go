@@ -0,0 +1,165 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+)
+
+const auditFileName = "audit.log"
+
+// sources of audit entries: cubes command or cubesd API
+const (
+	AuditSourceCli    = "cli"
+	AuditSourceDaemon = "cubesd"
+)
+
+// results of audited operations, operation is recorded as started before it runs, so operations, which crash or
+// hang, are in audit log too
+const (
+	AuditStarted = "started"
+	AuditOk      = "ok"
+	AuditFailed  = "failed"
+)
+
+// AuditEntry is one state-changing operation of project
+type AuditEntry struct {
+	Time   time.Time `json:"time"`
+	User   string    `json:"user"`
+	Host   string    `json:"host"`
+	Source string    `json:"source"`
+
+	// Client is address of cubesd API client, it's empty for cubes command
+	Client  string            `json:"client,omitempty"`
+	Command string            `json:"command"`
+	Args    []string          `json:"args,omitempty"`
+	Flags   map[string]string `json:"flags,omitempty"`
+	Result  string            `json:"result"`
+	Error   string            `json:"error,omitempty"`
+}
+
+// getAuditPath returns audit log of project in working directory. Commands outside of projects, like workspace
+// commands, are recorded to audit log of user next to workspace registry, so .cubes isn't created in any directory.
+func getAuditPath() (string, error) {
+	pwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	if !IsProjectDirectory(pwd) {
+		workspacePath, err := getWorkspacePath()
+		if err != nil {
+			return "", err
+		}
+
+		err = os.MkdirAll(filepath.Dir(workspacePath), 0777)
+		if err != nil {
+			return "", err
+		}
+
+		return filepath.Join(filepath.Dir(workspacePath), auditFileName), nil
+	}
+
+	stateDirectory, err := GetStateDirectoryPath()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(stateDirectory, auditFileName), nil
+}
+
+// GetCurrentUser returns name of user, who runs cubes
+func GetCurrentUser() string {
+	currentUser, err := user.Current()
+	if err == nil {
+		return currentUser.Username
+	}
+
+	return os.Getenv("USER")
+}
+
+// RecordAudit appends entry to .cubes/audit.log, entries are never changed or removed by cubes.
+// User, host and time are filled when they're empty.
+func RecordAudit(entry AuditEntry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	if entry.User == "" {
+		entry.User = GetCurrentUser()
+	}
+
+	if entry.Host == "" {
+		entry.Host, _ = os.Hostname()
+	}
+
+	auditPath, err := getAuditPath()
+	if err != nil {
+		return err
+	}
+
+	rawEntry, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(auditPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	_, err = file.Write(append(rawEntry, '\n'))
+	return err
+}
+
+// GetAuditEntries returns entries recorded since time, the oldest is first, broken lines are skipped
+func GetAuditEntries(since time.Time) ([]AuditEntry, error) {
+	auditPath, err := getAuditPath()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []AuditEntry{}
+
+	file, err := os.Open(auditPath)
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		var entry AuditEntry
+
+		err = json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			Warningf("Line %v of %v is broken: %v\n", line, auditPath, err)
+			continue
+		}
+
+		if entry.Time.Before(since) {
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("can't read %v: %v", auditPath, err)
+	}
+
+	return entries, nil
+}
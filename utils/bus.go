@@ -0,0 +1,212 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akaumov/cubes/executor"
+)
+
+// BusMonitoringPort is port of bus http monitoring endpoint in bus container
+const BusMonitoringPort = "8222"
+
+// BusContainerName is name of bus in project network, cube executor connects to it
+const BusContainerName = "cubes-bus"
+
+type BusConnection struct {
+	IP            string `json:"ip"`
+	Port          int    `json:"port"`
+	Name          string `json:"name"`
+	Subscriptions uint32 `json:"subscriptions"`
+	InMessages    uint64 `json:"in_msgs"`
+	OutMessages   uint64 `json:"out_msgs"`
+
+	// PendingBytes are bytes, which bus has for client, but client hasn't read yet
+	PendingBytes int `json:"pending_bytes"`
+
+	Start time.Time `json:"start"`
+
+	// Stop and Reason are set for closed connections
+	Stop   *time.Time `json:"stop,omitempty"`
+	Reason string     `json:"reason,omitempty"`
+
+	// SubscriptionsList are channels client is subscribed to, they can have wildcards
+	SubscriptionsList []string `json:"subscriptions_list"`
+}
+
+type busConnections struct {
+	Connections []BusConnection `json:"connections"`
+}
+
+// BusServerInfo is general information of bus server
+type BusServerInfo struct {
+	Version          string `json:"version"`
+	Port             int    `json:"port"`
+	Connections      int    `json:"connections"`
+	TotalConnections uint64 `json:"total_connections"`
+	Subscriptions    uint32 `json:"subscriptions"`
+	InMessages       int64  `json:"in_msgs"`
+	OutMessages      int64  `json:"out_msgs"`
+	InBytes          int64  `json:"in_bytes"`
+	OutBytes         int64  `json:"out_bytes"`
+	SlowConsumers    int64  `json:"slow_consumers"`
+}
+
+func getBusMonitoring(endpoint string, result interface{}) error {
+	client := http.Client{
+		Timeout: 2 * time.Second,
+	}
+
+	response, err := client.Get("http://" + GetBusHost() + ":" + GetBusMonitoringHostPort() + endpoint)
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("bus monitoring responded with status %v", response.StatusCode)
+	}
+
+	return json.NewDecoder(response.Body).Decode(result)
+}
+
+// GetBusConnections returns clients connected to bus with their message counters and subscriptions
+func GetBusConnections() (*[]BusConnection, error) {
+	var connections busConnections
+
+	err := getBusMonitoring("/connz?subs=1", &connections)
+	if err != nil {
+		return nil, err
+	}
+
+	return &connections.Connections, nil
+}
+
+// GetBusClosedConnections returns the latest closed connections of bus with reasons they're closed
+func GetBusClosedConnections() (*[]BusConnection, error) {
+	var connections busConnections
+
+	err := getBusMonitoring("/connz?state=closed", &connections)
+	if err != nil {
+		return nil, err
+	}
+
+	return &connections.Connections, nil
+}
+
+func GetBusServerInfo() (*BusServerInfo, error) {
+	var info BusServerInfo
+
+	err := getBusMonitoring("/varz", &info)
+	if err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// BusPort is port of bus in project network, cube executor connects to cubes-bus:4444
+const BusPort = "4444"
+
+// GetBusHost returns host, which cubes reaches bus on, it's busHost of project config or localhost
+func GetBusHost() string {
+	host := getBaseProjectConfig().BusHost
+	if host == "" {
+		return "localhost"
+	}
+
+	return host
+}
+
+// IsBusRemote returns true when bus isn't run by this cubes, but is reached on other host
+func IsBusRemote() bool {
+	return GetBusHost() != "localhost"
+}
+
+// GetBusHostPort returns port, which bus is published on host, it's busPort of project config or BusPort
+func GetBusHostPort() string {
+	port := getBaseProjectConfig().BusPort
+	if port == 0 {
+		return BusPort
+	}
+
+	return strconv.Itoa(port)
+}
+
+// GetBusMonitoringHostPort returns port of bus monitoring on host, it's busMonitoringPort of project config or BusMonitoringPort
+func GetBusMonitoringHostPort() string {
+	port := getBaseProjectConfig().BusMonitoringPort
+	if port == 0 {
+		return BusMonitoringPort
+	}
+
+	return strconv.Itoa(port)
+}
+
+// GetBusContainerName returns name of bus container, bus published on its own port gets its own container,
+// so buses of several projects can run side by side
+func GetBusContainerName() string {
+	port := GetBusHostPort()
+	if port == BusPort {
+		return BusContainerName
+	}
+
+	return BusContainerName + "-" + port
+}
+
+// BusProtocolVersion is version of protocol between cube executor and bus, bus of this cubes speaks protocol of its
+// executor
+const BusProtocolVersion = executor.BusProtocolVersion
+
+// MinBusProtocolVersion is the oldest protocol, which bus of this cubes still serves
+const MinBusProtocolVersion = 1
+
+// legacyBusProtocolVersion is protocol of buses and cubes, which were made before protocol was versioned
+const legacyBusProtocolVersion = 1
+
+func getBusProtocolPath() (string, error) {
+	busDirectory, err := GetStateDirectoryPath("bus")
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(busDirectory, "protocol"), nil
+}
+
+// WriteBusProtocol saves protocol of started bus, so instances are checked against bus they connect to,
+// even when cubes is upgraded while bus runs
+func WriteBusProtocol() error {
+	protocolPath, err := getBusProtocolPath()
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(protocolPath, []byte(strconv.Itoa(BusProtocolVersion)), 0644)
+}
+
+// GetBusProtocol returns protocol of running bus
+func GetBusProtocol() int {
+	protocolPath, err := getBusProtocolPath()
+	if err != nil {
+		return legacyBusProtocolVersion
+	}
+
+	rawProtocol, err := ioutil.ReadFile(protocolPath)
+	if err != nil {
+		return legacyBusProtocolVersion
+	}
+
+	protocol, err := strconv.Atoi(strings.TrimSpace(string(rawProtocol)))
+	if err != nil {
+		return legacyBusProtocolVersion
+	}
+
+	return protocol
+}
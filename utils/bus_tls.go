@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+)
+
+const (
+	BusTLSCAFile         = "ca.pem"
+	BusTLSServerCertFile = "server.pem"
+	BusTLSServerKeyFile  = "server-key.pem"
+	BusTLSClientCertFile = "client.pem"
+	BusTLSClientKeyFile  = "client-key.pem"
+)
+
+// BusCertsPath is directory where bus and instances containers find their certificates
+const BusCertsPath = "/certs"
+
+// GetBusTLSDirectoryPath returns .cubes/tls/bus directory, which keeps bus server and clients certificates
+func GetBusTLSDirectoryPath() (string, error) {
+	return GetStateDirectoryPath("tls", "bus")
+}
+
+func isFileExist(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// IsBusTLSEnabled returns true when bus server certificate and key are present
+func IsBusTLSEnabled() (bool, error) {
+	directory, err := GetBusTLSDirectoryPath()
+	if err != nil {
+		return false, err
+	}
+
+	return isFileExist(filepath.Join(directory, BusTLSServerCertFile)) &&
+		isFileExist(filepath.Join(directory, BusTLSServerKeyFile)), nil
+}
+
+// IsBusClientVerified returns true when bus requires clients certificates signed by CA
+func IsBusClientVerified() (bool, error) {
+	directory, err := GetBusTLSDirectoryPath()
+	if err != nil {
+		return false, err
+	}
+
+	return isFileExist(filepath.Join(directory, BusTLSCAFile)), nil
+}
@@ -1,21 +1,36 @@
 package utils
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"github.com/docker/docker/api/types"
 	docker_client "github.com/docker/docker/client"
 	"golang.org/x/net/context"
 	"io"
-	"log"
 	"os"
+	"strings"
 )
 
+// PingDocker checks that docker service is reachable
+func PingDocker() error {
+	client, err := docker_client.NewEnvClient()
+	if err != nil {
+		return err
+	}
+
+	defer client.Close()
+
+	_, err = client.Ping(context.Background())
+	return err
+}
+
 func PullImage(image string) error {
 	ctx := context.Background()
 	client, err := docker_client.NewEnvClient()
 
 	if err != nil {
-		log.Fatalf("can't connect to docker service:\n%v", err)
-		return err
+		return ConnectionError(fmt.Errorf("can't connect to docker service: %v", err))
 	}
 
 	out, err := client.ImagePull(ctx, image, types.ImagePullOptions{})
@@ -30,3 +45,159 @@ func PullImage(image string) error {
 
 	return nil
 }
+
+func InspectContainer(name string) (*types.ContainerJSON, error) {
+	ctx := context.Background()
+	client, err := docker_client.NewEnvClient()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer client.Close()
+
+	containerInfo, err := client.ContainerInspect(ctx, name)
+	if err != nil {
+		if docker_client.IsErrContainerNotFound(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return &containerInfo, nil
+}
+
+func GetImageDigest(image string) (string, error) {
+	ctx := context.Background()
+	client, err := docker_client.NewEnvClient()
+
+	if err != nil {
+		return "", err
+	}
+
+	defer client.Close()
+
+	imageInfo, _, err := client.ImageInspectWithRaw(ctx, image)
+	if err != nil {
+		return "", err
+	}
+
+	repository := GetImageRepository(image)
+
+	for _, repoDigest := range imageInfo.RepoDigests {
+		parts := strings.SplitN(repoDigest, "@", 2)
+		if len(parts) == 2 && (parts[0] == repository || strings.HasSuffix(parts[0], "/"+repository)) {
+			return parts[1], nil
+		}
+	}
+
+	return "", fmt.Errorf("image %v has no registry digest", image)
+}
+
+// GetImageRepository strips tag and digest from image reference
+func GetImageRepository(image string) string {
+	if index := strings.Index(image, "@"); index != -1 {
+		image = image[:index]
+	}
+
+	lastSlashIndex := strings.LastIndex(image, "/")
+	if index := strings.LastIndex(image, ":"); index > lastSlashIndex {
+		image = image[:index]
+	}
+
+	return image
+}
+
+func GetImageLabels(image string) (map[string]string, error) {
+	ctx := context.Background()
+	client, err := docker_client.NewEnvClient()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer client.Close()
+
+	imageInfo, _, err := client.ImageInspectWithRaw(ctx, image)
+	if err != nil {
+		return nil, err
+	}
+
+	if imageInfo.Config == nil {
+		return map[string]string{}, nil
+	}
+
+	return imageInfo.Config.Labels, nil
+}
+
+// EnvRegistryUsername and EnvRegistryPassword are credentials of docker registry, which images are pushed to
+const (
+	EnvRegistryUsername = "CUBES_REGISTRY_USERNAME"
+	EnvRegistryPassword = "CUBES_REGISTRY_PASSWORD"
+)
+
+// ReadDockerStream prints progress of docker build or push and returns error, which docker reports in stream
+func ReadDockerStream(stream io.Reader) error {
+	decoder := json.NewDecoder(stream)
+
+	for {
+		var message struct {
+			Stream string `json:"stream"`
+			Status string `json:"status"`
+			Error  string `json:"error"`
+		}
+
+		err := decoder.Decode(&message)
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if message.Error != "" {
+			return fmt.Errorf("%v", message.Error)
+		}
+
+		if message.Stream != "" {
+			Debugf("%v", message.Stream)
+		} else if message.Status != "" {
+			Debugf("%v\n", message.Status)
+		}
+	}
+}
+
+// PushImage pushes image to its registry with credentials from environment
+func PushImage(image string) error {
+	ctx := context.Background()
+	client, err := docker_client.NewEnvClient()
+
+	if err != nil {
+		return err
+	}
+
+	defer client.Close()
+
+	rawAuth, err := json.Marshal(types.AuthConfig{
+		Username: os.Getenv(EnvRegistryUsername),
+		Password: os.Getenv(EnvRegistryPassword),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	out, err := client.ImagePush(ctx, image, types.ImagePushOptions{
+		RegistryAuth: base64.URLEncoding.EncodeToString(rawAuth),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	defer out.Close()
+
+	return ReadDockerStream(out)
+}
@@ -0,0 +1,22 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// EnvDryRun makes destructive commands print files they'd delete, SQL they'd run and processes they'd signal
+// instead of doing it
+const EnvDryRun = "CUBES_DRY_RUN"
+
+// IsDryRun returns true when command only prints what it would do
+func IsDryRun() bool {
+	isDryRun, _ := strconv.ParseBool(os.Getenv(EnvDryRun))
+	return isDryRun
+}
+
+// DryRunf prints step, which command would do, to stdout, so it isn't hidden by --quiet and log file
+func DryRunf(format string, args ...interface{}) {
+	fmt.Fprintf(AboveProgress(os.Stdout), "[dry run] "+format, args...)
+}
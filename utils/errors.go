@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// codes of errors, they're printed in json log and --json output of failed command
+const (
+	ErrorFailure    = "failure"
+	ErrorConfig     = "config"
+	ErrorValidation = "validation"
+	ErrorConnection = "connection"
+	ErrorPartial    = "partial"
+)
+
+// exit codes of cubes by codes of errors, they're kept stable, so wrappers and CI can branch on type of failure.
+// Plugins and cubes exec exit with exit codes of their commands.
+var exitCodes = map[string]int{
+	ErrorFailure:    1,
+	ErrorConfig:     2,
+	ErrorValidation: 3,
+	ErrorConnection: 4,
+	ErrorPartial:    5,
+}
+
+// ExitCodesDescription is shown in help of cubes
+const ExitCodesDescription = `exit codes:
+     0  success
+     1  failure, which has no other code
+     2  config error: project, instance or workspace config can't be read or is wrong
+     3  validation error: wrong arguments, or lint, doctor, test or contracts found problems
+     4  connection error: docker, database, bus, agent or registry isn't reachable
+     5  partial failure: some of instances were handled before command failed
+   Failed command with --json or --log-format json writes error as json object with code and exitCode to stderr.`
+
+// CodedError is error of command with code of its type
+type CodedError struct {
+	Code string
+	Err  error
+}
+
+func (e *CodedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CodedError) Unwrap() error {
+	return e.Err
+}
+
+func newCodedError(code string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &CodedError{
+		Code: code,
+		Err:  err,
+	}
+}
+
+func ConfigError(err error) error {
+	return newCodedError(ErrorConfig, err)
+}
+
+func ValidationError(err error) error {
+	return newCodedError(ErrorValidation, err)
+}
+
+func ConnectionError(err error) error {
+	return newCodedError(ErrorConnection, err)
+}
+
+// IsConnectionError returns true when error is wrapped with ConnectionError, its message isn't checked
+func IsConnectionError(err error) bool {
+	var codedError *CodedError
+	return errors.As(err, &codedError) && codedError.Code == ErrorConnection
+}
+
+// PartialError returns error of command over total instances, which failed after handled ones,
+// error is returned as is, when nothing is handled
+func PartialError(handled int, total int, err error) error {
+	if handled == 0 {
+		return err
+	}
+
+	return newCodedError(ErrorPartial, fmt.Errorf("%v of %v instances are handled: %v", handled, total, err))
+}
+
+// errors of packages are wrapped as text, so their type is recognized by their messages
+var errorMessageCodes = []struct {
+	code      string
+	fragments []string
+}{
+	{ErrorConnection, []string{
+		"can't connect to", "cannot connect to the docker daemon", "connection refused", "no such host",
+		"i/o timeout", "isn't reachable",
+	}},
+	{ErrorConfig, []string{
+		"project config", "instance config", "instance file is not exist", "project.json", "cubes.lock",
+		"workspace", "unknown runtime", "profile",
+	}},
+	{ErrorValidation, []string{
+		"is required", "flag provided but not defined", "wrong", "invalid", "doesn't support --dry-run",
+	}},
+}
+
+// GetErrorCode returns code of error: code of CodedError or code recognized by message, failure is default
+func GetErrorCode(err error) string {
+	var codedError *CodedError
+	if errors.As(err, &codedError) {
+		return codedError.Code
+	}
+
+	var netError net.Error
+	if errors.As(err, &netError) {
+		return ErrorConnection
+	}
+
+	message := strings.ToLower(err.Error())
+
+	for _, messageCode := range errorMessageCodes {
+		for _, fragment := range messageCode.fragments {
+			if strings.Contains(message, strings.ToLower(fragment)) {
+				return messageCode.code
+			}
+		}
+	}
+
+	return ErrorFailure
+}
+
+// GetExitCode returns exit code of cubes for code of error
+func GetExitCode(code string) int {
+	if exitCode, ok := exitCodes[code]; ok {
+		return exitCode
+	}
+
+	return exitCodes[ErrorFailure]
+}
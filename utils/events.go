@@ -0,0 +1,221 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// types of lifecycle events of project
+const (
+	EventInstanceStarted  = "instance.started"
+	EventInstanceStopped  = "instance.stopped"
+	EventInstanceCrashed  = "instance.crashed"
+	EventMigrationApplied = "migration.applied"
+	EventMigrationFailed  = "migration.failed"
+	EventBusUp            = "bus.up"
+	EventBusDown          = "bus.down"
+)
+
+const eventsFileName = "events.log"
+
+// eventsPollInterval is interval of checks of events file for new events
+const eventsPollInterval = 500 * time.Millisecond
+
+// Event is lifecycle event of project, Subject is name of instance, id of migration or bus
+type Event struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	Subject string    `json:"subject,omitempty"`
+	Message string    `json:"message,omitempty"`
+
+	// Profile is profile of project, which process emitted event with
+	Profile string `json:"profile,omitempty"`
+}
+
+func getEventsPath() (string, error) {
+	stateDirectory, err := GetStateDirectoryPath()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(stateDirectory, eventsFileName), nil
+}
+
+// EmitEvent appends event to .cubes/events.log, which is shared by cubes commands, cubesd and bus processes
+// of project, so events are seen by subscribers in other processes. Events can't fail operations, they're
+// only logged when they can't be written.
+func EmitEvent(eventType string, subject string, message string) {
+	event := Event{
+		Time:    time.Now(),
+		Type:    eventType,
+		Subject: subject,
+		Message: message,
+		Profile: GetProfile(),
+	}
+
+	Debugf("Event %v %v\n", eventType, subject)
+
+	eventsPath, err := getEventsPath()
+	if err != nil {
+		Warningf("Can't emit event %v: %v\n", eventType, err)
+		return
+	}
+
+	rawEvent, err := json.Marshal(event)
+	if err != nil {
+		Warningf("Can't emit event %v: %v\n", eventType, err)
+		return
+	}
+
+	file, err := os.OpenFile(eventsPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		Warningf("Can't emit event %v: %v\n", eventType, err)
+		return
+	}
+
+	defer file.Close()
+
+	_, err = file.Write(append(rawEvent, '\n'))
+	if err != nil {
+		Warningf("Can't emit event %v: %v\n", eventType, err)
+	}
+}
+
+// IsEventMatched matches event type with filter, filter can end with '*': instance.*
+func IsEventMatched(filter string, eventType string) bool {
+	if filter == "" || filter == eventType {
+		return true
+	}
+
+	return strings.HasSuffix(filter, "*") && strings.HasPrefix(eventType, strings.TrimSuffix(filter, "*"))
+}
+
+// readEvents reads complete lines of events from reader, it returns number of read bytes
+func readEvents(reader io.Reader, handler func(event Event)) (int64, error) {
+	var offset int64
+
+	buffered := bufio.NewReader(reader)
+
+	for {
+		line, err := buffered.ReadBytes('\n')
+		if err == io.EOF {
+			// incomplete line is read again when it's written to the end
+			return offset, nil
+		}
+
+		if err != nil {
+			return offset, err
+		}
+
+		offset += int64(len(line))
+
+		var event Event
+		if json.Unmarshal(line, &event) == nil {
+			handler(event)
+		}
+	}
+}
+
+// GetEvents returns events emitted since time, the oldest is first
+func GetEvents(since time.Time) ([]Event, error) {
+	eventsPath, err := getEventsPath()
+	if err != nil {
+		return nil, err
+	}
+
+	events := []Event{}
+
+	file, err := os.Open(eventsPath)
+	if os.IsNotExist(err) {
+		return events, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	_, err = readEvents(file, func(event Event) {
+		if !event.Time.Before(since) {
+			events = append(events, event)
+		}
+	})
+
+	return events, err
+}
+
+// EventsSubscription calls handler for every new event of project until it's closed
+type EventsSubscription struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+// SubscribeEvents calls handler for events, which are emitted after subscription, from separate goroutine
+func SubscribeEvents(handler func(event Event)) (*EventsSubscription, error) {
+	eventsPath, err := getEventsPath()
+	if err != nil {
+		return nil, err
+	}
+
+	var offset int64
+
+	info, err := os.Stat(eventsPath)
+	if err == nil {
+		offset = info.Size()
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	subscription := EventsSubscription{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(subscription.done)
+
+		ticker := time.NewTicker(eventsPollInterval)
+		defer ticker.Stop()
+
+		for isStopped := false; !isStopped; {
+			// events, which are emitted before subscription is closed, are read once more
+			select {
+			case <-subscription.stop:
+				isStopped = true
+			case <-ticker.C:
+			}
+
+			file, err := os.Open(eventsPath)
+			if err != nil {
+				continue
+			}
+
+			_, err = file.Seek(offset, io.SeekStart)
+			if err == nil {
+				var read int64
+				read, err = readEvents(file, handler)
+				offset += read
+			}
+
+			file.Close()
+
+			if err != nil {
+				Warningf("Can't read events: %v\n", err)
+			}
+		}
+	}()
+
+	return &subscription, nil
+}
+
+// Close stops subscription after events, which are emitted already, are handled, handler isn't called after it returns
+func (subscription *EventsSubscription) Close() {
+	close(subscription.stop)
+	<-subscription.done
+}
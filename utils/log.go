@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// RotatingFile is log file, which is moved to <path>.1 when it grows over max size, older files are shifted up to
+// <path>.<backups> and the oldest one is removed
+type RotatingFile struct {
+	path    string
+	maxSize int64
+	backups int
+
+	mutex sync.Mutex
+	file  *os.File
+	size  int64
+}
+
+func NewRotatingFile(path string, maxSize int64, backups int) (*RotatingFile, error) {
+	rotatingFile := &RotatingFile{
+		path:    path,
+		maxSize: maxSize,
+		backups: backups,
+	}
+
+	err := rotatingFile.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return rotatingFile, nil
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+func (f *RotatingFile) rotate() error {
+	err := f.file.Close()
+	if err != nil {
+		return err
+	}
+
+	for i := f.backups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%v.%v", f.path, i), fmt.Sprintf("%v.%v", f.path, i+1))
+	}
+
+	if f.backups > 0 {
+		err = os.Rename(f.path, f.path+".1")
+	} else {
+		err = os.Remove(f.path)
+	}
+
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return f.open()
+}
+
+func (f *RotatingFile) Write(data []byte) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.size > 0 && f.size+int64(len(data)) > f.maxSize {
+		err := f.rotate()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	written, err := f.file.Write(data)
+	f.size += int64(written)
+	return written, err
+}
+
+func (f *RotatingFile) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.file.Close()
+}
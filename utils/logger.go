@@ -0,0 +1,175 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// levels of log messages, messages below level of logger aren't written
+const (
+	LogDebug = iota
+	LogInfo
+	LogWarning
+	LogError
+)
+
+// formats of log: text is for humans, json has one object per line for automation
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+var logLevelsNames = map[int]string{
+	LogDebug:   "debug",
+	LogInfo:    "info",
+	LogWarning: "warning",
+	LogError:   "error",
+}
+
+// logger is shared by cubes packages, it's set up by global flags of cubes command
+type logger struct {
+	mutex  sync.Mutex
+	level  int
+	format string
+	output io.Writer
+
+	// progress is drawn under log, it's redrawn after every message
+	progress *Progress
+}
+
+var sharedLogger = logger{
+	level:  LogInfo,
+	format: LogFormatText,
+	output: os.Stderr,
+}
+
+type logEntry struct {
+	Time    string `json:"time"`
+	Level   string `json:"level"`
+	Message string `json:"message"`
+
+	// Code and ExitCode are set for error, which command failed with
+	Code     string `json:"code,omitempty"`
+	ExitCode int    `json:"exitCode,omitempty"`
+}
+
+// SetLogLevel sets the lowest level of messages, which are written: LogDebug for --verbose, LogWarning for --quiet
+func SetLogLevel(level int) {
+	sharedLogger.mutex.Lock()
+	defer sharedLogger.mutex.Unlock()
+
+	sharedLogger.level = level
+}
+
+func SetLogFormat(format string) error {
+	if format != LogFormatText && format != LogFormatJSON {
+		return fmt.Errorf("wrong log format %v, it can be %v or %v", format, LogFormatText, LogFormatJSON)
+	}
+
+	sharedLogger.mutex.Lock()
+	defer sharedLogger.mutex.Unlock()
+
+	sharedLogger.format = format
+	return nil
+}
+
+// SetLogOutput sets writer of log, standard logger of vendored packages writes there too
+func SetLogOutput(output io.Writer) {
+	sharedLogger.mutex.Lock()
+	defer sharedLogger.mutex.Unlock()
+
+	sharedLogger.output = output
+	log.SetOutput(output)
+}
+
+func (l *logger) write(level int, format string, args ...interface{}) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if level < l.level {
+		return
+	}
+
+	message := strings.TrimSuffix(fmt.Sprintf(format, args...), "\n")
+	now := time.Now()
+
+	l.clearProgress()
+	defer l.drawProgress()
+
+	if l.format == LogFormatJSON {
+		entry, err := json.Marshal(logEntry{
+			Time:    now.Format(time.RFC3339Nano),
+			Level:   logLevelsNames[level],
+			Message: message,
+		})
+
+		if err == nil {
+			l.output.Write(append(entry, '\n'))
+			return
+		}
+	}
+
+	switch level {
+	case LogWarning:
+		message = l.colorize(colorYellow, message)
+	case LogError:
+		message = l.colorize(colorRed, message)
+	}
+
+	// text log looks like standard log, which cubes wrote before
+	fmt.Fprintf(l.output, "%v %v\n", now.Format("2006/01/02 15:04:05"), message)
+}
+
+// Debugf logs details, which are written with --verbose only
+func Debugf(format string, args ...interface{}) {
+	sharedLogger.write(LogDebug, format, args...)
+}
+
+// Infof logs progress of command
+func Infof(format string, args ...interface{}) {
+	sharedLogger.write(LogInfo, format, args...)
+}
+
+// Warningf logs problems, which command continues after, they're written even with --quiet
+func Warningf(format string, args ...interface{}) {
+	sharedLogger.write(LogWarning, format, args...)
+}
+
+// Fatalf logs error and exits with exit code of error recognized by its message
+func Fatalf(format string, args ...interface{}) {
+	sharedLogger.write(LogError, format, args...)
+	os.Exit(GetExitCode(GetErrorCode(fmt.Errorf(format, args...))))
+}
+
+// ExitWithError logs error, which command failed with, and exits with exit code of its type.
+// Error is written as json object with its code, when log is json or isJSON is set for command with --json.
+func ExitWithError(err error, isJSON bool) {
+	code := GetErrorCode(err)
+	exitCode := GetExitCode(code)
+
+	sharedLogger.mutex.Lock()
+	isJSONLog := sharedLogger.format == LogFormatJSON
+	sharedLogger.mutex.Unlock()
+
+	if !isJSON && !isJSONLog {
+		sharedLogger.write(LogError, "%v", err)
+		os.Exit(exitCode)
+	}
+
+	entry, _ := json.Marshal(logEntry{
+		Time:     time.Now().Format(time.RFC3339Nano),
+		Level:    logLevelsNames[LogError],
+		Message:  strings.TrimSpace(err.Error()),
+		Code:     code,
+		ExitCode: exitCode,
+	})
+
+	fmt.Fprintln(os.Stderr, string(entry))
+	os.Exit(exitCode)
+}
@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// PluginPrefix is prefix of plugins executables: cubes-deploy on PATH is run by 'cubes deploy'
+const PluginPrefix = "cubes-"
+
+// environment variables, which plugins get from cubes
+const (
+	EnvPluginCubesPath   = "CUBES_PATH"
+	EnvPluginProjectPath = "CUBES_PROJECT_PATH"
+)
+
+type Plugin struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// FindPlugin returns path of plugin executable, it's empty when plugin isn't on PATH
+func FindPlugin(name string) string {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return ""
+	}
+
+	path, err := exec.LookPath(PluginPrefix + name)
+	if err != nil {
+		return ""
+	}
+
+	return path
+}
+
+func isExecutable(info os.FileInfo) bool {
+	return !info.IsDir() && info.Mode()&0111 != 0
+}
+
+// GetPlugins returns plugins on PATH, plugin found first hides plugins with the same name later on PATH
+func GetPlugins() []Plugin {
+	plugins := []Plugin{}
+	isFound := map[string]bool{}
+
+	for _, directory := range filepath.SplitList(os.Getenv("PATH")) {
+		files, err := ioutil.ReadDir(directory)
+		if err != nil {
+			continue
+		}
+
+		for _, file := range files {
+			name := strings.TrimPrefix(file.Name(), PluginPrefix)
+			if !strings.HasPrefix(file.Name(), PluginPrefix) || name == "" || isFound[name] || !isExecutable(file) {
+				continue
+			}
+
+			isFound[name] = true
+			plugins = append(plugins, Plugin{
+				Name: name,
+				Path: filepath.Join(directory, file.Name()),
+			})
+		}
+	}
+
+	sort.Slice(plugins, func(i, j int) bool {
+		return plugins[i].Name < plugins[j].Name
+	})
+
+	return plugins
+}
+
+// RunPlugin runs plugin with arguments in terminal of cubes, plugin gets path of cubes and project in environment,
+// so it can call cubes commands back. Exit code of plugin is returned.
+func RunPlugin(path string, args []string) (int, error) {
+	command := exec.Command(path, args...)
+	command.Stdin = os.Stdin
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+	command.Env = os.Environ()
+
+	if cubesPath, err := os.Executable(); err == nil {
+		command.Env = append(command.Env, EnvPluginCubesPath+"="+cubesPath)
+	}
+
+	if projectPath, err := os.Getwd(); err == nil {
+		command.Env = append(command.Env, EnvPluginProjectPath+"="+projectPath)
+	}
+
+	err := command.Run()
+	if exitError, ok := err.(*exec.ExitError); ok {
+		return exitError.ExitCode(), nil
+	}
+
+	return 0, err
+}
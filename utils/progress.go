@@ -0,0 +1,216 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// EnvNoColor turns off colors of output, when it's set to any value, as --no-color does
+const EnvNoColor = "NO_COLOR"
+
+const (
+	colorReset  = "\033[0m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorCyan   = "\033[36m"
+)
+
+// clearLine moves cursor to start of line and clears it, progress is redrawn over it
+const clearLine = "\r\033[K"
+
+const progressBarWidth = 30
+const progressStepWidth = 40
+const progressRefreshInterval = 100 * time.Millisecond
+
+var spinnerFrames = []string{"|", "/", "-", "\\"}
+
+var isColorDisabled = false
+
+// DisableColor turns off colors of output for --no-color
+func DisableColor() {
+	sharedLogger.mutex.Lock()
+	defer sharedLogger.mutex.Unlock()
+
+	isColorDisabled = true
+}
+
+func isTerminal(output io.Writer) bool {
+	file, ok := output.(*os.File)
+	if !ok || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// isColored returns true when text log is written to terminal and colors aren't turned off
+func (l *logger) isColored() bool {
+	return !isColorDisabled && os.Getenv(EnvNoColor) == "" && l.format == LogFormatText && isTerminal(l.output)
+}
+
+func (l *logger) colorize(color string, text string) string {
+	if !l.isColored() {
+		return text
+	}
+
+	return color + text + colorReset
+}
+
+// Progress is shown under log while long operation runs: bar, when number of steps is known, or spinner.
+// It's shown only when text log is written to terminal, otherwise operation is logged as before.
+type Progress struct {
+	title     string
+	total     int
+	current   int
+	step      string
+	frame     int
+	startedAt time.Time
+	isShown   bool
+	stop      chan struct{}
+}
+
+// StartProgress shows progress of operation with total steps, spinner is shown when total is 0.
+// Only one progress is shown, progress of operation inside other one isn't shown.
+func StartProgress(title string, total int) *Progress {
+	sharedLogger.mutex.Lock()
+	defer sharedLogger.mutex.Unlock()
+
+	progress := &Progress{
+		title:     title,
+		total:     total,
+		startedAt: time.Now(),
+		stop:      make(chan struct{}),
+	}
+
+	if sharedLogger.progress != nil || sharedLogger.format != LogFormatText || sharedLogger.level > LogInfo ||
+		!isTerminal(sharedLogger.output) {
+		return progress
+	}
+
+	progress.isShown = true
+	sharedLogger.progress = progress
+	sharedLogger.drawProgress()
+
+	go progress.refresh()
+	return progress
+}
+
+// refresh redraws progress, so spinner turns and elapsed time grows while step runs
+func (p *Progress) refresh() {
+	ticker := time.NewTicker(progressRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			sharedLogger.mutex.Lock()
+			p.frame++
+			sharedLogger.drawProgress()
+			sharedLogger.mutex.Unlock()
+		}
+	}
+}
+
+// Step shows that next step of operation started
+func (p *Progress) Step(name string) {
+	if !p.isShown {
+		return
+	}
+
+	sharedLogger.mutex.Lock()
+	defer sharedLogger.mutex.Unlock()
+
+	if p.current < p.total {
+		p.current++
+	}
+
+	p.step = name
+	sharedLogger.drawProgress()
+}
+
+// Done removes progress from terminal, log continues on its line
+func (p *Progress) Done() {
+	if !p.isShown {
+		return
+	}
+
+	sharedLogger.mutex.Lock()
+	defer sharedLogger.mutex.Unlock()
+
+	p.isShown = false
+	close(p.stop)
+
+	sharedLogger.clearProgress()
+	sharedLogger.progress = nil
+}
+
+func (p *Progress) format(l *logger) string {
+	elapsed := time.Since(p.startedAt).Truncate(time.Second)
+
+	step := p.step
+	if len(step) > progressStepWidth {
+		step = step[:progressStepWidth-3] + "..."
+	}
+
+	parts := []string{}
+
+	if p.total == 0 {
+		parts = append(parts, l.colorize(colorCyan, spinnerFrames[p.frame%len(spinnerFrames)]), p.title)
+	} else {
+		filled := progressBarWidth * p.current / p.total
+		bar := l.colorize(colorGreen, strings.Repeat("=", filled)) + strings.Repeat(" ", progressBarWidth-filled)
+
+		parts = append(parts, p.title, "["+bar+"]", fmt.Sprintf("%v/%v", p.current, p.total))
+	}
+
+	if step != "" {
+		parts = append(parts, step)
+	}
+
+	return strings.Join(append(parts, elapsed.String()), " ")
+}
+
+// drawProgress draws progress over its line, logger is locked by caller
+func (l *logger) drawProgress() {
+	if l.progress == nil {
+		return
+	}
+
+	fmt.Fprint(l.output, clearLine+l.progress.format(l))
+}
+
+// clearProgress clears line of progress before other output is written, logger is locked by caller
+func (l *logger) clearProgress() {
+	if l.progress == nil {
+		return
+	}
+
+	fmt.Fprint(l.output, clearLine)
+}
+
+// progressWriter writes output above progress, progress is redrawn under it
+type progressWriter struct {
+	output io.Writer
+}
+
+func (w progressWriter) Write(data []byte) (int, error) {
+	sharedLogger.mutex.Lock()
+	defer sharedLogger.mutex.Unlock()
+
+	sharedLogger.clearProgress()
+	defer sharedLogger.drawProgress()
+
+	return w.output.Write(data)
+}
+
+// AboveProgress returns writer of command output, which doesn't mix with progress: output of build, applied migrations
+func AboveProgress(output io.Writer) io.Writer {
+	return progressWriter{output: output}
+}
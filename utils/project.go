@@ -0,0 +1,191 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+const stateDirectoryName = ".cubes"
+
+// GetStateDirectoryPath returns path inside project state directory, creating missing directories
+func GetStateDirectoryPath(subdirectories ...string) (string, error) {
+	pwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	directory := filepath.Join(append([]string{pwd, stateDirectoryName}, subdirectories...)...)
+
+	err = os.MkdirAll(directory, 0777)
+	if err != nil {
+		return "", err
+	}
+
+	return directory, nil
+}
+
+// ProjectConfigFileName is config of project at its root, it's read by every command
+const ProjectConfigFileName = "project.json"
+
+// environment variables, which override project config, so deployments don't need to edit it
+const (
+	EnvBusHost           = "CUBES_BUS_HOST"
+	EnvBusPort           = "CUBES_BUS_PORT"
+	EnvBusMonitoringPort = "CUBES_BUS_MONITORING_PORT"
+	EnvDefaultRuntime    = "CUBES_DEFAULT_RUNTIME"
+	EnvMigrationsPath    = "CUBES_MIGRATIONS_PATH"
+	EnvInstancesPath     = "CUBES_INSTANCES_PATH"
+)
+
+// EnvProfile selects profile of project config: dev, staging, prod, etc
+const EnvProfile = "CUBES_ENV"
+
+// baseProjectConfig is part of project config, which is needed by packages below global: bus address, default runtime
+// and paths, the rest of project config is parsed in global
+type baseProjectConfig struct {
+	BusHost           string `json:"busHost"`
+	BusPort           int    `json:"busPort"`
+	BusMonitoringPort int    `json:"busMonitoringPort"`
+	DefaultRuntime    string `json:"defaultRuntime"`
+	MigrationsPath    string `json:"migrationsPath"`
+	InstancesPath     string `json:"instancesPath"`
+	IsBusAuthEnabled  bool   `json:"isBusAuthEnabled"`
+
+	Profiles map[string]baseProjectProfile `json:"profiles"`
+}
+
+// baseProjectProfile is part of profile, which replaces settings of baseProjectConfig
+type baseProjectProfile struct {
+	BusHost           string `json:"busHost"`
+	BusPort           int    `json:"busPort"`
+	BusMonitoringPort int    `json:"busMonitoringPort"`
+	DefaultRuntime    string `json:"defaultRuntime"`
+
+	// InstanceParams are params of instances by their names, they replace params of instances configs
+	InstanceParams map[string]map[string]string `json:"instanceParams"`
+}
+
+// GetProfile returns name of selected profile, it's empty when project config is used as is
+func GetProfile() string {
+	return os.Getenv(EnvProfile)
+}
+
+// applyProfile replaces settings of config with settings of selected profile, which are set
+func (config *baseProjectConfig) applyProfile() {
+	profile, ok := config.Profiles[GetProfile()]
+	if !ok {
+		return
+	}
+
+	if profile.BusHost != "" {
+		config.BusHost = profile.BusHost
+	}
+
+	if profile.BusPort != 0 {
+		config.BusPort = profile.BusPort
+	}
+
+	if profile.BusMonitoringPort != 0 {
+		config.BusMonitoringPort = profile.BusMonitoringPort
+	}
+
+	if profile.DefaultRuntime != "" {
+		config.DefaultRuntime = profile.DefaultRuntime
+	}
+}
+
+// GetProfileInstanceParams returns params of instance in selected profile, they replace params of instance config
+func GetProfileInstanceParams(name string) map[string]string {
+	return getBaseProjectConfig().Profiles[GetProfile()].InstanceParams[name]
+}
+
+// LookupEnvString replaces value with environment variable when it's set
+func LookupEnvString(name string, value *string) {
+	if envValue, ok := os.LookupEnv(name); ok {
+		*value = envValue
+	}
+}
+
+// LookupEnvInt replaces value with environment variable when it's set, variable must be number
+func LookupEnvInt(name string, value *int) error {
+	envValue, ok := os.LookupEnv(name)
+	if !ok {
+		return nil
+	}
+
+	number, err := strconv.Atoi(envValue)
+	if err != nil {
+		return fmt.Errorf("%v must be number: %v", name, envValue)
+	}
+
+	*value = number
+	return nil
+}
+
+// getBaseProjectConfig reads project config with selected profile and environment overrides,
+// missing or broken config gives defaults
+func getBaseProjectConfig() baseProjectConfig {
+	var config baseProjectConfig
+
+	pwd, err := os.Getwd()
+	if err == nil {
+		rawConfig, err := ioutil.ReadFile(filepath.Join(pwd, ProjectConfigFileName))
+		if err == nil {
+			json.Unmarshal(rawConfig, &config)
+		}
+	}
+
+	config.applyProfile()
+
+	// broken numbers and unknown profiles are reported by global, when whole config is read
+	LookupEnvString(EnvBusHost, &config.BusHost)
+	LookupEnvInt(EnvBusPort, &config.BusPort)
+	LookupEnvInt(EnvBusMonitoringPort, &config.BusMonitoringPort)
+	LookupEnvString(EnvDefaultRuntime, &config.DefaultRuntime)
+	LookupEnvString(EnvMigrationsPath, &config.MigrationsPath)
+	LookupEnvString(EnvInstancesPath, &config.InstancesPath)
+
+	return config
+}
+
+// getProjectPath returns absolute path of project directory, path of config is relative to project root
+func getProjectPath(path string, defaultPath string) (string, error) {
+	if path == "" {
+		path = defaultPath
+	}
+
+	if filepath.IsAbs(path) {
+		return path, nil
+	}
+
+	pwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(pwd, path), nil
+}
+
+// GetMigrationsPath returns directory of migrations, it's migrationsPath of project config or defaultPath
+func GetMigrationsPath(defaultPath string) (string, error) {
+	return getProjectPath(getBaseProjectConfig().MigrationsPath, defaultPath)
+}
+
+// GetInstancesPath returns directory of instances configs, it's instancesPath of project config or defaultPath
+func GetInstancesPath(defaultPath string) (string, error) {
+	return getProjectPath(getBaseProjectConfig().InstancesPath, defaultPath)
+}
+
+// GetDefaultRuntime returns runtime of instances, which don't set it, it's defaultRuntime of project config or defaultRuntime
+func GetDefaultRuntime(defaultRuntime string) string {
+	runtime := getBaseProjectConfig().DefaultRuntime
+	if runtime == "" {
+		return defaultRuntime
+	}
+
+	return runtime
+}
@@ -0,0 +1,349 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// EnvSecretsKey overrides key of secrets store, it's hex of 32 bytes, CI passes it from its own secrets
+const EnvSecretsKey = "CUBES_SECRETS_KEY"
+
+const (
+	secretsFileName    = "secrets.enc"
+	secretsKeyFileName = "secrets.key"
+
+	// keychainService is service of key of secrets store in OS keychain, account is project directory
+	keychainService = "cubes-secrets"
+)
+
+var secretNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// secretReferenceRegexp matches references to secrets in params and database config: ${secret:name}
+var secretReferenceRegexp = regexp.MustCompile(`\$\{secret:([^}]*)\}`)
+
+func getSecretsDirectory() (string, error) {
+	return GetStateDirectoryPath("secrets")
+}
+
+func checkSecretName(name string) error {
+	if !secretNameRegexp.MatchString(name) {
+		return fmt.Errorf("wrong secret name '%v', it can have letters, digits, '_', '.' and '-'", name)
+	}
+
+	return nil
+}
+
+// keychain commands of OS, secret-tool is part of libsecret on linux
+func readKeychain(account string) (string, error) {
+	var command *exec.Cmd
+
+	switch runtime.GOOS {
+	case "darwin":
+		command = exec.Command("security", "find-generic-password", "-s", keychainService, "-a", account, "-w")
+	case "linux":
+		command = exec.Command("secret-tool", "lookup", "service", keychainService, "account", account)
+	default:
+		return "", fmt.Errorf("keychain isn't supported on %v", runtime.GOOS)
+	}
+
+	output, err := command.Output()
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+// quoteKeychainArg quotes argument of command of interactive security tool
+func quoteKeychainArg(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// writeKeychain passes key to keychain command on stdin, so it isn't seen in arguments of processes
+func writeKeychain(account string, key string) error {
+	var command *exec.Cmd
+
+	switch runtime.GOOS {
+	case "darwin":
+		// security reads commands from stdin in interactive mode
+		command = exec.Command("security", "-i")
+		command.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %v -a %v -w %v\n",
+			quoteKeychainArg(keychainService), quoteKeychainArg(account), quoteKeychainArg(key)))
+	case "linux":
+		command = exec.Command("secret-tool", "store", "--label", "cubes secrets of "+account, "service", keychainService, "account", account)
+		command.Stdin = strings.NewReader(key)
+	default:
+		return fmt.Errorf("keychain isn't supported on %v", runtime.GOOS)
+	}
+
+	output, err := command.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v: %v", err, strings.TrimSpace(string(output)))
+	}
+
+	// interactive security exits without status of its commands, so key is read back
+	storedKey, err := readKeychain(account)
+	if err != nil || storedKey != key {
+		return fmt.Errorf("key isn't found in keychain after it's written: %v", strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// getSecretsKey returns key of secrets store from environment, key file or OS keychain.
+// Key is generated when store is created, it's kept in keychain when it's available and in key file otherwise.
+func getSecretsKey(isCreated bool) ([]byte, error) {
+	key := os.Getenv(EnvSecretsKey)
+
+	if key == "" {
+		secretsDirectory, err := getSecretsDirectory()
+		if err != nil {
+			return nil, err
+		}
+
+		keyPath := filepath.Join(secretsDirectory, secretsKeyFileName)
+		account := filepath.Dir(secretsDirectory)
+
+		rawKey, err := ioutil.ReadFile(keyPath)
+		if err == nil {
+			key = strings.TrimSpace(string(rawKey))
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		if key == "" {
+			key, _ = readKeychain(account)
+		}
+
+		if key == "" && !isCreated {
+			return nil, fmt.Errorf("key of secrets store isn't found: set %v, put it to %v or OS keychain", EnvSecretsKey, keyPath)
+		}
+
+		if key == "" {
+			data := make([]byte, 32)
+
+			_, err = rand.Read(data)
+			if err != nil {
+				return nil, err
+			}
+
+			key = hex.EncodeToString(data)
+
+			err = writeKeychain(account, key)
+			if err == nil {
+				Infof("Key of secrets store is generated and kept in OS keychain\n")
+			} else {
+				Debugf("Can't keep key in OS keychain: %v\n", err)
+
+				err = ioutil.WriteFile(keyPath, []byte(key), 0600)
+				if err != nil {
+					return nil, err
+				}
+
+				Infof("Key of secrets store is generated in %v, secrets can't be read without it\n", keyPath)
+			}
+		}
+	}
+
+	rawKey, err := hex.DecodeString(key)
+	if err != nil || len(rawKey) != 32 {
+		return nil, fmt.Errorf("key of secrets store must be hex of 32 bytes")
+	}
+
+	return rawKey, nil
+}
+
+func getSecretsCipher(isCreated bool) (cipher.AEAD, error) {
+	key, err := getSecretsKey(isCreated)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func getSecretsPath() (string, error) {
+	secretsDirectory, err := getSecretsDirectory()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(secretsDirectory, secretsFileName), nil
+}
+
+// readSecrets decrypts secrets store, store without secrets doesn't need key
+func readSecrets() (map[string]string, error) {
+	secretsPath, err := getSecretsPath()
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := ioutil.ReadFile(secretsPath)
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := getSecretsCipher(false)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(encrypted) < aead.NonceSize() {
+		return nil, fmt.Errorf("secrets store %v is broken", secretsPath)
+	}
+
+	nonce, data := encrypted[:aead.NonceSize()], encrypted[aead.NonceSize():]
+
+	rawSecrets, err := aead.Open(nil, nonce, data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("can't decrypt secrets store, key is wrong: %v", err)
+	}
+
+	secrets := map[string]string{}
+
+	err = json.Unmarshal(rawSecrets, &secrets)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse secrets store: %v", err)
+	}
+
+	return secrets, nil
+}
+
+func writeSecrets(secrets map[string]string) error {
+	secretsPath, err := getSecretsPath()
+	if err != nil {
+		return err
+	}
+
+	aead, err := getSecretsCipher(true)
+	if err != nil {
+		return err
+	}
+
+	rawSecrets, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(secretsPath, aead.Seal(nonce, nonce, rawSecrets, nil), 0600)
+}
+
+func SetSecret(name string, value string) error {
+	err := checkSecretName(name)
+	if err != nil {
+		return err
+	}
+
+	secrets, err := readSecrets()
+	if err != nil {
+		return err
+	}
+
+	secrets[name] = value
+	return writeSecrets(secrets)
+}
+
+func GetSecret(name string) (string, error) {
+	secrets, err := readSecrets()
+	if err != nil {
+		return "", err
+	}
+
+	value, ok := secrets[name]
+	if !ok {
+		return "", fmt.Errorf("secret '%v' doesn't exist", name)
+	}
+
+	return value, nil
+}
+
+func RemoveSecret(name string) error {
+	secrets, err := readSecrets()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := secrets[name]; !ok {
+		return fmt.Errorf("secret '%v' doesn't exist", name)
+	}
+
+	delete(secrets, name)
+	return writeSecrets(secrets)
+}
+
+// GetSecretsNames returns sorted names of secrets, values aren't returned
+func GetSecretsNames() ([]string, error) {
+	secrets, err := readSecrets()
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for name := range secrets {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+// HasSecretReference returns true when value refers to secret with ${secret:name}
+func HasSecretReference(value string) bool {
+	return secretReferenceRegexp.MatchString(value)
+}
+
+// ResolveSecrets replaces ${secret:name} references in value with secrets, it's done right before value is used,
+// so secrets aren't written to config files
+func ResolveSecrets(value string) (string, error) {
+	if !HasSecretReference(value) {
+		return value, nil
+	}
+
+	secrets, err := readSecrets()
+	if err != nil {
+		return "", err
+	}
+
+	var resolveError error
+
+	result := secretReferenceRegexp.ReplaceAllStringFunc(value, func(reference string) string {
+		name := secretReferenceRegexp.FindStringSubmatch(reference)[1]
+
+		secret, ok := secrets[name]
+		if !ok && resolveError == nil {
+			resolveError = fmt.Errorf("secret '%v' doesn't exist, set it with 'cubes secret set %v'", name, name)
+		}
+
+		return secret
+	})
+
+	return result, resolveError
+}
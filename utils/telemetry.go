@@ -0,0 +1,271 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// EnvTelemetry turns telemetry off, when it's "0", "off" or "false", even if project enabled it: CI sets it
+const EnvTelemetry = "CUBES_TELEMETRY"
+
+const (
+	telemetrySettingsFileName = "settings.json"
+	telemetryUsageFileName    = "usage.log"
+)
+
+// TelemetrySettings enables telemetry of project, Id is random id of installation, which isn't linked to user or host
+type TelemetrySettings struct {
+	IsEnabled bool      `json:"isEnabled"`
+	Id        string    `json:"id,omitempty"`
+	EnabledAt time.Time `json:"enabledAt"`
+}
+
+// UsageRecord is one run of cubes command, args and flags aren't recorded, so it's anonymous
+type UsageRecord struct {
+	Time           time.Time `json:"time"`
+	Id             string    `json:"id"`
+	Command        string    `json:"command"`
+	DurationMillis float64   `json:"durationMillis"`
+	IsFailed       bool      `json:"isFailed"`
+	Version        string    `json:"version"`
+	Os             string    `json:"os"`
+}
+
+// CommandUsage is usage of command summed over records
+type CommandUsage struct {
+	Command           string  `json:"command"`
+	Runs              int     `json:"runs"`
+	Failures          int     `json:"failures"`
+	AvgDurationMillis float64 `json:"avgDurationMillis"`
+	MaxDurationMillis float64 `json:"maxDurationMillis"`
+}
+
+// getTelemetryPath returns path of telemetry file, directory is created only when file is written,
+// so commands don't create state directory in directories without telemetry
+func getTelemetryPath(fileName string, isWritten bool) (string, error) {
+	if isWritten {
+		telemetryDirectory, err := GetStateDirectoryPath("telemetry")
+		if err != nil {
+			return "", err
+		}
+
+		return filepath.Join(telemetryDirectory, fileName), nil
+	}
+
+	pwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(pwd, stateDirectoryName, "telemetry", fileName), nil
+}
+
+func GetTelemetrySettings() (*TelemetrySettings, error) {
+	settingsPath, err := getTelemetryPath(telemetrySettingsFileName, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var settings TelemetrySettings
+
+	rawSettings, err := ioutil.ReadFile(settingsPath)
+	if os.IsNotExist(err) {
+		return &settings, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(rawSettings, &settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return &settings, nil
+}
+
+// SetTelemetryEnabled enables or disables telemetry of project, records are kept when it's disabled
+func SetTelemetryEnabled(isEnabled bool) error {
+	settings, err := GetTelemetrySettings()
+	if err != nil {
+		return err
+	}
+
+	settings.IsEnabled = isEnabled
+
+	if isEnabled {
+		settings.EnabledAt = time.Now()
+	}
+
+	if isEnabled && settings.Id == "" {
+		data := make([]byte, 8)
+
+		_, err = rand.Read(data)
+		if err != nil {
+			return err
+		}
+
+		settings.Id = hex.EncodeToString(data)
+	}
+
+	settingsPath, err := getTelemetryPath(telemetrySettingsFileName, true)
+	if err != nil {
+		return err
+	}
+
+	rawSettings, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(settingsPath, rawSettings, 0644)
+}
+
+func isTelemetryDisabledByEnv() bool {
+	switch strings.ToLower(os.Getenv(EnvTelemetry)) {
+	case "0", "off", "false":
+		return true
+	}
+
+	return false
+}
+
+// IsTelemetryEnabled returns true only when project enabled telemetry and environment doesn't turn it off
+func IsTelemetryEnabled() bool {
+	if isTelemetryDisabledByEnv() {
+		return false
+	}
+
+	settings, err := GetTelemetrySettings()
+	return err == nil && settings.IsEnabled
+}
+
+// RecordUsage appends run of command to .cubes/telemetry/usage.log, when telemetry is enabled. Records are only
+// kept locally, nothing is sent anywhere.
+func RecordUsage(command string, duration time.Duration, isFailed bool, version string) {
+	if isTelemetryDisabledByEnv() {
+		return
+	}
+
+	settings, err := GetTelemetrySettings()
+	if err != nil || !settings.IsEnabled {
+		return
+	}
+
+	usagePath, err := getTelemetryPath(telemetryUsageFileName, true)
+	if err != nil {
+		return
+	}
+
+	rawRecord, err := json.Marshal(UsageRecord{
+		Time:           time.Now(),
+		Id:             settings.Id,
+		Command:        command,
+		DurationMillis: float64(duration) / float64(time.Millisecond),
+		IsFailed:       isFailed,
+		Version:        version,
+		Os:             runtime.GOOS,
+	})
+
+	if err != nil {
+		return
+	}
+
+	file, err := os.OpenFile(usagePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		Debugf("Can't record usage: %v\n", err)
+		return
+	}
+
+	defer file.Close()
+
+	_, err = file.Write(append(rawRecord, '\n'))
+	if err != nil {
+		Debugf("Can't record usage: %v\n", err)
+	}
+}
+
+// GetUsageRecords returns usage recorded since time, the oldest is first
+func GetUsageRecords(since time.Time) ([]UsageRecord, error) {
+	usagePath, err := getTelemetryPath(telemetryUsageFileName, false)
+	if err != nil {
+		return nil, err
+	}
+
+	records := []UsageRecord{}
+
+	file, err := os.Open(usagePath)
+	if os.IsNotExist(err) {
+		return records, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		var record UsageRecord
+
+		if json.Unmarshal(scanner.Bytes(), &record) != nil || record.Time.Before(since) {
+			continue
+		}
+
+		records = append(records, record)
+	}
+
+	return records, scanner.Err()
+}
+
+// GetCommandsUsage sums records by command, the most used command is first
+func GetCommandsUsage(records []UsageRecord) []CommandUsage {
+	usages := map[string]*CommandUsage{}
+
+	for _, record := range records {
+		usage, ok := usages[record.Command]
+		if !ok {
+			usage = &CommandUsage{Command: record.Command}
+			usages[record.Command] = usage
+		}
+
+		usage.Runs++
+		usage.AvgDurationMillis += record.DurationMillis
+
+		if record.IsFailed {
+			usage.Failures++
+		}
+
+		if record.DurationMillis > usage.MaxDurationMillis {
+			usage.MaxDurationMillis = record.DurationMillis
+		}
+	}
+
+	result := []CommandUsage{}
+	for _, usage := range usages {
+		usage.AvgDurationMillis /= float64(usage.Runs)
+		result = append(result, *usage)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Runs != result[j].Runs {
+			return result[i].Runs > result[j].Runs
+		}
+
+		return result[i].Command < result[j].Command
+	})
+
+	return result
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// GetTLSConfig loads certificate with its key and CA certificate, which is used to verify the other side of connection
+func GetTLSConfig(caFile string, certFile string, keyFile string) (*tls.Config, error) {
+	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("can't load certificate: %v", err)
+	}
+
+	rawCA, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("can't read CA certificate: %v", err)
+	}
+
+	certPool := x509.NewCertPool()
+	if !certPool.AppendCertsFromPEM(rawCA) {
+		return nil, fmt.Errorf("can't parse CA certificate %v", caFile)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		RootCAs:      certPool,
+		ClientCAs:    certPool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// GetClientTLSConfig loads CLI certificates from .cubes/tls: ca.pem, client.pem and client-key.pem
+func GetClientTLSConfig() (*tls.Config, error) {
+	tlsDirectory, err := GetStateDirectoryPath("tls")
+	if err != nil {
+		return nil, err
+	}
+
+	return GetTLSConfig(
+		filepath.Join(tlsDirectory, "ca.pem"),
+		filepath.Join(tlsDirectory, "client.pem"),
+		filepath.Join(tlsDirectory, "client-key.pem"),
+	)
+}
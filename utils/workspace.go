@@ -0,0 +1,269 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// EnvProject selects project of command by name in workspace or by path, like --project
+const EnvProject = "CUBES_PROJECT"
+
+// EnvWorkspace overrides path of workspace registry, it's ~/.cubes/workspace.json by default
+const EnvWorkspace = "CUBES_WORKSPACE"
+
+const workspaceFileName = "workspace.json"
+
+// WorkspaceProject is project registered in workspace of user
+type WorkspaceProject struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// Workspace is registry of projects of user, commands run in Current project, when they're run outside of projects
+type Workspace struct {
+	Current  string             `json:"current,omitempty"`
+	Projects []WorkspaceProject `json:"projects"`
+}
+
+func getWorkspacePath() (string, error) {
+	if path := os.Getenv(EnvWorkspace); path != "" {
+		return path, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("can't find home directory: %v", err)
+	}
+
+	return filepath.Join(home, stateDirectoryName, workspaceFileName), nil
+}
+
+func GetWorkspace() (*Workspace, error) {
+	workspacePath, err := getWorkspacePath()
+	if err != nil {
+		return nil, err
+	}
+
+	workspace := Workspace{
+		Projects: []WorkspaceProject{},
+	}
+
+	rawWorkspace, err := ioutil.ReadFile(workspacePath)
+	if os.IsNotExist(err) {
+		return &workspace, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(rawWorkspace, &workspace)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse %v: %v", workspacePath, err)
+	}
+
+	return &workspace, nil
+}
+
+func saveWorkspace(workspace *Workspace) error {
+	workspacePath, err := getWorkspacePath()
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(workspacePath), 0755)
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(workspace.Projects, func(i, j int) bool {
+		return workspace.Projects[i].Name < workspace.Projects[j].Name
+	})
+
+	rawWorkspace, err := json.MarshalIndent(workspace, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(workspacePath, rawWorkspace, 0644)
+}
+
+// IsProjectDirectory returns true when directory has project config
+func IsProjectDirectory(path string) bool {
+	info, err := os.Stat(filepath.Join(path, ProjectConfigFileName))
+	return err == nil && !info.IsDir()
+}
+
+// getProjectName returns name from project config in directory
+func getProjectName(path string) (string, error) {
+	rawConfig, err := ioutil.ReadFile(filepath.Join(path, ProjectConfigFileName))
+	if err != nil {
+		return "", err
+	}
+
+	var config struct {
+		Name string `json:"name"`
+	}
+
+	err = json.Unmarshal(rawConfig, &config)
+	if err != nil {
+		return "", fmt.Errorf("can't parse project config: %v", err)
+	}
+
+	return config.Name, nil
+}
+
+// AddWorkspaceProject registers project directory in workspace, name is name of project config when it's empty
+func AddWorkspaceProject(name string, path string) (*WorkspaceProject, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !IsProjectDirectory(path) {
+		return nil, fmt.Errorf("%v doesn't have %v", path, ProjectConfigFileName)
+	}
+
+	if name == "" {
+		name, err = getProjectName(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if name == "" {
+		return nil, fmt.Errorf("project doesn't have name, pass --name")
+	}
+
+	workspace, err := GetWorkspace()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, project := range workspace.Projects {
+		if project.Name == name {
+			return nil, fmt.Errorf("project %v is in workspace already: %v", name, project.Path)
+		}
+
+		if project.Path == path {
+			return nil, fmt.Errorf("%v is in workspace already as %v", path, project.Name)
+		}
+	}
+
+	project := WorkspaceProject{
+		Name: name,
+		Path: path,
+	}
+
+	workspace.Projects = append(workspace.Projects, project)
+	return &project, saveWorkspace(workspace)
+}
+
+func RemoveWorkspaceProject(name string) error {
+	workspace, err := GetWorkspace()
+	if err != nil {
+		return err
+	}
+
+	for i, project := range workspace.Projects {
+		if project.Name != name {
+			continue
+		}
+
+		workspace.Projects = append(workspace.Projects[:i], workspace.Projects[i+1:]...)
+		if workspace.Current == name {
+			workspace.Current = ""
+		}
+
+		return saveWorkspace(workspace)
+	}
+
+	return fmt.Errorf("project %v isn't in workspace", name)
+}
+
+// UseWorkspaceProject makes project current, commands outside of projects run in it, empty name clears it
+func UseWorkspaceProject(name string) error {
+	workspace, err := GetWorkspace()
+	if err != nil {
+		return err
+	}
+
+	if name != "" {
+		_, err = workspace.findProject(name)
+		if err != nil {
+			return err
+		}
+	}
+
+	workspace.Current = name
+	return saveWorkspace(workspace)
+}
+
+func (workspace *Workspace) findProject(name string) (*WorkspaceProject, error) {
+	for _, project := range workspace.Projects {
+		if project.Name == name {
+			return &project, nil
+		}
+	}
+
+	return nil, fmt.Errorf("project %v isn't in workspace, add it with 'cubes workspace add'", name)
+}
+
+// GetProjectDirectory returns directory of project by its name in workspace or by path
+func GetProjectDirectory(project string) (string, error) {
+	workspace, err := GetWorkspace()
+	if err != nil {
+		return "", err
+	}
+
+	workspaceProject, err := workspace.findProject(project)
+	if err == nil {
+		return workspaceProject.Path, nil
+	}
+
+	if IsProjectDirectory(project) {
+		return filepath.Abs(project)
+	}
+
+	return "", err
+}
+
+// ChangeToProject changes working directory to project, every package reads project from working directory
+func ChangeToProject(project string) error {
+	directory, err := GetProjectDirectory(project)
+	if err != nil {
+		return err
+	}
+
+	err = os.Chdir(directory)
+	if err != nil {
+		return fmt.Errorf("can't change to project %v: %v", project, err)
+	}
+
+	Debugf("Project directory is %v\n", directory)
+	return nil
+}
+
+// ChangeToCurrentProject changes working directory to current project of workspace, when working directory
+// isn't project, it returns false when current project isn't set
+func ChangeToCurrentProject() (bool, error) {
+	pwd, err := os.Getwd()
+	if err != nil {
+		return false, err
+	}
+
+	if IsProjectDirectory(pwd) {
+		return false, nil
+	}
+
+	workspace, err := GetWorkspace()
+	if err != nil || workspace.Current == "" {
+		return false, err
+	}
+
+	return true, ChangeToProject(workspace.Current)
+}